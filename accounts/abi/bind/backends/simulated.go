@@ -24,7 +24,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/ebakus/ebakusdb"
 	ebakus "github.com/ebakus/go-ebakus"
 	"github.com/ebakus/go-ebakus/accounts/abi/bind"
 	"github.com/ebakus/go-ebakus/common"
@@ -32,6 +31,7 @@ import (
 	"github.com/ebakus/go-ebakus/consensus/ethash"
 	"github.com/ebakus/go-ebakus/core"
 	"github.com/ebakus/go-ebakus/core/bloombits"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/rawdb"
 	"github.com/ebakus/go-ebakus/core/state"
 	"github.com/ebakus/go-ebakus/core/types"
@@ -55,13 +55,13 @@ var (
 // the background. Its main purpose is to allow easily testing contract bindings.
 type SimulatedBackend struct {
 	database   ethdb.Database // In memory database to store our testing data
-	ebakusDb   *ebakusdb.DB
+	ebakusDb   *ebkdb.DB
 	blockchain *core.BlockChain // Ebakus blockchain to handle the consensus
 
 	mu                 sync.Mutex
-	pendingBlock       *types.Block       // Currently pending block that will be imported on request
-	pendingState       *state.StateDB     // Currently pending state that will be the active on on request
-	pendingEbakusState *ebakusdb.Snapshot // Currently pending state that will be the active on on request
+	pendingBlock       *types.Block   // Currently pending block that will be imported on request
+	pendingState       *state.StateDB // Currently pending state that will be the active on on request
+	pendingEbakusState ebkdb.State    // Currently pending state that will be the active on on request
 
 	events *filters.EventSystem // Event system for filtering log events live
 
@@ -70,7 +70,7 @@ type SimulatedBackend struct {
 
 // NewSimulatedBackendWithDatabase creates a new binding backend based on the given database
 // and uses a simulated blockchain for testing purposes.
-func NewSimulatedBackendWithDatabase(database ethdb.Database, ebakusDb *ebakusdb.DB, alloc core.GenesisAlloc, gasLimit uint64) *SimulatedBackend {
+func NewSimulatedBackendWithDatabase(database ethdb.Database, ebakusDb *ebkdb.DB, alloc core.GenesisAlloc, gasLimit uint64) *SimulatedBackend {
 	genesis := core.Genesis{Config: params.AllEthashProtocolChanges, GasLimit: gasLimit, Alloc: alloc}
 	genesis.MustCommit(database, ebakusDb)
 	blockchain, _ := core.NewBlockChain(database, ebakusDb, nil, genesis.Config, ethash.NewFaker(), vm.Config{}, nil)
@@ -89,7 +89,7 @@ func NewSimulatedBackendWithDatabase(database ethdb.Database, ebakusDb *ebakusdb
 // NewSimulatedBackend creates a new binding backend using a simulated blockchain
 // for testing purposes.
 func NewSimulatedBackend(alloc core.GenesisAlloc, gasLimit uint64) *SimulatedBackend {
-	ebakusDb, _ := ebakusdb.OpenInMemory(nil)
+	ebakusDb, _ := ebkdb.OpenInMemory(nil)
 	return NewSimulatedBackendWithDatabase(rawdb.NewMemoryDatabase(), ebakusDb, alloc, gasLimit)
 }
 
@@ -311,7 +311,7 @@ func (b *SimulatedBackend) EstimateGas(ctx context.Context, call ebakus.CallMsg)
 
 // callContract implements common code between normal and pending contract calls.
 // state is modified during execution, make sure to copy it if necessary.
-func (b *SimulatedBackend) callContract(ctx context.Context, call ebakus.CallMsg, block *types.Block, statedb *state.StateDB, ebakusState *ebakusdb.Snapshot) ([]byte, uint64, bool, error) {
+func (b *SimulatedBackend) callContract(ctx context.Context, call ebakus.CallMsg, block *types.Block, statedb *state.StateDB, ebakusState ebkdb.State) ([]byte, uint64, bool, error) {
 	// Ensure message is initialized properly.
 	if call.GasPrice == nil {
 		call.GasPrice = big.NewInt(1)
@@ -477,8 +477,9 @@ type filterBackend struct {
 	bc *core.BlockChain
 }
 
-func (fb *filterBackend) ChainDb() ethdb.Database  { return fb.db }
-func (fb *filterBackend) EventMux() *event.TypeMux { panic("not supported") }
+func (fb *filterBackend) ChainDb() ethdb.Database          { return fb.db }
+func (fb *filterBackend) ChainConfig() *params.ChainConfig { return fb.bc.Config() }
+func (fb *filterBackend) EventMux() *event.TypeMux         { panic("not supported") }
 
 func (fb *filterBackend) HeaderByNumber(ctx context.Context, block rpc.BlockNumber) (*types.Header, error) {
 	if block == rpc.LatestBlockNumber {
@@ -515,6 +516,24 @@ func (fb *filterBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*ty
 	return logs, nil
 }
 
+func (fb *filterBackend) EbakusStateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (ebkdb.State, *types.Header, error) {
+	hash, ok := blockNrOrHash.Hash()
+	if !ok {
+		number, _ := blockNrOrHash.Number()
+		header, _ := fb.HeaderByNumber(ctx, number)
+		if header == nil {
+			return nil, nil, errors.New("header not found")
+		}
+		hash = header.Hash()
+	}
+	header := fb.bc.GetHeaderByHash(hash)
+	if header == nil {
+		return nil, nil, errors.New("header not found")
+	}
+	ebakusState, err := fb.bc.ReadEbakusStateAt(hash, header.Number.Uint64())
+	return ebakusState, header, err
+}
+
 func (fb *filterBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
 	return event.NewSubscription(func(quit <-chan struct{}) error {
 		<-quit
@@ -532,6 +551,7 @@ func (fb *filterBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscr
 }
 
 func (fb *filterBackend) BloomStatus() (uint64, uint64) { return 4096, 0 }
+func (fb *filterBackend) RPCLogsMaxBlocks() uint64      { return 0 }
 func (fb *filterBackend) ServiceFilter(ctx context.Context, ms *bloombits.MatcherSession) {
 	panic("not supported")
 }
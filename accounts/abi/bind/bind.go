@@ -31,6 +31,8 @@ import (
 	"unicode"
 
 	"github.com/ebakus/go-ebakus/accounts/abi"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/core/vm"
 	"github.com/ebakus/go-ebakus/log"
 )
 
@@ -41,6 +43,7 @@ const (
 	LangGo Lang = iota
 	LangJava
 	LangObjC
+	LangJS
 )
 
 // Bind generates a Go wrapper around a contract ABI. This wrapper isn't meant
@@ -61,20 +64,21 @@ func Bind(types []string, abis []string, bytecodes []string, fsigs []map[string]
 			return "", err
 		}
 		// Strip any whitespace from the JSON ABI
-		strippedABI := strings.Map(func(r rune) rune {
-			if unicode.IsSpace(r) {
-				return -1
-			}
-			return r
-		}, abis[i])
+		strippedABI := stripABI(abis[i])
 
 		// Extract the call and transact methods; events, struct definitions; and sort them alphabetically
 		var (
 			calls     = make(map[string]*tmplMethod)
 			transacts = make(map[string]*tmplMethod)
 			events    = make(map[string]*tmplEvent)
+			tables    = make(map[string]*tmplTable)
 			structs   = make(map[string]*tmplStruct)
 		)
+		for _, input := range evmABI.Constructor.Inputs {
+			if hasStruct(input.Type) {
+				bindStructType[lang](input.Type, structs)
+			}
+		}
 		for _, original := range evmABI.Methods {
 			// Normalize the method for capital cases and non-anonymous inputs/outputs
 			normalized := original
@@ -129,20 +133,37 @@ func Bind(types []string, abis []string, bytecodes []string, fsigs []map[string]
 			// Append the event to the accumulator list
 			events[original.Name] = &tmplEvent{Original: original, Normalized: normalized}
 		}
+		for _, original := range evmABI.Tables {
+			// Normalize the table for capital cases, its columns are always named
+			normalized := original
+			normalized.Name = methodNormalizer[lang](original.Name)
+
+			tables[original.Name] = &tmplTable{Original: original, Normalized: normalized}
+		}
 
-		// There is no easy way to pass arbitrary java objects to the Go side.
-		if len(structs) > 0 && lang == LangJava {
-			return "", errors.New("java binding for tuple arguments is not supported yet")
+		// Java structs cross over to the Go side one by one as interface tuples,
+		// there is no way to pass arrays of them.
+		if lang == LangJava {
+			args := append(abi.Arguments{}, evmABI.Constructor.Inputs...)
+			for _, method := range evmABI.Methods {
+				args = append(append(args, method.Inputs...), method.Outputs...)
+			}
+			for _, arg := range args {
+				if hasStructArray(arg.Type) {
+					return "", errors.New("java binding for tuple array arguments is not supported yet")
+				}
+			}
 		}
 
 		contracts[types[i]] = &tmplContract{
 			Type:        capitalise(types[i]),
-			InputABI:    strings.Replace(strippedABI, "\"", "\\\"", -1),
+			InputABI:    escapeABI(strippedABI),
 			InputBin:    strings.TrimPrefix(strings.TrimSpace(bytecodes[i]), "0x"),
 			Constructor: evmABI.Constructor,
 			Calls:       calls,
 			Transacts:   transacts,
 			Events:      events,
+			Tables:      tables,
 			Libraries:   make(map[string]string),
 			Structs:     structs,
 		}
@@ -177,6 +198,11 @@ func Bind(types []string, abis []string, bytecodes []string, fsigs []map[string]
 		Contracts: contracts,
 		Libraries: libs,
 	}
+	// JS bindings wrap the system contract too, as every dapp stakes through it
+	if lang == LangJS {
+		data.SystemABI = escapeABI(stripABI(vm.SystemContractABI))
+		data.SystemAddress = systemContractAddress()
+	}
 	buffer := new(bytes.Buffer)
 
 	funcs := map[string]interface{}{
@@ -187,6 +213,7 @@ func Bind(types []string, abis []string, bytecodes []string, fsigs []map[string]
 		"formatevent":   formatEvent,
 		"capitalise":    capitalise,
 		"decapitalise":  decapitalise,
+		"istuple":       isTuple,
 	}
 	tmpl := template.Must(template.New("").Funcs(funcs).Parse(tmplSource[lang]))
 	if err := tmpl.Execute(buffer, data); err != nil {
@@ -209,6 +236,7 @@ func Bind(types []string, abis []string, bytecodes []string, fsigs []map[string]
 var bindType = map[Lang]func(kind abi.Type, structs map[string]*tmplStruct) string{
 	LangGo:   bindTypeGo,
 	LangJava: bindTypeJava,
+	LangJS:   bindTypeJS,
 }
 
 // bindBasicTypeGo converts basic solidity types(except array, slice and tuple) to Go one.
@@ -326,11 +354,43 @@ func bindTypeJava(kind abi.Type, structs map[string]*tmplStruct) string {
 	}
 }
 
+// bindBasicTypeJS converts basic solidity types(except array, slice and tuple) to
+// the JSDoc types web3 accepts and returns.
+func bindBasicTypeJS(kind abi.Type) string {
+	switch kind.T {
+	case abi.IntTy, abi.UintTy:
+		// Integers which may not fit a double are passed around as decimal strings
+		if kind.Size <= 32 {
+			return "number"
+		}
+		return "string"
+	case abi.BoolTy:
+		return "boolean"
+	default:
+		// addresses, strings and hex encoded byte types
+		return "string"
+	}
+}
+
+// bindTypeJS converts a Solidity type to a JSDoc one, so that the bindings can
+// be type checked by TypeScript too.
+func bindTypeJS(kind abi.Type, structs map[string]*tmplStruct) string {
+	switch kind.T {
+	case abi.TupleTy:
+		return structs[kind.TupleRawName+kind.String()].Name
+	case abi.ArrayTy, abi.SliceTy:
+		return bindTypeJS(*kind.Elem, structs) + "[]"
+	default:
+		return bindBasicTypeJS(kind)
+	}
+}
+
 // bindTopicType is a set of type binders that convert Solidity types to some
 // supported programming language topic types.
 var bindTopicType = map[Lang]func(kind abi.Type, structs map[string]*tmplStruct) string{
 	LangGo:   bindTopicTypeGo,
 	LangJava: bindTopicTypeJava,
+	LangJS:   bindTypeJS,
 }
 
 // bindTopicTypeGo converts a Solidity topic type to a Go one. It is almost the same
@@ -372,6 +432,7 @@ func bindTopicTypeJava(kind abi.Type, structs map[string]*tmplStruct) string {
 var bindStructType = map[Lang]func(kind abi.Type, structs map[string]*tmplStruct) string{
 	LangGo:   bindStructTypeGo,
 	LangJava: bindStructTypeJava,
+	LangJS:   bindStructTypeJS,
 }
 
 // bindStructTypeGo converts a Solidity tuple type to a Go one and records the mapping
@@ -450,6 +511,38 @@ func bindStructTypeJava(kind abi.Type, structs map[string]*tmplStruct) string {
 	}
 }
 
+// bindStructTypeJS converts a Solidity tuple type to a JSDoc one and records the
+// mapping in the given map. The fields keep their raw names, as web3 decodes the
+// tuples into objects keyed by them.
+// Notably, this function will resolve and record nested struct recursively.
+func bindStructTypeJS(kind abi.Type, structs map[string]*tmplStruct) string {
+	switch kind.T {
+	case abi.TupleTy:
+		id := kind.TupleRawName + kind.String()
+		if s, exist := structs[id]; exist {
+			return s.Name
+		}
+		var fields []*tmplField
+		for i, elem := range kind.TupleElems {
+			field := bindStructTypeJS(*elem, structs)
+			fields = append(fields, &tmplField{Type: field, Name: kind.TupleRawNames[i], SolKind: *elem})
+		}
+		name := kind.TupleRawName
+		if name == "" {
+			name = fmt.Sprintf("Struct%d", len(structs))
+		}
+		structs[id] = &tmplStruct{
+			Name:   name,
+			Fields: fields,
+		}
+		return name
+	case abi.ArrayTy, abi.SliceTy:
+		return bindStructTypeJS(*kind.Elem, structs) + "[]"
+	default:
+		return bindBasicTypeJS(kind)
+	}
+}
+
 // namedType is a set of functions that transform language specific types to
 // named versions that my be used inside method names.
 var namedType = map[Lang]func(string, abi.Type) string{
@@ -460,6 +553,9 @@ var namedType = map[Lang]func(string, abi.Type) string{
 // namedTypeJava converts some primitive data types to named variants that can
 // be used as parts of method names.
 func namedTypeJava(javaKind string, solKind abi.Type) string {
+	if solKind.T == abi.TupleTy {
+		return "Tuple"
+	}
 	switch javaKind {
 	case "byte[]":
 		return "Binary"
@@ -488,6 +584,7 @@ func namedTypeJava(javaKind string, solKind abi.Type) string {
 var methodNormalizer = map[Lang]func(string) string{
 	LangGo:   abi.ToCamelCase,
 	LangJava: decapitalise,
+	LangJS:   decapitalise,
 }
 
 // capitalise makes a camel-case string which starts with an upper case character.
@@ -505,6 +602,27 @@ func decapitalise(input string) string {
 	return strings.ToLower(goForm[:1]) + goForm[1:]
 }
 
+// stripABI strips any whitespace from a JSON ABI.
+func stripABI(input string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, input)
+}
+
+// escapeABI escapes the quotes of a JSON ABI, so that it can be embedded in a
+// string literal of the generated code.
+func escapeABI(input string) string {
+	return strings.Replace(input, "\"", "\\\"", -1)
+}
+
+// systemContractAddress returns the hex address of the system contract.
+func systemContractAddress() string {
+	return types.PrecompliledSystemContract.Hex()
+}
+
 // structured checks whether a list of ABI data types has enough information to
 // operate through a proper Go struct or if flat returns are needed.
 func structured(args abi.Arguments) bool {
@@ -543,6 +661,29 @@ func hasStruct(t abi.Type) bool {
 	}
 }
 
+// hasStructArray returns an indicator whether the given type is or contains an
+// array or slice of structs.
+func hasStructArray(t abi.Type) bool {
+	switch t.T {
+	case abi.SliceTy, abi.ArrayTy:
+		return hasStruct(*t.Elem)
+	case abi.TupleTy:
+		for _, elem := range t.TupleElems {
+			if hasStructArray(*elem) {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+// isTuple returns an indicator whether the given type is a struct.
+func isTuple(t abi.Type) bool {
+	return t.T == abi.TupleTy
+}
+
 // resolveArgName converts a raw argument representation into a user friendly format.
 func resolveArgName(arg abi.Argument, structs map[string]*tmplStruct) string {
 	var (
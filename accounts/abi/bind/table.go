@@ -0,0 +1,179 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	ebakus "github.com/ebakus/go-ebakus"
+	"github.com/ebakus/go-ebakus/accounts/abi"
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/core/vm"
+)
+
+// ErrNoTableSelect is returned by table selects if the backend can't iterate
+// over ebakusdb tables.
+var ErrNoTableSelect = errors.New("backend does not support table selects")
+
+// TableSelecter is implemented by backends able to iterate over the rows of an
+// ebakusdb table. The DB precompile can only do so within a single call, so
+// selects from outside the chain have to go through the node's db API.
+type TableSelecter interface {
+	// SelectTable returns the JSON encoded rows of the owner's table matching
+	// the where clause, sorted by the order clause.
+	SelectTable(ctx context.Context, owner common.Address, table string, whereClause string, orderClause string, blockNumber *big.Int) ([]json.RawMessage, error)
+}
+
+// BoundTable is the base wrapper of an ebakusdb table, accessed through the DB
+// precompile. Tables are namespaced by their owner, which is the contract that
+// created them, or the account sending the transactions for tables created by
+// an externally owned account.
+type BoundTable struct {
+	owner   common.Address // Owner of the table namespace
+	name    string         // Name of the table in the owner's namespace
+	table   abi.Table      // Schema of the table rows
+	db      *BoundContract // Binding of the DB precompile
+	backend ContractBackend
+}
+
+// NewBoundTable creates a low level table wrapper through which rows can be
+// read and written.
+func NewBoundTable(owner common.Address, name string, contractABI abi.ABI, backend ContractBackend) (*BoundTable, error) {
+	table, ok := contractABI.Tables[name]
+	if !ok {
+		return nil, fmt.Errorf("table %q not found in abi", name)
+	}
+	dbABI, err := abi.JSON(strings.NewReader(vm.DBABI))
+	if err != nil {
+		return nil, err
+	}
+	return &BoundTable{
+		owner:   owner,
+		name:    name,
+		table:   table,
+		db:      NewBoundContract(types.PrecompliledDBContract, dbABI, backend, backend, backend),
+		backend: backend,
+	}, nil
+}
+
+// Get retrieves into row the first row matching the where clause, sorted by
+// the order clause.
+func (t *BoundTable) Get(opts *CallOpts, row interface{}, whereClause string, orderClause string) error {
+	if opts == nil {
+		opts = new(CallOpts)
+	}
+	input, err := t.db.abi.Pack(vm.DBContractGetCmd, t.name, whereClause, orderClause)
+	if err != nil {
+		return err
+	}
+	var (
+		msg    = ebakus.CallMsg{From: t.owner, To: &t.db.address, Data: input}
+		ctx    = ensureContext(opts.Context)
+		output []byte
+	)
+	if opts.Pending {
+		pb, ok := t.backend.(PendingContractCaller)
+		if !ok {
+			return ErrNoPendingState
+		}
+		output, err = pb.PendingCallContract(ctx, msg)
+	} else {
+		output, err = t.backend.CallContract(ctx, msg, opts.BlockNumber)
+	}
+	if err != nil {
+		return err
+	}
+	// The row is returned prefixed by its 32 bytes long size
+	if len(output) <= 32 {
+		return ebakus.NotFound
+	}
+	return t.table.Inputs.Unpack(row, output[32:])
+}
+
+// Select retrieves all the rows matching the where clause, sorted by the order
+// clause, calling decode to allocate and fill every row. The backend has to
+// implement TableSelecter.
+func (t *BoundTable) Select(opts *CallOpts, whereClause string, orderClause string, decode func(json.RawMessage) error) error {
+	if opts == nil {
+		opts = new(CallOpts)
+	}
+	if opts.Pending {
+		return ErrNoPendingState
+	}
+	selecter, ok := t.backend.(TableSelecter)
+	if !ok {
+		return ErrNoTableSelect
+	}
+	rows, err := selecter.SelectTable(ensureContext(opts.Context), t.owner, t.name, whereClause, orderClause, opts.BlockNumber)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := decode(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Insert inserts a row, replacing any existing one with the same id. The table
+// namespace written to is the one of the transaction sender.
+func (t *BoundTable) Insert(opts *TransactOpts, row interface{}) (*types.Transaction, error) {
+	data, err := t.pack(row)
+	if err != nil {
+		return nil, err
+	}
+	return t.db.Transact(opts, vm.DBContractInsertObjCmd, t.name, data)
+}
+
+// Delete deletes the row with the given id. The table namespace written to is
+// the one of the transaction sender.
+func (t *BoundTable) Delete(opts *TransactOpts, id interface{}) (*types.Transaction, error) {
+	for _, input := range t.table.Inputs {
+		if input.Name != "Id" {
+			continue
+		}
+		packed, err := abi.Arguments{input}.Pack(id)
+		if err != nil {
+			return nil, err
+		}
+		return t.db.Transact(opts, vm.DBContractDeleteObjCmd, t.name, packed)
+	}
+	return nil, fmt.Errorf("table %q has no Id field", t.name)
+}
+
+// pack encodes the fields of a row in the order of the table schema.
+func (t *BoundTable) pack(row interface{}) ([]byte, error) {
+	value := reflect.Indirect(reflect.ValueOf(row))
+
+	values := make([]interface{}, len(t.table.Inputs))
+	for i, input := range t.table.Inputs {
+		field := value.FieldByName(abi.ToCamelCase(input.Name))
+		if !field.IsValid() {
+			return nil, fmt.Errorf("row has no field for column %q", input.Name)
+		}
+		values[i] = field.Interface()
+	}
+	return t.table.Inputs.Pack(values...)
+}
@@ -23,6 +23,9 @@ type tmplData struct {
 	Package   string                   // Name of the package to place the generated file in
 	Contracts map[string]*tmplContract // List of contracts to generate into this file
 	Libraries map[string]string        // Map the bytecode's link pattern to the library name
+
+	SystemABI     string // JSON ABI of the system contract, wrapped by the JS bindings
+	SystemAddress string // Address of the system contract, wrapped by the JS bindings
 }
 
 // tmplContract contains the data needed to generate an individual contract binding.
@@ -35,6 +38,7 @@ type tmplContract struct {
 	Calls       map[string]*tmplMethod // Contract calls that only read state data
 	Transacts   map[string]*tmplMethod // Contract calls that write state data
 	Events      map[string]*tmplEvent  // Contract events accessors
+	Tables      map[string]*tmplTable  // Contract ebakusdb table accessors
 	Libraries   map[string]string      // Same as tmplData, but filtered to only keep what the contract needs
 	Structs     map[string]*tmplStruct // Contract struct type definitions
 	Library     bool
@@ -54,6 +58,13 @@ type tmplEvent struct {
 	Normalized abi.Event // Normalized version of the parsed fields
 }
 
+// tmplTable is a wrapper around an abi.Table that contains the normalized field
+// names of the table rows.
+type tmplTable struct {
+	Original   abi.Table // Original table as parsed by the abi package
+	Normalized abi.Table // Normalized version of the parsed fields
+}
+
 // tmplField is a wrapper around a struct field with binding language
 // struct type definition and relative filed name.
 type tmplField struct {
@@ -74,6 +85,7 @@ type tmplStruct struct {
 var tmplSource = map[Lang]string{
 	LangGo:   tmplSourceGo,
 	LangJava: tmplSourceJava,
+	LangJS:   tmplSourceJS,
 }
 
 // tmplSourceGo is the Go source template use to generate the contract binding
@@ -85,6 +97,7 @@ const tmplSourceGo = `
 package {{.Package}}
 
 import (
+	"encoding/json"
 	"math/big"
 	"strings"
 
@@ -98,6 +111,7 @@ import (
 
 // Reference imports to suppress errors if they are not otherwise used.
 var (
+	_ = json.Unmarshal
 	_ = big.NewInt
 	_ = strings.NewReader
 	_ = ebakus.NotFound
@@ -493,6 +507,67 @@ var (
 		}
 
  	{{end}}
+
+	{{range $table := .Tables}}
+		// {{$contract.Type}}{{.Normalized.Name}} represents a row of the {{.Original.Name}} ebakusdb table.
+		type {{$contract.Type}}{{.Normalized.Name}} struct { {{range .Normalized.Inputs}}
+			{{capitalise .Name}} {{bindtype .Type $structs}}; {{end}}
+		}
+
+		// {{$contract.Type}}{{.Normalized.Name}}Table is an auto generated Go binding around the {{.Original.Name}}
+		// ebakusdb table, accessed through the DB precompile.
+		type {{$contract.Type}}{{.Normalized.Name}}Table struct {
+			table *bind.BoundTable // Generic table wrapper for the low level calls
+		}
+
+		// New{{$contract.Type}}{{.Normalized.Name}}Table creates a new instance of {{$contract.Type}}{{.Normalized.Name}}Table,
+		// bound to the {{.Original.Name}} table in the namespace of owner.
+		func New{{$contract.Type}}{{.Normalized.Name}}Table(owner common.Address, backend bind.ContractBackend) (*{{$contract.Type}}{{.Normalized.Name}}Table, error) {
+			parsed, err := abi.JSON(strings.NewReader({{$contract.Type}}ABI))
+			if err != nil {
+				return nil, err
+			}
+			table, err := bind.NewBoundTable(owner, "{{.Original.Name}}", parsed, backend)
+			if err != nil {
+				return nil, err
+			}
+			return &{{$contract.Type}}{{.Normalized.Name}}Table{table: table}, nil
+		}
+
+		// Get retrieves the first row matching the where clause, sorted by the order clause.
+		func (_{{$contract.Type}}{{.Normalized.Name}}Table *{{$contract.Type}}{{.Normalized.Name}}Table) Get(opts *bind.CallOpts, whereClause string, orderClause string) (*{{$contract.Type}}{{.Normalized.Name}}, error) {
+			row := new({{$contract.Type}}{{.Normalized.Name}})
+			if err := _{{$contract.Type}}{{.Normalized.Name}}Table.table.Get(opts, row, whereClause, orderClause); err != nil {
+				return nil, err
+			}
+			return row, nil
+		}
+
+		// Select retrieves all the rows matching the where clause, sorted by the order clause.
+		func (_{{$contract.Type}}{{.Normalized.Name}}Table *{{$contract.Type}}{{.Normalized.Name}}Table) Select(opts *bind.CallOpts, whereClause string, orderClause string) ([]*{{$contract.Type}}{{.Normalized.Name}}, error) {
+			var rows []*{{$contract.Type}}{{.Normalized.Name}}
+			err := _{{$contract.Type}}{{.Normalized.Name}}Table.table.Select(opts, whereClause, orderClause, func(blob json.RawMessage) error {
+				row := new({{$contract.Type}}{{.Normalized.Name}})
+				if err := json.Unmarshal(blob, row); err != nil {
+					return err
+				}
+				rows = append(rows, row)
+				return nil
+			})
+			return rows, err
+		}
+
+		// Insert inserts a row into the table namespace of the transaction sender.
+		func (_{{$contract.Type}}{{.Normalized.Name}}Table *{{$contract.Type}}{{.Normalized.Name}}Table) Insert(opts *bind.TransactOpts, row *{{$contract.Type}}{{.Normalized.Name}}) (*types.Transaction, error) {
+			return _{{$contract.Type}}{{.Normalized.Name}}Table.table.Insert(opts, row)
+		}
+		{{range .Normalized.Inputs}}{{if eq .Name "Id"}}
+		// Delete deletes the row with the given id from the table namespace of the transaction sender.
+		func (_{{$contract.Type}}{{$table.Normalized.Name}}Table *{{$contract.Type}}{{$table.Normalized.Name}}Table) Delete(opts *bind.TransactOpts, id {{bindtype .Type $structs}}) (*types.Transaction, error) {
+			return _{{$contract.Type}}{{$table.Normalized.Name}}Table.table.Delete(opts, id)
+		}
+		{{end}}{{end}}
+	{{end}}
 {{end}}
 `
 
@@ -538,7 +613,7 @@ import java.util.*;
 		bytecode = bytecode.replace("__${{$pattern}}$__", {{decapitalise $name}}Inst.Address.ebakusex().substring(2));
 		{{end}}
 		{{end}}
-		{{range $index, $element := .Constructor.Inputs}}Interface arg{{$index}} = Ebakus.newInterface();arg{{$index}}.set{{namedtype (bindtype .Type $structs) .Type}}({{.Name}});args.set({{$index}},arg{{$index}});
+		{{range $index, $element := .Constructor.Inputs}}{{if istuple .Type}}Interface arg{{$index}} = {{.Name}}.toInterface();{{else}}Interface arg{{$index}} = Ebakus.newInterface();arg{{$index}}.set{{namedtype (bindtype .Type $structs) .Type}}({{.Name}});{{end}}args.set({{$index}},arg{{$index}});
 		{{end}}
 		return new {{.Type}}(Ebakus.deployContract(auth, ABI, Ebakus.decodeFromHex(bytecode), client, args));
 	}
@@ -564,7 +639,31 @@ import java.util.*;
 	public {{.Type}}(Address address, EbakusClient client) throws Exception {
 		this(Ebakus.bindContract(address, ABI, client));
 	}
+{{range $structs}}
+	// {{.Name}} is an auto generated Java binding around an user-defined struct.
+	public static class {{.Name}} {
+		{{range .Fields}}public {{.Type}} {{.Name}};
+		{{end}}
+		// toInterface converts the struct into the tuple passed to the Go side.
+		Interface toInterface() throws Exception {
+			Interfaces fields = Ebakus.newInterfaces({{(len .Fields)}});
+			{{range $index, $field := .Fields}}{{if istuple .SolKind}}Interface field{{$index}} = this.{{.Name}}.toInterface();{{else}}Interface field{{$index}} = Ebakus.newInterface();field{{$index}}.set{{namedtype .Type .SolKind}}(this.{{.Name}});{{end}}fields.set({{$index}},field{{$index}});
+			{{end}}
+			Interface tuple = Ebakus.newInterface();
+			tuple.setTuple(fields);
+			return tuple;
+		}
 
+		// fromInterface converts a tuple unpacked by the Go side into the struct.
+		static {{.Name}} fromInterface(Interface tuple) throws Exception {
+			Interfaces fields = tuple.getTuple();
+			{{.Name}} result = new {{.Name}}();
+			{{range $index, $field := .Fields}}result.{{.Name}} = {{if istuple .SolKind}}{{.Type}}.fromInterface(fields.get({{$index}})){{else}}fields.get({{$index}}).get{{namedtype .Type .SolKind}}(){{end}};
+			{{end}}
+			return result;
+		}
+	}
+{{end}}
 	{{range .Calls}}
 	{{if gt (len .Normalized.Outputs) 1}}
 	// {{capitalise .Normalized.Name}}Results is the output of a call to {{.Normalized.Name}}.
@@ -579,7 +678,7 @@ import java.util.*;
 	// Solidity: {{.Original.String}}
 	public {{if gt (len .Normalized.Outputs) 1}}{{capitalise .Normalized.Name}}Results{{else}}{{range .Normalized.Outputs}}{{bindtype .Type $structs}}{{end}}{{end}} {{.Normalized.Name}}(CallOpts opts{{range .Normalized.Inputs}}, {{bindtype .Type $structs}} {{.Name}}{{end}}) throws Exception {
 		Interfaces args = Ebakus.newInterfaces({{(len .Normalized.Inputs)}});
-		{{range $index, $item := .Normalized.Inputs}}Interface arg{{$index}} = Ebakus.newInterface();arg{{$index}}.set{{namedtype (bindtype .Type $structs) .Type}}({{.Name}});args.set({{$index}},arg{{$index}});
+		{{range $index, $item := .Normalized.Inputs}}{{if istuple .Type}}Interface arg{{$index}} = {{.Name}}.toInterface();{{else}}Interface arg{{$index}} = Ebakus.newInterface();arg{{$index}}.set{{namedtype (bindtype .Type $structs) .Type}}({{.Name}});{{end}}args.set({{$index}},arg{{$index}});
 		{{end}}
 
 		Interfaces results = Ebakus.newInterfaces({{(len .Normalized.Outputs)}});
@@ -592,10 +691,10 @@ import java.util.*;
 		this.Contract.call(opts, results, "{{.Original.Name}}", args);
 		{{if gt (len .Normalized.Outputs) 1}}
 			{{capitalise .Normalized.Name}}Results result = new {{capitalise .Normalized.Name}}Results();
-			{{range $index, $item := .Normalized.Outputs}}result.{{if ne .Name ""}}{{.Name}}{{else}}Return{{$index}}{{end}} = results.get({{$index}}).get{{namedtype (bindtype .Type $structs) .Type}}();
+			{{range $index, $item := .Normalized.Outputs}}result.{{if ne .Name ""}}{{.Name}}{{else}}Return{{$index}}{{end}} = {{if istuple .Type}}{{bindtype .Type $structs}}.fromInterface(results.get({{$index}})){{else}}results.get({{$index}}).get{{namedtype (bindtype .Type $structs) .Type}}(){{end}};
 			{{end}}
 			return result;
-		{{else}}{{range .Normalized.Outputs}}return results.get(0).get{{namedtype (bindtype .Type $structs) .Type}}();{{end}}
+		{{else}}{{range .Normalized.Outputs}}return {{if istuple .Type}}{{bindtype .Type $structs}}.fromInterface(results.get(0)){{else}}results.get(0).get{{namedtype (bindtype .Type $structs) .Type}}(){{end}};{{end}}
 		{{end}}
 	}
 	{{end}}
@@ -606,7 +705,7 @@ import java.util.*;
 	// Solidity: {{.Original.String}}
 	public Transaction {{.Normalized.Name}}(TransactOpts opts{{range .Normalized.Inputs}}, {{bindtype .Type $structs}} {{.Name}}{{end}}) throws Exception {
 		Interfaces args = Ebakus.newInterfaces({{(len .Normalized.Inputs)}});
-		{{range $index, $item := .Normalized.Inputs}}Interface arg{{$index}} = Ebakus.newInterface();arg{{$index}}.set{{namedtype (bindtype .Type $structs) .Type}}({{.Name}});args.set({{$index}},arg{{$index}});
+		{{range $index, $item := .Normalized.Inputs}}{{if istuple .Type}}Interface arg{{$index}} = {{.Name}}.toInterface();{{else}}Interface arg{{$index}} = Ebakus.newInterface();arg{{$index}}.set{{namedtype (bindtype .Type $structs) .Type}}({{.Name}});{{end}}args.set({{$index}},arg{{$index}});
 		{{end}}
 		return this.Contract.transact(opts, "{{.Original.Name}}"	, args);
 	}
@@ -614,3 +713,275 @@ import java.util.*;
 }
 {{end}}
 `
+
+// tmplSourceJS is the JavaScript source template use to generate the contract
+// binding based on. The bindings are ES modules documented with JSDoc, so that
+// TypeScript projects can type check against them too.
+const tmplSourceJS = `
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+//
+// Module {{.Package}} needs a web3 instance extended by web3-ebakus, which adds
+// the proof of work of the transactions and the ebakusdb queries.
+
+/**
+ * Options of the calls made through the bindings.
+ * @typedef {Object} CallOpts
+ * @property {string} [from] - Address the call is made from
+ * @property {number|string} [blockNumber] - Block the call is made at, latest by default
+ */
+
+/**
+ * Options of the transactions sent through the bindings.
+ * @typedef {Object} TransactOpts
+ * @property {string} from - Address of the sender
+ * @property {string} [privateKey] - Key to sign the transaction with locally, the node's account signs it otherwise
+ * @property {number} [gas] - Gas limit of the transaction, estimated if not set
+ * @property {number} [difficulty] - Work difficulty to target, suggested by the node if not set
+ * @property {number|string} [value] - Amount of wei sent along with the transaction
+ */
+
+/**
+ * sendTransaction computes the proof of work of a transaction and sends it.
+ * @param {Object} web3 - Web3 instance extended by web3-ebakus
+ * @param {string|undefined} to - Recipient of the transaction, none for contract deployments
+ * @param {string} data - Hex encoded input of the transaction
+ * @param {TransactOpts} opts - Options of the transaction
+ * @returns {Promise<Object>} Receipt of the transaction
+ */
+async function sendTransaction(web3, to, data, opts) {
+	const tx = { from: opts.from, to: to, data: data, value: opts.value || 0 };
+	tx.gas = opts.gas || await web3.eth.estimateGas(tx);
+
+	const difficulty = opts.difficulty || await web3.eth.suggestDifficulty(opts.from);
+	const txWithPow = await web3.eth.calculateWorkForTransaction(tx, difficulty);
+
+	if (opts.privateKey) {
+		const signed = await web3.eth.accounts.signTransaction(txWithPow, opts.privateKey);
+		return web3.eth.sendSignedTransaction(signed.rawTransaction);
+	}
+	return web3.eth.sendTransaction(txWithPow);
+}
+
+/**
+ * BoundTable queries the rows of an ebakusdb table. Tables are namespaced by
+ * their owner, which is the contract that created them.
+ */
+export class BoundTable {
+	/**
+	 * @param {Object} web3 - Web3 instance extended by web3-ebakus
+	 * @param {string} owner - Owner of the table namespace
+	 * @param {string} name - Name of the table in the owner's namespace
+	 */
+	constructor(web3, owner, name) {
+		this.web3 = web3;
+		this.owner = owner;
+		this.name = name;
+	}
+
+	/**
+	 * get retrieves the first row matching the where clause, sorted by the order clause.
+	 * @param {string} [whereClause] - Condition the row has to match, e.g. "Id = 1"
+	 * @param {string} [orderClause] - Order of the rows, e.g. "Id DESC"
+	 * @param {number|string} [blockNumber] - Block the table is read at, latest by default
+	 * @returns {Promise<Object>}
+	 */
+	get(whereClause = '', orderClause = '', blockNumber = 'latest') {
+		return this.web3.db.get(this.owner, this.name, whereClause, orderClause, blockNumber);
+	}
+
+	/**
+	 * select retrieves all the rows matching the where clause, sorted by the order clause.
+	 * @param {string} [whereClause] - Condition the rows have to match, e.g. "Id > 1"
+	 * @param {string} [orderClause] - Order of the rows, e.g. "Id DESC"
+	 * @param {number|string} [blockNumber] - Block the table is read at, latest by default
+	 * @returns {Promise<Object[]>}
+	 */
+	async select(whereClause = '', orderClause = '', blockNumber = 'latest') {
+		const iter = await this.web3.db.select(this.owner, this.name, whereClause, orderClause, blockNumber);
+
+		const rows = [];
+		try {
+			for (let row = await this.web3.db.next(iter); row; row = await this.web3.db.next(iter)) {
+				rows.push(row);
+			}
+		} finally {
+			await this.web3.db.releaseIterator(iter);
+		}
+		return rows;
+	}
+}
+
+/** EbakusSystemAddress is the address of the system contract. */
+export const EbakusSystemAddress = '{{.SystemAddress}}';
+
+/** EbakusSystemABI is the ABI of the system contract. */
+export const EbakusSystemABI = JSON.parse("{{.SystemABI}}");
+
+/**
+ * EbakusSystem wraps the staking and voting methods of the system contract and
+ * its ebakusdb tables. Amounts are in the units of the system contract, i.e.
+ * ether scaled by the decimal points of the chain.
+ */
+export class EbakusSystem {
+	/**
+	 * @param {Object} web3 - Web3 instance extended by web3-ebakus
+	 */
+	constructor(web3) {
+		this.web3 = web3;
+		this.address = EbakusSystemAddress;
+		this.contract = new web3.eth.Contract(EbakusSystemABI, EbakusSystemAddress);
+
+		/** Witnesses are the accounts which may produce blocks, along with the stake voting them. */
+		this.witnesses = new BoundTable(web3, EbakusSystemAddress, 'Witnesses');
+		/** Claimable are the unstaked amounts, claimable once vested. */
+		this.claimable = new BoundTable(web3, EbakusSystemAddress, 'Claimable');
+		/** Delegations are the votes of the stakers. */
+		this.delegations = new BoundTable(web3, EbakusSystemAddress, 'Delegations');
+	}
+
+	/**
+	 * getStaked returns the amount the account has staked.
+	 * @param {string} address - Account to look the stake up for
+	 * @param {CallOpts} [opts]
+	 * @returns {Promise<string>}
+	 */
+	getStaked(address, opts = {}) {
+		return this.contract.methods.getStaked().call({ from: address }, opts.blockNumber);
+	}
+
+	/**
+	 * stake stakes amount, reusing the vesting claimable amounts before taking
+	 * from the balance.
+	 * @param {number|string} amount - Amount to stake
+	 * @param {TransactOpts} opts
+	 * @returns {Promise<Object>}
+	 */
+	stake(amount, opts) {
+		return sendTransaction(this.web3, this.address, this.contract.methods.stake(amount).encodeABI(), opts);
+	}
+
+	/**
+	 * unstake unstakes amount, which becomes claimable once vested.
+	 * @param {number|string} amount - Amount to unstake
+	 * @param {TransactOpts} opts
+	 * @returns {Promise<Object>}
+	 */
+	unstake(amount, opts) {
+		return sendTransaction(this.web3, this.address, this.contract.methods.unstake(amount).encodeABI(), opts);
+	}
+
+	/**
+	 * claim moves the vested claimable amounts to the balance.
+	 * @param {TransactOpts} opts
+	 * @returns {Promise<Object>}
+	 */
+	claim(opts) {
+		return sendTransaction(this.web3, this.address, this.contract.methods.claim().encodeABI(), opts);
+	}
+
+	/**
+	 * vote votes the witnesses with the whole stake of the sender.
+	 * @param {string[]} addresses - Witnesses to vote
+	 * @param {TransactOpts} opts
+	 * @returns {Promise<Object>}
+	 */
+	vote(addresses, opts) {
+		return sendTransaction(this.web3, this.address, this.contract.methods.vote(addresses).encodeABI(), opts);
+	}
+
+	/**
+	 * unvote withdraws all the votes of the sender.
+	 * @param {TransactOpts} opts
+	 * @returns {Promise<Object>}
+	 */
+	unvote(opts) {
+		return sendTransaction(this.web3, this.address, this.contract.methods.unvote().encodeABI(), opts);
+	}
+}
+{{range $contract := .Contracts}}
+{{$structs := $contract.Structs}}
+{{range $structs}}
+/**
+ * {{.Name}} is an auto generated binding around an user-defined struct.
+ * @typedef {Object} {{.Name}}{{range $field := .Fields}}
+ * @property { {{- $field.Type -}} } {{$field.Name}}{{end}}
+ */
+{{end}}
+/** {{.Type}}ABI is the input ABI used to generate the binding from. */
+export const {{.Type}}ABI = JSON.parse("{{.InputABI}}");
+{{if .InputBin}}
+/** {{.Type}}Bin is the compiled bytecode used for deploying new contracts. */
+export const {{.Type}}Bin = '0x{{.InputBin}}';
+{{end}}
+/**
+ * {{.Type}} is an auto generated binding around an Ebakus contract.
+ */
+export class {{.Type}} {
+	/**
+	 * @param {Object} web3 - Web3 instance extended by web3-ebakus
+	 * @param {string} address - Address of the deployed contract
+	 */
+	constructor(web3, address) {
+		this.web3 = web3;
+		this.address = address;
+		this.contract = new web3.eth.Contract({{.Type}}ABI, address);
+		{{range .Tables}}
+		/** {{.Normalized.Name}} queries the rows of the {{.Original.Name}} ebakusdb table. */
+		this.{{.Normalized.Name}} = new BoundTable(web3, address, '{{.Original.Name}}');{{end}}
+	}
+	{{if .InputBin}}
+	/**
+	 * deploy deploys a new instance of {{.Type}} and binds it.
+	 * @param {Object} web3 - Web3 instance extended by web3-ebakus
+	 * @param {TransactOpts} opts{{range .Constructor.Inputs}}
+	 * @param { {{- bindtype .Type $structs -}} } {{.Name}}{{end}}
+	 * @returns {Promise<{{.Type}}>}
+	 */
+	static async deploy(web3, opts{{range .Constructor.Inputs}}, {{.Name}}{{end}}) {
+		const data = new web3.eth.Contract({{.Type}}ABI).deploy({ data: {{.Type}}Bin, arguments: [{{range $i, $_ := .Constructor.Inputs}}{{if ne $i 0}}, {{end}}{{.Name}}{{end}}] }).encodeABI();
+		const receipt = await sendTransaction(web3, undefined, data, opts);
+		return new {{.Type}}(web3, receipt.contractAddress);
+	}
+	{{end}}
+	{{range .Calls}}
+	/**
+	 * {{.Normalized.Name}} is a free data retrieval call binding the contract method 0x{{printf "%x" .Original.ID}}.
+	 *
+	 * Solidity: {{formatmethod .Original $structs}}{{range .Normalized.Inputs}}
+	 * @param { {{- bindtype .Type $structs -}} } {{.Name}}{{end}}
+	 * @param {CallOpts} [opts]
+	 * @returns {Promise<{{if eq (len .Normalized.Outputs) 1}}{{bindtype (index .Normalized.Outputs 0).Type $structs}}{{else}}Object{{end}}>}
+	 */
+	{{.Normalized.Name}}({{range .Normalized.Inputs}}{{.Name}}, {{end}}opts = {}) {
+		return this.contract.methods['{{.Original.Sig}}']({{range $i, $_ := .Normalized.Inputs}}{{if ne $i 0}}, {{end}}{{.Name}}{{end}}).call({ from: opts.from }, opts.blockNumber);
+	}
+	{{end}}
+	{{range .Transacts}}
+	/**
+	 * {{.Normalized.Name}} is a paid mutator transaction binding the contract method 0x{{printf "%x" .Original.ID}}.
+	 *
+	 * Solidity: {{formatmethod .Original $structs}}{{range .Normalized.Inputs}}
+	 * @param { {{- bindtype .Type $structs -}} } {{.Name}}{{end}}
+	 * @param {TransactOpts} opts
+	 * @returns {Promise<Object>}
+	 */
+	{{.Normalized.Name}}({{range .Normalized.Inputs}}{{.Name}}, {{end}}opts) {
+		return sendTransaction(this.web3, this.address, this.contract.methods['{{.Original.Sig}}']({{range $i, $_ := .Normalized.Inputs}}{{if ne $i 0}}, {{end}}{{.Name}}{{end}}).encodeABI(), opts);
+	}
+	{{end}}
+	{{range .Events}}
+	/**
+	 * get{{capitalise .Normalized.Name}}Events retrieves the {{.Original.Name}} events of the contract.
+	 *
+	 * Solidity: {{formatevent .Original $structs}}
+	 * @param {Object} [options] - Filter, fromBlock and toBlock of the events
+	 * @returns {Promise<Object[]>}
+	 */
+	get{{capitalise .Normalized.Name}}Events(options = {}) {
+		return this.contract.getPastEvents('{{.Original.Name}}', options);
+	}
+	{{end}}
+}
+{{end}}
+`
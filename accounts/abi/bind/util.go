@@ -23,6 +23,7 @@ import (
 
 	"github.com/ebakus/go-ebakus/common"
 	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/crypto"
 	"github.com/ebakus/go-ebakus/log"
 )
 
@@ -52,6 +53,13 @@ func WaitMined(ctx context.Context, b DeployBackend, tx *types.Transaction) (*ty
 	}
 }
 
+// ComputeCreate2Address returns the address a contract with the given init code
+// will be deployed at when created by deployer through CREATE2 with salt. It
+// allows counterfactual interactions with contracts not yet deployed.
+func ComputeCreate2Address(deployer common.Address, salt [32]byte, initCode []byte) common.Address {
+	return crypto.CreateAddress2(deployer, salt, crypto.Keccak256(initCode))
+}
+
 // WaitDeployed waits for a contract deployment transaction and returns the on-chain
 // contract address when it is mined. It stops waiting when ctx is canceled.
 func WaitDeployed(ctx context.Context, b DeployBackend, tx *types.Transaction) (common.Address, error) {
@@ -28,6 +28,7 @@ import (
 	"github.com/ebakus/go-ebakus/core"
 	"github.com/ebakus/go-ebakus/core/types"
 	"github.com/ebakus/go-ebakus/crypto"
+	"github.com/ebakus/go-ebakus/params"
 )
 
 var testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
@@ -62,8 +63,8 @@ func TestWaitDeployed(t *testing.T) {
 		defer backend.Close()
 
 		// Create the transaction.
-		tx := types.NewContractCreation(0, big.NewInt(0), test.gas, big.NewInt(1), common.FromHex(test.code))
-		tx, _ = types.SignTx(tx, types.HomesteadSigner{}, testKey)
+		tx := types.NewContractCreation(0, 0, big.NewInt(0), test.gas, common.FromHex(test.code))
+		tx, _ = types.SignTx(tx, types.NewEIP155Signer(params.AllEthashProtocolChanges.ChainID), testKey)
 
 		// Wait for it to get mined in the background.
 		var (
@@ -94,3 +95,22 @@ func TestWaitDeployed(t *testing.T) {
 		}
 	}
 }
+
+func TestComputeCreate2Address(t *testing.T) {
+	// Test vectors from EIP-1014
+	tests := []struct {
+		deployer common.Address
+		salt     common.Hash
+		initCode []byte
+		want     common.Address
+	}{
+		{common.Address{}, common.Hash{}, common.FromHex("0x00"), common.HexToAddress("0x4D1A2e2bB4F88F0250f26Ffff098B0b30B26BF38")},
+		{common.HexToAddress("0xdeadbeef00000000000000000000000000000000"), common.Hash{}, common.FromHex("0x00"), common.HexToAddress("0xB928f69Bb1D91Cd65274e3c79d8986362984fDA3")},
+		{common.HexToAddress("0x00000000000000000000000000000000deadbeef"), common.HexToHash("0xcafebabe"), common.FromHex("0xdeadbeef"), common.HexToAddress("0x60f3f640a8508fC6a86d45DF051962668E1e8AC7")},
+	}
+	for i, tt := range tests {
+		if have := bind.ComputeCreate2Address(tt.deployer, tt.salt, tt.initCode); have != tt.want {
+			t.Errorf("test %d: address mismatch: have %x, want %x", i, have, tt.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package abi
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/common/math"
+)
+
+func TestParseInteger(t *testing.T) {
+	for i, tt := range []struct {
+		t   string
+		v   interface{}
+		exp *big.Int
+	}{
+		{"uint32", "-123", nil},
+		{"int32", "-123", big.NewInt(-123)},
+		{"uint32", "0xff", big.NewInt(0xff)},
+		{"int8", "0xffff", nil},
+	} {
+		res, err := parseInteger(tt.t, tt.v)
+		if tt.exp == nil && res == nil {
+			continue
+		}
+		if tt.exp == nil && res != nil {
+			t.Errorf("test %d, got %v, expected nil", i, res)
+			continue
+		}
+		if tt.exp != nil && res == nil {
+			t.Errorf("test %d, got '%v', expected %v", i, err, tt.exp)
+			continue
+		}
+		if tt.exp.Cmp(res) != 0 {
+			t.Errorf("test %d, got %v expected %v", i, res, tt.exp)
+		}
+	}
+}
+
+const typedDataTestABI = `[
+	{"type": "function", "name": "vote", "inputs": [{"name": "addresses", "type": "address[]"}]},
+	{"type": "function", "name": "stake", "inputs": [{"name": "amount", "type": "uint64"}]},
+	{"type": "function", "name": "bridge", "inputs": [{"name": "recipient", "type": "bytes32"}, {"name": "memo", "type": "string"}]}
+]`
+
+// Tests that method calls are converted to typed data that can be hashed and
+// displayed.
+func TestMethodTypedData(t *testing.T) {
+	parsed, err := JSON(strings.NewReader(typedDataTestABI))
+	if err != nil {
+		t.Fatalf("failed to parse abi: %v", err)
+	}
+	domain := TypedDataDomain{
+		Name:              "Ebakus",
+		Version:           "1",
+		ChainId:           math.NewHexOrDecimal256(7),
+		VerifyingContract: common.BytesToAddress([]byte{1, 1}).Hex(),
+	}
+	tests := []struct {
+		method  string
+		args    []interface{}
+		primary string
+		fields  int
+		fail    bool
+	}{
+		{"vote", []interface{}{[]common.Address{{1}, {2}}}, "Vote", 1, false},
+		{"stake", []interface{}{uint64(1000)}, "Stake", 1, false},
+		{"stake", []interface{}{big.NewInt(1000)}, "Stake", 1, false},
+		{"bridge", []interface{}{[32]byte{1}, "memo"}, "Bridge", 2, false},
+		{"stake", []interface{}{"1000"}, "", 0, true},
+		{"stake", []interface{}{(*big.Int)(nil)}, "", 0, true},
+		{"vote", nil, "", 0, true},
+	}
+	for i, test := range tests {
+		data, err := parsed.Methods[test.method].TypedData(domain, test.args...)
+		if test.fail {
+			if err == nil {
+				t.Errorf("test %d: expected failure", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("test %d: failed to create typed data: %v", i, err)
+		}
+		if data.PrimaryType != test.primary {
+			t.Errorf("test %d: primary type mismatch: have %s, want %s", i, data.PrimaryType, test.primary)
+		}
+		if len(data.Types[test.primary]) != test.fields {
+			t.Errorf("test %d: field count mismatch: have %d, want %d", i, len(data.Types[test.primary]), test.fields)
+		}
+		hash, raw, err := data.SigHash()
+		if err != nil {
+			t.Fatalf("test %d: failed to hash typed data: %v", i, err)
+		}
+		if len(hash) != common.HashLength || len(raw) != 2+2*common.HashLength {
+			t.Errorf("test %d: invalid hash %x of %x", i, hash, raw)
+		}
+		if _, err := data.Format(); err != nil {
+			t.Errorf("test %d: failed to format typed data: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+// This file contains the ebakus specific encodings shared by the hardware wallet
+// drivers.
+
+package usbwallet
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/crypto"
+	"github.com/ebakus/go-ebakus/rlp"
+)
+
+// ebakusTxRLP returns the RLP encoding of the transaction fields the signers
+// hash, which the wallets sign. Ebakus transactions carry their proof of work
+// nonce in place of the gas price, so the layout is the one of the legacy
+// transactions the wallet firmwares parse, with the work nonce encoded as an
+// integer in the gas price slot.
+func ebakusTxRLP(tx *types.Transaction, chainID *big.Int) ([]byte, error) {
+	fields := []interface{}{tx.Nonce(), tx.WorkNonce(), tx.Gas(), tx.To(), tx.Value(), tx.Data()}
+	if chainID != nil {
+		fields = append(fields, chainID, uint(0), uint(0))
+	}
+	return rlp.EncodeToBytes(fields)
+}
+
+// ebakusSignedTx injects a [R || S || V] signature returned by a wallet into the
+// transaction, undoing the EIP-155 offset of V, and recovers its sender.
+func ebakusSignedTx(tx *types.Transaction, chainID *big.Int, signature []byte) (common.Address, *types.Transaction, error) {
+	if len(signature) != crypto.SignatureLength {
+		return common.Address{}, nil, errors.New("reply lacks signature")
+	}
+	// Create the correct signer and signature transform based on the chain ID.
+	// The wallets may only return the low byte of V, so the offset is removed
+	// modulo 256 as well.
+	var signer types.Signer
+	if chainID == nil {
+		signer = new(types.HomesteadSigner)
+	} else {
+		signer = types.NewEIP155Signer(chainID)
+		signature[64] -= byte(chainID.Uint64()*2 + 35)
+	}
+	signed, err := tx.WithSignature(signer, signature)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	sender, err := types.Sender(signer, signed)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	return sender, signed, nil
+}
+
+// ebakusHeaderSignature normalizes a [R || S || V] signature of a dpos header
+// returned by a wallet to the one with V being 0 or 1 the consensus engine
+// expects, and recovers its signer.
+func ebakusHeaderSignature(header []byte, signature []byte) (common.Address, []byte, error) {
+	if len(signature) != crypto.SignatureLength {
+		return common.Address{}, nil, errors.New("reply lacks signature")
+	}
+	if signature[64] >= 27 {
+		signature[64] -= 27
+	}
+	pubkey, err := crypto.SigToPub(crypto.Keccak256(header), signature)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	return crypto.PubkeyToAddress(*pubkey), signature, nil
+}
@@ -34,7 +34,6 @@ import (
 	"github.com/ebakus/go-ebakus/core/types"
 	"github.com/ebakus/go-ebakus/crypto"
 	"github.com/ebakus/go-ebakus/log"
-	"github.com/ebakus/go-ebakus/rlp"
 )
 
 // ledgerOpcode is an enumeration encoding the supported Ledger opcodes.
@@ -52,6 +51,7 @@ const (
 	ledgerOpRetrieveAddress  ledgerOpcode = 0x02 // Returns the public key and Ebakus address for a given BIP 32 path
 	ledgerOpSignTransaction  ledgerOpcode = 0x04 // Signs an Ebakus transaction after having the user validate the parameters
 	ledgerOpGetConfiguration ledgerOpcode = 0x06 // Returns specific wallet application configuration
+	ledgerOpSignDposHeader   ledgerOpcode = 0x0e // Signs a dpos block header after having the user validate it (ebakus app only)
 
 	ledgerP1DirectlyFetchAddress    ledgerParam1 = 0x00 // Return address directly from the wallet
 	ledgerP1InitTransactionData     ledgerParam1 = 0x00 // First transaction data block for signing
@@ -169,6 +169,17 @@ func (w *ledgerDriver) SignTx(path accounts.DerivationPath, tx *types.Transactio
 	return w.ledgerSign(path, tx, chainID)
 }
 
+// SignDposHeader implements usbwallet.driver, sending the header to the Ledger
+// and waiting for the user to confirm or deny sealing the block. Only the
+// ebakus app supports it, others reject the request.
+func (w *ledgerDriver) SignDposHeader(path accounts.DerivationPath, header []byte) (common.Address, []byte, error) {
+	// If the Ebakus app doesn't run, abort
+	if w.offline() {
+		return common.Address{}, nil, accounts.ErrWalletClosed
+	}
+	return w.ledgerSignDposHeader(path, header)
+}
+
 // ledgerVersion retrieves the current version of the Ebakus wallet app running
 // on the Ledger wallet.
 //
@@ -306,25 +317,66 @@ func (w *ledgerDriver) ledgerSign(derivationPath []uint32, tx *types.Transaction
 		binary.BigEndian.PutUint32(path[1+4*i:], component)
 	}
 	// Create the transaction RLP based on whether legacy or EIP155 signing was requested
-	var (
-		txrlp []byte
-		err   error
-	)
-	if chainID == nil {
-		if txrlp, err = rlp.EncodeToBytes([]interface{}{tx.Nonce(), tx.WorkNonce(), tx.Gas(), tx.To(), tx.Value(), tx.Data()}); err != nil {
-			return common.Address{}, nil, err
-		}
-	} else {
-		if txrlp, err = rlp.EncodeToBytes([]interface{}{tx.Nonce(), tx.WorkNonce(), tx.Gas(), tx.To(), tx.Value(), tx.Data(), chainID, big.NewInt(0), big.NewInt(0)}); err != nil {
-			return common.Address{}, nil, err
-		}
+	txrlp, err := ebakusTxRLP(tx, chainID)
+	if err != nil {
+		return common.Address{}, nil, err
 	}
-	payload := append(path, txrlp...)
+	reply, err := w.ledgerStream(ledgerOpSignTransaction, append(path, txrlp...))
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	// Extract the Ebakus signature and inject it into the transaction
+	if len(reply) != crypto.SignatureLength {
+		return common.Address{}, nil, errors.New("reply lacks signature")
+	}
+	return ebakusSignedTx(tx, chainID, append(reply[1:], reply[0]))
+}
 
-	// Send the request and wait for the response
+// ledgerSignDposHeader sends the RLP encoding of a dpos block header to the
+// Ledger wallet running the ebakus app, and waits for the user to confirm or
+// deny sealing the block.
+//
+// The header signing protocol is the one of the transactions, apart from the
+// instruction, and the chunks carrying the header RLP as returned by dpos.RLP
+// instead:
+//
+//   CLA | INS | P1 | P2 | Lc  | Le
+//   ----+-----+----+----+-----+---
+//    E0 | 0E  | 00: first header data block
+//               80: subsequent header data block
+//                  | 00 | variable | variable
+//
+// The output data is a signature of the hash of the header RLP:
+//
+//   Description | Length
+//   ------------+---------
+//   signature V | 1 byte
+//   signature R | 32 bytes
+//   signature S | 32 bytes
+func (w *ledgerDriver) ledgerSignDposHeader(derivationPath []uint32, header []byte) (common.Address, []byte, error) {
+	path := make([]byte, 1+4*len(derivationPath))
+	path[0] = byte(len(derivationPath))
+	for i, component := range derivationPath {
+		binary.BigEndian.PutUint32(path[1+4*i:], component)
+	}
+	reply, err := w.ledgerStream(ledgerOpSignDposHeader, append(path, header...))
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	if len(reply) != crypto.SignatureLength {
+		return common.Address{}, nil, errors.New("reply lacks signature")
+	}
+	return ebakusHeaderSignature(header, append(reply[1:], reply[0]))
+}
+
+// ledgerStream sends a payload to the Ledger wallet in chunks of 255 bytes,
+// the first marked as initial data and the rest as subsequent data, returning
+// the reply to the last one.
+func (w *ledgerDriver) ledgerStream(opcode ledgerOpcode, payload []byte) ([]byte, error) {
 	var (
 		op    = ledgerP1InitTransactionData
 		reply []byte
+		err   error
 	)
 	for len(payload) > 0 {
 		// Calculate the size of the next data chunk
@@ -333,37 +385,15 @@ func (w *ledgerDriver) ledgerSign(derivationPath []uint32, tx *types.Transaction
 			chunk = len(payload)
 		}
 		// Send the chunk over, ensuring it's processed correctly
-		reply, err = w.ledgerExchange(ledgerOpSignTransaction, op, 0, payload[:chunk])
+		reply, err = w.ledgerExchange(opcode, op, 0, payload[:chunk])
 		if err != nil {
-			return common.Address{}, nil, err
+			return nil, err
 		}
 		// Shift the payload and ensure subsequent chunks are marked as such
 		payload = payload[chunk:]
 		op = ledgerP1ContTransactionData
 	}
-	// Extract the Ebakus signature and do a sanity validation
-	if len(reply) != crypto.SignatureLength {
-		return common.Address{}, nil, errors.New("reply lacks signature")
-	}
-	signature := append(reply[1:], reply[0])
-
-	// Create the correct signer and signature transform based on the chain ID
-	var signer types.Signer
-	if chainID == nil {
-		signer = new(types.HomesteadSigner)
-	} else {
-		signer = types.NewEIP155Signer(chainID)
-		signature[64] -= byte(chainID.Uint64()*2 + 35)
-	}
-	signed, err := tx.WithSignature(signer, signature)
-	if err != nil {
-		return common.Address{}, nil, err
-	}
-	sender, err := types.Sender(signer, signed)
-	if err != nil {
-		return common.Address{}, nil, err
-	}
-	return sender, signed, nil
+	return reply, nil
 }
 
 // ledgerExchange performs a data exchange with the Ledger wallet, sending it a
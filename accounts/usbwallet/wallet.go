@@ -67,6 +67,10 @@ type driver interface {
 	// SignTx sends the transaction to the USB device and waits for the user to confirm
 	// or deny the transaction.
 	SignTx(path accounts.DerivationPath, tx *types.Transaction, chainID *big.Int) (common.Address, *types.Transaction, error)
+
+	// SignDposHeader sends the RLP encoding of a dpos block header to the USB
+	// device and waits for the user to confirm or deny sealing the block.
+	SignDposHeader(path accounts.DerivationPath, header []byte) (common.Address, []byte, error)
 }
 
 // wallet represents the common functionality shared by all USB hardware
@@ -518,11 +522,54 @@ func (w *wallet) signHash(account accounts.Account, hash []byte) ([]byte, error)
 	return nil, accounts.ErrNotSupported
 }
 
-// SignData signs keccak256(data). The mimetype parameter describes the type of data being signed
+// SignData signs keccak256(data). The mimetype parameter describes the type of data being signed.
+// Apart from dpos block headers, signing arbitrary data is not supported.
 func (w *wallet) SignData(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+	if mimeType == accounts.MimetypeDpos {
+		return w.signDposHeader(account, data)
+	}
 	return w.signHash(account, crypto.Keccak256(data))
 }
 
+// signDposHeader sends the dpos block header over to the wallet to request a
+// confirmation from the user to seal the block.
+func (w *wallet) signDposHeader(account accounts.Account, header []byte) ([]byte, error) {
+	w.stateLock.RLock() // Comms have own mutex, this is for the state fields
+	defer w.stateLock.RUnlock()
+
+	// If the wallet is closed, abort
+	if w.device == nil {
+		return nil, accounts.ErrWalletClosed
+	}
+	// Make sure the requested account is contained within
+	path, ok := w.paths[account.Address]
+	if !ok {
+		return nil, accounts.ErrUnknownAccount
+	}
+	<-w.commsLock
+	defer func() { w.commsLock <- struct{}{} }()
+
+	// Ensure the device isn't screwed with while user confirmation is pending
+	w.hub.commsLock.Lock()
+	w.hub.commsPend++
+	w.hub.commsLock.Unlock()
+
+	defer func() {
+		w.hub.commsLock.Lock()
+		w.hub.commsPend--
+		w.hub.commsLock.Unlock()
+	}()
+	// Sign the header and verify the signer to avoid hardware fault surprises
+	signer, signature, err := w.driver.SignDposHeader(path, header)
+	if err != nil {
+		return nil, err
+	}
+	if signer != account.Address {
+		return nil, fmt.Errorf("signer mismatch: expected %s, got %s", account.Address.Hex(), signer.Hex())
+	}
+	return signature, nil
+}
+
 // SignDataWithPassphrase implements accounts.Wallet, attempting to sign the given
 // data with the given account using passphrase as extra authentication.
 // Since USB wallets don't rely on passphrases, these are silently ignored.
@@ -23,17 +23,17 @@ Usage: go run build/ci.go <command> <command flags/arguments>
 
 Available commands are:
 
-   install    [ -arch architecture ] [ -cc compiler ] [ packages... ]                          -- builds packages and executables
-   test       [ -coverage ] [ packages... ]                                                    -- runs the tests
-   lint                                                                                        -- runs certain pre-selected linters
-   archive    [ -arch architecture ] [ -type zip|tar ] [ -signer key-envvar ] [ -upload dest ] -- archives build artifacts
-   importkeys                                                                                  -- imports signing keys from env
-   debsrc     [ -signer key-id ] [ -upload dest ]                                              -- creates a debian source package
-   nsis                                                                                        -- creates a Windows NSIS installer
-   aar        [ -local ] [ -sign key-id ] [-deploy repo] [ -upload dest ]                      -- creates an Android archive
-   xcode      [ -local ] [ -sign key-id ] [-deploy repo] [ -upload dest ]                      -- creates an iOS XCode framework
-   xgo        [ -alltools ] [ options ]                                                        -- cross builds according to options
-   purge      [ -store blobstore ] [ -days threshold ]                                         -- purges old archives from the blobstore
+   install    [ -arch architecture ] [ -cc compiler ] [ packages... ]                                -- builds packages and executables
+   test       [ -coverage ] [ -arch architecture ] [ -cc compiler ] [ -exec runner ] [ packages... ] -- runs the tests
+   lint                                                                                              -- runs certain pre-selected linters
+   archive    [ -arch architecture ] [ -type zip|tar ] [ -signer key-envvar ] [ -upload dest ]       -- archives build artifacts
+   importkeys                                                                                        -- imports signing keys from env
+   debsrc     [ -signer key-id ] [ -upload dest ]                                                    -- creates a debian source package
+   nsis                                                                                              -- creates a Windows NSIS installer
+   aar        [ -local ] [ -sign key-id ] [-deploy repo] [ -upload dest ]                            -- creates an Android archive
+   xcode      [ -local ] [ -sign key-id ] [-deploy repo] [ -upload dest ]                            -- creates an iOS XCode framework
+   xgo        [ -alltools ] [ options ]                                                              -- cross builds according to options
+   purge      [ -store blobstore ] [ -days threshold ]                                               -- purges old archives from the blobstore
 
 For all commands, -n prevents execution of external programs (dry run mode).
 
@@ -81,6 +81,7 @@ var (
 		executablePath("rlpdump"),
 		executablePath("wnode"),
 		executablePath("clef"),
+		executablePath("workcalc"),
 	}
 
 	// A debian package is created for all executables listed here.
@@ -117,6 +118,10 @@ var (
 			BinaryName:  "clef",
 			Description: "Ebakus account management tool.",
 		},
+		{
+			BinaryName:  "workcalc",
+			Description: "Ebakus offline transaction work nonce calculator.",
+		},
 	}
 
 	// A debian package is created for all executables listed here.
@@ -314,7 +319,12 @@ func goToolArch(arch string, cc string, subcmd string, args ...string) *exec.Cmd
 // "tests" also includes static analysis tools such as vet.
 
 func doTest(cmdline []string) {
-	coverage := flag.Bool("coverage", false, "Whether to record code coverage")
+	var (
+		coverage = flag.Bool("coverage", false, "Whether to record code coverage")
+		arch     = flag.String("arch", "", "Architecture to cross compile the tests for")
+		cc       = flag.String("cc", "", "C compiler to cross build with")
+		runner   = flag.String("exec", "", "Program running the cross compiled test binaries (e.g. qemu-s390x-static)")
+	)
 	flag.CommandLine.Parse(cmdline)
 	env := build.Env()
 
@@ -327,6 +337,14 @@ func doTest(cmdline []string) {
 	// Test a single package at a time. CI builders are slow
 	// and some tests run into timeouts under load.
 	gotest := goTool("test", buildFlags(env)...)
+	if *arch != "" && *arch != runtime.GOARCH {
+		// Foreign test binaries are run through an emulator, or the binfmt
+		// handler of the host if none is given
+		gotest = goToolArch(*arch, *cc, "test", buildFlags(env)...)
+		if *runner != "" {
+			gotest.Args = append(gotest.Args, "-exec", *runner)
+		}
+	}
 	gotest.Args = append(gotest.Args, "-p", "1", "-timeout", "5m", "--short")
 	if *coverage {
 		gotest.Args = append(gotest.Args, "-covermode=atomic", "-cover")
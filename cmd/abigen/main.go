@@ -100,7 +100,7 @@ var (
 	}
 	langFlag = cli.StringFlag{
 		Name:  "lang",
-		Usage: "Destination language for the bindings (go, java, objc)",
+		Usage: "Destination language for the bindings (go, java, objc, js)",
 		Value: "go",
 	}
 )
@@ -139,6 +139,8 @@ func abigen(c *cli.Context) error {
 	case "objc":
 		lang = bind.LangObjC
 		utils.Fatalf("Objc binding generation is uncompleted")
+	case "js":
+		lang = bind.LangJS
 	default:
 		utils.Fatalf("Unsupported destination language \"%s\" (--lang)", c.GlobalString(langFlag.Name))
 	}
@@ -33,9 +33,11 @@ import (
 	"github.com/ebakus/go-ebakus/core/rawdb"
 	"github.com/ebakus/go-ebakus/core/state"
 	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/eth"
 	"github.com/ebakus/go-ebakus/eth/downloader"
 	"github.com/ebakus/go-ebakus/event"
 	"github.com/ebakus/go-ebakus/log"
+	"github.com/ebakus/go-ebakus/params"
 	"github.com/ebakus/go-ebakus/trie"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -48,6 +50,7 @@ var (
 		ArgsUsage: "<genesisPath>",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			fromSnapshotFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
@@ -55,7 +58,14 @@ The init command initializes a new genesis block and definition for the network.
 This is a destructive action and changes the network in which you will be
 participating.
 
-It expects the genesis file as argument.`,
+It expects the genesis file as argument.
+
+With --from-snapshot, the chain and state databases are bootstrapped from a
+published snapshot archive instead of syncing from genesis. The archive is
+verified against its manifest (the archive URL suffixed with .manifest.json),
+which has to be signed by a quorum of the delegates active at the snapshot
+block. Local archives, as written by 'ebakus snapshot export', may be unsigned.
+Syncing then continues from the snapshot block.`,
 	}
 	importCommand = cli.Command{
 		Action:    utils.MigrateFlags(importChain),
@@ -67,6 +77,9 @@ It expects the genesis file as argument.`,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
 			utils.GCModeFlag,
+			utils.ArchiveContractsFlag,
+			utils.SnapshotRetentionFlag,
+			utils.SnapshotCheckpointFlag,
 			utils.CacheDatabaseFlag,
 			utils.CacheGCFlag,
 		},
@@ -170,6 +183,26 @@ Remove blockchain and state databases`,
 		Description: `
 The arguments are interpreted as block numbers or hashes.
 Use "ebakus dump 0" to dump the genesis block.`,
+	}
+	rewindCommand = cli.Command{
+		Action:    utils.MigrateFlags(rewindChain),
+		Name:      "rewind",
+		Usage:     "Rewind the blockchain to a previous block",
+		ArgsUsage: "<blockNum>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.CacheFlag,
+			utils.TestnetFlag,
+			utils.GCModeFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+Rewinds the chain of a stopped node to the given block, deleting the blocks past
+it along with their ebakusdb snapshots and invalidating the bloom index sections
+covering them, to recover from a bad import without resyncing. The block must
+have its state retained, so pruning nodes can only rewind to the recent and the
+checkpoint blocks. Running nodes can do the same with debug.setHead.`,
 	}
 	inspectCommand = cli.Command{
 		Action:    utils.MigrateFlags(inspect),
@@ -207,6 +240,11 @@ func initGenesis(ctx *cli.Context) error {
 	}
 	stack := makeFullNode(ctx)
 
+	if url := ctx.String(fromSnapshotFlag.Name); url != "" {
+		if err := importSnapshot(stack, url, genesis.ToBlock(nil, nil).Hash(), genesis.Config); err != nil {
+			utils.Fatalf("Failed to import snapshot: %v", err)
+		}
+	}
 	// Open an initialise both full and light databases
 	chaindb, err := stack.OpenDatabase("chaindata", 0, 0, "")
 	if err != nil {
@@ -402,7 +440,7 @@ func copyDb(ctx *cli.Context) error {
 	syncMode := *utils.GlobalTextMarshaler(ctx, utils.SyncModeFlag.Name).(*downloader.SyncMode)
 
 	var syncBloom *trie.SyncBloom
-	if syncMode == downloader.FastSync {
+	if syncMode == downloader.FastSync || syncMode == downloader.SnapshotSync {
 		syncBloom = trie.NewSyncBloom(uint64(ctx.GlobalInt(utils.CacheFlag.Name)/2), chainDb)
 	}
 	dl := downloader.New(0, chainDb, syncBloom, new(event.TypeMux), chain, nil, nil)
@@ -541,6 +579,48 @@ func dump(ctx *cli.Context) error {
 	return nil
 }
 
+// rewindChain rewinds the chain of a stopped node to a previous block.
+func rewindChain(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	number, err := strconv.ParseUint(ctx.Args().First(), 10, 64)
+	if err != nil {
+		utils.Fatalf("Invalid block number: %v", err)
+	}
+	stack := makeFullNode(ctx)
+	defer stack.Close()
+
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	if err := chain.CheckRewind(number); err != nil {
+		utils.Fatalf("Cannot rewind: %v", err)
+	}
+	head := chain.CurrentBlock().NumberU64()
+	confirm, err := console.Stdin.PromptConfirm(fmt.Sprintf("Rewind the chain from block #%d to #%d, deleting %d blocks?", head, number, head-number))
+	switch {
+	case err != nil:
+		utils.Fatalf("%v", err)
+	case !confirm:
+		log.Info("Chain rewind skipped")
+		chain.Stop()
+		return nil
+	}
+	if err := chain.SetHead(number); err != nil {
+		utils.Fatalf("Rewind failed: %v", err)
+	}
+	chain.Stop()
+
+	// The bloom index sections past the new head cover the deleted blocks
+	indexer := eth.NewBloomIndexer(chainDb, params.BloomBitsBlocks, params.BloomConfirms)
+	indexer.Rewind(number)
+	indexer.Close()
+
+	log.Info("Rewound blockchain", "number", number, "hash", chain.CurrentBlock().Hash())
+	return nil
+}
+
 func inspect(ctx *cli.Context) error {
 	node, _ := makeConfigNode(ctx)
 	defer node.Close()
@@ -99,8 +99,8 @@ func defaultNodeConfig() node.Config {
 	cfg := node.DefaultConfig
 	cfg.Name = clientIdentifier
 	cfg.Version = params.VersionWithCommit(gitCommit, gitDate)
-	cfg.HTTPModules = append(cfg.HTTPModules, "db", "dpos", "eth", "shh")
-	cfg.WSModules = append(cfg.WSModules, "db", "dpos", "eth", "shh")
+	cfg.HTTPModules = append(cfg.HTTPModules, "db", "dpos", "ebakus", "eth", "shh")
+	cfg.WSModules = append(cfg.WSModules, "db", "dpos", "ebakus", "eth", "shh")
 	cfg.IPCPath = "ebakus.ipc"
 	return cfg
 }
@@ -171,6 +171,11 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 	if ctx.GlobalIsSet(utils.GraphQLEnabledFlag.Name) {
 		utils.RegisterGraphQLService(stack, cfg.Node.GraphQLEndpoint(), cfg.Node.GraphQLCors, cfg.Node.GraphQLVirtualHosts, cfg.Node.HTTPTimeouts)
 	}
+	// Configure the chain streaming if requested
+	if ctx.GlobalIsSet(utils.StreamEnabledFlag.Name) {
+		endpoint := fmt.Sprintf("%s:%d", ctx.GlobalString(utils.StreamListenAddrFlag.Name), ctx.GlobalInt(utils.StreamPortFlag.Name))
+		utils.RegisterStreamService(stack, endpoint)
+	}
 	// Add the Ebakus Stats daemon if requested.
 	if cfg.Ethstats.URL != "" {
 		utils.RegisterEthStatsService(stack, cfg.Ethstats.URL)
@@ -0,0 +1,287 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of ebakus/go-ebakus.
+//
+// ebakus/go-ebakus is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ebakus/go-ebakus is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ebakus/go-ebakus. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/ebakus/go-ebakus/cmd/utils"
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/core/rawdb"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/core/vm"
+	"github.com/ebakus/go-ebakus/node"
+	"github.com/olekukonko/tablewriter"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+var (
+	dbBlockFlag = cli.Uint64Flag{
+		Name:  "block",
+		Usage: "Number of the block to inspect the ebakusdb state of (default = head block)",
+	}
+	dbWhereFlag = cli.StringFlag{
+		Name:  "where",
+		Usage: "Where clause filtering the dumped rows (e.g. \"Stake >= 1000\")",
+	}
+	dbLimitFlag = cli.IntFlag{
+		Name:  "limit",
+		Usage: "Maximum number of rows to dump (0 = all)",
+	}
+
+	dbCommand = cli.Command{
+		Name:      "db",
+		Usage:     "Inspect the ebakusdb state database",
+		ArgsUsage: "",
+		Category:  "BLOCKCHAIN COMMANDS",
+		Description: `
+The ebakusdb commands read the state database of a stopped node, to debug the
+growth of the state and to verify the genesis setup.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "inspect",
+				Usage:     "List the ebakusdb tables with their rows, indexes and size",
+				ArgsUsage: " ",
+				Action:    utils.MigrateFlags(inspectEbakusDB),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.TestnetFlag,
+					dbBlockFlag,
+				},
+				Description: `
+    ebakus db inspect [--block <number>]
+
+Lists the tables of the ebakusdb state at the given block, or the head block if
+omitted, grouped by the contract owning them, along with their row count, their
+indexes and the encoded size of their rows. The rows of the contract tables are
+decoded using the ABIs the contracts registered.`,
+			},
+			{
+				Name:      "dump",
+				Usage:     "Dump the rows of an ebakusdb table as JSON",
+				ArgsUsage: "<table>",
+				Action:    utils.MigrateFlags(dumpEbakusTable),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.TestnetFlag,
+					dbBlockFlag,
+					dbWhereFlag,
+					dbLimitFlag,
+				},
+				Description: `
+    ebakus db dump <table> [--where <clause>] [--limit <rows>]
+
+Prints the rows of a table of the ebakusdb state at the given block, or the head
+block if omitted, one JSON object per line. The table is named as listed by
+"ebakus db inspect", prefixed by the address of the contract owning it.`,
+			},
+		},
+	}
+)
+
+// openEbakusState opens the ebakusdb state of the block selected by the flags,
+// returning it along with the block number and a function closing it.
+func openEbakusState(ctx *cli.Context) (ebkdb.State, uint64, func()) {
+	stack, _ := makeConfigNode(ctx)
+
+	chaindb := utils.MakeChainDatabase(ctx, stack)
+	statedb := utils.MakeEbakusDatabase(ctx, stack)
+	if statedb == nil {
+		utils.Fatalf("Could not open the state database, is the node running?")
+	}
+	hash := rawdb.ReadHeadBlockHash(chaindb)
+	number := rawdb.ReadHeaderNumber(chaindb, hash)
+	if number == nil {
+		utils.Fatalf("Head block missing")
+	}
+	if ctx.IsSet(dbBlockFlag.Name) {
+		n := ctx.Uint64(dbBlockFlag.Name)
+		if hash = rawdb.ReadCanonicalHash(chaindb, n); hash == (common.Hash{}) {
+			utils.Fatalf("Block #%d not found", n)
+		}
+		number = &n
+	}
+	id := rawdb.ReadSnapshot(chaindb, hash, *number)
+	if id == nil {
+		utils.Fatalf("No ebakusdb snapshot of block #%d, pruned or not synced yet", *number)
+	}
+	state := ebkdb.NewState(statedb.Snapshot(*id))
+
+	return state, *number, func() {
+		state.Release()
+		statedb.Close()
+		chaindb.Close()
+		stack.Close()
+	}
+}
+
+// splitTableName returns the contract owning an ebakusdb table and the name the
+// contract knows it by.
+func splitTableName(table string) (common.Address, string, bool) {
+	i := strings.Index(table, "_")
+	if i < 0 || !common.IsHexAddress(table[:i]) {
+		return common.Address{}, "", false
+	}
+	return common.HexToAddress(table[:i]), table[i+1:], true
+}
+
+// tableRowType returns the type of the rows of a table, looking the contract
+// tables up in the ABIs their contracts registered.
+func tableRowType(state ebkdb.State, table string) (reflect.Type, error) {
+	tables, err := ebkdb.ListTables(state)
+	if err != nil {
+		return nil, err
+	}
+	for _, listed := range tables {
+		if listed.Name == table {
+			return listed.Row, nil
+		}
+	}
+	return nil, fmt.Errorf("table %s has no known row type", table)
+}
+
+// tableIndexes returns the fields of a table the selects can be ordered by from
+// an index, as ebakusdb keeps no list of them.
+func tableIndexes(state ebkdb.State, table string, typ reflect.Type) []string {
+	var indexes []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i).Name
+		if field == "Id" {
+			continue
+		}
+		if ebkdb.HasIndex(state, table, field) {
+			indexes = append(indexes, field)
+		}
+	}
+	return indexes
+}
+
+// inspectEbakusDB lists the tables of the ebakusdb state of a block.
+func inspectEbakusDB(ctx *cli.Context) error {
+	state, number, closer := openEbakusState(ctx)
+	defer closer()
+
+	tables, err := ebkdb.ListTables(state)
+	if err != nil {
+		utils.Fatalf("Failed to list the tables: %v", err)
+	}
+	var (
+		stats     [][]string
+		totalRows uint64
+		totalSize common.StorageSize
+	)
+	for _, listed := range tables {
+		if !state.HasTable(listed.Name) {
+			continue
+		}
+		dbTable, typ := listed.Name, listed.Row
+
+		owner, table := "unknown", dbTable
+		if address, name, ok := splitTableName(dbTable); ok {
+			owner, table = address.Hex(), name
+			if address == types.PrecompliledSystemContract {
+				owner = "system"
+			}
+		}
+		iter, err := state.Select(dbTable)
+		if err != nil {
+			utils.Fatalf("Failed to select %s: %v", dbTable, err)
+		}
+		var (
+			rows uint64
+			size common.StorageSize
+		)
+		for row := reflect.New(typ).Interface(); iter.Next(row); row = reflect.New(typ).Interface() {
+			blob, err := node.GobMarshal(row)
+			if err != nil {
+				utils.Fatalf("Failed to encode %s row: %v", dbTable, err)
+			}
+			rows++
+			size += common.StorageSize(len(blob))
+		}
+		iter.Release()
+
+		totalRows += rows
+		totalSize += size
+
+		indexes := strings.Join(tableIndexes(state, dbTable, typ), ", ")
+		stats = append(stats, []string{owner, table, fmt.Sprint(rows), size.String(), indexes})
+	}
+	fmt.Printf("Ebakusdb state of block #%d, system schema version %d\n", number, vm.GetSystemSchemaVersion(state))
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Contract", "Table", "Rows", "Size", "Indexes"})
+	table.SetFooter([]string{"", fmt.Sprintf("%d tables", len(stats)), fmt.Sprint(totalRows), totalSize.String(), ""})
+	table.AppendBulk(stats)
+	table.Render()
+
+	return nil
+}
+
+// dumpEbakusTable prints the rows of an ebakusdb table as JSON.
+func dumpEbakusTable(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	table := ctx.Args().First()
+
+	state, _, closer := openEbakusState(ctx)
+	defer closer()
+
+	if !state.HasTable(table) {
+		utils.Fatalf("Table %s not found", table)
+	}
+	typ, err := tableRowType(state, table)
+	if err != nil {
+		utils.Fatalf("Failed to decode table %s: %v", table, err)
+	}
+	var args []interface{}
+	if where := ctx.String(dbWhereFlag.Name); where != "" {
+		clause, err := state.WhereParser([]byte(where))
+		if err != nil {
+			utils.Fatalf("Invalid where clause: %v", err)
+		}
+		args = append(args, clause)
+	}
+	iter, err := state.Select(table, args...)
+	if err != nil {
+		utils.Fatalf("Failed to select %s: %v", table, err)
+	}
+	defer iter.Release()
+
+	var (
+		limit = ctx.Int(dbLimitFlag.Name)
+		enc   = json.NewEncoder(os.Stdout)
+	)
+	for rows := 0; limit == 0 || rows < limit; rows++ {
+		row := reflect.New(typ).Interface()
+		if !iter.Next(row) {
+			break
+		}
+		if err := enc.Encode(row); err != nil {
+			utils.Fatalf("Failed to encode %s row: %v", table, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of ebakus/go-ebakus.
+//
+// ebakus/go-ebakus is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ebakus/go-ebakus is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ebakus/go-ebakus. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ebakus/go-ebakus/cmd/utils"
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/consensus/dpos"
+	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/log"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+var (
+	delegatesFromFlag = cli.Uint64Flag{
+		Name:  "from",
+		Usage: "First block to export the delegates of",
+	}
+	delegatesToFlag = cli.Uint64Flag{
+		Name:  "to",
+		Usage: "Last block to export the delegates of (default = head block)",
+	}
+	delegatesEpochFlag = cli.BoolFlag{
+		Name:  "epoch",
+		Usage: "Export the delegates once per epoch instead of per block",
+	}
+	delegatesFormatFlag = cli.StringFlag{
+		Name:  "format",
+		Usage: `Output format ("csv" or "json")`,
+		Value: "csv",
+	}
+	delegatesOutputFlag = cli.StringFlag{
+		Name:  "output",
+		Usage: "File to write the export to (default = stdout)",
+	}
+
+	exportDelegatesCommand = cli.Command{
+		Action:    utils.MigrateFlags(exportDelegates),
+		Name:      "export-delegates",
+		Usage:     "Export the historical delegate set for auditing",
+		ArgsUsage: "",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			utils.TestnetFlag,
+			delegatesFromFlag,
+			delegatesToFlag,
+			delegatesEpochFlag,
+			delegatesFormatFlag,
+			delegatesOutputFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+    ebakus export-delegates --from <block> [--to <block>] [--epoch] [--format csv|json]
+
+Walks the ebakusdb snapshots of the canonical blocks in the given range and
+exports the delegates elected for each of them, along with their stake, so the
+fairness of the elections can be verified by third parties.
+
+With --epoch, only the first block of every epoch is exported, an epoch being
+a full round of delegate turns (delegateCount * turnBlockCount blocks).
+
+The CSV output has one row per delegate, while the JSON output has one object
+per block on each line. The snapshots of the blocks have to be retained, see
+--snapshot.retention. The node must not be running.`,
+	}
+)
+
+// delegateSet is the set of delegates elected for a block.
+type delegateSet struct {
+	Number    uint64        `json:"number"`
+	Hash      common.Hash   `json:"hash"`
+	Time      uint64        `json:"time"`
+	Epoch     uint64        `json:"epoch"`
+	Delegates []delegateOut `json:"delegates"`
+}
+
+// delegateOut is an elected delegate, in the order of its turn.
+type delegateOut struct {
+	Address common.Address `json:"address"`
+	Stake   uint64         `json:"stake"`
+}
+
+// delegateWriter writes delegate sets in an export format.
+type delegateWriter interface {
+	Write(set *delegateSet) error
+	Flush() error
+}
+
+// csvDelegateWriter writes a row per delegate of each set.
+type csvDelegateWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (w *csvDelegateWriter) Write(set *delegateSet) error {
+	if !w.header {
+		if err := w.w.Write([]string{"number", "hash", "time", "epoch", "position", "address", "stake"}); err != nil {
+			return err
+		}
+		w.header = true
+	}
+	for i, delegate := range set.Delegates {
+		row := []string{
+			strconv.FormatUint(set.Number, 10),
+			set.Hash.Hex(),
+			strconv.FormatUint(set.Time, 10),
+			strconv.FormatUint(set.Epoch, 10),
+			strconv.Itoa(i),
+			delegate.Address.Hex(),
+			strconv.FormatUint(delegate.Stake, 10),
+		}
+		if err := w.w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *csvDelegateWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// jsonDelegateWriter writes each set as a JSON object on its own line.
+type jsonDelegateWriter struct {
+	enc *json.Encoder
+}
+
+func (w *jsonDelegateWriter) Write(set *delegateSet) error { return w.enc.Encode(set) }
+func (w *jsonDelegateWriter) Flush() error                 { return nil }
+
+// newDelegateWriter creates a delegate set writer of the given format.
+func newDelegateWriter(out io.Writer, format string) (delegateWriter, error) {
+	switch format {
+	case "csv":
+		return &csvDelegateWriter{w: csv.NewWriter(out)}, nil
+	case "json":
+		return &jsonDelegateWriter{enc: json.NewEncoder(out)}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// exportDelegates exports the delegates elected for a range of blocks.
+func exportDelegates(ctx *cli.Context) error {
+	if !ctx.IsSet(delegatesFromFlag.Name) {
+		utils.Fatalf("Export error: --%s is required", delegatesFromFlag.Name)
+	}
+	stack := makeFullNode(ctx)
+	defer stack.Close()
+
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	from, to := ctx.Uint64(delegatesFromFlag.Name), chain.CurrentBlock().NumberU64()
+	if ctx.IsSet(delegatesToFlag.Name) {
+		if n := ctx.Uint64(delegatesToFlag.Name); n < to {
+			to = n
+		}
+	}
+	if from > to {
+		utils.Fatalf("Export error: --%s block #%d is beyond the last block #%d", delegatesFromFlag.Name, from, to)
+	}
+	out := io.Writer(os.Stdout)
+	if path := ctx.String(delegatesOutputFlag.Name); path != "" {
+		fh, err := os.Create(path)
+		if err != nil {
+			utils.Fatalf("Export error: %v", err)
+		}
+		defer fh.Close()
+		out = fh
+	}
+	writer, err := newDelegateWriter(out, ctx.String(delegatesFormatFlag.Name))
+	if err != nil {
+		utils.Fatalf("Export error: %v", err)
+	}
+	start := time.Now()
+
+	count, err := writeDelegateSets(chain, writer, from, to, ctx.Bool(delegatesEpochFlag.Name))
+	if err != nil {
+		utils.Fatalf("Export error: %v", err)
+	}
+	if err := writer.Flush(); err != nil {
+		utils.Fatalf("Export error: %v", err)
+	}
+	log.Info("Exported delegates", "from", from, "to", to, "sets", count, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// writeDelegateSets writes the delegates elected for each canonical block in
+// the range, or only for the first block of each epoch if perEpoch is set. It
+// returns the number of sets written.
+func writeDelegateSets(chain *core.BlockChain, writer delegateWriter, from, to uint64, perEpoch bool) (int, error) {
+	config := chain.Config().DPOS
+	if config == nil {
+		return 0, fmt.Errorf("chain is not using dpos")
+	}
+	epochLength := config.DelegateCount * config.TurnBlockCount
+	if epochLength == 0 {
+		epochLength = 1
+	}
+	var (
+		count  int
+		logged = time.Now()
+	)
+	for number := from; number <= to; number++ {
+		if perEpoch && number%epochLength != 0 {
+			// Skip to the start of the next epoch, watching for overflows
+			next := (number/epochLength + 1) * epochLength
+			if next <= number || next > to {
+				break
+			}
+			number = next
+		}
+		header := chain.GetHeaderByNumber(number)
+		if header == nil {
+			return count, fmt.Errorf("block #%d missing", number)
+		}
+		ebakusState, err := chain.EbakusStateAt(header.Hash(), number)
+		if err != nil {
+			return count, fmt.Errorf("state of block #%d missing (pruned?): %v", number, err)
+		}
+		delegates := dpos.GetDelegates(header, ebakusState, config.DelegateCount, config.BonusDelegateCount, config.TurnBlockCount)
+		ebakusState.Release()
+
+		set := &delegateSet{
+			Number:    number,
+			Hash:      header.Hash(),
+			Time:      header.Time,
+			Epoch:     number / epochLength,
+			Delegates: make([]delegateOut, len(delegates)),
+		}
+		for i, delegate := range delegates {
+			set.Delegates[i] = delegateOut{Address: delegate.Id, Stake: delegate.Stake}
+		}
+		if err := writer.Write(set); err != nil {
+			return count, err
+		}
+		count++
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Exporting delegates", "number", number, "sets", count)
+			logged = time.Now()
+		}
+	}
+	return count, nil
+}
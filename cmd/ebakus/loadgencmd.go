@@ -0,0 +1,430 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of ebakus/go-ebakus.
+//
+// ebakus/go-ebakus is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ebakus/go-ebakus is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ebakus/go-ebakus. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ebakus/go-ebakus/accounts/abi"
+	"github.com/ebakus/go-ebakus/cmd/utils"
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/core/vm"
+	"github.com/ebakus/go-ebakus/crypto"
+	"github.com/ebakus/go-ebakus/ethclient"
+	"github.com/ebakus/go-ebakus/log"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+var (
+	loadgenRPCFlag = cli.StringFlag{
+		Name:  "rpc",
+		Usage: "RPC endpoint of the node to load",
+		Value: "http://localhost:8545",
+	}
+	loadgenFaucetFlag = cli.StringFlag{
+		Name:  "faucet",
+		Usage: "Hex private key of the account funding the test accounts",
+	}
+	loadgenSeedFlag = cli.Int64Flag{
+		Name:  "seed",
+		Usage: "Seed deriving the test accounts and the random choices, for reproducible runs",
+		Value: 1,
+	}
+	loadgenAccountsFlag = cli.IntFlag{
+		Name:  "accounts",
+		Usage: "Number of test accounts sending transactions",
+		Value: 10,
+	}
+	loadgenFundFlag = cli.StringFlag{
+		Name:  "fund",
+		Usage: "Amount (in wei) transferred from the faucet to every test account",
+		Value: "1000000000000000000",
+	}
+	loadgenStakeFlag = cli.Uint64Flag{
+		Name:  "stake",
+		Usage: "Amount every test account stakes before the load starts (0 = none)",
+	}
+	loadgenTxsFlag = cli.IntFlag{
+		Name:  "txs",
+		Usage: "Number of transactions to send",
+		Value: 1000,
+	}
+	loadgenRateFlag = cli.IntFlag{
+		Name:  "rate",
+		Usage: "Transactions sent per second",
+		Value: 50,
+	}
+	loadgenDifficultyFlag = cli.StringFlag{
+		Name:  "difficulty",
+		Usage: "Comma separated multipliers of the suggested difficulty, picked uniformly for every transaction",
+		Value: "1",
+	}
+	loadgenTimeoutFlag = cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "Time to wait for the transactions to be included after the last one is sent",
+		Value: time.Minute,
+	}
+
+	loadgenCommand = cli.Command{
+		Action:    loadgen,
+		Name:      "loadgen",
+		Usage:     "Generate transaction load against a node and report inclusion latencies",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			loadgenRPCFlag,
+			loadgenFaucetFlag,
+			loadgenSeedFlag,
+			loadgenAccountsFlag,
+			loadgenFundFlag,
+			loadgenStakeFlag,
+			loadgenTxsFlag,
+			loadgenRateFlag,
+			loadgenDifficultyFlag,
+			loadgenTimeoutFlag,
+		},
+		Category: "MISCELLANEOUS COMMANDS",
+		Description: `
+The loadgen command derives a set of test accounts from the seed, funds them
+from the faucet account, optionally stakes part of their balance and sends
+transfers between them at the given rate. The difficulty of every transaction
+is the suggested one, scaled by a multiplier picked from the given list.
+
+Once done, it reports how many transactions got included and the percentiles
+of the time from sending a transaction until seeing it in a block. Using the
+same seed derives the same accounts and choices, making runs reproducible.`,
+	}
+)
+
+// loadgenAccount is a test account sending transactions.
+type loadgenAccount struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+	nonce   uint64
+}
+
+// loadgenTracker records the send times of the transactions and the time they
+// were seen included in a block.
+type loadgenTracker struct {
+	lock      sync.Mutex
+	sent      map[common.Hash]time.Time
+	latencies []time.Duration
+}
+
+func loadgen(ctx *cli.Context) error {
+	if !ctx.IsSet(loadgenFaucetFlag.Name) {
+		utils.Fatalf("The faucet private key is required (--%s)", loadgenFaucetFlag.Name)
+	}
+	faucetKey, err := crypto.HexToECDSA(strings.TrimPrefix(ctx.String(loadgenFaucetFlag.Name), "0x"))
+	if err != nil {
+		utils.Fatalf("Invalid faucet key: %v", err)
+	}
+	fund, ok := new(big.Int).SetString(ctx.String(loadgenFundFlag.Name), 10)
+	if !ok {
+		utils.Fatalf("Invalid fund amount: %s", ctx.String(loadgenFundFlag.Name))
+	}
+	multipliers, err := parseMultipliers(ctx.String(loadgenDifficultyFlag.Name))
+	if err != nil {
+		utils.Fatalf("Invalid difficulty distribution: %v", err)
+	}
+	rate := ctx.Int(loadgenRateFlag.Name)
+	if rate <= 0 {
+		utils.Fatalf("The rate must be positive")
+	}
+	client, err := ethclient.Dial(ctx.String(loadgenRPCFlag.Name))
+	if err != nil {
+		utils.Fatalf("Failed to connect to %s: %v", ctx.String(loadgenRPCFlag.Name), err)
+	}
+	defer client.Close()
+
+	background := context.Background()
+	chainID, err := client.ChainID(background)
+	if err != nil {
+		return err
+	}
+	signer := types.NewEIP155Signer(chainID)
+	seed := ctx.Int64(loadgenSeedFlag.Name)
+	rng := rand.New(rand.NewSource(seed))
+
+	// Derive and fund the test accounts
+	faucet := &loadgenAccount{key: faucetKey, address: crypto.PubkeyToAddress(faucetKey.PublicKey)}
+	if faucet.nonce, err = client.PendingNonceAt(background, faucet.address); err != nil {
+		return err
+	}
+	accounts := make([]*loadgenAccount, ctx.Int(loadgenAccountsFlag.Name))
+	for i := range accounts {
+		if accounts[i], err = deriveLoadgenAccount(seed, i); err != nil {
+			return err
+		}
+		if accounts[i].nonce, err = client.PendingNonceAt(background, accounts[i].address); err != nil {
+			return err
+		}
+	}
+	log.Info("Funding test accounts", "accounts", len(accounts), "amount", fund)
+
+	var pending []common.Hash
+	for _, account := range accounts {
+		tx, err := sendLoadgenTx(background, client, signer, faucet, account.address, fund, 21000, nil, 1)
+		if err != nil {
+			return fmt.Errorf("funding %x failed: %v", account.address, err)
+		}
+		pending = append(pending, tx.Hash())
+	}
+	if err := waitLoadgenTxs(background, client, pending, ctx.Duration(loadgenTimeoutFlag.Name)); err != nil {
+		return err
+	}
+	// Stake part of the balance if requested
+	if stake := ctx.Uint64(loadgenStakeFlag.Name); stake > 0 {
+		systemABI, err := abi.JSON(strings.NewReader(vm.SystemContractABI))
+		if err != nil {
+			return err
+		}
+		data, err := systemABI.Pack(vm.SystemContractStakeCmd, stake)
+		if err != nil {
+			return err
+		}
+		log.Info("Staking from test accounts", "accounts", len(accounts), "amount", stake)
+
+		pending = pending[:0]
+		for _, account := range accounts {
+			tx, err := sendLoadgenTx(background, client, signer, account, types.PrecompliledSystemContract, new(big.Int), 1000000, data, 1)
+			if err != nil {
+				return fmt.Errorf("staking from %x failed: %v", account.address, err)
+			}
+			pending = append(pending, tx.Hash())
+		}
+		if err := waitLoadgenTxs(background, client, pending, ctx.Duration(loadgenTimeoutFlag.Name)); err != nil {
+			return err
+		}
+	}
+	// Track the inclusion of the transactions while sending them
+	tracker := &loadgenTracker{sent: make(map[common.Hash]time.Time)}
+
+	head, err := client.HeaderByNumber(background, nil)
+	if err != nil {
+		return err
+	}
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go tracker.watch(client, head.Number.Uint64()+1, quit, done)
+
+	var (
+		total  = ctx.Int(loadgenTxsFlag.Name)
+		failed int
+		ticker = time.NewTicker(time.Second / time.Duration(rate))
+		start  = time.Now()
+	)
+	log.Info("Sending transactions", "count", total, "rate", rate)
+
+	for i := 0; i < total; i++ {
+		<-ticker.C
+
+		from := accounts[i%len(accounts)]
+		to := accounts[(i+1)%len(accounts)]
+		multiplier := multipliers[rng.Intn(len(multipliers))]
+
+		tx, err := signLoadgenTx(background, client, signer, from, to.address, big.NewInt(1), 21000, nil, multiplier)
+		if err == nil {
+			tracker.track(tx)
+			if err = client.SendTransaction(background, tx); err != nil {
+				tracker.untrack(tx)
+			} else {
+				from.nonce++
+			}
+		}
+		if err != nil {
+			log.Warn("Failed to send transaction", "from", from.address, "err", err)
+			failed++
+		}
+	}
+	ticker.Stop()
+	elapsed := time.Since(start)
+
+	// Wait for the remaining transactions to be included
+	deadline := time.After(ctx.Duration(loadgenTimeoutFlag.Name))
+	for waiting := true; waiting; {
+		select {
+		case <-deadline:
+			waiting = false
+		case <-time.After(time.Second):
+			tracker.lock.Lock()
+			waiting = len(tracker.latencies) < total-failed
+			tracker.lock.Unlock()
+		}
+	}
+	close(quit)
+	<-done
+
+	tracker.report(total, failed, elapsed)
+	return nil
+}
+
+// deriveLoadgenAccount deterministically derives the key of a test account.
+func deriveLoadgenAccount(seed int64, index int) (*loadgenAccount, error) {
+	blob := make([]byte, 16)
+	binary.BigEndian.PutUint64(blob[:8], uint64(seed))
+	binary.BigEndian.PutUint64(blob[8:], uint64(index))
+
+	key, err := crypto.ToECDSA(crypto.Keccak256([]byte("ebakus-loadgen"), blob))
+	if err != nil {
+		return nil, err
+	}
+	return &loadgenAccount{key: key, address: crypto.PubkeyToAddress(key.PublicKey)}, nil
+}
+
+// parseMultipliers parses a comma separated list of difficulty multipliers.
+func parseMultipliers(list string) ([]float64, error) {
+	var multipliers []float64
+	for _, field := range strings.Split(list, ",") {
+		var multiplier float64
+		if _, err := fmt.Sscanf(strings.TrimSpace(field), "%g", &multiplier); err != nil {
+			return nil, fmt.Errorf("invalid multiplier %q", field)
+		}
+		if multiplier <= 0 {
+			return nil, fmt.Errorf("multiplier %q not positive", field)
+		}
+		multipliers = append(multipliers, multiplier)
+	}
+	if len(multipliers) == 0 {
+		return nil, errors.New("no multipliers")
+	}
+	return multipliers, nil
+}
+
+// signLoadgenTx signs a transaction with the work nonce for the suggested
+// difficulty scaled by the multiplier.
+func signLoadgenTx(ctx context.Context, client *ethclient.Client, signer types.Signer, from *loadgenAccount, to common.Address, value *big.Int, gas uint64, data []byte, multiplier float64) (*types.Transaction, error) {
+	difficulty, err := client.SuggestDifficulty(ctx, from.address)
+	if err != nil {
+		return nil, err
+	}
+	tx := types.NewTransaction(0, from.nonce, to, value, gas, data)
+	tx.CalculateWorkNonce(*difficulty * multiplier * float64(gas))
+
+	return types.SignTx(tx, signer, from.key)
+}
+
+// sendLoadgenTx signs and sends a transaction.
+func sendLoadgenTx(ctx context.Context, client *ethclient.Client, signer types.Signer, from *loadgenAccount, to common.Address, value *big.Int, gas uint64, data []byte, multiplier float64) (*types.Transaction, error) {
+	tx, err := signLoadgenTx(ctx, client, signer, from, to, value, gas, data, multiplier)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.SendTransaction(ctx, tx); err != nil {
+		return nil, err
+	}
+	from.nonce++
+
+	return tx, nil
+}
+
+// waitLoadgenTxs waits until all the transactions got included.
+func waitLoadgenTxs(ctx context.Context, client *ethclient.Client, hashes []common.Hash, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for _, hash := range hashes {
+		for {
+			if receipt, err := client.TransactionReceipt(ctx, hash); err == nil && receipt != nil {
+				if receipt.Status != types.ReceiptStatusSuccessful {
+					return fmt.Errorf("transaction %x failed", hash)
+				}
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("transaction %x not included in %v", hash, timeout)
+			}
+			time.Sleep(250 * time.Millisecond)
+		}
+	}
+	return nil
+}
+
+// track records the send time of a transaction.
+func (t *loadgenTracker) track(tx *types.Transaction) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.sent[tx.Hash()] = time.Now()
+}
+
+// untrack drops a transaction which failed to be sent.
+func (t *loadgenTracker) untrack(tx *types.Transaction) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.sent, tx.Hash())
+}
+
+// watch polls the blocks from the given number on, recording the inclusion
+// latency of the tracked transactions.
+func (t *loadgenTracker) watch(client *ethclient.Client, number uint64, quit, done chan struct{}) {
+	defer close(done)
+
+	for {
+		block, err := client.BlockByNumber(context.Background(), new(big.Int).SetUint64(number))
+		if err == nil && block != nil {
+			now := time.Now()
+
+			t.lock.Lock()
+			for _, tx := range block.Transactions() {
+				if sent, ok := t.sent[tx.Hash()]; ok {
+					t.latencies = append(t.latencies, now.Sub(sent))
+					delete(t.sent, tx.Hash())
+				}
+			}
+			t.lock.Unlock()
+
+			number++
+			continue
+		}
+		select {
+		case <-quit:
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// report prints the inclusion statistics.
+func (t *loadgenTracker) report(total, failed int, elapsed time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	sort.Slice(t.latencies, func(i, j int) bool { return t.latencies[i] < t.latencies[j] })
+	percentile := func(p float64) time.Duration {
+		if len(t.latencies) == 0 {
+			return 0
+		}
+		return t.latencies[int(p*float64(len(t.latencies)-1))]
+	}
+	fmt.Printf("Sent:      %d in %v (%.1f tx/s)\n", total-failed, common.PrettyDuration(elapsed), float64(total-failed)/elapsed.Seconds())
+	fmt.Printf("Failed:    %d\n", failed)
+	fmt.Printf("Included:  %d\n", len(t.latencies))
+	fmt.Printf("Pending:   %d\n", len(t.sent))
+	fmt.Printf("Latency:   p50 %v, p90 %v, p99 %v, max %v\n",
+		common.PrettyDuration(percentile(0.5)), common.PrettyDuration(percentile(0.9)),
+		common.PrettyDuration(percentile(0.99)), common.PrettyDuration(percentile(1)))
+}
@@ -71,6 +71,7 @@ var (
 		utils.SmartCardDaemonPathFlag,
 		utils.OverrideIstanbulFlag,
 		utils.TxPoolNoLocalsFlag,
+		utils.TxPoolAllowUnprotectedFlag,
 		utils.TxPoolJournalFlag,
 		utils.TxPoolRejournalFlag,
 		utils.TxPoolPriceLimitFlag,
@@ -79,20 +80,35 @@ var (
 		utils.TxPoolGlobalSlotsFlag,
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolGlobalQueueFlag,
+		utils.TxPoolCapacityBaseFlag,
+		utils.TxPoolCapacitySlotsFlag,
 		utils.TxPoolLifetimeFlag,
+		utils.TxPoolQueueJournalFlag,
+		utils.TxPoolQueueLifetimeFlag,
+		utils.TxPoolQueueDifficultyFlag,
 		utils.SyncModeFlag,
 		utils.ExitWhenSyncedFlag,
 		utils.GCModeFlag,
+		utils.ArchiveContractsFlag,
+		utils.SnapshotRetentionFlag,
+		utils.SnapshotCheckpointFlag,
+		utils.ReceiptRetentionFlag,
+		utils.ReceiptContractsFlag,
+		utils.ParallelWorkersFlag,
+		utils.SchemaDryRunFlag,
 		utils.LightServeFlag,
 		utils.LightLegacyServFlag,
 		utils.LightIngressFlag,
 		utils.LightEgressFlag,
 		utils.LightMaxPeersFlag,
+		utils.LightWalletFlag,
 		utils.LightLegacyPeersFlag,
 		utils.LightKDFFlag,
 		utils.UltraLightServersFlag,
 		utils.UltraLightFractionFlag,
 		utils.UltraLightOnlyAnnounceFlag,
+		utils.UltraLightSkipListFlag,
+		utils.UltraLightBatchFlag,
 		utils.WhitelistFlag,
 		utils.CacheFlag,
 		utils.CacheDatabaseFlag,
@@ -108,14 +124,22 @@ var (
 		utils.MinerGasTargetFlag,
 		utils.MinerLegacyGasTargetFlag,
 		utils.MinerGasLimitFlag,
+		utils.MinerGasUtilizationFlag,
+		utils.MinerGasUtilizationWindowFlag,
 		utils.MinerGasPriceFlag,
 		utils.MinerLegacyGasPriceFlag,
 		utils.MinerEtherbaseFlag,
 		utils.MinerLegacyEtherbaseFlag,
 		utils.MinerRecommitIntervalFlag,
 		utils.MinerNoVerfiyFlag,
+		utils.MinerSenderGasShareFlag,
+		utils.MinerStallTimeoutFlag,
+		utils.MinerStallWebhookFlag,
+		utils.MinerStallStopFlag,
 		utils.NATFlag,
+		utils.AnnounceAddrsFlag,
 		utils.NoDiscoverFlag,
+		utils.NoDelegateDialFlag,
 		utils.DiscoveryV5Flag,
 		utils.NetrestrictFlag,
 		utils.NodeKeyFileFlag,
@@ -134,6 +158,8 @@ var (
 		utils.EWASMInterpreterFlag,
 		utils.EVMInterpreterFlag,
 		utils.EbakusdbMaxActiveIteratorsFlag,
+		utils.EbakusdbQueryCacheFlag,
+		utils.EbakusdbMaxIteratorLagFlag,
 		configFileFlag,
 	}
 
@@ -148,6 +174,9 @@ var (
 		utils.GraphQLPortFlag,
 		utils.GraphQLCORSDomainFlag,
 		utils.GraphQLVirtualHostsFlag,
+		utils.StreamEnabledFlag,
+		utils.StreamListenAddrFlag,
+		utils.StreamPortFlag,
 		utils.RPCApiFlag,
 		utils.WSEnabledFlag,
 		utils.WSListenAddrFlag,
@@ -158,6 +187,10 @@ var (
 		utils.IPCPathFlag,
 		utils.InsecureUnlockAllowedFlag,
 		utils.RPCGlobalGasCap,
+		utils.RPCGlobalEVMTimeout,
+		utils.RPCGlobalDBRowsCap,
+		utils.RPCGlobalLogsMaxBlocks,
+		utils.RPCSlowQueryFlag,
 	}
 
 	whisperFlags = []cli.Flag{
@@ -194,7 +227,12 @@ func init() {
 		copydbCommand,
 		removedbCommand,
 		dumpCommand,
+		rewindCommand,
 		inspectCommand,
+		// See dbcmd.go:
+		dbCommand,
+		// See delegatescmd.go:
+		exportDelegatesCommand,
 		// See accountcmd.go:
 		accountCommand,
 		walletCommand,
@@ -209,6 +247,10 @@ func init() {
 		licenseCommand,
 		// See config.go
 		dumpConfigCommand,
+		// See snapshot.go
+		snapshotCommand,
+		// See loadgencmd.go
+		loadgenCommand,
 		// See retesteth.go
 		retestethCommand,
 	}
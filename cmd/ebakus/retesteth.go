@@ -31,6 +31,7 @@ import (
 	"github.com/ebakus/go-ebakus/common/math"
 	"github.com/ebakus/go-ebakus/consensus"
 	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/rawdb"
 	"github.com/ebakus/go-ebakus/core/state"
 	"github.com/ebakus/go-ebakus/core/types"
@@ -43,7 +44,6 @@ import (
 	"github.com/ebakus/go-ebakus/rlp"
 	"github.com/ebakus/go-ebakus/rpc"
 	"github.com/ebakus/go-ebakus/trie"
-	"github.com/ebakus/ebakusdb"
 
 	cli "gopkg.in/urfave/cli.v1"
 )
@@ -102,7 +102,7 @@ type RetestWeb3API interface {
 type RetestethAPI struct {
 	ethDb         ethdb.Database
 	db            state.Database
-	ebakusState   *ebakusdb.Snapshot
+	ebakusState   ebkdb.State
 	chainConfig   *params.ChainConfig
 	author        common.Address
 	extraData     []byte
@@ -226,16 +226,16 @@ func (e *NoRewardEngine) accumulateRewards(config *params.ChainConfig, state *st
 	state.AddBalance(author, reward)
 }
 
-func (e *NoRewardEngine) Finalize(chain consensus.ChainReader, header *types.Header, statedb *state.StateDB, ebakusState *ebakusdb.Snapshot, coinbase common.Address, txs []*types.Transaction) {
+func (e *NoRewardEngine) Finalize(chain consensus.ChainReader, header *types.Header, statedb *state.StateDB, ebakusState ebkdb.State, coinbase common.Address, txs []*types.Transaction) error {
 	if e.rewardsOn {
-		e.inner.Finalize(chain, header, statedb, ebakusState, coinbase, txs)
-	} else {
-		e.accumulateRewards(chain.Config(), statedb, header)
-		header.Root = statedb.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+		return e.inner.Finalize(chain, header, statedb, ebakusState, coinbase, txs)
 	}
+	e.accumulateRewards(chain.Config(), statedb, header)
+	header.Root = statedb.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+	return nil
 }
 
-func (e *NoRewardEngine) FinalizeAndAssemble(chain consensus.ChainReader, header *types.Header, statedb *state.StateDB, ebakusState *ebakusdb.Snapshot, coinbase common.Address, txs []*types.Transaction,
+func (e *NoRewardEngine) FinalizeAndAssemble(chain consensus.ChainReader, header *types.Header, statedb *state.StateDB, ebakusState ebkdb.State, coinbase common.Address, txs []*types.Transaction,
 	receipts []*types.Receipt) (*types.Block, error) {
 	if e.rewardsOn {
 		return e.inner.FinalizeAndAssemble(chain, header, statedb, ebakusState, coinbase, txs, receipts)
@@ -280,7 +280,7 @@ func (api *RetestethAPI) SetChainParams(ctx context.Context, chainParams ChainPa
 		api.ethDb.Close()
 	}
 	ethDb := rawdb.NewMemoryDatabase()
-	ebakusDb, _ := ebakusdb.OpenInMemory(nil)
+	ebakusDb, _ := ebkdb.OpenInMemory(nil)
 	ebakusSnapshot := ebakusDb.GetRootSnapshot()
 	defer ebakusSnapshot.Release()
 	accounts := make(core.GenesisAlloc)
@@ -388,7 +388,7 @@ func (api *RetestethAPI) SetChainParams(ctx context.Context, chainParams ChainPa
 	api.engine = engine
 	api.blockchain = blockchain
 	api.db = state.NewDatabase(api.ethDb)
-	api.ebakusState = ebakusSnapshot
+	api.ebakusState = ebkdb.NewState(ebakusSnapshot)
 	api.blockNumber = 0
 	api.txMap = make(map[common.Address]map[uint64]*types.Transaction)
 	api.txSenders = make(map[common.Address]struct{})
@@ -0,0 +1,644 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of ebakus/go-ebakus.
+//
+// ebakus/go-ebakus is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ebakus/go-ebakus is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ebakus/go-ebakus. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ebakus/go-ebakus/cmd/utils"
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/common/hexutil"
+	"github.com/ebakus/go-ebakus/consensus/dpos"
+	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/core/rawdb"
+	"github.com/ebakus/go-ebakus/core/state"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/crypto"
+	"github.com/ebakus/go-ebakus/ethdb"
+	"github.com/ebakus/go-ebakus/log"
+	"github.com/ebakus/go-ebakus/node"
+	"github.com/ebakus/go-ebakus/params"
+	"github.com/ebakus/go-ebakus/rlp"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// snapshotManifestSuffix is appended to the archive URL to get its manifest.
+const snapshotManifestSuffix = ".manifest.json"
+
+var (
+	fromSnapshotFlag = cli.StringFlag{
+		Name:  "from-snapshot",
+		Usage: "URL or path of a trusted chain and state snapshot archive to bootstrap from",
+	}
+
+	snapshotCommand = cli.Command{
+		Name:      "snapshot",
+		Usage:     "Export and import chain and state snapshots",
+		ArgsUsage: "",
+		Category:  "BLOCKCHAIN COMMANDS",
+		Description: `
+Snapshots hold the chain up to a block along with its account and ebakusdb
+state, so new nodes can be bootstrapped from them without replaying the whole
+chain.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "export",
+				Usage:     "Export the chain and state at a block into a snapshot archive",
+				ArgsUsage: "<archive> [<blockNum>]",
+				Action:    utils.MigrateFlags(exportSnapshot),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.SyncModeFlag,
+				},
+				Description: `
+    ebakus snapshot export <archive> [<blockNum>]
+
+Exports the chain up to the given block, or the head block if omitted, along
+with its state into a gzipped tarball. A manifest holding the checksum of the
+archive is written next to it, suffixed with .manifest.json, for the delegates
+to sign. The node must not be running.`,
+			},
+			{
+				Name:      "import",
+				Usage:     "Bootstrap the chain and state databases from a snapshot archive",
+				ArgsUsage: "<archive>",
+				Action:    utils.MigrateFlags(importSnapshotArchive),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.TestnetFlag,
+				},
+				Description: `
+    ebakus snapshot import <archive>
+
+Imports a snapshot archive into an empty data directory, verifying it against
+its manifest. Unsigned archives are trusted as local exports, while signed ones
+have to be signed by a quorum of the delegates active at the snapshot block.
+Syncing then continues from the snapshot block.`,
+			},
+		},
+	}
+)
+
+// snapshotManifest describes a snapshot archive. It's signed by the delegates
+// active at the snapshot block, a quorum of which vouches for the archive.
+type snapshotManifest struct {
+	Genesis    common.Hash     `json:"genesis"`    // Hash of the genesis block of the chain
+	Number     uint64          `json:"number"`     // Number of the snapshot head block
+	Hash       common.Hash     `json:"hash"`       // Hash of the snapshot head block
+	Archive    common.Hash     `json:"archive"`    // SHA256 of the archive
+	Signatures []hexutil.Bytes `json:"signatures"` // Delegate signatures of the manifest digest
+}
+
+// digest returns the hash the delegates sign.
+func (m *snapshotManifest) digest() common.Hash {
+	blob, _ := rlp.EncodeToBytes([]interface{}{m.Genesis, m.Number, m.Hash, m.Archive})
+	return crypto.Keccak256Hash(blob)
+}
+
+// signers returns the distinct accounts which signed the manifest.
+func (m *snapshotManifest) signers() (map[common.Address]struct{}, error) {
+	digest := m.digest()
+
+	signers := make(map[common.Address]struct{})
+	for _, sig := range m.Signatures {
+		pubkey, err := crypto.SigToPub(digest[:], sig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifest signature: %v", err)
+		}
+		signers[crypto.PubkeyToAddress(*pubkey)] = struct{}{}
+	}
+	return signers, nil
+}
+
+// exportSnapshot exports the chain and state at a block into a snapshot archive.
+func exportSnapshot(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack := makeFullNode(ctx)
+	defer stack.Close()
+
+	chaindb := utils.MakeChainDatabase(ctx, stack)
+	defer chaindb.Close()
+
+	head := rawdb.ReadHeadBlockHash(chaindb)
+	number := rawdb.ReadHeaderNumber(chaindb, head)
+	if number == nil {
+		utils.Fatalf("Export error: head block missing")
+	}
+	if len(ctx.Args()) > 1 {
+		n, err := strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+		if err != nil {
+			utils.Fatalf("Export error in parsing parameters: block number not an integer")
+		}
+		if n > *number {
+			utils.Fatalf("Export error: block #%d is beyond the head block #%d", n, *number)
+		}
+		number = &n
+	}
+	start := time.Now()
+
+	manifest, err := writeSnapshot(stack, chaindb, ctx.Args().First(), *number)
+	if err != nil {
+		utils.Fatalf("Export error: %v", err)
+	}
+	log.Info("Exported snapshot", "number", manifest.Number, "hash", manifest.Hash, "checksum", manifest.Archive)
+	fmt.Printf("Export done in %v\n", time.Since(start))
+	return nil
+}
+
+// importSnapshotArchive bootstraps the databases of the node of the selected
+// network from a snapshot archive.
+func importSnapshotArchive(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	genesis := utils.MakeGenesis(ctx)
+	if genesis == nil {
+		genesis = core.DefaultGenesisBlock()
+	}
+	stack := makeFullNode(ctx)
+	defer stack.Close()
+
+	start := time.Now()
+	if err := importSnapshot(stack, ctx.Args().First(), genesis.ToBlock(nil, nil).Hash(), genesis.Config); err != nil {
+		utils.Fatalf("Import error: %v", err)
+	}
+	fmt.Printf("Import done in %v\n", time.Since(start))
+	return nil
+}
+
+// writeSnapshot exports the chain up to the given block, along with its account
+// state, into a fresh chain database and archives it with the ebakusdb state.
+// The manifest of the archive is written next to it, unsigned.
+func writeSnapshot(stack *node.Node, chaindb ethdb.Database, path string, number uint64) (*snapshotManifest, error) {
+	hash := rawdb.ReadCanonicalHash(chaindb, number)
+	if hash == (common.Hash{}) {
+		return nil, fmt.Errorf("block #%d missing", number)
+	}
+	if rawdb.ReadSnapshot(chaindb, hash, number) == nil {
+		return nil, fmt.Errorf("ebakusdb snapshot of block #%d missing", number)
+	}
+	genesis := rawdb.ReadCanonicalHash(chaindb, 0)
+
+	tmp, err := ioutil.TempDir("", "ebakus-snapshot-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	chainPath := filepath.Join(tmp, "chaindata")
+	if err := copySnapshotChain(chaindb, chainPath, genesis, number); err != nil {
+		return nil, err
+	}
+	checksum, err := archiveSnapshot(path, chainPath, stack.ResolvePath("state.db"))
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	manifest := &snapshotManifest{
+		Genesis:    genesis,
+		Number:     number,
+		Hash:       hash,
+		Archive:    checksum,
+		Signatures: []hexutil.Bytes{},
+	}
+	blob, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(path+snapshotManifestSuffix, blob, 0644); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// copySnapshotChain copies the canonical chain up to the given block into a new
+// database at path, along with the account state of the block.
+func copySnapshotChain(chaindb ethdb.Database, path string, genesis common.Hash, number uint64) error {
+	db, err := rawdb.NewLevelDBDatabase(path, 16, 16, "")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	batch := db.NewBatch()
+	flush := func(force bool) error {
+		if !force && batch.ValueSize() < ethdb.IdealBatchSize {
+			return nil
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		batch.Reset()
+		return nil
+	}
+	var head *types.Block
+	for i := uint64(0); i <= number; i++ {
+		hash := rawdb.ReadCanonicalHash(chaindb, i)
+		block := rawdb.ReadBlock(chaindb, hash, i)
+		if block == nil {
+			return fmt.Errorf("block #%d missing", i)
+		}
+		rawdb.WriteBlock(batch, block)
+		rawdb.WriteReceipts(batch, hash, i, rawdb.ReadRawReceipts(chaindb, hash, i))
+		rawdb.WriteCanonicalHash(batch, hash, i)
+		rawdb.WriteTxLookupEntries(batch, block)
+		if id := rawdb.ReadSnapshot(chaindb, hash, i); id != nil {
+			rawdb.WriteSnapshot(batch, hash, *id)
+		}
+		if err := flush(false); err != nil {
+			return err
+		}
+		head = block
+	}
+	// Copy the account state of the snapshot block, trie nodes and contract
+	// code alike being keyed by their hash
+	statedb, err := state.New(head.Root(), state.NewDatabase(chaindb))
+	if err != nil {
+		return err
+	}
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+		if it.Hash == (common.Hash{}) {
+			continue
+		}
+		blob, err := chaindb.Get(it.Hash[:])
+		if err != nil {
+			return fmt.Errorf("state entry %x missing", it.Hash)
+		}
+		if err := batch.Put(it.Hash[:], blob); err != nil {
+			return err
+		}
+		if err := flush(false); err != nil {
+			return err
+		}
+	}
+	if it.Error != nil {
+		return it.Error
+	}
+	rawdb.WriteHeadHeaderHash(batch, head.Hash())
+	rawdb.WriteHeadBlockHash(batch, head.Hash())
+	rawdb.WriteHeadFastBlockHash(batch, head.Hash())
+	if config := rawdb.ReadChainConfig(chaindb, genesis); config != nil {
+		rawdb.WriteChainConfig(batch, genesis, config)
+	}
+	if version := rawdb.ReadDatabaseVersion(chaindb); version != nil {
+		rawdb.WriteDatabaseVersion(batch, *version)
+	}
+	return flush(true)
+}
+
+// archiveSnapshot writes a gzipped tarball holding the chaindata directory and
+// the state.db ebakusdb database, returning its checksum.
+func archiveSnapshot(path string, chainPath, statePath string) (common.Hash, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(file, hasher))
+	writer := tar.NewWriter(gz)
+
+	err = filepath.Walk(chainPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(chainPath, path)
+		if err != nil {
+			return err
+		}
+		return archiveSnapshotEntry(writer, filepath.Join("chaindata", rel), path, info)
+	})
+	if err != nil {
+		return common.Hash{}, err
+	}
+	info, err := os.Stat(statePath)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := archiveSnapshotEntry(writer, "state.db", statePath, info); err != nil {
+		return common.Hash{}, err
+	}
+	if err := writer.Close(); err != nil {
+		return common.Hash{}, err
+	}
+	if err := gz.Close(); err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(hasher.Sum(nil)), nil
+}
+
+// archiveSnapshotEntry adds a file or directory to the snapshot tarball.
+func archiveSnapshotEntry(writer *tar.Writer, name string, path string, info os.FileInfo) error {
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(name)
+	if err := writer.WriteHeader(header); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(writer, file)
+	return err
+}
+
+// importSnapshot imports the snapshot archive at source, either a URL or a
+// local path, into the data directory of the node, verifying it against its
+// manifest. The chain database is checked to hold the snapshot block linked
+// back to genesis, and the manifest to be signed by a quorum of the delegates
+// active at the snapshot block. Only local archives may be unsigned, being
+// trusted as exported by the operator.
+func importSnapshot(stack *node.Node, source string, genesis common.Hash, config *params.ChainConfig) error {
+	if config.DPOS == nil {
+		return errors.New("snapshots are only supported on DPOS chains")
+	}
+	chainPath, statePath := stack.ResolvePath("chaindata"), stack.ResolvePath("state.db")
+	for _, path := range []string{chainPath, statePath} {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("database %s already exists", path)
+		}
+	}
+	var (
+		manifest = new(snapshotManifest)
+		remote   = strings.Contains(source, "://")
+		archive  = source
+		err      error
+	)
+	if remote {
+		err = fetchSnapshotManifest(source+snapshotManifestSuffix, manifest)
+	} else {
+		err = loadSnapshotManifest(source+snapshotManifestSuffix, manifest)
+	}
+	if err != nil {
+		return err
+	}
+	if manifest.Genesis != genesis {
+		return fmt.Errorf("snapshot of another chain: genesis %x, want %x", manifest.Genesis, genesis)
+	}
+	if remote {
+		if archive, err = downloadSnapshot(source, manifest.Archive); err != nil {
+			return err
+		}
+		defer os.Remove(archive)
+	} else if err := checkSnapshot(archive, manifest.Archive); err != nil {
+		return err
+	}
+	if err := extractSnapshot(archive, chainPath, statePath); err != nil {
+		os.RemoveAll(chainPath)
+		os.RemoveAll(statePath)
+		return err
+	}
+	if err := verifySnapshot(stack, manifest, config.DPOS, remote || len(manifest.Signatures) > 0); err != nil {
+		os.RemoveAll(chainPath)
+		os.RemoveAll(statePath)
+		return err
+	}
+	log.Info("Imported trusted snapshot", "number", manifest.Number, "hash", manifest.Hash, "signatures", len(manifest.Signatures))
+	return nil
+}
+
+func fetchSnapshotManifest(url string, manifest *snapshotManifest) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch snapshot manifest: %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(manifest)
+}
+
+func loadSnapshotManifest(path string, manifest *snapshotManifest) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return json.NewDecoder(file).Decode(manifest)
+}
+
+// checkSnapshot verifies the checksum of a local archive.
+func checkSnapshot(archive string, checksum common.Hash) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return err
+	}
+	if sum := common.BytesToHash(hasher.Sum(nil)); sum != checksum {
+		return fmt.Errorf("snapshot checksum mismatch: have %x, want %x", sum, checksum)
+	}
+	return nil
+}
+
+// downloadSnapshot downloads the archive into a temporary file, returning its
+// path if its checksum matches the expected one.
+func downloadSnapshot(url string, checksum common.Hash) (string, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download snapshot: %s", resp.Status)
+	}
+	file, err := ioutil.TempFile("", "ebakus-snapshot-")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	log.Info("Downloading snapshot", "url", url, "size", common.StorageSize(resp.ContentLength))
+
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(file, hasher), resp.Body); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	if sum := common.BytesToHash(hasher.Sum(nil)); sum != checksum {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("snapshot checksum mismatch: have %x, want %x", sum, checksum)
+	}
+	return file.Name(), nil
+}
+
+// extractSnapshot unpacks a gzipped tarball holding the chaindata directory and
+// the state.db ebakusdb database.
+func extractSnapshot(archive string, chainPath, statePath string) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+
+		var target string
+		switch {
+		case name == "chaindata":
+			target = chainPath
+		case strings.HasPrefix(name, "chaindata"+string(filepath.Separator)):
+			target = filepath.Join(chainPath, strings.TrimPrefix(name, "chaindata"))
+		case name == "state.db":
+			target = statePath
+		default:
+			return fmt.Errorf("unexpected snapshot entry %q", header.Name)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(out, reader)
+			out.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported snapshot entry %q", header.Name)
+		}
+	}
+}
+
+// verifySnapshot checks the extracted databases against the manifest. The
+// delegate signatures are only checked if checkSigners is set.
+func verifySnapshot(stack *node.Node, manifest *snapshotManifest, config *params.DPOSConfig, checkSigners bool) error {
+	chaindb, err := stack.OpenDatabaseWithFreezer("chaindata", 0, 0, "", "")
+	if err != nil {
+		return err
+	}
+	defer chaindb.Close()
+
+	// Make sure the snapshot block is canonical and links back to genesis
+	if hash := rawdb.ReadCanonicalHash(chaindb, manifest.Number); hash != manifest.Hash {
+		return fmt.Errorf("snapshot block #%d mismatch: have %x, want %x", manifest.Number, hash, manifest.Hash)
+	}
+	head := rawdb.ReadHeader(chaindb, manifest.Hash, manifest.Number)
+	if head == nil {
+		return fmt.Errorf("snapshot block #%d missing", manifest.Number)
+	}
+	for header := head; header.Number.Uint64() > 0; {
+		parent := rawdb.ReadHeader(chaindb, header.ParentHash, header.Number.Uint64()-1)
+		if parent == nil {
+			return fmt.Errorf("snapshot chain broken at block #%d", header.Number.Uint64()-1)
+		}
+		header = parent
+	}
+	if hash := rawdb.ReadCanonicalHash(chaindb, 0); hash != manifest.Genesis {
+		return fmt.Errorf("snapshot genesis mismatch: have %x, want %x", hash, manifest.Genesis)
+	}
+	if _, err := state.New(head.Root, state.NewDatabase(chaindb)); err != nil {
+		return fmt.Errorf("snapshot account state of block #%d missing: %v", manifest.Number, err)
+	}
+	// Make sure a quorum of the delegates at the snapshot block signed it
+	id := rawdb.ReadSnapshot(chaindb, manifest.Hash, manifest.Number)
+	if id == nil {
+		return fmt.Errorf("snapshot state of block #%d missing", manifest.Number)
+	}
+	ebakusDb, err := stack.OpenEbakusDatabase("state.db")
+	if err != nil {
+		return err
+	}
+	defer ebakusDb.Close()
+
+	ebakusState := ebkdb.NewState(ebakusDb.Snapshot(*id))
+	if ebakusState == nil {
+		return fmt.Errorf("snapshot state of block #%d missing", manifest.Number)
+	}
+	defer ebakusState.Release()
+
+	if !checkSigners {
+		log.Warn("Snapshot is unsigned, trusting it as a local export", "number", manifest.Number)
+		return nil
+	}
+	signers, err := manifest.signers()
+	if err != nil {
+		return err
+	}
+	delegates := dpos.GetDelegates(head, ebakusState, config.DelegateCount, config.BonusDelegateCount, config.TurnBlockCount)
+
+	signed := 0
+	for _, delegate := range delegates {
+		if _, ok := signers[delegate.Id]; ok {
+			signed++
+		}
+	}
+	if quorum := len(delegates)*2/3 + 1; signed < quorum {
+		return fmt.Errorf("snapshot signed by %d delegates, quorum is %d", signed, quorum)
+	}
+	return nil
+}
@@ -78,6 +78,13 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.SyncModeFlag,
 			utils.ExitWhenSyncedFlag,
 			utils.GCModeFlag,
+			utils.ArchiveContractsFlag,
+			utils.SnapshotRetentionFlag,
+			utils.SnapshotCheckpointFlag,
+			utils.ReceiptRetentionFlag,
+			utils.ReceiptContractsFlag,
+			utils.ParallelWorkersFlag,
+			utils.SchemaDryRunFlag,
 			utils.EthStatsURLFlag,
 			utils.IdentityFlag,
 			utils.LightKDFFlag,
@@ -91,9 +98,12 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.LightIngressFlag,
 			utils.LightEgressFlag,
 			utils.LightMaxPeersFlag,
+			utils.LightWalletFlag,
 			utils.UltraLightServersFlag,
 			utils.UltraLightFractionFlag,
 			utils.UltraLightOnlyAnnounceFlag,
+			utils.UltraLightSkipListFlag,
+			utils.UltraLightBatchFlag,
 		},
 	},
 	{
@@ -124,6 +134,7 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			utils.TxPoolLocalsFlag,
 			utils.TxPoolNoLocalsFlag,
+			utils.TxPoolAllowUnprotectedFlag,
 			utils.TxPoolJournalFlag,
 			utils.TxPoolRejournalFlag,
 			utils.TxPoolPriceLimitFlag,
@@ -132,7 +143,12 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TxPoolGlobalSlotsFlag,
 			utils.TxPoolAccountQueueFlag,
 			utils.TxPoolGlobalQueueFlag,
+			utils.TxPoolCapacityBaseFlag,
+			utils.TxPoolCapacitySlotsFlag,
 			utils.TxPoolLifetimeFlag,
+			utils.TxPoolQueueJournalFlag,
+			utils.TxPoolQueueLifetimeFlag,
+			utils.TxPoolQueueDifficultyFlag,
 		},
 	},
 	{
@@ -164,6 +180,10 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.RPCPortFlag,
 			utils.RPCApiFlag,
 			utils.RPCGlobalGasCap,
+			utils.RPCGlobalEVMTimeout,
+			utils.RPCGlobalDBRowsCap,
+			utils.RPCGlobalLogsMaxBlocks,
+			utils.RPCSlowQueryFlag,
 			utils.RPCCORSDomainFlag,
 			utils.RPCVirtualHostsFlag,
 			utils.WSEnabledFlag,
@@ -176,6 +196,9 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.GraphQLPortFlag,
 			utils.GraphQLCORSDomainFlag,
 			utils.GraphQLVirtualHostsFlag,
+			utils.StreamEnabledFlag,
+			utils.StreamListenAddrFlag,
+			utils.StreamPortFlag,
 			utils.JSpathFlag,
 			utils.ExecFlag,
 			utils.PreloadJSFlag,
@@ -191,7 +214,9 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.MaxPeersFlag,
 			utils.MaxPendingPeersFlag,
 			utils.NATFlag,
+			utils.AnnounceAddrsFlag,
 			utils.NoDiscoverFlag,
+			utils.NoDelegateDialFlag,
 			utils.DiscoveryV5Flag,
 			utils.NetrestrictFlag,
 			utils.NodeKeyFileFlag,
@@ -207,9 +232,15 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.MinerGasPriceFlag,
 			utils.MinerGasTargetFlag,
 			utils.MinerGasLimitFlag,
+			utils.MinerGasUtilizationFlag,
+			utils.MinerGasUtilizationWindowFlag,
 			utils.MinerEtherbaseFlag,
 			utils.MinerRecommitIntervalFlag,
 			utils.MinerNoVerfiyFlag,
+			utils.MinerSenderGasShareFlag,
+			utils.MinerStallTimeoutFlag,
+			utils.MinerStallWebhookFlag,
+			utils.MinerStallStopFlag,
 		},
 	},
 	{
@@ -250,6 +281,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.MinerLegacyGasTargetFlag,
 			utils.MinerLegacyGasPriceFlag,
 			utils.MinerLegacyEtherbaseFlag,
+			utils.EbakusdbMaxActiveIteratorsFlag,
 		},
 	},
 	{
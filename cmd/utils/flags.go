@@ -32,13 +32,13 @@ import (
 	"text/template"
 	"time"
 
-	"github.com/ebakus/ebakusdb"
 	"github.com/ebakus/go-ebakus/accounts"
 	"github.com/ebakus/go-ebakus/accounts/keystore"
 	"github.com/ebakus/go-ebakus/common"
 	"github.com/ebakus/go-ebakus/common/fdlimit"
 	"github.com/ebakus/go-ebakus/consensus/dpos"
 	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/types"
 	"github.com/ebakus/go-ebakus/core/vm"
 	"github.com/ebakus/go-ebakus/crypto"
@@ -61,6 +61,7 @@ import (
 	"github.com/ebakus/go-ebakus/p2p/netutil"
 	"github.com/ebakus/go-ebakus/params"
 	"github.com/ebakus/go-ebakus/rpc"
+	"github.com/ebakus/go-ebakus/stream"
 	whisper "github.com/ebakus/go-ebakus/whisper/whisperv6"
 	pcsclite "github.com/gballet/go-libpcsclite"
 	cli "gopkg.in/urfave/cli.v1"
@@ -202,7 +203,7 @@ var (
 	defaultSyncMode = eth.DefaultConfig.SyncMode
 	SyncModeFlag    = TextMarshalerFlag{
 		Name:  "syncmode",
-		Usage: `Blockchain sync mode ("fast", "full", or "light")`,
+		Usage: `Blockchain sync mode ("fast", "full", "light" or "snapshot")`,
 		Value: &defaultSyncMode,
 	}
 	GCModeFlag = cli.StringFlag{
@@ -210,6 +211,38 @@ var (
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
 		Value: "full",
 	}
+	ArchiveContractsFlag = cli.StringFlag{
+		Name:  "archive.contracts",
+		Usage: "Comma separated contract addresses to retain historical ebakusdb state for, pruning it for all others",
+	}
+	SnapshotRetentionFlag = cli.Uint64Flag{
+		Name:  "snapshot.retention",
+		Usage: "Number of recent blocks to retain the ebakusdb snapshots of (0 = retain all)",
+		Value: eth.DefaultConfig.SnapshotRetention,
+	}
+	SnapshotCheckpointFlag = cli.Uint64Flag{
+		Name:  "snapshot.checkpoint",
+		Usage: "Interval of the blocks whose ebakusdb snapshots are retained past the retention window (0 = none)",
+		Value: eth.DefaultConfig.SnapshotCheckpoint,
+	}
+	ReceiptRetentionFlag = cli.Uint64Flag{
+		Name:  "receipts.retention",
+		Usage: "Number of recent blocks to retain the receipts and logs of (0 = retain all)",
+		Value: eth.DefaultConfig.ReceiptRetention,
+	}
+	ReceiptContractsFlag = cli.StringFlag{
+		Name:  "receipts.contracts",
+		Usage: "Comma separated contract addresses to retain the receipts and logs of past the retention window",
+	}
+	ParallelWorkersFlag = cli.IntFlag{
+		Name:  "parallel.workers",
+		Usage: "Number of workers executing the disjoint transactions of blocks in parallel (< 2 = serial)",
+		Value: eth.DefaultConfig.ParallelWorkers,
+	}
+	SchemaDryRunFlag = cli.BoolFlag{
+		Name:  "schema.dryrun",
+		Usage: "Check the pending system schema migrations on the head state without persisting them, then exit",
+	}
 	LightKDFFlag = cli.BoolFlag{
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
@@ -253,6 +286,11 @@ var (
 		Usage: "Maximum number of light clients to serve, or light servers to attach to",
 		Value: eth.DefaultConfig.LightPeers,
 	}
+	LightWalletFlag = cli.StringFlag{
+		Name:  "light.wallet",
+		Usage: "Comma separated accounts whose stake state the light client syncs",
+		Value: "",
+	}
 	UltraLightServersFlag = cli.StringFlag{
 		Name:  "ulc.servers",
 		Usage: "List of trusted ultra-light servers",
@@ -267,6 +305,16 @@ var (
 		Name:  "ulc.onlyannounce",
 		Usage: "Ultra light server sends announcements only",
 	}
+	UltraLightSkipListFlag = cli.Uint64Flag{
+		Name:  "ulc.skiplist",
+		Usage: "Ultra light client verifies the seal of every Nth header plus all delegate set changes (0 = verify none)",
+		Value: eth.DefaultConfig.UltraLightSkipList,
+	}
+	UltraLightBatchFlag = cli.DurationFlag{
+		Name:  "ulc.batch",
+		Usage: "Interval over which ultra light client coalesces server announcements (0 = process immediately)",
+		Value: eth.DefaultConfig.UltraLightBatch,
+	}
 	// Transaction pool settings
 	TxPoolLocalsFlag = cli.StringFlag{
 		Name:  "txpool.locals",
@@ -276,6 +324,10 @@ var (
 		Name:  "txpool.nolocals",
 		Usage: "Disables price exemptions for locally submitted transactions",
 	}
+	TxPoolAllowUnprotectedFlag = cli.BoolFlag{
+		Name:  "txpool.allowunprotected",
+		Usage: "Accepts transactions without EIP155 replay protection, until the chain rejects them",
+	}
 	TxPoolJournalFlag = cli.StringFlag{
 		Name:  "txpool.journal",
 		Usage: "Disk journal for local transaction to survive node restarts",
@@ -316,11 +368,36 @@ var (
 		Usage: "Maximum number of non-executable transaction slots for all accounts",
 		Value: eth.DefaultConfig.TxPool.GlobalQueue,
 	}
+	TxPoolCapacityBaseFlag = cli.Uint64Flag{
+		Name:  "txpool.capacitybase",
+		Usage: "Number of transaction slots of the remote accounts without stake (0 = unlimited)",
+		Value: eth.DefaultConfig.TxPool.CapacityBase,
+	}
+	TxPoolCapacitySlotsFlag = cli.Uint64Flag{
+		Name:  "txpool.capacityslots",
+		Usage: "Number of transaction slots added to the base ones, scaled by the share of the stake of the account",
+		Value: eth.DefaultConfig.TxPool.CapacitySlots,
+	}
 	TxPoolLifetimeFlag = cli.DurationFlag{
 		Name:  "txpool.lifetime",
 		Usage: "Maximum amount of time non-executable transaction are queued",
 		Value: eth.DefaultConfig.TxPool.Lifetime,
 	}
+	TxPoolQueueJournalFlag = cli.StringFlag{
+		Name:  "txpool.queuejournal",
+		Usage: "Disk journal for remote queued transactions to survive node restarts (disabled if empty)",
+		Value: core.DefaultTxPoolConfig.QueueJournal,
+	}
+	TxPoolQueueLifetimeFlag = cli.DurationFlag{
+		Name:  "txpool.queuelifetime",
+		Usage: "Maximum age of the journaled queued transactions to reload on startup",
+		Value: eth.DefaultConfig.TxPool.QueueLifetime,
+	}
+	TxPoolQueueDifficultyFlag = cli.Float64Flag{
+		Name:  "txpool.queuedifficulty",
+		Usage: "Minimum Proof of Work difficulty of the remote queued transactions to journal",
+		Value: eth.DefaultConfig.TxPool.QueueDifficulty,
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -375,6 +452,15 @@ var (
 		Usage: "Target gas ceiling for mined blocks",
 		Value: eth.DefaultConfig.Miner.GasCeil,
 	}
+	MinerGasUtilizationFlag = cli.Float64Flag{
+		Name:  "miner.gasutilization",
+		Usage: "Target gas utilization ratio (0-1] the gas limit of mined blocks adapts to (0 = disabled)",
+	}
+	MinerGasUtilizationWindowFlag = cli.Uint64Flag{
+		Name:  "miner.gasutilizationwindow",
+		Usage: "Number of blocks the gas utilization is averaged over",
+		Value: eth.DefaultConfig.Miner.GasWindow,
+	}
 	MinerGasPriceFlag = cli.Float64Flag{
 		Name:  "miner.gasprice",
 		Usage: "Minimum gas price for mining a transaction",
@@ -397,13 +483,29 @@ var (
 	}
 	MinerRecommitIntervalFlag = cli.DurationFlag{
 		Name:  "miner.recommit",
-		Usage: "Time interval to recreate the block being mined",
+		Usage: "Maximum time spent packing transactions into a block, capped by the slot deadline",
 		Value: eth.DefaultConfig.Miner.Recommit,
 	}
 	MinerNoVerfiyFlag = cli.BoolFlag{
 		Name:  "miner.noverify",
 		Usage: "Disable remote sealing verification",
 	}
+	MinerSenderGasShareFlag = cli.Uint64Flag{
+		Name:  "miner.sendergasshare",
+		Usage: "Maximum percentage of the block gas a single sender fills while others have transactions pending (0 = no cap)",
+	}
+	MinerStallTimeoutFlag = cli.DurationFlag{
+		Name:  "miner.stalltimeout",
+		Usage: "Time without blocks from the network after which block production is considered stalled (0 = disabled)",
+	}
+	MinerStallWebhookFlag = cli.StringFlag{
+		Name:  "miner.stallwebhook",
+		Usage: "HTTP URL to post an alert to when block production stalls",
+	}
+	MinerStallStopFlag = cli.BoolFlag{
+		Name:  "miner.stallstop",
+		Usage: "Stop producing blocks when block production stalls",
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -432,6 +534,23 @@ var (
 		Name:  "rpc.gascap",
 		Usage: "Sets a cap on gas that can be used in eth_call/estimateGas",
 	}
+	RPCGlobalEVMTimeout = cli.DurationFlag{
+		Name:  "rpc.evmtimeout",
+		Usage: "Sets a timeout used for eth_call/estimateGas (0=infinite)",
+		Value: eth.DefaultConfig.RPCEVMTimeout,
+	}
+	RPCGlobalDBRowsCap = cli.Uint64Flag{
+		Name:  "rpc.dbrowscap",
+		Usage: "Sets a cap on ebakus db rows that can be read in eth_call/estimateGas (0=no cap)",
+	}
+	RPCGlobalLogsMaxBlocks = cli.Uint64Flag{
+		Name:  "rpc.logsmaxblocks",
+		Usage: "Sets a cap on the blocks searched by eth_getLogs, and the blocks searched per eth_getLogsPage call (0=no cap)",
+	}
+	RPCSlowQueryFlag = cli.DurationFlag{
+		Name:  "rpc.slowquery",
+		Usage: "Logs the RPC calls taking longer than this, along with their parameters (0=disabled)",
+	}
 	// Logging and debug settings
 	EthStatsURLFlag = cli.StringFlag{
 		Name:  "ethstats",
@@ -531,6 +650,20 @@ var (
 		Usage: "Comma separated list of virtual hostnames from which to accept requests (server enforced). Accepts '*' wildcard.",
 		Value: strings.Join(node.DefaultConfig.GraphQLVirtualHosts, ","),
 	}
+	StreamEnabledFlag = cli.BoolFlag{
+		Name:  "stream",
+		Usage: "Enable the gRPC chain streaming server",
+	}
+	StreamListenAddrFlag = cli.StringFlag{
+		Name:  "stream.addr",
+		Usage: "gRPC chain streaming server listening interface",
+		Value: "localhost",
+	}
+	StreamPortFlag = cli.IntFlag{
+		Name:  "stream.port",
+		Usage: "gRPC chain streaming server listening port",
+		Value: 8549,
+	}
 	ExecFlag = cli.StringFlag{
 		Name:  "exec",
 		Usage: "Execute JavaScript statement",
@@ -539,10 +672,18 @@ var (
 		Name:  "preload",
 		Usage: "Comma separated list of JavaScript files to preload into the console",
 	}
-	EbakusdbMaxActiveIteratorsFlag = cli.Uint64Flag{
+	EbakusdbMaxActiveIteratorsFlag = cli.Uint64Flag{ // Deprecated, RPC iterators are capped and released once idle, remove in 2021
 		Name:  "maxactiveiterators",
-		Usage: "Maximum number of ebakusDb iterators to retain in memory for RPC APIs",
-		Value: eth.DefaultConfig.EbakusdbMaxActiveIterators,
+		Usage: "Maximum number of ebakusDb iterators to retain in memory for RPC APIs (deprecated, ignored)",
+	}
+	EbakusdbQueryCacheFlag = cli.IntFlag{
+		Name:  "dbquerycache",
+		Usage: "Number of rows returned by ebakusDb RPC queries to cache (0 = disabled)",
+		Value: eth.DefaultConfig.EbakusdbQueryCache,
+	}
+	EbakusdbMaxIteratorLagFlag = cli.Uint64Flag{
+		Name:  "dbmaxiteratorlag",
+		Usage: "Blocks an ebakusDb RPC iterator's snapshot may fall behind the head before failing (0 = unlimited)",
 	}
 	// Network Settings
 	MaxPeersFlag = cli.IntFlag{
@@ -588,10 +729,19 @@ var (
 		Usage: "NAT port mapping mechanism (any|none|upnp|pmp|extip:<IP>)",
 		Value: "any",
 	}
+	AnnounceAddrsFlag = cli.StringFlag{
+		Name:  "announceaddrs",
+		Usage: "Comma separated endpoints (ip:port) to advertise, at most one IPv4 and one IPv6",
+		Value: "",
+	}
 	NoDiscoverFlag = cli.BoolFlag{
 		Name:  "nodiscover",
 		Usage: "Disables the peer discovery mechanism (manual peer addition)",
 	}
+	NoDelegateDialFlag = cli.BoolFlag{
+		Name:  "nodelegatedial",
+		Usage: "Disables staying connected to the announced enodes of the block producers",
+	}
 	DiscoveryV5Flag = cli.BoolFlag{
 		Name:  "v5disc",
 		Usage: "Enables the experimental RLPx V5 (Topic Discovery) mechanism",
@@ -611,12 +761,12 @@ var (
 	// Gas price oracle settings
 	GpoBlocksFlag = cli.IntFlag{
 		Name:  "gpoblocks",
-		Usage: "Number of recent blocks to check for gas prices",
+		Usage: "Number of recent blocks to check for gas prices and virtual difficulties",
 		Value: eth.DefaultConfig.GPO.Blocks,
 	}
 	GpoPercentileFlag = cli.IntFlag{
 		Name:  "gpopercentile",
-		Usage: "Suggested gas price is the given percentile of a set of recent transaction gas prices",
+		Usage: "Suggested gas price and virtual difficulty are the given percentile of the recent block minimums",
 		Value: eth.DefaultConfig.GPO.Percentile,
 	}
 	WhisperEnabledFlag = cli.BoolFlag{
@@ -815,6 +965,9 @@ func setNAT(ctx *cli.Context, cfg *p2p.Config) {
 		}
 		cfg.NAT = natif
 	}
+	if ctx.GlobalIsSet(AnnounceAddrsFlag.Name) {
+		cfg.AnnounceAddrs = splitAndTrim(ctx.GlobalString(AnnounceAddrsFlag.Name))
+	}
 }
 
 // splitAndTrim splits input separated by a comma
@@ -920,6 +1073,15 @@ func setLes(ctx *cli.Context, cfg *eth.Config) {
 	if ctx.GlobalIsSet(LightMaxPeersFlag.Name) {
 		cfg.LightPeers = ctx.GlobalInt(LightMaxPeersFlag.Name)
 	}
+	if ctx.GlobalIsSet(LightWalletFlag.Name) {
+		for _, account := range strings.Split(ctx.GlobalString(LightWalletFlag.Name), ",") {
+			if trimmed := strings.TrimSpace(account); !common.IsHexAddress(trimmed) {
+				Fatalf("Invalid account in --light.wallet: %s", trimmed)
+			} else {
+				cfg.WalletAddresses = append(cfg.WalletAddresses, common.HexToAddress(account))
+			}
+		}
+	}
 	if ctx.GlobalIsSet(UltraLightServersFlag.Name) {
 		cfg.UltraLightServers = strings.Split(ctx.GlobalString(UltraLightServersFlag.Name), ",")
 	}
@@ -933,6 +1095,12 @@ func setLes(ctx *cli.Context, cfg *eth.Config) {
 	if ctx.GlobalIsSet(UltraLightOnlyAnnounceFlag.Name) {
 		cfg.UltraLightOnlyAnnounce = ctx.GlobalBool(UltraLightOnlyAnnounceFlag.Name)
 	}
+	if ctx.GlobalIsSet(UltraLightSkipListFlag.Name) {
+		cfg.UltraLightSkipList = ctx.GlobalUint64(UltraLightSkipListFlag.Name)
+	}
+	if ctx.GlobalIsSet(UltraLightBatchFlag.Name) {
+		cfg.UltraLightBatch = ctx.GlobalDuration(UltraLightBatchFlag.Name)
+	}
 }
 
 // makeDatabaseHandles raises out the number of allowed file handles per process
@@ -1119,6 +1287,9 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(InsecureUnlockAllowedFlag.Name) {
 		cfg.InsecureUnlockAllowed = ctx.GlobalBool(InsecureUnlockAllowedFlag.Name)
 	}
+	if ctx.GlobalIsSet(RPCSlowQueryFlag.Name) {
+		cfg.RPCSlowQuery = ctx.GlobalDuration(RPCSlowQueryFlag.Name)
+	}
 }
 
 func setSmartCard(ctx *cli.Context, cfg *node.Config) {
@@ -1175,6 +1346,9 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolNoLocalsFlag.Name) {
 		cfg.NoLocals = ctx.GlobalBool(TxPoolNoLocalsFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolAllowUnprotectedFlag.Name) {
+		cfg.AllowUnprotected = ctx.GlobalBool(TxPoolAllowUnprotectedFlag.Name)
+	}
 	if ctx.GlobalIsSet(TxPoolJournalFlag.Name) {
 		cfg.Journal = ctx.GlobalString(TxPoolJournalFlag.Name)
 	}
@@ -1199,9 +1373,24 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolGlobalQueueFlag.Name) {
 		cfg.GlobalQueue = ctx.GlobalUint64(TxPoolGlobalQueueFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolCapacityBaseFlag.Name) {
+		cfg.CapacityBase = ctx.GlobalUint64(TxPoolCapacityBaseFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolCapacitySlotsFlag.Name) {
+		cfg.CapacitySlots = ctx.GlobalUint64(TxPoolCapacitySlotsFlag.Name)
+	}
 	if ctx.GlobalIsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.GlobalDuration(TxPoolLifetimeFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolQueueJournalFlag.Name) {
+		cfg.QueueJournal = ctx.GlobalString(TxPoolQueueJournalFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolQueueLifetimeFlag.Name) {
+		cfg.QueueLifetime = ctx.GlobalDuration(TxPoolQueueLifetimeFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolQueueDifficultyFlag.Name) {
+		cfg.QueueDifficulty = ctx.GlobalFloat64(TxPoolQueueDifficultyFlag.Name)
+	}
 }
 
 func setMiner(ctx *cli.Context, cfg *miner.Config) {
@@ -1217,6 +1406,12 @@ func setMiner(ctx *cli.Context, cfg *miner.Config) {
 	if ctx.GlobalIsSet(MinerGasLimitFlag.Name) {
 		cfg.GasCeil = ctx.GlobalUint64(MinerGasLimitFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerGasUtilizationFlag.Name) {
+		cfg.GasTarget = ctx.GlobalFloat64(MinerGasUtilizationFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerGasUtilizationWindowFlag.Name) {
+		cfg.GasWindow = ctx.GlobalUint64(MinerGasUtilizationWindowFlag.Name)
+	}
 	if ctx.GlobalIsSet(MinerLegacyGasPriceFlag.Name) {
 		cfg.GasPrice = ctx.GlobalFloat64(MinerLegacyGasPriceFlag.Name)
 	}
@@ -1229,6 +1424,18 @@ func setMiner(ctx *cli.Context, cfg *miner.Config) {
 	if ctx.GlobalIsSet(MinerNoVerfiyFlag.Name) {
 		cfg.Noverify = ctx.Bool(MinerNoVerfiyFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerSenderGasShareFlag.Name) {
+		cfg.SenderGasShare = ctx.GlobalUint64(MinerSenderGasShareFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerStallTimeoutFlag.Name) {
+		cfg.StallTimeout = ctx.GlobalDuration(MinerStallTimeoutFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerStallWebhookFlag.Name) {
+		cfg.StallWebhook = ctx.GlobalString(MinerStallWebhookFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerStallStopFlag.Name) {
+		cfg.StallStop = ctx.GlobalBool(MinerStallStopFlag.Name)
+	}
 }
 
 func setWhitelist(ctx *cli.Context, cfg *eth.Config) {
@@ -1348,6 +1555,48 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(GCModeFlag.Name) {
 		cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
 	}
+	if ctx.GlobalIsSet(ArchiveContractsFlag.Name) {
+		cfg.ArchiveContracts = []common.Address{}
+		for _, contract := range strings.Split(ctx.GlobalString(ArchiveContractsFlag.Name), ",") {
+			if contract = strings.TrimSpace(contract); contract == "" {
+				continue
+			}
+			if !common.IsHexAddress(contract) {
+				Fatalf("Invalid archived contract address: %s", contract)
+			}
+			cfg.ArchiveContracts = append(cfg.ArchiveContracts, common.HexToAddress(contract))
+		}
+	}
+	if ctx.GlobalIsSet(SnapshotRetentionFlag.Name) {
+		cfg.SnapshotRetention = ctx.GlobalUint64(SnapshotRetentionFlag.Name)
+	}
+	if ctx.GlobalIsSet(SnapshotCheckpointFlag.Name) {
+		cfg.SnapshotCheckpoint = ctx.GlobalUint64(SnapshotCheckpointFlag.Name)
+	}
+	if ctx.GlobalIsSet(ReceiptRetentionFlag.Name) {
+		cfg.ReceiptRetention = ctx.GlobalUint64(ReceiptRetentionFlag.Name)
+	}
+	if ctx.GlobalIsSet(ReceiptContractsFlag.Name) {
+		cfg.ReceiptContracts = []common.Address{}
+		for _, contract := range strings.Split(ctx.GlobalString(ReceiptContractsFlag.Name), ",") {
+			if contract = strings.TrimSpace(contract); contract == "" {
+				continue
+			}
+			if !common.IsHexAddress(contract) {
+				Fatalf("Invalid retained receipts contract address: %s", contract)
+			}
+			cfg.ReceiptContracts = append(cfg.ReceiptContracts, common.HexToAddress(contract))
+		}
+	}
+	if ctx.GlobalIsSet(ParallelWorkersFlag.Name) {
+		cfg.ParallelWorkers = ctx.GlobalInt(ParallelWorkersFlag.Name)
+	}
+	if ctx.GlobalIsSet(SchemaDryRunFlag.Name) {
+		cfg.SchemaDryRun = ctx.GlobalBool(SchemaDryRunFlag.Name)
+	}
+	if ctx.GlobalIsSet(NoDelegateDialFlag.Name) {
+		cfg.NoDelegateDial = ctx.GlobalBool(NoDelegateDialFlag.Name)
+	}
 	if ctx.GlobalIsSet(CacheNoPrefetchFlag.Name) {
 		cfg.NoPrefetch = ctx.GlobalBool(CacheNoPrefetchFlag.Name)
 	}
@@ -1375,8 +1624,23 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(RPCGlobalGasCap.Name) {
 		cfg.RPCGasCap = new(big.Int).SetUint64(ctx.GlobalUint64(RPCGlobalGasCap.Name))
 	}
+	if ctx.GlobalIsSet(RPCGlobalEVMTimeout.Name) {
+		cfg.RPCEVMTimeout = ctx.GlobalDuration(RPCGlobalEVMTimeout.Name)
+	}
+	if ctx.GlobalIsSet(RPCGlobalDBRowsCap.Name) {
+		cfg.RPCDBRowsCap = ctx.GlobalUint64(RPCGlobalDBRowsCap.Name)
+	}
+	if ctx.GlobalIsSet(RPCGlobalLogsMaxBlocks.Name) {
+		cfg.RPCLogsMaxBlocks = ctx.GlobalUint64(RPCGlobalLogsMaxBlocks.Name)
+	}
 	if ctx.GlobalIsSet(EbakusdbMaxActiveIteratorsFlag.Name) {
-		cfg.EbakusdbMaxActiveIterators = ctx.GlobalUint64(EbakusdbMaxActiveIteratorsFlag.Name)
+		log.Warn("The flag --maxactiveiterators is deprecated and will be removed in the future, idle RPC iterators are released instead")
+	}
+	if ctx.GlobalIsSet(EbakusdbQueryCacheFlag.Name) {
+		cfg.EbakusdbQueryCache = ctx.GlobalInt(EbakusdbQueryCacheFlag.Name)
+	}
+	if ctx.GlobalIsSet(EbakusdbMaxIteratorLagFlag.Name) {
+		cfg.EbakusdbMaxIteratorLag = ctx.GlobalUint64(EbakusdbMaxIteratorLagFlag.Name)
 	}
 
 	// Override any default configs for hard coded networks.
@@ -1512,6 +1776,27 @@ func RegisterGraphQLService(stack *node.Node, endpoint string, cors, vhosts []st
 	}
 }
 
+// RegisterStreamService is a utility function to construct a new chain
+// streaming service.
+func RegisterStreamService(stack *node.Node, endpoint string) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		// Try to construct the streaming service backed by a full node
+		var ethServ *eth.Ebakus
+		if err := ctx.Service(&ethServ); err == nil {
+			return stream.New(ethServ.APIBackend, endpoint)
+		}
+		// Try to construct the streaming service backed by a light node
+		var lesServ *les.LightEbakus
+		if err := ctx.Service(&lesServ); err == nil {
+			return stream.New(lesServ.ApiBackend, endpoint)
+		}
+		// Well, this should not have happened, bail out
+		return nil, errors.New("no Ebakus service")
+	}); err != nil {
+		Fatalf("Failed to register the chain streaming service: %v", err)
+	}
+}
+
 func SetupMetrics(ctx *cli.Context) {
 	if metrics.Enabled {
 		log.Info("Enabling metrics collection")
@@ -1567,7 +1852,7 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node) ethdb.Database {
 	return chainDb
 }
 
-func MakeEbakusDatabase(ctx *cli.Context, stack *node.Node) *ebakusdb.DB {
+func MakeEbakusDatabase(ctx *cli.Context, stack *node.Node) *ebkdb.DB {
 	db, err := stack.OpenEbakusDatabase("state.db")
 	if err != nil {
 		return nil
@@ -1613,6 +1898,9 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 		TrieDirtyLimit:      eth.DefaultConfig.TrieDirtyCache,
 		TrieDirtyDisabled:   ctx.GlobalString(GCModeFlag.Name) == "archive",
 		TrieTimeLimit:       eth.DefaultConfig.TrieTimeout,
+
+		SnapshotRetention:  ctx.GlobalUint64(SnapshotRetentionFlag.Name),
+		SnapshotCheckpoint: ctx.GlobalUint64(SnapshotCheckpointFlag.Name),
 	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheTrieFlag.Name) {
 		cache.TrieCleanLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheTrieFlag.Name) / 100
@@ -0,0 +1,143 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of ebakus/go-ebakus.
+//
+// ebakus/go-ebakus is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// ebakus/go-ebakus is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with ebakus/go-ebakus. If not, see <http://www.gnu.org/licenses/>.
+
+// workcalc calculates the work nonce of unsigned transactions offline.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/workcalc"
+)
+
+var (
+	txMode     = flag.String("tx", "", "calculate the work nonce of the given transaction JSON")
+	difficulty = flag.Float64("difficulty", types.MinimumTargetDifficulty, "target difficulty per gas")
+	threads    = flag.Int("threads", 0, "number of threads to use (0 = one per CPU)")
+	timeout    = flag.Duration("timeout", 0, "give up after the given duration, keeping the best work nonce found (0 = never)")
+)
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[-difficulty <target>] [-threads <n>] [-timeout <duration>] [-tx <json>] [filename]")
+		flag.PrintDefaults()
+		fmt.Fprintln(os.Stderr, `
+Calculates the work nonce of the unsigned transaction, or array of transactions,
+read as JSON from the given file. If the filename is omitted, the transaction is
+read from stdin. The transaction fields are the ones of eth_sendTransaction, with
+"nonce" and "gas" required.
+
+The transactions are written to stdout as JSON, with their "workNonce" set and
+the "difficulty" per gas achieved.`)
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	var r io.Reader
+	switch {
+	case *txMode != "":
+		r = bytes.NewReader([]byte(*txMode))
+
+	case flag.NArg() == 0:
+		r = os.Stdin
+
+	case flag.NArg() == 1:
+		fd, err := os.Open(flag.Arg(0))
+		if err != nil {
+			die(err)
+		}
+		defer fd.Close()
+		r = fd
+
+	default:
+		fmt.Fprintln(os.Stderr, "Error: too many arguments")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	input, err := ioutil.ReadAll(r)
+	if err != nil {
+		die(err)
+	}
+	input = bytes.TrimSpace(input)
+
+	// Accept both a single transaction and a batch of them
+	batch := len(input) > 0 && input[0] == '['
+
+	var txs []*workcalc.Transaction
+	if batch {
+		err = json.Unmarshal(input, &txs)
+	} else {
+		txs = make([]*workcalc.Transaction, 1)
+		err = json.Unmarshal(input, &txs[0])
+	}
+	if err != nil {
+		die(fmt.Errorf("invalid transaction JSON: %v", err))
+	}
+
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	var (
+		results = make([]*workcalc.Result, len(txs))
+		unmet   bool
+	)
+	for i, tx := range txs {
+		if tx == nil {
+			die(fmt.Errorf("transaction %d: missing", i))
+		}
+		if results[i], err = workcalc.Calculate(ctx, tx, *difficulty, *threads); err != nil && results[i] == nil {
+			die(fmt.Errorf("transaction %d: %v", i, err))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: transaction %d: target difficulty not met: %v\n", i, err)
+			unmet = true
+		}
+	}
+
+	var output []byte
+	if batch {
+		output, err = json.MarshalIndent(results, "", "  ")
+	} else {
+		output, err = json.MarshalIndent(results[0], "", "  ")
+	}
+	if err != nil {
+		die(err)
+	}
+	fmt.Println(string(output))
+
+	if unmet {
+		os.Exit(1)
+	}
+}
+
+func die(args ...interface{}) {
+	fmt.Fprintln(os.Stderr, args...)
+	os.Exit(1)
+}
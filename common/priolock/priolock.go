@@ -0,0 +1,87 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package priolock implements a mutual exclusion lock with two priority
+// classes, letting the block import critical path overtake RPC reads.
+package priolock
+
+import (
+	"sync"
+	"time"
+)
+
+// Mutex is a mutual exclusion lock where high priority lockers acquire the lock
+// before any waiting low priority one. Low priority lockers yield as long as a
+// high priority one is waiting.
+//
+// The zero value is an unlocked mutex. A Mutex must not be copied after first use.
+type Mutex struct {
+	mu          sync.Mutex
+	cond        *sync.Cond
+	locked      bool
+	highWaiting int
+}
+
+func (m *Mutex) init() {
+	if m.cond == nil {
+		m.cond = sync.NewCond(&m.mu)
+	}
+}
+
+// Lock acquires the lock with high priority, returning the time spent waiting
+// for it.
+func (m *Mutex) Lock() time.Duration {
+	start := time.Now()
+
+	m.mu.Lock()
+	m.init()
+
+	m.highWaiting++
+	for m.locked {
+		m.cond.Wait()
+	}
+	m.highWaiting--
+	m.locked = true
+
+	m.mu.Unlock()
+
+	return time.Since(start)
+}
+
+// LockLow acquires the lock with low priority.
+func (m *Mutex) LockLow() {
+	m.mu.Lock()
+	m.init()
+
+	for m.locked || m.highWaiting > 0 {
+		m.cond.Wait()
+	}
+	m.locked = true
+
+	m.mu.Unlock()
+}
+
+// Unlock releases the lock, irrespective of the priority it was acquired with.
+func (m *Mutex) Unlock() {
+	m.mu.Lock()
+	if !m.locked {
+		m.mu.Unlock()
+		panic("priolock: unlock of unlocked mutex")
+	}
+	m.locked = false
+	m.cond.Broadcast()
+	m.mu.Unlock()
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package priolock
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// Tests that a waiting high priority locker acquires the lock before low
+// priority lockers that were already waiting.
+func TestHighPriorityFirst(t *testing.T) {
+	var (
+		m     Mutex
+		order []string
+		lock  sync.Mutex
+		wg    sync.WaitGroup
+	)
+	record := func(name string) {
+		lock.Lock()
+		order = append(order, name)
+		lock.Unlock()
+	}
+
+	m.LockLow()
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		m.LockLow()
+		record("low")
+		m.Unlock()
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	go func() {
+		defer wg.Done()
+		if waited := m.Lock(); waited <= 0 {
+			t.Errorf("wait time not reported")
+		}
+		record("high")
+		m.Unlock()
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	m.Unlock()
+	wg.Wait()
+
+	if len(order) != 2 || order[0] != "high" || order[1] != "low" {
+		t.Fatalf("lock order mismatch: have %v, want [high low]", order)
+	}
+}
+
+func TestUnlockOfUnlocked(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("unlock of unlocked mutex didn't panic")
+		}
+	}()
+	var m Mutex
+	m.Unlock()
+}
@@ -21,11 +21,11 @@ import (
 	"math/big"
 
 	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/state"
 	"github.com/ebakus/go-ebakus/core/types"
 	"github.com/ebakus/go-ebakus/params"
 	"github.com/ebakus/go-ebakus/rpc"
-	"github.com/ebakus/ebakusdb"
 )
 
 // ChainReader defines a small collection of methods needed to access the local
@@ -56,7 +56,7 @@ type ChainReader interface {
 	StateAt(hash common.Hash) (*state.StateDB, error)
 
 	// EbakusStateAt retrieves the ebakus state with a given block
-	EbakusStateAt(hash common.Hash, number uint64) (*ebakusdb.Snapshot, error)
+	EbakusStateAt(hash common.Hash, number uint64) (ebkdb.State, error)
 }
 
 // Engine is an algorithm agnostic consensus engine.
@@ -93,15 +93,16 @@ type Engine interface {
 	// but does not assemble the block.
 	//
 	// Note: The block header and state database might be updated to reflect any
-	// consensus rules that happen at finalization (e.g. block rewards).
-	Finalize(chain ChainReader, header *types.Header, state *state.StateDB, ebakusState *ebakusdb.Snapshot, coinbase common.Address, txs []*types.Transaction)
+	// consensus rules that happen at finalization (e.g. block rewards). An error
+	// means the block can't be finalized and is invalid.
+	Finalize(chain ChainReader, header *types.Header, state *state.StateDB, ebakusState ebkdb.State, coinbase common.Address, txs []*types.Transaction) error
 
 	// FinalizeAndAssemble runs any post-transaction state modifications (e.g. block
 	// rewards) and assembles the final block.
 	//
 	// Note: The block header and state database might be updated to reflect any
 	// consensus rules that happen at finalization (e.g. block rewards).
-	FinalizeAndAssemble(chain ChainReader, header *types.Header, state *state.StateDB, ebakusState *ebakusdb.Snapshot, coinbase common.Address, txs []*types.Transaction, receipts []*types.Receipt) (*types.Block, error)
+	FinalizeAndAssemble(chain ChainReader, header *types.Header, state *state.StateDB, ebakusState ebkdb.State, coinbase common.Address, txs []*types.Transaction, receipts []*types.Receipt) (*types.Block, error)
 
 	// Seal generates a new sealing request for the given input block and pushes
 	// the result into the given channel.
@@ -22,6 +22,7 @@ import (
 
 	"github.com/ebakus/go-ebakus/common"
 	"github.com/ebakus/go-ebakus/consensus"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/rawdb"
 	"github.com/ebakus/go-ebakus/core/types"
 	"github.com/ebakus/go-ebakus/core/vm"
@@ -35,13 +36,16 @@ type API struct {
 	dpos  *DPOS
 }
 
-func (api *API) rpcOutputWitnesses(wits *vm.WitnessArray) []interface{} {
+// RPCMarshalWitnesses converts the given witnesses to their RPC output, along
+// with the enode URLs they published in ebakusState.
+func RPCMarshalWitnesses(ebakusState ebkdb.State, wits *vm.WitnessArray) []interface{} {
 	dels := make([]interface{}, len(*wits))
 
 	for i, wit := range *wits {
 		d := map[string]interface{}{
 			"address": wit.Id,
 			"stake":   wit.Stake,
+			"enode":   witnessEnode(ebakusState, wit.Id),
 		}
 		dels[i] = d
 	}
@@ -49,43 +53,43 @@ func (api *API) rpcOutputWitnesses(wits *vm.WitnessArray) []interface{} {
 	return dels
 }
 
+// witnessEnode returns the enode URL published by the witness, or nil if it
+// has not published one.
+func witnessEnode(ebakusState ebkdb.State, address common.Address) interface{} {
+	node, err := vm.GetWitnessEnode(ebakusState, address)
+	if err != nil || node == nil {
+		return nil
+	}
+	return node.URLv4()
+}
+
 // GetDelegates retrieves the list of delegates at the specified block.
 func (api *API) GetDelegates(ctx context.Context, number rpc.BlockNumber) ([]interface{}, error) {
-	var header *types.Header
-	if number == rpc.LatestBlockNumber {
-		header = api.chain.CurrentHeader()
-	} else {
-		header = api.chain.GetHeaderByNumber(uint64(number))
-	}
+	header := api.headerByNumber(number)
 
 	if header == nil {
 		return nil, consensus.ErrFutureBlock
 	}
 
 	ebakusSnapshotID := rawdb.ReadSnapshot(api.dpos.db, header.Hash(), header.Number.Uint64())
-	ebakusState := api.dpos.ebakusDb.Snapshot(*ebakusSnapshotID)
+	ebakusState := ebkdb.NewState(api.dpos.ebakusDb.Snapshot(*ebakusSnapshotID))
 	defer ebakusState.Release()
 
 	delegates := GetDelegates(header, ebakusState, api.dpos.config.DelegateCount, api.dpos.config.BonusDelegateCount, api.dpos.config.TurnBlockCount)
 
-	return api.rpcOutputWitnesses(&delegates), nil
+	return RPCMarshalWitnesses(ebakusState, &delegates), nil
 }
 
 // GetDelegate get delegate.
 func (api *API) GetDelegate(ctx context.Context, address common.Address, number rpc.BlockNumber) (map[string]interface{}, error) {
-	var header *types.Header
-	if number == rpc.LatestBlockNumber {
-		header = api.chain.CurrentHeader()
-	} else {
-		header = api.chain.GetHeaderByNumber(uint64(number))
-	}
+	header := api.headerByNumber(number)
 
 	if header == nil {
 		return nil, consensus.ErrFutureBlock
 	}
 
 	ebakusSnapshotID := rawdb.ReadSnapshot(api.dpos.db, header.Hash(), header.Number.Uint64())
-	ebakusState := api.dpos.ebakusDb.Snapshot(*ebakusSnapshotID)
+	ebakusState := ebkdb.NewState(api.dpos.ebakusDb.Snapshot(*ebakusSnapshotID))
 	defer ebakusState.Release()
 
 	var witness vm.Witness
@@ -109,11 +113,166 @@ func (api *API) GetDelegate(ctx context.Context, address common.Address, number
 		"address": witness.Id,
 		"stake":   witness.Stake,
 		"elected": (witness.Flags & vm.ElectEnabledFlag) == 1,
+		"enode":   witnessEnode(ebakusState, witness.Id),
 	}
 
 	return out, nil
 }
 
 func (api *API) GetBlockDensity(ctx context.Context, number rpc.BlockNumber, lookbackTime uint64) (map[string]interface{}, error) {
+	if number == rpc.SafeBlockNumber || number == rpc.FinalizedBlockNumber {
+		header := api.headerByNumber(number)
+		if header == nil {
+			return nil, consensus.ErrFutureBlock
+		}
+		number = rpc.BlockNumber(header.Number.Uint64())
+	}
 	return api.dpos.getBlockDensity(api.chain, number, lookbackTime)
 }
+
+// GetWitnessPerformance retrieves the block production record of a witness at
+// the specified block.
+func (api *API) GetWitnessPerformance(ctx context.Context, address common.Address, number rpc.BlockNumber) (map[string]interface{}, error) {
+	header := api.headerByNumber(number)
+
+	if header == nil {
+		return nil, consensus.ErrFutureBlock
+	}
+
+	ebakusSnapshotID := rawdb.ReadSnapshot(api.dpos.db, header.Hash(), header.Number.Uint64())
+	if ebakusSnapshotID == nil {
+		return nil, fmt.Errorf("Ebakusdb snapshot not found")
+	}
+	ebakusState := ebkdb.NewState(api.dpos.ebakusDb.Snapshot(*ebakusSnapshotID))
+	defer ebakusState.Release()
+
+	performance, err := GetWitnessPerformance(ebakusState, address)
+	if err != nil {
+		return nil, fmt.Errorf("Ebakusdb query error")
+	}
+	if performance == nil {
+		performance = &WitnessPerformance{Id: address}
+	}
+
+	out := map[string]interface{}{
+		"address":     performance.Id,
+		"produced":    performance.Produced,
+		"missed":      performance.Missed,
+		"missedInRow": performance.MissedInRow,
+	}
+
+	return out, nil
+}
+
+// GetLatestIrreversibleBlock retrieves the latest block over two thirds of the
+// delegates have built on, which the chain won't reorganise below.
+func (api *API) GetLatestIrreversibleBlock(ctx context.Context) (map[string]interface{}, error) {
+	head := api.chain.CurrentHeader()
+
+	header := LastIrreversibleHeader(api.chain, head)
+	if header == nil {
+		return nil, fmt.Errorf("Irreversible block not found")
+	}
+
+	out := map[string]interface{}{
+		"number":    header.Number.Uint64(),
+		"hash":      header.Hash(),
+		"timestamp": header.Time,
+		"depth":     head.Number.Uint64() - header.Number.Uint64(),
+	}
+
+	return out, nil
+}
+
+// headerByNumber resolves the header of the specified block, including the
+// "latest", "safe" and "finalized" tags.
+func (api *API) headerByNumber(number rpc.BlockNumber) *types.Header {
+	switch number {
+	case rpc.LatestBlockNumber:
+		return api.chain.CurrentHeader()
+	case rpc.SafeBlockNumber, rpc.FinalizedBlockNumber:
+		return LastIrreversibleHeader(api.chain, api.chain.CurrentHeader())
+	default:
+		return api.chain.GetHeaderByNumber(uint64(number))
+	}
+}
+
+// ebakusState opens the ebakusdb snapshot of the specified block.
+func (api *API) ebakusState(number rpc.BlockNumber) (ebkdb.State, error) {
+	header := api.headerByNumber(number)
+
+	if header == nil {
+		return nil, consensus.ErrFutureBlock
+	}
+
+	ebakusSnapshotID := rawdb.ReadSnapshot(api.dpos.db, header.Hash(), header.Number.Uint64())
+	if ebakusSnapshotID == nil {
+		return nil, fmt.Errorf("Ebakusdb snapshot not found")
+	}
+	return ebkdb.NewState(api.dpos.ebakusDb.Snapshot(*ebakusSnapshotID)), nil
+}
+
+// stakeOf returns the amount staked by the account, zero if none.
+func stakeOf(ebakusState ebkdb.State, address common.Address) (uint64, error) {
+	staked, err := vm.GetStaked(ebakusState, address)
+	if err != nil || staked == nil {
+		return 0, err
+	}
+	return staked.Amount, nil
+}
+
+// GetVoters retrieves the accounts delegating to a witness at the specified
+// block, along with their staked amounts.
+func (api *API) GetVoters(ctx context.Context, address common.Address, number rpc.BlockNumber) ([]interface{}, error) {
+	ebakusState, err := api.ebakusState(number)
+	if err != nil {
+		return nil, err
+	}
+	defer ebakusState.Release()
+
+	voters, err := vm.GetWitnessVoters(ebakusState, address)
+	if err != nil {
+		return nil, fmt.Errorf("Ebakusdb query error")
+	}
+	out := make([]interface{}, len(voters))
+	for i, voter := range voters {
+		stake, err := stakeOf(ebakusState, voter)
+		if err != nil {
+			return nil, fmt.Errorf("Ebakusdb query error")
+		}
+		out[i] = map[string]interface{}{
+			"address": voter,
+			"stake":   stake,
+		}
+	}
+	return out, nil
+}
+
+// GetDelegations retrieves the witnesses an account votes for at the specified
+// block, along with the amount it has staked.
+func (api *API) GetDelegations(ctx context.Context, address common.Address, number rpc.BlockNumber) (map[string]interface{}, error) {
+	ebakusState, err := api.ebakusState(number)
+	if err != nil {
+		return nil, err
+	}
+	defer ebakusState.Release()
+
+	witnesses, err := vm.GetDelegations(ebakusState, address)
+	if err != nil {
+		return nil, fmt.Errorf("Ebakusdb query error")
+	}
+	stake, err := stakeOf(ebakusState, address)
+	if err != nil {
+		return nil, fmt.Errorf("Ebakusdb query error")
+	}
+	if witnesses == nil {
+		witnesses = []common.Address{}
+	}
+	out := map[string]interface{}{
+		"address":   address,
+		"stake":     stake,
+		"witnesses": witnesses,
+	}
+
+	return out, nil
+}
@@ -27,11 +27,11 @@ import (
 	"sync"
 	"time"
 
-	"github.com/ebakus/ebakusdb"
-
 	"github.com/ebakus/go-ebakus/accounts"
 	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/common/mclock"
 	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/rawdb"
 	"github.com/ebakus/go-ebakus/core/state"
 	"github.com/ebakus/go-ebakus/core/types"
@@ -102,12 +102,15 @@ type SignerFn func(accounts.Account, string, []byte) ([]byte, error)
 type DPOS struct {
 	config     *params.DPOSConfig
 	db         ethdb.Database
-	ebakusDb   *ebakusdb.DB
+	ebakusDb   *ebkdb.DB
 	blockchain *core.BlockChain
 	genesis    *core.Genesis
 
 	signatures *lru.ARCCache // Signatures of recent blocks to speed up address recover
 
+	clock mclock.Clock // Clock driving the slot timing, nil for the wall clock
+	epoch time.Time    // Wall time of the clock's zero, used to derive block timestamps
+
 	signer common.Address // Ebakus address of the signing key
 	signFn SignerFn       // Signer function to authorize hashes with
 	lock   sync.RWMutex
@@ -138,8 +141,17 @@ func ecrecover(header *types.Header, sigcache *lru.ARCCache) (common.Address, er
 	return signer, nil
 }
 
+// producerSignatures caches the producers recovered by Producer.
+var producerSignatures, _ = lru.NewARC(signatureCacheSize)
+
+// Producer returns the address of the witness which produced and signed the
+// header, for consumers without access to the consensus engine.
+func Producer(header *types.Header) (common.Address, error) {
+	return ecrecover(header, producerSignatures)
+}
+
 // New creates a Delegated Proof of Stake consensus engine
-func New(config *params.DPOSConfig, db ethdb.Database, ebakusDb *ebakusdb.DB, genesis *core.Genesis) *DPOS {
+func New(config *params.DPOSConfig, db ethdb.Database, ebakusDb *ebkdb.DB, genesis *core.Genesis) *DPOS {
 	conf := *config
 
 	if conf.Period == 0 {
@@ -171,6 +183,30 @@ func (d *DPOS) SetBlockchain(bc *core.BlockChain) {
 	d.blockchain = bc
 }
 
+// SetClock replaces the wall clock used for slot timing with the given one,
+// its zero time corresponding to epoch. Combined with mclock.Simulated it allows
+// driving block production deterministically in tests.
+func (d *DPOS) SetClock(clock mclock.Clock, epoch time.Time) {
+	d.clock = clock
+	d.epoch = epoch
+}
+
+// now returns the current time as seen by the engine's clock.
+func (d *DPOS) now() time.Time {
+	if d.clock == nil {
+		return time.Now()
+	}
+	return d.epoch.Add(time.Duration(d.clock.Now()))
+}
+
+// after waits for the duration to elapse on the engine's clock.
+func (d *DPOS) after(timeout time.Duration) <-chan time.Time {
+	if d.clock == nil {
+		return time.After(timeout)
+	}
+	return d.clock.After(timeout)
+}
+
 // Author implements consensus.Engine, returning the Ebakus address recovered
 // from the signature in the header
 func (d *DPOS) Author(header *types.Header) (common.Address, error) {
@@ -198,7 +234,7 @@ func (d *DPOS) verifyHeader(chain consensus.ChainReader, header *types.Header, p
 
 	blockNum := header.Number.Uint64()
 
-	if header.Time > uint64(time.Now().Unix()) {
+	if header.Time > uint64(d.now().Unix()) {
 		return consensus.ErrFutureBlock
 	}
 
@@ -270,7 +306,7 @@ func (d *DPOS) verifySeal(chain consensus.ChainReader, header *types.Header, par
 
 	parentHeader := d.blockchain.GetHeaderByHash(header.ParentHash)
 
-	signer := d.getSignerAtSlot(chain, parentHeader, ebakusState, slot)
+	producers := d.getProducersAtSlot(chain, parentHeader, ebakusState, slot)
 	ebakusState.Release()
 
 	blockSigner, err := ecrecover(header, d.signatures)
@@ -278,9 +314,15 @@ func (d *DPOS) verifySeal(chain consensus.ChainReader, header *types.Header, par
 		return err
 	}
 
-	if blockSigner != signer {
+	rank := producerRank(producers, blockSigner)
+	if rank < 0 {
 		return errUnauthorized
 	}
+	// Standby blocks arriving before the standby's turn are postponed, to give
+	// the delegates ranked before it their chance
+	if rank > 0 && d.now().Before(d.slotStart(header.Time).Add(standbyDelay(d.config, rank))) {
+		return consensus.ErrFutureBlock
+	}
 
 	return nil
 }
@@ -302,7 +344,7 @@ func (d *DPOS) Prepare(chain consensus.ChainReader, stop <-chan struct{}) (*type
 		head := chain.CurrentBlock()
 		headSlot := float64(head.Time()) / float64(d.config.Period)
 
-		now := unixNow()
+		now := uint64(d.now().Unix())
 		slot := float64(now) / float64(d.config.Period)
 
 		headHash := head.Hash()
@@ -312,13 +354,26 @@ func (d *DPOS) Prepare(chain consensus.ChainReader, stop <-chan struct{}) (*type
 			return nil, nil, fmt.Errorf("Prepare new block failed to get ebakus state at block number %d: %s", headBlockNumber, err)
 		}
 
-		inTurnSigner := d.getSignerAtSlot(chain, head.Header(), ebakusState, slot)
+		producers := d.getProducersAtSlot(chain, head.Header(), ebakusState, slot)
 		ebakusState.Release()
 
-		log.Trace("Check turn", "slot", slot, "signer", signer, "turn for", inTurnSigner)
-
-		if slot > headSlot && signer == inTurnSigner {
+		rank := producerRank(producers, signer)
+		log.Trace("Check turn", "slot", slot, "signer", signer, "turn for", producers, "rank", rank)
+
+		// Standbys wait for the delegates ranked before them to miss the slot
+		var (
+			turn        = rank == 0
+			standbyWait time.Duration
+		)
+		if rank > 0 {
+			standbyWait = d.slotStart(now).Add(standbyDelay(d.config, rank)).Sub(d.now())
+			turn = standbyWait <= 0 && head.Time()+d.config.Period <= now
+		}
+		if slot > headSlot && turn {
 			// We are the chosen one. Break.
+			if rank > 0 {
+				log.Debug("Producing in place of the delegate in turn", "slot", slot, "delegate", producers[0], "rank", rank)
+			}
 			num := head.Number()
 
 			header := &types.Header{
@@ -342,7 +397,11 @@ func (d *DPOS) Prepare(chain consensus.ChainReader, stop <-chan struct{}) (*type
 
 		nextSlotTime := time.Unix(int64((slot+1)*float64(d.config.Period)), 0)
 
-		timeToNextSlot := nextSlotTime.Sub(time.Now())
+		timeToNextSlot := nextSlotTime.Sub(d.now())
+		if slot > headSlot && standbyWait > 0 && standbyWait < timeToNextSlot {
+			// Check whether the slot was missed once our standby turn comes
+			timeToNextSlot = standbyWait
+		}
 
 		log.Trace("Sleeping", "time", timeToNextSlot)
 
@@ -350,7 +409,7 @@ func (d *DPOS) Prepare(chain consensus.ChainReader, stop <-chan struct{}) (*type
 		case <-stop:
 			log.Info("Woke to abort")
 			return nil, nil, ErrProductionAborted
-		case <-time.After(timeToNextSlot):
+		case <-d.after(timeToNextSlot):
 		}
 	}
 }
@@ -359,43 +418,81 @@ func (d *DPOS) Prepare(chain consensus.ChainReader, stop <-chan struct{}) (*type
 // and assembles the final block.
 // Note: The block header and state database might be updated to reflect any
 // consensus rules that happen at finalization (e.g. block rewards).
-func (d *DPOS) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, ebakusState *ebakusdb.Snapshot, coinbase common.Address, txs []*types.Transaction) {
+func (d *DPOS) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, ebakusState ebkdb.State, coinbase common.Address, txs []*types.Transaction) error {
 	// Accumulate any block and uncle rewards and commit the final state root
-	d.AccumulateRewards(chain.Config().DPOS, state, header, coinbase)
+	if err := vm.ApplySchemaMigrations(chain.Config(), header.Number, ebakusState); err != nil {
+		return err
+	}
+	if err := d.AccumulateRewards(chain.Config(), state, ebakusState, header, coinbase); err != nil {
+		return err
+	}
 	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+
+	if err := d.trackPerformance(chain, header, ebakusState, coinbase); err != nil {
+		return err
+	}
+	if chain.Config().IsBridge(header.Number) {
+		if err := vm.CommitBridgeIntents(ebakusState, header.Number.Uint64()); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // FinalizeAndAssemble implements consensus.Engine, accumulating the block and
 // setting the final state and assembling the block.
-func (d *DPOS) FinalizeAndAssemble(chain consensus.ChainReader, header *types.Header, state *state.StateDB, ebakusState *ebakusdb.Snapshot, coinbase common.Address, txs []*types.Transaction,
+func (d *DPOS) FinalizeAndAssemble(chain consensus.ChainReader, header *types.Header, state *state.StateDB, ebakusState ebkdb.State, coinbase common.Address, txs []*types.Transaction,
 	receipts []*types.Receipt) (*types.Block, error) {
 
 	// For internal storage chains, refuse to seal empty blocks (no reward but would spin sealing)
 	if d.genesis.SuspendEmptyBlocks && len(txs) == 0 {
-		now := unixNow()
+		now := uint64(d.now().Unix())
 		slot := float64(now) / float64(d.config.Period)
 		nextSlotTime := time.Unix(int64((slot+1)*float64(d.config.Period)), 0)
 
-		timeToNextSlot := nextSlotTime.Sub(time.Now())
+		timeToNextSlot := nextSlotTime.Sub(d.now())
 
 		select {
-		case <-time.After(timeToNextSlot):
+		case <-d.after(timeToNextSlot):
 		}
 
 		return nil, ErrWaitForTransactions
 	}
 
 	// Accumulate any block and uncle rewards and commit the final state root
-	d.AccumulateRewards(chain.Config().DPOS, state, header, coinbase)
+	if err := vm.ApplySchemaMigrations(chain.Config(), header.Number, ebakusState); err != nil {
+		return nil, err
+	}
+	if err := d.AccumulateRewards(chain.Config(), state, ebakusState, header, coinbase); err != nil {
+		return nil, err
+	}
 	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
 
+	if err := d.trackPerformance(chain, header, ebakusState, coinbase); err != nil {
+		return nil, err
+	}
+	if chain.Config().IsBridge(header.Number) {
+		if err := vm.CommitBridgeIntents(ebakusState, header.Number.Uint64()); err != nil {
+			return nil, err
+		}
+	}
+
+	// Refuse to seal on top of a corrupted stake accounting, checked once per
+	// checkpoint as it iterates all the stakes
+	if header.Number.Uint64()%checkpointInterval == 0 {
+		if err := vm.CheckSystemStake(ebakusState); err != nil {
+			log.Error("System stake invariant violated, refusing to seal", "number", header.Number, "err", err)
+			return nil, err
+		}
+	}
+
 	// Calculate delegate changes
 	oldBlockNumber := header.Number.Uint64() - 1
 	oldEbakusSnapshotId := rawdb.ReadSnapshot(d.db, header.ParentHash, oldBlockNumber)
 	if oldEbakusSnapshotId == nil {
 		return nil, errUnknownBlock
 	}
-	oldEbakusState := d.ebakusDb.Snapshot(*oldEbakusSnapshotId)
+	oldEbakusState := ebkdb.NewState(d.ebakusDb.Snapshot(*oldEbakusSnapshotId))
 	defer oldEbakusState.Release()
 
 	delegateCount := d.config.DelegateCount
@@ -476,11 +573,26 @@ func (d *DPOS) SealHash(header *types.Header) (hash common.Hash) {
 	return hash
 }
 
-// AccumulateRewards credits the coinbase of the given block with the reward
-func (d *DPOS) AccumulateRewards(config *params.DPOSConfig, state *state.StateDB, header *types.Header, coinbase common.Address) {
+// AccumulateRewards credits the coinbase of the given block with the reward.
+// After the reward share fork, the configured percentage of the reward is
+// instead distributed to the accounts voting for the coinbase, pro-rata to
+// their stake.
+func (d *DPOS) AccumulateRewards(config *params.ChainConfig, state *state.StateDB, ebakusState ebkdb.State, header *types.Header, coinbase common.Address) error {
 	reward := big.NewInt(3171 * 1e14)
 
+	if config.IsRewardShare(header.Number) && config.DPOS != nil && config.DPOS.RewardShare > 0 && ebakusState != nil {
+		// The voters are looked up by witness from the fork on, index them once
+		if err := vm.CreateWitnessVotersIndex(ebakusState); err != nil {
+			return err
+		}
+		shared, err := shareReward(config.DPOS, state, ebakusState, coinbase, reward)
+		if err != nil {
+			return err
+		}
+		reward.Sub(reward, shared)
+	}
 	state.AddBalance(coinbase, reward)
+	return nil
 }
 
 // CalcDifficulty is essentialy dummy in ebakus
@@ -498,26 +610,13 @@ func (d *DPOS) APIs(chain consensus.ChainReader) []rpc.API {
 	}}
 }
 
-func unixNow() uint64 {
-	return uint64(time.Now().Unix())
-}
-
-func (d *DPOS) getSignerAtSlot(chain consensus.ChainReader, header *types.Header, state *ebakusdb.Snapshot, slot float64) common.Address {
-	delegates := GetDelegates(header, state, d.config.DelegateCount, d.config.BonusDelegateCount, d.config.TurnBlockCount)
-
+func (d *DPOS) getSignerAtSlot(chain consensus.ChainReader, header *types.Header, state ebkdb.State, slot float64) common.Address {
 	if d.config.TurnBlockCount == 0 {
 		log.Warn("DPOS.TurnBlockCount is zero. This means that mining won't match a signer.")
 	}
 
-	if d.config.DelegateCount == 0 || d.config.TurnBlockCount == 0 {
-		return common.Address{}
-	}
-
-	slot = slot / float64(d.config.TurnBlockCount)
-	s := int(slot) % int(d.config.DelegateCount)
-
-	if s < len(delegates) {
-		return delegates[s].Id
+	if producers := d.getProducersAtSlot(chain, header, state, slot); len(producers) > 0 {
+		return producers[0]
 	}
 
 	return common.Address{}
@@ -605,7 +704,7 @@ func uniformRandom(max uint64, hash common.Hash) uint64 {
 	return rand
 }
 
-func GetDelegates(header *types.Header, snap *ebakusdb.Snapshot, maxWitnesses uint64, maxBonusWitnesses uint64, turnBlockCount uint64) vm.WitnessArray {
+func GetDelegates(header *types.Header, snap ebkdb.State, maxWitnesses uint64, maxBonusWitnesses uint64, turnBlockCount uint64) vm.WitnessArray {
 	if maxWitnesses == 0 {
 		log.Warn("DPOS.getDelegates maxWitnesses is zero. This means that mining won't match a signer. Check if DPOS.DelegatesCount is set to zero")
 	}
@@ -617,6 +716,13 @@ func GetDelegates(header *types.Header, snap *ebakusdb.Snapshot, maxWitnesses ui
 
 	delegates := vm.DelegateVotingGetDelegates(snap, maxWitnessesToLoad)
 
+	return SelectDelegates(header, delegates, maxWitnesses, turnBlockCount)
+}
+
+// SelectDelegates picks the delegates of the slot of header out of the elected
+// witnesses, sorted by stake. Candidates beyond the first maxWitnesses-1 compete
+// for the last, bonus, position.
+func SelectDelegates(header *types.Header, delegates vm.WitnessArray, maxWitnesses uint64, turnBlockCount uint64) vm.WitnessArray {
 	// get bonus delegate
 	if uint64(len(delegates)) > maxWitnesses {
 		bonusCandidateDelegates := delegates[maxWitnesses-1:]
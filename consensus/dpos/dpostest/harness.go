@@ -0,0 +1,314 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package dpostest drives networks of DPOS block producers in memory on a
+// simulated clock, for testing the consensus engine.
+package dpostest
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ebakus/go-ebakus/accounts"
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/common/mclock"
+	"github.com/ebakus/go-ebakus/consensus/dpos"
+	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/core/rawdb"
+	"github.com/ebakus/go-ebakus/core/state"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/core/vm"
+	"github.com/ebakus/go-ebakus/crypto"
+	"github.com/ebakus/go-ebakus/ethdb"
+	"github.com/ebakus/go-ebakus/log"
+)
+
+// HarnessNode is a block producing node of a Harness, backed by in-memory
+// databases.
+type HarnessNode struct {
+	Key     *ecdsa.PrivateKey
+	Address common.Address
+	Engine  *dpos.DPOS
+	Chain   *core.BlockChain
+
+	db       ethdb.Database
+	ebakusDb *ebkdb.DB
+
+	txs   []*types.Transaction // Transactions to include when producing blocks
+	txsMu sync.Mutex
+}
+
+// Harness drives a network of DPOS nodes on a simulated clock. Every node runs
+// a producer loop, which produces empty blocks when in turn and delivers them
+// to all the nodes of the network, so thousands of slots can be simulated in
+// seconds and with deterministic results.
+type Harness struct {
+	Clock *mclock.Simulated
+	Nodes []*HarnessNode
+
+	// Connected reports whether blocks produced by a node reach another one,
+	// nil meaning a fully connected network. It must only be changed between
+	// calls to Run.
+	Connected func(from, to *HarnessNode) bool
+
+	genesis *core.Genesis
+	period  time.Duration
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+
+	lock sync.Mutex
+	err  error // First error encountered by a producer loop
+}
+
+// NewHarness creates a node for every given key, all of them sharing the same
+// genesis and simulated clock. The zero time of the clock maps to the genesis
+// timestamp.
+func NewHarness(genesis *core.Genesis, keys []*ecdsa.PrivateKey) (*Harness, error) {
+	if genesis.Config == nil || genesis.Config.DPOS == nil {
+		return nil, fmt.Errorf("harness requires a DPOS genesis")
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("harness requires at least one node")
+	}
+	h := &Harness{
+		Clock:   new(mclock.Simulated),
+		genesis: genesis,
+		quit:    make(chan struct{}),
+	}
+	epoch := time.Unix(int64(genesis.Timestamp), 0)
+
+	for _, key := range keys {
+		db := rawdb.NewMemoryDatabase()
+		ebakusDb, err := ebkdb.OpenInMemory(nil)
+		if err != nil {
+			h.close()
+			return nil, err
+		}
+		config, _, err := core.SetupGenesisBlock(db, ebakusDb, genesis)
+		if err != nil {
+			ebakusDb.Close()
+			h.close()
+			return nil, err
+		}
+		engine := dpos.New(config.DPOS, db, ebakusDb, genesis)
+		engine.SetClock(h.Clock, epoch)
+
+		chain, err := core.NewBlockChain(db, ebakusDb, nil, config, engine, vm.Config{}, nil)
+		if err != nil {
+			ebakusDb.Close()
+			h.close()
+			return nil, err
+		}
+		engine.SetBlockchain(chain)
+
+		node := &HarnessNode{
+			Key:      key,
+			Address:  crypto.PubkeyToAddress(key.PublicKey),
+			Engine:   engine,
+			Chain:    chain,
+			db:       db,
+			ebakusDb: ebakusDb,
+		}
+		engine.Authorize(node.Address, node.signData)
+
+		h.Nodes = append(h.Nodes, node)
+	}
+	h.period = time.Duration(genesis.Config.DPOS.Period) * time.Second
+
+	return h, nil
+}
+
+// Start launches the producer loops of all the nodes.
+func (h *Harness) Start() {
+	for _, node := range h.Nodes {
+		h.wg.Add(1)
+		go h.produce(node)
+	}
+}
+
+// Run advances the simulated clock by the given number of block periods,
+// waiting for all the nodes to finish their work between slots. It returns the
+// first error any of the nodes encountered.
+func (h *Harness) Run(slots int) error {
+	for i := 0; i < slots; i++ {
+		h.Clock.WaitForTimers(len(h.Nodes))
+		if err := h.Err(); err != nil {
+			return err
+		}
+		h.Clock.Run(h.period)
+	}
+	h.Clock.WaitForTimers(len(h.Nodes))
+
+	return h.Err()
+}
+
+// Err returns the first error encountered by a producer loop.
+func (h *Harness) Err() error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.err
+}
+
+// Stop terminates the producer loops and releases all the node resources.
+func (h *Harness) Stop() {
+	close(h.quit)
+	h.wg.Wait()
+	h.close()
+}
+
+func (h *Harness) close() {
+	for _, node := range h.Nodes {
+		node.Chain.Stop()
+		node.ebakusDb.Close()
+		node.db.Close()
+	}
+}
+
+func (h *Harness) fail(err error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.err == nil {
+		h.err = err
+	}
+}
+
+// produce is the producer loop of a node, mimicking the miner's worker. Errors
+// are recorded and the loop backs off for a slot, keeping one pending timer per
+// node on the clock at all times.
+func (h *Harness) produce(node *HarnessNode) {
+	defer h.wg.Done()
+
+	for {
+		parent, header, err := node.Engine.Prepare(node.Chain, h.quit)
+		if err == dpos.ErrProductionAborted {
+			return
+		}
+		if err == nil {
+			err = h.seal(node, parent, header)
+		}
+		if err != nil && err != dpos.ErrWaitForTransactions {
+			h.fail(fmt.Errorf("node %x: %v", node.Address, err))
+
+			select {
+			case <-h.Clock.After(h.period):
+			case <-h.quit:
+				return
+			}
+		}
+	}
+}
+
+// seal assembles and signs a block on top of parent with the transactions
+// queued at the node, delivering it to every node it is connected to.
+func (h *Harness) seal(node *HarnessNode, parent *types.Block, header *types.Header) error {
+	header.GasLimit = core.CalcGasLimit(parent.Header(), h.genesis.GasLimit, h.genesis.GasLimit)
+
+	state, err := node.Chain.StateAt(parent.Root())
+	if err != nil {
+		return err
+	}
+	ebakusState, err := node.Chain.EbakusStateAt(parent.Hash(), parent.NumberU64())
+	if err != nil {
+		return err
+	}
+	defer ebakusState.Release()
+
+	txs, receipts := h.applyTransactions(node, header, state, ebakusState)
+
+	block, err := node.Engine.FinalizeAndAssemble(node.Chain, header, state, ebakusState, node.Address, txs, receipts)
+	if err != nil {
+		return err
+	}
+	results := make(chan *types.Block, 1)
+	if err := node.Engine.Seal(node.Chain, block, results, nil); err != nil {
+		return err
+	}
+	block = <-results
+
+	log.Trace("Harness produced block", "number", block.Number(), "producer", node.Address)
+
+	for _, peer := range h.Nodes {
+		if peer != node && h.Connected != nil && !h.Connected(node, peer) {
+			continue
+		}
+		if _, err := peer.Chain.InsertChain(types.Blocks{block}); err != nil {
+			return fmt.Errorf("block %d rejected by %x: %v", block.NumberU64(), peer.Address, err)
+		}
+	}
+	return nil
+}
+
+// applyTransactions executes the transactions queued at the node on top of the
+// given state, keeping the ones which can't be included yet for later blocks.
+func (h *Harness) applyTransactions(node *HarnessNode, header *types.Header, state *state.StateDB, ebakusState ebkdb.State) ([]*types.Transaction, []*types.Receipt) {
+	node.txsMu.Lock()
+	defer node.txsMu.Unlock()
+
+	var (
+		txs      []*types.Transaction
+		receipts []*types.Receipt
+		pending  []*types.Transaction
+		gasPool  = new(core.GasPool).AddGas(header.GasLimit)
+		config   = node.Chain.Config()
+	)
+	for _, tx := range node.txs {
+		state.Prepare(tx.Hash(), common.Hash{}, len(txs))
+
+		snap := state.Snapshot()
+		ebakusSnapshot := ebakusState.Snapshot()
+
+		receipt, _, err := core.ApplyTransaction(config, node.Chain, &node.Address, gasPool, state, ebakusSnapshot, header, tx, &header.GasUsed, vm.Config{})
+		switch err {
+		case nil:
+			ebakusState.ResetTo(ebakusSnapshot)
+			txs = append(txs, tx)
+			receipts = append(receipts, receipt)
+
+		case core.ErrNonceTooHigh, core.ErrGasLimitReached:
+			state.RevertToSnapshot(snap)
+			pending = append(pending, tx)
+
+		default:
+			state.RevertToSnapshot(snap)
+			log.Debug("Harness dropped transaction", "hash", tx.Hash(), "err", err)
+		}
+		ebakusSnapshot.Release()
+	}
+	node.txs = pending
+
+	return txs, receipts
+}
+
+// AddTransaction queues a transaction for inclusion in the blocks the node
+// produces. Transactions are kept until included or found invalid.
+func (n *HarnessNode) AddTransaction(tx *types.Transaction) {
+	n.txsMu.Lock()
+	defer n.txsMu.Unlock()
+
+	n.txs = append(n.txs, tx)
+}
+
+// signData implements SignerFn using the node key, the same way a keystore
+// wallet signs the keccak256 of the data.
+func (n *HarnessNode) signData(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+	return crypto.Sign(crypto.Keccak256(data), n.Key)
+}
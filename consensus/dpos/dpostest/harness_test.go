@@ -0,0 +1,58 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpostest
+
+import (
+	"crypto/ecdsa"
+	"testing"
+
+	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/crypto"
+)
+
+// Tests that a network driven by the simulated clock produces exactly one block
+// per slot and that all the nodes agree on the resulting chain.
+func TestHarnessSlots(t *testing.T) {
+	var keys []*ecdsa.PrivateKey
+	for i := 0; i < 3; i++ {
+		key, _ := crypto.GenerateKey()
+		keys = append(keys, key)
+	}
+	genesis := core.DeveloperGenesisBlock(1, crypto.PubkeyToAddress(keys[0].PublicKey))
+
+	h, err := NewHarness(genesis, keys)
+	if err != nil {
+		t.Fatalf("failed to create harness: %v", err)
+	}
+	defer h.Stop()
+
+	h.Start()
+
+	slots := 2000
+	if err := h.Run(slots); err != nil {
+		t.Fatalf("failed to run %d slots: %v", slots, err)
+	}
+	head := h.Nodes[0].Chain.CurrentBlock()
+	if head.NumberU64() != uint64(slots) {
+		t.Errorf("head number mismatch: have %d, want %d", head.NumberU64(), slots)
+	}
+	for i, node := range h.Nodes[1:] {
+		if have := node.Chain.CurrentBlock().Hash(); have != head.Hash() {
+			t.Errorf("node %d: head mismatch: have %x, want %x", i+1, have, head.Hash())
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/params"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// HeaderReader is the part of the full and light chains needed to track the
+// irreversible blocks.
+type HeaderReader interface {
+	// Config retrieves the blockchain's chain configuration.
+	Config() *params.ChainConfig
+
+	// GetHeader retrieves a block header from the database by hash and number.
+	GetHeader(hash common.Hash, number uint64) *types.Header
+
+	// GetHeaderByNumber retrieves a block header from the database by number.
+	GetHeaderByNumber(number uint64) *types.Header
+}
+
+// irreversibleRounds is the number of delegate rounds searched for a quorum of
+// producers, before falling back to the immutability threshold.
+const irreversibleRounds = 2
+
+// irreversibleHeaders caches the last irreversible header of recent heads.
+var irreversibleHeaders, _ = lru.NewARC(64)
+
+// irreversibleQuorum returns the number of distinct delegates which have to
+// produce blocks on top of a block for it to become irreversible.
+func irreversibleQuorum(delegateCount uint64) int {
+	return int(delegateCount*2/3 + 1)
+}
+
+// LastIrreversibleHeader returns the latest header of the chain ending at head
+// which over two thirds of the delegates have produced blocks on top of, so it
+// can't be reverted without them signing a conflicting chain. It returns nil if
+// the chain isn't run by delegates or the headers needed are missing.
+//
+// If no quorum produced on top of the last few rounds of blocks, e.g. while many
+// delegates are offline, the block params.ImmutabilityThreshold deep is returned
+// instead, as the chain refuses reorgs beyond it.
+func LastIrreversibleHeader(chain HeaderReader, head *types.Header) *types.Header {
+	config := chain.Config().DPOS
+	if config == nil || head == nil {
+		return nil
+	}
+	if header, ok := irreversibleHeaders.Get(head.Hash()); ok {
+		return header.(*types.Header)
+	}
+	var (
+		quorum    = irreversibleQuorum(config.DelegateCount)
+		lookback  = irreversibleRounds * config.DelegateCount * config.TurnBlockCount
+		producers = make(map[common.Address]struct{})
+		header    = head
+	)
+	for i := uint64(0); ; i++ {
+		if producer, err := Producer(header); err == nil {
+			producers[producer] = struct{}{}
+		}
+		if len(producers) >= quorum {
+			break
+		}
+		if i >= lookback || header.Number.Uint64() == 0 {
+			header = nil
+			break
+		}
+		if header = chain.GetHeader(header.ParentHash, header.Number.Uint64()-1); header == nil {
+			return nil
+		}
+	}
+	if header == nil {
+		var number uint64
+		if head.Number.Uint64() > params.ImmutabilityThreshold {
+			number = head.Number.Uint64() - params.ImmutabilityThreshold
+		}
+		if header = chain.GetHeaderByNumber(number); header == nil {
+			return nil
+		}
+	}
+	irreversibleHeaders.Add(head.Hash(), header)
+	return header
+}
@@ -0,0 +1,104 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/crypto"
+	"github.com/ebakus/go-ebakus/params"
+)
+
+// testHeaderChain is a chain of headers signed by a rotation of producers.
+type testHeaderChain struct {
+	config  *params.ChainConfig
+	headers []*types.Header
+}
+
+func newTestHeaderChain(t *testing.T, delegateCount uint64, producers []*ecdsa.PrivateKey, length int) *testHeaderChain {
+	chain := &testHeaderChain{
+		config:  &params.ChainConfig{DPOS: &params.DPOSConfig{DelegateCount: delegateCount, TurnBlockCount: 1}},
+		headers: []*types.Header{{Number: big.NewInt(0)}},
+	}
+	for i := 1; i < length; i++ {
+		header := &types.Header{
+			ParentHash: chain.headers[i-1].Hash(),
+			Number:     big.NewInt(int64(i)),
+			Time:       uint64(i),
+		}
+		signature, err := crypto.Sign(sigHash(header).Bytes(), producers[i%len(producers)])
+		if err != nil {
+			t.Fatalf("failed to sign header #%d: %v", i, err)
+		}
+		header.Signature = signature
+		chain.headers = append(chain.headers, header)
+	}
+	return chain
+}
+
+func (c *testHeaderChain) Config() *params.ChainConfig { return c.config }
+
+func (c *testHeaderChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header := c.GetHeaderByNumber(number); header != nil && header.Hash() == hash {
+		return header
+	}
+	return nil
+}
+
+func (c *testHeaderChain) GetHeaderByNumber(number uint64) *types.Header {
+	if number < uint64(len(c.headers)) {
+		return c.headers[number]
+	}
+	return nil
+}
+
+func (c *testHeaderChain) head() *types.Header { return c.headers[len(c.headers)-1] }
+
+// Tests that the last irreversible block is the latest one over two thirds of
+// the delegates have produced on top of.
+func TestLastIrreversibleHeader(t *testing.T) {
+	var keys []*ecdsa.PrivateKey
+	for i := 0; i < 4; i++ {
+		key, _ := crypto.GenerateKey()
+		keys = append(keys, key)
+	}
+	// Three of four delegates are a quorum, reached two blocks below the head
+	chain := newTestHeaderChain(t, 4, keys, 20)
+	if header := LastIrreversibleHeader(chain, chain.head()); header == nil || header.Number.Uint64() != 17 {
+		t.Errorf("irreversible header mismatch: have %v, want #17", header)
+	}
+	// With two of four delegates producing there's no quorum, so only the
+	// genesis is irreversible within the immutability threshold
+	chain = newTestHeaderChain(t, 4, keys[:2], 20)
+	if header := LastIrreversibleHeader(chain, chain.head()); header == nil || header.Number.Uint64() != 0 {
+		t.Errorf("irreversible header mismatch: have %v, want genesis", header)
+	}
+	// A single delegate finalizes its own blocks
+	chain = newTestHeaderChain(t, 1, keys[:1], 5)
+	if header := LastIrreversibleHeader(chain, chain.head()); header != chain.head() {
+		t.Errorf("irreversible header mismatch: have %v, want head", header)
+	}
+	// Chains not run by delegates have no irreversible blocks
+	chain.config = params.TestChainConfig
+	if header := LastIrreversibleHeader(chain, chain.head()); header != nil {
+		t.Errorf("irreversible header on a non dpos chain: %v", header)
+	}
+}
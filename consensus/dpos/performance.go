@@ -0,0 +1,167 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"reflect"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/consensus"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/core/vm"
+	"github.com/ebakus/go-ebakus/log"
+)
+
+// WitnessPerformance holds the block production record of a witness.
+type WitnessPerformance struct {
+	Id          common.Address
+	Produced    uint64 // Blocks produced
+	Missed      uint64 // Slots missed
+	MissedInRow uint64 // Slots missed since the last produced block
+}
+
+// PerformanceTable is the system table holding the witnesses performance.
+var PerformanceTable = ebkdb.GetDBTableName(types.PrecompliledSystemContract, "WitnessPerformance")
+
+func init() {
+	ebkdb.RegisterTables(func(db ebkdb.State) ([]ebkdb.Table, error) {
+		return []ebkdb.Table{{Name: PerformanceTable, Row: reflect.TypeOf(WitnessPerformance{}), System: true}}, nil
+	})
+}
+
+// GetWitnessPerformance returns the production record of a witness, or nil if
+// it never was in turn to produce a block.
+func GetWitnessPerformance(db ebkdb.State, witness common.Address) (*WitnessPerformance, error) {
+	if !db.HasTable(PerformanceTable) {
+		return nil, nil
+	}
+	whereClause, err := db.WhereParser(append([]byte("Id = "), witness.Bytes()...))
+	if err != nil {
+		return nil, err
+	}
+	iter, err := db.Select(PerformanceTable, whereClause)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Release()
+
+	var performance WitnessPerformance
+	if !iter.Next(&performance) {
+		return nil, nil
+	}
+	return &performance, nil
+}
+
+// trackPerformance records the block production of the witnesses in turn since
+// the parent block: the block producer gets a produced block, the witnesses of
+// the empty slots in between a missed one. A standby producing the block in place
+// of the witness in turn gets the produced block, the witness in turn a missed
+// slot. Witnesses missing more consecutive slots than the configured maximum are
+// de-elected, so they stop being picked as delegates until they re-enable their
+// candidacy.
+func (d *DPOS) trackPerformance(chain consensus.ChainReader, header *types.Header, ebakusState ebkdb.State, producer common.Address) error {
+	if !chain.Config().IsPerformance(header.Number) || header.Number.Sign() == 0 {
+		return nil
+	}
+	parent := chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	parentState, err := chain.EbakusStateAt(parent.Hash(), parent.Number.Uint64())
+	if err != nil {
+		return err
+	}
+	defer parentState.Release()
+
+	if !ebakusState.HasTable(PerformanceTable) {
+		if err := ebakusState.CreateTable(PerformanceTable, &WitnessPerformance{}); err != nil {
+			return err
+		}
+	}
+	// Only look back one round of turns, after a longer halt every witness
+	// missed its turn anyway
+	from, slot := parent.Time/d.config.Period+1, header.Time/d.config.Period
+	if round := d.config.DelegateCount * d.config.TurnBlockCount; slot > from && slot-from > round {
+		from = slot - round
+	}
+	for missed := from; missed < slot; missed++ {
+		witness := d.getSignerAtSlot(chain, parent, parentState, float64(missed))
+		if witness == (common.Address{}) {
+			continue
+		}
+		if err := d.updatePerformance(ebakusState, witness, false); err != nil {
+			return err
+		}
+	}
+	inTurn := d.getSignerAtSlot(chain, parent, parentState, float64(slot))
+	if standbyCount(d.config) == 0 || producer == inTurn {
+		return d.updatePerformance(ebakusState, inTurn, true)
+	}
+	if err := d.updatePerformance(ebakusState, inTurn, false); err != nil {
+		return err
+	}
+	return d.updatePerformance(ebakusState, producer, true)
+}
+
+// updatePerformance records a produced block or a missed slot of a witness.
+func (d *DPOS) updatePerformance(ebakusState ebkdb.State, witness common.Address, produced bool) error {
+	performance, err := GetWitnessPerformance(ebakusState, witness)
+	if err != nil {
+		return err
+	}
+	if performance == nil {
+		performance = &WitnessPerformance{Id: witness}
+	}
+	if produced {
+		performance.Produced++
+		performance.MissedInRow = 0
+	} else {
+		performance.Missed++
+		performance.MissedInRow++
+	}
+	if err := ebakusState.InsertObj(PerformanceTable, performance); err != nil {
+		return err
+	}
+	if d.config.MaxMissedSlots == 0 || performance.MissedInRow <= d.config.MaxMissedSlots {
+		return nil
+	}
+	return deelectWitness(ebakusState, witness)
+}
+
+// deelectWitness clears the elect enabled flag of a witness.
+func deelectWitness(ebakusState ebkdb.State, address common.Address) error {
+	whereClause, err := ebakusState.WhereParser(append([]byte("Id LIKE "), address.Bytes()...))
+	if err != nil {
+		return err
+	}
+	iter, err := ebakusState.Select(vm.WitnessesTable, whereClause)
+	if err != nil {
+		return err
+	}
+	var witness vm.Witness
+	found := iter.Next(&witness)
+	iter.Release()
+
+	if !found || witness.Flags&vm.ElectEnabledFlag == 0 {
+		return nil
+	}
+	witness.Flags &= ^vm.ElectEnabledFlag
+
+	log.Info("De-electing witness missing its turns", "witness", address)
+	return ebakusState.InsertObj(vm.WitnessesTable, &witness)
+}
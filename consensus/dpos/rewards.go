@@ -0,0 +1,75 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"math/big"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/core/state"
+	"github.com/ebakus/go-ebakus/core/vm"
+	"github.com/ebakus/go-ebakus/params"
+)
+
+// shareReward credits the voters of the witness with the configured share of
+// the block reward, pro-rata to their stake, returning the amount credited.
+// The rounding remainder isn't distributed, staying with the witness.
+func shareReward(config *params.DPOSConfig, state *state.StateDB, ebakusState ebkdb.State, witness common.Address, reward *big.Int) (*big.Int, error) {
+	percentage := config.RewardShare
+	if percentage > 100 {
+		percentage = 100
+	}
+	pool := new(big.Int).Mul(reward, new(big.Int).SetUint64(percentage))
+	pool.Div(pool, big.NewInt(100))
+
+	voters, err := vm.GetWitnessVoters(ebakusState, witness)
+	if err != nil {
+		return nil, err
+	}
+	// Gather all the stakes before crediting anyone, so a failing lookup
+	// leaves the balances untouched
+	var (
+		stakes = make([]*big.Int, len(voters))
+		total  = new(big.Int)
+	)
+	for i, voter := range voters {
+		staked, err := vm.GetStaked(ebakusState, voter)
+		if err != nil {
+			return nil, err
+		}
+		stakes[i] = new(big.Int)
+		if staked != nil {
+			stakes[i].SetUint64(staked.Amount)
+		}
+		total.Add(total, stakes[i])
+	}
+	shared := new(big.Int)
+	if total.Sign() == 0 {
+		return shared, nil
+	}
+	for i, voter := range voters {
+		share := new(big.Int).Mul(pool, stakes[i])
+		share.Div(share, total)
+		if share.Sign() == 0 {
+			continue
+		}
+		state.AddBalance(voter, share)
+		shared.Add(shared, share)
+	}
+	return shared, nil
+}
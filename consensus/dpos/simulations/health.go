@@ -0,0 +1,88 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulations
+
+import (
+	"fmt"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/types"
+)
+
+// Health summarises the state of the chain across a simulated network.
+type Health struct {
+	Heads       []*types.Header        // Current head of every node
+	Forks       int                    // Number of distinct heads in the network
+	Best        *types.Header          // Highest head in the network
+	MissedSlots uint64                 // Slots without a block in the best chain
+	Producers   map[common.Address]int // Blocks produced by every signer in the best chain
+}
+
+// Health inspects the chains of all the nodes.
+func (net *Network) Health() (*Health, error) {
+	health := &Health{
+		Producers: make(map[common.Address]int),
+	}
+	var (
+		best  = net.Nodes[0]
+		heads = make(map[common.Hash]struct{})
+	)
+	for _, node := range net.Nodes {
+		head := node.Chain.CurrentHeader()
+		health.Heads = append(health.Heads, head)
+		heads[head.Hash()] = struct{}{}
+
+		if head.Number.Cmp(best.Chain.CurrentHeader().Number) > 0 {
+			best = node
+		}
+	}
+	health.Forks = len(heads)
+	health.Best = best.Chain.CurrentHeader()
+
+	for header := health.Best; header.Number.Sign() > 0; header = best.Chain.GetHeader(header.ParentHash, header.Number.Uint64()-1) {
+		signer, err := best.Engine.Author(header)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %v", header.Number, err)
+		}
+		health.Producers[signer]++
+	}
+	period := best.Chain.Config().DPOS.Period
+	if period == 0 {
+		period = 1
+	}
+	if slots := (health.Best.Time - net.genesis.Timestamp) / period; slots > health.Best.Number.Uint64() {
+		health.MissedSlots = slots - health.Best.Number.Uint64()
+	}
+	return health, nil
+}
+
+// Converged reports whether all the nodes share the same head.
+func (h *Health) Converged() bool {
+	return h.Forks == 1
+}
+
+// Check returns an error if the network hasn't converged or missed more slots
+// than allowed.
+func (h *Health) Check(maxMissedSlots uint64) error {
+	if !h.Converged() {
+		return fmt.Errorf("network forked into %d heads", h.Forks)
+	}
+	if h.MissedSlots > maxMissedSlots {
+		return fmt.Errorf("missed %d slots, allowed %d", h.MissedSlots, maxMissedSlots)
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package simulations simulates networks of DPOS block producers in memory, on
+// a virtual clock, allowing to script consensus scenarios like delegate churn,
+// network partitions and stake shifts and to check the chain health afterwards.
+package simulations
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ebakus/go-ebakus/accounts/abi"
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/consensus/dpos/dpostest"
+	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/core/vm"
+	"github.com/ebakus/go-ebakus/log"
+)
+
+// systemCallGas is the gas limit of the transactions calling the system contract.
+const systemCallGas = 1000000
+
+// Network is a simulated network of DPOS nodes.
+type Network struct {
+	*dpostest.Harness
+
+	genesis *core.Genesis
+	slot    int // Number of slots simulated so far
+
+	groups map[*dpostest.HarnessNode]int    // Partition of every node, nil when fully connected
+	nonces map[*dpostest.HarnessNode]uint64 // Next nonce of the transactions sent by a node
+	abi    abi.ABI
+}
+
+// NewNetwork creates and starts a simulated network with a node per key.
+func NewNetwork(genesis *core.Genesis, keys []*ecdsa.PrivateKey) (*Network, error) {
+	systemABI, err := abi.JSON(strings.NewReader(vm.SystemContractABI))
+	if err != nil {
+		return nil, err
+	}
+	harness, err := dpostest.NewHarness(genesis, keys)
+	if err != nil {
+		return nil, err
+	}
+	net := &Network{
+		Harness: harness,
+		genesis: genesis,
+		nonces:  make(map[*dpostest.HarnessNode]uint64),
+		abi:     systemABI,
+	}
+	harness.Connected = net.connected
+	harness.Start()
+
+	return net, nil
+}
+
+// Slot returns the number of slots simulated so far.
+func (net *Network) Slot() int {
+	return net.slot
+}
+
+// Run simulates the given number of slots.
+func (net *Network) Run(slots int) error {
+	err := net.Harness.Run(slots)
+	net.slot += slots
+	return err
+}
+
+// Partition splits the network into the given groups of node indexes. Blocks
+// only propagate within a group and nodes not part of any group get isolated.
+func (net *Network) Partition(groups ...[]int) error {
+	partition := make(map[*dpostest.HarnessNode]int)
+	for i, node := range net.Nodes {
+		partition[node] = -i - 1
+	}
+	for id, group := range groups {
+		for _, index := range group {
+			if index < 0 || index >= len(net.Nodes) {
+				return fmt.Errorf("unknown node %d", index)
+			}
+			partition[net.Nodes[index]] = id
+		}
+	}
+	net.groups = partition
+
+	log.Debug("Partitioned simulated network", "slot", net.slot, "groups", groups)
+	return nil
+}
+
+// Heal reconnects all the nodes and synchronises them with the longest chain.
+func (net *Network) Heal() error {
+	net.groups = nil
+
+	best := net.Nodes[0]
+	for _, node := range net.Nodes[1:] {
+		if node.Chain.CurrentBlock().NumberU64() > best.Chain.CurrentBlock().NumberU64() {
+			best = node
+		}
+	}
+	for _, node := range net.Nodes {
+		if node == best {
+			continue
+		}
+		if err := syncChain(best, node); err != nil {
+			return err
+		}
+	}
+	log.Debug("Healed simulated network", "slot", net.slot, "head", best.Chain.CurrentBlock().NumberU64())
+	return nil
+}
+
+// connected implements dpostest.Harness.Connected based on the current partition.
+func (net *Network) connected(from, to *dpostest.HarnessNode) bool {
+	return net.groups == nil || net.groups[from] == net.groups[to]
+}
+
+// syncChain imports into the to node the blocks of the from node's canonical
+// chain it is missing.
+func syncChain(from, to *dpostest.HarnessNode) error {
+	var blocks types.Blocks
+	for block := from.Chain.CurrentBlock(); !to.Chain.HasBlock(block.Hash(), block.NumberU64()); {
+		blocks = append(blocks, block)
+		block = from.Chain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	}
+	if len(blocks) == 0 {
+		return nil
+	}
+	for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
+		blocks[i], blocks[j] = blocks[j], blocks[i]
+	}
+	if _, err := to.Chain.InsertChain(blocks); err != nil {
+		return fmt.Errorf("sync %x -> %x failed: %v", from.Address, to.Address, err)
+	}
+	return nil
+}
+
+// SendTransaction signs a transaction from the given node and hands it to all
+// the nodes the sender can currently reach.
+func (net *Network) SendTransaction(from int, to common.Address, value *big.Int, gas uint64, data []byte) (*types.Transaction, error) {
+	if from < 0 || from >= len(net.Nodes) {
+		return nil, fmt.Errorf("unknown node %d", from)
+	}
+	sender := net.Nodes[from]
+
+	nonce, ok := net.nonces[sender]
+	if !ok {
+		state, err := sender.Chain.State()
+		if err != nil {
+			return nil, err
+		}
+		nonce = state.GetNonce(sender.Address)
+	}
+	signer := types.NewEIP155Signer(sender.Chain.Config().ChainID)
+
+	tx, err := types.SignTx(types.NewTransaction(0, nonce, to, value, gas, data), signer, sender.Key)
+	if err != nil {
+		return nil, err
+	}
+	net.nonces[sender] = nonce + 1
+
+	for _, node := range net.Nodes {
+		if net.connected(sender, node) {
+			node.AddTransaction(tx)
+		}
+	}
+	return tx, nil
+}
+
+// Transfer sends value from a node to the account of another one.
+func (net *Network) Transfer(from, to int, value *big.Int) error {
+	if to < 0 || to >= len(net.Nodes) {
+		return fmt.Errorf("unknown node %d", to)
+	}
+	_, err := net.SendTransaction(from, net.Nodes[to].Address, value, 21000, nil)
+	return err
+}
+
+// Stake locks amount of the node's balance in the system contract.
+func (net *Network) Stake(node int, amount uint64) error {
+	return net.systemCall(node, vm.SystemContractStakeCmd, amount)
+}
+
+// Unstake releases amount of the node's stake.
+func (net *Network) Unstake(node int, amount uint64) error {
+	return net.systemCall(node, vm.SystemContractUnstakeCmd, amount)
+}
+
+// ElectEnable registers (or unregisters) the node as a delegate candidate.
+func (net *Network) ElectEnable(node int, enable bool) error {
+	return net.systemCall(node, vm.SystemContractElectEnableCmd, enable)
+}
+
+// Vote casts the node's stake to the given delegate candidates.
+func (net *Network) Vote(node int, delegates ...int) error {
+	addresses := make([]common.Address, len(delegates))
+	for i, delegate := range delegates {
+		if delegate < 0 || delegate >= len(net.Nodes) {
+			return fmt.Errorf("unknown node %d", delegate)
+		}
+		addresses[i] = net.Nodes[delegate].Address
+	}
+	return net.systemCall(node, vm.SystemContractVoteCmd, addresses)
+}
+
+func (net *Network) systemCall(node int, method string, args ...interface{}) error {
+	data, err := net.abi.Pack(method, args...)
+	if err != nil {
+		return err
+	}
+	_, err = net.SendTransaction(node, types.PrecompliledSystemContract, new(big.Int), systemCallGas, data)
+	return err
+}
+
+// Step is an action of a scenario, executed when the network reaches a slot.
+type Step struct {
+	Slot   int
+	Name   string
+	Action func(net *Network) error
+}
+
+// Scenario is a script of actions to run against a simulated network.
+type Scenario []Step
+
+// RunScenario simulates the given number of slots, executing the scenario
+// steps as their slot is reached.
+func (net *Network) RunScenario(scenario Scenario, slots int) error {
+	steps := make(Scenario, len(scenario))
+	copy(steps, scenario)
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].Slot < steps[j].Slot })
+
+	end := net.slot + slots
+	for _, step := range steps {
+		if step.Slot > end {
+			break
+		}
+		if step.Slot > net.slot {
+			if err := net.Run(step.Slot - net.slot); err != nil {
+				return err
+			}
+		}
+		log.Debug("Running scenario step", "slot", net.slot, "name", step.Name)
+		if err := step.Action(net); err != nil {
+			return fmt.Errorf("step %q at slot %d failed: %v", step.Name, step.Slot, err)
+		}
+	}
+	if end > net.slot {
+		return net.Run(end - net.slot)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulations
+
+import (
+	"crypto/ecdsa"
+	"testing"
+
+	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/crypto"
+)
+
+func newTestNetwork(t *testing.T, nodes int) *Network {
+	var keys []*ecdsa.PrivateKey
+	for i := 0; i < nodes; i++ {
+		key, _ := crypto.GenerateKey()
+		keys = append(keys, key)
+	}
+	genesis := core.DeveloperGenesisBlock(1, crypto.PubkeyToAddress(keys[0].PublicKey))
+
+	net, err := NewNetwork(genesis, keys)
+	if err != nil {
+		t.Fatalf("failed to create network: %v", err)
+	}
+	return net
+}
+
+// Tests that nodes cut off from the producer fall behind during a partition and
+// catch up with the chain once it heals.
+func TestPartitionHeal(t *testing.T) {
+	net := newTestNetwork(t, 3)
+	defer net.Stop()
+
+	var lagging *Health
+	scenario := Scenario{
+		{Slot: 20, Name: "heal", Action: func(net *Network) (err error) {
+			lagging, err = net.Health()
+			if err != nil {
+				return err
+			}
+			return net.Heal()
+		}},
+		{Slot: 10, Name: "partition", Action: func(net *Network) error {
+			return net.Partition([]int{0}, []int{1, 2})
+		}},
+	}
+	if err := net.RunScenario(scenario, 30); err != nil {
+		t.Fatalf("scenario failed: %v", err)
+	}
+	if lagging.Converged() {
+		t.Errorf("partitioned network converged")
+	}
+	if have := lagging.Heads[1].Number.Uint64(); have != 10 {
+		t.Errorf("partitioned node head mismatch: have %d, want %d", have, 10)
+	}
+	health, err := net.Health()
+	if err != nil {
+		t.Fatalf("failed to inspect health: %v", err)
+	}
+	if err := health.Check(0); err != nil {
+		t.Errorf("unhealthy network: %v", err)
+	}
+	if have := health.Best.Number.Uint64(); have != 30 {
+		t.Errorf("head number mismatch: have %d, want %d", have, 30)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/consensus"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/core/vm"
+	"github.com/ebakus/go-ebakus/params"
+)
+
+// The delegates following the one in turn in the rotation stand by for its slot:
+// if no block was produced after StandbyDelay percent of the slot elapsed, the
+// first standby may produce it, the second one after twice the delay and so on.
+// The standby blocks are timestamped like the in turn ones, so the nodes tell
+// the premature ones apart by their wall clock, postponing their import until
+// the standby's delay elapsed.
+
+// standbyCount returns the number of standby delegates of a slot, capped to the
+// ones getting their turn before the slot ends.
+func standbyCount(config *params.DPOSConfig) int {
+	if config.StandbyCount == 0 || config.StandbyDelay == 0 {
+		return 0
+	}
+	count := config.StandbyCount
+	if max := (100 - 1) / config.StandbyDelay; count > max {
+		count = max
+	}
+	return int(count)
+}
+
+// standbyDelay returns the time into the slot after which the producer of the
+// given rank, 0 being the delegate in turn, may produce its block.
+func standbyDelay(config *params.DPOSConfig, rank int) time.Duration {
+	return time.Duration(config.Period) * time.Second * time.Duration(uint64(rank)*config.StandbyDelay) / 100
+}
+
+// slotProducers returns the delegates which may produce the block of a slot by
+// rank, the one in turn first followed by its standbys, or nil if the slot has
+// no delegate in turn.
+func slotProducers(config *params.DPOSConfig, delegates vm.WitnessArray, slot float64) []common.Address {
+	if config.DelegateCount == 0 || config.TurnBlockCount == 0 {
+		return nil
+	}
+	slot = slot / float64(config.TurnBlockCount)
+	s := int(slot) % int(config.DelegateCount)
+
+	if s >= len(delegates) {
+		return nil
+	}
+	standbys := standbyCount(config)
+	if standbys > len(delegates)-1 {
+		standbys = len(delegates) - 1
+	}
+	producers := make([]common.Address, 0, 1+standbys)
+	for rank := 0; rank <= standbys; rank++ {
+		producers = append(producers, delegates[(s+rank)%len(delegates)].Id)
+	}
+	return producers
+}
+
+// producerRank returns the rank of the producer among the ones of a slot, or -1
+// if it may not produce the block of the slot.
+func producerRank(producers []common.Address, producer common.Address) int {
+	for rank, address := range producers {
+		if address == producer {
+			return rank
+		}
+	}
+	return -1
+}
+
+// getProducersAtSlot returns the delegates which may produce the block of a slot
+// on top of header by rank, the one in turn first. Before the standby fork, only
+// the delegate in turn may.
+func (d *DPOS) getProducersAtSlot(chain consensus.ChainReader, header *types.Header, state ebkdb.State, slot float64) []common.Address {
+	delegates := GetDelegates(header, state, d.config.DelegateCount, d.config.BonusDelegateCount, d.config.TurnBlockCount)
+
+	producers := slotProducers(d.config, delegates, slot)
+	if len(producers) > 1 && !chain.Config().IsStandby(new(big.Int).Add(header.Number, common.Big1)) {
+		producers = producers[:1]
+	}
+	return producers
+}
+
+// slotStart returns the time the slot of a block timestamp started at.
+func (d *DPOS) slotStart(timestamp uint64) time.Time {
+	return time.Unix(int64(timestamp-timestamp%d.config.Period), 0)
+}
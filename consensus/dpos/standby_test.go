@@ -0,0 +1,66 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/vm"
+	"github.com/ebakus/go-ebakus/params"
+)
+
+// Tests that the standbys of a slot are the delegates following the one in turn
+// in the rotation, each getting its turn later into the slot.
+func TestSlotProducers(t *testing.T) {
+	var delegates vm.WitnessArray
+	for i := 1; i <= 4; i++ {
+		delegates = append(delegates, vm.Witness{Id: common.BytesToAddress([]byte{byte(i)})})
+	}
+	addr := func(i int) common.Address { return delegates[i].Id }
+
+	tests := []struct {
+		count, delay uint64
+		slot         float64
+		producers    []common.Address
+	}{
+		{0, 25, 2, []common.Address{addr(2)}},                            // Standbys disabled
+		{2, 0, 2, []common.Address{addr(2)}},                             // No delay, standbys disabled
+		{2, 25, 2, []common.Address{addr(2), addr(3), addr(0)}},          // Standbys wrap around the rotation
+		{3, 40, 1, []common.Address{addr(1), addr(2), addr(3)}},          // Third standby past the slot end
+		{9, 10, 0, []common.Address{addr(0), addr(1), addr(2), addr(3)}}, // More standbys than delegates
+		{2, 25, 5, nil}, // Slot without a delegate in turn
+	}
+	for i, tt := range tests {
+		config := &params.DPOSConfig{Period: 1, DelegateCount: 6, TurnBlockCount: 1, StandbyCount: tt.count, StandbyDelay: tt.delay}
+		producers := slotProducers(config, delegates, tt.slot)
+		if !reflect.DeepEqual(producers, tt.producers) {
+			t.Errorf("test %d: producers mismatch: have %x, want %x", i, producers, tt.producers)
+		}
+		for rank, producer := range producers {
+			if have := producerRank(producers, producer); have != rank {
+				t.Errorf("test %d: rank mismatch: have %d, want %d", i, have, rank)
+			}
+		}
+	}
+	config := &params.DPOSConfig{Period: 2, StandbyCount: 2, StandbyDelay: 30}
+	if have, want := standbyDelay(config, 2), 1200*time.Millisecond; have != want {
+		t.Errorf("standby delay mismatch: have %v, want %v", have, want)
+	}
+}
@@ -0,0 +1,384 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"reflect"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/core/state"
+	"github.com/ebakus/go-ebakus/core/types"
+)
+
+// stateRecorder is a view of a state database recording the accounts the
+// transactions executed against it access, so that the execution can be checked
+// for conflicts and its outcome copied over to another state database.
+//
+// Accesses are recorded at account granularity and never rolled back when the
+// state is reverted, so they are a superset of the effective ones.
+type stateRecorder struct {
+	db *state.StateDB
+
+	reads   map[common.Address]struct{}                 // Accounts read or touched
+	writes  map[common.Address]struct{}                 // Accounts changed
+	touched map[common.Address]struct{}                 // Accounts touched without change
+	created map[common.Address]struct{}                 // Accounts (re)created
+	code    map[common.Address]struct{}                 // Accounts whose code was set
+	slots   map[common.Address]map[common.Hash]struct{} // Storage slots set, by account
+
+	preimages map[common.Hash][]byte
+}
+
+// newStateRecorder creates a recording view of the given state database.
+func newStateRecorder(db *state.StateDB) *stateRecorder {
+	return &stateRecorder{
+		db:        db,
+		reads:     make(map[common.Address]struct{}),
+		writes:    make(map[common.Address]struct{}),
+		touched:   make(map[common.Address]struct{}),
+		created:   make(map[common.Address]struct{}),
+		code:      make(map[common.Address]struct{}),
+		slots:     make(map[common.Address]map[common.Hash]struct{}),
+		preimages: make(map[common.Hash][]byte),
+	}
+}
+
+func (r *stateRecorder) read(addr common.Address)  { r.reads[addr] = struct{}{} }
+func (r *stateRecorder) write(addr common.Address) { r.writes[addr] = struct{}{} }
+
+func (r *stateRecorder) touch(addr common.Address) {
+	r.reads[addr] = struct{}{}
+	r.touched[addr] = struct{}{}
+}
+
+func (r *stateRecorder) CreateAccount(addr common.Address) {
+	r.write(addr)
+	r.created[addr] = struct{}{}
+	r.db.CreateAccount(addr)
+}
+
+// SubBalance and AddBalance of zero amounts only touch the account, which is
+// deleted if empty. That's recorded as a read, so that all transactions paying
+// the zero gas price to the coinbase don't conflict with each other, and it's
+// replayed on commit.
+func (r *stateRecorder) SubBalance(addr common.Address, amount *big.Int) {
+	if amount.Sign() == 0 {
+		r.touch(addr)
+	} else {
+		r.write(addr)
+	}
+	r.db.SubBalance(addr, amount)
+}
+
+func (r *stateRecorder) AddBalance(addr common.Address, amount *big.Int) {
+	if amount.Sign() == 0 {
+		r.touch(addr)
+	} else {
+		r.write(addr)
+	}
+	r.db.AddBalance(addr, amount)
+}
+
+func (r *stateRecorder) GetBalance(addr common.Address) *big.Int {
+	r.read(addr)
+	return r.db.GetBalance(addr)
+}
+
+func (r *stateRecorder) GetNonce(addr common.Address) uint64 {
+	r.read(addr)
+	return r.db.GetNonce(addr)
+}
+
+func (r *stateRecorder) SetNonce(addr common.Address, nonce uint64) {
+	r.write(addr)
+	r.db.SetNonce(addr, nonce)
+}
+
+func (r *stateRecorder) GetCodeHash(addr common.Address) common.Hash {
+	r.read(addr)
+	return r.db.GetCodeHash(addr)
+}
+
+func (r *stateRecorder) GetCode(addr common.Address) []byte {
+	r.read(addr)
+	return r.db.GetCode(addr)
+}
+
+func (r *stateRecorder) SetCode(addr common.Address, code []byte) {
+	r.write(addr)
+	r.code[addr] = struct{}{}
+	r.db.SetCode(addr, code)
+}
+
+func (r *stateRecorder) GetCodeSize(addr common.Address) int {
+	r.read(addr)
+	return r.db.GetCodeSize(addr)
+}
+
+func (r *stateRecorder) AddRefund(gas uint64) { r.db.AddRefund(gas) }
+func (r *stateRecorder) SubRefund(gas uint64) { r.db.SubRefund(gas) }
+func (r *stateRecorder) GetRefund() uint64    { return r.db.GetRefund() }
+
+func (r *stateRecorder) GetCommittedState(addr common.Address, key common.Hash) common.Hash {
+	r.read(addr)
+	return r.db.GetCommittedState(addr, key)
+}
+
+func (r *stateRecorder) GetState(addr common.Address, key common.Hash) common.Hash {
+	r.read(addr)
+	return r.db.GetState(addr, key)
+}
+
+func (r *stateRecorder) SetState(addr common.Address, key common.Hash, value common.Hash) {
+	r.write(addr)
+	if r.slots[addr] == nil {
+		r.slots[addr] = make(map[common.Hash]struct{})
+	}
+	r.slots[addr][key] = struct{}{}
+	r.db.SetState(addr, key, value)
+}
+
+func (r *stateRecorder) Suicide(addr common.Address) bool {
+	r.write(addr)
+	return r.db.Suicide(addr)
+}
+
+func (r *stateRecorder) HasSuicided(addr common.Address) bool {
+	r.read(addr)
+	return r.db.HasSuicided(addr)
+}
+
+func (r *stateRecorder) Exist(addr common.Address) bool {
+	r.read(addr)
+	return r.db.Exist(addr)
+}
+
+func (r *stateRecorder) Empty(addr common.Address) bool {
+	r.read(addr)
+	return r.db.Empty(addr)
+}
+
+func (r *stateRecorder) RevertToSnapshot(revid int) { r.db.RevertToSnapshot(revid) }
+func (r *stateRecorder) Snapshot() int              { return r.db.Snapshot() }
+func (r *stateRecorder) AddLog(log *types.Log)      { r.db.AddLog(log) }
+
+func (r *stateRecorder) AddPreimage(hash common.Hash, preimage []byte) {
+	r.preimages[hash] = preimage
+	r.db.AddPreimage(hash, preimage)
+}
+
+func (r *stateRecorder) ForEachStorage(addr common.Address, cb func(common.Hash, common.Hash) bool) error {
+	r.read(addr)
+	return r.db.ForEachStorage(addr, cb)
+}
+
+// commit copies the final state of the accounts accessed over to dst, which
+// must hold the state the recording started from for these accounts.
+func (r *stateRecorder) commit(dst *state.StateDB) {
+	for addr := range r.touched {
+		if _, ok := r.writes[addr]; !ok {
+			// Replay the touch, deleting the account if empty
+			dst.AddBalance(addr, new(big.Int))
+		}
+	}
+	for addr := range r.writes {
+		if r.unchanged(dst, addr) {
+			continue // Reverted writes mustn't touch the account
+		}
+		if !r.db.Exist(addr) {
+			dst.Suicide(addr)
+			continue
+		}
+		if _, ok := r.created[addr]; ok {
+			dst.CreateAccount(addr)
+		}
+		dst.SetBalance(addr, r.db.GetBalance(addr))
+		dst.SetNonce(addr, r.db.GetNonce(addr))
+		if _, ok := r.code[addr]; ok {
+			dst.SetCode(addr, r.db.GetCode(addr))
+		}
+		for key := range r.slots[addr] {
+			dst.SetState(addr, key, r.db.GetState(addr, key))
+		}
+	}
+	for hash, preimage := range r.preimages {
+		dst.AddPreimage(hash, preimage)
+	}
+}
+
+// unchanged reports whether an account written ended up as it is in dst.
+func (r *stateRecorder) unchanged(dst *state.StateDB, addr common.Address) bool {
+	if _, ok := r.created[addr]; ok {
+		return false
+	}
+	if r.db.Exist(addr) != dst.Exist(addr) {
+		return false
+	}
+	if r.db.GetBalance(addr).Cmp(dst.GetBalance(addr)) != 0 || r.db.GetNonce(addr) != dst.GetNonce(addr) || r.db.GetCodeHash(addr) != dst.GetCodeHash(addr) {
+		return false
+	}
+	for key := range r.slots[addr] {
+		if r.db.GetState(addr, key) != dst.GetState(addr, key) {
+			return false
+		}
+	}
+	return true
+}
+
+// ebakusRecorder is a view of an ebakus state recording the tables and keys the
+// transactions executed against it access, along with the changes they make,
+// so that these can be checked for conflicts and replayed on another state.
+//
+// Tables are recorded as a whole, as their rows are selected by queries.
+type ebakusRecorder struct {
+	ebkdb.State
+
+	reads  map[string]struct{} // Tables and keys read
+	writes map[string]struct{} // Tables and keys changed
+	ops    []func(ebkdb.State) error
+}
+
+// ebakusMark is a snapshot of a recorded ebakus state, which resetting to also
+// drops the changes recorded since.
+type ebakusMark struct {
+	ebkdb.State
+	ops int
+}
+
+// newEbakusRecorder creates a recording view of the given ebakus state.
+func newEbakusRecorder(state ebkdb.State) *ebakusRecorder {
+	return &ebakusRecorder{
+		State:  state,
+		reads:  make(map[string]struct{}),
+		writes: make(map[string]struct{}),
+	}
+}
+
+func tableAccess(table string) string { return "t:" + table }
+func keyAccess(key []byte) string     { return "k:" + string(key) }
+
+func (r *ebakusRecorder) Get(key []byte) (*[]byte, bool) {
+	r.reads[keyAccess(key)] = struct{}{}
+	return r.State.Get(key)
+}
+
+func (r *ebakusRecorder) Insert(key, value []byte) error {
+	r.writes[keyAccess(key)] = struct{}{}
+	if err := r.State.Insert(key, value); err != nil {
+		return err
+	}
+	key, value = common.CopyBytes(key), common.CopyBytes(value)
+	r.ops = append(r.ops, func(state ebkdb.State) error { return state.Insert(key, value) })
+	return nil
+}
+
+func (r *ebakusRecorder) Delete(key []byte) error {
+	r.writes[keyAccess(key)] = struct{}{}
+	if err := r.State.Delete(key); err != nil {
+		return err
+	}
+	key = common.CopyBytes(key)
+	r.ops = append(r.ops, func(state ebkdb.State) error { return state.Delete(key) })
+	return nil
+}
+
+func (r *ebakusRecorder) HasTable(table string) bool {
+	r.reads[tableAccess(table)] = struct{}{}
+	return r.State.HasTable(table)
+}
+
+func (r *ebakusRecorder) CreateTable(table string, obj interface{}) error {
+	r.writes[tableAccess(table)] = struct{}{}
+	if err := r.State.CreateTable(table, obj); err != nil {
+		return err
+	}
+	obj = copyObject(obj)
+	r.ops = append(r.ops, func(state ebkdb.State) error { return state.CreateTable(table, obj) })
+	return nil
+}
+
+func (r *ebakusRecorder) CreateIndex(index ebkdb.IndexField) error {
+	r.writes[tableAccess(index.Table)] = struct{}{}
+	if err := r.State.CreateIndex(index); err != nil {
+		return err
+	}
+	r.ops = append(r.ops, func(state ebkdb.State) error { return state.CreateIndex(index) })
+	return nil
+}
+
+func (r *ebakusRecorder) InsertObj(table string, obj interface{}) error {
+	r.writes[tableAccess(table)] = struct{}{}
+	if err := r.State.InsertObj(table, obj); err != nil {
+		return err
+	}
+	obj = copyObject(obj)
+	r.ops = append(r.ops, func(state ebkdb.State) error { return state.InsertObj(table, obj) })
+	return nil
+}
+
+func (r *ebakusRecorder) DeleteObj(table string, id interface{}) error {
+	r.writes[tableAccess(table)] = struct{}{}
+	if err := r.State.DeleteObj(table, id); err != nil {
+		return err
+	}
+	id = copyObject(id)
+	r.ops = append(r.ops, func(state ebkdb.State) error { return state.DeleteObj(table, id) })
+	return nil
+}
+
+func (r *ebakusRecorder) Select(table string, args ...interface{}) (ebkdb.Iterator, error) {
+	r.reads[tableAccess(table)] = struct{}{}
+	return r.State.Select(table, args...)
+}
+
+func (r *ebakusRecorder) Snapshot() ebkdb.State {
+	return &ebakusMark{State: r.State.Snapshot(), ops: len(r.ops)}
+}
+
+func (r *ebakusRecorder) ResetTo(snap ebkdb.State) {
+	if mark, ok := snap.(*ebakusMark); ok {
+		r.State.ResetTo(mark.State)
+		r.ops = r.ops[:mark.ops]
+		return
+	}
+	r.State.ResetTo(snap)
+}
+
+// replay applies the recorded changes to dst, which must hold the state the
+// recording started from for the tables and keys changed.
+func (r *ebakusRecorder) replay(dst ebkdb.State) error {
+	for _, op := range r.ops {
+		if err := op(dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyObject returns a shallow copy of the value an object pointer points to,
+// so that the callers may reuse their objects after storing them.
+func copyObject(obj interface{}) interface{} {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return obj
+	}
+	cpy := reflect.New(v.Elem().Type())
+	cpy.Elem().Set(v.Elem())
+	return cpy.Interface()
+}
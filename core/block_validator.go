@@ -134,3 +134,56 @@ func CalcGasLimit(parent *types.Header, gasFloor, gasCeil uint64) uint64 {
 	}
 	return limit
 }
+
+// CalcGasLimitTarget computes the gas limit of the next block after parent,
+// steering the average utilization of the last window blocks towards the
+// target ratio. The limit moves at most parentGasLimit/1024 per block and is
+// kept between the provided floor and ceil. A target outside (0, 1] or an
+// empty window fall back to CalcGasLimit.
+func CalcGasLimitTarget(chain consensus.ChainReader, parent *types.Header, gasFloor, gasCeil uint64, target float64, window uint64) uint64 {
+	if target <= 0 || target > 1 || window == 0 {
+		return CalcGasLimit(parent, gasFloor, gasCeil)
+	}
+
+	// Average the utilization over the window (or up to genesis)
+	var used, limit float64
+	for header, i := parent, uint64(0); header != nil && i < window; i++ {
+		used += float64(header.GasUsed)
+		limit += float64(header.GasLimit)
+
+		if header.Number.Sign() == 0 {
+			break
+		}
+		header = chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	}
+
+	desired := parent.GasLimit
+	if limit > 0 {
+		desired = uint64(float64(parent.GasLimit) * (used / limit) / target)
+	}
+
+	// Smooth capacity changes, the same way CalcGasLimit does
+	step := parent.GasLimit/params.GasLimitBoundDivisor - 1
+	if desired > parent.GasLimit+step {
+		desired = parent.GasLimit + step
+	} else if desired+step < parent.GasLimit {
+		desired = parent.GasLimit - step
+	}
+
+	// If we're outside our allowed gas range, we try to hone towards them
+	if desired < gasFloor {
+		desired = parent.GasLimit + step
+		if desired > gasFloor {
+			desired = gasFloor
+		}
+	} else if desired > gasCeil {
+		desired = parent.GasLimit - step
+		if desired < gasCeil {
+			desired = gasCeil
+		}
+	}
+	if desired < params.MinGasLimit {
+		desired = params.MinGasLimit
+	}
+	return desired
+}
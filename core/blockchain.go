@@ -27,13 +27,14 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/ebakus/ebakusdb"
 	lru "github.com/hashicorp/golang-lru"
 
 	"github.com/ebakus/go-ebakus/common"
 	"github.com/ebakus/go-ebakus/common/mclock"
+	"github.com/ebakus/go-ebakus/common/priolock"
 	"github.com/ebakus/go-ebakus/common/prque"
 	"github.com/ebakus/go-ebakus/consensus"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/rawdb"
 	"github.com/ebakus/go-ebakus/core/state"
 	"github.com/ebakus/go-ebakus/core/types"
@@ -62,16 +63,21 @@ var (
 	storageUpdateTimer = metrics.NewRegisteredTimer("chain/storage/updates", nil)
 	storageCommitTimer = metrics.NewRegisteredTimer("chain/storage/commits", nil)
 
-	blockInsertTimer     = metrics.NewRegisteredTimer("chain/inserts", nil)
-	blockValidationTimer = metrics.NewRegisteredTimer("chain/validation", nil)
-	blockExecutionTimer  = metrics.NewRegisteredTimer("chain/execution", nil)
-	blockWriteTimer      = metrics.NewRegisteredTimer("chain/write", nil)
+	blockInsertTimer     = metrics.NewRegisteredTimerForced("chain/inserts", nil)
+	blockValidationTimer = metrics.NewRegisteredTimerForced("chain/validation", nil)
+	blockExecutionTimer  = metrics.NewRegisteredTimerForced("chain/execution", nil)
+	blockWriteTimer      = metrics.NewRegisteredTimerForced("chain/write", nil)
+	blockTxsMeter        = metrics.NewRegisteredMeterForced("chain/inserts/txs", nil)
+	blockGasMeter        = metrics.NewRegisteredMeterForced("chain/inserts/gas", nil)
 	blockReorgAddMeter   = metrics.NewRegisteredMeter("chain/reorg/drop", nil)
 	blockReorgDropMeter  = metrics.NewRegisteredMeter("chain/reorg/add", nil)
 
 	blockPrefetchExecuteTimer   = metrics.NewRegisteredTimer("chain/prefetch/executes", nil)
 	blockPrefetchInterruptMeter = metrics.NewRegisteredMeter("chain/prefetch/interrupts", nil)
 
+	ebakusImportWaitTimer = metrics.NewRegisteredTimerForced("chain/ebakus/importwait", nil)
+	ebakusCommitTimer     = metrics.NewRegisteredTimerForced("chain/ebakus/commits", nil)
+
 	errInsertionInterrupted = errors.New("insertion is interrupted")
 )
 
@@ -85,6 +91,11 @@ const (
 	badBlockLimit       = 10
 	TriesInMemory       = 128
 
+	// Ebakusdb snapshots live for longer than snapshotLeakAge are reported as
+	// leaked, checked every snapshotLeakInterval
+	snapshotLeakInterval = time.Minute
+	snapshotLeakAge      = 10 * time.Minute
+
 	// BlockChainVersion ensures that an incompatible database forces a resync from scratch.
 	//
 	// Changelog:
@@ -116,6 +127,17 @@ type CacheConfig struct {
 	TrieDirtyLimit      int           // Memory limit (MB) at which to start flushing dirty trie nodes to disk
 	TrieDirtyDisabled   bool          // Whether to disable trie write caching and GC altogether (archive node)
 	TrieTimeLimit       time.Duration // Time limit after which to flush the current in-memory trie to disk
+
+	ArchiveContracts   []common.Address // Contracts to retain historical ebakusdb state for, pruning the rest (nil = retain all)
+	SnapshotRetention  uint64           // Number of recent blocks to retain the ebakusdb snapshots of (0 = retain all)
+	SnapshotCheckpoint uint64           // Interval of the blocks whose ebakusdb snapshots are retained past the window (0 = none)
+
+	ReceiptRetention uint64           // Number of recent blocks to retain the receipts of (0 = retain all)
+	ReceiptContracts []common.Address // Contracts whose receipts are retained past the receipt retention window
+
+	ParallelWorkers int // Number of workers executing the disjoint transactions of blocks in parallel (< 2 = serial)
+
+	SchemaDryRun bool // Whether to only check the pending system schema migrations, failing the startup
 }
 
 // BlockChain represents the canonical chain given a database with a genesis
@@ -137,7 +159,7 @@ type BlockChain struct {
 	cacheConfig *CacheConfig        // Cache configuration for pruning
 
 	db      ethdb.Database // Low level persistent database to store final content in
-	stateDb *ebakusdb.DB
+	stateDb *ebkdb.DB
 	triegc  *prque.Prque  // Priority queue mapping block numbers to tries to gc
 	gcproc  time.Duration // Accumulates canonical block processing for trie dumping
 
@@ -151,7 +173,8 @@ type BlockChain struct {
 	scope         event.SubscriptionScope
 	genesisBlock  *types.Block
 
-	chainmu sync.RWMutex // blockchain insertion lock
+	chainmu  sync.RWMutex   // blockchain insertion lock
+	ebakusmu priolock.Mutex // ebakusdb snapshots access lock, prioritizing import over reads
 
 	currentBlock     atomic.Value // Current head of the block chain
 	currentFastBlock atomic.Value // Current head of the fast-sync chain (may be above the block chain!)
@@ -184,7 +207,7 @@ type BlockChain struct {
 // NewBlockChain returns a fully initialised block chain using information
 // available in the database. It initialises the default Ebakus Validator and
 // Processor.
-func NewBlockChain(db ethdb.Database, stateDb *ebakusdb.DB, cacheConfig *CacheConfig, chainConfig *params.ChainConfig, engine consensus.Engine, vmConfig vm.Config, shouldPreserve func(block *types.Block) bool) (*BlockChain, error) {
+func NewBlockChain(db ethdb.Database, stateDb *ebkdb.DB, cacheConfig *CacheConfig, chainConfig *params.ChainConfig, engine consensus.Engine, vmConfig vm.Config, shouldPreserve func(block *types.Block) bool) (*BlockChain, error) {
 	if cacheConfig == nil {
 		cacheConfig = &CacheConfig{
 			TrieCleanLimit: 256,
@@ -219,6 +242,9 @@ func NewBlockChain(db ethdb.Database, stateDb *ebakusdb.DB, cacheConfig *CacheCo
 		vmConfig:       vmConfig,
 		badBlocks:      badBlocks,
 	}
+	bc.sanitizeSnapshotRetention()
+	bc.sanitizeReceiptRetention()
+
 	bc.validator = NewBlockValidator(chainConfig, bc, engine)
 	bc.prefetcher = newStatePrefetcher(chainConfig, bc, engine)
 	bc.processor = NewStateProcessor(chainConfig, bc, engine)
@@ -245,6 +271,11 @@ func NewBlockChain(db ethdb.Database, stateDb *ebakusdb.DB, cacheConfig *CacheCo
 	if err := bc.loadLastState(); err != nil {
 		return nil, err
 	}
+	if bc.chainConfig.DPOS != nil {
+		if err := bc.checkSystemSchema(); err != nil {
+			return nil, err
+		}
+	}
 	// The first thing the node will do is reconstruct the verification data for
 	// the head block (ethash cache or clique voting snapshot). Might as well do
 	// it in advance.
@@ -393,17 +424,19 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	bc.chainmu.Lock()
 	defer bc.chainmu.Unlock()
 
+	previous := bc.CurrentHeader().Number.Uint64()
+
 	updateFn := func(db ethdb.KeyValueWriter, header *types.Header) {
-		// Rewind the block chain, ensuring we don't end up with a stateless head block
+		// Rewind the block chain, ensuring we don't end up with a stateless head block.
+		// The states of the blocks rewound past aren't checked, as the pruned ones
+		// would reset the chain to genesis.
 		if currentBlock := bc.CurrentBlock(); currentBlock != nil && header.Number.Uint64() < currentBlock.NumberU64() {
 			newHeadBlock := bc.GetBlock(header.Hash(), header.Number.Uint64())
 			if newHeadBlock == nil {
 				newHeadBlock = bc.genesisBlock
-			} else {
-				if _, err := state.New(newHeadBlock.Root(), bc.stateCache); err != nil {
-					// Rewound state missing, rolled back to before pivot, reset to genesis
-					newHeadBlock = bc.genesisBlock
-				}
+			} else if header.Number.Uint64() == head && !bc.hasRewindState(newHeadBlock) {
+				// Rewound state missing, rolled back to before pivot, reset to genesis
+				newHeadBlock = bc.genesisBlock
 			}
 			rawdb.WriteHeadBlockHash(db, newHeadBlock.Hash())
 			bc.currentBlock.Store(newHeadBlock)
@@ -425,6 +458,12 @@ func (bc *BlockChain) SetHead(head uint64) error {
 
 	// Rewind the header chain, deleting all block bodies until then
 	delFn := func(db ethdb.KeyValueWriter, hash common.Hash, num uint64) {
+		// Release the ebakus snapshot before the ancient store forgets it, and
+		// drop its mapping so nothing resolves the released id
+		if id := rawdb.ReadSnapshot(bc.db, hash, num); id != nil {
+			ebkdb.ReleasePersisted(bc.stateDb, *id)
+			rawdb.DeleteSnapshot(db, hash)
+		}
 		// Ignore the error here since light client won't hit this path
 		frozen, _ := bc.db.Ancients()
 		if num+1 <= frozen {
@@ -445,7 +484,11 @@ func (bc *BlockChain) SetHead(head uint64) error {
 		}
 		// Todo(rjl493456442) txlookup, bloombits, etc
 	}
+	// Hold the ebakus states while the snapshots of the rewound blocks are released
+	ebakusImportWaitTimer.Update(bc.ebakusmu.Lock())
 	bc.hc.SetHead(head, updateFn, delFn)
+	bc.releaseSideSnapshots(head+1, previous)
+	bc.ebakusmu.Unlock()
 
 	// Clear out any stale content from the caches
 	bc.bodyCache.Purge()
@@ -455,7 +498,12 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	bc.txLookupCache.Purge()
 	bc.futureBlocks.Purge()
 
-	return bc.loadLastState()
+	if err := bc.loadLastState(); err != nil {
+		return err
+	}
+	// Reset the transaction pool and the miner to the new head
+	bc.chainHeadFeed.Send(ChainHeadEvent{Block: bc.CurrentBlock()})
+	return nil
 }
 
 // FastSyncCommitHead sets the current head block to the one defined by the hash
@@ -512,7 +560,7 @@ func (bc *BlockChain) State() (*state.StateDB, error) {
 }
 
 // EbakusState returns a new mutable state based on the current HEAD block.
-func (bc *BlockChain) EbakusState() (*ebakusdb.Snapshot, error) {
+func (bc *BlockChain) EbakusState() (ebkdb.State, error) {
 	return bc.EbakusStateAt(bc.CurrentBlock().Hash(), bc.CurrentBlock().NumberU64())
 }
 
@@ -522,13 +570,40 @@ func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
 }
 
 // EbakusStateAt returns a new mutable state based on a particular point in time.
-func (bc *BlockChain) EbakusStateAt(hash common.Hash, number uint64) (*ebakusdb.Snapshot, error) {
+func (bc *BlockChain) EbakusStateAt(hash common.Hash, number uint64) (ebkdb.State, error) {
+	// Look the snapshot up under the lock, so it can't be pruned meanwhile
+	ebakusImportWaitTimer.Update(bc.ebakusmu.Lock())
+	defer bc.ebakusmu.Unlock()
+
 	snapID := rawdb.ReadSnapshot(bc.db, hash, number)
 	if snapID == nil {
 		return nil, fmt.Errorf("Snapshot not found")
 	}
 
-	return bc.stateDb.Snapshot(*snapID), nil
+	return ebkdb.NewState(bc.stateDb.Snapshot(*snapID)), nil
+}
+
+// ReadEbakusStateAt is like EbakusStateAt, but yields to block import and
+// production. It is meant for serving reads, like RPC calls.
+func (bc *BlockChain) ReadEbakusStateAt(hash common.Hash, number uint64) (ebkdb.State, error) {
+	// Look the snapshot up under the lock, so it can't be pruned meanwhile
+	bc.ebakusmu.LockLow()
+	defer bc.ebakusmu.Unlock()
+
+	snapID := rawdb.ReadSnapshot(bc.db, hash, number)
+	if snapID == nil {
+		return nil, fmt.Errorf("Snapshot not found")
+	}
+
+	return ebkdb.NewState(bc.stateDb.Snapshot(*snapID)), nil
+}
+
+// WriteEbakusState stores the ebakus state of a block imported without being
+// executed, like the pivot block of a snapshot sync.
+func (bc *BlockChain) WriteEbakusState(hash common.Hash, ebakusState ebkdb.State) {
+	ebakusImportWaitTimer.Update(bc.ebakusmu.Lock())
+	rawdb.WriteSnapshot(bc.db, hash, ebkdb.Persist(ebakusState))
+	bc.ebakusmu.Unlock()
 }
 
 // StateCache returns the caching database underpinning the blockchain instance.
@@ -1256,7 +1331,7 @@ func (bc *BlockChain) writeKnownBlock(block *types.Block) error {
 }
 
 // WriteBlockWithState writes the block and all associated state to the database.
-func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.Receipt, state *state.StateDB, ebakusState *ebakusdb.Snapshot) (status WriteStatus, err error) {
+func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.Receipt, state *state.StateDB, ebakusState ebkdb.State) (status WriteStatus, err error) {
 	bc.chainmu.Lock()
 	defer bc.chainmu.Unlock()
 
@@ -1265,7 +1340,7 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 
 // writeBlockWithState writes the block and all associated state to the database,
 // but is expects the chain mutex to be held.
-func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.Receipt, state *state.StateDB, ebakusState *ebakusdb.Snapshot) (status WriteStatus, err error) {
+func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.Receipt, state *state.StateDB, ebakusState ebkdb.State) (status WriteStatus, err error) {
 	bc.wg.Add(1)
 	defer bc.wg.Done()
 
@@ -1369,7 +1444,28 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 		return NonStatTy, err
 	}
 
-	rawdb.WriteSnapshot(bc.db, block.Hash(), ebakusState.Snapshot().GetId())
+	ebakusImportWaitTimer.Update(bc.ebakusmu.Lock())
+	commitStart := time.Now()
+	rawdb.WriteSnapshot(bc.db, block.Hash(), ebkdb.Persist(ebakusState))
+	ebakusCommitTimer.UpdateSince(commitStart)
+	bc.ebakusmu.Unlock()
+
+	// In partial archive mode, prune the snapshot of the block leaving the
+	// reorg window from the tables of the contracts not archived
+	if bc.cacheConfig.ArchiveContracts != nil && status == CanonStatTy && block.NumberU64() > TriesInMemory {
+		chosen := block.NumberU64() - TriesInMemory
+		bc.pruneEbakusSnapshot(bc.GetCanonicalHash(chosen), chosen)
+	}
+	// Release the snapshots of the blocks leaving the retention window, apart
+	// from the checkpoint ones
+	if bc.snapshotPruning() && status == CanonStatTy && block.NumberU64() > bc.cacheConfig.SnapshotRetention {
+		bc.releaseEbakusSnapshots(block.NumberU64() - bc.cacheConfig.SnapshotRetention)
+	}
+	// Prune the receipts of the blocks leaving the receipt retention window,
+	// apart from the ones of the retained contracts
+	if bc.receiptPruning() && status == CanonStatTy && block.NumberU64() > bc.cacheConfig.ReceiptRetention {
+		bc.pruneReceipts(block.NumberU64() - bc.cacheConfig.ReceiptRetention)
+	}
 
 	// Set new head.
 	if status == CanonStatTy {
@@ -1594,12 +1690,14 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, []
 		if snapID == nil {
 			return it.index, events, coalescedLogs, fmt.Errorf("State snapshot for parent block %s not found", block.ParentHash())
 		}
-		parentSnapshot := bc.stateDb.Snapshot(*snapID)
-		defer parentSnapshot.Release()
+		ebakusImportWaitTimer.Update(bc.ebakusmu.Lock())
+		parentSnapshot := ebkdb.NewState(bc.stateDb.Snapshot(*snapID))
+		bc.ebakusmu.Unlock()
 
 		// Get the coinbase
 		coinbase, err := bc.engine.Author(block.Header())
 		if err != nil {
+			parentSnapshot.Release()
 			return it.index, events, coalescedLogs, err
 		}
 
@@ -1609,9 +1707,11 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, []
 
 		if !bc.cacheConfig.TrieCleanNoPrefetch {
 			if followup, err := it.peek(); followup != nil && err == nil {
+				// Branch the ebakus state before processing modifies it
+				throwawaySnapshot := parentSnapshot.Snapshot()
+
 				go func(start time.Time) {
 					throwaway, _ := state.New(parent.Root, bc.stateCache)
-					throwawaySnapshot := parentSnapshot.Snapshot()
 
 					bc.prefetcher.Prefetch(followup, throwaway, throwawaySnapshot, bc.vmConfig, &followupInterrupt)
 
@@ -1630,6 +1730,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, []
 		if err != nil {
 			bc.reportBlock(block, receipts, err)
 			atomic.StoreUint32(&followupInterrupt, 1)
+			parentSnapshot.Release()
 			return it.index, events, coalescedLogs, err
 		}
 		// Update the metrics touched during block processing
@@ -1649,6 +1750,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, []
 		if err := bc.validator.ValidateState(block, statedb, receipts, usedGas); err != nil {
 			bc.reportBlock(block, receipts, err)
 			atomic.StoreUint32(&followupInterrupt, 1)
+			parentSnapshot.Release()
 			return it.index, events, coalescedLogs, err
 		}
 		proctime := time.Since(start)
@@ -1662,11 +1764,13 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, []
 		// Write the block to the chain and get the status.
 		substart = time.Now()
 		status, err := bc.writeBlockWithState(block, receipts, statedb, parentSnapshot)
+		atomic.StoreUint32(&followupInterrupt, 1)
+
+		// Release the snapshot per block, not once the whole batch is imported
+		parentSnapshot.Release()
 		if err != nil {
-			atomic.StoreUint32(&followupInterrupt, 1)
 			return it.index, events, coalescedLogs, err
 		}
-		atomic.StoreUint32(&followupInterrupt, 1)
 
 		// Update the metrics touched during block commit
 		accountCommitTimer.Update(statedb.AccountCommits) // Account commits are complete, we can mark them
@@ -1674,6 +1778,8 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, []
 
 		blockWriteTimer.Update(time.Since(substart) - statedb.AccountCommits - statedb.StorageCommits)
 		blockInsertTimer.UpdateSince(start)
+		blockTxsMeter.Mark(int64(len(block.Transactions())))
+		blockGasMeter.Mark(int64(usedGas))
 
 		switch status {
 		case CanonStatTy:
@@ -2023,10 +2129,14 @@ func (bc *BlockChain) PostChainEvents(events []interface{}, logs []*types.Log) {
 func (bc *BlockChain) update() {
 	futureTimer := time.NewTicker(5 * time.Second)
 	defer futureTimer.Stop()
+	leakTimer := time.NewTicker(snapshotLeakInterval)
+	defer leakTimer.Stop()
 	for {
 		select {
 		case <-futureTimer.C:
 			bc.procFutureBlocks()
+		case <-leakTimer.C:
+			ebkdb.ReportLeakedSnapshots(snapshotLeakAge)
 		case <-bc.quit:
 			return
 		}
@@ -236,6 +236,13 @@ func (c *ChainIndexer) eventLoop(currentHeader *types.Header, events chan ChainH
 	}
 }
 
+// Rewind invalidates the sections past the given head, after the chain was
+// rewound with SetHead. Unlike reorgs, the rewinds can't be detected from the
+// chain head events, as the rewound headers are deleted.
+func (c *ChainIndexer) Rewind(head uint64) {
+	c.newHead(head, true)
+}
+
 // newHead notifies the indexer about new chain heads and/or reorgs.
 func (c *ChainIndexer) newHead(head uint64, reorg bool) {
 	c.lock.Lock()
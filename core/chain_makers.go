@@ -20,9 +20,9 @@ import (
 	"fmt"
 	"math/big"
 
-	"github.com/ebakus/ebakusdb"
 	"github.com/ebakus/go-ebakus/common"
 	"github.com/ebakus/go-ebakus/consensus"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/state"
 	"github.com/ebakus/go-ebakus/core/types"
 	"github.com/ebakus/go-ebakus/core/vm"
@@ -40,7 +40,7 @@ type BlockGen struct {
 	header      *types.Header
 	coinbase    common.Address
 	statedb     *state.StateDB
-	ebakusState *ebakusdb.Snapshot
+	ebakusState ebkdb.State
 
 	gasPool  *GasPool
 	txs      []*types.Transaction
@@ -169,7 +169,7 @@ func (b *BlockGen) OffsetTime(seconds int64) {
 // Blocks created by GenerateChain do not contain valid proof of work
 // values. Inserting them into BlockChain requires use of FakePow or
 // a similar non-validating proof of work implementation.
-func GenerateChain(config *params.ChainConfig, parent *types.Block, engine consensus.Engine, db ethdb.Database, ebakusDb *ebakusdb.DB, n int, gen func(int, *BlockGen)) ([]*types.Block, []types.Receipts) {
+func GenerateChain(config *params.ChainConfig, parent *types.Block, engine consensus.Engine, db ethdb.Database, ebakusDb *ebkdb.DB, n int, gen func(int, *BlockGen)) ([]*types.Block, []types.Receipts) {
 	if config == nil {
 		config = params.TestChainConfig
 	}
@@ -185,7 +185,7 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, engine conse
 		}
 		if b.engine != nil {
 			// Finalize and seal the block
-			ebakusSnapshot := ebakusDb.GetRootSnapshot()
+			ebakusSnapshot := ebkdb.NewState(ebakusDb.GetRootSnapshot())
 			block, _ := b.engine.FinalizeAndAssemble(chainreader, b.header, statedb, ebakusSnapshot, b.coinbase, b.txs, b.receipts)
 			ebakusSnapshot.Release()
 
@@ -232,7 +232,7 @@ func makeHeader(chain consensus.ChainReader, parent *types.Block, state *state.S
 }
 
 // makeHeaderChain creates a deterministic chain of headers rooted at parent.
-func makeHeaderChain(parent *types.Header, n int, engine consensus.Engine, db ethdb.Database, ebakusDb *ebakusdb.DB, seed int) []*types.Header {
+func makeHeaderChain(parent *types.Header, n int, engine consensus.Engine, db ethdb.Database, ebakusDb *ebkdb.DB, seed int) []*types.Header {
 	blocks := makeBlockChain(types.NewBlockWithHeader(parent), n, engine, db, ebakusDb, seed)
 	headers := make([]*types.Header, len(blocks))
 	for i, block := range blocks {
@@ -242,7 +242,7 @@ func makeHeaderChain(parent *types.Header, n int, engine consensus.Engine, db et
 }
 
 // makeBlockChain creates a deterministic chain of blocks rooted at parent.
-func makeBlockChain(parent *types.Block, n int, engine consensus.Engine, db ethdb.Database, ebakusDb *ebakusdb.DB, seed int) []*types.Block {
+func makeBlockChain(parent *types.Block, n int, engine consensus.Engine, db ethdb.Database, ebakusDb *ebkdb.DB, seed int) []*types.Block {
 	blocks, _ := GenerateChain(params.TestChainConfig, parent, engine, db, ebakusDb, n, func(i int, b *BlockGen) {
 		b.SetCoinbase(common.Address{0: byte(seed), 19: byte(i)})
 	})
@@ -266,6 +266,6 @@ func (cr *fakeChainReader) GetHeader(hash common.Hash, number uint64) *types.Hea
 func (cr *fakeChainReader) GetBlock(hash common.Hash, number uint64) *types.Block   { return nil }
 func (cr *fakeChainReader) CurrentBlock() *types.Block                              { return nil }
 func (cr *fakeChainReader) StateAt(hash common.Hash) (*state.StateDB, error)        { return nil, nil }
-func (cr *fakeChainReader) EbakusStateAt(hash common.Hash, number uint64) (*ebakusdb.Snapshot, error) {
+func (cr *fakeChainReader) EbakusStateAt(hash common.Hash, number uint64) (ebkdb.State, error) {
 	return nil, nil
 }
@@ -0,0 +1,80 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/core/rawdb"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/log"
+)
+
+// CheckRewind verifies that the chain can be rewound to the given block without
+// losing the head state, which SetHead would otherwise reset to genesis. The
+// block must be canonical and have both its state trie and its ebakusdb snapshot
+// retained, which the pruning nodes only do for the recent and checkpoint blocks.
+func (bc *BlockChain) CheckRewind(head uint64) error {
+	current := bc.CurrentBlock().NumberU64()
+	if head > current {
+		return fmt.Errorf("block #%d is ahead of the head block #%d", head, current)
+	}
+	hash := rawdb.ReadCanonicalHash(bc.db, head)
+	if hash == (common.Hash{}) {
+		return fmt.Errorf("block #%d not found", head)
+	}
+	block := bc.GetBlock(hash, head)
+	if block == nil {
+		return fmt.Errorf("block #%d body missing", head)
+	}
+	if !bc.hasRewindState(block) {
+		return fmt.Errorf("state of block #%d not retained", head)
+	}
+	return nil
+}
+
+// hasRewindState reports whether a block has both its state trie and its ebakusdb
+// snapshot, so the chain can be rewound to it.
+func (bc *BlockChain) hasRewindState(block *types.Block) bool {
+	if !bc.HasState(block.Root()) {
+		return false
+	}
+	return rawdb.ReadSnapshot(bc.db, block.Hash(), block.NumberU64()) != nil
+}
+
+// releaseSideSnapshots releases the ebakusdb snapshots of the side chain blocks
+// in the given range, left behind by a rewind of the canonical chain. The caller
+// must hold ebakusmu.
+func (bc *BlockChain) releaseSideSnapshots(from, to uint64) {
+	var released int
+	for number := from; number <= to; number++ {
+		for _, hash := range rawdb.ReadAllHashes(bc.db, number) {
+			id := rawdb.ReadSnapshot(bc.db, hash, number)
+			if id == nil {
+				continue
+			}
+			rawdb.DeleteSnapshot(bc.db, hash)
+			ebkdb.ReleasePersisted(bc.stateDb, *id)
+			released++
+		}
+	}
+	if released > 0 {
+		log.Debug("Released rewound side chain snapshots", "from", from, "to", to, "count", released)
+	}
+}
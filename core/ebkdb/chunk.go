@@ -0,0 +1,360 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package ebkdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/rlp"
+)
+
+var (
+	errChunkUnavailable = errors.New("snapshot chunk unavailable")
+	errChunkMismatch    = errors.New("snapshot chunk keys and values mismatch")
+	errChunkOrigin      = errors.New("snapshot chunk starts before its origin")
+	errChunkOrder       = errors.New("snapshot chunk keys out of order")
+	errChunkKey         = errors.New("malformed snapshot chunk key")
+)
+
+// The groups the keys of the snapshot chunks start with, ordering the entries
+// of the global keys before those of the system tables, and those before the
+// ones of the contract tables, whose rows are typed after the system ones.
+const (
+	chunkGlobalGroup   byte = iota // Raw keys outside of any table
+	chunkSystemGroup               // Rows of the system tables
+	chunkContractGroup             // Rows of the contract tables
+)
+
+// SnapshotChunk is a range of the entries of an ebakusdb snapshot, in key order.
+// The ebakus state of a block is transferred between nodes as a sequence of
+// chunks, each one starting right after the last key of the previous one.
+//
+// As ebakusdb can only be walked table by table, the keys are those of a
+// logical layout of the snapshot rather than the raw ones: the registered
+// global keys, followed by the tables, in the order ListTables returns them.
+// Each table has a header entry, holding its indexed fields, followed by an
+// entry per row, keyed by its position in the table and holding it gob encoded.
+type SnapshotChunk struct {
+	Keys   [][]byte
+	Values [][]byte
+	More   bool // Whether the snapshot has entries past the last key
+}
+
+// globalChunkKey returns the chunk key of a global key.
+func globalChunkKey(key string) []byte {
+	return append([]byte{chunkGlobalGroup}, key...)
+}
+
+// tableChunkKey returns the chunk key of the header entry of a table, which
+// prefixes the keys of its rows.
+func tableChunkKey(table Table) []byte {
+	group := chunkContractGroup
+	if table.System {
+		group = chunkSystemGroup
+	}
+	key := append([]byte{group}, table.Name...)
+	return append(key, 0x00)
+}
+
+// rowChunkKey returns the chunk key of the row at the given position of a table.
+func rowChunkKey(prefix []byte, position uint64) []byte {
+	key := make([]byte, len(prefix)+8)
+	copy(key, prefix)
+	binary.BigEndian.PutUint64(key[len(prefix):], position)
+	return key
+}
+
+// splitChunkKey splits a table chunk key into the name of the table and the
+// position of the row, reporting whether it's the key of the table header.
+func splitChunkKey(key []byte) (string, uint64, bool, error) {
+	if len(key) == 0 || (key[0] != chunkSystemGroup && key[0] != chunkContractGroup) {
+		return "", 0, false, errChunkKey
+	}
+	end := bytes.IndexByte(key[1:], 0x00)
+	if end <= 0 {
+		return "", 0, false, errChunkKey
+	}
+	name, rest := string(key[1:1+end]), key[2+end:]
+	switch len(rest) {
+	case 0:
+		return name, 0, true, nil
+	case 8:
+		return name, binary.BigEndian.Uint64(rest), false, nil
+	default:
+		return "", 0, false, errChunkKey
+	}
+}
+
+// tableOrigin returns the position of the first row of a table at or past the
+// origin, whether the table header is past it too, and whether the whole table
+// is before the origin.
+func tableOrigin(prefix []byte, origin []byte) (uint64, bool, bool) {
+	if bytes.Compare(origin, prefix) <= 0 {
+		return 0, true, false
+	}
+	if !bytes.HasPrefix(origin, prefix) {
+		return 0, false, true
+	}
+	suffix := origin[len(prefix):]
+
+	var position [8]byte
+	copy(position[:], suffix)
+	first := binary.BigEndian.Uint64(position[:])
+	if len(suffix) > 8 {
+		first++
+	}
+	return first, false, false
+}
+
+// tableIndexes returns the indexed fields of a table, besides the Id.
+func tableIndexes(state State, table Table) []string {
+	indexes := []string{}
+	for i := 0; i < table.Row.NumField(); i++ {
+		if field := table.Row.Field(i).Name; field != "Id" && HasIndex(state, table.Name, field) {
+			indexes = append(indexes, field)
+		}
+	}
+	return indexes
+}
+
+// ReadSnapshotChunk reads the entries of the state starting at origin, until
+// about limit bytes of keys and values are gathered.
+func ReadSnapshotChunk(state State, origin []byte, limit uint64) (*SnapshotChunk, error) {
+	var (
+		chunk = new(SnapshotChunk)
+		size  uint64
+	)
+	// add appends an entry to the chunk, reporting whether there's room for more
+	add := func(key, value []byte) bool {
+		if size >= limit && len(chunk.Keys) > 0 {
+			chunk.More = true
+			return false
+		}
+		chunk.Keys = append(chunk.Keys, key)
+		chunk.Values = append(chunk.Values, value)
+		size += uint64(len(key) + len(value))
+		return true
+	}
+	for _, key := range GlobalKeys() {
+		chunkKey := globalChunkKey(key)
+		if bytes.Compare(chunkKey, origin) < 0 {
+			continue
+		}
+		if value, found := state.Get([]byte(key)); found {
+			if !add(chunkKey, common.CopyBytes(*value)) {
+				return chunk, nil
+			}
+		}
+	}
+	tables, err := ListTables(state)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range tables {
+		if !state.HasTable(table.Name) {
+			continue
+		}
+		prefix := tableChunkKey(table)
+
+		first, header, before := tableOrigin(prefix, origin)
+		if before {
+			continue
+		}
+		if header {
+			indexes, err := rlp.EncodeToBytes(tableIndexes(state, table))
+			if err != nil {
+				return nil, err
+			}
+			if !add(prefix, indexes) {
+				return chunk, nil
+			}
+		}
+		more, err := readTableRows(state, table, prefix, first, add)
+		if err != nil || !more {
+			return chunk, err
+		}
+	}
+	return chunk, nil
+}
+
+// readTableRows adds the rows of a table starting at the given position to a
+// chunk, reporting whether there's room for more.
+func readTableRows(state State, table Table, prefix []byte, first uint64, add func(key, value []byte) bool) (bool, error) {
+	iter, err := state.Select(table.Name)
+	if err != nil {
+		return false, err
+	}
+	defer iter.Release()
+
+	for position, row := uint64(0), reflect.New(table.Row).Interface(); iter.Next(row); position++ {
+		if position < first {
+			continue
+		}
+		buf := new(bytes.Buffer)
+		if err := gob.NewEncoder(buf).Encode(row); err != nil {
+			return false, err
+		}
+		if !add(rowChunkKey(prefix, position), buf.Bytes()) {
+			return false, nil
+		}
+		row = reflect.New(table.Row).Interface()
+	}
+	return true, nil
+}
+
+// Verify checks that the chunk is a well formed range of entries starting at
+// origin. As a snapshot always holds the system tables, an empty chunk means
+// the peer doesn't have the snapshot.
+func (c *SnapshotChunk) Verify(origin []byte) error {
+	if len(c.Keys) == 0 {
+		return errChunkUnavailable
+	}
+	if len(c.Keys) != len(c.Values) {
+		return errChunkMismatch
+	}
+	if bytes.Compare(c.Keys[0], origin) < 0 {
+		return errChunkOrigin
+	}
+	for i := 1; i < len(c.Keys); i++ {
+		if bytes.Compare(c.Keys[i-1], c.Keys[i]) >= 0 {
+			return errChunkOrder
+		}
+	}
+	return nil
+}
+
+// Next returns the origin of the chunk following this one, or nil if this is
+// the last chunk of the snapshot.
+func (c *SnapshotChunk) Next() []byte {
+	if !c.More || len(c.Keys) == 0 {
+		return nil
+	}
+	last := c.Keys[len(c.Keys)-1]
+	return append(common.CopyBytes(last), 0x00)
+}
+
+// ApplySnapshotChunk writes a verified chunk into the state. The global keys
+// in the range of the chunk that it doesn't hold are deleted, and so are the
+// rows of a table when its header is applied, so applying all the chunks of a
+// snapshot in order turns any base state into a copy of it.
+func ApplySnapshotChunk(state State, origin []byte, chunk *SnapshotChunk) error {
+	next := chunk.Next()
+
+	held := make(map[string]struct{}, len(chunk.Keys))
+	for _, key := range chunk.Keys {
+		held[string(key)] = struct{}{}
+	}
+	for _, key := range GlobalKeys() {
+		chunkKey := globalChunkKey(key)
+		if bytes.Compare(chunkKey, origin) < 0 || (next != nil && bytes.Compare(chunkKey, next) >= 0) {
+			continue
+		}
+		if _, ok := held[string(chunkKey)]; ok {
+			continue
+		}
+		if _, found := state.Get([]byte(key)); found {
+			if err := state.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+	}
+	// The row types are looked up lazily, as the contract ones are only known
+	// once the system tables holding their ABIs are applied
+	rows := make(map[string]reflect.Type)
+	rowType := func(name string) (reflect.Type, error) {
+		if typ, ok := rows[name]; ok {
+			return typ, nil
+		}
+		tables, err := ListTables(state)
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range tables {
+			rows[table.Name] = table.Row
+		}
+		if typ, ok := rows[name]; ok {
+			return typ, nil
+		}
+		return nil, fmt.Errorf("snapshot chunk table %s unknown", name)
+	}
+	for i, key := range chunk.Keys {
+		if len(key) > 0 && key[0] == chunkGlobalGroup {
+			if err := state.Insert(key[1:], chunk.Values[i]); err != nil {
+				return err
+			}
+			continue
+		}
+		name, _, header, err := splitChunkKey(key)
+		if err != nil {
+			return err
+		}
+		if header {
+			if err := applyTableHeader(state, name, chunk.Values[i], rowType); err != nil {
+				return err
+			}
+			continue
+		}
+		typ, err := rowType(name)
+		if err != nil {
+			return err
+		}
+		row := reflect.New(typ).Interface()
+		if err := gob.NewDecoder(bytes.NewReader(chunk.Values[i])).Decode(row); err != nil {
+			return err
+		}
+		if err := state.InsertObj(name, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyTableHeader creates a table along with its indexes, or empties it if it
+// already exists, so it only holds the rows that follow.
+func applyTableHeader(state State, name string, value []byte, rowType func(string) (reflect.Type, error)) error {
+	var indexes []string
+	if err := rlp.DecodeBytes(value, &indexes); err != nil {
+		return err
+	}
+	if state.HasTable(name) {
+		typ, err := rowType(name)
+		if err != nil {
+			return err
+		}
+		_, err = ClearTable(state, name, typ)
+		return err
+	}
+	typ, err := rowType(name)
+	if err != nil {
+		return err
+	}
+	if err := state.CreateTable(name, reflect.New(typ).Interface()); err != nil {
+		return err
+	}
+	for _, index := range indexes {
+		if err := state.CreateIndex(IndexField{Table: name, Field: index}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
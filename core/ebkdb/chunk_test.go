@@ -0,0 +1,116 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package ebkdb
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ebakus/go-ebakus/common"
+)
+
+func TestSnapshotChunkVerify(t *testing.T) {
+	tests := []struct {
+		origin []byte
+		chunk  *SnapshotChunk
+		err    error
+	}{
+		{nil, &SnapshotChunk{Keys: [][]byte{{1}, {2}}, Values: [][]byte{{}, {}}}, nil},
+		{[]byte{1}, &SnapshotChunk{Keys: [][]byte{{1}, {1, 0}}, Values: [][]byte{{}, {}}, More: true}, nil},
+		{nil, &SnapshotChunk{}, errChunkUnavailable},
+		{nil, &SnapshotChunk{More: true}, errChunkUnavailable},
+		{nil, &SnapshotChunk{Keys: [][]byte{{1}, {2}}, Values: [][]byte{{}}}, errChunkMismatch},
+		{[]byte{2}, &SnapshotChunk{Keys: [][]byte{{1}, {2}}, Values: [][]byte{{}, {}}}, errChunkOrigin},
+		{nil, &SnapshotChunk{Keys: [][]byte{{2}, {1}}, Values: [][]byte{{}, {}}}, errChunkOrder},
+		{nil, &SnapshotChunk{Keys: [][]byte{{1}, {1}}, Values: [][]byte{{}, {}}}, errChunkOrder},
+	}
+	for i, test := range tests {
+		if err := test.chunk.Verify(test.origin); err != test.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+		}
+	}
+}
+
+func TestSnapshotChunkNext(t *testing.T) {
+	chunk := &SnapshotChunk{Keys: [][]byte{{1}, {2, 3}}, Values: [][]byte{{}, {}}}
+	if next := chunk.Next(); next != nil {
+		t.Errorf("last chunk has a next origin: %x", next)
+	}
+	chunk.More = true
+	next := chunk.Next()
+	if !bytes.Equal(next, []byte{2, 3, 0}) {
+		t.Fatalf("next origin mismatch: have %x, want %x", next, []byte{2, 3, 0})
+	}
+	// The next origin must sort right after the last key
+	if bytes.Compare(chunk.Keys[1], next) >= 0 {
+		t.Errorf("next origin %x not past the last key %x", next, chunk.Keys[1])
+	}
+	next[0] = 0xff
+	if chunk.Keys[1][0] != 2 {
+		t.Errorf("next origin aliases the last key")
+	}
+}
+
+func TestSnapshotChunkKeys(t *testing.T) {
+	table := Table{Name: "Rows", System: true}
+	prefix := tableChunkKey(table)
+
+	name, _, header, err := splitChunkKey(prefix)
+	if err != nil || name != "Rows" || !header {
+		t.Fatalf("header key split mismatch: have %q, %v, %v", name, header, err)
+	}
+	key := rowChunkKey(prefix, 7)
+	name, position, header, err := splitChunkKey(key)
+	if err != nil || name != "Rows" || position != 7 || header {
+		t.Fatalf("row key split mismatch: have %q, %d, %v, %v", name, position, header, err)
+	}
+	if _, _, _, err := splitChunkKey(globalChunkKey("key")); err != errChunkKey {
+		t.Errorf("global key split as a table one: %v", err)
+	}
+	// The rows must sort after the header and before the next table
+	next := tableChunkKey(Table{Name: "RowsMore", System: true})
+	if bytes.Compare(prefix, key) >= 0 || bytes.Compare(key, next) >= 0 {
+		t.Errorf("row key %x not between the header %x and the next table %x", key, prefix, next)
+	}
+	if bytes.Compare(next, tableChunkKey(Table{Name: "A"})) >= 0 {
+		t.Errorf("system table not sorted before the contract ones")
+	}
+}
+
+func TestSnapshotChunkTableOrigin(t *testing.T) {
+	prefix := tableChunkKey(Table{Name: "Rows", System: true})
+
+	tests := []struct {
+		origin []byte
+		first  uint64
+		header bool
+		before bool
+	}{
+		{nil, 0, true, false},
+		{prefix, 0, true, false},
+		{append(common.CopyBytes(prefix), 0x00), 0, false, false},
+		{rowChunkKey(prefix, 3), 3, false, false},
+		{append(rowChunkKey(prefix, 3), 0x00), 4, false, false},
+		{tableChunkKey(Table{Name: "RowsMore", System: true}), 0, false, true},
+	}
+	for i, test := range tests {
+		first, header, before := tableOrigin(prefix, test.origin)
+		if first != test.first || header != test.header || before != test.before {
+			t.Errorf("test %d: origin mismatch: have %d, %v, %v, want %d, %v, %v", i, first, header, before, test.first, test.header, test.before)
+		}
+	}
+}
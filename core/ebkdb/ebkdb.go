@@ -0,0 +1,61 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package ebkdb is the single entry point to the ebakusdb module. The rest of
+// the codebase only refers to the types and constructors aliased here, so the
+// underlying module (e.g. a fork of it) can be swapped by changing the import
+// of this file alone.
+package ebkdb
+
+import (
+	"os"
+
+	"github.com/ebakus/ebakusdb"
+)
+
+type (
+	// DB is an ebakusdb database.
+	DB = ebakusdb.DB
+
+	// Options are the ebakusdb database options.
+	Options = ebakusdb.Options
+
+	// Snapshot is a copy on write view of the database, the ebakus state of a
+	// block.
+	Snapshot = ebakusdb.Snapshot
+
+	// ResultIterator iterates over the rows of a table select.
+	ResultIterator = ebakusdb.ResultIterator
+
+	// IndexField describes a table index.
+	IndexField = ebakusdb.IndexField
+
+	// WhereField is a parsed where clause.
+	WhereField = ebakusdb.WhereField
+
+	// OrderField is a parsed order clause.
+	OrderField = ebakusdb.OrderField
+)
+
+// Open opens the database at path, creating it if needed.
+func Open(path string, mode os.FileMode, options *Options) (*DB, error) {
+	return ebakusdb.Open(path, mode, options)
+}
+
+// OpenInMemory opens a database living in memory only.
+func OpenInMemory(options *Options) (*DB, error) {
+	return ebakusdb.OpenInMemory(options)
+}
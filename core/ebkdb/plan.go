@@ -0,0 +1,85 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package ebkdb
+
+import "strings"
+
+// ScanType is the way a select walks over the rows of a table.
+type ScanType string
+
+const (
+	ScanPoint ScanType = "point" // Looks up the rows equal to a value of an index
+	ScanRange ScanType = "range" // Walks the part of an index bounded by a comparison or prefix
+	ScanFull  ScanType = "full"  // Walks all the rows of the table
+)
+
+// QueryPlan describes how a select walks a table.
+type QueryPlan struct {
+	Table   string
+	Index   string // Field whose index is walked
+	Scan    ScanType
+	Reverse bool // Whether the index is walked in descending order
+}
+
+// Explain describes how a select with the given where and order clauses walks
+// a table, without executing it. A where clause on an indexed field bounds the
+// walk to a part of its index. Otherwise the whole index of the order field is
+// walked, that of the Id without an order clause, filtering the rows on the
+// where clause.
+func Explain(state State, table string, whereClause string, orderClause string) (*QueryPlan, error) {
+	plan := &QueryPlan{
+		Table: table,
+		Index: "Id",
+		Scan:  ScanFull,
+	}
+	if orderClause == "" {
+		orderClause = CanonicalOrder
+	}
+	if terms := strings.Fields(orderClause); len(terms) > 0 {
+		plan.Index = terms[0]
+		plan.Reverse = len(terms) > 1 && strings.EqualFold(terms[1], "DESC")
+	}
+	terms := strings.SplitN(whereClause, " ", 3)
+	if len(terms) < 3 || !HasIndex(state, table, terms[0]) {
+		return plan, nil
+	}
+	plan.Index = terms[0]
+	if terms[1] == "=" {
+		plan.Scan = ScanPoint
+	} else {
+		plan.Scan = ScanRange
+	}
+	return plan, nil
+}
+
+// HasIndex reports whether a field of a table is indexed. ebakusdb only orders
+// selects by indexed fields, so it's probed by ordering a select by the field.
+func HasIndex(state State, table string, field string) bool {
+	if field == "Id" {
+		return true
+	}
+	order, err := state.OrderParser([]byte(field + " ASC"))
+	if err != nil {
+		return false
+	}
+	iter, err := state.Select(table, (*WhereField)(nil), order)
+	if err != nil || iter == nil {
+		return false
+	}
+	iter.Release()
+	return true
+}
@@ -0,0 +1,63 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package ebkdb
+
+import "testing"
+
+type plannedRow struct {
+	Id      uint64
+	Indexed uint64
+	Plain   uint64
+}
+
+func TestExplain(t *testing.T) {
+	db, err := OpenInMemory(nil)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+
+	state := NewState(db.GetRootSnapshot())
+	defer state.Release()
+
+	if err := state.CreateTable("Planned", &plannedRow{}); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	if err := state.CreateIndex(IndexField{Table: "Planned", Field: "Indexed"}); err != nil {
+		t.Fatalf("failed to create index: %v", err)
+	}
+	tests := []struct {
+		where, order string
+		expected     QueryPlan
+	}{
+		{"", "", QueryPlan{Table: "Planned", Index: "Id", Scan: ScanFull}},
+		{"", "Indexed DESC", QueryPlan{Table: "Planned", Index: "Indexed", Scan: ScanFull, Reverse: true}},
+		{"Id = 1", "", QueryPlan{Table: "Planned", Index: "Id", Scan: ScanPoint}},
+		{"Indexed >= 1", "", QueryPlan{Table: "Planned", Index: "Indexed", Scan: ScanRange}},
+		{"Plain >= 1", "", QueryPlan{Table: "Planned", Index: "Id", Scan: ScanFull}},
+		{"Plain = 1", "Indexed ASC", QueryPlan{Table: "Planned", Index: "Indexed", Scan: ScanFull}},
+	}
+	for i, test := range tests {
+		plan, err := Explain(state, "Planned", test.where, test.order)
+		if err != nil {
+			t.Fatalf("test %d: failed to explain: %v", i, err)
+		}
+		if *plan != test.expected {
+			t.Errorf("test %d: plan mismatch: have %+v, want %+v", i, *plan, test.expected)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package ebkdb
+
+import "github.com/ebakus/go-ebakus/log"
+
+// Iterator iterates over the rows of a table select.
+type Iterator interface {
+	// Next decodes the next row into val, reporting whether there was one.
+	Next(val interface{}) bool
+
+	// Prev decodes the previous row into val, reporting whether there was one.
+	Prev(val interface{}) bool
+
+	// Release frees the resources held by the iterator.
+	Release()
+}
+
+// State is the ebakus state blocks and calls are executed against. The EVM,
+// the miner, the transaction pool and the consensus engines only depend on it,
+// so alternative backends (e.g. an in-memory test store) can be plugged in
+// place of the ebakusdb snapshots.
+type State interface {
+	// Get retrieves the raw value stored at key.
+	Get(key []byte) (*[]byte, bool)
+
+	// Insert stores a raw value at key.
+	Insert(key, value []byte) error
+
+	// Delete removes the raw value stored at key.
+	Delete(key []byte) error
+
+	// HasTable reports whether the table exists.
+	HasTable(table string) bool
+
+	// CreateTable creates a table whose rows have the fields of obj.
+	CreateTable(table string, obj interface{}) error
+
+	// CreateIndex creates an index over a field of a table.
+	CreateIndex(index IndexField) error
+
+	// InsertObj inserts a row, replacing the one with the same id.
+	InsertObj(table string, obj interface{}) error
+
+	// DeleteObj deletes the row with the given id.
+	DeleteObj(table string, id interface{}) error
+
+	// WhereParser parses a where clause for Select.
+	WhereParser(input []byte) (*WhereField, error)
+
+	// OrderParser parses an order clause for Select.
+	OrderParser(input []byte) (*OrderField, error)
+
+	// Select iterates over the rows of a table matching the parsed where and
+	// order clauses. Without an order clause the rows are iterated by ascending
+	// Id, as consensus relies on all the nodes iterating them alike.
+	Select(table string, args ...interface{}) (Iterator, error)
+
+	// Snapshot returns a copy of the state, modified independently of it.
+	Snapshot() State
+
+	// ResetTo discards the changes made after the given snapshot of the state.
+	ResetTo(snap State)
+
+	// Release frees the state.
+	Release()
+
+	// GetId returns the id the state is persisted under.
+	GetId() uint64
+
+	// GetUsedMemory returns the memory allocated by the state.
+	GetUsedMemory() uint64
+}
+
+// CanonicalOrder is the order clause of the selects without one.
+const CanonicalOrder = "Id ASC"
+
+// hasOrder reports whether the select arguments have an order clause.
+func hasOrder(args []interface{}) bool {
+	if len(args) < 2 {
+		return false
+	}
+	order, ok := args[1].(*OrderField)
+	return ok && order != nil
+}
+
+// withOrder returns the select arguments with their order clause, following
+// the where clause, replaced by order. As ebakusdb tells the clauses apart by
+// their type, a missing where clause is passed as a typed nil.
+func withOrder(args []interface{}, order *OrderField) []interface{} {
+	size := len(args)
+	if size < 2 {
+		size = 2
+	}
+	ordered := make([]interface{}, size)
+	copy(ordered, args)
+	if ordered[0] == nil {
+		ordered[0] = (*WhereField)(nil)
+	}
+	ordered[1] = order
+
+	return ordered
+}
+
+// snapshotState is the State backed by an ebakusdb snapshot.
+type snapshotState struct {
+	snap *Snapshot
+}
+
+// NewState wraps an ebakusdb snapshot into a State. A nil snapshot results in
+// a nil State. The State is tracked until released, see LiveSnapshots.
+func NewState(snap *Snapshot) State {
+	if snap == nil {
+		return nil
+	}
+	s := &snapshotState{snap: snap}
+	tracker.track(s)
+
+	return s
+}
+
+// SnapshotOf returns the ebakusdb snapshot backing a State, or nil if the
+// State has another backend.
+func SnapshotOf(state State) *Snapshot {
+	if s, ok := state.(*snapshotState); ok {
+		return s.snap
+	}
+	return nil
+}
+
+func (s *snapshotState) Get(key []byte) (*[]byte, bool)     { return s.snap.Get(key) }
+func (s *snapshotState) Insert(key, value []byte) error     { return s.snap.Insert(key, value) }
+func (s *snapshotState) Delete(key []byte) error            { return s.snap.Delete(key) }
+func (s *snapshotState) HasTable(table string) bool         { return s.snap.HasTable(table) }
+func (s *snapshotState) CreateIndex(index IndexField) error { return s.snap.CreateIndex(index) }
+
+func (s *snapshotState) DeleteObj(table string, id interface{}) error {
+	return s.snap.DeleteObj(table, id)
+}
+
+func (s *snapshotState) CreateTable(table string, obj interface{}) error {
+	return s.snap.CreateTable(table, obj)
+}
+
+func (s *snapshotState) InsertObj(table string, obj interface{}) error {
+	return s.snap.InsertObj(table, obj)
+}
+
+func (s *snapshotState) WhereParser(input []byte) (*WhereField, error) {
+	return s.snap.WhereParser(input)
+}
+
+func (s *snapshotState) OrderParser(input []byte) (*OrderField, error) {
+	return s.snap.OrderParser(input)
+}
+
+func (s *snapshotState) Select(table string, args ...interface{}) (Iterator, error) {
+	args, err := s.canonicalArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	iter, err := s.snap.Select(table, args...)
+	if iter == nil {
+		// Don't wrap a nil iterator into a non nil interface
+		return nil, err
+	}
+	return iter, err
+}
+
+// canonicalArgs returns the select arguments ordered by CanonicalOrder if they
+// have no order clause.
+func (s *snapshotState) canonicalArgs(args []interface{}) ([]interface{}, error) {
+	if hasOrder(args) {
+		return args, nil
+	}
+	order, err := s.snap.OrderParser([]byte(CanonicalOrder))
+	if err != nil {
+		return nil, err
+	}
+	return withOrder(args, order), nil
+}
+
+func (s *snapshotState) Snapshot() State { return NewState(s.snap.Snapshot()) }
+
+func (s *snapshotState) ResetTo(snap State) {
+	other := SnapshotOf(snap)
+	if other == nil {
+		panic("ebkdb: reset to a state of another backend")
+	}
+	s.snap.ResetTo(other)
+}
+
+// Release frees the snapshot. Releasing it again is reported instead of freeing
+// it twice, as it would be freed from under the other states sharing it.
+func (s *snapshotState) Release() {
+	if !tracker.untrack(s) {
+		log.Error("Ebakusdb snapshot released twice or after being persisted", "id", s.snap.GetId())
+		return
+	}
+	s.snap.Release()
+}
+
+func (s *snapshotState) GetId() uint64         { return s.snap.GetId() }
+func (s *snapshotState) GetUsedMemory() uint64 { return s.snap.GetObjAllocated() }
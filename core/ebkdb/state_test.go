@@ -0,0 +1,136 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package ebkdb
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/ebakus/go-ebakus/common"
+)
+
+func TestWithOrder(t *testing.T) {
+	var (
+		where, order = new(WhereField), new(OrderField)
+		noWhere      = (*WhereField)(nil)
+	)
+
+	tests := []struct {
+		args     []interface{}
+		expected []interface{}
+	}{
+		{nil, []interface{}{noWhere, order}},
+		{[]interface{}{where}, []interface{}{where, order}},
+		{[]interface{}{where, nil}, []interface{}{where, order}},
+		{[]interface{}{where, (*OrderField)(nil)}, []interface{}{where, order}},
+		{[]interface{}{nil, nil, 1}, []interface{}{noWhere, order, 1}},
+		{[]interface{}{noWhere}, []interface{}{noWhere, order}},
+	}
+	for i, test := range tests {
+		if hasOrder(test.args) {
+			t.Errorf("test %d: args have an order clause", i)
+		}
+		ordered := withOrder(test.args, order)
+		if len(ordered) != len(test.expected) {
+			t.Fatalf("test %d: have %d args, want %d", i, len(ordered), len(test.expected))
+		}
+		for j := range ordered {
+			if ordered[j] != test.expected[j] {
+				t.Errorf("test %d: arg %d mismatch: have %v, want %v", i, j, ordered[j], test.expected[j])
+			}
+		}
+		if !hasOrder(ordered) {
+			t.Errorf("test %d: ordered args have no order clause", i)
+		}
+	}
+}
+
+type orderedRow struct {
+	Id    common.Address
+	Value uint64
+}
+
+// selectIds inserts the rows in the given order and returns the ids of a
+// select without an order clause.
+func selectIds(t *testing.T, rows []orderedRow) []common.Address {
+	db, err := OpenInMemory(nil)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+
+	state := NewState(db.GetRootSnapshot())
+	defer state.Release()
+
+	if err := state.CreateTable("Ordered", &orderedRow{}); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	for _, row := range rows {
+		if err := state.InsertObj("Ordered", &row); err != nil {
+			t.Fatalf("failed to insert row: %v", err)
+		}
+	}
+	iter, err := state.Select("Ordered")
+	if err != nil {
+		t.Fatalf("failed to select: %v", err)
+	}
+	defer iter.Release()
+
+	var (
+		ids []common.Address
+		row orderedRow
+	)
+	for iter.Next(&row) {
+		ids = append(ids, row.Id)
+	}
+	return ids
+}
+
+// Tests that selects without an order clause iterate by ascending Id, whatever
+// the order the rows were inserted in.
+func TestSelectCanonicalOrder(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	rows := make([]orderedRow, 64)
+	for i := range rows {
+		rng.Read(rows[i].Id[:])
+		rows[i].Value = uint64(i)
+	}
+	expected := selectIds(t, rows)
+	if len(expected) != len(rows) {
+		t.Fatalf("selected %d rows, want %d", len(expected), len(rows))
+	}
+	for i := 1; i < len(expected); i++ {
+		if bytes.Compare(expected[i-1][:], expected[i][:]) >= 0 {
+			t.Fatalf("row %d out of order: %x after %x", i, expected[i], expected[i-1])
+		}
+	}
+	for round := 0; round < 8; round++ {
+		rng.Shuffle(len(rows), func(i, j int) { rows[i], rows[j] = rows[j], rows[i] })
+
+		ids := selectIds(t, rows)
+		if len(ids) != len(expected) {
+			t.Fatalf("round %d: selected %d rows, want %d", round, len(ids), len(expected))
+		}
+		for i := range ids {
+			if ids[i] != expected[i] {
+				t.Fatalf("round %d: row %d mismatch: have %x, want %x", round, i, ids[i], expected[i])
+			}
+		}
+	}
+}
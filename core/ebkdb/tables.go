@@ -0,0 +1,113 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package ebkdb
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// Table is a table of a state, along with the type of its rows.
+type Table struct {
+	Name   string
+	Row    reflect.Type // Struct type of the rows, keyed by their Id field
+	System bool         // Whether the table belongs to the system contracts
+}
+
+// TableLister lists the tables a package knows the rows of. Tables created on
+// demand are listed even if the state doesn't hold them yet.
+type TableLister func(state State) ([]Table, error)
+
+var (
+	registryLock sync.RWMutex
+	tableListers []TableLister
+	globalKeys   []string
+)
+
+// RegisterTables registers a lister of the tables of the states. As ebakusdb
+// keeps no list of the tables, the packages creating them register listers to
+// have them walked over, e.g. by the snapshot chunks.
+func RegisterTables(lister TableLister) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	tableListers = append(tableListers, lister)
+}
+
+// RegisterKeys registers raw keys the states hold outside of any table.
+func RegisterKeys(keys ...string) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	globalKeys = append(globalKeys, keys...)
+	sort.Strings(globalKeys)
+}
+
+// GlobalKeys returns the registered raw keys, in ascending order.
+func GlobalKeys() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	return append([]string(nil), globalKeys...)
+}
+
+// ListTables returns the tables known to the registered listers, the system
+// tables first, each group ordered by name. The state may not hold all of them.
+func ListTables(state State) ([]Table, error) {
+	registryLock.RLock()
+	listers := append([]TableLister(nil), tableListers...)
+	registryLock.RUnlock()
+
+	var tables []Table
+	for _, lister := range listers {
+		listed, err := lister(state)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, listed...)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		if tables[i].System != tables[j].System {
+			return tables[i].System
+		}
+		return tables[i].Name < tables[j].Name
+	})
+	return tables, nil
+}
+
+// ClearTable deletes all the rows of a table, returning their number. The table
+// itself and its indexes are kept.
+func ClearTable(state State, table string, row reflect.Type) (int, error) {
+	iter, err := state.Select(table)
+	if err != nil {
+		return 0, err
+	}
+	// Collect the ids first, so the rows aren't deleted under the iterator
+	var ids []interface{}
+	for obj := reflect.New(row); iter.Next(obj.Interface()); obj = reflect.New(row) {
+		ids = append(ids, obj.Elem().FieldByName("Id").Interface())
+	}
+	iter.Release()
+
+	for _, id := range ids {
+		if err := state.DeleteObj(table, id); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
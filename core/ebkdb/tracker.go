@@ -0,0 +1,192 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package ebkdb
+
+import (
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ebakus/go-ebakus/log"
+	"github.com/ebakus/go-ebakus/metrics"
+)
+
+const (
+	// trackedCallers is the number of call sites recorded for each snapshot, the
+	// ones within this package included.
+	trackedCallers = 8
+
+	modulePath  = "github.com/ebakus/go-ebakus"
+	packagePath = modulePath + "/core/ebkdb"
+)
+
+var (
+	liveSnapshotsGauge   = metrics.NewRegisteredGauge("ebakus/snapshots/live", nil)
+	leakedSnapshotsMeter = metrics.NewRegisteredMeter("ebakus/snapshots/leaked", nil)
+)
+
+// trackedState is the lifecycle record of a live state.
+type trackedState struct {
+	callers  [trackedCallers]uintptr // Call sites the state was created from
+	created  time.Time               // Time the state was created
+	reported bool                    // Whether the state was reported as leaked
+}
+
+// snapshotTracker keeps the states backed by ebakusdb snapshots from their
+// creation until their release, so the ones never released can be found and
+// blamed on the code creating them.
+type snapshotTracker struct {
+	live map[*snapshotState]*trackedState
+	lock sync.Mutex
+}
+
+var tracker = &snapshotTracker{live: make(map[*snapshotState]*trackedState)}
+
+// track records a state as live, created by the callers of the exported
+// function of this package creating it.
+func (t *snapshotTracker) track(s *snapshotState) {
+	entry := &trackedState{created: time.Now()}
+	runtime.Callers(3, entry.callers[:])
+
+	t.lock.Lock()
+	t.live[s] = entry
+	liveSnapshotsGauge.Update(int64(len(t.live)))
+	t.lock.Unlock()
+}
+
+// untrack forgets a state, reporting whether it was live.
+func (t *snapshotTracker) untrack(s *snapshotState) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	_, ok := t.live[s]
+	delete(t.live, s)
+	liveSnapshotsGauge.Update(int64(len(t.live)))
+
+	return ok
+}
+
+// SnapshotOwner is the set of live snapshots created from the same call site.
+type SnapshotOwner struct {
+	Owner  string  `json:"owner"`  // Function creating the snapshots
+	Caller string  `json:"caller"` // Function calling the owner
+	Count  int     `json:"count"`  // Number of live snapshots
+	Oldest float64 `json:"oldest"` // Age of the oldest one, in seconds
+}
+
+// owners groups the live states created before the given time by owner, the
+// most numerous first. With report set, the states not reported yet are marked
+// so, and the returned owners only count those.
+func (t *snapshotTracker) owners(before time.Time, report bool) []*SnapshotOwner {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var (
+		owners = make(map[[2]string]*SnapshotOwner)
+		now    = time.Now()
+	)
+	for _, entry := range t.live {
+		if !entry.created.Before(before) || (report && entry.reported) {
+			continue
+		}
+		entry.reported = entry.reported || report
+
+		owner, caller := callSite(entry.callers[:])
+		key := [2]string{owner, caller}
+		if owners[key] == nil {
+			owners[key] = &SnapshotOwner{Owner: owner, Caller: caller}
+		}
+		owners[key].Count++
+		if age := now.Sub(entry.created).Seconds(); age > owners[key].Oldest {
+			owners[key].Oldest = age
+		}
+	}
+	list := make([]*SnapshotOwner, 0, len(owners))
+	for _, owner := range owners {
+		list = append(list, owner)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Oldest > list[j].Oldest
+	})
+	return list
+}
+
+// callSite returns the functions creating and calling the creator of a state,
+// skipping the frames of this package.
+func callSite(pcs []uintptr) (string, string) {
+	var sites []string
+
+	frames := runtime.CallersFrames(pcs)
+	for len(sites) < 2 {
+		frame, more := frames.Next()
+		if frame.Function != "" && !strings.HasPrefix(frame.Function, packagePath+".") {
+			sites = append(sites, strings.TrimPrefix(frame.Function, modulePath+"/"))
+		}
+		if !more {
+			break
+		}
+	}
+	for len(sites) < 2 {
+		sites = append(sites, "unknown")
+	}
+	return sites[0], sites[1]
+}
+
+// LiveSnapshots returns the live ebakusdb snapshots grouped by the code owning
+// them, the most numerous first.
+func LiveSnapshots() []*SnapshotOwner {
+	return tracker.owners(time.Now(), false)
+}
+
+// ReportLeakedSnapshots logs the ebakusdb snapshots which have been live for
+// longer than age, grouped by the code owning them, as they are most likely
+// never getting released. Each snapshot is only reported once. It returns the
+// number of snapshots newly reported.
+func ReportLeakedSnapshots(age time.Duration) int {
+	var leaked int
+	for _, owner := range tracker.owners(time.Now().Add(-age), true) {
+		log.Warn("Possibly leaked ebakusdb snapshots", "owner", owner.Owner, "caller", owner.Caller, "count", owner.Count, "age", time.Duration(owner.Oldest*float64(time.Second)))
+		leaked += owner.Count
+	}
+	leakedSnapshotsMeter.Mark(int64(leaked))
+	return leaked
+}
+
+// Persist creates a snapshot of the state whose ownership is handed over to
+// the database, returning the id it is persisted under. It is released by id
+// through the database and not through a State.
+func Persist(state State) uint64 {
+	snap := state.Snapshot()
+	if s, ok := snap.(*snapshotState); ok {
+		tracker.untrack(s)
+	}
+	return snap.GetId()
+}
+
+// ReleasePersisted releases a snapshot handed over to the database by Persist.
+// Loading a snapshot by id retains it, so the loaded one is released twice, to
+// drop the reference held since it was persisted too.
+func ReleasePersisted(db *DB, id uint64) {
+	snap := db.Snapshot(id)
+	snap.Release()
+	snap.Release()
+}
@@ -0,0 +1,68 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package ebkdb
+
+import (
+	"testing"
+	"time"
+)
+
+// Tests that the snapshot tracker groups the live states by the code creating
+// them, skipping the frames of the package, and reports each leak only once.
+func TestSnapshotTracker(t *testing.T) {
+	tracker := &snapshotTracker{live: make(map[*snapshotState]*trackedState)}
+
+	// States created from within the package are blamed on the test runner
+	track := func() *snapshotState {
+		s := new(snapshotState)
+		tracker.track(s)
+		return s
+	}
+	first, second, third := track(), track(), track()
+
+	owners := tracker.owners(time.Now(), false)
+	if len(owners) != 1 {
+		t.Fatalf("owners mismatch: have %d, want %d", len(owners), 1)
+	}
+	if owners[0].Owner != "testing.tRunner" || owners[0].Count != 3 {
+		t.Fatalf("owner mismatch: have %s x%d, want %s x%d", owners[0].Owner, owners[0].Count, "testing.tRunner", 3)
+	}
+	// Release a state, age the rest and check they're reported once
+	if !tracker.untrack(first) {
+		t.Fatalf("live state not tracked")
+	}
+	if tracker.untrack(first) {
+		t.Fatalf("released state still tracked")
+	}
+	tracker.live[second].created = time.Now().Add(-time.Hour)
+
+	if owners := tracker.owners(time.Now().Add(-time.Minute), true); len(owners) != 1 || owners[0].Count != 1 || owners[0].Oldest < time.Hour.Seconds() {
+		t.Fatalf("leaks mismatch: have %+v, want one leak an hour old", owners)
+	}
+	if owners := tracker.owners(time.Now().Add(-time.Minute), true); len(owners) != 0 {
+		t.Fatalf("leak reported twice: %+v", owners)
+	}
+	if owners := tracker.owners(time.Now(), false); len(owners) != 1 || owners[0].Count != 2 {
+		t.Fatalf("live states mismatch: have %+v, want 2", owners)
+	}
+	tracker.untrack(second)
+	tracker.untrack(third)
+
+	if owners := tracker.owners(time.Now(), false); len(owners) != 0 {
+		t.Fatalf("released states still live: %+v", owners)
+	}
+}
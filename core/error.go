@@ -33,6 +33,10 @@ var (
 	// next one expected based on the local chain.
 	ErrNonceTooHigh = errors.New("nonce too high")
 
+	// ErrUnprotectedTx is returned if a transaction isn't replay protected as
+	// specified by EIP155, once those are rejected.
+	ErrUnprotectedTx = errors.New("unprotected transaction")
+
 	// ErrNoGenesis is returned when there is no Genesis Block.
 	ErrNoGenesis = errors.New("genesis not found in chain")
 )
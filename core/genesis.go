@@ -25,11 +25,10 @@ import (
 	"math/big"
 	"strings"
 
-	"github.com/ebakus/ebakusdb"
-
 	"github.com/ebakus/go-ebakus/common"
 	"github.com/ebakus/go-ebakus/common/hexutil"
 	"github.com/ebakus/go-ebakus/common/math"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/rawdb"
 	"github.com/ebakus/go-ebakus/core/state"
 	"github.com/ebakus/go-ebakus/core/types"
@@ -154,7 +153,7 @@ func (e *GenesisMismatchError) Error() string {
 // error is a *params.ConfigCompatError and the new, unwritten config is returned.
 //
 // The returned chain configuration is never nil.
-func SetupGenesisBlock(db ethdb.Database, ebakusDb *ebakusdb.DB, genesis *Genesis) (*params.ChainConfig, common.Hash, error) {
+func SetupGenesisBlock(db ethdb.Database, ebakusDb *ebkdb.DB, genesis *Genesis) (*params.ChainConfig, common.Hash, error) {
 	if genesis != nil && genesis.Config == nil {
 		return params.AllEthashProtocolChanges, common.Hash{}, errGenesisNoConfig
 	}
@@ -248,12 +247,12 @@ func (g *Genesis) configOrDefault(ghash common.Hash) *params.ChainConfig {
 
 // ToBlock creates the genesis block and writes state of a genesis specification
 // to the given database (or discards it if nil).
-func (g *Genesis) ToBlock(db ethdb.Database, ebakusDb *ebakusdb.DB) *types.Block {
+func (g *Genesis) ToBlock(db ethdb.Database, ebakusDb *ebkdb.DB) *types.Block {
 	if db == nil {
 		db = rawdb.NewMemoryDatabase()
 	}
 	if ebakusDb == nil {
-		ebakusDb, _ = ebakusdb.OpenInMemory(nil)
+		ebakusDb, _ = ebkdb.OpenInMemory(nil)
 	}
 	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
 	for addr, account := range g.Alloc {
@@ -281,7 +280,7 @@ func (g *Genesis) ToBlock(db ethdb.Database, ebakusDb *ebakusdb.DB) *types.Block
 			snap := ebakusDb.GetRootSnapshot()
 			defer snap.Release()
 
-			vm.SystemContractSetupDB(snap, bootProducer)
+			vm.SystemContractSetupDB(ebkdb.NewState(snap), bootProducer)
 			ebakusDb.SetRootSnapshot(snap)
 		}
 	}
@@ -293,7 +292,7 @@ func (g *Genesis) ToBlock(db ethdb.Database, ebakusDb *ebakusdb.DB) *types.Block
 
 // Commit writes the block and state of a genesis specification to the database.
 // The block is committed as the canonical head block.
-func (g *Genesis) Commit(db ethdb.Database, ebakusDb *ebakusdb.DB) (*types.Block, error) {
+func (g *Genesis) Commit(db ethdb.Database, ebakusDb *ebkdb.DB) (*types.Block, error) {
 	block := g.ToBlock(db, ebakusDb)
 	if block.Number().Sign() != 0 {
 		return nil, fmt.Errorf("can't commit genesis block with number > 0")
@@ -324,7 +323,7 @@ func (g *Genesis) Commit(db ethdb.Database, ebakusDb *ebakusdb.DB) (*types.Block
 
 // MustCommit writes the genesis block and state to db, panicking on error.
 // The block is committed as the canonical head block.
-func (g *Genesis) MustCommit(db ethdb.Database, ebakusDb *ebakusdb.DB) *types.Block {
+func (g *Genesis) MustCommit(db ethdb.Database, ebakusDb *ebkdb.DB) *types.Block {
 	block, err := g.Commit(db, ebakusDb)
 	if err != nil {
 		panic(err)
@@ -333,7 +332,7 @@ func (g *Genesis) MustCommit(db ethdb.Database, ebakusDb *ebakusdb.DB) *types.Bl
 }
 
 // GenesisBlockForTesting creates and writes a block in which addr has the given wei balance.
-func GenesisBlockForTesting(db ethdb.Database, ebakusDb *ebakusdb.DB, addr common.Address, balance *big.Int) *types.Block {
+func GenesisBlockForTesting(db ethdb.Database, ebakusDb *ebkdb.DB, addr common.Address, balance *big.Int) *types.Block {
 	g := Genesis{Alloc: GenesisAlloc{addr: {Balance: balance}}}
 	return g.MustCommit(db, ebakusDb)
 }
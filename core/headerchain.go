@@ -26,8 +26,8 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/state"
-	"github.com/ebakus/ebakusdb"
 
 	"github.com/ebakus/go-ebakus/common"
 	"github.com/ebakus/go-ebakus/consensus"
@@ -67,6 +67,8 @@ type HeaderChain struct {
 
 	rand   *mrand.Rand
 	engine consensus.Engine
+
+	skipList uint64 // Seal verification interval in skip-list mode, 0 for random sampling (atomic)
 }
 
 // NewHeaderChain creates a new HeaderChain structure.
@@ -220,7 +222,14 @@ func (hc *HeaderChain) ValidateHeaderChain(chain []*types.Header, checkFreq int)
 
 	// Generate the list of seal verification requests, and start the parallel verifier
 	seals := make([]bool, len(chain))
-	if checkFreq != 0 {
+	if every := atomic.LoadUint64(&hc.skipList); checkFreq != 0 && every != 0 {
+		// In skip-list mode verify every Nth block and all the ones changing
+		// the delegate set, so that no epoch boundary goes unchecked.
+		for i, header := range chain {
+			seals[i] = header.Number.Uint64()%every == 0 || len(header.DelegateDiff) > 0
+		}
+		seals[len(seals)-1] = true
+	} else if checkFreq != 0 {
 		// In case of checkFreq == 0 all seals are left false.
 		for i := 0; i < len(seals)/checkFreq; i++ {
 			index := i*checkFreq + hc.rand.Intn(checkFreq)
@@ -256,6 +265,13 @@ func (hc *HeaderChain) ValidateHeaderChain(chain []*types.Header, checkFreq int)
 	return 0, nil
 }
 
+// SetSkipListVerification switches seal verification of header chains from
+// random sampling to verifying every Nth header plus the delegate set changes.
+// Zero restores random sampling.
+func (hc *HeaderChain) SetSkipListVerification(every uint64) {
+	atomic.StoreUint64(&hc.skipList, every)
+}
+
 // InsertHeaderChain attempts to insert the given header chain in to the local
 // chain, possibly creating a reorg. If an error is returned, it will return the
 // index number of the failing header as well an error describing what went wrong.
@@ -522,6 +538,6 @@ func (hc *HeaderChain) StateAt(hash common.Hash) (*state.StateDB, error) {
 }
 
 // EbakusStateAt is not valid in this situation
-func (hc *HeaderChain) EbakusStateAt(hash common.Hash, number uint64) (*ebakusdb.Snapshot, error) {
+func (hc *HeaderChain) EbakusStateAt(hash common.Hash, number uint64) (ebkdb.State, error) {
 	return nil, fmt.Errorf("Unsupported on light chain")
 }
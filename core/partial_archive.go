@@ -0,0 +1,88 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/core/rawdb"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/core/vm"
+	"github.com/ebakus/go-ebakus/log"
+	"github.com/ebakus/go-ebakus/metrics"
+)
+
+var prunedTablesMeter = metrics.NewRegisteredMeter("chain/ebakus/pruned/tables", nil)
+
+// archivedContract reports whether the historical rows of the tables of a
+// contract are retained in partial archive mode. The system contract tables are
+// always retained, as consensus and the delegate APIs depend on them.
+func (bc *BlockChain) archivedContract(owner common.Address) bool {
+	if owner == types.PrecompliledSystemContract {
+		return true
+	}
+	for _, contract := range bc.cacheConfig.ArchiveContracts {
+		if contract == owner {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneEbakusSnapshot replaces the ebakusdb snapshot of a block with one where
+// the tables of the contracts not archived are emptied, releasing the original
+// one. The rows of the pruned tables which are still live in newer snapshots
+// are shared and unaffected.
+func (bc *BlockChain) pruneEbakusSnapshot(hash common.Hash, number uint64) {
+	id := rawdb.ReadSnapshot(bc.db, hash, number)
+	if id == nil {
+		return
+	}
+	ebakusImportWaitTimer.Update(bc.ebakusmu.Lock())
+	defer bc.ebakusmu.Unlock()
+
+	state := ebkdb.NewState(bc.stateDb.Snapshot(*id))
+	defer state.Release()
+
+	tables, err := vm.GetContractTables(state)
+	if err != nil {
+		log.Warn("Failed to list ebakusdb tables", "number", number, "err", err)
+		return
+	}
+	var pruned int
+	for _, table := range tables {
+		if bc.archivedContract(table.Contract) {
+			continue
+		}
+		rows, err := vm.ClearContractTable(state, table.Contract, table.Name)
+		if err != nil {
+			log.Warn("Failed to prune ebakusdb table", "number", number, "contract", table.Contract, "table", table.Name, "err", err)
+			return
+		}
+		if rows > 0 {
+			pruned++
+		}
+	}
+	if pruned == 0 {
+		return
+	}
+	rawdb.WriteSnapshot(bc.db, hash, ebkdb.Persist(state))
+	ebkdb.ReleasePersisted(bc.stateDb, *id)
+
+	prunedTablesMeter.Mark(int64(pruned))
+	log.Trace("Pruned ebakusdb snapshot", "number", number, "hash", hash, "tables", pruned)
+}
@@ -432,6 +432,10 @@ func ReadReceipts(db ethdb.Reader, hash common.Hash, number uint64, config *para
 		log.Error("Missing body but have receipt", "hash", hash, "number", number)
 		return nil
 	}
+	// The receipts of old blocks may have been pruned, leaving an empty list
+	if len(receipts) == 0 && len(body.Transactions) > 0 {
+		return nil
+	}
 	if err := receipts.DeriveFields(config, hash, number, body.Transactions); err != nil {
 		log.Error("Failed to derive block receipts fields", "hash", hash, "number", number, "err", err)
 		return nil
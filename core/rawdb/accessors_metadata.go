@@ -96,3 +96,44 @@ func WritePreimages(db ethdb.KeyValueWriter, preimages map[common.Hash][]byte) {
 	preimageCounter.Inc(int64(len(preimages)))
 	preimageHitCounter.Inc(int64(len(preimages)))
 }
+
+// PendingWork is the progress of the block being produced by the local miner,
+// kept so that the block can be rebuilt after a restart within the same slot.
+type PendingWork struct {
+	ParentHash common.Hash
+	Number     uint64
+	Time       uint64
+	TxHashes   []common.Hash // Transactions included so far, in execution order
+}
+
+// ReadPendingWork retrieves the progress of the block being produced locally.
+func ReadPendingWork(db ethdb.KeyValueReader) *PendingWork {
+	data, _ := db.Get(pendingWorkKey)
+	if len(data) == 0 {
+		return nil
+	}
+	work := new(PendingWork)
+	if err := rlp.DecodeBytes(data, work); err != nil {
+		log.Error("Invalid pending work RLP", "err", err)
+		return nil
+	}
+	return work
+}
+
+// WritePendingWork stores the progress of the block being produced locally.
+func WritePendingWork(db ethdb.KeyValueWriter, work *PendingWork) {
+	data, err := rlp.EncodeToBytes(work)
+	if err != nil {
+		log.Crit("Failed to RLP encode pending work", "err", err)
+	}
+	if err := db.Put(pendingWorkKey, data); err != nil {
+		log.Crit("Failed to store pending work", "err", err)
+	}
+}
+
+// DeletePendingWork removes the progress of the block being produced locally.
+func DeletePendingWork(db ethdb.KeyValueWriter) {
+	if err := db.Delete(pendingWorkKey); err != nil {
+		log.Crit("Failed to delete pending work", "err", err)
+	}
+}
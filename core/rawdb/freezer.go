@@ -26,10 +26,12 @@ import (
 	"time"
 
 	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/types"
 	"github.com/ebakus/go-ebakus/ethdb"
 	"github.com/ebakus/go-ebakus/log"
 	"github.com/ebakus/go-ebakus/metrics"
 	"github.com/ebakus/go-ebakus/params"
+	"github.com/ebakus/go-ebakus/rlp"
 	"github.com/prometheus/tsdb/fileutil"
 )
 
@@ -120,6 +122,13 @@ func newFreezer(datadir string, namespace string) (*freezer, error) {
 		lock.Release()
 		return nil, err
 	}
+	if err := freezer.checkHeaders(); err != nil {
+		for _, table := range freezer.tables {
+			table.Close()
+		}
+		lock.Release()
+		return nil, err
+	}
 	log.Info("Opened ancient database", "database", datadir)
 	return freezer, nil
 }
@@ -317,11 +326,9 @@ func (f *freezer) freeze(db ethdb.KeyValueStore) {
 				log.Error("Block receipts missing, can't freeze", "number", f.frozen, "hash", hash)
 				break
 			}
+			// The ebakusdb snapshots of the pruned blocks are released, freeze
+			// them as empty
 			ebakusSnapshot := ReadSnapshotRLP(nfdb, hash, f.frozen)
-			if len(ebakusSnapshot) == 0 {
-				log.Error("Block ebakusdb snapshot missing, can't freeze", "number", f.frozen, "hash", hash)
-				break
-			}
 			log.Trace("Deep froze ancient block", "number", f.frozen, "hash", hash)
 			// Inject all the components into the relevant data tables
 			if err := f.AppendAncient(f.frozen, hash[:], header, body, receipts, ebakusSnapshot); err != nil {
@@ -376,6 +383,9 @@ func (f *freezer) freeze(db ethdb.KeyValueStore) {
 
 // repair truncates all data tables to the same length.
 func (f *freezer) repair() error {
+	if err := f.migrateSnapshots(); err != nil {
+		return err
+	}
 	min := uint64(math.MaxUint64)
 	for _, table := range f.tables {
 		items := atomic.LoadUint64(&table.items)
@@ -391,3 +401,66 @@ func (f *freezer) repair() error {
 	atomic.StoreUint64(&f.frozen, min)
 	return nil
 }
+
+// migrateSnapshots backfills the ebakusdb snapshot table of a freezer populated
+// before the table was introduced, otherwise the repair would truncate all the
+// chain data to the empty table. Freezing those blocks deleted their snapshot
+// ids from the key-value store without keeping them, so they are stored as
+// empty. Nodes pruning snapshots released them before the blocks froze, as the
+// retention is shorter than the immutability threshold, but archive nodes lose
+// the ebakus state of these blocks, which is logged.
+func (f *freezer) migrateSnapshots() error {
+	snapshots := f.tables[freezerSnapshotTable]
+	if atomic.LoadUint64(&snapshots.items) != 0 {
+		return nil
+	}
+	min := uint64(math.MaxUint64)
+	for name, table := range f.tables {
+		if name == freezerSnapshotTable {
+			continue
+		}
+		if items := atomic.LoadUint64(&table.items); min > items {
+			min = items
+		}
+	}
+	if min == 0 || min == math.MaxUint64 {
+		return nil
+	}
+	log.Info("Migrating ancient database to ebakusdb snapshots", "blocks", min)
+	log.Warn("Ancient blocks have no ebakusdb snapshot, their ebakus state is unavailable", "from", 0, "to", min-1)
+	for i := uint64(0); i < min; i++ {
+		if err := snapshots.Append(i, nil); err != nil {
+			return err
+		}
+	}
+	return snapshots.Sync()
+}
+
+// checkHeaders ensures that the first and last frozen headers decode into ebakus
+// headers, carrying the producer signature and the delegate diff, and re-encode
+// to the frozen hash. This refuses to serve up a freezer populated by a foreign
+// client or with a different header format as chain data.
+func (f *freezer) checkHeaders() error {
+	frozen := atomic.LoadUint64(&f.frozen)
+	if frozen == 0 {
+		return nil
+	}
+	for _, number := range []uint64{0, frozen - 1} {
+		blob, err := f.tables[freezerHeaderTable].Retrieve(number)
+		if err != nil {
+			return err
+		}
+		hash, err := f.tables[freezerHashTable].Retrieve(number)
+		if err != nil {
+			return err
+		}
+		header := new(types.Header)
+		if err := rlp.DecodeBytes(blob, header); err != nil {
+			return fmt.Errorf("ancient header #%d is not an ebakus header: %v", number, err)
+		}
+		if have, want := header.Hash(), common.BytesToHash(hash); have != want {
+			return fmt.Errorf("ancient header #%d hash mismatch: have %x, want %x", number, have, want)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/rawdb"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/log"
+	"github.com/ebakus/go-ebakus/metrics"
+	"github.com/ebakus/go-ebakus/params"
+)
+
+var prunedReceiptsMeter = metrics.NewRegisteredMeter("chain/receipts/pruned", nil)
+
+// sanitizeReceiptRetention ensures the receipt retention window covers the
+// reorg window, and that the receipts leaving it aren't frozen in the ancient
+// store yet, as those can't be pruned anymore.
+func (bc *BlockChain) sanitizeReceiptRetention() {
+	retention := bc.cacheConfig.ReceiptRetention
+	if retention == 0 {
+		return
+	}
+	if retention < TriesInMemory {
+		log.Warn("Sanitizing receipt retention", "provided", retention, "updated", TriesInMemory)
+		bc.cacheConfig.ReceiptRetention = TriesInMemory
+	}
+	if retention >= params.ImmutabilityThreshold {
+		log.Warn("Sanitizing receipt retention", "provided", retention, "updated", params.ImmutabilityThreshold-1)
+		bc.cacheConfig.ReceiptRetention = params.ImmutabilityThreshold - 1
+	}
+}
+
+// receiptPruning reports whether the receipts of the blocks leaving the
+// retention window are pruned. Archive nodes retain all of them.
+func (bc *BlockChain) receiptPruning() bool {
+	return !bc.cacheConfig.TrieDirtyDisabled && bc.cacheConfig.ReceiptRetention > 0
+}
+
+// retainedReceipts reports whether the receipts of a block are retained past
+// the retention window, as it holds transactions to or logs of the retained
+// contracts. The receipts are retained or pruned as a whole, as the log indexes
+// are derived from their position within the block.
+func (bc *BlockChain) retainedReceipts(body *types.Body, receipts types.Receipts) bool {
+	if len(bc.cacheConfig.ReceiptContracts) == 0 {
+		return false
+	}
+	retained := make(map[common.Address]struct{}, len(bc.cacheConfig.ReceiptContracts))
+	for _, contract := range bc.cacheConfig.ReceiptContracts {
+		retained[contract] = struct{}{}
+	}
+	if body != nil {
+		for _, tx := range body.Transactions {
+			if to := tx.To(); to != nil {
+				if _, ok := retained[*to]; ok {
+					return true
+				}
+			}
+		}
+	}
+	for _, receipt := range receipts {
+		if receipt.ContractAddress != (common.Address{}) {
+			if _, ok := retained[receipt.ContractAddress]; ok {
+				return true
+			}
+		}
+		for _, l := range receipt.Logs {
+			if _, ok := retained[l.Address]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pruneReceipts prunes the receipts of all the blocks at the given number, side
+// chain ones included, apart from the ones of the retained contracts. Pruned
+// receipts are replaced by an empty list, so that the blocks can still be
+// frozen in the ancient store.
+func (bc *BlockChain) pruneReceipts(number uint64) {
+	if number == 0 {
+		return
+	}
+	// Receipts already frozen are part of the ancient store for good
+	if frozen, err := bc.db.Ancients(); err == nil && number < frozen {
+		return
+	}
+	var (
+		batch  = bc.db.NewBatch()
+		pruned int
+	)
+	for _, hash := range rawdb.ReadAllHashes(bc.db, number) {
+		receipts := rawdb.ReadRawReceipts(bc.db, hash, number)
+		if len(receipts) == 0 {
+			continue
+		}
+		if bc.retainedReceipts(rawdb.ReadBody(bc.db, hash, number), receipts) {
+			continue
+		}
+		rawdb.WriteReceipts(batch, hash, number, types.Receipts{})
+		bc.receiptsCache.Remove(hash)
+		pruned++
+	}
+	if pruned == 0 {
+		return
+	}
+	if err := batch.Write(); err != nil {
+		log.Error("Failed to prune receipts", "number", number, "err", err)
+		return
+	}
+	prunedReceiptsMeter.Mark(int64(pruned))
+	log.Trace("Pruned block receipts", "number", number, "count", pruned)
+}
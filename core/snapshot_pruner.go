@@ -0,0 +1,85 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/core/rawdb"
+	"github.com/ebakus/go-ebakus/log"
+	"github.com/ebakus/go-ebakus/metrics"
+	"github.com/ebakus/go-ebakus/params"
+)
+
+var prunedSnapshotsMeter = metrics.NewRegisteredMeter("chain/ebakus/pruned/snapshots", nil)
+
+// sanitizeSnapshotRetention ensures the ebakusdb snapshot retention window
+// covers the reorg window, and that the snapshots leaving it aren't frozen in
+// the ancient store yet, as those can't be deleted anymore.
+func (bc *BlockChain) sanitizeSnapshotRetention() {
+	retention := bc.cacheConfig.SnapshotRetention
+	if retention == 0 {
+		return
+	}
+	if retention < TriesInMemory {
+		log.Warn("Sanitizing ebakusdb snapshot retention", "provided", retention, "updated", TriesInMemory)
+		bc.cacheConfig.SnapshotRetention = TriesInMemory
+	}
+	if retention >= params.ImmutabilityThreshold {
+		log.Warn("Sanitizing ebakusdb snapshot retention", "provided", retention, "updated", params.ImmutabilityThreshold-1)
+		bc.cacheConfig.SnapshotRetention = params.ImmutabilityThreshold - 1
+	}
+}
+
+// snapshotPruning reports whether the ebakusdb snapshots of the blocks leaving
+// the retention window are released. Archive nodes retain all of them.
+func (bc *BlockChain) snapshotPruning() bool {
+	return !bc.cacheConfig.TrieDirtyDisabled && bc.cacheConfig.SnapshotRetention > 0
+}
+
+// releaseEbakusSnapshots releases the ebakusdb snapshots of all the blocks at
+// the given number, side chain ones included, deleting their references. The
+// genesis and checkpoint snapshots are retained.
+func (bc *BlockChain) releaseEbakusSnapshots(number uint64) {
+	if number == 0 {
+		return
+	}
+	if checkpoint := bc.cacheConfig.SnapshotCheckpoint; checkpoint > 0 && number%checkpoint == 0 {
+		return
+	}
+	// Snapshots already frozen are referenced by the ancient store for good
+	if frozen, err := bc.db.Ancients(); err == nil && number < frozen {
+		return
+	}
+	ebakusImportWaitTimer.Update(bc.ebakusmu.Lock())
+	defer bc.ebakusmu.Unlock()
+
+	var released int
+	for _, hash := range rawdb.ReadAllHashes(bc.db, number) {
+		id := rawdb.ReadSnapshot(bc.db, hash, number)
+		if id == nil {
+			continue
+		}
+		rawdb.DeleteSnapshot(bc.db, hash)
+		ebkdb.ReleasePersisted(bc.stateDb, *id)
+		released++
+	}
+	if released == 0 {
+		return
+	}
+	prunedSnapshotsMeter.Mark(int64(released))
+	log.Trace("Released ebakusdb snapshots", "number", number, "count", released)
+}
@@ -19,12 +19,12 @@ package core
 import (
 	"github.com/ebakus/go-ebakus/common"
 	"github.com/ebakus/go-ebakus/consensus"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/state"
 	"github.com/ebakus/go-ebakus/core/types"
 	"github.com/ebakus/go-ebakus/core/vm"
 	"github.com/ebakus/go-ebakus/crypto"
 	"github.com/ebakus/go-ebakus/params"
-	"github.com/ebakus/ebakusdb"
 )
 
 // StateProcessor is a basic Processor, which takes care of transitioning
@@ -32,17 +32,19 @@ import (
 //
 // StateProcessor implements Processor.
 type StateProcessor struct {
-	config *params.ChainConfig // Chain configuration options
-	bc     *BlockChain         // Canonical block chain
-	engine consensus.Engine    // Consensus engine used for block rewards
+	config   *params.ChainConfig // Chain configuration options
+	bc       *BlockChain         // Canonical block chain
+	engine   consensus.Engine    // Consensus engine used for block rewards
+	executor *statePrefetcher    // Executor of the disjoint transactions in parallel
 }
 
 // NewStateProcessor initialises a new StateProcessor.
 func NewStateProcessor(config *params.ChainConfig, bc *BlockChain, engine consensus.Engine) *StateProcessor {
 	return &StateProcessor{
-		config: config,
-		bc:     bc,
-		engine: engine,
+		config:   config,
+		bc:       bc,
+		engine:   engine,
+		executor: newStatePrefetcher(config, bc, engine),
 	}
 }
 
@@ -53,7 +55,7 @@ func NewStateProcessor(config *params.ChainConfig, bc *BlockChain, engine consen
 // Process returns the receipts and logs accumulated during the process and
 // returns the amount of gas that was used in the process. If any of the
 // transactions failed to execute due to insufficient gas it will return an error.
-func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, ebakusState *ebakusdb.Snapshot, coinbase common.Address, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error) {
+func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, ebakusState ebkdb.State, coinbase common.Address, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error) {
 	var (
 		receipts types.Receipts
 		usedGas  = new(uint64)
@@ -61,6 +63,15 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, eba
 		allLogs  []*types.Log
 		gp       = new(GasPool).AddGas(block.GasLimit())
 	)
+	// Execute the disjoint transactions in parallel if enabled, serially otherwise
+	if p.bc != nil && p.bc.cacheConfig.ParallelWorkers > 1 {
+		if receipts, allLogs, usedGas, ok := p.executor.Execute(block, statedb, ebakusState, coinbase, cfg, p.bc.cacheConfig.ParallelWorkers); ok {
+			if err := p.engine.Finalize(p.bc, header, statedb, ebakusState, coinbase, block.Transactions()); err != nil {
+				return nil, nil, 0, err
+			}
+			return receipts, allLogs, usedGas, nil
+		}
+	}
 	// Iterate over and process the individual transactions
 	for i, tx := range block.Transactions() {
 		statedb.Prepare(tx.Hash(), block.Hash(), i)
@@ -72,8 +83,9 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, eba
 		allLogs = append(allLogs, receipt.Logs...)
 	}
 	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
-	p.engine.Finalize(p.bc, header, statedb, ebakusState, coinbase, block.Transactions())
-
+	if err := p.engine.Finalize(p.bc, header, statedb, ebakusState, coinbase, block.Transactions()); err != nil {
+		return nil, nil, 0, err
+	}
 	return receipts, allLogs, *usedGas, nil
 }
 
@@ -81,7 +93,16 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, eba
 // and uses the input parameters for its environment. It returns the receipt
 // for the transaction, gas used and an error if the transaction failed,
 // indicating the block was invalid.
-func ApplyTransaction(config *params.ChainConfig, bc *BlockChain, author *common.Address, gp *GasPool, statedb *state.StateDB, ebakusState *ebakusdb.Snapshot, header *types.Header, tx *types.Transaction, usedGas *uint64, cfg vm.Config) (*types.Receipt, uint64, error) {
+func ApplyTransaction(config *params.ChainConfig, bc *BlockChain, author *common.Address, gp *GasPool, statedb *state.StateDB, ebakusState ebkdb.State, header *types.Header, tx *types.Transaction, usedGas *uint64, cfg vm.Config) (*types.Receipt, uint64, error) {
+	return applyTransaction(config, bc, author, gp, statedb, statedb, ebakusState, header, tx, usedGas, cfg)
+}
+
+// applyTransaction is ApplyTransaction running the EVM against the given view of
+// statedb, which may record the accesses of the transaction.
+func applyTransaction(config *params.ChainConfig, bc *BlockChain, author *common.Address, gp *GasPool, statedb *state.StateDB, view vm.StateDB, ebakusState ebkdb.State, header *types.Header, tx *types.Transaction, usedGas *uint64, cfg vm.Config) (*types.Receipt, uint64, error) {
+	if !tx.Protected() && config.IsStrictEIP155(header.Number) {
+		return nil, 0, ErrUnprotectedTx
+	}
 	msg, err := tx.AsMessage(types.MakeSigner(config))
 	if err != nil {
 		return nil, 0, err
@@ -90,7 +111,7 @@ func ApplyTransaction(config *params.ChainConfig, bc *BlockChain, author *common
 	context := NewEVMContext(msg, header, bc, author)
 	// Create a new environment which holds all relevant information
 	// about the transaction and calling mechanisms.
-	vmenv := vm.NewEVM(context, statedb, ebakusState, config, cfg)
+	vmenv := vm.NewEVM(context, view, ebakusState, config, cfg)
 	// Apply the transaction to the current state (included in the env)
 	_, gas, failed, err := ApplyMessage(vmenv, msg, gp)
 	if err != nil {
@@ -104,6 +125,7 @@ func ApplyTransaction(config *params.ChainConfig, bc *BlockChain, author *common
 	// Create a new receipt for the transaction, storing the intermediate root and gas used by the tx
 	// based on the eip phase, we're passing whether the root touch-delete accounts.
 	receipt := types.NewReceipt(root, failed, *usedGas)
+	receipt.Type = tx.Type()
 	receipt.TxHash = tx.Hash()
 	receipt.GasUsed = gas
 	// if the transaction created a contract, store the creation address in the receipt.
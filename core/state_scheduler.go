@@ -0,0 +1,328 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/core/state"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/core/vm"
+	"github.com/ebakus/go-ebakus/crypto"
+	"github.com/ebakus/go-ebakus/log"
+	"github.com/ebakus/go-ebakus/metrics"
+)
+
+// parallelMinTxs is the number of transactions below which blocks are processed
+// serially, as spreading them over workers doesn't pay off.
+const parallelMinTxs = 8
+
+var (
+	parallelBlockMeter    = metrics.NewRegisteredMeter("chain/parallel/blocks", nil)
+	parallelConflictMeter = metrics.NewRegisteredMeter("chain/parallel/conflicts", nil)
+)
+
+// systemAccessKey is the access key of the transactions calling the system
+// precompiles, whose tables are shared by all accounts. Sharing it with the zero
+// address only costs some parallelism.
+var systemAccessKey = common.Address{}
+
+// executionLane is a run of the transactions of a block which statically touch
+// accounts and ebakusdb tables none of the other lanes do, executed serially on
+// its own copy of the states.
+type executionLane struct {
+	txs []int // Indexes of the transactions in the block, in order
+
+	statedb  *stateRecorder
+	ebakusdb *ebakusRecorder
+	receipts []*types.Receipt
+	gas      []uint64
+	err      error
+}
+
+// scheduleLanes splits the transactions of a block into lanes by the accounts
+// they statically access: their sender, and their recipient or the contract
+// they create. Transactions calling the same contract share a lane, as do the
+// ones calling the system precompiles, as contracts own their ebakusdb tables.
+// The DB precompile only accesses the tables of its caller.
+func scheduleLanes(signer types.Signer, txs types.Transactions) ([]*executionLane, error) {
+	parent := make(map[common.Address]common.Address)
+
+	var find func(common.Address) common.Address
+	find = func(key common.Address) common.Address {
+		p, ok := parent[key]
+		if !ok {
+			parent[key] = key
+			return key
+		}
+		if p != key {
+			p = find(p)
+			parent[key] = p
+		}
+		return p
+	}
+	roots := make([]common.Address, len(txs))
+	for i, tx := range txs {
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			return nil, err
+		}
+		root := find(from)
+
+		var other common.Address
+		switch to := tx.To(); {
+		case to == nil:
+			other = crypto.CreateAddress(from, tx.Nonce())
+		case *to == types.PrecompliledDBContract:
+			other = from
+		case vm.PrecompiledContractsWrappedToken[*to] != nil:
+			other = systemAccessKey
+		default:
+			other = *to
+		}
+		if r := find(other); r != root {
+			parent[r] = root
+		}
+		roots[i] = from
+	}
+	var (
+		lanes  []*executionLane
+		byRoot = make(map[common.Address]*executionLane)
+	)
+	for i := range txs {
+		root := find(roots[i])
+		lane, ok := byRoot[root]
+		if !ok {
+			lane = new(executionLane)
+			byRoot[root] = lane
+			lanes = append(lanes, lane)
+		}
+		lane.txs = append(lane.txs, i)
+	}
+	return lanes, nil
+}
+
+// Execute processes the transactions of a block by running the lanes of the
+// ones statically touching disjoint state concurrently on copies of the states,
+// merging the outcomes into statedb and ebakusState if the accesses recorded
+// turned out disjoint too. It reports false, leaving the states untouched, if
+// the block can't be processed in parallel, in which case it has to be processed
+// serially.
+//
+// Execute doesn't finalize the block into the states, but checks that finalizing
+// copies of the merged states yields the state root of the header.
+func (p *statePrefetcher) Execute(block *types.Block, statedb *state.StateDB, ebakusState ebkdb.State, coinbase common.Address, cfg vm.Config, workers int) (types.Receipts, []*types.Log, uint64, bool) {
+	txs := block.Transactions()
+	if workers < 2 || len(txs) < parallelMinTxs {
+		return nil, nil, 0, false
+	}
+	lanes, err := scheduleLanes(types.MakeSigner(p.config), txs)
+	if err != nil || len(lanes) < 2 {
+		return nil, nil, 0, false
+	}
+	// Set up the lanes serially, as the states aren't safe to copy concurrently
+	for _, lane := range lanes {
+		lane.statedb = newStateRecorder(statedb.Copy())
+		lane.ebakusdb = newEbakusRecorder(ebakusState.Snapshot())
+	}
+	defer func() {
+		for _, lane := range lanes {
+			lane.ebakusdb.Release()
+		}
+	}()
+	var (
+		pending = make(chan *executionLane, len(lanes))
+		wg      sync.WaitGroup
+	)
+	for _, lane := range lanes {
+		pending <- lane
+	}
+	close(pending)
+
+	if workers > len(lanes) {
+		workers = len(lanes)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for lane := range pending {
+				p.executeLane(block, lane, cfg)
+			}
+		}()
+	}
+	wg.Wait()
+
+	receipts, logs, usedGas, err := p.mergeLanes(block, coinbase, lanes, statedb, ebakusState)
+	if err != nil {
+		log.Debug("Falling back to serial block processing", "number", block.NumberU64(), "lanes", len(lanes), "err", err)
+		parallelConflictMeter.Mark(1)
+		return nil, nil, 0, false
+	}
+	parallelBlockMeter.Mark(1)
+	return receipts, logs, usedGas, true
+}
+
+// executeLane runs the transactions of a lane serially on its copy of the states.
+func (p *statePrefetcher) executeLane(block *types.Block, lane *executionLane, cfg vm.Config) {
+	var (
+		header  = block.Header()
+		txs     = block.Transactions()
+		gaspool = new(GasPool).AddGas(block.GasLimit())
+		usedGas = new(uint64)
+	)
+	for _, i := range lane.txs {
+		lane.statedb.db.Prepare(txs[i].Hash(), block.Hash(), i)
+
+		receipt, gas, err := applyTransaction(p.config, p.bc, nil, gaspool, lane.statedb.db, lane.statedb, lane.ebakusdb, header, txs[i], usedGas, cfg)
+		if err != nil {
+			lane.err = err
+			return
+		}
+		lane.receipts = append(lane.receipts, receipt)
+		lane.gas = append(lane.gas, gas)
+	}
+}
+
+// mergeLanes checks that none of the lanes accessed state another changed, and
+// merges their outcomes into the states in block order, provided they add up to
+// the gas used, receipts root and, once finalized, state root of the header. Any
+// failure leaves the states as they were.
+func (p *statePrefetcher) mergeLanes(block *types.Block, coinbase common.Address, lanes []*executionLane, statedb *state.StateDB, ebakusState ebkdb.State) (types.Receipts, []*types.Log, uint64, error) {
+	var (
+		accounts = make(map[common.Address]*executionLane)
+		tables   = make(map[string]*executionLane)
+	)
+	for _, lane := range lanes {
+		if lane.err != nil {
+			return nil, nil, 0, lane.err
+		}
+		for addr := range lane.statedb.writes {
+			accounts[addr] = lane
+		}
+		for key := range lane.ebakusdb.writes {
+			tables[key] = lane
+		}
+	}
+	for _, lane := range lanes {
+		for _, set := range []map[common.Address]struct{}{lane.statedb.reads, lane.statedb.writes} {
+			for addr := range set {
+				if owner, ok := accounts[addr]; ok && owner != lane {
+					return nil, nil, 0, fmt.Errorf("lanes conflict on account %x", addr)
+				}
+			}
+		}
+		for _, set := range []map[string]struct{}{lane.ebakusdb.reads, lane.ebakusdb.writes} {
+			for key := range set {
+				if owner, ok := tables[key]; ok && owner != lane {
+					return nil, nil, 0, fmt.Errorf("lanes conflict on ebakusdb %q", key)
+				}
+			}
+		}
+	}
+	// Account for the gas in block order, as the serial processing does
+	var (
+		txs      = block.Transactions()
+		receipts = make(types.Receipts, len(txs))
+		gas      = make([]uint64, len(txs))
+		gaspool  = new(GasPool).AddGas(block.GasLimit())
+		usedGas  uint64
+	)
+	for _, lane := range lanes {
+		for j, i := range lane.txs {
+			receipts[i], gas[i] = lane.receipts[j], lane.gas[j]
+		}
+	}
+	for i, tx := range txs {
+		if err := gaspool.SubGas(tx.Gas()); err != nil {
+			return nil, nil, 0, err
+		}
+		gaspool.AddGas(tx.Gas() - gas[i])
+		usedGas += gas[i]
+		receipts[i].CumulativeGasUsed = usedGas
+	}
+	// The lanes must reproduce what the serial processing commits to in the
+	// header. Any difference, e.g. receipt roots taken on the copies of the
+	// states, is a dependency the recorders missed
+	if usedGas != block.GasUsed() {
+		return nil, nil, 0, fmt.Errorf("lanes gas used mismatch: have %d, want %d", usedGas, block.GasUsed())
+	}
+	if hash := types.DeriveSha(receipts); hash != block.ReceiptHash() {
+		return nil, nil, 0, fmt.Errorf("lanes receipts root mismatch: have %x, want %x", hash, block.ReceiptHash())
+	}
+	// No more conflicts possible. The statedb can't be reverted once finalized,
+	// so merge the lanes into copies of the states first, and check that they
+	// finalize to the state root of the header
+	var (
+		header        = block.Header()
+		trialState    = statedb.Copy()
+		trialSnapshot = ebakusState.Snapshot()
+	)
+	defer trialSnapshot.Release()
+
+	if _, err := mergeLaneStates(block, lanes, receipts, trialState, trialSnapshot); err != nil {
+		return nil, nil, 0, err
+	}
+	if err := p.engine.Finalize(p.bc, header, trialState, trialSnapshot, coinbase, txs); err != nil {
+		return nil, nil, 0, err
+	}
+	if root := trialState.IntermediateRoot(p.config.IsEIP158(header.Number)); root != block.Root() {
+		return nil, nil, 0, fmt.Errorf("lanes state root mismatch: have %x, want %x", root, block.Root())
+	}
+	// The outcome is the serial one, merge the lanes into the states
+	var (
+		revision = statedb.Snapshot()
+		snapshot = ebakusState.Snapshot()
+	)
+	defer snapshot.Release()
+
+	allLogs, err := mergeLaneStates(block, lanes, receipts, statedb, ebakusState)
+	if err != nil {
+		statedb.RevertToSnapshot(revision)
+		ebakusState.ResetTo(snapshot)
+		return nil, nil, 0, err
+	}
+	return receipts, allLogs, usedGas, nil
+}
+
+// mergeLaneStates replays the changes of the lanes on the states, re-adding the
+// logs of the receipts in block order, so that they're indexed within the block.
+func mergeLaneStates(block *types.Block, lanes []*executionLane, receipts types.Receipts, statedb *state.StateDB, ebakusState ebkdb.State) ([]*types.Log, error) {
+	for _, lane := range lanes {
+		if err := lane.ebakusdb.replay(ebakusState); err != nil {
+			return nil, err
+		}
+		lane.statedb.commit(statedb)
+	}
+	var allLogs []*types.Log
+	for i, tx := range block.Transactions() {
+		statedb.Prepare(tx.Hash(), block.Hash(), i)
+		for _, l := range receipts[i].Logs {
+			cpy := *l
+			statedb.AddLog(&cpy)
+		}
+		receipts[i].Logs = statedb.GetLogs(tx.Hash())
+		receipts[i].Bloom = types.CreateBloom(types.Receipts{receipts[i]})
+		allLogs = append(allLogs, receipts[i].Logs...)
+	}
+	statedb.Finalise(true)
+
+	return allLogs, nil
+}
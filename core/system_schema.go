@@ -0,0 +1,74 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+
+	"github.com/ebakus/go-ebakus/core/vm"
+	"github.com/ebakus/go-ebakus/log"
+)
+
+// errSchemaDryRun is returned on startup once the pending system schema
+// migrations were checked, nothing being persisted.
+var errSchemaDryRun = errors.New("system schema migrations dry run done, nothing persisted")
+
+// checkSystemSchema checks that the software supports the system tables schema
+// of the head ebakus state, reporting the pending migrations. These run while
+// processing the blocks of their forks; the startup never changes the state.
+// In dry run mode, the pending migrations are also run on a discarded copy of
+// the head state, to check they succeed.
+func (bc *BlockChain) checkSystemSchema() error {
+	head := bc.CurrentBlock()
+
+	ebakusState, err := bc.ReadEbakusStateAt(head.Hash(), head.NumberU64())
+	if err != nil {
+		// Snapshot synced nodes may lack the head state until the sync is done
+		log.Debug("Skipping system schema check", "number", head.NumberU64(), "err", err)
+		return nil
+	}
+	defer ebakusState.Release()
+
+	if !vm.HasSystemTables(ebakusState) {
+		return nil
+	}
+	migrations, err := vm.PendingSchemaMigrations(ebakusState)
+	if err != nil {
+		return err
+	}
+	if len(migrations) == 0 {
+		if bc.cacheConfig.SchemaDryRun {
+			log.Info("System schema up to date", "version", vm.SystemSchemaVersion)
+			return errSchemaDryRun
+		}
+		return nil
+	}
+	from := vm.GetSystemSchemaVersion(ebakusState)
+	log.Info("System schema migrations pending", "number", head.NumberU64(), "from", from, "to", vm.SystemSchemaVersion, "migrations", len(migrations))
+
+	if !bc.cacheConfig.SchemaDryRun {
+		return nil
+	}
+	migrated := ebakusState.Snapshot()
+	defer migrated.Release()
+
+	if err := vm.MigrateSystemSchema(migrated); err != nil {
+		return err
+	}
+	log.Info("System schema migrations succeeded", "from", from, "to", vm.SystemSchemaVersion)
+	return errSchemaDryRun
+}
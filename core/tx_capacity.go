@@ -0,0 +1,67 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/core/types"
+)
+
+// txCapacity tracks the number of transactions the accounts may have pooled,
+// as a function of their virtual capacity, i.e. their share of the staked
+// tokens at the head of the chain. Accounts without stake are only granted the
+// base slots, so they can't crowd the pool out with high work transactions.
+type txCapacity struct {
+	base  uint64 // Slots of the accounts without stake
+	slots uint64 // Slots added for holding all the staked tokens
+
+	state  ebkdb.State               // Ebakus state of the head the capacities are read at
+	limits map[common.Address]uint64 // Cached slots of the accounts seen
+}
+
+// newTxCapacity creates the capacity tracker of the accounts at the given
+// ebakus state, which it releases when reset.
+func newTxCapacity(base, slots uint64, state ebkdb.State) *txCapacity {
+	return &txCapacity{
+		base:   base,
+		slots:  slots,
+		state:  state,
+		limits: make(map[common.Address]uint64),
+	}
+}
+
+// limit returns the number of transactions the account may have pooled.
+func (c *txCapacity) limit(addr common.Address) uint64 {
+	if limit, ok := c.limits[addr]; ok {
+		return limit
+	}
+	limit := c.base
+	if c.state != nil {
+		limit += uint64(types.VirtualCapacity(addr, c.state) * float64(c.slots))
+	}
+	c.limits[addr] = limit
+	return limit
+}
+
+// release frees the ebakus state the capacities are read at.
+func (c *txCapacity) release() {
+	if c.state != nil {
+		c.state.Release()
+		c.state = nil
+	}
+}
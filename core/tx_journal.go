@@ -45,12 +45,14 @@ func (*devNull) Close() error                      { return nil }
 type txJournal struct {
 	path   string         // Filesystem path to store the transactions at
 	writer io.WriteCloser // Output stream to write new transactions into
+	work   *txWorkJournal // Journal of the Proof of Work of the transactions
 }
 
 // newTxJournal creates a new transaction journal to
 func newTxJournal(path string) *txJournal {
 	return &txJournal{
 		path: path,
+		work: newTxWorkJournal(path + ".work"),
 	}
 }
 
@@ -68,9 +70,15 @@ func (journal *txJournal) load(add func([]*types.Transaction) []error) error {
 	}
 	defer input.Close()
 
+	// Restore the journaled work, so the transactions needn't recalculate it
+	work, err := journal.work.load()
+	if err != nil {
+		log.Warn("Failed to load transaction work journal", "err", err)
+	}
 	// Temporarily discard any journal additions (don't double add on load)
 	journal.writer = new(devNull)
-	defer func() { journal.writer = nil }()
+	journal.work.writer = new(devNull)
+	defer func() { journal.writer, journal.work.writer = nil, nil }()
 
 	// Inject all transactions from the journal into the pool
 	stream := rlp.NewStream(input, 0)
@@ -106,6 +114,10 @@ func (journal *txJournal) load(add func([]*types.Transaction) []error) error {
 		// New transaction parsed, queue up for later, import if threshold is reached
 		total++
 
+		if difficulty, ok := work[tx.Hash()]; ok {
+			tx.SetDifficulty(difficulty)
+		}
+
 		if batch = append(batch, tx); batch.Len() > 1024 {
 			loadBatch(batch)
 			batch = batch[:0]
@@ -124,7 +136,7 @@ func (journal *txJournal) insert(tx *types.Transaction) error {
 	if err := rlp.Encode(journal.writer, tx); err != nil {
 		return err
 	}
-	return nil
+	return journal.work.insert(tx)
 }
 
 // rotate regenerates the transaction journal based on the current contents of
@@ -165,7 +177,7 @@ func (journal *txJournal) rotate(all map[common.Address]types.Transactions) erro
 	journal.writer = sink
 	log.Info("Regenerated local transaction journal", "transactions", journaled, "accounts", len(all))
 
-	return nil
+	return journal.work.rotate(all)
 }
 
 // close flushes the transaction journal contents to disk and closes the file.
@@ -176,5 +188,8 @@ func (journal *txJournal) close() error {
 		err = journal.writer.Close()
 		journal.writer = nil
 	}
+	if workErr := journal.work.close(); err == nil {
+		err = workErr
+	}
 	return err
 }
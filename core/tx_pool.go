@@ -20,13 +20,14 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/big"
 	"sort"
 	"sync"
 	"time"
 
-	"github.com/ebakus/ebakusdb"
 	"github.com/ebakus/go-ebakus/common"
 	"github.com/ebakus/go-ebakus/common/prque"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/state"
 	"github.com/ebakus/go-ebakus/core/types"
 	"github.com/ebakus/go-ebakus/event"
@@ -72,6 +73,10 @@ var (
 	// than some meaningful limit a user might use. This is not a consensus error
 	// making the transaction invalid, rather a DOS protection.
 	ErrOversizedData = errors.New("oversized data")
+
+	// ErrAccountCapacity is returned if the sender already has as many
+	// transactions pooled as its virtual capacity allows.
+	ErrAccountCapacity = errors.New("account capacity exceeded")
 )
 
 var (
@@ -97,6 +102,11 @@ var (
 	validTxMeter       = metrics.NewRegisteredMeter("txpool/valid", nil)
 	invalidTxMeter     = metrics.NewRegisteredMeter("txpool/invalid", nil)
 	underpricedTxMeter = metrics.NewRegisteredMeter("txpool/underpriced", nil)
+	capacityTxMeter    = metrics.NewRegisteredMeter("txpool/capacity", nil) // Dropped due to exceeding the account capacity
+
+	// unprotectedTxCounter counts the rejected transactions lacking the EIP155
+	// replay protection
+	unprotectedTxCounter = metrics.NewRegisteredCounter("txpool/unprotected", nil)
 
 	pendingGauge = metrics.NewRegisteredGauge("txpool/pending", nil)
 	queuedGauge  = metrics.NewRegisteredGauge("txpool/queued", nil)
@@ -120,8 +130,8 @@ type blockChain interface {
 	GetBlock(hash common.Hash, number uint64) *types.Block
 	StateAt(root common.Hash) (*state.StateDB, error)
 
-	EbakusState() (*ebakusdb.Snapshot, error)
-	EbakusStateAt(hash common.Hash, number uint64) (*ebakusdb.Snapshot, error)
+	EbakusState() (ebkdb.State, error)
+	EbakusStateAt(hash common.Hash, number uint64) (ebkdb.State, error)
 
 	SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) event.Subscription
 }
@@ -141,7 +151,16 @@ type TxPoolConfig struct {
 	AccountQueue uint64 // Maximum number of non-executable transaction slots permitted per account
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
+	CapacityBase  uint64 // Number of transaction slots of the remote accounts without stake (0 = unlimited)
+	CapacitySlots uint64 // Number of transaction slots added to the base ones for holding all the stake
+
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	QueueJournal    string        // Journal of remote queued transactions to survive node restarts (disabled if empty)
+	QueueLifetime   time.Duration // Maximum age of the journaled queued transactions to reload
+	QueueDifficulty float64       // Minimum difficulty of the remote queued transactions to journal
+
+	AllowUnprotected bool // Whether to accept transactions without EIP155 replay protection
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -158,7 +177,13 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	AccountQueue: 64,
 	GlobalQueue:  1024,
 
+	CapacityBase:  16,
+	CapacitySlots: 4096,
+
 	Lifetime: 3 * time.Hour,
+
+	QueueLifetime:   30 * time.Minute,
+	QueueDifficulty: 1,
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -197,6 +222,10 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool lifetime", "provided", conf.Lifetime, "updated", DefaultTxPoolConfig.Lifetime)
 		conf.Lifetime = DefaultTxPoolConfig.Lifetime
 	}
+	if conf.QueueLifetime < 1 {
+		log.Warn("Sanitizing invalid txpool queue journal lifetime", "provided", conf.QueueLifetime, "updated", DefaultTxPoolConfig.QueueLifetime)
+		conf.QueueLifetime = DefaultTxPoolConfig.QueueLifetime
+	}
 	return conf
 }
 
@@ -217,15 +246,19 @@ type TxPool struct {
 	signer      types.Signer
 	mu          sync.RWMutex
 
-	istanbul bool // Fork indicator whether we are in the istanbul stage.
+	istanbul     bool // Fork indicator whether we are in the istanbul stage.
+	strictEIP155 bool // Fork indicator whether unprotected transactions are invalid.
 
 	currentState  *state.StateDB // Current state in the blockchain head
 	pendingNonces *txNoncer      // Pending state tracking virtual nonces
+	capacity      *txCapacity    // Transaction slots of the accounts by their virtual capacity
 	currentMaxGas uint64         // Current gas limit for transaction caps
 
 	locals  *accountSet // Set of local transaction to exempt from eviction rules
 	journal *txJournal  // Journal of local transaction to back up to disk
 
+	queueJournal *txQueueJournal // Journal of remote queued transactions to back up to disk
+
 	pending map[common.Address]*txList   // All currently processable transactions
 	queue   map[common.Address]*txList   // Queued but non-processable transactions
 	beats   map[common.Address]time.Time // Last heartbeat from each known account
@@ -293,6 +326,14 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 			log.Warn("Failed to rotate transaction journal", "err", err)
 		}
 	}
+	// If queued transaction journaling is enabled, reload the remote ones too
+	if config.QueueJournal != "" {
+		pool.queueJournal = newTxQueueJournal(config.QueueJournal)
+
+		if err := pool.queueJournal.load(pool.AddRemotes, config.QueueLifetime); err != nil {
+			log.Warn("Failed to load queued transaction journal", "err", err)
+		}
+	}
 
 	// Subscribe events from blockchain and start the main event loop.
 	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
@@ -373,6 +414,9 @@ func (pool *TxPool) loop() {
 				}
 				pool.mu.Unlock()
 			}
+			if pool.queueJournal != nil {
+				pool.rotateQueueJournal()
+			}
 		}
 	}
 }
@@ -389,6 +433,12 @@ func (pool *TxPool) Stop() {
 	if pool.journal != nil {
 		pool.journal.close()
 	}
+	if pool.queueJournal != nil {
+		pool.rotateQueueJournal()
+	}
+	if pool.capacity != nil {
+		pool.capacity.release()
+	}
 	log.Info("Transaction pool stopped")
 }
 
@@ -490,6 +540,32 @@ func (pool *TxPool) Locals() []common.Address {
 	return pool.locals.flatten()
 }
 
+// rotateQueueJournal regenerates the journal of the remote queued transactions
+// whose work reaches the configured difficulty floor.
+func (pool *TxPool) rotateQueueJournal() {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	var (
+		txs   = make(map[common.Address]types.Transactions)
+		beats = make(map[common.Address]time.Time)
+	)
+	for addr, list := range pool.queue {
+		if pool.locals.contains(addr) {
+			continue
+		}
+		for _, tx := range list.Flatten() {
+			if tx.CalculateDifficulty() >= pool.config.QueueDifficulty {
+				txs[addr] = append(txs[addr], tx)
+			}
+		}
+		beats[addr] = pool.beats[addr]
+	}
+	if err := pool.queueJournal.rotate(txs, beats); err != nil {
+		log.Warn("Failed to rotate queued tx journal", "err", err)
+	}
+}
+
 // local retrieves all currently known local transactions, grouped by origin
 // account and sorted by nonce. The returned transaction set is a copy and can be
 // freely modified by calling code.
@@ -509,6 +585,11 @@ func (pool *TxPool) local() map[common.Address]types.Transactions {
 // validateTx checks whether a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
+	// Typed transactions decode, but none is executable yet. The pool keeps no
+	// relay-only set either, so they aren't forwarded to the peers.
+	if tx.Type() != types.LegacyTxType {
+		return types.ErrTxTypeNotSupported
+	}
 	// Heuristic limit, reject transactions over 32KB to prevent DOS attacks
 	if tx.Size() > 32*1024 {
 		return ErrOversizedData
@@ -527,6 +608,11 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if err != nil {
 		return ErrInvalidSender
 	}
+	// Reject the transactions replayable on other chains, unless allowed
+	if !tx.Protected() && (pool.strictEIP155 || !pool.config.AllowUnprotected) {
+		unprotectedTxCounter.Inc(1)
+		return ErrUnprotectedTx
+	}
 	// Drop transactions under our own minimal accepted gas price
 	if pool.gasPrice > tx.GasPrice() {
 		return ErrUnderpriced
@@ -606,7 +692,24 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 		log.Trace("Pooled new executable transaction", "hash", hash, "from", from, "to", tx.To())
 		return old != nil, nil
 	}
-	// New transaction isn't replacing a pending one, push into queue
+	// New transaction isn't replacing a pending one, ensure the sender has room for it
+	if pool.capacity != nil && !local && !pool.locals.contains(from) {
+		if queued := pool.queue[from]; queued == nil || !queued.Overlaps(tx) {
+			pooled := uint64(0)
+			if pending := pool.pending[from]; pending != nil {
+				pooled += uint64(pending.Len())
+			}
+			if queued != nil {
+				pooled += uint64(queued.Len())
+			}
+			if limit := pool.capacity.limit(from); pooled >= limit {
+				log.Trace("Discarding transaction exceeding account capacity", "hash", hash, "from", from, "pooled", pooled, "limit", limit)
+				capacityTxMeter.Mark(1)
+				return false, ErrAccountCapacity
+			}
+		}
+	}
+	// Push the transaction into the queue
 	replaced, err = pool.enqueueTx(hash, tx)
 	if err != nil {
 		return false, err
@@ -1136,6 +1239,23 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	pool.pendingNonces = newTxNoncer(statedb)
 	pool.currentMaxGas = newHead.GasLimit
 
+	// Read the account capacities at the new head, if they're limited
+	if pool.config.CapacityBase > 0 {
+		ebakusState, err := pool.chain.EbakusStateAt(newHead.Hash(), newHead.Number.Uint64())
+		if err != nil {
+			log.Debug("Failed to read txpool account capacities", "err", err)
+		}
+		if pool.capacity != nil {
+			pool.capacity.release()
+		}
+		pool.capacity = newTxCapacity(pool.config.CapacityBase, pool.config.CapacitySlots, ebakusState)
+	}
+
+	// Update the fork indicator, the pending transactions are included in the
+	// next block
+	next := new(big.Int).Add(newHead.Number, big.NewInt(1))
+	pool.strictEIP155 = pool.chainconfig.IsStrictEIP155(next)
+
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
 	senderCacher.recover(pool.signer, reinject)
@@ -0,0 +1,132 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/log"
+	"github.com/ebakus/go-ebakus/rlp"
+)
+
+// txQueueEntry is a journaled queued transaction, along with the last heartbeat
+// of its sender and its Proof of Work, stored by its IEEE 754 bits.
+type txQueueEntry struct {
+	Time       uint64
+	Difficulty uint64
+	Tx         *types.Transaction
+}
+
+// txQueueJournal is a snapshot of the non-executable remote transactions of the
+// pool, so that short restarts don't drop the pending activity of the users.
+// Unlike the local journal it isn't appended to, as the queue churns far too
+// much, but only regenerated periodically and on shutdown.
+type txQueueJournal struct {
+	path string // Filesystem path to store the transactions at
+}
+
+// newTxQueueJournal creates a new queued transaction journal.
+func newTxQueueJournal(path string) *txQueueJournal {
+	return &txQueueJournal{
+		path: path,
+	}
+}
+
+// load parses a queued transaction journal dump from disk, loading into the
+// specified pool the transactions whose sender was seen within the lifetime.
+func (journal *txQueueJournal) load(add func([]*types.Transaction) []error, lifetime time.Duration) error {
+	// Skip the parsing if the journal file doesn't exist at all
+	if _, err := os.Stat(journal.path); os.IsNotExist(err) {
+		return nil
+	}
+	input, err := os.Open(journal.path)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	var (
+		stream  = rlp.NewStream(input, 0)
+		failure error
+		batch   types.Transactions
+	)
+	total, expired, dropped := 0, 0, 0
+	for {
+		var entry txQueueEntry
+		if err := stream.Decode(&entry); err != nil {
+			if err != io.EOF {
+				failure = err
+			}
+			break
+		}
+		total++
+
+		if time.Since(time.Unix(int64(entry.Time), 0)) > lifetime {
+			expired++
+			continue
+		}
+		entry.Tx.SetDifficulty(math.Float64frombits(entry.Difficulty))
+		batch = append(batch, entry.Tx)
+	}
+	for _, err := range add(batch) {
+		if err != nil {
+			log.Debug("Failed to add journaled queued transaction", "err", err)
+			dropped++
+		}
+	}
+	log.Info("Loaded queued transaction journal", "transactions", total, "expired", expired, "dropped", dropped)
+
+	return failure
+}
+
+// rotate regenerates the queued transaction journal based on the current
+// contents of the transaction pool.
+func (journal *txQueueJournal) rotate(all map[common.Address]types.Transactions, beats map[common.Address]time.Time) error {
+	replacement, err := os.OpenFile(journal.path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	journaled := 0
+	for addr, txs := range all {
+		for _, tx := range txs {
+			entry := &txQueueEntry{
+				Time:       uint64(beats[addr].Unix()),
+				Difficulty: math.Float64bits(tx.CalculateDifficulty()),
+				Tx:         tx,
+			}
+			if err = rlp.Encode(replacement, entry); err != nil {
+				replacement.Close()
+				return err
+			}
+		}
+		journaled += len(txs)
+	}
+	replacement.Close()
+
+	// Replace the previous journal with the newly generated one
+	if err = os.Rename(journal.path+".new", journal.path); err != nil {
+		return err
+	}
+	log.Debug("Regenerated queued transaction journal", "transactions", journaled, "accounts", len(all))
+
+	return nil
+}
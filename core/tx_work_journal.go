@@ -0,0 +1,145 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"io"
+	"math"
+	"os"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/rlp"
+)
+
+// txWork is the journaled Proof of Work of a transaction. The difficulty is
+// stored by its IEEE 754 bits, as RLP has no floats.
+type txWork struct {
+	Hash       common.Hash
+	Difficulty uint64
+}
+
+// txWorkJournal is a rotating log of the Proof of Work of the journaled local
+// transactions, kept next to the transaction journal. The pool orders the
+// transactions by their difficulty, so restoring it lets them be ordered right
+// away on startup instead of recalculating it for all of them. The virtual
+// difficulty depends on the stake of the sender at the block being mined, so
+// it's derived from the restored difficulty rather than journaled.
+type txWorkJournal struct {
+	path   string         // Filesystem path to store the work at
+	writer io.WriteCloser // Output stream to write new work into
+}
+
+// newTxWorkJournal creates a new transaction work journal.
+func newTxWorkJournal(path string) *txWorkJournal {
+	return &txWorkJournal{
+		path: path,
+	}
+}
+
+// load parses a work journal dump from disk, returning the difficulties of the
+// transactions by hash.
+func (journal *txWorkJournal) load() (map[common.Hash]float64, error) {
+	work := make(map[common.Hash]float64)
+
+	// Skip the parsing if the journal file doesn't exist at all
+	if _, err := os.Stat(journal.path); os.IsNotExist(err) {
+		return work, nil
+	}
+	input, err := os.Open(journal.path)
+	if err != nil {
+		return work, err
+	}
+	defer input.Close()
+
+	stream := rlp.NewStream(input, 0)
+	for {
+		var entry txWork
+		if err := stream.Decode(&entry); err != nil {
+			if err != io.EOF {
+				return work, err
+			}
+			return work, nil
+		}
+		work[entry.Hash] = math.Float64frombits(entry.Difficulty)
+	}
+}
+
+// insert adds the work of the specified transaction to the disk journal.
+func (journal *txWorkJournal) insert(tx *types.Transaction) error {
+	if journal.writer == nil {
+		return errNoActiveJournal
+	}
+	return rlp.Encode(journal.writer, workOf(tx))
+}
+
+// rotate regenerates the work journal based on the current contents of the
+// transaction pool.
+func (journal *txWorkJournal) rotate(all map[common.Address]types.Transactions) error {
+	// Close the current journal (if any is open)
+	if journal.writer != nil {
+		if err := journal.writer.Close(); err != nil {
+			return err
+		}
+		journal.writer = nil
+	}
+	// Generate a new journal with the contents of the current pool
+	replacement, err := os.OpenFile(journal.path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	for _, txs := range all {
+		for _, tx := range txs {
+			if err = rlp.Encode(replacement, workOf(tx)); err != nil {
+				replacement.Close()
+				return err
+			}
+		}
+	}
+	replacement.Close()
+
+	// Replace the live journal with the newly generated one
+	if err = os.Rename(journal.path+".new", journal.path); err != nil {
+		return err
+	}
+	sink, err := os.OpenFile(journal.path, os.O_WRONLY|os.O_APPEND, 0755)
+	if err != nil {
+		return err
+	}
+	journal.writer = sink
+
+	return nil
+}
+
+// close flushes the work journal contents to disk and closes the file.
+func (journal *txWorkJournal) close() error {
+	var err error
+
+	if journal.writer != nil {
+		err = journal.writer.Close()
+		journal.writer = nil
+	}
+	return err
+}
+
+// workOf returns the journal entry of a transaction's work.
+func workOf(tx *types.Transaction) *txWork {
+	return &txWork{
+		Hash:       tx.Hash(),
+		Difficulty: math.Float64bits(tx.CalculateDifficulty()),
+	}
+}
@@ -18,7 +18,7 @@ package core
 
 import (
 	"github.com/ebakus/go-ebakus/common"
-	"github.com/ebakus/ebakusdb"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 
 	"github.com/ebakus/go-ebakus/core/state"
 	"github.com/ebakus/go-ebakus/core/types"
@@ -42,7 +42,7 @@ type Prefetcher interface {
 	// Prefetch processes the state changes according to the Ebakus rules by running
 	// the transaction messages using the statedb, but any changes are discarded. The
 	// only goal is to pre-cache transaction signatures and state trie nodes.
-	Prefetch(block *types.Block, statedb *state.StateDB, ebakusState *ebakusdb.Snapshot, cfg vm.Config, interrupt *uint32)
+	Prefetch(block *types.Block, statedb *state.StateDB, ebakusState ebkdb.State, cfg vm.Config, interrupt *uint32)
 }
 
 // Processor is an interface for processing blocks using a given initial state.
@@ -50,5 +50,5 @@ type Processor interface {
 	// Process processes the state changes according to the Ebakus rules by running
 	// the transaction messages using the statedb and applying any rewards to both
 	// the processor (coinbase) and any included uncles.
-	Process(block *types.Block, statedb *state.StateDB, ebakusState *ebakusdb.Snapshot, coinbase common.Address, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error)
+	Process(block *types.Block, statedb *state.StateDB, ebakusState ebkdb.State, coinbase common.Address, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error)
 }
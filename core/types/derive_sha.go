@@ -30,6 +30,25 @@ type DerivableList interface {
 }
 
 func DeriveSha(list DerivableList) common.Hash {
+	return deriveTrie(list).Hash()
+}
+
+// DeriveProof returns the Merkle proof of the i'th item of the list, against the
+// root returned by DeriveSha. The proof nodes are ordered from the root down.
+func DeriveProof(list DerivableList, i int) ([][]byte, error) {
+	key, err := rlp.EncodeToBytes(uint(i))
+	if err != nil {
+		return nil, err
+	}
+	var proof proofList
+	if err := deriveTrie(list).Prove(key, 0, &proof); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+// deriveTrie builds the trie of the list, keyed by the rlp encoded indexes.
+func deriveTrie(list DerivableList) *trie.Trie {
 	keybuf := new(bytes.Buffer)
 	trie := new(trie.Trie)
 	for i := 0; i < list.Len(); i++ {
@@ -37,5 +56,17 @@ func DeriveSha(list DerivableList) common.Hash {
 		rlp.Encode(keybuf, uint(i))
 		trie.Update(keybuf.Bytes(), list.GetRlp(i))
 	}
-	return trie.Hash()
+	return trie
+}
+
+// proofList collects the nodes of a Merkle proof in order.
+type proofList [][]byte
+
+func (n *proofList) Put(key []byte, value []byte) error {
+	*n = append(*n, value)
+	return nil
+}
+
+func (n *proofList) Delete(key []byte) error {
+	panic("not supported")
 }
@@ -19,13 +19,13 @@ package types
 import (
 	"encoding/binary"
 
-	"github.com/ebakus/ebakusdb"
 	"github.com/ebakus/go-ebakus/common"
 	"github.com/ebakus/go-ebakus/core/ebkdb"
 )
 
 var PrecompliledSystemContract = common.BytesToAddress([]byte{1, 1})
 var PrecompliledDBContract = common.BytesToAddress([]byte{1, 2})
+var PrecompliledWrappedToken = common.BytesToAddress([]byte{1, 3})
 
 // EspilonStake for calculating virtual difficulty
 const EspilonStake = 1e-10
@@ -41,7 +41,7 @@ type Staked struct {
 
 var StakedTable = ebkdb.GetDBTableName(PrecompliledSystemContract, "Staked")
 
-func VirtualCapacity(from common.Address, ebakusState *ebakusdb.Snapshot) float64 {
+func VirtualCapacity(from common.Address, ebakusState ebkdb.State) float64 {
 	accountStaked := uint64(0)
 
 	where := []byte("Id LIKE ")
@@ -59,5 +59,12 @@ func VirtualCapacity(from common.Address, ebakusState *ebakusdb.Snapshot) float6
 		systemStaked = binary.BigEndian.Uint64(*systemStakedBytes)
 	}
 
+	return StakeCapacity(accountStaked, systemStaked)
+}
+
+// StakeCapacity returns the virtual capacity of an account staking the given
+// amount out of the system stake, i.e. the multiplier of the work difficulty of
+// its transactions into their virtual difficulty.
+func StakeCapacity(accountStaked, systemStaked uint64) float64 {
 	return (EspilonStake + float64(accountStaked)) / (EspilonStake + float64(systemStaked))
 }
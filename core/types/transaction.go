@@ -18,17 +18,20 @@ package types
 
 import (
 	"container/heap"
+	"context"
 	"encoding/binary"
 	"errors"
 	"io"
 	"math/big"
+	"runtime"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"ekyu.moe/cryptonight"
-	"github.com/ebakus/ebakusdb"
 	"github.com/ebakus/go-ebakus/common"
 	"github.com/ebakus/go-ebakus/common/hexutil"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/crypto"
 	"github.com/ebakus/go-ebakus/metrics"
 	"github.com/ebakus/go-ebakus/rlp"
@@ -55,11 +58,30 @@ const MinimumTargetDifficulty = 0.2
 const MinimumVirtualDifficulty = 0.0
 
 var (
-	ErrInvalidSig = errors.New("invalid transaction v, r, s values")
+	ErrInvalidSig         = errors.New("invalid transaction v, r, s values")
+	ErrTxTypeNotSupported = errors.New("transaction type not supported")
+	errEmptyTypedTx       = errors.New("empty typed transaction bytes")
+	errInvalidTxType      = errors.New("invalid transaction type")
 )
 
+// Transaction types, prefixing the encoding of the typed transactions. Legacy
+// transactions keep their plain RLP list encoding.
+const (
+	LegacyTxType = 0x00
+)
+
+// maxTxType is the highest transaction type byte, distinguishing the typed
+// transactions from the legacy ones, whose RLP lists start at 0xc0.
+const maxTxType = 0x7f
+
 type Transaction struct {
 	data txdata
+	typ  uint8
+
+	// payload is the opaque encoding of a typed transaction of a type this
+	// node doesn't support, retained so it can still be relayed and stored
+	payload []byte
+
 	// caches
 	hash atomic.Value
 	size atomic.Value
@@ -146,20 +168,92 @@ func isProtectedV(V *big.Int) bool {
 	return true
 }
 
-// EncodeRLP implements rlp.Encoder
+// Type returns the transaction type.
+func (tx *Transaction) Type() uint8 {
+	return tx.typ
+}
+
+// envelope returns the type prefixed payload of a typed transaction.
+func (tx *Transaction) envelope() []byte {
+	return append([]byte{tx.typ}, tx.payload...)
+}
+
+// EncodeRLP implements rlp.Encoder. Typed transactions are encoded as an RLP
+// string holding their envelope.
 func (tx *Transaction) EncodeRLP(w io.Writer) error {
-	return rlp.Encode(w, &tx.data)
+	if tx.typ == LegacyTxType {
+		return rlp.Encode(w, &tx.data)
+	}
+	return rlp.Encode(w, tx.envelope())
 }
 
 // DecodeRLP implements rlp.Decoder
 func (tx *Transaction) DecodeRLP(s *rlp.Stream) error {
-	_, size, _ := s.Kind()
-	err := s.Decode(&tx.data)
-	if err == nil {
+	kind, size, err := s.Kind()
+	switch {
+	case err != nil:
+		return err
+	case kind == rlp.List:
+		var data txdata
+		if err := s.Decode(&data); err != nil {
+			return err
+		}
+		tx.data, tx.typ, tx.payload = data, LegacyTxType, nil
 		tx.size.Store(common.StorageSize(rlp.ListSize(size)))
+		return nil
+	default:
+		b, err := s.Bytes()
+		if err != nil {
+			return err
+		}
+		return tx.decodeTyped(b)
+	}
+}
+
+// MarshalBinary returns the canonical encoding of the transaction: the RLP list
+// of a legacy transaction, or the type prefixed payload of a typed one.
+func (tx *Transaction) MarshalBinary() ([]byte, error) {
+	if tx.typ == LegacyTxType {
+		return rlp.EncodeToBytes(&tx.data)
+	}
+	return tx.envelope(), nil
+}
+
+// UnmarshalBinary decodes the canonical encoding of a transaction.
+func (tx *Transaction) UnmarshalBinary(b []byte) error {
+	if len(b) > 0 && b[0] > maxTxType {
+		var data txdata
+		if err := rlp.DecodeBytes(b, &data); err != nil {
+			return err
+		}
+		*tx = Transaction{data: data}
+		tx.size.Store(common.StorageSize(len(b)))
+		return nil
 	}
+	return tx.decodeTyped(b)
+}
 
-	return err
+// decodeTyped decodes the envelope of a typed transaction. No typed transaction
+// is executable yet, so the payload is kept opaque, reencoding unchanged.
+func (tx *Transaction) decodeTyped(b []byte) error {
+	if len(b) == 0 {
+		return errEmptyTypedTx
+	}
+	if b[0] == LegacyTxType || b[0] > maxTxType {
+		return errInvalidTxType
+	}
+	*tx = Transaction{
+		data: txdata{
+			Amount: new(big.Int),
+			V:      new(big.Int),
+			R:      new(big.Int),
+			S:      new(big.Int),
+		},
+		typ:     b[0],
+		payload: common.CopyBytes(b[1:]),
+	}
+	tx.size.Store(common.StorageSize(len(b)))
+	return nil
 }
 
 // rlpWithoutNonce returns the RLP encoded transaction contents, except the nonce.
@@ -224,13 +318,18 @@ func (tx *Transaction) To() *common.Address {
 	return &to
 }
 
-// Hash hashes the RLP encoding of tx.
+// Hash hashes the RLP encoding of tx, or the envelope of a typed one.
 // It uniquely identifies the transaction.
 func (tx *Transaction) Hash() common.Hash {
 	if hash := tx.hash.Load(); hash != nil {
 		return hash.(common.Hash)
 	}
-	v := rlpHash(tx)
+	var v common.Hash
+	if tx.typ == LegacyTxType {
+		v = rlpHash(tx)
+	} else {
+		v = crypto.Keccak256Hash(tx.envelope())
+	}
 	tx.hash.Store(v)
 	return v
 }
@@ -242,7 +341,11 @@ func (tx *Transaction) Size() common.StorageSize {
 		return size.(common.StorageSize)
 	}
 	c := writeCounter(0)
-	rlp.Encode(&c, &tx.data)
+	if tx.typ == LegacyTxType {
+		rlp.Encode(&c, &tx.data)
+	} else {
+		c = writeCounter(1 + len(tx.payload))
+	}
 	tx.size.Store(common.StorageSize(c))
 	return common.StorageSize(c)
 }
@@ -276,38 +379,113 @@ func (tx *Transaction) CalculateDifficulty() float64 {
 	return v
 }
 
+// SetDifficulty caches a previously calculated Proof of Work of the transaction,
+// sparing CalculateDifficulty from recalculating it.
+func (tx *Transaction) SetDifficulty(difficulty float64) {
+	tx.pow.Store(difficulty)
+}
+
 // CalculateWorkNonce does the needed PoW for this transaction.
 func (tx *Transaction) CalculateWorkNonce(targetDifficulty float64) {
+	tx.CalculateWorkNonceCtx(context.Background(), targetDifficulty, 1)
+}
+
+// CalculateWorkNonceUntil searches for a work nonce meeting the target difficulty
+// like CalculateWorkNonce, giving up at the deadline (if not zero) and keeping the
+// best nonce found so far. It reports whether the target difficulty was met.
+func (tx *Transaction) CalculateWorkNonceUntil(targetDifficulty float64, deadline time.Time) bool {
+	ctx := context.Background()
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	return tx.CalculateWorkNonceCtx(ctx, targetDifficulty, 1) == nil
+}
+
+// CalculateWorkNonceCtx searches for a work nonce meeting the target difficulty
+// on the given number of threads (one per CPU if not positive), each of them
+// trying a disjoint part of the nonce space. If ctx is done before the target
+// difficulty is met, the best nonce found so far is kept and the context error
+// is returned.
+func (tx *Transaction) CalculateWorkNonceCtx(ctx context.Context, targetDifficulty float64, threads int) error {
 	defer transactionCalculateWorkNonceTimer.UpdateSince(time.Now())
 
 	if targetDifficulty < 1.0 {
-		return
+		return nil
+	}
+	if threads <= 0 {
+		threads = runtime.NumCPU()
 	}
 
 	td := new(big.Float).SetFloat64(targetDifficulty)
 	targetFloat := new(big.Float).Quo(two256Float, td)
 	targetInt, _ := targetFloat.Int(nil)
 
-	buf := make([]byte, 64)
 	// h := getCryptoNightBigEndian(tx.rlpForPoW())
 	h := crypto.Keccak256(tx.rlpForPoW())
-	copy(buf[:32], h[:])
 
-	nonce := uint64(0)
-	smallestHash := new(big.Int).Set(two256)
-	for {
-		binary.BigEndian.PutUint64(buf[56:], nonce)
-		// hash := getCryptoNightBigEndian(buf)
-		hash := crypto.Keccak256(buf)
-		t := new(big.Int).SetBytes(hash[:])
-
-		if t.Cmp(smallestHash) == -1 {
-			tx.data.WorkNonce, smallestHash = nonce, t
-			if smallestHash.Cmp(targetInt) == -1 {
-				return
+	var (
+		found     = make(chan struct{})
+		foundOnce sync.Once
+		pend      sync.WaitGroup
+
+		lock         sync.Mutex
+		bestNonce    uint64
+		smallestHash = new(big.Int).Set(two256)
+	)
+	for i := 0; i < threads; i++ {
+		pend.Add(1)
+		go func(nonce uint64) {
+			defer pend.Done()
+
+			buf := make([]byte, 64)
+			copy(buf[:32], h[:])
+
+			localSmallest := new(big.Int).Set(two256)
+			for tries := 1; ; tries++ {
+				binary.BigEndian.PutUint64(buf[56:], nonce)
+				// hash := getCryptoNightBigEndian(buf)
+				hash := crypto.Keccak256(buf)
+				t := new(big.Int).SetBytes(hash[:])
+
+				if t.Cmp(localSmallest) == -1 {
+					localSmallest = t
+
+					lock.Lock()
+					if t.Cmp(smallestHash) == -1 {
+						bestNonce, smallestHash = nonce, t
+					}
+					lock.Unlock()
+
+					if t.Cmp(targetInt) == -1 {
+						foundOnce.Do(func() { close(found) })
+						return
+					}
+				}
+				nonce += uint64(threads)
+
+				if tries%1024 == 0 {
+					select {
+					case <-found:
+						return
+					case <-ctx.Done():
+						return
+					default:
+					}
+				}
 			}
-		}
-		nonce++
+		}(uint64(i))
+	}
+	pend.Wait()
+
+	tx.data.WorkNonce = bestNonce
+
+	select {
+	case <-found:
+		return nil
+	default:
+		return ctx.Err()
 	}
 }
 
@@ -354,6 +532,9 @@ func (tx *Transaction) AsMessage(s Signer) (Message, error) {
 // WithSignature returns a new transaction with the given signature.
 // This signature needs to be in the [R || S || V] format where V is 0 or 1.
 func (tx *Transaction) WithSignature(signer Signer, sig []byte) (*Transaction, error) {
+	if tx.typ != LegacyTxType {
+		return nil, ErrTxTypeNotSupported
+	}
 	r, s, v, err := signer.SignatureValues(tx, sig)
 	if err != nil {
 		return nil, err
@@ -363,7 +544,7 @@ func (tx *Transaction) WithSignature(signer Signer, sig []byte) (*Transaction, e
 	return cpy, nil
 }
 
-func (tx *Transaction) VirtualDifficulty(from common.Address, ebakusState *ebakusdb.Snapshot) *big.Float {
+func (tx *Transaction) VirtualDifficulty(from common.Address, ebakusState ebkdb.State) *big.Float {
 	defer transactionVirtualDifficultyTimer.UpdateSince(time.Now())
 	cv := VirtualCapacity(from, ebakusState)
 	txd := tx.CalculateDifficulty()
@@ -392,9 +573,10 @@ func (s Transactions) Len() int { return len(s) }
 // Swap swaps the i'th and the j'th element in s.
 func (s Transactions) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
 
-// GetRlp implements Rlpable and returns the i'th element of s in rlp.
+// GetRlp implements Rlpable and returns the canonical encoding of the i'th
+// element of s.
 func (s Transactions) GetRlp(i int) []byte {
-	enc, _ := rlp.EncodeToBytes(s[i])
+	enc, _ := s[i].MarshalBinary()
 	return enc
 }
 
@@ -430,7 +612,7 @@ func (s TxByNonce) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 type TxByPrice struct {
 	tx          *Transaction
 	from        common.Address
-	ebakusState *ebakusdb.Snapshot
+	ebakusState ebkdb.State
 }
 
 type TxsByPrice []*TxByPrice
@@ -471,7 +653,7 @@ type TransactionsByVirtualDifficultyAndNonce struct {
 //
 // Note, the input map is reowned so the caller should not interact any more with
 // if after providing it to the constructor.
-func NewTransactionsByVirtualDifficultyAndNonce(signer Signer, txs map[common.Address]Transactions, ebakusState *ebakusdb.Snapshot) *TransactionsByVirtualDifficultyAndNonce {
+func NewTransactionsByVirtualDifficultyAndNonce(signer Signer, txs map[common.Address]Transactions, ebakusState ebkdb.State) *TransactionsByVirtualDifficultyAndNonce {
 	defer transactionsByVirtualDifficultyAndNonceTimer.UpdateSince(time.Now())
 
 	// Initialize a price based heap with the head transactions
@@ -525,6 +707,18 @@ func (t *TransactionsByVirtualDifficultyAndNonce) Pop() {
 	heap.Pop(&t.heads)
 }
 
+// Take removes the best transaction along with the rest of the ones from the
+// same account, returning them in nonce order so they can be considered later.
+func (t *TransactionsByVirtualDifficultyAndNonce) Take() Transactions {
+	head := heap.Pop(&t.heads).(*TxByPrice)
+
+	acc, _ := Sender(t.signer, head.tx)
+	txs := append(Transactions{head.tx}, t.txs[acc]...)
+	delete(t.txs, acc)
+
+	return txs
+}
+
 // Message is a fully derived transaction and implements core.Message
 //
 // NOTE: In a future PR this will be removed.
@@ -27,9 +27,9 @@ import (
 	"testing"
 
 	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/crypto"
 	"github.com/ebakus/go-ebakus/rlp"
-	"github.com/ebakus/ebakusdb"
 )
 
 // The values in those tests are from the Transaction Tests
@@ -177,8 +177,8 @@ func TestTransactionPriceNonceSort(t *testing.T) {
 
 // Tests VirtualDifficulty result.
 func TestTransactionVirtualDifficulty(t *testing.T) {
-	db, _ := ebakusdb.OpenInMemory(nil)
-	snap := db.GetRootSnapshot()
+	db, _ := ebkdb.OpenInMemory(nil)
+	snap := ebkdb.NewState(db.GetRootSnapshot())
 	snap.CreateTable(StakedTable, &Staked{})
 
 	key, _ := crypto.GenerateKey()
@@ -216,8 +216,8 @@ func TestTransactionVirtualDifficulty(t *testing.T) {
 // decreasing order, but at the same time with increasing nonces when issued by
 // the same account.
 func TestTransactionVirtualDifficultyNonceSort(t *testing.T) {
-	db, _ := ebakusdb.OpenInMemory(nil)
-	snap := db.GetRootSnapshot()
+	db, _ := ebkdb.OpenInMemory(nil)
+	snap := ebkdb.NewState(db.GetRootSnapshot())
 
 	snap.CreateTable(StakedTable, &Staked{})
 
@@ -0,0 +1,98 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+
+	"github.com/ebakus/go-ebakus/accounts/abi"
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/common/math"
+	"github.com/ebakus/go-ebakus/crypto"
+)
+
+var (
+	ErrTypedDataChainId   = errors.New("typed data signed for another chain")
+	errTypedDataSignature = errors.New("invalid typed data signature")
+)
+
+// TypedDataDomain returns the EIP-712 domain of the typed data of the ebakus
+// operations on the chain with the given id. The system contract verifies them,
+// so it's the verifying contract of the domain.
+func TypedDataDomain(chainId *big.Int) abi.TypedDataDomain {
+	return abi.TypedDataDomain{
+		Name:              "Ebakus",
+		Version:           "1",
+		ChainId:           (*math.HexOrDecimal256)(new(big.Int).Set(chainId)),
+		VerifyingContract: PrecompliledSystemContract.Hex(),
+	}
+}
+
+// TypedDataSigner hashes and recovers the signer of EIP-712 typed data, making
+// sure it was signed for the chain.
+type TypedDataSigner struct {
+	chainId *big.Int
+}
+
+// NewTypedDataSigner creates a typed data signer for the chain with the given id.
+func NewTypedDataSigner(chainId *big.Int) TypedDataSigner {
+	if chainId == nil {
+		chainId = new(big.Int)
+	}
+	return TypedDataSigner{chainId: chainId}
+}
+
+// Hash returns the hash to sign of the typed data, along with the raw data it
+// hashes.
+func (s TypedDataSigner) Hash(data *abi.TypedData) (common.Hash, []byte, error) {
+	if data.Domain.ChainId == nil || (*big.Int)(data.Domain.ChainId).Cmp(s.chainId) != 0 {
+		return common.Hash{}, nil, ErrTypedDataChainId
+	}
+	hash, raw, err := data.SigHash()
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	return common.BytesToHash(hash), raw, nil
+}
+
+// Sender returns the address that signed the typed data. The signature is in
+// the [R || S || V] format, where V is 0 or 1.
+func (s TypedDataSigner) Sender(data *abi.TypedData, sig []byte) (common.Address, error) {
+	if len(sig) != crypto.SignatureLength || sig[crypto.RecoveryIDOffset] > 1 {
+		return common.Address{}, errTypedDataSignature
+	}
+	hash, _, err := s.Hash(data)
+	if err != nil {
+		return common.Address{}, err
+	}
+	pub, err := crypto.SigToPub(hash[:], sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// SignTypedData signs the typed data using the given signer and private key.
+func SignTypedData(data *abi.TypedData, s TypedDataSigner, prv *ecdsa.PrivateKey) ([]byte, error) {
+	hash, _, err := s.Hash(data)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Sign(hash[:], prv)
+}
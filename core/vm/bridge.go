@@ -0,0 +1,353 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/ebakus/go-ebakus/accounts/abi"
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/crypto"
+	"github.com/ebakus/go-ebakus/log"
+)
+
+// maxBridgeIntents is the maximum number of bridge intents per block.
+const maxBridgeIntents = 256
+
+// bridgeCounterDBKey holds the block number and the number of the bridge
+// intents submitted within it so far.
+const bridgeCounterDBKey = "ebk:global:bridgeIntents"
+
+var (
+	errBridgeMalformed      = errors.New("bridge transaction malformed")
+	errBridgeInvalid        = errors.New("bridge intent amount or recipient is invalid")
+	errBridgeTooMany        = errors.New("too many bridge intents in block")
+	errBridgeIndexNotFound  = errors.New("bridge intent not found")
+	errBridgeNoCommitment   = errors.New("no bridge commitment for block")
+	errBridgeCommitmentDone = errors.New("bridge intents already committed")
+)
+
+// BridgeIntentId is the block number and the index within the block of a
+// bridge intent, so the intents of a block share the block number prefix.
+type BridgeIntentId [16]byte
+
+// GetBridgeIntentId returns the id of the index-th bridge intent of a block.
+func GetBridgeIntentId(number uint64, index uint64) BridgeIntentId {
+	var id BridgeIntentId
+
+	binary.BigEndian.PutUint64(id[:8], number)
+	binary.BigEndian.PutUint64(id[8:], index)
+
+	return id
+}
+
+// Content gets the block number and the index of the intent.
+func (id BridgeIntentId) Content() (number uint64, index uint64) {
+	return binary.BigEndian.Uint64(id[:8]), binary.BigEndian.Uint64(id[8:])
+}
+
+// BridgeIntent is a request to move Amount of EBK, locked in the system
+// contract, to Recipient on the chain with ChainId. Bridge operators watch the
+// per-block commitments of the intents and prove their inclusion.
+type BridgeIntent struct {
+	Id        BridgeIntentId
+	Sender    common.Address
+	ChainId   uint64
+	Recipient common.Hash
+	Amount    uint64
+}
+
+// Leaf returns the hash committing to the intent.
+func (intent *BridgeIntent) Leaf() common.Hash {
+	number, index := intent.Id.Content()
+
+	var buf [8 * 4]byte
+	binary.BigEndian.PutUint64(buf[0:], number)
+	binary.BigEndian.PutUint64(buf[8:], index)
+	binary.BigEndian.PutUint64(buf[16:], intent.ChainId)
+	binary.BigEndian.PutUint64(buf[24:], intent.Amount)
+
+	return crypto.Keccak256Hash([]byte{0x00}, intent.Sender[:], intent.Recipient[:], buf[:])
+}
+
+var BridgeIntentTable = ebkdb.GetDBTableName(types.PrecompliledSystemContract, "BridgeIntents")
+
+// BridgeCommitment is the merkle root of the bridge intents of a block.
+type BridgeCommitment struct {
+	Id    [8]byte // Block number
+	Root  common.Hash
+	Count uint64
+}
+
+var BridgeCommitmentTable = ebkdb.GetDBTableName(types.PrecompliledSystemContract, "BridgeCommitments")
+
+// bridgeIntentCmd locks amount of the sender's EBK and records the intent to
+// move them to the recipient on another chain.
+func (c *systemContract) bridgeIntentCmd(evm *EVM, evmABI *abi.ABI, from common.Address, chainId uint64, recipient common.Hash, amount uint64) ([]byte, error) {
+	if amount == 0 || recipient == (common.Hash{}) {
+		return nil, errBridgeInvalid
+	}
+
+	db := evm.EbakusState
+	number := evm.BlockNumber.Uint64()
+
+	index := bridgeIntentCount(db, number)
+	if index >= maxBridgeIntents {
+		log.Trace("Bridge intent failed as maxBridgeIntents reached", "number", number)
+		return nil, errBridgeTooMany
+	}
+
+	amountWei := evm.toWei(amount)
+	if !evm.CanTransfer(evm.StateDB, from, amountWei) {
+		log.Trace("Failed to lock bridged amount because of insufficient balance")
+		return nil, ErrInsufficientBalance
+	}
+
+	if !db.HasTable(BridgeIntentTable) {
+		db.CreateTable(BridgeIntentTable, &BridgeIntent{})
+	}
+
+	intent := &BridgeIntent{
+		Id:        GetBridgeIntentId(number, index),
+		Sender:    from,
+		ChainId:   chainId,
+		Recipient: recipient,
+		Amount:    amount,
+	}
+	if err := db.InsertObj(BridgeIntentTable, intent); err != nil {
+		return nil, errSystemContractError
+	}
+	if err := putBridgeIntentCount(db, number, index+1); err != nil {
+		return nil, err
+	}
+
+	evm.Transfer(evm.StateDB, from, types.PrecompliledSystemContract, amountWei)
+
+	if err := c.addLog(evm, evmABI, "BridgeIntent", []common.Hash{from.Hash(), common.BigToHash(new(big.Int).SetUint64(chainId))}, recipient, amount, index); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// bridgeIntentCount returns the number of bridge intents of the block.
+func bridgeIntentCount(db ebkdb.State, number uint64) uint64 {
+	counter, found := db.Get([]byte(bridgeCounterDBKey))
+	if !found || len(*counter) != 16 || binary.BigEndian.Uint64((*counter)[:8]) != number {
+		return 0
+	}
+	return binary.BigEndian.Uint64((*counter)[8:])
+}
+
+func putBridgeIntentCount(db ebkdb.State, number uint64, count uint64) error {
+	counter := make([]byte, 16)
+	binary.BigEndian.PutUint64(counter[:8], number)
+	binary.BigEndian.PutUint64(counter[8:], count)
+
+	if err := db.Insert([]byte(bridgeCounterDBKey), counter); err != nil {
+		return errSystemContractError
+	}
+	return nil
+}
+
+// GetBridgeIntents returns the bridge intents of a block, sorted by index.
+func GetBridgeIntents(db ebkdb.State, number uint64) ([]BridgeIntent, error) {
+	if !db.HasTable(BridgeIntentTable) {
+		return nil, nil
+	}
+
+	var prefix [8]byte
+	binary.BigEndian.PutUint64(prefix[:], number)
+
+	where := []byte("Id LIKE ")
+	whereClause, err := db.WhereParser(append(where, prefix[:]...))
+	if err != nil {
+		return nil, errSystemContractQueryError
+	}
+
+	iter, err := db.Select(BridgeIntentTable, whereClause)
+	if err != nil {
+		return nil, errSystemContractError
+	}
+	defer iter.Release()
+
+	var (
+		intents []BridgeIntent
+		intent  BridgeIntent
+	)
+	for iter.Next(&intent) {
+		intents = append(intents, intent)
+	}
+	return intents, nil
+}
+
+// CommitBridgeIntents stores the merkle root of the bridge intents of a block.
+// It's run by the consensus engine when finalizing the block, blocks without
+// intents get no commitment.
+func CommitBridgeIntents(db ebkdb.State, number uint64) error {
+	intents, err := GetBridgeIntents(db, number)
+	if err != nil || len(intents) == 0 {
+		return err
+	}
+	if commitment, err := GetBridgeCommitment(db, number); err != nil {
+		return err
+	} else if commitment != nil {
+		return errBridgeCommitmentDone
+	}
+
+	if !db.HasTable(BridgeCommitmentTable) {
+		db.CreateTable(BridgeCommitmentTable, &BridgeCommitment{})
+	}
+
+	commitment := &BridgeCommitment{
+		Root:  BridgeMerkleRoot(bridgeLeaves(intents)),
+		Count: uint64(len(intents)),
+	}
+	binary.BigEndian.PutUint64(commitment.Id[:], number)
+
+	if err := db.InsertObj(BridgeCommitmentTable, commitment); err != nil {
+		return errSystemContractError
+	}
+	return nil
+}
+
+// GetBridgeCommitment returns the commitment of the bridge intents of a block,
+// or nil if the block has none.
+func GetBridgeCommitment(db ebkdb.State, number uint64) (*BridgeCommitment, error) {
+	if !db.HasTable(BridgeCommitmentTable) {
+		return nil, nil
+	}
+
+	var id [8]byte
+	binary.BigEndian.PutUint64(id[:], number)
+
+	where := []byte("Id = ")
+	whereClause, err := db.WhereParser(append(where, id[:]...))
+	if err != nil {
+		return nil, errSystemContractQueryError
+	}
+
+	iter, err := db.Select(BridgeCommitmentTable, whereClause)
+	if err != nil {
+		return nil, errSystemContractError
+	}
+	defer iter.Release()
+
+	var commitment BridgeCommitment
+	if !iter.Next(&commitment) {
+		return nil, nil
+	}
+	return &commitment, nil
+}
+
+// GetBridgeProof returns the index-th bridge intent of a block along with the
+// merkle proof of its inclusion in the commitment of the block.
+func GetBridgeProof(db ebkdb.State, number uint64, index uint64) (*BridgeIntent, []common.Hash, *BridgeCommitment, error) {
+	commitment, err := GetBridgeCommitment(db, number)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if commitment == nil {
+		return nil, nil, nil, errBridgeNoCommitment
+	}
+	intents, err := GetBridgeIntents(db, number)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if index >= uint64(len(intents)) {
+		return nil, nil, nil, errBridgeIndexNotFound
+	}
+	return &intents[index], BridgeMerkleProof(bridgeLeaves(intents), int(index)), commitment, nil
+}
+
+func bridgeLeaves(intents []BridgeIntent) []common.Hash {
+	leaves := make([]common.Hash, len(intents))
+	for i := range intents {
+		leaves[i] = intents[i].Leaf()
+	}
+	return leaves
+}
+
+// bridgeNode hashes two sibling nodes of the merkle tree. Leaves and nodes are
+// domain separated, so a node can't be passed off as a leaf.
+func bridgeNode(left common.Hash, right common.Hash) common.Hash {
+	return crypto.Keccak256Hash([]byte{0x01}, left[:], right[:])
+}
+
+// BridgeMerkleRoot returns the root of the binary merkle tree of the leaves.
+func BridgeMerkleRoot(leaves []common.Hash) common.Hash {
+	if len(leaves) == 0 {
+		return common.Hash{}
+	}
+	level := leaves
+	for len(level) > 1 {
+		level = bridgeLevel(level)
+	}
+	return level[0]
+}
+
+// BridgeMerkleProof returns the sibling nodes from the leaf at index up to the
+// root of the binary merkle tree of the leaves.
+func BridgeMerkleProof(leaves []common.Hash, index int) []common.Hash {
+	var proof []common.Hash
+
+	level := leaves
+	for len(level) > 1 {
+		sibling := index ^ 1
+		if sibling >= len(level) {
+			sibling = index
+		}
+		proof = append(proof, level[sibling])
+
+		level, index = bridgeLevel(level), index/2
+	}
+	return proof
+}
+
+// bridgeLevel hashes the pairs of nodes of a merkle tree level into the level
+// above it. The last node of a level with an odd number of nodes is paired with
+// itself.
+func bridgeLevel(level []common.Hash) []common.Hash {
+	next := make([]common.Hash, (len(level)+1)/2)
+	for i := range next {
+		left, right := level[2*i], level[2*i]
+		if 2*i+1 < len(level) {
+			right = level[2*i+1]
+		}
+		next[i] = bridgeNode(left, right)
+	}
+	return next
+}
+
+// VerifyBridgeProof checks that the leaf at index is included in the merkle
+// tree with the given root.
+func VerifyBridgeProof(root common.Hash, leaf common.Hash, index uint64, proof []common.Hash) bool {
+	node := leaf
+	for _, sibling := range proof {
+		if index&1 == 0 {
+			node = bridgeNode(node, sibling)
+		} else {
+			node = bridgeNode(sibling, node)
+		}
+		index >>= 1
+	}
+	return index == 0 && node == root
+}
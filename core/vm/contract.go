@@ -59,6 +59,8 @@ type Contract struct {
 
 	Gas   uint64
 	value *big.Int
+
+	delegate bool // Whether the contract runs in the context of its caller
 }
 
 // NewContract returns a new contract environment for the execution of EVM.
@@ -122,6 +124,7 @@ func (c *Contract) AsDelegate() *Contract {
 	parent := c.caller.(*Contract)
 	c.CallerAddress = parent.CallerAddress
 	c.value = parent.value
+	c.delegate = true
 
 	return c
 }
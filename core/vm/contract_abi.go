@@ -0,0 +1,212 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/core/types"
+)
+
+var errContractAbiUnauthorized = errors.New("only the contract or its creator can update its abi")
+
+// ContractCreator records the account which created a contract, authorizing it
+// to publish new versions of the contract's ABI.
+type ContractCreator struct {
+	Id      common.Address
+	Creator common.Address
+}
+
+var ContractCreatorsTable = ebkdb.GetDBTableName(types.PrecompliledSystemContract, "ContractCreators")
+
+// contractAbiVersionId returns the id of an ABI version of a contract. The first
+// version keeps the unversioned id the ABIs were stored under before versioning,
+// while later ones append the big endian version, so the versions sort in order.
+func contractAbiVersionId(address common.Address, version uint64) ContractAbiId {
+	id := GetContractAbiId(address, "abi", "")
+	if version == 0 {
+		return id
+	}
+	versioned := make(ContractAbiId, len(id)+8)
+	copy(versioned, id)
+	binary.BigEndian.PutUint64(versioned[len(id):], version)
+	return versioned
+}
+
+// contractAbiVersion returns the version of an ABI id of the contract, and
+// whether the id is one of its ABI versions at all.
+func contractAbiVersion(address common.Address, id ContractAbiId) (uint64, bool) {
+	prefix := GetContractAbiId(address, "abi", "")
+	switch {
+	case !bytes.HasPrefix(id, prefix):
+		return 0, false
+	case len(id) == len(prefix):
+		return 0, true
+	case len(id) == len(prefix)+8:
+		return binary.BigEndian.Uint64(id[len(prefix):]), true
+	default:
+		return 0, false
+	}
+}
+
+// recordContractCreator records the creator of a newly created contract.
+func recordContractCreator(db ebkdb.State, address common.Address, creator common.Address) error {
+	if !db.HasTable(ContractCreatorsTable) {
+		db.CreateTable(ContractCreatorsTable, &ContractCreator{})
+	}
+	if err := db.InsertObj(ContractCreatorsTable, &ContractCreator{Id: address, Creator: creator}); err != nil {
+		return errSystemContractError
+	}
+	return nil
+}
+
+// GetContractCreator returns the account which created the contract, if it was
+// created after the ABI versioning fork.
+func GetContractCreator(db ebkdb.State, address common.Address) (common.Address, bool, error) {
+	if !db.HasTable(ContractCreatorsTable) {
+		return common.Address{}, false, nil
+	}
+	whereClause, err := makeIDLikeWhereClause(db, address)
+	if err != nil {
+		return common.Address{}, false, err
+	}
+	iter, err := db.Select(ContractCreatorsTable, whereClause)
+	if err != nil {
+		return common.Address{}, false, errSystemContractError
+	}
+	defer iter.Release()
+
+	var entry ContractCreator
+	if !iter.Next(&entry) || entry.Id != address {
+		return common.Address{}, false, nil
+	}
+	return entry.Creator, true, nil
+}
+
+// GetAbiVersionsAtAddress returns the versions of the ABI stored for the
+// contract, in ascending order.
+func GetAbiVersionsAtAddress(db ebkdb.State, contractAddress common.Address) ([]uint64, error) {
+	idPrefix := GetContractAbiId(contractAddress, "abi", "")
+
+	where := []byte("Id LIKE ")
+	whereClause, err := db.WhereParser(append(where, idPrefix...))
+	if err != nil {
+		return nil, errSystemContractQueryError
+	}
+	iter, err := db.Select(ContractAbiTable, whereClause)
+	if err != nil {
+		return nil, errSystemContractError
+	}
+	defer iter.Release()
+
+	versions := []uint64{}
+
+	var contractAbi ContractAbi
+	for iter.Next(&contractAbi) {
+		if version, ok := contractAbiVersion(contractAddress, contractAbi.Id); ok {
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
+
+// storeAbiVersion stores the ABI of a contract. Anyone may store the first
+// version, while later ones can be published only by the contract itself or by
+// the account which created it.
+func storeAbiVersion(db ebkdb.State, from common.Address, contractAddress common.Address, abi string) ([]byte, error) {
+	versions, err := GetAbiVersionsAtAddress(db, contractAddress)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return storeAbiAtAddress(db, contractAddress, abi)
+	}
+
+	if from != contractAddress {
+		creator, ok, err := GetContractCreator(db, contractAddress)
+		if err != nil {
+			return nil, err
+		}
+		if !ok || creator != from {
+			return nil, errContractAbiUnauthorized
+		}
+	}
+
+	contractAbi := ContractAbi{
+		Id:  contractAbiVersionId(contractAddress, versions[len(versions)-1]+1),
+		Abi: abi,
+	}
+	if err := db.InsertObj(ContractAbiTable, &contractAbi); err != nil {
+		return nil, errSystemContractError
+	}
+	return nil, nil
+}
+
+// ContractTable is a table a contract created through the DB contract.
+type ContractTable struct {
+	Contract common.Address
+	Name     string
+}
+
+// GetContractTables returns the tables the contracts created through the DB
+// contract, read off the table ABIs stored for them.
+func GetContractTables(db ebkdb.State) ([]ContractTable, error) {
+	if !db.HasTable(ContractAbiTable) {
+		return nil, nil
+	}
+	iter, err := db.Select(ContractAbiTable)
+	if err != nil {
+		return nil, errSystemContractError
+	}
+	defer iter.Release()
+
+	var (
+		tables      []ContractTable
+		contractAbi ContractAbi
+		tableType   = []byte("table")
+	)
+	for iter.Next(&contractAbi) {
+		id := contractAbi.Id
+		if len(id) <= common.AddressLength+len(tableType) || !bytes.Equal(id[common.AddressLength:common.AddressLength+len(tableType)], tableType) {
+			continue
+		}
+		tables = append(tables, ContractTable{
+			Contract: common.BytesToAddress(id[:common.AddressLength]),
+			Name:     string(id[common.AddressLength+len(tableType):]),
+		})
+	}
+	return tables, nil
+}
+
+// ClearContractTable deletes all the rows of a table a contract created,
+// returning their number. The table itself and its ABI are kept.
+func ClearContractTable(db ebkdb.State, contractAddress common.Address, name string) (int, error) {
+	tableABI, err := GetAbiForTable(db, contractAddress, name)
+	if err != nil {
+		return 0, err
+	}
+	obj, err := tableABI.GetTableInstance(name)
+	if err != nil {
+		return 0, err
+	}
+	return ebkdb.ClearTable(db, ebkdb.GetDBTableName(contractAddress, name), reflect.TypeOf(obj).Elem())
+}
@@ -21,21 +21,22 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"math/big"
+	"reflect"
 	"strings"
-	"sync"
-	"unsafe"
 
-	"github.com/ebakus/ebakusdb"
 	"github.com/ebakus/go-ebakus/accounts/abi"
 	"github.com/ebakus/go-ebakus/common"
 	"github.com/ebakus/go-ebakus/common/math"
+	"github.com/ebakus/go-ebakus/common/priolock"
 	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/types"
 	"github.com/ebakus/go-ebakus/crypto"
 	"github.com/ebakus/go-ebakus/crypto/blake2b"
 	"github.com/ebakus/go-ebakus/crypto/bn256"
 	"github.com/ebakus/go-ebakus/log"
+	"github.com/ebakus/go-ebakus/metrics"
 	"github.com/ebakus/go-ebakus/params"
 	"golang.org/x/crypto/ripemd160"
 )
@@ -64,15 +65,119 @@ var PrecompiledContractsEbakus = map[common.Address]PrecompiledContract{
 	types.PrecompliledDBContract:     &dbContract{},
 }
 
-var systemContractMux sync.Mutex
+// PrecompiledContractsWrappedToken contains the default set of pre-compiled
+// Ebakus contracts used after the wrapped token fork.
+var PrecompiledContractsWrappedToken = map[common.Address]PrecompiledContract{
+	common.BytesToAddress([]byte{1}): &ecrecover{},
+	common.BytesToAddress([]byte{2}): &sha256hash{},
+	common.BytesToAddress([]byte{3}): &ripemd160hash{},
+	common.BytesToAddress([]byte{4}): &dataCopy{},
+	common.BytesToAddress([]byte{5}): &bigModExp{},
+	common.BytesToAddress([]byte{6}): &bn256AddIstanbul{},
+	common.BytesToAddress([]byte{7}): &bn256ScalarMulIstanbul{},
+	common.BytesToAddress([]byte{8}): &bn256PairingIstanbul{},
+	common.BytesToAddress([]byte{9}): &blake2F{},
+	types.PrecompliledSystemContract: &systemContract{},
+	types.PrecompliledDBContract:     &dbContract{},
+	types.PrecompliledWrappedToken:   &wrappedToken{},
+}
+
+// systemContractMux serializes ebakus db access of the precompiles, giving
+// priority to block import and production over RPC calls.
+var systemContractMux priolock.Mutex
+
+var precompileImportWaitTimer = metrics.NewRegisteredTimer("vm/precompiles/importwait", nil)
+
+// readOnlyPrecompileCmds are the system and db contract commands allowed within
+// static calls.
+var readOnlyPrecompileCmds = map[string]bool{
+	SystemContractGetStakedCmd:      true,
+	SystemContractGetAbiCmd:         true,
+	SystemContractGetAbiVersionsCmd: true,
+	SystemContractAllowanceCmd:      true,
+	DBContractGetCmd:                true,
+	DBContractSelectCmd:             true,
+	DBContractNextCmd:               true,
+	DBContractCloseIteratorCmd:      true,
+	DBContractSavepointCmd:          true,
+	DBContractReleaseSavepointCmd:   true,
+	WrappedTokenNameCmd:             true,
+	WrappedTokenSymbolCmd:           true,
+	WrappedTokenDecimalsCmd:         true,
+	WrappedTokenTotalSupplyCmd:      true,
+	WrappedTokenBalanceOfCmd:        true,
+}
+
+// checkPrecompileCall enforces the rules of calling the system and db contracts
+// from other contracts:
+//
+//   - The staker and the tables namespace are always the direct caller. Library
+//     code invoked through a delegate call runs in the context of the calling
+//     contract, so its calls act on behalf of that contract.
+//   - Delegate calls to them are rejected, as they would act on behalf of the
+//     caller's caller, e.g. unstaking the tokens of the transaction sender.
+//   - Within static calls only the reading commands are allowed.
+//   - A failing call reverts its own ebakusdb writes, and a reverting caller
+//     reverts all the writes of its frame, like with account storage.
+//
+// Other precompiles are stateless and unaffected.
+func checkPrecompileCall(p PrecompiledContract, contract *Contract, input []byte, readOnly bool) error {
+	contractABI := precompileABI(p)
+	if contractABI == "" {
+		return nil
+	}
+	if contract.delegate {
+		return errPrecompileDelegateCall
+	}
+	if !readOnly {
+		return nil
+	}
+	evmABI, err := abi.JSON(strings.NewReader(contractABI))
+	if err != nil {
+		return err
+	}
+	method, err := evmABI.MethodById(input)
+	if err != nil || !readOnlyPrecompileCmds[method.Name] {
+		return errWriteProtection
+	}
+	return nil
+}
 
 // RunPrecompiledContract runs and evaluates the output of a precompiled contract.
 func RunPrecompiledContract(evm *EVM, p PrecompiledContract, input []byte, contract *Contract) (ret []byte, err error) {
-	systemContractMux.Lock()
+	if evm.lowPriority {
+		systemContractMux.LockLow()
+	} else {
+		precompileImportWaitTimer.Update(systemContractMux.Lock())
+	}
 	defer systemContractMux.Unlock()
 
 	db := evm.EbakusState
-	preUsedMemory := db.GetObjAllocated()
+	preUsedMemory := db.GetUsedMemory()
+
+	if tracer, ok := evm.vmConfig.Tracer.(PrecompileTracer); ok && evm.vmConfig.Debug {
+		if frame := newPrecompileFrame(evm, p, contract.Address(), input); frame != nil {
+			var (
+				gas  = contract.Gas
+				rows = evm.tracedRows
+			)
+			evm.EbakusState = &tracedState{State: db, rows: &evm.tracedRows}
+			defer func() {
+				evm.EbakusState = db
+
+				frame.RowsRead = evm.tracedRows.read - rows.read
+				frame.RowsWritten = evm.tracedRows.written - rows.written
+				if err == nil {
+					frame.GasUsed = gas - contract.Gas
+					frame.MemoryGas = frame.GasUsed - frame.Gas
+				}
+				if frame.Err = err; err != nil {
+					frame.Error = err.Error()
+				}
+				tracer.CapturePrecompile(evm, frame)
+			}()
+		}
+	}
 
 	minimumGas := p.RequiredGas(input)
 	if contract.Gas < minimumGas {
@@ -80,7 +185,7 @@ func RunPrecompiledContract(evm *EVM, p PrecompiledContract, input []byte, contr
 	}
 	ret, err = p.Run(evm, contract, input)
 
-	postUsedMemory := db.GetObjAllocated()
+	postUsedMemory := db.GetUsedMemory()
 	usedMemoryGas := minimumGas
 	usedMemory := int64(postUsedMemory - preUsedMemory)
 
@@ -103,12 +208,33 @@ const (
 	SystemContractUnstakeCmd   = "unstake"
 	SystemContractClaimCmd     = "claim"
 
-	SystemContractVoteCmd        = "vote"
-	SystemContractUnvoteCmd      = "unvote"
-	SystemContractElectEnableCmd = "electEnable"
+	SystemContractVoteCmd            = "vote"
+	SystemContractUnvoteCmd          = "unvote"
+	SystemContractUnvoteAddressesCmd = "unvoteAddresses"
+	SystemContractElectEnableCmd     = "electEnable"
 
-	SystemContractStoreAbiCmd = "storeAbiForAddress"
-	SystemContractGetAbiCmd   = "getAbiForAddress"
+	SystemContractStoreAbiCmd       = "storeAbiForAddress"
+	SystemContractGetAbiCmd         = "getAbiForAddress"
+	SystemContractGetAbiVersionsCmd = "getAbiVersionsForAddress"
+
+	SystemContractSetTablesAliasCmd    = "setTablesAlias"
+	SystemContractAcceptTablesAliasCmd = "acceptTablesAlias"
+	SystemContractRemoveTablesAliasCmd = "removeTablesAlias"
+
+	SystemContractTransferLockedCmd = "transferLocked"
+
+	SystemContractSubscribeCmd   = "subscribe"
+	SystemContractUnsubscribeCmd = "unsubscribe"
+	SystemContractSettleCmd      = "settle"
+
+	SystemContractApproveCmd      = "approve"
+	SystemContractAllowanceCmd    = "allowance"
+	SystemContractTransferFromCmd = "transferFrom"
+
+	SystemContractBridgeIntentCmd = "bridgeIntent"
+
+	SystemContractAnnounceEnodeCmd   = "announceEnode"
+	SystemContractSetWitnessEnodeCmd = "setWitnessEnode"
 
 	DBContractCreateTableCmd = "createTable"
 	DBContractInsertObjCmd   = "insertObj"
@@ -116,16 +242,30 @@ const (
 	DBContractGetCmd         = "get"
 	DBContractSelectCmd      = "select"
 	DBContractNextCmd        = "next"
+
+	DBContractCloseIteratorCmd = "closeIterator"
+
+	DBContractCollectGarbageCmd = "collectGarbage"
+	DBContractUpdateObjCmd      = "updateObj"
+
+	DBContractSavepointCmd        = "savepoint"
+	DBContractRollbackCmd         = "rollback"
+	DBContractReleaseSavepointCmd = "releaseSavepoint"
 )
 
 const (
 	maxClaimableEntries  = 5
-	unstakeVestingPeriod = 60 * 60 * 24 * 3 // (3 days) Number of seconds taken for tokens to become claimable
-)
+	unstakeVestingPeriod = 60 * 60 * 24 * 3       // (3 days) Number of seconds taken for tokens to become claimable
+	claimMergeWindow     = 60 * 60 * 24           // (1 day) Claimable entries vesting this close to a new unstake are merged into it
+	maxLockedTransfers   = 32                     // Max pending locked transfers from a sender to a recipient, to protect system abuse
+	maxLockedPeriod      = 60 * 60 * 24 * 365 * 2 // (2 years) Max number of seconds a transfer can stay locked
+	minLockedTransferWei = 1e16                   // (0.01 EBK) Min wei locked by a transfer, so dust can't fill the recipient's transfers
 
-var (
-	valueDecimalPoints = int64(4)
-	precisionFactor    = new(big.Int).Exp(big.NewInt(10), big.NewInt(18-valueDecimalPoints), nil)
+	minSubscriptionInterval = 60 * 60 // (1 hour) Minimum number of seconds between the payments of a subscription
+
+	maxGarbageCollectRows = 100 // Max rows of a tombstoned table deleted per collectGarbage call
+
+	tablesAliasDelay = 60 * 60 * 24 // (1 day) Number of seconds before a tables alias becomes active
 )
 
 var (
@@ -135,6 +275,8 @@ var (
 
 	errStakeMalformed        = errors.New("staking transaction malformed")
 	errStakeNotEnoughBalance = errors.New("not enough balance for staking")
+	errStakeOverflow         = errors.New("staked amount overflows")
+	errSystemStakeUnderflow  = errors.New("system staked amount underflows")
 
 	errUnstakeMalformed             = errors.New("unstaking transaction malformed")
 	errUnstakeTooManyClaimable      = errors.New("unstaking failure because of too many claimable entries")
@@ -144,10 +286,29 @@ var (
 	errVoteAddressIsNotWitness = errors.New("a voted address is not valid")
 	errVoteNothingStaked       = errors.New("nothing staked")
 	errVoteMaxWitnessesReached = errors.New("not allowed to vote more than 20 witnesses")
+	errUnvoteMalformed         = errors.New("unvoting transaction malformed")
+	errUnvoteAddressNotVoted   = errors.New("an unvoted address is not voted")
 	errElectEnableMalformed    = errors.New("elect enable transaction malformed")
 	errContractAbiMalformed    = errors.New("contract abi transaction malformed")
 	errContractAbiNotFound     = errors.New("contract abi not found")
 	errContractAbiExists       = errors.New("contract abi exists")
+	errTablesAliasMalformed    = errors.New("tables alias transaction malformed")
+	errTablesAliasInvalid      = errors.New("tables alias address is invalid")
+	errTablesAliasExists       = errors.New("tables alias exists")
+	errTablesAliasNotFound     = errors.New("tables alias not found")
+
+	errLockedTransferMalformed = errors.New("locked transfer transaction malformed")
+	errLockedTransferInvalid   = errors.New("locked transfer recipient, amount or unlock time is invalid")
+	errLockedTransferTooMany   = errors.New("too many pending locked transfers from the sender to the recipient")
+
+	errSubscriptionMalformed = errors.New("subscription transaction malformed")
+	errSubscriptionInvalid   = errors.New("subscription payee, amount, interval, expiry or allowance is invalid")
+	errSubscriptionNotFound  = errors.New("subscription not found")
+	errSubscriptionNotDue    = errors.New("no subscription payment is due")
+
+	errAllowanceMalformed = errors.New("allowance transaction malformed")
+	errAllowanceInvalid   = errors.New("allowance spender or recipient is invalid")
+	errAllowanceExceeded  = errors.New("amount exceeds the allowance")
 
 	errDBContractError      = errors.New("db contract error")
 	errNoEntryFound         = errors.New("no entry found in db")
@@ -159,6 +320,24 @@ var (
 	errDeleteObjMalformed   = errors.New("delete object transaction malformed")
 	errSelectMalformed      = errors.New("db select transaction malformed")
 	errIteratorMalformed    = errors.New("next iterator transaction malformed")
+	errTableTombstoned      = errors.New("tables of self-destructed contract are read only")
+	errTableNotTombstoned   = errors.New("tables are not tombstoned")
+	errGarbageMalformed     = errors.New("collect garbage transaction malformed")
+	errUpdateObjMalformed   = errors.New("update object transaction malformed")
+	errUpdateFieldInvalid   = errors.New("updated field is unknown, duplicate or the id")
+	errSavepointMalformed   = errors.New("savepoint transaction malformed")
+	errSavepointNotFound    = errors.New("savepoint not found in the call frame")
+	errSavepointNotContract = errors.New("savepoints can only be taken by contracts")
+	errSavepointCrossed     = errors.New("can't roll back over other contract or system contract calls")
+	errIteratorNotFound     = errors.New("iterator not found")
+
+	errInputMethodTooShort = errors.New("input too short for method id")
+	errInputMisaligned     = errors.New("input is not 32 bytes aligned")
+	errInputTruncated      = errors.New("input truncated")
+	errInputOversized      = errors.New("input has trailing bytes")
+	errInputBadOffset      = errors.New("input has out of bounds offset")
+
+	errPrecompileDelegateCall = errors.New("system and db contracts can't be delegate called")
 )
 
 const (
@@ -170,7 +349,7 @@ const (
 type systemContract struct{}
 
 func (c *systemContract) RequiredGas(input []byte) uint64 {
-	if len(input) == 0 {
+	if len(input) < 4 {
 		return params.SystemContractBaseGas
 	}
 
@@ -204,12 +383,36 @@ func (c *systemContract) RequiredGas(input []byte) uint64 {
 		return params.SystemContractVoteGas * uint64(len(addresses))
 	case SystemContractUnvoteCmd:
 		return params.SystemContractUnvoteGas
+	case SystemContractUnvoteAddressesCmd:
+		var addresses []common.Address
+		if err = evmABI.UnpackWithArguments(&addresses, cmd, inputData, abi.InputsArgumentsType); err != nil {
+			return params.SystemContractBaseGas
+		}
+		return params.SystemContractUnvoteAddrGas * uint64(len(addresses))
 	case SystemContractElectEnableCmd:
 		return params.SystemContractElectEnableGas
 	case SystemContractStoreAbiCmd:
 		return params.SystemContractStoreAbiGas
-	case SystemContractGetAbiCmd:
+	case SystemContractGetAbiCmd, SystemContractGetAbiVersionsCmd:
 		return params.SystemContractGetAbiGas
+	case SystemContractSetTablesAliasCmd, SystemContractAcceptTablesAliasCmd, SystemContractRemoveTablesAliasCmd:
+		return params.SystemContractTablesAliasGas
+	case SystemContractTransferLockedCmd:
+		return params.SystemContractLockedGas
+	case SystemContractSubscribeCmd, SystemContractUnsubscribeCmd:
+		return params.SystemContractSubscribeGas
+	case SystemContractSettleCmd:
+		return params.SystemContractSettleGas
+	case SystemContractApproveCmd:
+		return params.SystemContractApproveGas
+	case SystemContractAllowanceCmd:
+		return params.SystemContractAllowanceGas
+	case SystemContractTransferFromCmd:
+		return params.SystemContractTransferGas
+	case SystemContractBridgeIntentCmd:
+		return params.SystemContractBridgeGas
+	case SystemContractAnnounceEnodeCmd, SystemContractSetWitnessEnodeCmd:
+		return params.SystemContractAnnounceGas
 	default:
 		return params.SystemContractBaseGas
 	}
@@ -247,7 +450,12 @@ func (s WitnessArray) Diff(b WitnessArray) types.DelegateDiff {
 
 var WitnessesTable = ebkdb.GetDBTableName(types.PrecompliledSystemContract, "Witnesses")
 
-type ClaimableId [common.AddressLength + unsafe.Sizeof(uint64(0))]byte // address + timestamp
+// uint64Length is the encoded size of the uint64 fields of the system contract
+// ids. Unlike the native integer size, it is the same on every architecture.
+const uint64Length = 8
+
+// ClaimableId is the account and the claimable time of an unstaked amount.
+type ClaimableId [common.AddressLength + uint64Length]byte // address + timestamp
 
 type Claimable struct {
 	Id        ClaimableId
@@ -257,16 +465,243 @@ type Claimable struct {
 
 var ClaimableTable = ebkdb.GetDBTableName(types.PrecompliledSystemContract, "Claimable")
 
+// GetClaimableId returns the id of the amount an account can claim at timestamp.
+//
+// Contrary to the rest of the ids the timestamp is little endian. The ids are
+// persisted in the system contract state, so the layout must not change.
 func GetClaimableId(from common.Address, timestamp uint64) ClaimableId {
-	timestampBytes := make([]byte, 8)
-	binary.LittleEndian.PutUint64(timestampBytes, timestamp)
-
 	var id ClaimableId
-	b := bytes.Join([][]byte{from.Bytes(), timestampBytes}, []byte(""))
-	copy(id[:], b)
+
+	copy(id[:], from[:])
+	binary.LittleEndian.PutUint64(id[common.AddressLength:], timestamp)
+
+	return id
+}
+
+// LockedTransferId is the recipient, the unlock time and the sender of a time
+// locked transfer, so the transfers to a recipient are iterated by unlock time.
+type LockedTransferId [common.AddressLength*2 + uint64Length]byte // to + unlock time + from
+
+// LockedTransfer is an amount the recipient can only claim after UnlockTime.
+type LockedTransfer struct {
+	Id         LockedTransferId
+	Amount     uint64
+	UnlockTime uint64
+}
+
+var LockedTransferTable = ebkdb.GetDBTableName(types.PrecompliledSystemContract, "LockedTransfers")
+
+// GetLockedTransferId returns the id of the transfers from an account to a
+// recipient unlocked at the same time.
+func GetLockedTransferId(to common.Address, from common.Address, unlockTime uint64) LockedTransferId {
+	var id LockedTransferId
+
+	copy(id[:], to[:])
+	binary.BigEndian.PutUint64(id[common.AddressLength:], unlockTime)
+	copy(id[common.AddressLength+uint64Length:], from[:])
+
+	return id
+}
+
+// Content gets the recipient, sender and unlock time of a locked transfer.
+func (id LockedTransferId) Content() (to common.Address, from common.Address, unlockTime uint64) {
+	to = common.BytesToAddress(id[:common.AddressLength])
+	unlockTime = binary.BigEndian.Uint64(id[common.AddressLength:])
+	from = common.BytesToAddress(id[common.AddressLength+uint64Length:])
+	return
+}
+
+// GetLockedTransfers returns the pending locked transfers to a recipient, sorted
+// by unlock time.
+func GetLockedTransfers(db ebkdb.State, to common.Address) ([]LockedTransfer, error) {
+	if !db.HasTable(LockedTransferTable) {
+		return nil, nil
+	}
+
+	whereClause, err := makeIDLikeWhereClause(db, to)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := db.Select(LockedTransferTable, whereClause)
+	if err != nil {
+		return nil, errSystemContractError
+	}
+	defer iter.Release()
+
+	var (
+		transfers []LockedTransfer
+		transfer  LockedTransfer
+	)
+	for iter.Next(&transfer) {
+		transfers = append(transfers, transfer)
+		transfer = LockedTransfer{}
+	}
+	return transfers, nil
+}
+
+// SubscriptionId is the payer and the payee of a subscription.
+type SubscriptionId [common.AddressLength * 2]byte // payer + payee
+
+// Subscription pre-authorizes the payee to pull Amount from the payer every
+// Interval seconds until Expiry, up to a total of Allowance.
+type Subscription struct {
+	Id         SubscriptionId
+	Amount     uint64
+	Interval   uint64
+	Expiry     uint64
+	NextSettle uint64 // Timestamp the next unsettled payment is due at
+	Allowance  uint64 // Amount left to be paid
+}
+
+var SubscriptionTable = ebkdb.GetDBTableName(types.PrecompliledSystemContract, "Subscriptions")
+
+// GetSubscriptionId returns the id of the subscription of a payer to a payee.
+func GetSubscriptionId(payer common.Address, payee common.Address) SubscriptionId {
+	var id SubscriptionId
+
+	copy(id[:], payer[:])
+	copy(id[common.AddressLength:], payee[:])
+
+	return id
+}
+
+// Content gets the payer and payee of a subscription.
+func (id SubscriptionId) Content() (payer common.Address, payee common.Address) {
+	payer = common.BytesToAddress(id[:common.AddressLength])
+	payee = common.BytesToAddress(id[common.AddressLength:])
+	return
+}
+
+// GetSubscription returns the subscription of a payer to a payee, or nil if
+// none exists.
+func GetSubscription(db ebkdb.State, payer common.Address, payee common.Address) (*Subscription, error) {
+	if !db.HasTable(SubscriptionTable) {
+		return nil, nil
+	}
+
+	id := GetSubscriptionId(payer, payee)
+
+	where := []byte("Id = ")
+	whereClause, err := db.WhereParser(append(where, id[:]...))
+	if err != nil {
+		return nil, errSystemContractQueryError
+	}
+
+	iter, err := db.Select(SubscriptionTable, whereClause)
+	if err != nil {
+		return nil, errSystemContractError
+	}
+	defer iter.Release()
+
+	var subscription Subscription
+	if !iter.Next(&subscription) {
+		return nil, nil
+	}
+	return &subscription, nil
+}
+
+// GetSubscriptions returns the subscriptions of a payer.
+func GetSubscriptions(db ebkdb.State, payer common.Address) ([]Subscription, error) {
+	if !db.HasTable(SubscriptionTable) {
+		return nil, nil
+	}
+
+	whereClause, err := makeIDLikeWhereClause(db, payer)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := db.Select(SubscriptionTable, whereClause)
+	if err != nil {
+		return nil, errSystemContractError
+	}
+	defer iter.Release()
+
+	var (
+		subscriptions []Subscription
+		subscription  Subscription
+	)
+	for iter.Next(&subscription) {
+		subscriptions = append(subscriptions, subscription)
+		subscription = Subscription{}
+	}
+	return subscriptions, nil
+}
+
+// AllowanceId is the owner and the spender of an allowance.
+type AllowanceId [common.AddressLength * 2]byte // owner + spender
+
+// Allowance is the amount of native tokens the spender may pull from the owner.
+type Allowance struct {
+	Id     AllowanceId
+	Amount uint64
+}
+
+var AllowanceTable = ebkdb.GetDBTableName(types.PrecompliledSystemContract, "Allowances")
+
+// GetAllowanceId returns the id of the allowance of an owner to a spender.
+func GetAllowanceId(owner common.Address, spender common.Address) AllowanceId {
+	var id AllowanceId
+
+	copy(id[:], owner[:])
+	copy(id[common.AddressLength:], spender[:])
+
 	return id
 }
 
+// GetAllowance returns the amount the spender may pull from the owner.
+func GetAllowance(db ebkdb.State, owner common.Address, spender common.Address) (uint64, error) {
+	if !db.HasTable(AllowanceTable) {
+		return 0, nil
+	}
+
+	id := GetAllowanceId(owner, spender)
+
+	where := []byte("Id = ")
+	whereClause, err := db.WhereParser(append(where, id[:]...))
+	if err != nil {
+		return 0, errSystemContractQueryError
+	}
+
+	iter, err := db.Select(AllowanceTable, whereClause)
+	if err != nil {
+		return 0, errSystemContractError
+	}
+	defer iter.Release()
+
+	var allowance Allowance
+	if !iter.Next(&allowance) {
+		return 0, nil
+	}
+	return allowance.Amount, nil
+}
+
+// setAllowance stores the amount the spender may pull from the owner, deleting
+// the allowance once it's zero.
+func setAllowance(db ebkdb.State, owner common.Address, spender common.Address, amount uint64) error {
+	id := GetAllowanceId(owner, spender)
+
+	if amount == 0 {
+		if !db.HasTable(AllowanceTable) {
+			return nil
+		}
+		if err := db.DeleteObj(AllowanceTable, id); err != nil {
+			return errSystemContractError
+		}
+		return nil
+	}
+
+	if !db.HasTable(AllowanceTable) {
+		db.CreateTable(AllowanceTable, &Allowance{})
+	}
+
+	if err := db.InsertObj(AllowanceTable, &Allowance{Id: id, Amount: amount}); err != nil {
+		return errSystemContractError
+	}
+	return nil
+}
+
 // DelegationId represents the 40 byte of two 20 bytes addresses combined.
 type DelegationId [common.AddressLength * 2]byte
 
@@ -316,7 +751,172 @@ func GetContractAbiId(address common.Address, abiType string, name string) Contr
 
 var ContractAbiTable = ebkdb.GetDBTableName(types.PrecompliledSystemContract, "ContractAbi")
 
-func SystemContractSetupDB(db *ebakusdb.Snapshot, address common.Address) error {
+// Tombstone marks the table namespace of a self-destructed contract. Its tables
+// become read only and anyone can garbage collect their rows, until code is
+// deployed again at the address through CREATE2.
+type Tombstone struct {
+	Id    common.Address
+	Block uint64 // Block number the contract self-destructed at
+}
+
+var TombstoneTable = ebkdb.GetDBTableName(types.PrecompliledSystemContract, "Tombstones")
+
+// IsContractTombstoned returns whether the tables of contractAddress have been
+// tombstoned because the contract self-destructed.
+func IsContractTombstoned(db ebkdb.State, contractAddress common.Address) (bool, error) {
+	if !db.HasTable(TombstoneTable) {
+		return false, nil
+	}
+
+	where := []byte("Id = ")
+	whereClause, err := db.WhereParser(append(where, contractAddress.Bytes()...))
+	if err != nil {
+		return false, errSystemContractQueryError
+	}
+
+	iter, err := db.Select(TombstoneTable, whereClause)
+	if err != nil {
+		return false, errSystemContractError
+	}
+	defer iter.Release()
+
+	var tombstone Tombstone
+	return iter.Next(&tombstone), nil
+}
+
+// TablesAlias grants the Id contract access to the tables namespace of Owner,
+// so upgraded contracts deployed at a new address keep access to their data.
+// The alias only takes effect once the Id contract accepted it, so no contract
+// can be pointed at the tables of another without its consent.
+type TablesAlias struct {
+	Id       common.Address
+	Owner    common.Address
+	ActiveAt uint64 // Timestamp the alias becomes active at
+	Accepted bool   // Whether the Id contract accepted the alias
+}
+
+var TablesAliasTable = ebkdb.GetDBTableName(types.PrecompliledSystemContract, "TablesAliases")
+
+// GetTablesAlias returns the alias entry of the given address, or nil if none exists.
+func GetTablesAlias(db ebkdb.State, alias common.Address) (*TablesAlias, error) {
+	if !db.HasTable(TablesAliasTable) {
+		return nil, nil
+	}
+
+	where := []byte("Id = ")
+	whereClause, err := db.WhereParser(append(where, alias.Bytes()...))
+	if err != nil {
+		return nil, errSystemContractQueryError
+	}
+
+	iter, err := db.Select(TablesAliasTable, whereClause)
+	if err != nil {
+		return nil, errSystemContractError
+	}
+	defer iter.Release()
+
+	var tablesAlias TablesAlias
+	if iter.Next(&tablesAlias) == false {
+		return nil, nil
+	}
+
+	return &tablesAlias, nil
+}
+
+// GetTablesNamespace returns the address whose tables are accessed by
+// contractAddress at the given time. That is the owner of an accepted and
+// active alias, or the contract address itself.
+func GetTablesNamespace(db ebkdb.State, contractAddress common.Address, time uint64) (common.Address, error) {
+	tablesAlias, err := GetTablesAlias(db, contractAddress)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	if tablesAlias == nil || !tablesAlias.Accepted || tablesAlias.ActiveAt > time {
+		return contractAddress, nil
+	}
+
+	return tablesAlias.Owner, nil
+}
+
+func hasTablesAliases(db ebkdb.State, owner common.Address) (bool, error) {
+	if !db.HasTable(TablesAliasTable) {
+		return false, nil
+	}
+
+	where := []byte("Owner = ")
+	whereClause, err := db.WhereParser(append(where, owner.Bytes()...))
+	if err != nil {
+		return false, errSystemContractQueryError
+	}
+
+	iter, err := db.Select(TablesAliasTable, whereClause)
+	if err != nil {
+		return false, errSystemContractError
+	}
+	defer iter.Release()
+
+	var tablesAlias TablesAlias
+	return iter.Next(&tablesAlias), nil
+}
+
+// tombstoneContractTables marks the tables of a self-destructing contract as
+// tombstoned. Contracts which never created a table are left untouched.
+func tombstoneContractTables(db ebkdb.State, contractAddress common.Address, blockNumber uint64) error {
+	idPrefix := GetContractAbiId(contractAddress, "table", "")
+
+	where := []byte("Id LIKE ")
+	whereClause, err := db.WhereParser(append(where, idPrefix...))
+	if err != nil {
+		return errSystemContractQueryError
+	}
+
+	iter, err := db.Select(ContractAbiTable, whereClause)
+	if err != nil {
+		return errSystemContractError
+	}
+
+	var contractAbi ContractAbi
+	hasTables := iter.Next(&contractAbi)
+	iter.Release()
+
+	if !hasTables {
+		return nil
+	}
+
+	// an upgraded contract keeps using the tables through its aliases
+	aliased, err := hasTablesAliases(db, contractAddress)
+	if err != nil || aliased {
+		return err
+	}
+
+	tombstoned, err := IsContractTombstoned(db, contractAddress)
+	if err != nil || tombstoned {
+		return err
+	}
+
+	if !db.HasTable(TombstoneTable) {
+		db.CreateTable(TombstoneTable, &Tombstone{})
+	}
+
+	return db.InsertObj(TombstoneTable, &Tombstone{Id: contractAddress, Block: blockNumber})
+}
+
+// untombstoneContractTables lifts the tombstone of a self-destructed contract
+// when code is deployed again at its address through CREATE2. The new contract
+// takes the tables back, except for the rows garbage collected meanwhile.
+func untombstoneContractTables(db ebkdb.State, contractAddress common.Address) error {
+	tombstoned, err := IsContractTombstoned(db, contractAddress)
+	if err != nil || !tombstoned {
+		return err
+	}
+	if err := db.DeleteObj(TombstoneTable, contractAddress); err != nil {
+		return errSystemContractError
+	}
+	return nil
+}
+
+func SystemContractSetupDB(db ebkdb.State, address common.Address) error {
 
 	if db.HasTable(WitnessesTable) {
 		panic("Witnesses table existed in genesis")
@@ -339,7 +939,7 @@ func SystemContractSetupDB(db *ebakusdb.Snapshot, address common.Address) error
 	}
 
 	db.CreateTable(WitnessesTable, &Witness{})
-	db.CreateIndex(ebakusdb.IndexField{
+	db.CreateIndex(ebkdb.IndexField{
 		Table: WitnessesTable,
 		Field: "Stake",
 	})
@@ -366,7 +966,7 @@ func SystemContractSetupDB(db *ebakusdb.Snapshot, address common.Address) error
 	return nil
 }
 
-func DelegateVotingGetDelegates(snap *ebakusdb.Snapshot, maxWitnesses uint64) WitnessArray {
+func DelegateVotingGetDelegates(snap ebkdb.State, maxWitnesses uint64) WitnessArray {
 	res := make(WitnessArray, 0)
 
 	orderClause, err := snap.OrderParser([]byte("Stake DESC"))
@@ -395,7 +995,7 @@ func DelegateVotingGetDelegates(snap *ebakusdb.Snapshot, maxWitnesses uint64) Wi
 	return res
 }
 
-func makeIDLikeWhereClause(db *ebakusdb.Snapshot, from common.Address) (*ebakusdb.WhereField, error) {
+func makeIDLikeWhereClause(db ebkdb.State, from common.Address) (*ebkdb.WhereField, error) {
 	where := []byte("Id LIKE ")
 	whereClause, err := db.WhereParser(append(where, from.Bytes()...))
 	if err != nil {
@@ -404,7 +1004,7 @@ func makeIDLikeWhereClause(db *ebakusdb.Snapshot, from common.Address) (*ebakusd
 	return whereClause, nil
 }
 
-func vote(db *ebakusdb.Snapshot, from common.Address, addresses []common.Address, amount uint64) error {
+func vote(db ebkdb.State, from common.Address, addresses []common.Address, amount uint64) error {
 	for _, address := range addresses {
 		var witness Witness
 
@@ -422,31 +1022,56 @@ func vote(db *ebakusdb.Snapshot, from common.Address, addresses []common.Address
 			return errVoteAddressIsNotWitness
 		}
 
-		witness.Stake = witness.Stake + amount
+		var overflow bool
+		if witness.Stake, overflow = math.SafeAdd(witness.Stake, amount); overflow {
+			log.Trace("Witness stake overflows", "witness", address, "amount", amount)
+			return errStakeOverflow
+		}
 
 		if err := db.InsertObj(WitnessesTable, &witness); err != nil {
 			return errSystemContractError
 		}
 
-		delegation := Delegation{
-			Id: AddressesToDelegationId(from, address),
-		}
-
-		if err := db.InsertObj(DelegationTable, &delegation); err != nil {
-			return errSystemContractError
+		if err := insertDelegation(db, from, address); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-func unvote(db *ebakusdb.Snapshot, from common.Address, amount uint64) ([]common.Address, error) {
-
+// GetDelegations returns the witnesses the given account votes for, sorted by
+// address.
+func GetDelegations(db ebkdb.State, from common.Address) ([]common.Address, error) {
 	whereClause, err := makeIDLikeWhereClause(db, from)
 	if err != nil {
 		return nil, err
 	}
-
+	iter, err := db.Select(DelegationTable, whereClause)
+	if err != nil {
+		return nil, errSystemContractError
+	}
+	defer iter.Release()
+
+	var (
+		witnesses  []common.Address
+		delegation Delegation
+	)
+	for iter.Next(&delegation) {
+		if voter, witness := delegation.Id.Content(); voter == from {
+			witnesses = append(witnesses, witness)
+		}
+	}
+	return witnesses, nil
+}
+
+func unvote(db ebkdb.State, from common.Address, amount uint64) ([]common.Address, error) {
+
+	whereClause, err := makeIDLikeWhereClause(db, from)
+	if err != nil {
+		return nil, err
+	}
+
 	delIter, err := db.Select(DelegationTable, whereClause)
 	if err != nil {
 		return nil, errSystemContractError
@@ -494,14 +1119,71 @@ func unvote(db *ebakusdb.Snapshot, from common.Address, amount uint64) ([]common
 	}
 
 	for _, delegation := range delegationsToBeDeleted {
-		if err := db.DeleteObj(DelegationTable, delegation.Id); err != nil {
-			return nil, errSystemContractError
+		from, witness := delegation.Id.Content()
+		if err := deleteDelegation(db, from, witness); err != nil {
+			return nil, err
 		}
 	}
 
 	return delegationsAddresses, nil
 }
 
+// unvoteAddresses removes the delegations of from to the given witnesses only,
+// keeping the rest of its votes.
+func unvoteAddresses(db ebkdb.State, from common.Address, addresses []common.Address, amount uint64) error {
+	for _, address := range addresses {
+		id := AddressesToDelegationId(from, address)
+
+		where := []byte("Id LIKE ")
+		whereClause, err := db.WhereParser(append(where, id[:]...))
+		if err != nil {
+			return errSystemContractQueryError
+		}
+
+		delIter, err := db.Select(DelegationTable, whereClause)
+		if err != nil {
+			return errSystemContractError
+		}
+
+		var delegation Delegation
+		if delIter.Next(&delegation) == false {
+			return errUnvoteAddressNotVoted
+		}
+
+		var witness Witness
+
+		whereClause, err = makeIDLikeWhereClause(db, address)
+		if err != nil {
+			return err
+		}
+
+		iter, err := db.Select(WitnessesTable, whereClause)
+		if err != nil {
+			return errSystemContractError
+		}
+
+		if iter.Next(&witness) == false {
+			return errSystemContractError
+		}
+
+		if witness.Stake < amount {
+			return errSystemContractError
+		}
+
+		witness.Stake = witness.Stake - amount
+
+		if err := db.InsertObj(WitnessesTable, &witness); err != nil {
+			return errSystemContractError
+		}
+
+		if err := deleteDelegation(db, from, address); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 const SystemContractABI = `[
 {
   "type": "function",
@@ -561,6 +1243,17 @@ const SystemContractABI = `[
   "inputs": [],
   "outputs": [],
   "stateMutability": "nonpayable"
+},{
+  "type": "function",
+  "name": "unvoteAddresses",
+  "inputs": [
+    {
+      "name": "addresses",
+      "type": "address[]"
+    }
+  ],
+  "outputs": [],
+  "stateMutability": "nonpayable"
 },{
   "type": "function",
   "name": "electEnable",
@@ -605,200 +1298,1313 @@ const SystemContractABI = `[
   "constant": true,
   "payable": false,
   "stateMutability": "view"
-}]`
-
-const SystemContractTablesABI = `[
-{
-  "type": "table",
-  "name": "Witnesses",
+},{
+  "type": "function",
+  "name": "getAbiVersionsForAddress",
   "inputs": [
     {
-      "name": "Id",
+      "name": "address",
+      "type": "address"
+    }
+  ],
+  "outputs": [
+    {
+      "name": "versions",
+      "type": "uint64[]"
+    }
+  ],
+  "constant": true,
+  "payable": false,
+  "stateMutability": "view"
+},{
+  "type": "function",
+  "name": "setTablesAlias",
+  "inputs": [
+    {
+      "name": "alias",
       "type": "address"
+    }
+  ],
+  "outputs": [],
+  "stateMutability": "nonpayable"
+},{
+  "type": "function",
+  "name": "acceptTablesAlias",
+  "inputs": [
+    {
+      "name": "owner",
+      "type": "address"
+    }
+  ],
+  "outputs": [],
+  "stateMutability": "nonpayable"
+},{
+  "type": "function",
+  "name": "removeTablesAlias",
+  "inputs": [
+    {
+      "name": "alias",
+      "type": "address"
+    }
+  ],
+  "outputs": [],
+  "stateMutability": "nonpayable"
+},{
+  "type": "event",
+  "name": "TablesAliasSet",
+  "inputs": [
+    {
+      "name": "owner",
+      "type": "address",
+      "indexed": true
     },
     {
-      "name": "Stake",
+      "name": "alias",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "activeAt",
+      "type": "uint64",
+      "indexed": false
+    }
+  ],
+  "anonymous": false
+},{
+  "type": "event",
+  "name": "TablesAliasAccepted",
+  "inputs": [
+    {
+      "name": "owner",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "alias",
+      "type": "address",
+      "indexed": true
+    }
+  ],
+  "anonymous": false
+},{
+  "type": "event",
+  "name": "TablesAliasRemoved",
+  "inputs": [
+    {
+      "name": "owner",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "alias",
+      "type": "address",
+      "indexed": true
+    }
+  ],
+  "anonymous": false
+},{
+  "type": "function",
+  "name": "transferLocked",
+  "inputs": [
+    {
+      "name": "to",
+      "type": "address"
+    },
+    {
+      "name": "amount",
       "type": "uint64"
     },
     {
-      "name": "Flags",
+      "name": "unlockTime",
       "type": "uint64"
     }
-  ]
+  ],
+  "outputs": [],
+  "stateMutability": "nonpayable"
 },{
-  "type": "table",
-  "name": "Claimable",
+  "type": "event",
+  "name": "LockedTransfer",
   "inputs": [
     {
-      "name": "Id",
-      "type": "bytes28"
+      "name": "from",
+      "type": "address",
+      "indexed": true
     },
     {
-      "name": "Amount",
+      "name": "to",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "amount",
+      "type": "uint64",
+      "indexed": false
+    },
+    {
+      "name": "unlockTime",
+      "type": "uint64",
+      "indexed": false
+    }
+  ],
+  "anonymous": false
+},{
+  "type": "event",
+  "name": "LockedTransferClaimed",
+  "inputs": [
+    {
+      "name": "from",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "to",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "amount",
+      "type": "uint64",
+      "indexed": false
+    }
+  ],
+  "anonymous": false
+},{
+  "type": "function",
+  "name": "subscribe",
+  "inputs": [
+    {
+      "name": "payee",
+      "type": "address"
+    },
+    {
+      "name": "amount",
       "type": "uint64"
     },
     {
-      "name": "Timestamp",
+      "name": "interval",
+      "type": "uint64"
+    },
+    {
+      "name": "expiry",
+      "type": "uint64"
+    },
+    {
+      "name": "allowance",
       "type": "uint64"
     }
-  ]
+  ],
+  "outputs": [],
+  "stateMutability": "nonpayable"
 },{
-  "type": "table",
-  "name": "Delegations",
+  "type": "function",
+  "name": "unsubscribe",
   "inputs": [
     {
-      "name": "Id",
-      "type": "bytes40"
+      "name": "payee",
+      "type": "address"
     }
-  ]
+  ],
+  "outputs": [],
+  "stateMutability": "nonpayable"
 },{
-  "type": "table",
-  "name": "ContractAbi",
+  "type": "function",
+  "name": "settle",
   "inputs": [
     {
-      "name": "Id",
-      "type": "bytes"
+      "name": "payer",
+      "type": "address"
+    }
+  ],
+  "outputs": [],
+  "stateMutability": "nonpayable"
+},{
+  "type": "event",
+  "name": "Subscribed",
+  "inputs": [
+    {
+      "name": "payer",
+      "type": "address",
+      "indexed": true
     },
     {
-      "name": "Abi",
-      "type": "string"
+      "name": "payee",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "amount",
+      "type": "uint64",
+      "indexed": false
+    },
+    {
+      "name": "interval",
+      "type": "uint64",
+      "indexed": false
+    },
+    {
+      "name": "expiry",
+      "type": "uint64",
+      "indexed": false
+    },
+    {
+      "name": "allowance",
+      "type": "uint64",
+      "indexed": false
     }
-  ]
-}]`
-
-func (c *systemContract) stakeCmd(evm *EVM, from common.Address, amount uint64) ([]byte, error) {
-	if amount <= 0 {
-		log.Trace("Can't stake negative or zero amounts")
-		return nil, errSystemContractError
-	}
-
-	db := evm.EbakusState
-
-	hasEnoughBalance := false
-	amountToBeTransfered := amount
-
-	// Check if user has claimable entries that can be used for staking
-	whereClauseClaimable, err := makeIDLikeWhereClause(db, from)
-	if err != nil {
-		return nil, err
-	}
-
-	orderClauseClaimable, err := db.OrderParser([]byte("Id DESC"))
-	if err != nil {
-		return nil, errSystemContractError
-	}
-
-	iterClaimable, err := db.Select(ClaimableTable, whereClauseClaimable, orderClauseClaimable)
-	if err != nil {
-		return nil, errSystemContractError
-	}
+  ],
+  "anonymous": false
+},{
+  "type": "event",
+  "name": "Unsubscribed",
+  "inputs": [
+    {
+      "name": "payer",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "payee",
+      "type": "address",
+      "indexed": true
+    }
+  ],
+  "anonymous": false
+},{
+  "type": "event",
+  "name": "SubscriptionSettled",
+  "inputs": [
+    {
+      "name": "payer",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "payee",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "amount",
+      "type": "uint64",
+      "indexed": false
+    },
+    {
+      "name": "periods",
+      "type": "uint64",
+      "indexed": false
+    }
+  ],
+  "anonymous": false
+},{
+  "type": "function",
+  "name": "approve",
+  "inputs": [
+    {
+      "name": "spender",
+      "type": "address"
+    },
+    {
+      "name": "amount",
+      "type": "uint64"
+    }
+  ],
+  "outputs": [],
+  "stateMutability": "nonpayable"
+},{
+  "type": "function",
+  "name": "allowance",
+  "inputs": [
+    {
+      "name": "owner",
+      "type": "address"
+    },
+    {
+      "name": "spender",
+      "type": "address"
+    }
+  ],
+  "outputs": [
+    {
+      "name": "amount",
+      "type": "uint64"
+    }
+  ],
+  "constant": true,
+  "payable": false,
+  "stateMutability": "view"
+},{
+  "type": "function",
+  "name": "transferFrom",
+  "inputs": [
+    {
+      "name": "owner",
+      "type": "address"
+    },
+    {
+      "name": "to",
+      "type": "address"
+    },
+    {
+      "name": "amount",
+      "type": "uint64"
+    }
+  ],
+  "outputs": [],
+  "stateMutability": "nonpayable"
+},{
+  "type": "event",
+  "name": "Approval",
+  "inputs": [
+    {
+      "name": "owner",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "spender",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "amount",
+      "type": "uint64",
+      "indexed": false
+    }
+  ],
+  "anonymous": false
+},{
+  "type": "event",
+  "name": "ApprovedTransfer",
+  "inputs": [
+    {
+      "name": "owner",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "spender",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "to",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "amount",
+      "type": "uint64",
+      "indexed": false
+    }
+  ],
+  "anonymous": false
+},{
+  "type": "function",
+  "name": "bridgeIntent",
+  "inputs": [
+    {
+      "name": "chainId",
+      "type": "uint64"
+    },
+    {
+      "name": "recipient",
+      "type": "bytes32"
+    },
+    {
+      "name": "amount",
+      "type": "uint64"
+    }
+  ],
+  "outputs": [],
+  "stateMutability": "nonpayable"
+},{
+  "type": "event",
+  "name": "BridgeIntent",
+  "inputs": [
+    {
+      "name": "sender",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "chainId",
+      "type": "uint64",
+      "indexed": true
+    },
+    {
+      "name": "recipient",
+      "type": "bytes32",
+      "indexed": false
+    },
+    {
+      "name": "amount",
+      "type": "uint64",
+      "indexed": false
+    },
+    {
+      "name": "index",
+      "type": "uint64",
+      "indexed": false
+    }
+  ],
+  "anonymous": false
+},{
+  "type": "function",
+  "name": "announceEnode",
+  "inputs": [
+    {
+      "name": "enode",
+      "type": "string"
+    }
+  ],
+  "outputs": [],
+  "stateMutability": "nonpayable"
+},{
+  "type": "function",
+  "name": "setWitnessEnode",
+  "inputs": [
+    {
+      "name": "enode",
+      "type": "string"
+    }
+  ],
+  "outputs": [],
+  "stateMutability": "nonpayable"
+},{
+  "type": "event",
+  "name": "Stake",
+  "inputs": [
+    {
+      "name": "staker",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "amount",
+      "type": "uint64",
+      "indexed": false
+    },
+    {
+      "name": "staked",
+      "type": "uint64",
+      "indexed": false
+    }
+  ],
+  "anonymous": false
+},{
+  "type": "event",
+  "name": "Unstake",
+  "inputs": [
+    {
+      "name": "staker",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "amount",
+      "type": "uint64",
+      "indexed": false
+    },
+    {
+      "name": "claimableAt",
+      "type": "uint64",
+      "indexed": false
+    }
+  ],
+  "anonymous": false
+},{
+  "type": "event",
+  "name": "Claim",
+  "inputs": [
+    {
+      "name": "staker",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "amount",
+      "type": "uint64",
+      "indexed": false
+    }
+  ],
+  "anonymous": false
+},{
+  "type": "event",
+  "name": "Vote",
+  "inputs": [
+    {
+      "name": "voter",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "witnesses",
+      "type": "address[]",
+      "indexed": false
+    },
+    {
+      "name": "amount",
+      "type": "uint64",
+      "indexed": false
+    }
+  ],
+  "anonymous": false
+},{
+  "type": "event",
+  "name": "Unvote",
+  "inputs": [
+    {
+      "name": "voter",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "witnesses",
+      "type": "address[]",
+      "indexed": false
+    }
+  ],
+  "anonymous": false
+}]`
+
+const SystemContractTablesABI = `[
+{
+  "type": "table",
+  "name": "Witnesses",
+  "inputs": [
+    {
+      "name": "Id",
+      "type": "address"
+    },
+    {
+      "name": "Stake",
+      "type": "uint64"
+    },
+    {
+      "name": "Flags",
+      "type": "uint64"
+    }
+  ]
+},{
+  "type": "table",
+  "name": "Claimable",
+  "inputs": [
+    {
+      "name": "Id",
+      "type": "bytes28"
+    },
+    {
+      "name": "Amount",
+      "type": "uint64"
+    },
+    {
+      "name": "Timestamp",
+      "type": "uint64"
+    }
+  ]
+},{
+  "type": "table",
+  "name": "Delegations",
+  "inputs": [
+    {
+      "name": "Id",
+      "type": "bytes40"
+    }
+  ]
+},{
+  "type": "table",
+  "name": "ContractAbi",
+  "inputs": [
+    {
+      "name": "Id",
+      "type": "bytes"
+    },
+    {
+      "name": "Abi",
+      "type": "string"
+    }
+  ]
+},{
+  "type": "table",
+  "name": "Tombstones",
+  "inputs": [
+    {
+      "name": "Id",
+      "type": "address"
+    },
+    {
+      "name": "Block",
+      "type": "uint64"
+    }
+  ]
+},{
+  "type": "table",
+  "name": "TablesAliases",
+  "inputs": [
+    {
+      "name": "Id",
+      "type": "address"
+    },
+    {
+      "name": "Owner",
+      "type": "address"
+    },
+    {
+      "name": "ActiveAt",
+      "type": "uint64"
+    },
+    {
+      "name": "Accepted",
+      "type": "bool"
+    }
+  ]
+},{
+  "type": "table",
+  "name": "WitnessPerformance",
+  "inputs": [
+    {
+      "name": "Id",
+      "type": "address"
+    },
+    {
+      "name": "Produced",
+      "type": "uint64"
+    },
+    {
+      "name": "Missed",
+      "type": "uint64"
+    },
+    {
+      "name": "MissedInRow",
+      "type": "uint64"
+    }
+  ]
+},{
+  "type": "table",
+  "name": "LockedTransfers",
+  "inputs": [
+    {
+      "name": "Id",
+      "type": "bytes48"
+    },
+    {
+      "name": "Amount",
+      "type": "uint64"
+    },
+    {
+      "name": "UnlockTime",
+      "type": "uint64"
+    }
+  ]
+},{
+  "type": "table",
+  "name": "Subscriptions",
+  "inputs": [
+    {
+      "name": "Id",
+      "type": "bytes40"
+    },
+    {
+      "name": "Amount",
+      "type": "uint64"
+    },
+    {
+      "name": "Interval",
+      "type": "uint64"
+    },
+    {
+      "name": "Expiry",
+      "type": "uint64"
+    },
+    {
+      "name": "NextSettle",
+      "type": "uint64"
+    },
+    {
+      "name": "Allowance",
+      "type": "uint64"
+    }
+  ]
+},{
+  "type": "table",
+  "name": "Allowances",
+  "inputs": [
+    {
+      "name": "Id",
+      "type": "bytes40"
+    },
+    {
+      "name": "Amount",
+      "type": "uint64"
+    }
+  ]
+},{
+  "type": "table",
+  "name": "BridgeIntents",
+  "inputs": [
+    {
+      "name": "Id",
+      "type": "bytes16"
+    },
+    {
+      "name": "Sender",
+      "type": "address"
+    },
+    {
+      "name": "ChainId",
+      "type": "uint64"
+    },
+    {
+      "name": "Recipient",
+      "type": "bytes32"
+    },
+    {
+      "name": "Amount",
+      "type": "uint64"
+    }
+  ]
+},{
+  "type": "table",
+  "name": "BridgeCommitments",
+  "inputs": [
+    {
+      "name": "Id",
+      "type": "bytes8"
+    },
+    {
+      "name": "Root",
+      "type": "bytes32"
+    },
+    {
+      "name": "Count",
+      "type": "uint64"
+    }
+  ]
+},{
+  "type": "table",
+  "name": "WitnessEnodes",
+  "inputs": [
+    {
+      "name": "Id",
+      "type": "address"
+    },
+    {
+      "name": "Enode",
+      "type": "string"
+    }
+  ]
+}]`
+
+// StakedTableABI is the schema of the system Staked table. It's kept out of
+// SystemContractTablesABI so the DB contract can't read it, and is only used to
+// serve the stakes to light clients.
+const StakedTableABI = `[
+{
+  "type": "table",
+  "name": "Staked",
+  "inputs": [
+    {
+      "name": "Id",
+      "type": "address"
+    },
+    {
+      "name": "Amount",
+      "type": "uint64"
+    }
+  ]
+}]`
+
+func (c *systemContract) stakeCmd(evm *EVM, evmABI *abi.ABI, from common.Address, amount uint64) ([]byte, error) {
+	if amount <= 0 {
+		log.Trace("Can't stake negative or zero amounts")
+		return nil, errSystemContractError
+	}
+
+	db := evm.EbakusState
+
+	hasEnoughBalance := false
+	amountToBeTransfered := amount
+
+	// Check if user has claimable entries that can be used for staking
+	whereClauseClaimable, err := makeIDLikeWhereClause(db, from)
+	if err != nil {
+		return nil, err
+	}
+
+	orderClauseClaimable, err := db.OrderParser([]byte("Id DESC"))
+	if err != nil {
+		return nil, errSystemContractError
+	}
+
+	iterClaimable, err := db.Select(ClaimableTable, whereClauseClaimable, orderClauseClaimable)
+	if err != nil {
+		return nil, errSystemContractError
+	}
+
+	claimableAmount := uint64(0)
+	claimablesToBeDeleted := make([]Claimable, 0)
+	var claimable Claimable
+
+	for iterClaimable.Next(&claimable) {
+		if amount-claimableAmount >= claimable.Amount {
+			claimableAmount = claimableAmount + claimable.Amount
+
+			claimablesToBeDeleted = append(claimablesToBeDeleted, claimable)
+		} else {
+			claimable.Amount = claimable.Amount - (amount - claimableAmount)
+			claimableAmount = claimableAmount + (amount - claimableAmount)
+
+			if err := db.InsertObj(ClaimableTable, &claimable); err != nil {
+				log.Trace("Claimable entry failed to be updated with new claimable amount", "err", err)
+				return nil, errSystemContractError
+			}
+		}
+
+		claimable = Claimable{}
+
+		if claimableAmount == amount {
+			hasEnoughBalance = true
+			break
+		}
+	}
+
+	amountToBeTransfered = amount - claimableAmount
+
+	// Check account has balance (amount <= balance)
+	balanceWei := evm.StateDB.GetBalance(from)
+	balance := evm.fromWei(balanceWei)
+
+	hasEnoughBalance = amountToBeTransfered <= balance
+
+	if !hasEnoughBalance {
+		log.Trace("Account doesn't have sufficient balance")
+		return nil, errStakeNotEnoughBalance
+	}
+
+	for _, claimableEntry := range claimablesToBeDeleted {
+		if err := db.DeleteObj(ClaimableTable, claimableEntry.Id); err != nil {
+			log.Trace("Claimable tokens failed to delete (staked)", "err", err)
+			return nil, errSystemContractError
+		}
+	}
+
+	//  Update whole system staked amount
+	if err := addSystemStake(db, amount); err != nil {
+		return nil, err
+	}
+
+	staked, err := GetStaked(db, from)
+	if err != nil {
+		return nil, err
+	}
+
+	if staked != nil {
+		delegatedAddresses, err := unvote(db, from, staked.Amount)
+		if err != nil {
+			return nil, errSystemContractError
+		}
+
+		var overflow bool
+		if staked.Amount, overflow = math.SafeAdd(staked.Amount, amount); overflow {
+			log.Trace("Staked amount overflows", "from", from, "amount", amount)
+			return nil, errStakeOverflow
+		}
+
+		if err := vote(db, from, delegatedAddresses, staked.Amount); err != nil {
+			return nil, errSystemContractError
+		}
+	} else {
+		delegatedAddresses, err := unvote(db, from, uint64(0))
+		if err != nil {
+			return nil, errSystemContractError
+		}
+
+		staked = &types.Staked{
+			Id:     from,
+			Amount: amount,
+		}
+
+		if err := vote(db, from, delegatedAddresses, staked.Amount); err != nil {
+			return nil, errSystemContractError
+		}
+	}
+
+	if err := db.InsertObj(types.StakedTable, staked); err != nil {
+		return nil, errSystemContractError
+	}
+
+	amountToBeTransferedWei := evm.toWei(amountToBeTransfered)
+	// Fail if we're trying to transfer more than the available balance
+	if !evm.CanTransfer(evm.StateDB, from, amountToBeTransferedWei) {
+		log.Trace("Failed to stake amount because of insufficient balance", "err", err)
+		return nil, ErrInsufficientBalance
+	}
+	evm.Transfer(evm.StateDB, from, types.PrecompliledSystemContract, amountToBeTransferedWei)
+
+	if err := c.addActivityLog(evm, evmABI, "Stake", []common.Hash{from.Hash()}, amount, staked.Amount); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (c *systemContract) getStakedCmd(evm *EVM, from common.Address) ([]byte, error) {
+	db := evm.EbakusState
+
+	staked, err := GetStaked(db, from)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := uint64(0)
+
+	if staked != nil {
+		amount = staked.Amount
+	}
+
+	stakeAmount := make([]byte, 32)
+	binary.BigEndian.PutUint64(stakeAmount[24:], amount)
+	return stakeAmount, nil
+}
+
+func (c *systemContract) unstakeCmd(evm *EVM, evmABI *abi.ABI, from common.Address, amount uint64) ([]byte, error) {
+	db := evm.EbakusState
+
+	timestamp := evm.Time.Uint64() + unstakeVestingPeriod
+	newClaimableEntryId := GetClaimableId(from, timestamp)
+
+	// get all claimable tokens
+	whereClause, err := makeIDLikeWhereClause(db, from)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := db.Select(ClaimableTable, whereClause)
+	if err != nil {
+		return nil, errSystemContractError
+	}
+
+	// Disallow more than maxClaimableEntries, to protect system abuse
+	var (
+		claimable             Claimable
+		claimablesToBeMerged  []Claimable
+		countClaimableEntries int
+	)
+	for iter.Next(&claimable) {
+		// Entries vesting within a day of the new one are merged into it,
+		// extending their vesting to its later timestamp
+		if evm.chainRules.IsClaimMerge && claimable.Timestamp <= timestamp && timestamp-claimable.Timestamp < claimMergeWindow {
+			claimablesToBeMerged = append(claimablesToBeMerged, claimable)
+			claimable = Claimable{}
+			continue
+		}
+		countClaimableEntries++
+
+		if newClaimableEntryId == claimable.Id {
+			log.Trace("Unstake request failed because of existing entry for same block, please try again.")
+			return nil, errSystemContractError
+		}
+
+		if countClaimableEntries >= maxClaimableEntries {
+			log.Trace("Unstake failed as maxClaimableEntries reached", "maxClaimableEntries", maxClaimableEntries)
+			return nil, errUnstakeTooManyClaimable
+		}
+	}
+
+	var staked types.Staked
+	iter, err = db.Select(types.StakedTable, whereClause)
+	if err != nil {
+		return nil, errSystemContractError
+	}
+
+	if iter.Next(&staked) == false {
+		return nil, errSystemContractError
+	}
+
+	oldStake := staked.Amount
+	newStake := uint64(0)
+
+	if amount > staked.Amount {
+		return nil, errUnstakeNotEnoughStakedAmount
+
+	} else if amount == staked.Amount {
+		if err := db.DeleteObj(types.StakedTable, staked.Id); err != nil {
+			return nil, errSystemContractError
+		}
+	} else {
+		staked.Amount = staked.Amount - amount
+		newStake = staked.Amount
+
+		if err := db.InsertObj(types.StakedTable, &staked); err != nil {
+			return nil, errSystemContractError
+		}
+	}
+
+	newClaimableEntry := Claimable{
+		Id:        newClaimableEntryId,
+		Amount:    amount,
+		Timestamp: timestamp,
+	}
+
+	for _, claimableEntry := range claimablesToBeMerged {
+		var overflow bool
+		if newClaimableEntry.Amount, overflow = math.SafeAdd(newClaimableEntry.Amount, claimableEntry.Amount); overflow {
+			return nil, errStakeOverflow
+		}
+		if err := db.DeleteObj(ClaimableTable, claimableEntry.Id); err != nil {
+			log.Trace("Claimable entry failed to be merged", "err", err)
+			return nil, errSystemContractError
+		}
+	}
+
+	if err := db.InsertObj(ClaimableTable, &newClaimableEntry); err != nil {
+		return nil, errSystemContractError
+	}
+
+	delegatedAddresses, err := unvote(db, from, oldStake)
+	if err != nil {
+		return nil, errSystemContractError
+	}
+
+	if err := vote(db, from, delegatedAddresses, newStake); err != nil {
+		return nil, errSystemContractError
+	}
+
+	//  Update whole system staked amount
+	if err := subSystemStake(db, amount); err != nil {
+		return nil, err
+	}
+
+	if err := c.addActivityLog(evm, evmABI, "Unstake", []common.Hash{from.Hash()}, amount, timestamp); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (c *systemContract) claimCmd(evm *EVM, evmABI *abi.ABI, from common.Address) ([]byte, error) {
+	db := evm.EbakusState
+
+	// check if user has claimable tokens
+	whereClause, err := makeIDLikeWhereClause(db, from)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := db.Select(ClaimableTable, whereClause)
+	if err != nil {
+		return nil, errSystemContractError
+	}
+
+	claimableAmount := uint64(0)
+	var claimable Claimable
+	claimablesToBeDeleted := make([]Claimable, 0)
+
+	for iter.Next(&claimable) {
+		if claimable.Timestamp <= evm.Time.Uint64() {
+			claimableAmount = claimableAmount + claimable.Amount
+
+			claimablesToBeDeleted = append(claimablesToBeDeleted, claimable)
+		}
+		claimable = Claimable{}
+	}
+
+	for _, claimableEntry := range claimablesToBeDeleted {
+		if err := db.DeleteObj(ClaimableTable, claimableEntry.Id); err != nil {
+			return nil, errSystemContractError
+		}
+	}
+
+	if evm.chainRules.IsLockedTransfer {
+		unlockedAmount, err := c.claimLockedTransfers(evm, evmABI, from)
+		if err != nil {
+			return nil, err
+		}
+		var overflow bool
+		if claimableAmount, overflow = math.SafeAdd(claimableAmount, unlockedAmount); overflow {
+			return nil, errSystemContractError
+		}
+	}
+
+	if claimableAmount <= 0 {
+		log.Trace("No amount to be claimed")
+		return nil, nil
+	}
+
+	claimableAmountWei := evm.toWei(claimableAmount)
+	// Fail if we're trying to transfer more than the available balance
+	if !evm.CanTransfer(evm.StateDB, types.PrecompliledSystemContract, claimableAmountWei) {
+		log.Trace("Failed to claim amount because of insufficient balance", "err", err)
+		return nil, ErrInsufficientBalance
+	}
+	evm.Transfer(evm.StateDB, types.PrecompliledSystemContract, from, claimableAmountWei)
+
+	if err := c.addActivityLog(evm, evmABI, "Claim", []common.Hash{from.Hash()}, claimableAmount); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func GetStaked(db ebkdb.State, from common.Address) (*types.Staked, error) {
+	var staked types.Staked
+
+	whereClause, err := makeIDLikeWhereClause(db, from)
+	if err != nil {
+		return nil, err
+	}
 
-	claimableAmount := uint64(0)
-	claimablesToBeDeleted := make([]Claimable, 0)
-	var claimable Claimable
+	iter, err := db.Select(types.StakedTable, whereClause)
+	if err != nil {
+		return nil, errSystemContractError
+	}
 
-	for iterClaimable.Next(&claimable) {
-		if amount-claimableAmount >= claimable.Amount {
-			claimableAmount = claimableAmount + claimable.Amount
+	if iter.Next(&staked) == false {
+		return nil, nil
+	}
 
-			claimablesToBeDeleted = append(claimablesToBeDeleted, claimable)
-		} else {
-			claimable.Amount = claimable.Amount - (amount - claimableAmount)
-			claimableAmount = claimableAmount + (amount - claimableAmount)
+	return &staked, nil
+}
 
-			if err := db.InsertObj(ClaimableTable, &claimable); err != nil {
-				log.Trace("Claimable entry failed to be updated with new claimable amount", "err", err)
-				return nil, errSystemContractError
-			}
-		}
+// GetSystemStake returns the amount staked by all the accounts.
+func GetSystemStake(db ebkdb.State) uint64 {
+	if systemStakedBytes, found := db.Get([]byte(types.SystemStakeDBKey)); found {
+		return binary.BigEndian.Uint64(*systemStakedBytes)
+	}
+	return 0
+}
 
-		claimable = Claimable{}
+func putSystemStake(db ebkdb.State, systemStaked uint64) error {
+	systemStakedBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(systemStakedBytes, systemStaked)
 
-		if claimableAmount == amount {
-			hasEnoughBalance = true
-			break
-		}
+	if err := db.Insert([]byte(types.SystemStakeDBKey), systemStakedBytes); err != nil {
+		return errSystemContractError
 	}
+	return nil
+}
 
-	amountToBeTransfered = amount - claimableAmount
+// addSystemStake adds amount to the system stake, failing instead of wrapping
+// around.
+func addSystemStake(db ebkdb.State, amount uint64) error {
+	systemStaked, overflow := math.SafeAdd(GetSystemStake(db), amount)
+	if overflow {
+		log.Trace("System staked amount overflows", "amount", amount)
+		return errStakeOverflow
+	}
+	return putSystemStake(db, systemStaked)
+}
 
-	// Check account has balance (amount <= balance)
-	balanceWei := evm.StateDB.GetBalance(from)
-	balance := new(big.Int).Div(balanceWei, precisionFactor).Uint64()
+// subSystemStake subtracts amount from the system stake, failing instead of
+// wrapping around.
+func subSystemStake(db ebkdb.State, amount uint64) error {
+	if _, found := db.Get([]byte(types.SystemStakeDBKey)); !found {
+		return errSystemContractError
+	}
+	systemStaked, underflow := math.SafeSub(GetSystemStake(db), amount)
+	if underflow {
+		log.Trace("System staked amount underflows", "amount", amount)
+		return errSystemStakeUnderflow
+	}
+	return putSystemStake(db, systemStaked)
+}
 
-	hasEnoughBalance = amountToBeTransfered <= balance
+// CheckSystemStake asserts that the system stake equals the sum of the amounts
+// of the Staked table.
+func CheckSystemStake(db ebkdb.State) error {
+	iter, err := db.Select(types.StakedTable)
+	if err != nil {
+		return err
+	}
+	defer iter.Release()
 
-	if !hasEnoughBalance {
-		log.Trace("Account doesn't have sufficient balance")
-		return nil, errStakeNotEnoughBalance
+	var (
+		total    uint64
+		overflow bool
+		staked   types.Staked
+	)
+	for iter.Next(&staked) {
+		if total, overflow = math.SafeAdd(total, staked.Amount); overflow {
+			return errStakeOverflow
+		}
+		staked = types.Staked{}
+	}
+	if systemStaked := GetSystemStake(db); systemStaked != total {
+		return fmt.Errorf("system stake %d doesn't match the %d staked by the accounts", systemStaked, total)
 	}
+	return nil
+}
 
-	for _, claimableEntry := range claimablesToBeDeleted {
-		if err := db.DeleteObj(ClaimableTable, claimableEntry.Id); err != nil {
-			log.Trace("Claimable tokens failed to delete (staked)", "err", err)
-			return nil, errSystemContractError
+func unique(addresses []common.Address) []common.Address {
+	used := make(map[common.Address]bool)
+	res := []common.Address{}
+	for _, entry := range addresses {
+		if _, value := used[entry]; !value {
+			used[entry] = true
+			res = append(res, entry)
 		}
 	}
+	return res
+}
 
-	//  Update whole system staked amount
-	systemStaked := amount
+func (c *systemContract) voteCmd(evm *EVM, evmABI *abi.ABI, from common.Address, addresses []common.Address) ([]byte, error) {
+	db := evm.EbakusState
 
-	if systemStakedBytesOut, found := db.Get([]byte(types.SystemStakeDBKey)); found {
-		systemStaked += binary.BigEndian.Uint64(*systemStakedBytesOut)
-	}
+	addresses = unique(addresses)
 
-	systemStakedBytesIn := make([]byte, 8)
-	binary.BigEndian.PutUint64(systemStakedBytesIn[:], systemStaked)
-	db.Insert([]byte(types.SystemStakeDBKey), systemStakedBytesIn)
+	if len(addresses) > int(evm.chainConfig.DPOS.MaxWitnessesVotes) {
+		return nil, errVoteMaxWitnessesReached
+	}
 
 	staked, err := GetStaked(db, from)
 	if err != nil {
 		return nil, err
 	}
 
-	if staked != nil {
-		delegatedAddresses, err := unvote(db, from, staked.Amount)
-		if err != nil {
-			return nil, errSystemContractError
-		}
+	if staked == nil {
+		return nil, errVoteNothingStaked
+	}
 
-		staked.Amount = staked.Amount + amount
+	if _, err := unvote(db, from, staked.Amount); err != nil {
+		return nil, err
+	}
 
-		if err := vote(db, from, delegatedAddresses, staked.Amount); err != nil {
-			return nil, errSystemContractError
-		}
-	} else {
-		delegatedAddresses, err := unvote(db, from, uint64(0))
-		if err != nil {
-			return nil, errSystemContractError
-		}
+	if err := vote(db, from, addresses, staked.Amount); err != nil {
+		return nil, err
+	}
 
-		staked = &types.Staked{
-			Id:     from,
-			Amount: amount,
-		}
+	if err := c.addActivityLog(evm, evmABI, "Vote", []common.Hash{from.Hash()}, addresses, staked.Amount); err != nil {
+		return nil, err
+	}
 
-		if err := vote(db, from, delegatedAddresses, staked.Amount); err != nil {
-			return nil, errSystemContractError
-		}
+	return nil, nil
+}
+
+func (c *systemContract) unvoteCmd(evm *EVM, evmABI *abi.ABI, from common.Address) ([]byte, error) {
+	db := evm.EbakusState
+
+	staked, err := GetStaked(db, from)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := db.InsertObj(types.StakedTable, staked); err != nil {
+	if staked == nil {
+		return nil, errVoteNothingStaked
+	}
+
+	addresses, err := unvote(db, from, staked.Amount)
+	if err != nil {
 		return nil, errSystemContractError
 	}
 
-	amountToBeTransferedWei := new(big.Int).Mul(new(big.Int).SetUint64(amountToBeTransfered), precisionFactor)
-	// Fail if we're trying to transfer more than the available balance
-	if !evm.CanTransfer(evm.StateDB, from, amountToBeTransferedWei) {
-		log.Trace("Failed to stake amount because of insufficient balance", "err", err)
-		return nil, ErrInsufficientBalance
+	if err := c.addActivityLog(evm, evmABI, "Unvote", []common.Hash{from.Hash()}, addresses); err != nil {
+		return nil, err
 	}
-	evm.Transfer(evm.StateDB, from, types.PrecompliledSystemContract, amountToBeTransferedWei)
 
 	return nil, nil
 }
 
-func (c *systemContract) getStakedCmd(evm *EVM, from common.Address) ([]byte, error) {
+func (c *systemContract) unvoteAddressesCmd(evm *EVM, evmABI *abi.ABI, from common.Address, addresses []common.Address) ([]byte, error) {
 	db := evm.EbakusState
 
 	staked, err := GetStaked(db, from)
@@ -806,363 +2612,614 @@ func (c *systemContract) getStakedCmd(evm *EVM, from common.Address) ([]byte, er
 		return nil, err
 	}
 
-	amount := uint64(0)
+	if staked == nil {
+		return nil, errVoteNothingStaked
+	}
 
-	if staked != nil {
-		amount = staked.Amount
+	addresses = unique(addresses)
+	if err := unvoteAddresses(db, from, addresses, staked.Amount); err != nil {
+		return nil, err
 	}
 
-	stakeAmount := make([]byte, 32)
-	binary.BigEndian.PutUint64(stakeAmount[24:], amount)
-	return stakeAmount, nil
+	if err := c.addActivityLog(evm, evmABI, "Unvote", []common.Hash{from.Hash()}, addresses); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
 }
 
-func (c *systemContract) unstakeCmd(evm *EVM, from common.Address, amount uint64) ([]byte, error) {
+func (c *systemContract) electEnableCmd(evm *EVM, from common.Address, enable bool) ([]byte, error) {
 	db := evm.EbakusState
 
-	timestamp := evm.Time.Uint64() + unstakeVestingPeriod
-	newClaimableEntryId := GetClaimableId(from, timestamp)
+	var witness Witness
 
-	// get all claimable tokens
 	whereClause, err := makeIDLikeWhereClause(db, from)
 	if err != nil {
 		return nil, err
 	}
 
-	iter, err := db.Select(ClaimableTable, whereClause)
+	iter, err := db.Select(WitnessesTable, whereClause)
 	if err != nil {
 		return nil, errSystemContractError
 	}
 
-	// Disallow more than maxClaimableEntries, to protect system abuse
-	var claimable Claimable
-	countClaimableEntries := 0
-	for iter.Next(&claimable) {
-		countClaimableEntries++
-
-		if newClaimableEntryId == claimable.Id {
-			log.Trace("Unstake request failed because of existing entry for same block, please try again.")
-			return nil, errSystemContractError
+	if iter.Next(&witness) == false {
+		witness = Witness{
+			Id:    from,
+			Stake: 0,
+			Flags: 0,
 		}
+	}
 
-		if countClaimableEntries >= maxClaimableEntries {
-			log.Trace("Unstake failed as maxClaimableEntries reached", "maxClaimableEntries", maxClaimableEntries)
-			return nil, errUnstakeTooManyClaimable
-		}
+	if enable {
+		witness.Flags |= ElectEnabledFlag
+	} else {
+		witness.Flags &= ^ElectEnabledFlag
 	}
 
-	var staked types.Staked
-	iter, err = db.Select(types.StakedTable, whereClause)
+	if err := db.InsertObj(WitnessesTable, &witness); err != nil {
+		return nil, errSystemContractError
+	}
+
+	return nil, nil
+}
+
+func (c *systemContract) storeAbiAtAddress(evm *EVM, from common.Address, contractAddress common.Address, abi string) ([]byte, error) {
+	if evm.chainRules.IsAbiVersioning {
+		return storeAbiVersion(evm.EbakusState, from, contractAddress, abi)
+	}
+	return storeAbiAtAddress(evm.EbakusState, contractAddress, abi)
+}
+
+func storeAbiAtAddress(db ebkdb.State, contractAddress common.Address, abi string) ([]byte, error) {
+	id := GetContractAbiId(contractAddress, "abi", "")
+
+	where := []byte("Id LIKE ")
+	whereClause, err := db.WhereParser(append(where, id[:]...))
 	if err != nil {
 		return nil, errSystemContractError
 	}
 
-	if iter.Next(&staked) == false {
+	iter, err := db.Select(ContractAbiTable, whereClause)
+	if err != nil {
 		return nil, errSystemContractError
 	}
 
-	oldStake := staked.Amount
-	newStake := uint64(0)
+	var contractAbi ContractAbi
 
-	if amount > staked.Amount {
-		return nil, errUnstakeNotEnoughStakedAmount
+	if iter.Next(&contractAbi) == true {
+		return nil, errContractAbiExists
+	}
 
-	} else if amount == staked.Amount {
-		if err := db.DeleteObj(types.StakedTable, staked.Id); err != nil {
-			return nil, errSystemContractError
-		}
-	} else {
-		staked.Amount = staked.Amount - amount
-		newStake = staked.Amount
+	contractAbi = ContractAbi{
+		Id:  id,
+		Abi: abi,
+	}
 
-		if err := db.InsertObj(types.StakedTable, &staked); err != nil {
-			return nil, errSystemContractError
-		}
+	if err := db.InsertObj(ContractAbiTable, &contractAbi); err != nil {
+		return nil, errSystemContractError
+	}
+
+	return nil, nil
+}
+
+func (c *systemContract) getAbiAtAddress(evm *EVM, contractAddress common.Address) (string, error) {
+	return GetAbiAtAddress(evm.EbakusState, evm.chainConfig, evm.BlockNumber, contractAddress)
+}
+
+// GetAbiAtAddress returns the ABI of a precompiled contract, or the latest ABI
+// stored for a contract. The wrapped token only has an ABI once it's deployed
+// at the given block.
+func GetAbiAtAddress(db ebkdb.State, config *params.ChainConfig, num *big.Int, contractAddress common.Address) (string, error) {
+
+	if contractAddress == types.PrecompliledSystemContract {
+		return SystemContractABI, nil
+	} else if contractAddress == types.PrecompliledDBContract {
+		return DBABI, nil
+	} else if contractAddress == types.PrecompliledWrappedToken && config.IsWrappedToken(num) {
+		return WrappedTokenABI, nil
+	}
+
+	idPrefix := GetContractAbiId(contractAddress, "abi", "")
+
+	where := []byte("Id LIKE ")
+	whereClause, err := db.WhereParser(append(where, idPrefix[:]...))
+	if err != nil {
+		return "", errSystemContractError
+	}
+
+	// Pick the latest version of the ABI
+	orderClause, err := db.OrderParser([]byte("Id DESC"))
+	if err != nil {
+		return "", errSystemContractError
+	}
+
+	iter, err := db.Select(ContractAbiTable, whereClause, orderClause)
+	if err != nil {
+		return "", errSystemContractError
+	}
+
+	var contractAbi ContractAbi
+	if iter.Next(&contractAbi) == false {
+		return "", errContractAbiNotFound
+	}
+
+	contractAbiID := common.BytesToAddress(contractAbi.Id[:common.AddressLength])
+	if contractAbiID != contractAddress {
+		return "", errSystemContractError
 	}
 
-	newClaimableEntry := Claimable{
-		Id:        newClaimableEntryId,
-		Amount:    amount,
-		Timestamp: timestamp,
+	return contractAbi.Abi, nil
+}
+
+// setTablesAliasCmd schedules alias to access the tables namespace used by
+// from, after the safety delay has passed and once alias accepted it.
+func (c *systemContract) setTablesAliasCmd(evm *EVM, evmABI *abi.ABI, from common.Address, alias common.Address) ([]byte, error) {
+	db := evm.EbakusState
+	now := evm.Time.Uint64()
+
+	owner, err := GetTablesNamespace(db, from, now)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := db.InsertObj(ClaimableTable, &newClaimableEntry); err != nil {
-		return nil, errSystemContractError
+	if alias == (common.Address{}) || alias == from || alias == owner {
+		return nil, errTablesAliasInvalid
 	}
 
-	delegatedAddresses, err := unvote(db, from, oldStake)
+	tablesAlias, err := GetTablesAlias(db, alias)
 	if err != nil {
-		return nil, errSystemContractError
+		return nil, err
+	}
+	if tablesAlias != nil {
+		return nil, errTablesAliasExists
 	}
 
-	if err := vote(db, from, delegatedAddresses, newStake); err != nil {
-		return nil, errSystemContractError
+	if !db.HasTable(TablesAliasTable) {
+		db.CreateTable(TablesAliasTable, &TablesAlias{})
+		db.CreateIndex(ebkdb.IndexField{
+			Table: TablesAliasTable,
+			Field: "Owner",
+		})
 	}
 
-	//  Update whole system staked amount
-	systemStakedBytesOut, found := db.Get([]byte(types.SystemStakeDBKey))
-	if !found {
-		return nil, errSystemContractError
+	tablesAlias = &TablesAlias{
+		Id:       alias,
+		Owner:    owner,
+		ActiveAt: now + tablesAliasDelay,
 	}
 
-	systemStakedOut := binary.BigEndian.Uint64(*systemStakedBytesOut)
-	if systemStakedOut < amount {
+	if err := db.InsertObj(TablesAliasTable, tablesAlias); err != nil {
 		return nil, errSystemContractError
 	}
 
-	systemStaked := systemStakedOut - amount
-	systemStakedBytesIn := make([]byte, 8)
-	binary.BigEndian.PutUint64(systemStakedBytesIn[:], systemStaked)
-	db.Insert([]byte(types.SystemStakeDBKey), systemStakedBytesIn)
+	if err := c.addLog(evm, evmABI, "TablesAliasSet", []common.Hash{owner.Hash(), alias.Hash()}, tablesAlias.ActiveAt); err != nil {
+		return nil, err
+	}
 
 	return nil, nil
 }
 
-func (c *systemContract) claimCmd(evm *EVM, from common.Address) ([]byte, error) {
+// acceptTablesAliasCmd is called by the alias contract from to consent to the
+// alias of the tables namespace of owner, set by owner.
+func (c *systemContract) acceptTablesAliasCmd(evm *EVM, evmABI *abi.ABI, from common.Address, owner common.Address) ([]byte, error) {
 	db := evm.EbakusState
 
-	// check if user has claimable tokens
-	whereClause, err := makeIDLikeWhereClause(db, from)
+	tablesAlias, err := GetTablesAlias(db, from)
 	if err != nil {
 		return nil, err
 	}
+	if tablesAlias == nil || tablesAlias.Owner != owner {
+		return nil, errTablesAliasNotFound
+	}
+	if tablesAlias.Accepted {
+		return nil, nil
+	}
 
-	iter, err := db.Select(ClaimableTable, whereClause)
-	if err != nil {
+	tablesAlias.Accepted = true
+	if err := db.InsertObj(TablesAliasTable, tablesAlias); err != nil {
 		return nil, errSystemContractError
 	}
 
-	claimableAmount := uint64(0)
-	var claimable Claimable
-	claimablesToBeDeleted := make([]Claimable, 0)
+	if err := c.addLog(evm, evmABI, "TablesAliasAccepted", []common.Hash{owner.Hash(), from.Hash()}); err != nil {
+		return nil, err
+	}
 
-	for iter.Next(&claimable) {
-		if claimable.Timestamp <= evm.Time.Uint64() {
-			claimableAmount = claimableAmount + claimable.Amount
+	return nil, nil
+}
 
-			claimablesToBeDeleted = append(claimablesToBeDeleted, claimable)
-		}
-		claimable = Claimable{}
+// removeTablesAliasCmd revokes immediately an alias of the tables namespace used by from.
+func (c *systemContract) removeTablesAliasCmd(evm *EVM, evmABI *abi.ABI, from common.Address, alias common.Address) ([]byte, error) {
+	db := evm.EbakusState
+
+	owner, err := GetTablesNamespace(db, from, evm.Time.Uint64())
+	if err != nil {
+		return nil, err
 	}
 
-	for _, claimableEntry := range claimablesToBeDeleted {
-		if err := db.DeleteObj(ClaimableTable, claimableEntry.Id); err != nil {
-			return nil, errSystemContractError
-		}
+	tablesAlias, err := GetTablesAlias(db, alias)
+	if err != nil {
+		return nil, err
+	}
+	if tablesAlias == nil || tablesAlias.Owner != owner {
+		return nil, errTablesAliasNotFound
 	}
 
-	if claimableAmount <= 0 {
-		log.Trace("No amount to be claimed")
-		return nil, nil
+	if err := db.DeleteObj(TablesAliasTable, alias); err != nil {
+		return nil, errSystemContractError
 	}
 
-	claimableAmountWei := new(big.Int).Mul(new(big.Int).SetUint64(claimableAmount), precisionFactor)
-	// Fail if we're trying to transfer more than the available balance
-	if !evm.CanTransfer(evm.StateDB, types.PrecompliledSystemContract, claimableAmountWei) {
-		log.Trace("Failed to claim amount because of insufficient balance", "err", err)
-		return nil, ErrInsufficientBalance
+	if err := c.addLog(evm, evmABI, "TablesAliasRemoved", []common.Hash{owner.Hash(), alias.Hash()}); err != nil {
+		return nil, err
 	}
-	evm.Transfer(evm.StateDB, types.PrecompliledSystemContract, from, claimableAmountWei)
 
 	return nil, nil
 }
 
-func GetStaked(db *ebakusdb.Snapshot, from common.Address) (*types.Staked, error) {
-	var staked types.Staked
+// transferLockedCmd moves amount from the sender to the system contract, to be
+// claimed by the recipient once unlockTime is reached. Transfers between the same
+// accounts unlocking at the same time are accumulated.
+func (c *systemContract) transferLockedCmd(evm *EVM, evmABI *abi.ABI, from common.Address, to common.Address, amount uint64, unlockTime uint64) ([]byte, error) {
+	now := evm.Time.Uint64()
+	if to == (common.Address{}) || unlockTime <= now || unlockTime-now > maxLockedPeriod {
+		return nil, errLockedTransferInvalid
+	}
+	amountWei := evm.toWei(amount)
+	if amountWei.Cmp(big.NewInt(minLockedTransferWei)) < 0 {
+		return nil, errLockedTransferInvalid
+	}
 
-	whereClause, err := makeIDLikeWhereClause(db, from)
+	db := evm.EbakusState
+
+	transfers, err := GetLockedTransfers(db, to)
 	if err != nil {
 		return nil, err
 	}
 
-	iter, err := db.Select(types.StakedTable, whereClause)
-	if err != nil {
-		return nil, errSystemContractError
+	id := GetLockedTransferId(to, from, unlockTime)
+	transfer := &LockedTransfer{Id: id, UnlockTime: unlockTime}
+
+	found, pending := false, 0
+	for i := range transfers {
+		if transfers[i].Id == id {
+			transfer, found = &transfers[i], true
+		}
+		if _, sender, _ := transfers[i].Id.Content(); sender == from {
+			pending++
+		}
+	}
+	if !found && pending >= maxLockedTransfers {
+		log.Trace("Locked transfer failed as maxLockedTransfers reached", "from", from, "to", to, "maxLockedTransfers", maxLockedTransfers)
+		return nil, errLockedTransferTooMany
 	}
 
-	if iter.Next(&staked) == false {
-		return nil, nil
+	var overflow bool
+	if transfer.Amount, overflow = math.SafeAdd(transfer.Amount, amount); overflow {
+		return nil, errLockedTransferInvalid
 	}
 
-	return &staked, nil
-}
+	if !evm.CanTransfer(evm.StateDB, from, amountWei) {
+		log.Trace("Failed to lock amount because of insufficient balance")
+		return nil, ErrInsufficientBalance
+	}
 
-func unique(addresses []common.Address) []common.Address {
-	used := make(map[common.Address]bool)
-	res := []common.Address{}
-	for _, entry := range addresses {
-		if _, value := used[entry]; !value {
-			used[entry] = true
-			res = append(res, entry)
-		}
+	if !db.HasTable(LockedTransferTable) {
+		db.CreateTable(LockedTransferTable, &LockedTransfer{})
 	}
-	return res
+
+	if err := db.InsertObj(LockedTransferTable, transfer); err != nil {
+		return nil, errSystemContractError
+	}
+
+	evm.Transfer(evm.StateDB, from, types.PrecompliledSystemContract, amountWei)
+
+	if err := c.addLog(evm, evmABI, "LockedTransfer", []common.Hash{from.Hash(), to.Hash()}, amount, unlockTime); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
 }
 
-func (c *systemContract) voteCmd(evm *EVM, from common.Address, addresses []common.Address) ([]byte, error) {
+// claimLockedTransfers deletes the unlocked transfers to the recipient, returning
+// their total amount for claimCmd to pay out.
+func (c *systemContract) claimLockedTransfers(evm *EVM, evmABI *abi.ABI, to common.Address) (uint64, error) {
 	db := evm.EbakusState
 
-	addresses = unique(addresses)
+	transfers, err := GetLockedTransfers(db, to)
+	if err != nil {
+		return 0, err
+	}
 
-	if len(addresses) > int(evm.chainConfig.DPOS.MaxWitnessesVotes) {
-		return nil, errVoteMaxWitnessesReached
+	unlockedAmount := uint64(0)
+	for _, transfer := range transfers {
+		// Transfers are sorted by unlock time, the rest are still locked
+		if transfer.UnlockTime > evm.Time.Uint64() {
+			break
+		}
+
+		var overflow bool
+		if unlockedAmount, overflow = math.SafeAdd(unlockedAmount, transfer.Amount); overflow {
+			return 0, errSystemContractError
+		}
+
+		if err := db.DeleteObj(LockedTransferTable, transfer.Id); err != nil {
+			return 0, errSystemContractError
+		}
+
+		_, from, _ := transfer.Id.Content()
+		if err := c.addLog(evm, evmABI, "LockedTransferClaimed", []common.Hash{from.Hash(), to.Hash()}, transfer.Amount); err != nil {
+			return 0, err
+		}
 	}
 
-	staked, err := GetStaked(db, from)
-	if err != nil {
-		return nil, err
+	return unlockedAmount, nil
+}
+
+// subscribeCmd creates, or replaces, the subscription of a payer to a payee. The
+// first payment is due right away.
+func (c *systemContract) subscribeCmd(evm *EVM, evmABI *abi.ABI, subscription *Subscription) ([]byte, error) {
+	payer, payee := subscription.Id.Content()
+	now := evm.Time.Uint64()
+
+	if payee == (common.Address{}) || payee == payer || subscription.Amount == 0 ||
+		subscription.Interval < minSubscriptionInterval || subscription.Expiry <= now ||
+		subscription.Allowance < subscription.Amount {
+		return nil, errSubscriptionInvalid
 	}
+	subscription.NextSettle = now
 
-	if staked == nil {
-		return nil, errVoteNothingStaked
+	db := evm.EbakusState
+
+	if !db.HasTable(SubscriptionTable) {
+		db.CreateTable(SubscriptionTable, &Subscription{})
 	}
 
-	if _, err := unvote(db, from, staked.Amount); err != nil {
-		return nil, err
+	if err := db.InsertObj(SubscriptionTable, subscription); err != nil {
+		return nil, errSystemContractError
 	}
 
-	if err := vote(db, from, addresses, staked.Amount); err != nil {
+	if err := c.addLog(evm, evmABI, "Subscribed", []common.Hash{payer.Hash(), payee.Hash()}, subscription.Amount, subscription.Interval, subscription.Expiry, subscription.Allowance); err != nil {
 		return nil, err
 	}
 
 	return nil, nil
 }
 
-func (c *systemContract) unvoteCmd(evm *EVM, from common.Address) ([]byte, error) {
+// unsubscribeCmd cancels the subscription of a payer to a payee, dropping any
+// unsettled payments.
+func (c *systemContract) unsubscribeCmd(evm *EVM, evmABI *abi.ABI, payer common.Address, payee common.Address) ([]byte, error) {
 	db := evm.EbakusState
 
-	staked, err := GetStaked(db, from)
+	subscription, err := GetSubscription(db, payer, payee)
 	if err != nil {
 		return nil, err
 	}
-
-	if staked == nil {
-		return nil, errVoteNothingStaked
+	if subscription == nil {
+		return nil, errSubscriptionNotFound
 	}
 
-	if _, err := unvote(db, from, staked.Amount); err != nil {
+	if err := db.DeleteObj(SubscriptionTable, subscription.Id); err != nil {
 		return nil, errSystemContractError
 	}
 
+	if err := c.addLog(evm, evmABI, "Unsubscribed", []common.Hash{payer.Hash(), payee.Hash()}); err != nil {
+		return nil, err
+	}
+
 	return nil, nil
 }
 
-func (c *systemContract) electEnableCmd(evm *EVM, from common.Address, enable bool) ([]byte, error) {
+// settleCmd pays the payee all the payments of the subscription due until now,
+// bounded by the allowance left. Subscriptions which expired or ran out of
+// allowance are deleted.
+func (c *systemContract) settleCmd(evm *EVM, evmABI *abi.ABI, payer common.Address, payee common.Address) ([]byte, error) {
 	db := evm.EbakusState
 
-	var witness Witness
-
-	whereClause, err := makeIDLikeWhereClause(db, from)
+	subscription, err := GetSubscription(db, payer, payee)
 	if err != nil {
 		return nil, err
 	}
-
-	iter, err := db.Select(WitnessesTable, whereClause)
-	if err != nil {
-		return nil, errSystemContractError
+	if subscription == nil {
+		return nil, errSubscriptionNotFound
 	}
 
-	if iter.Next(&witness) == false {
-		witness = Witness{
-			Id:    from,
-			Stake: 0,
-			Flags: 0,
-		}
+	// Payments are due at NextSettle and every Interval after it, before Expiry
+	last := evm.Time.Uint64()
+	if last >= subscription.Expiry {
+		last = subscription.Expiry - 1
 	}
-
-	if enable {
-		witness.Flags |= ElectEnabledFlag
-	} else {
-		witness.Flags &= ^ElectEnabledFlag
+	if subscription.NextSettle > last {
+		return nil, errSubscriptionNotDue
 	}
 
-	if err := db.InsertObj(WitnessesTable, &witness); err != nil {
-		return nil, errSystemContractError
+	periods := (last-subscription.NextSettle)/subscription.Interval + 1
+	if affordable := subscription.Allowance / subscription.Amount; periods > affordable {
+		periods = affordable
 	}
+	if periods == 0 {
+		return nil, errSubscriptionNotDue
+	}
+	amount := periods * subscription.Amount // Bounded by the allowance
 
-	return nil, nil
-}
+	subscription.Allowance -= amount
 
-func (c *systemContract) storeAbiAtAddress(evm *EVM, contractAddress common.Address, abi string) ([]byte, error) {
-	return storeAbiAtAddress(evm.EbakusState, contractAddress, abi)
-}
+	var overflow bool
+	if subscription.NextSettle, overflow = math.SafeAdd(subscription.NextSettle, periods*subscription.Interval); overflow {
+		// No further payment is due before the expiry
+		subscription.NextSettle = subscription.Expiry
+	}
 
-func storeAbiAtAddress(db *ebakusdb.Snapshot, contractAddress common.Address, abi string) ([]byte, error) {
-	id := GetContractAbiId(contractAddress, "abi", "")
+	amountWei := evm.toWei(amount)
+	if !evm.CanTransfer(evm.StateDB, payer, amountWei) {
+		log.Trace("Failed to settle subscription because of insufficient balance", "payer", payer, "payee", payee)
+		return nil, ErrInsufficientBalance
+	}
 
-	where := []byte("Id LIKE ")
-	whereClause, err := db.WhereParser(append(where, id[:]...))
-	if err != nil {
+	if subscription.Allowance < subscription.Amount || subscription.NextSettle >= subscription.Expiry {
+		if err := db.DeleteObj(SubscriptionTable, subscription.Id); err != nil {
+			return nil, errSystemContractError
+		}
+	} else if err := db.InsertObj(SubscriptionTable, subscription); err != nil {
 		return nil, errSystemContractError
 	}
 
-	iter, err := db.Select(ContractAbiTable, whereClause)
-	if err != nil {
-		return nil, errSystemContractError
+	evm.Transfer(evm.StateDB, payer, payee, amountWei)
+
+	if err := c.addLog(evm, evmABI, "SubscriptionSettled", []common.Hash{payer.Hash(), payee.Hash()}, amount, periods); err != nil {
+		return nil, err
 	}
 
-	var contractAbi ContractAbi
+	return nil, nil
+}
 
-	if iter.Next(&contractAbi) == true {
-		return nil, errContractAbiExists
+// approveCmd sets the amount the spender may pull from the owner, replacing any
+// previous allowance. Approving zero revokes the allowance.
+func (c *systemContract) approveCmd(evm *EVM, evmABI *abi.ABI, owner common.Address, spender common.Address, amount uint64) ([]byte, error) {
+	if spender == (common.Address{}) || spender == owner {
+		return nil, errAllowanceInvalid
 	}
 
-	contractAbi = ContractAbi{
-		Id:  id,
-		Abi: abi,
+	if err := setAllowance(evm.EbakusState, owner, spender, amount); err != nil {
+		return nil, err
 	}
 
-	if err := db.InsertObj(ContractAbiTable, &contractAbi); err != nil {
-		return nil, errSystemContractError
+	if err := c.addLog(evm, evmABI, "Approval", []common.Hash{owner.Hash(), spender.Hash()}, amount); err != nil {
+		return nil, err
 	}
 
 	return nil, nil
 }
 
-func (c *systemContract) getAbiAtAddress(evm *EVM, contractAddress common.Address) (string, error) {
-	return GetAbiAtAddress(evm.EbakusState, contractAddress)
-}
+// transferFromCmd moves amount from the owner to the recipient on behalf of the
+// spender, consuming the allowance of the spender.
+func (c *systemContract) transferFromCmd(evm *EVM, evmABI *abi.ABI, spender common.Address, owner common.Address, to common.Address, amount uint64) ([]byte, error) {
+	if to == (common.Address{}) || amount == 0 {
+		return nil, errAllowanceInvalid
+	}
 
-func GetAbiAtAddress(db *ebakusdb.Snapshot, contractAddress common.Address) (string, error) {
+	db := evm.EbakusState
 
-	if contractAddress == types.PrecompliledSystemContract {
-		return SystemContractABI, nil
-	} else if contractAddress == types.PrecompliledDBContract {
-		return DBABI, nil
+	allowance, err := GetAllowance(db, owner, spender)
+	if err != nil {
+		return nil, err
+	}
+	if amount > allowance {
+		log.Trace("Transfer exceeds the allowance", "owner", owner, "spender", spender, "allowance", allowance, "amount", amount)
+		return nil, errAllowanceExceeded
 	}
 
-	idPrefix := GetContractAbiId(contractAddress, "abi", "")
+	amountWei := evm.toWei(amount)
+	if !evm.CanTransfer(evm.StateDB, owner, amountWei) {
+		log.Trace("Failed to transfer from owner because of insufficient balance", "owner", owner)
+		return nil, ErrInsufficientBalance
+	}
 
-	where := []byte("Id LIKE ")
-	whereClause, err := db.WhereParser(append(where, idPrefix[:]...))
-	if err != nil {
-		return "", errSystemContractError
+	if err := setAllowance(db, owner, spender, allowance-amount); err != nil {
+		return nil, err
 	}
 
-	iter, err := db.Select(ContractAbiTable, whereClause)
-	if err != nil {
-		return "", errSystemContractError
+	evm.Transfer(evm.StateDB, owner, to, amountWei)
+
+	if err := c.addLog(evm, evmABI, "ApprovedTransfer", []common.Hash{owner.Hash(), spender.Hash(), to.Hash()}, amount); err != nil {
+		return nil, err
 	}
 
-	var contractAbi ContractAbi
-	if iter.Next(&contractAbi) == false {
-		return "", errContractAbiNotFound
+	return nil, nil
+}
+
+// addLog emits a system contract event. Topics hold the indexed arguments,
+// while args the non indexed ones.
+func (c *systemContract) addLog(evm *EVM, evmABI *abi.ABI, name string, topics []common.Hash, args ...interface{}) error {
+	event, ok := evmABI.Events[name]
+	if !ok {
+		return errSystemContractAbiError
 	}
 
-	contractAbiID := common.BytesToAddress(contractAbi.Id[:common.AddressLength])
-	if contractAbiID != contractAddress {
-		return "", errSystemContractError
+	data, err := event.Inputs.NonIndexed().Pack(args...)
+	if err != nil {
+		log.Trace("SystemContractABI failed to pack event", "event", name, "err", err)
+		return errSystemContractError
 	}
 
-	return contractAbi.Abi, nil
+	evm.StateDB.AddLog(&types.Log{
+		Address:     types.PrecompliledSystemContract,
+		Topics:      append([]common.Hash{event.ID()}, topics...),
+		Data:        data,
+		BlockNumber: evm.BlockNumber.Uint64(),
+	})
+
+	return nil
+}
+
+// addActivityLog emits a staking or voting event, once the precompile logs fork
+// made the system contract log them.
+func (c *systemContract) addActivityLog(evm *EVM, evmABI *abi.ABI, name string, topics []common.Hash, args ...interface{}) error {
+	if !evm.chainRules.IsPrecompileLogs {
+		return nil
+	}
+	return c.addLog(evm, evmABI, name, topics, args...)
+}
+
+// validateMethodInput checks the shape of the abi encoded arguments of a system
+// or db contract call before unpacking them, so malformed payloads are rejected
+// with a precise error. Arguments have to be 32 bytes aligned, with all dynamic
+// offsets pointing within the input and no bytes beyond the encoded data.
+func validateMethodInput(method *abi.Method, input []byte) error {
+	if len(input)%32 != 0 {
+		return errInputMisaligned
+	}
+	size := uint64(len(input))
+
+	head := uint64(32 * len(method.Inputs))
+	if size < head {
+		return errInputTruncated
+	}
+	// Sum up the tail of the dynamic arguments, making sure it fits the input
+	expected := head
+	for i, arg := range method.Inputs {
+		var elemSize uint64
+		switch arg.Type.T {
+		case abi.StringTy, abi.BytesTy:
+			elemSize = 1
+		case abi.SliceTy:
+			elemSize = 32
+		default:
+			continue
+		}
+		offset := new(big.Int).SetBytes(input[i*32 : (i+1)*32])
+		if !offset.IsUint64() || offset.Uint64()%32 != 0 || offset.Uint64() < head || offset.Uint64()+32 > size {
+			return errInputBadOffset
+		}
+		start := offset.Uint64() + 32
+
+		length := new(big.Int).SetBytes(input[start-32 : start])
+		if !length.IsUint64() || length.Uint64() > size {
+			return errInputTruncated
+		}
+		tail := (length.Uint64()*elemSize + 31) / 32 * 32
+		if start+tail > size {
+			return errInputTruncated
+		}
+		expected += 32 + tail
+	}
+	if size > expected {
+		return errInputOversized
+	}
+	return nil
 }
 
 func (c *systemContract) Run(evm *EVM, contract *Contract, input []byte) ([]byte, error) {
 	from := contract.Caller()
 
+	// The system contract writes its own tables, which savepoints can't undo
+	evm.ebakusWriters++
+
 	if len(input) == 0 {
 		return nil, errSystemContractError
 	}
+	if len(input) < 4 {
+		return nil, errInputMethodTooShort
+	}
 
 	evmABI, err := abi.JSON(strings.NewReader(SystemContractABI))
 	if err != nil {
@@ -1174,6 +3231,12 @@ func (c *systemContract) Run(evm *EVM, contract *Contract, input []byte) ([]byte
 	if err != nil {
 		return nil, errSystemContractAbiError
 	}
+	if evm.chainRules.IsStrictInput {
+		if err := validateMethodInput(method, inputData); err != nil {
+			log.Trace("SystemContractABI invalid input", "cmd", method.Name, "err", err)
+			return nil, err
+		}
+	}
 
 	cmd := method.Name
 
@@ -1186,12 +3249,12 @@ func (c *systemContract) Run(evm *EVM, contract *Contract, input []byte) ([]byte
 			return nil, errStakeMalformed
 		}
 
-		_, err := c.claimCmd(evm, from)
+		_, err := c.claimCmd(evm, &evmABI, from)
 		if err != nil {
 			return nil, err
 		}
 
-		return c.stakeCmd(evm, from, amount)
+		return c.stakeCmd(evm, &evmABI, from, amount)
 	case SystemContractGetStakedCmd:
 		return c.getStakedCmd(evm, from)
 	case SystemContractUnstakeCmd:
@@ -1202,20 +3265,33 @@ func (c *systemContract) Run(evm *EVM, contract *Contract, input []byte) ([]byte
 			return nil, errUnstakeMalformed
 		}
 
-		return c.unstakeCmd(evm, from, amount)
-	case SystemContractClaimCmd:
-		return c.claimCmd(evm, from)
-	case SystemContractVoteCmd:
+		return c.unstakeCmd(evm, &evmABI, from, amount)
+	case SystemContractClaimCmd:
+		return c.claimCmd(evm, &evmABI, from)
+	case SystemContractVoteCmd:
+		var addresses []common.Address
+		err = evmABI.UnpackWithArguments(&addresses, cmd, inputData, abi.InputsArgumentsType)
+		if err != nil {
+			log.Trace("SystemContractABI failed to unpack input", "cmd", cmd, "err", err)
+			return nil, errVoteMalformed
+		}
+
+		return c.voteCmd(evm, &evmABI, from, addresses)
+	case SystemContractUnvoteCmd:
+		return c.unvoteCmd(evm, &evmABI, from)
+	case SystemContractUnvoteAddressesCmd:
+		if !evm.chainRules.IsPartialUnvote {
+			return nil, errSystemContractError
+		}
+
 		var addresses []common.Address
 		err = evmABI.UnpackWithArguments(&addresses, cmd, inputData, abi.InputsArgumentsType)
 		if err != nil {
 			log.Trace("SystemContractABI failed to unpack input", "cmd", cmd, "err", err)
-			return nil, errVoteMalformed
+			return nil, errUnvoteMalformed
 		}
 
-		return c.voteCmd(evm, from, addresses)
-	case SystemContractUnvoteCmd:
-		return c.unvoteCmd(evm, from)
+		return c.unvoteAddressesCmd(evm, &evmABI, from, addresses)
 	case SystemContractElectEnableCmd:
 		var enable bool
 		err = evmABI.UnpackWithArguments(&enable, cmd, inputData, abi.InputsArgumentsType)
@@ -1237,7 +3313,7 @@ func (c *systemContract) Run(evm *EVM, contract *Contract, input []byte) ([]byte
 			return nil, errContractAbiMalformed
 		}
 
-		return c.storeAbiAtAddress(evm, input.Address, input.Abi)
+		return c.storeAbiAtAddress(evm, from, input.Address, input.Abi)
 	case SystemContractGetAbiCmd:
 		var contractAddress common.Address
 		err = evmABI.UnpackWithArguments(&contractAddress, cmd, inputData, abi.InputsArgumentsType)
@@ -1258,6 +3334,207 @@ func (c *systemContract) Run(evm *EVM, contract *Contract, input []byte) ([]byte
 		}
 
 		return res[4:], nil
+	case SystemContractGetAbiVersionsCmd:
+		if !evm.chainRules.IsAbiVersioning {
+			return nil, errSystemContractError
+		}
+
+		var contractAddress common.Address
+		err = evmABI.UnpackWithArguments(&contractAddress, cmd, inputData, abi.InputsArgumentsType)
+		if err != nil {
+			log.Trace("SystemContractABI failed to unpack input", "cmd", cmd, "err", err)
+			return nil, errContractAbiMalformed
+		}
+
+		versions, err := GetAbiVersionsAtAddress(evm.EbakusState, contractAddress)
+		if err != nil {
+			return nil, errSystemContractError
+		}
+
+		res, err := evmABI.PackWithArguments(cmd, abi.OutputsArgumentsType, versions)
+		if err != nil {
+			log.Trace("ContractAbi failed to pack response", "err", err)
+			return nil, errSystemContractError
+		}
+
+		return res[4:], nil
+	case SystemContractSetTablesAliasCmd, SystemContractAcceptTablesAliasCmd, SystemContractRemoveTablesAliasCmd:
+		if !evm.chainRules.IsTableAlias {
+			return nil, errSystemContractError
+		}
+
+		// the alias to set or remove, or the owner of the accepted alias
+		var address common.Address
+		err = evmABI.UnpackWithArguments(&address, cmd, inputData, abi.InputsArgumentsType)
+		if err != nil {
+			log.Trace("SystemContractABI failed to unpack input", "cmd", cmd, "err", err)
+			return nil, errTablesAliasMalformed
+		}
+
+		switch cmd {
+		case SystemContractSetTablesAliasCmd:
+			return c.setTablesAliasCmd(evm, &evmABI, from, address)
+		case SystemContractAcceptTablesAliasCmd:
+			return c.acceptTablesAliasCmd(evm, &evmABI, from, address)
+		}
+		return c.removeTablesAliasCmd(evm, &evmABI, from, address)
+	case SystemContractTransferLockedCmd:
+		if !evm.chainRules.IsLockedTransfer {
+			return nil, errSystemContractError
+		}
+
+		type transferLockedInput struct {
+			To         common.Address
+			Amount     uint64
+			UnlockTime uint64
+		}
+
+		var input transferLockedInput
+		err = evmABI.UnpackWithArguments(&input, cmd, inputData, abi.InputsArgumentsType)
+		if err != nil {
+			log.Trace("SystemContractABI failed to unpack input", "cmd", cmd, "err", err)
+			return nil, errLockedTransferMalformed
+		}
+
+		return c.transferLockedCmd(evm, &evmABI, from, input.To, input.Amount, input.UnlockTime)
+	case SystemContractSubscribeCmd:
+		if !evm.chainRules.IsSubscription {
+			return nil, errSystemContractError
+		}
+
+		type subscribeInput struct {
+			Payee     common.Address
+			Amount    uint64
+			Interval  uint64
+			Expiry    uint64
+			Allowance uint64
+		}
+
+		var input subscribeInput
+		err = evmABI.UnpackWithArguments(&input, cmd, inputData, abi.InputsArgumentsType)
+		if err != nil {
+			log.Trace("SystemContractABI failed to unpack input", "cmd", cmd, "err", err)
+			return nil, errSubscriptionMalformed
+		}
+
+		subscription := &Subscription{
+			Id:        GetSubscriptionId(from, input.Payee),
+			Amount:    input.Amount,
+			Interval:  input.Interval,
+			Expiry:    input.Expiry,
+			Allowance: input.Allowance,
+		}
+		return c.subscribeCmd(evm, &evmABI, subscription)
+	case SystemContractUnsubscribeCmd, SystemContractSettleCmd:
+		if !evm.chainRules.IsSubscription {
+			return nil, errSystemContractError
+		}
+
+		var account common.Address
+		err = evmABI.UnpackWithArguments(&account, cmd, inputData, abi.InputsArgumentsType)
+		if err != nil {
+			log.Trace("SystemContractABI failed to unpack input", "cmd", cmd, "err", err)
+			return nil, errSubscriptionMalformed
+		}
+
+		if cmd == SystemContractUnsubscribeCmd {
+			return c.unsubscribeCmd(evm, &evmABI, from, account)
+		}
+		return c.settleCmd(evm, &evmABI, account, from)
+	case SystemContractApproveCmd:
+		if !evm.chainRules.IsAllowance {
+			return nil, errSystemContractError
+		}
+
+		type approveInput struct {
+			Spender common.Address
+			Amount  uint64
+		}
+
+		var input approveInput
+		err = evmABI.UnpackWithArguments(&input, cmd, inputData, abi.InputsArgumentsType)
+		if err != nil {
+			log.Trace("SystemContractABI failed to unpack input", "cmd", cmd, "err", err)
+			return nil, errAllowanceMalformed
+		}
+
+		return c.approveCmd(evm, &evmABI, from, input.Spender, input.Amount)
+	case SystemContractAllowanceCmd:
+		if !evm.chainRules.IsAllowance {
+			return nil, errSystemContractError
+		}
+
+		type allowanceInput struct {
+			Owner   common.Address
+			Spender common.Address
+		}
+
+		var input allowanceInput
+		err = evmABI.UnpackWithArguments(&input, cmd, inputData, abi.InputsArgumentsType)
+		if err != nil {
+			log.Trace("SystemContractABI failed to unpack input", "cmd", cmd, "err", err)
+			return nil, errAllowanceMalformed
+		}
+
+		amount, err := GetAllowance(evm.EbakusState, input.Owner, input.Spender)
+		if err != nil {
+			return nil, err
+		}
+
+		res := make([]byte, 32)
+		binary.BigEndian.PutUint64(res[24:], amount)
+		return res, nil
+	case SystemContractTransferFromCmd:
+		if !evm.chainRules.IsAllowance {
+			return nil, errSystemContractError
+		}
+
+		type transferFromInput struct {
+			Owner  common.Address
+			To     common.Address
+			Amount uint64
+		}
+
+		var input transferFromInput
+		err = evmABI.UnpackWithArguments(&input, cmd, inputData, abi.InputsArgumentsType)
+		if err != nil {
+			log.Trace("SystemContractABI failed to unpack input", "cmd", cmd, "err", err)
+			return nil, errAllowanceMalformed
+		}
+
+		return c.transferFromCmd(evm, &evmABI, from, input.Owner, input.To, input.Amount)
+	case SystemContractBridgeIntentCmd:
+		if !evm.chainRules.IsBridge {
+			return nil, errSystemContractError
+		}
+
+		type bridgeIntentInput struct {
+			ChainId   uint64
+			Recipient [32]byte
+			Amount    uint64
+		}
+
+		var input bridgeIntentInput
+		err = evmABI.UnpackWithArguments(&input, cmd, inputData, abi.InputsArgumentsType)
+		if err != nil {
+			log.Trace("SystemContractABI failed to unpack input", "cmd", cmd, "err", err)
+			return nil, errBridgeMalformed
+		}
+
+		return c.bridgeIntentCmd(evm, &evmABI, from, input.ChainId, common.Hash(input.Recipient), input.Amount)
+	case SystemContractAnnounceEnodeCmd, SystemContractSetWitnessEnodeCmd:
+		if !evm.chainRules.IsEnodeAnnounce {
+			return nil, errSystemContractError
+		}
+
+		var url string
+		err = evmABI.UnpackWithArguments(&url, cmd, inputData, abi.InputsArgumentsType)
+		if err != nil {
+			log.Trace("SystemContractABI failed to unpack input", "cmd", cmd, "err", err)
+			return nil, errEnodeMalformed
+		}
+
+		return c.announceEnodeCmd(evm, from, url)
 	default:
 		return nil, errSystemContractError
 	}
@@ -1761,6 +4038,187 @@ const DBABI = `[
     }
   ],
   "stateMutability": "nonpayable"
+},{
+  "type": "function",
+  "name": "closeIterator",
+  "inputs": [
+    {
+      "type": "bytes32"
+    }
+  ],
+  "outputs": [],
+  "stateMutability": "nonpayable"
+},{
+  "type": "function",
+  "name": "collectGarbage",
+  "inputs": [
+    {
+      "name": "owner",
+      "type": "address"
+    },
+    {
+      "name": "tableName",
+      "type": "string"
+    },
+    {
+      "name": "limit",
+      "type": "uint64"
+    }
+  ],
+  "outputs": [
+    {
+      "type": "uint64"
+    }
+  ],
+  "stateMutability": "nonpayable"
+},{
+  "type": "function",
+  "name": "updateObj",
+  "inputs": [
+    {
+      "name": "tableName",
+      "type": "string"
+    },
+    {
+      "name": "id",
+      "type": "bytes"
+    },
+    {
+      "name": "fieldNames",
+      "type": "string"
+    },
+    {
+      "name": "data",
+      "type": "bytes"
+    }
+  ],
+  "outputs": [
+    {
+      "type": "bool"
+    }
+  ],
+  "stateMutability": "nonpayable"
+},{
+  "type": "function",
+  "name": "savepoint",
+  "inputs": [],
+  "outputs": [
+    {
+      "type": "uint64"
+    }
+  ],
+  "stateMutability": "nonpayable"
+},{
+  "type": "function",
+  "name": "rollback",
+  "inputs": [
+    {
+      "name": "savepoint",
+      "type": "uint64"
+    }
+  ],
+  "outputs": [],
+  "stateMutability": "nonpayable"
+},{
+  "type": "function",
+  "name": "releaseSavepoint",
+  "inputs": [
+    {
+      "name": "savepoint",
+      "type": "uint64"
+    }
+  ],
+  "outputs": [],
+  "stateMutability": "nonpayable"
+},{
+  "type": "event",
+  "name": "TableCreated",
+  "inputs": [
+    {
+      "name": "owner",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "table",
+      "type": "string",
+      "indexed": false
+    }
+  ],
+  "anonymous": false
+},{
+  "type": "event",
+  "name": "RowInserted",
+  "inputs": [
+    {
+      "name": "owner",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "table",
+      "type": "string",
+      "indexed": false
+    },
+    {
+      "name": "data",
+      "type": "bytes",
+      "indexed": false
+    }
+  ],
+  "anonymous": false
+},{
+  "type": "event",
+  "name": "RowDeleted",
+  "inputs": [
+    {
+      "name": "owner",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "table",
+      "type": "string",
+      "indexed": false
+    },
+    {
+      "name": "id",
+      "type": "bytes",
+      "indexed": false
+    }
+  ],
+  "anonymous": false
+},{
+  "type": "event",
+  "name": "RowUpdated",
+  "inputs": [
+    {
+      "name": "owner",
+      "type": "address",
+      "indexed": true
+    },
+    {
+      "name": "table",
+      "type": "string",
+      "indexed": false
+    },
+    {
+      "name": "id",
+      "type": "bytes",
+      "indexed": false
+    },
+    {
+      "name": "fieldNames",
+      "type": "string",
+      "indexed": false
+    },
+    {
+      "name": "data",
+      "type": "bytes",
+      "indexed": false
+    }
+  ],
+  "anonymous": false
 }]`
 
 // dbContract exposes ebakusdb to solidity
@@ -1768,7 +4226,7 @@ type dbContract struct{}
 
 // RequiredGas returns the gas required to execute the pre-compiled contract.
 func (c *dbContract) RequiredGas(input []byte) uint64 {
-	if len(input) == 0 {
+	if len(input) < 4 {
 		return params.DBContractBaseGas
 	}
 
@@ -1777,7 +4235,7 @@ func (c *dbContract) RequiredGas(input []byte) uint64 {
 		return params.DBContractBaseGas
 	}
 
-	cmdData, _ := input[:4], input[4:]
+	cmdData, inputData := input[:4], input[4:]
 	method, err := evmABI.MethodById(cmdData)
 	if err != nil {
 		return params.DBContractBaseGas
@@ -1798,6 +4256,20 @@ func (c *dbContract) RequiredGas(input []byte) uint64 {
 		return params.DBContractSelectGas
 	case DBContractNextCmd:
 		return params.DBContractNextGas
+	case DBContractCloseIteratorCmd:
+		return params.DBContractCloseIteratorGas
+	case DBContractCollectGarbageCmd:
+		return params.DBContractCollectGarbageGas
+	case DBContractUpdateObjCmd:
+		var updateObj updateObjDef
+		if err = evmABI.UnpackWithArguments(&updateObj, cmd, inputData, abi.InputsArgumentsType); err != nil {
+			return params.DBContractBaseGas
+		}
+		return params.DBContractUpdateObjGas + params.DBContractUpdateFieldGas*uint64(len(splitFieldNames(updateObj.FieldNames)))
+	case DBContractSavepointCmd, DBContractReleaseSavepointCmd:
+		return params.DBContractSavepointGas
+	case DBContractRollbackCmd:
+		return params.DBContractRollbackGas
 	default:
 		return params.DBContractBaseGas
 	}
@@ -1817,6 +4289,12 @@ type deleteObjDef struct {
 	TableName string
 	Id        []byte
 }
+type updateObjDef struct {
+	TableName  string
+	Id         []byte
+	FieldNames string
+	Data       []byte
+}
 
 type selectDef struct {
 	TableName   string
@@ -1824,7 +4302,13 @@ type selectDef struct {
 	OrderClause string
 }
 
-func GetAbiForTable(db *ebakusdb.Snapshot, contractAddress common.Address, name string) (*abi.ABI, error) {
+type collectGarbageDef struct {
+	Owner     common.Address
+	TableName string
+	Limit     uint64
+}
+
+func GetAbiForTable(db ebkdb.State, contractAddress common.Address, name string) (*abi.ABI, error) {
 	var abiString string
 
 	if contractAddress == types.PrecompliledSystemContract {
@@ -1865,9 +4349,41 @@ func (c *dbContract) prependByteSize(data []byte) []byte {
 	return append(size, data...)
 }
 
-func (c *dbContract) createTable(evm *EVM, contractAddress common.Address, table tableDef) ([]byte, error) {
+// useRowSizeGas charges the gas proportional to the size of the rows and the
+// number of the indexes handled by a command, after the row size gas fork.
+func (c *dbContract) useRowSizeGas(evm *EVM, contract *Contract, gas uint64) error {
+	if !evm.chainRules.IsRowSizeGas {
+		return nil
+	}
+	if !contract.UseGas(gas) {
+		return ErrOutOfGas
+	}
+	return nil
+}
+
+// checkWritable returns an error if the tables of contractAddress are tombstoned.
+func (c *dbContract) checkWritable(evm *EVM, contractAddress common.Address) error {
+	if !evm.chainRules.IsTableTombstone {
+		return nil
+	}
+
+	tombstoned, err := IsContractTombstoned(evm.EbakusState, contractAddress)
+	if err != nil {
+		return err
+	}
+	if tombstoned {
+		return errTableTombstoned
+	}
+	return nil
+}
+
+func (c *dbContract) createTable(evm *EVM, evmABI *abi.ABI, contract *Contract, contractAddress common.Address, table tableDef) ([]byte, error) {
 	db := evm.EbakusState
 
+	if err := c.checkWritable(evm, contractAddress); err != nil {
+		return nil, err
+	}
+
 	if table.TableName == "" {
 		return nil, errEmptyTableNameError
 	}
@@ -1910,33 +4426,50 @@ func (c *dbContract) createTable(evm *EVM, contractAddress common.Address, table
 		Abi: table.Abi,
 	}
 
+	var indexes []string
+	if table.Indexes != "" {
+		indexes = strings.Split(table.Indexes, ",")
+	}
+	if err := c.useRowSizeGas(evm, contract, uint64(len(indexes))*params.DBContractIndexGas); err != nil {
+		return nil, err
+	}
+
 	db.CreateTable(dbTableName, obj)
 
-	if table.Indexes != "" {
-		indexes := strings.Split(table.Indexes, ",")
-		for _, index := range indexes {
-			db.CreateIndex(ebakusdb.IndexField{
-				Table: dbTableName,
-				Field: index,
-			})
-		}
+	for _, index := range indexes {
+		db.CreateIndex(ebkdb.IndexField{
+			Table: dbTableName,
+			Field: index,
+		})
 	}
 
 	if err := db.InsertObj(ContractAbiTable, &contractAbi); err != nil {
 		return nil, errDBContractError
 	}
 
+	if err := c.addLog(evm, evmABI, "TableCreated", []common.Hash{contractAddress.Hash()}, table.TableName); err != nil {
+		return nil, err
+	}
+
 	return common.LeftPadBytes([]byte{1}, 32), nil
 }
 
-func (c *dbContract) insertObj(evm *EVM, contractAddress common.Address, insertObj insertObjDef) ([]byte, error) {
+func (c *dbContract) insertObj(evm *EVM, evmABI *abi.ABI, contract *Contract, contractAddress common.Address, insertObj insertObjDef) ([]byte, error) {
 	db := evm.EbakusState
 
+	if err := c.checkWritable(evm, contractAddress); err != nil {
+		return nil, err
+	}
+
 	if insertObj.TableName == "" {
 		return nil, errEmptyTableNameError
 	}
 	dbTableName := ebkdb.GetDBTableName(contractAddress, insertObj.TableName)
 
+	if err := c.useRowSizeGas(evm, contract, uint64(len(insertObj.Data))*params.DBContractWriteByteGas); err != nil {
+		return nil, err
+	}
+
 	tableABI, err := GetAbiForTable(db, contractAddress, insertObj.TableName)
 	if err != nil {
 		return nil, err
@@ -1955,12 +4488,20 @@ func (c *dbContract) insertObj(evm *EVM, contractAddress common.Address, insertO
 		return common.LeftPadBytes([]byte{0}, 32), nil
 	}
 
+	if err := c.addLog(evm, evmABI, "RowInserted", []common.Hash{contractAddress.Hash()}, insertObj.TableName, insertObj.Data); err != nil {
+		return nil, err
+	}
+
 	return common.LeftPadBytes([]byte{1}, 32), nil
 }
 
-func (c *dbContract) deleteObj(evm *EVM, contractAddress common.Address, deleteObj deleteObjDef) ([]byte, error) {
+func (c *dbContract) deleteObj(evm *EVM, evmABI *abi.ABI, contractAddress common.Address, deleteObj deleteObjDef) ([]byte, error) {
 	db := evm.EbakusState
 
+	if err := c.checkWritable(evm, contractAddress); err != nil {
+		return nil, err
+	}
+
 	if deleteObj.TableName == "" {
 		return nil, errEmptyTableNameError
 	}
@@ -1985,10 +4526,188 @@ func (c *dbContract) deleteObj(evm *EVM, contractAddress common.Address, deleteO
 		return common.LeftPadBytes([]byte{0}, 32), nil
 	}
 
+	if err := c.addLog(evm, evmABI, "RowDeleted", []common.Hash{contractAddress.Hash()}, deleteObj.TableName, deleteObj.Id); err != nil {
+		return nil, err
+	}
+
+	return common.LeftPadBytes([]byte{1}, 32), nil
+}
+
+// updateObj loads the row with the given id, overwrites the comma separated
+// fields with the values abi encoded in data, in the same order, and stores it
+// back. The id can't be updated, as that would move the row.
+func (c *dbContract) updateObj(evm *EVM, evmABI *abi.ABI, contract *Contract, contractAddress common.Address, updateObj updateObjDef) ([]byte, error) {
+	db := evm.EbakusState
+
+	if err := c.checkWritable(evm, contractAddress); err != nil {
+		return nil, err
+	}
+
+	if updateObj.TableName == "" {
+		return nil, errEmptyTableNameError
+	}
+	dbTableName := ebkdb.GetDBTableName(contractAddress, updateObj.TableName)
+
+	if err := c.useRowSizeGas(evm, contract, uint64(len(updateObj.Data))*params.DBContractWriteByteGas); err != nil {
+		return nil, err
+	}
+
+	tableABI, err := GetAbiForTable(db, contractAddress, updateObj.TableName)
+	if err != nil {
+		return nil, err
+	}
+	table := tableABI.Tables[updateObj.TableName]
+
+	// Resolve the updated fields before touching the row
+	fieldNames := splitFieldNames(updateObj.FieldNames)
+	if len(fieldNames) == 0 {
+		return nil, errUpdateObjMalformed
+	}
+	fields := make(abi.Arguments, 0, len(fieldNames))
+	for _, name := range fieldNames {
+		if name == "Id" {
+			return nil, errUpdateFieldInvalid
+		}
+		var found bool
+		for _, input := range table.Inputs {
+			if input.Name == name {
+				fields, found = append(fields, input), true
+				break
+			}
+		}
+		if !found {
+			return nil, errUpdateFieldInvalid
+		}
+		for _, field := range fields[:len(fields)-1] {
+			if field.Name == name {
+				return nil, errUpdateFieldInvalid
+			}
+		}
+	}
+	values, err := fields.UnpackValues(updateObj.Data)
+	if err != nil {
+		return nil, errUpdateObjMalformed
+	}
+
+	// Load the row and patch the fields in place
+	idObj, err := tableABI.GetTableInstance(updateObj.TableName)
+	if err != nil {
+		return nil, err
+	}
+	id, err := tableABI.UnpackSingle(idObj, updateObj.TableName, "Id", updateObj.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	whereClause, err := db.WhereParser(append([]byte("Id = "), whereValue(id)...))
+	if err != nil {
+		return nil, errDBContractError
+	}
+	iter, err := db.Select(dbTableName, whereClause)
+	if err != nil {
+		return nil, errDBContractError
+	}
+	defer iter.Release()
+
+	obj, err := tableABI.GetTableInstance(updateObj.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if iter.Next(obj) == false {
+		return common.LeftPadBytes([]byte{0}, 32), nil
+	}
+
+	elem := reflect.ValueOf(obj).Elem()
+	for i, field := range fields {
+		dst, val := elem.FieldByName(abi.ToCamelCase(field.Name)), reflect.ValueOf(values[i])
+		if !dst.IsValid() || !val.IsValid() || !val.Type().AssignableTo(dst.Type()) {
+			return nil, errDBContractError
+		}
+		dst.Set(val)
+	}
+
+	if err := db.InsertObj(dbTableName, obj); err != nil {
+		return common.LeftPadBytes([]byte{0}, 32), nil
+	}
+
+	if err := c.addLog(evm, evmABI, "RowUpdated", []common.Hash{contractAddress.Hash()}, updateObj.TableName, updateObj.Id, updateObj.FieldNames, updateObj.Data); err != nil {
+		return nil, err
+	}
+
 	return common.LeftPadBytes([]byte{1}, 32), nil
 }
 
-func EbakusDBGet(db *ebakusdb.Snapshot, contractAddress common.Address, tableName string, whereClause string, orderClause string) (interface{}, error) {
+// savepoint takes, rolls back to or releases a savepoint of the table writes of
+// the calling contract's frame. Rolling back undoes the frame's table writes
+// since the savepoint, keeping the savepoint for further rollbacks and releasing
+// the ones taken after it. It's refused once other contracts or the system
+// contract got called, as their writes can't be undone. Savepoints are released
+// when the frame returns.
+func (c *dbContract) savepoint(evm *EVM, evmABI *abi.ABI, contract *Contract, cmd string, id uint64) ([]byte, error) {
+	frame, ok := contract.caller.(*Contract)
+	if !ok {
+		return nil, errSavepointNotContract
+	}
+	if cmd == DBContractSavepointCmd {
+		res, err := evmABI.PackWithArguments(cmd, abi.OutputsArgumentsType, evm.addEbakusSavepoint(frame))
+		if err != nil {
+			return nil, errDBContractError
+		}
+		return res[4:], nil
+	}
+
+	savepoint := evm.getEbakusSavepoint(frame, id)
+	if savepoint == nil {
+		return nil, errSavepointNotFound
+	}
+	if cmd == DBContractRollbackCmd {
+		// Only the frame's own table writes may be rolled back, other contracts
+		// and the system contract may have acted upon theirs (e.g. transferred
+		// tokens), which a rollback would leave inconsistent
+		if savepoint.writers != evm.ebakusWriters {
+			return nil, errSavepointCrossed
+		}
+		if err := c.checkWritable(evm, contract.Caller()); err != nil {
+			return nil, err
+		}
+		evm.EbakusState.ResetTo(savepoint.snapshot)
+		evm.releaseEbakusSavepoints(frame, id)
+		return nil, nil
+	}
+	evm.releaseEbakusSavepoints(frame, id-1)
+	return nil, nil
+}
+
+// splitFieldNames splits a comma separated list of table fields.
+func splitFieldNames(fieldNames string) []string {
+	var names []string
+	for _, name := range strings.Split(fieldNames, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// whereValue formats a field value for an ebakusdb where clause. Byte arrays are
+// compared as raw bytes, every other type by its textual form.
+func whereValue(value interface{}) []byte {
+	switch v := value.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8 {
+		b := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(b), rv)
+		return b
+	}
+	return []byte(fmt.Sprint(value))
+}
+
+func EbakusDBGet(db ebkdb.State, contractAddress common.Address, tableName string, whereClause string, orderClause string) (interface{}, error) {
 	if tableName == "" {
 		return nil, errEmptyTableNameError
 	}
@@ -2027,10 +4746,22 @@ func EbakusDBGet(db *ebakusdb.Snapshot, contractAddress common.Address, tableNam
 	return obj, nil
 }
 
-func (c *dbContract) get(evm *EVM, contractAddress common.Address, selectObj selectDef) ([]byte, error) {
+func (c *dbContract) get(evm *EVM, contract *Contract, contractAddress common.Address, selectObj selectDef) ([]byte, error) {
 	db := evm.EbakusState
 
-	obj, err := EbakusDBGet(db, contractAddress, selectObj.TableName, selectObj.WhereClause, selectObj.OrderClause)
+	if err := evm.useEbakusDBRows(1); err != nil {
+		return nil, err
+	}
+
+	var (
+		obj interface{}
+		err error
+	)
+	if evm.chainRules.IsRangeQuery {
+		obj, err = c.getRange(evm, contract, contractAddress, selectObj)
+	} else {
+		obj, err = EbakusDBGet(db, contractAddress, selectObj.TableName, selectObj.WhereClause, selectObj.OrderClause)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -2044,11 +4775,14 @@ func (c *dbContract) get(evm *EVM, contractAddress common.Address, selectObj sel
 	if err != nil {
 		return nil, err
 	}
+	if err := c.useRowSizeGas(evm, contract, uint64(len(data))*params.DBContractReadByteGas); err != nil {
+		return nil, err
+	}
 
 	return c.prependByteSize(data), nil
 }
 
-func EbakusDBSelect(db *ebakusdb.Snapshot, contractAddress common.Address, tableName string, whereClause string, orderClause string) (*ebakusdb.ResultIterator, error) {
+func EbakusDBSelect(db ebkdb.State, contractAddress common.Address, tableName string, whereClause string, orderClause string) (ebkdb.Iterator, error) {
 	if tableName == "" {
 		return nil, errEmptyTableNameError
 	}
@@ -2072,23 +4806,50 @@ func EbakusDBSelect(db *ebakusdb.Snapshot, contractAddress common.Address, table
 	return iter, err
 }
 
-func (c *dbContract) selectIter(evm *EVM, contractAddress common.Address, obj selectDef) ([]byte, error) {
+// EbakusDBExplain returns how ebakusdb would walk a table for a select with the
+// given clauses, without executing it.
+func EbakusDBExplain(db ebkdb.State, contractAddress common.Address, tableName string, whereClause string, orderClause string) (*ebkdb.QueryPlan, error) {
+	if tableName == "" {
+		return nil, errEmptyTableNameError
+	}
+	dbTableName := ebkdb.GetDBTableName(contractAddress, tableName)
+
+	if _, err := db.WhereParser([]byte(whereClause)); err != nil {
+		return nil, errDBContractError
+	}
+
+	if _, err := db.OrderParser([]byte(orderClause)); err != nil {
+		return nil, errDBContractError
+	}
+
+	return ebkdb.Explain(db, dbTableName, whereClause, orderClause)
+}
+
+func (c *dbContract) selectIter(evm *EVM, contract *Contract, contractAddress common.Address, obj selectDef) ([]byte, error) {
 	db := evm.EbakusState
 
-	iter, err := EbakusDBSelect(db, contractAddress, obj.TableName, obj.WhereClause, obj.OrderClause)
+	var (
+		iter ebkdb.Iterator
+		err  error
+	)
+	if evm.chainRules.IsRangeQuery {
+		iter, err = EbakusDBSelectRange(db, contractAddress, obj.TableName, obj.WhereClause, obj.OrderClause, c.scanRow(evm, contract))
+	} else {
+		iter, err = EbakusDBSelect(db, contractAddress, obj.TableName, obj.WhereClause, obj.OrderClause)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	iterPointer := evm.addEbakusStateIterator(obj.TableName, iter)
+	iterPointer := evm.addEbakusStateIterator(contract.caller, obj.TableName, iter)
 
-	var b bytes.Buffer
-	binary.Write(&b, binary.BigEndian, iterPointer)
+	handle := make([]byte, 32)
+	binary.BigEndian.PutUint64(handle, iterPointer)
 
-	return common.RightPadBytes(b.Bytes(), 32), nil
+	return handle, nil
 }
 
-func EbakusDBNext(db *ebakusdb.Snapshot, contractAddress common.Address, tableName string, iter *ebakusdb.ResultIterator) (interface{}, error) {
+func EbakusDBNext(db ebkdb.State, contractAddress common.Address, tableName string, iter ebkdb.Iterator) (interface{}, error) {
 	tableABI, err := GetAbiForTable(db, contractAddress, tableName)
 	if err != nil {
 		return nil, err
@@ -2108,10 +4869,61 @@ func EbakusDBNext(db *ebakusdb.Snapshot, contractAddress common.Address, tableNa
 	return obj, nil
 }
 
-func (c *dbContract) next(evm *EVM, contractAddress common.Address, input []byte) ([]byte, error) {
+// EbakusDBRows returns up to limit rows of a contract table matching the where
+// clause, sorted by the order clause, each one ABI encoded with the schema of
+// the table like the rows returned by the DB contract.
+func EbakusDBRows(db ebkdb.State, contractAddress common.Address, tableName string, whereClause string, orderClause string, limit uint64) ([][]byte, error) {
+	var (
+		tableABI *abi.ABI
+		err      error
+	)
+	if contractAddress == types.PrecompliledSystemContract && tableName == "Staked" {
+		stakedABI, err := abi.JSON(strings.NewReader(StakedTableABI))
+		if err != nil {
+			return nil, errDBContractError
+		}
+		tableABI = &stakedABI
+	} else if tableABI, err = GetAbiForTable(db, contractAddress, tableName); err != nil {
+		return nil, err
+	}
+	iter, err := EbakusDBSelect(db, contractAddress, tableName, whereClause, orderClause)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Release()
+
+	var rows [][]byte
+	for uint64(len(rows)) < limit {
+		obj, err := tableABI.GetTableInstance(tableName)
+		if err != nil {
+			return nil, err
+		}
+		if !iter.Next(obj) {
+			break
+		}
+		row, err := tableABI.Pack(tableName, obj)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (c *dbContract) next(evm *EVM, contract *Contract, contractAddress common.Address, input []byte) ([]byte, error) {
 	db := evm.EbakusState
 
-	tableIter := evm.getEbakusStateIterator(binary.BigEndian.Uint64(input))
+	if err := evm.useEbakusDBRows(1); err != nil {
+		return nil, err
+	}
+
+	if len(input) < 8 {
+		return nil, errIteratorMalformed
+	}
+	tableIter := evm.getEbakusStateIterator(contract.caller, binary.BigEndian.Uint64(input))
+	if tableIter == nil {
+		return nil, errIteratorNotFound
+	}
 
 	obj, err := EbakusDBNext(db, contractAddress, tableIter.TableName, tableIter.Iter)
 	if err != nil {
@@ -2130,16 +4942,152 @@ func (c *dbContract) next(evm *EVM, contractAddress common.Address, input []byte
 	if err != nil {
 		return nil, err
 	}
+	if err := c.useRowSizeGas(evm, contract, params.DBContractRowGas+uint64(len(data))*params.DBContractReadByteGas); err != nil {
+		return nil, err
+	}
 
 	return c.prependByteSize(data), nil
 }
 
+// closeIterator releases an iterator of the calling frame before the frame
+// returns, which releases the rest.
+func (c *dbContract) closeIterator(evm *EVM, contract *Contract, input []byte) ([]byte, error) {
+	if len(input) < 8 {
+		return nil, errIteratorMalformed
+	}
+	handle := binary.BigEndian.Uint64(input)
+	if evm.getEbakusStateIterator(contract.caller, handle) == nil {
+		return nil, errIteratorNotFound
+	}
+	evm.releaseEbakusStateIterator(handle)
+
+	return nil, nil
+}
+
+// collectGarbage deletes up to limit rows from a table of a self-destructed
+// contract. The table abi is dropped as well once the table is emptied. The
+// memory freed is refunded, as the only precompile call meant to free it.
+func (c *dbContract) collectGarbage(evm *EVM, contract *Contract, gc collectGarbageDef) ([]byte, error) {
+	db := evm.EbakusState
+	preUsedMemory := db.GetUsedMemory()
+
+	if gc.TableName == "" {
+		return nil, errEmptyTableNameError
+	}
+
+	tombstoned, err := IsContractTombstoned(db, gc.Owner)
+	if err != nil {
+		return nil, err
+	}
+	if !tombstoned {
+		return nil, errTableNotTombstoned
+	}
+
+	dbTableName := ebkdb.GetDBTableName(gc.Owner, gc.TableName)
+
+	tableABI, err := GetAbiForTable(db, gc.Owner, gc.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := gc.Limit
+	if limit == 0 || limit > maxGarbageCollectRows {
+		limit = maxGarbageCollectRows
+	}
+
+	iter, err := db.Select(dbTableName)
+	if err != nil {
+		return nil, errDBContractError
+	}
+
+	// collect the ids before deleting, so the iterator isn't invalidated. One
+	// more row than the limit is fetched to know if the table gets emptied.
+	ids := make([]interface{}, 0, limit+1)
+	for uint64(len(ids)) <= limit {
+		obj, err := tableABI.GetTableInstance(gc.TableName)
+		if err != nil {
+			iter.Release()
+			return nil, err
+		}
+		if !iter.Next(obj) {
+			break
+		}
+		ids = append(ids, reflect.ValueOf(obj).Elem().FieldByName("Id").Interface())
+	}
+	iter.Release()
+
+	more := uint64(len(ids)) > limit
+	if more {
+		ids = ids[:limit]
+	}
+
+	if !contract.UseGas(uint64(len(ids)) * params.DBContractGarbageRowGas) {
+		return nil, ErrOutOfGas
+	}
+
+	for _, id := range ids {
+		if err := db.DeleteObj(dbTableName, id); err != nil {
+			return nil, errDBContractError
+		}
+	}
+
+	if !more {
+		if err := db.DeleteObj(ContractAbiTable, GetContractAbiId(gc.Owner, "table", gc.TableName)); err != nil {
+			return nil, errDBContractError
+		}
+	}
+
+	if postUsedMemory := db.GetUsedMemory(); postUsedMemory < preUsedMemory {
+		evm.StateDB.AddRefund((preUsedMemory - postUsedMemory) * params.EbakusDBMemoryRefundGas)
+	}
+
+	return common.LeftPadBytes(new(big.Int).SetUint64(uint64(len(ids))).Bytes(), 32), nil
+}
+
+// addLog emits a db contract event once the precompile logs fork made the db
+// contract log the table writes, see systemContract.addLog.
+func (c *dbContract) addLog(evm *EVM, evmABI *abi.ABI, name string, topics []common.Hash, args ...interface{}) error {
+	if !evm.chainRules.IsPrecompileLogs {
+		return nil
+	}
+	event, ok := evmABI.Events[name]
+	if !ok {
+		return errDBContractError
+	}
+
+	data, err := event.Inputs.NonIndexed().Pack(args...)
+	if err != nil {
+		log.Trace("DBABI failed to pack event", "event", name, "err", err)
+		return errDBContractError
+	}
+
+	evm.StateDB.AddLog(&types.Log{
+		Address:     types.PrecompliledDBContract,
+		Topics:      append([]common.Hash{event.ID()}, topics...),
+		Data:        data,
+		BlockNumber: evm.BlockNumber.Uint64(),
+	})
+
+	return nil
+}
+
 func (c *dbContract) Run(evm *EVM, contract *Contract, input []byte) ([]byte, error) {
 	from := contract.Caller()
 
+	if evm.chainRules.IsTableAlias {
+		namespace, err := GetTablesNamespace(evm.EbakusState, from, evm.Time.Uint64())
+		if err != nil {
+			return nil, errDBContractError
+		}
+		from = namespace
+	}
+
 	if len(input) == 0 {
 		return nil, errDBContractError
 	}
+	if len(input) < 4 {
+		return nil, errInputMethodTooShort
+	}
 
 	evmABI, err := abi.JSON(strings.NewReader(DBABI))
 	if err != nil {
@@ -2151,6 +5099,11 @@ func (c *dbContract) Run(evm *EVM, contract *Contract, input []byte) ([]byte, er
 	if err != nil {
 		return nil, errDBContractError
 	}
+	if evm.chainRules.IsStrictInput {
+		if err := validateMethodInput(method, inputData); err != nil {
+			return nil, err
+		}
+	}
 
 	cmd := method.Name
 
@@ -2162,7 +5115,7 @@ func (c *dbContract) Run(evm *EVM, contract *Contract, input []byte) ([]byte, er
 			return nil, errCreateTableMalformed
 		}
 
-		return c.createTable(evm, from, tableObj)
+		return c.createTable(evm, &evmABI, contract, from, tableObj)
 	case DBContractInsertObjCmd:
 		var insertObj insertObjDef
 		err = evmABI.UnpackWithArguments(&insertObj, cmd, inputData, abi.InputsArgumentsType)
@@ -2170,7 +5123,7 @@ func (c *dbContract) Run(evm *EVM, contract *Contract, input []byte) ([]byte, er
 			return nil, errInsertObjMalformed
 		}
 
-		return c.insertObj(evm, from, insertObj)
+		return c.insertObj(evm, &evmABI, contract, from, insertObj)
 	case DBContractDeleteObjCmd:
 		var deleteObj deleteObjDef
 		err = evmABI.UnpackWithArguments(&deleteObj, cmd, inputData, abi.InputsArgumentsType)
@@ -2178,7 +5131,7 @@ func (c *dbContract) Run(evm *EVM, contract *Contract, input []byte) ([]byte, er
 			return nil, errDeleteObjMalformed
 		}
 
-		return c.deleteObj(evm, from, deleteObj)
+		return c.deleteObj(evm, &evmABI, from, deleteObj)
 	case DBContractGetCmd:
 		var selectData selectDef
 		err = evmABI.UnpackWithArguments(&selectData, cmd, inputData, abi.InputsArgumentsType)
@@ -2186,7 +5139,7 @@ func (c *dbContract) Run(evm *EVM, contract *Contract, input []byte) ([]byte, er
 			return nil, errSelectMalformed
 		}
 
-		return c.get(evm, from, selectData)
+		return c.get(evm, contract, from, selectData)
 	case DBContractSelectCmd:
 		var selectData selectDef
 		err = evmABI.UnpackWithArguments(&selectData, cmd, inputData, abi.InputsArgumentsType)
@@ -2194,7 +5147,7 @@ func (c *dbContract) Run(evm *EVM, contract *Contract, input []byte) ([]byte, er
 			return nil, errSelectMalformed
 		}
 
-		return c.selectIter(evm, from, selectData)
+		return c.selectIter(evm, contract, from, selectData)
 	case DBContractNextCmd:
 		var iterData [32]byte
 		err = evmABI.UnpackWithArguments(&iterData, cmd, inputData, abi.InputsArgumentsType)
@@ -2202,7 +5155,57 @@ func (c *dbContract) Run(evm *EVM, contract *Contract, input []byte) ([]byte, er
 			return nil, errIteratorMalformed
 		}
 
-		return c.next(evm, from, iterData[:])
+		return c.next(evm, contract, from, iterData[:])
+	case DBContractCloseIteratorCmd:
+		if !evm.chainRules.IsIteratorScope {
+			return nil, errDBContractError
+		}
+
+		var iterData [32]byte
+		err = evmABI.UnpackWithArguments(&iterData, cmd, inputData, abi.InputsArgumentsType)
+		if err != nil {
+			return nil, errIteratorMalformed
+		}
+
+		return c.closeIterator(evm, contract, iterData[:])
+	case DBContractCollectGarbageCmd:
+		if !evm.chainRules.IsTableTombstone {
+			return nil, errDBContractError
+		}
+
+		var gcData collectGarbageDef
+		err = evmABI.UnpackWithArguments(&gcData, cmd, inputData, abi.InputsArgumentsType)
+		if err != nil {
+			return nil, errGarbageMalformed
+		}
+
+		return c.collectGarbage(evm, contract, gcData)
+	case DBContractUpdateObjCmd:
+		if !evm.chainRules.IsTableUpdate {
+			return nil, errDBContractError
+		}
+
+		var updateObj updateObjDef
+		err = evmABI.UnpackWithArguments(&updateObj, cmd, inputData, abi.InputsArgumentsType)
+		if err != nil {
+			return nil, errUpdateObjMalformed
+		}
+
+		return c.updateObj(evm, &evmABI, contract, from, updateObj)
+	case DBContractSavepointCmd, DBContractRollbackCmd, DBContractReleaseSavepointCmd:
+		if !evm.chainRules.IsSavepoint {
+			return nil, errDBContractError
+		}
+
+		var id uint64
+		if cmd != DBContractSavepointCmd {
+			err = evmABI.UnpackWithArguments(&id, cmd, inputData, abi.InputsArgumentsType)
+			if err != nil {
+				return nil, errSavepointMalformed
+			}
+		}
+
+		return c.savepoint(evm, &evmABI, contract, cmd, id)
 	}
 
 	return nil, nil
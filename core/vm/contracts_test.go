@@ -18,14 +18,22 @@ package vm
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
+	"math"
 	"math/big"
+	"math/rand"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/ebakus/go-ebakus/accounts/abi"
 	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/core/rawdb"
+	"github.com/ebakus/go-ebakus/core/state"
+	"github.com/ebakus/go-ebakus/core/types"
 	"github.com/ebakus/go-ebakus/params"
-	"github.com/ebakus/ebakusdb"
 )
 
 // precompiledTest defines the input/output pairs for precompiled contract tests.
@@ -402,8 +410,8 @@ var blake2FTests = []precompiledTest{
 }
 
 func testPrecompiled(addr string, test precompiledTest, t *testing.T) {
-	ebakusDb, _ := ebakusdb.OpenInMemory(nil)
-	ebakusSnapshot := ebakusDb.GetRootSnapshot()
+	ebakusDb, _ := ebkdb.OpenInMemory(nil)
+	ebakusSnapshot := ebkdb.NewState(ebakusDb.GetRootSnapshot())
 	defer ebakusSnapshot.Release()
 
 	evm := NewEVM(Context{}, nil, ebakusSnapshot, params.TestChainConfig, Config{})
@@ -428,8 +436,8 @@ func testPrecompiled(addr string, test precompiledTest, t *testing.T) {
 }
 
 func testPrecompiledFailure(addr string, test precompiledFailureTest, t *testing.T) {
-	ebakusDb, _ := ebakusdb.OpenInMemory(nil)
-	ebakusSnapshot := ebakusDb.GetRootSnapshot()
+	ebakusDb, _ := ebkdb.OpenInMemory(nil)
+	ebakusSnapshot := ebkdb.NewState(ebakusDb.GetRootSnapshot())
 	defer ebakusSnapshot.Release()
 
 	evm := NewEVM(Context{}, nil, ebakusSnapshot, params.TestChainConfig, Config{})
@@ -455,8 +463,8 @@ func benchmarkPrecompiled(addr string, test precompiledTest, bench *testing.B) {
 	if test.noBenchmark {
 		return
 	}
-	ebakusDb, _ := ebakusdb.OpenInMemory(nil)
-	ebakusSnapshot := ebakusDb.GetRootSnapshot()
+	ebakusDb, _ := ebkdb.OpenInMemory(nil)
+	ebakusSnapshot := ebkdb.NewState(ebakusDb.GetRootSnapshot())
 	defer ebakusSnapshot.Release()
 
 	evm := NewEVM(Context{}, nil, ebakusSnapshot, params.TestChainConfig, Config{})
@@ -685,3 +693,743 @@ func TestPrecompileSystemContractMalformedInput(t *testing.T) {
 		testPrecompiledFailure("101", test, t)
 	}
 }
+
+func TestValidateMethodInput(t *testing.T) {
+	systemABI, _ := abi.JSON(strings.NewReader(SystemContractABI))
+	dbABI, _ := abi.JSON(strings.NewReader(DBABI))
+
+	tests := []struct {
+		abi    abi.ABI
+		method string
+		args   []interface{}
+	}{
+		{systemABI, SystemContractStakeCmd, []interface{}{uint64(1000)}},
+		{systemABI, SystemContractVoteCmd, []interface{}{[]common.Address{{1}, {2}}}},
+		{systemABI, SystemContractUnvoteCmd, nil},
+		{dbABI, DBContractInsertObjCmd, []interface{}{"Users", []byte("a row longer than a single 32 bytes word")}},
+		{dbABI, DBContractNextCmd, []interface{}{[32]byte{1}}},
+		{dbABI, DBContractUpdateObjCmd, []interface{}{"Users", []byte{1}, "Name,Age", []byte{2}}},
+	}
+	for _, test := range tests {
+		method := test.abi.Methods[test.method]
+		packed, err := test.abi.Pack(test.method, test.args...)
+		if err != nil {
+			t.Fatalf("%s: failed to pack: %v", test.method, err)
+		}
+		input := packed[4:]
+
+		if err := validateMethodInput(&method, input); err != nil {
+			t.Errorf("%s: valid input rejected: %v", test.method, err)
+		}
+		if err := validateMethodInput(&method, append(common.CopyBytes(input), 0)); err != errInputMisaligned {
+			t.Errorf("%s: misaligned input error mismatch: have %v, want %v", test.method, err, errInputMisaligned)
+		}
+		if err := validateMethodInput(&method, append(common.CopyBytes(input), make([]byte, 32)...)); err != errInputOversized {
+			t.Errorf("%s: oversized input error mismatch: have %v, want %v", test.method, err, errInputOversized)
+		}
+		if len(input) > 0 {
+			if err := validateMethodInput(&method, input[:len(input)-32]); err != errInputTruncated {
+				t.Errorf("%s: truncated input error mismatch: have %v, want %v", test.method, err, errInputTruncated)
+			}
+		}
+	}
+	// Dynamic arguments pointing outside the input must be rejected
+	method := dbABI.Methods[DBContractInsertObjCmd]
+	packed, _ := dbABI.Pack(DBContractInsertObjCmd, "Users", []byte{1, 2, 3})
+	input := common.CopyBytes(packed[4:])
+	input[31] = 0xff
+	if err := validateMethodInput(&method, input); err != errInputBadOffset {
+		t.Errorf("bad offset error mismatch: have %v, want %v", err, errInputBadOffset)
+	}
+}
+
+// Tests that random and mutated payloads never crash the validation, and that
+// inputs passing it can always be unpacked without panicking.
+func TestValidateMethodInputFuzz(t *testing.T) {
+	systemABI, _ := abi.JSON(strings.NewReader(SystemContractABI))
+	dbABI, _ := abi.JSON(strings.NewReader(DBABI))
+
+	rng := rand.New(rand.NewSource(1))
+	for _, contractABI := range []abi.ABI{systemABI, dbABI} {
+		for name, method := range contractABI.Methods {
+			method := method
+			for i := 0; i < 1000; i++ {
+				input := make([]byte, 32*rng.Intn(8))
+				rng.Read(input)
+
+				// Make offsets and lengths small often enough to pass validation
+				for j := 0; j < len(input); j += 32 {
+					if rng.Intn(2) == 0 {
+						for k := j; k < j+31; k++ {
+							input[k] = 0
+						}
+						input[j+31] = byte(32 * rng.Intn(4))
+					}
+				}
+				if rng.Intn(4) == 0 && len(input) > 0 {
+					input = input[:rng.Intn(len(input))]
+				}
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							t.Fatalf("%s: panic on input %x: %v", name, input, r)
+						}
+					}()
+					if validateMethodInput(&method, input) == nil {
+						method.Inputs.UnpackValues(input)
+					}
+				}()
+			}
+		}
+	}
+}
+
+func TestPrecompileShortMethodId(t *testing.T) {
+	ebakusDb, _ := ebkdb.OpenInMemory(nil)
+	ebakusSnapshot := ebkdb.NewState(ebakusDb.GetRootSnapshot())
+	defer ebakusSnapshot.Release()
+
+	evm := NewEVM(Context{}, nil, ebakusSnapshot, params.TestChainConfig, Config{})
+	for _, input := range [][]byte{{0x01}, {0x01, 0x02}, {0x01, 0x02, 0x03}} {
+		system := PrecompiledContractsEbakus[types.PrecompliledSystemContract]
+		if gas := system.RequiredGas(input); gas != params.SystemContractBaseGas {
+			t.Errorf("system contract gas mismatch for %x: have %d, want %d", input, gas, params.SystemContractBaseGas)
+		}
+		if gas := PrecompiledContractsEbakus[types.PrecompliledDBContract].RequiredGas(input); gas != params.DBContractBaseGas {
+			t.Errorf("db contract gas mismatch for %x: have %d, want %d", input, gas, params.DBContractBaseGas)
+		}
+		contract := NewContract(AccountRef(common.HexToAddress("1337")), nil, new(big.Int), params.SystemContractBaseGas)
+		if _, err := RunPrecompiledContract(evm, system, input, contract); err != errInputMethodTooShort {
+			t.Errorf("system contract error mismatch for %x: have %v, want %v", input, err, errInputMethodTooShort)
+		}
+	}
+}
+
+func TestWhereValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  []byte
+	}{
+		{"alice", []byte("alice")},
+		{[]byte{1, 2}, []byte{1, 2}},
+		{common.Address{0xaa}, common.Address{0xaa}.Bytes()},
+		{uint64(42), []byte("42")},
+		{big.NewInt(-7), []byte("-7")},
+	}
+	for _, test := range tests {
+		if have := whereValue(test.value); !bytes.Equal(have, test.want) {
+			t.Errorf("where value mismatch for %v: have %x, want %x", test.value, have, test.want)
+		}
+	}
+	if names := splitFieldNames(" Name, ,Age "); !reflect.DeepEqual(names, []string{"Name", "Age"}) {
+		t.Errorf("field names mismatch: have %v", names)
+	}
+}
+
+func TestPrecompileCallPolicy(t *testing.T) {
+	ebakusDb, _ := ebkdb.OpenInMemory(nil)
+	ebakusSnapshot := ebkdb.NewState(ebakusDb.GetRootSnapshot())
+	defer ebakusSnapshot.Release()
+
+	evm := NewEVM(Context{BlockNumber: new(big.Int)}, nil, ebakusSnapshot, params.TestChainConfig, Config{})
+
+	systemABI, _ := abi.JSON(strings.NewReader(SystemContractABI))
+	dbABI, _ := abi.JSON(strings.NewReader(DBABI))
+
+	stake, _ := systemABI.Pack(SystemContractStakeCmd, uint64(1))
+	getStaked, _ := systemABI.Pack(SystemContractGetStakedCmd)
+	insertObj, _ := dbABI.Pack(DBContractInsertObjCmd, "Users", []byte{1})
+	get, _ := dbABI.Pack(DBContractGetCmd, "Users", "", "")
+
+	var (
+		sender  = common.HexToAddress("0x1337")
+		caller  = common.HexToAddress("0xc0ffee")
+		library = common.HexToAddress("0x11b")
+	)
+	// newCall creates the frame of a contract calling a precompile, optionally
+	// through library code invoked by the caller with a delegate call.
+	newCall := func(precompile common.Address, viaLibrary bool) *Contract {
+		frame := NewContract(AccountRef(sender), AccountRef(caller), new(big.Int), 100000)
+		if viaLibrary {
+			frame = NewContract(frame, AccountRef(caller), nil, 100000).AsDelegate()
+			frame.SetCallCode(&library, common.Hash{}, nil)
+		}
+		contract := NewContract(frame, AccountRef(precompile), new(big.Int), 100000)
+		contract.SetCallCode(&precompile, common.Hash{}, nil)
+		return contract
+	}
+	newDelegateCall := func(precompile common.Address) *Contract {
+		frame := NewContract(AccountRef(sender), AccountRef(caller), new(big.Int), 100000)
+		contract := NewContract(frame, AccountRef(caller), nil, 100000).AsDelegate()
+		contract.SetCallCode(&precompile, common.Hash{}, nil)
+		return contract
+	}
+	tests := []struct {
+		name     string
+		contract *Contract
+		input    []byte
+		readOnly bool
+		err      error
+	}{
+		{"system call", newCall(types.PrecompliledSystemContract, false), stake, false, nil},
+		{"db call", newCall(types.PrecompliledDBContract, false), insertObj, false, nil},
+		{"system call from library", newCall(types.PrecompliledSystemContract, true), stake, false, nil},
+		{"db call from library", newCall(types.PrecompliledDBContract, true), insertObj, false, nil},
+		{"system delegate call", newDelegateCall(types.PrecompliledSystemContract), getStaked, false, errPrecompileDelegateCall},
+		{"db delegate call", newDelegateCall(types.PrecompliledDBContract), get, false, errPrecompileDelegateCall},
+		{"system static read", newCall(types.PrecompliledSystemContract, false), getStaked, true, nil},
+		{"db static read", newCall(types.PrecompliledDBContract, false), get, true, nil},
+		{"system static write", newCall(types.PrecompliledSystemContract, false), stake, true, errWriteProtection},
+		{"db static write", newCall(types.PrecompliledDBContract, false), insertObj, true, errWriteProtection},
+		{"stateless delegate call", newDelegateCall(common.BytesToAddress([]byte{4})), []byte{1}, false, nil},
+	}
+	for _, test := range tests {
+		p := PrecompiledContractsEbakus[*test.contract.CodeAddr]
+		if err := checkPrecompileCall(p, test.contract, test.input, test.readOnly); err != test.err {
+			t.Errorf("%s: error mismatch: have %v, want %v", test.name, err, test.err)
+		}
+		if !test.contract.delegate && test.contract.Caller() != caller {
+			t.Errorf("%s: namespace mismatch: have %x, want %x", test.name, test.contract.Caller(), caller)
+		}
+	}
+	// Rejected calls must fail before running the precompile
+	if _, err := run(evm, newDelegateCall(types.PrecompliledSystemContract), stake, false); err != errPrecompileDelegateCall {
+		t.Errorf("delegate call error mismatch: have %v, want %v", err, errPrecompileDelegateCall)
+	}
+	if _, err := run(evm, newCall(types.PrecompliledDBContract, false), insertObj, true); err != errWriteProtection {
+		t.Errorf("static call error mismatch: have %v, want %v", err, errWriteProtection)
+	}
+}
+
+func TestDBContractSavepoints(t *testing.T) {
+	ebakusDb, _ := ebkdb.OpenInMemory(nil)
+	ebakusSnapshot := ebkdb.NewState(ebakusDb.GetRootSnapshot())
+	defer ebakusSnapshot.Release()
+
+	evm := NewEVM(Context{BlockNumber: new(big.Int)}, nil, ebakusSnapshot, params.TestChainConfig, Config{})
+	dbABI, _ := abi.JSON(strings.NewReader(DBABI))
+
+	var (
+		c      = new(dbContract)
+		owner  = common.HexToAddress("0xc0ffee")
+		frame  = NewContract(AccountRef(common.HexToAddress("0x1337")), AccountRef(owner), new(big.Int), 100000)
+		other  = NewContract(AccountRef(common.HexToAddress("0x1337")), AccountRef(owner), new(big.Int), 100000)
+		dbCall = func(caller ContractRef) *Contract {
+			return NewContract(caller, AccountRef(types.PrecompliledDBContract), new(big.Int), 100000)
+		}
+	)
+	take := func() uint64 {
+		res, err := c.savepoint(evm, &dbABI, dbCall(frame), DBContractSavepointCmd, 0)
+		if err != nil {
+			t.Fatalf("failed to take savepoint: %v", err)
+		}
+		return new(big.Int).SetBytes(res).Uint64()
+	}
+	first, second := take(), take()
+	if first != 1 || second != 2 {
+		t.Fatalf("savepoint ids mismatch: have %d, %d, want 1, 2", first, second)
+	}
+	// Savepoints are private to their frame and only contracts may take them
+	if _, err := c.savepoint(evm, &dbABI, dbCall(other), DBContractRollbackCmd, first); err != errSavepointNotFound {
+		t.Errorf("foreign frame rollback error mismatch: have %v, want %v", err, errSavepointNotFound)
+	}
+	if _, err := c.savepoint(evm, &dbABI, dbCall(AccountRef(owner)), DBContractSavepointCmd, 0); err != errSavepointNotContract {
+		t.Errorf("account savepoint error mismatch: have %v, want %v", err, errSavepointNotContract)
+	}
+	// Rolling back keeps the savepoint but drops the newer ones
+	if _, err := c.savepoint(evm, &dbABI, dbCall(frame), DBContractRollbackCmd, first); err != nil {
+		t.Fatalf("failed to roll back: %v", err)
+	}
+	if _, err := c.savepoint(evm, &dbABI, dbCall(frame), DBContractRollbackCmd, second); err != errSavepointNotFound {
+		t.Errorf("released savepoint rollback error mismatch: have %v, want %v", err, errSavepointNotFound)
+	}
+	if _, err := c.savepoint(evm, &dbABI, dbCall(frame), DBContractRollbackCmd, first); err != nil {
+		t.Errorf("failed to roll back twice: %v", err)
+	}
+	// Rolling back over other contract calls is refused
+	evm.ebakusWriters++
+	if _, err := c.savepoint(evm, &dbABI, dbCall(frame), DBContractRollbackCmd, first); err != errSavepointCrossed {
+		t.Errorf("crossed rollback error mismatch: have %v, want %v", err, errSavepointCrossed)
+	}
+	// Returning from the frame releases its savepoints
+	if _, err := c.savepoint(evm, &dbABI, dbCall(frame), DBContractReleaseSavepointCmd, first); err != nil {
+		t.Fatalf("failed to release savepoint: %v", err)
+	}
+	take()
+	evm.releaseEbakusSavepoints(frame, 0)
+	if len(evm.ebakusSavepoints) != 0 {
+		t.Errorf("savepoints left after the frame returned: %d", len(evm.ebakusSavepoints))
+	}
+}
+
+// releaseTracker is an iterator recording whether it was released.
+type releaseTracker struct{ released bool }
+
+func (it *releaseTracker) Next(val interface{}) bool { return false }
+func (it *releaseTracker) Prev(val interface{}) bool { return false }
+func (it *releaseTracker) Release()                  { it.released = true }
+
+func TestDBContractIteratorScope(t *testing.T) {
+	evm := NewEVM(Context{BlockNumber: new(big.Int)}, nil, nil, params.TestChainConfig, Config{})
+
+	var (
+		c      = new(dbContract)
+		frame  = NewContract(AccountRef(common.HexToAddress("0x1337")), AccountRef(common.HexToAddress("0xc0ffee")), new(big.Int), 100000)
+		child  = NewContract(frame, AccountRef(common.HexToAddress("0xdead")), new(big.Int), 100000)
+		dbCall = func(caller ContractRef) *Contract {
+			return NewContract(caller, AccountRef(types.PrecompliledDBContract), new(big.Int), 100000)
+		}
+		handle = func(h uint64) []byte {
+			input := make([]byte, 32)
+			binary.BigEndian.PutUint64(input, h)
+			return input
+		}
+	)
+	first, second, nested := new(releaseTracker), new(releaseTracker), new(releaseTracker)
+	h1 := evm.addEbakusStateIterator(frame, "t", first)
+	h2 := evm.addEbakusStateIterator(frame, "t", second)
+	h3 := evm.addEbakusStateIterator(child, "t", nested)
+	if h1 != 1 || h2 != 2 || h3 != 3 {
+		t.Fatalf("handles mismatch: have %d, %d, %d, want 1, 2, 3", h1, h2, h3)
+	}
+	// Iterators are private to the frame which selected them
+	if evm.getEbakusStateIterator(child, h1) != nil || evm.getEbakusStateIterator(frame, h3) != nil {
+		t.Errorf("iterator shared among frames")
+	}
+	if _, err := c.closeIterator(evm, dbCall(child), handle(h1)); err != errIteratorNotFound {
+		t.Errorf("foreign iterator close error mismatch: have %v, want %v", err, errIteratorNotFound)
+	}
+	if _, err := c.closeIterator(evm, dbCall(frame), handle(h1)); err != nil || !first.released {
+		t.Errorf("failed to close iterator: %v", err)
+	}
+	if _, err := c.closeIterator(evm, dbCall(frame), handle(h1)); err != errIteratorNotFound {
+		t.Errorf("closed iterator close error mismatch: have %v, want %v", err, errIteratorNotFound)
+	}
+	// Returning from a frame releases its iterators only, the message all of them
+	evm.releaseEbakusStateIterators(child)
+	if !nested.released || second.released {
+		t.Errorf("frame release mismatch: child %v, parent %v", nested.released, second.released)
+	}
+	evm.releaseEbakusStateIterators(nil)
+	if !second.released || len(evm.ebakusStateIterators) != 0 {
+		t.Errorf("iterators left after the message: %d", len(evm.ebakusStateIterators))
+	}
+}
+
+func TestSystemStakeBounds(t *testing.T) {
+	ebakusDb, _ := ebkdb.OpenInMemory(nil)
+	db := ebkdb.NewState(ebakusDb.GetRootSnapshot())
+	defer db.Release()
+
+	// Unstaking with no system stake at all is refused
+	if err := subSystemStake(db, 1); err != errSystemContractError {
+		t.Errorf("missing stake error mismatch: have %v, want %v", err, errSystemContractError)
+	}
+	if err := addSystemStake(db, math.MaxUint64-1); err != nil {
+		t.Fatalf("failed to add stake: %v", err)
+	}
+	if err := addSystemStake(db, 2); err != errStakeOverflow {
+		t.Errorf("overflow error mismatch: have %v, want %v", err, errStakeOverflow)
+	}
+	if have := GetSystemStake(db); have != math.MaxUint64-1 {
+		t.Errorf("system stake changed by overflowing add: have %d", have)
+	}
+	if err := subSystemStake(db, math.MaxUint64); err != errSystemStakeUnderflow {
+		t.Errorf("underflow error mismatch: have %v, want %v", err, errSystemStakeUnderflow)
+	}
+	if err := subSystemStake(db, math.MaxUint64-1); err != nil {
+		t.Fatalf("failed to subtract stake: %v", err)
+	}
+	if have := GetSystemStake(db); have != 0 {
+		t.Errorf("system stake mismatch: have %d, want 0", have)
+	}
+}
+
+func TestLockedTransferId(t *testing.T) {
+	to, from := common.Address{0x01, 0xff}, common.Address{0x02, 0xee}
+
+	id := GetLockedTransferId(to, from, 1234)
+	if haveTo, haveFrom, haveUnlock := id.Content(); haveTo != to || haveFrom != from || haveUnlock != 1234 {
+		t.Errorf("content mismatch: have %x, %x, %d", haveTo, haveFrom, haveUnlock)
+	}
+	// Ids of a recipient are prefixed by it and sorted by unlock time
+	if !bytes.HasPrefix(id[:], to[:]) {
+		t.Errorf("id %x not prefixed by the recipient", id)
+	}
+	later := GetLockedTransferId(to, common.Address{}, 1235)
+	if bytes.Compare(id[:], later[:]) >= 0 {
+		t.Errorf("ids not sorted by unlock time: %x >= %x", id, later)
+	}
+}
+
+func TestContractAbiVersionId(t *testing.T) {
+	address, other := common.Address{0x01, 0xff}, common.Address{0x02, 0xee}
+
+	// The first version keeps the unversioned id
+	if id := contractAbiVersionId(address, 0); !bytes.Equal(id, GetContractAbiId(address, "abi", "")) {
+		t.Errorf("first version id mismatch: have %x", id)
+	}
+	var prev ContractAbiId
+	for _, version := range []uint64{0, 1, 2, 255, 256, 1 << 40} {
+		id := contractAbiVersionId(address, version)
+		if have, ok := contractAbiVersion(address, id); !ok || have != version {
+			t.Errorf("version %d: have %d, %v", version, have, ok)
+		}
+		// Versions sort in order, keeping the latest last
+		if prev != nil && bytes.Compare(prev, id) >= 0 {
+			t.Errorf("version %d: ids not sorted: %x >= %x", version, prev, id)
+		}
+		prev = id
+	}
+	// Table ids of the contract aren't ABI versions
+	if _, ok := contractAbiVersion(address, GetContractAbiId(address, "table", "Orders")); ok {
+		t.Errorf("table id taken as an abi version")
+	}
+	if !bytes.HasPrefix(contractAbiVersionId(other, 1), other[:]) {
+		t.Errorf("version id not prefixed by the contract")
+	}
+}
+
+func TestPrecompileFrame(t *testing.T) {
+	systemABI, _ := abi.JSON(strings.NewReader(SystemContractABI))
+	address := common.HexToAddress("0x57a4e2")
+
+	input, err := systemABI.Pack(SystemContractGetAbiCmd, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evm := &EVM{depth: 1}
+	p := PrecompiledContractsEbakus[types.PrecompliledSystemContract]
+
+	frame := newPrecompileFrame(evm, p, types.PrecompliledSystemContract, input)
+	if frame == nil {
+		t.Fatal("no frame for the system contract")
+	}
+	if frame.Command != SystemContractGetAbiCmd {
+		t.Errorf("command mismatch: have %q, want %q", frame.Command, SystemContractGetAbiCmd)
+	}
+	if have, ok := frame.Args["address"].(common.Address); !ok || have != address {
+		t.Errorf("address argument mismatch: have %v", frame.Args["address"])
+	}
+	if frame.Depth != 2 {
+		t.Errorf("depth mismatch: have %d, want 2", frame.Depth)
+	}
+	if frame.Gas != p.RequiredGas(input) {
+		t.Errorf("gas mismatch: have %d, want %d", frame.Gas, p.RequiredGas(input))
+	}
+	// Unknown commands are still traced, just not decoded
+	if frame := newPrecompileFrame(evm, p, types.PrecompliledSystemContract, []byte{0xde, 0xad, 0xbe, 0xef}); frame == nil || frame.Command != "" || frame.Args != nil {
+		t.Errorf("unknown command decoded: %+v", frame)
+	}
+	// The stateless precompiles aren't traced
+	if frame := newPrecompileFrame(evm, &sha256hash{}, common.BytesToAddress([]byte{2}), input); frame != nil {
+		t.Errorf("frame for a stateless precompile: %+v", frame)
+	}
+}
+
+func TestSplitDBRange(t *testing.T) {
+	tests := []struct {
+		clause string
+		where  string
+		rng    dbRange
+	}{
+		{"", "", dbRange{}},
+		{"Id = 1", "Id = 1", dbRange{}},
+		{"Id > 1 LIMIT 10", "Id > 1", dbRange{Limit: 10, Limited: true}},
+		{"Id > 1 LIMIT 10 OFFSET 20", "Id > 1", dbRange{Limit: 10, Offset: 20, Limited: true}},
+		{"Id > 1 OFFSET 20", "Id > 1", dbRange{Offset: 20}},
+		{"LIMIT 5", "", dbRange{Limit: 5, Limited: true}},
+		{"Name = xLIMIT 5", "Name = xLIMIT 5", dbRange{}},
+		{"Name = LIMIT x", "Name = LIMIT x", dbRange{}},
+		{"Id > 1 LIMIT -1", "Id > 1 LIMIT -1", dbRange{}},
+	}
+	for i, test := range tests {
+		where, rng := splitDBRange(test.clause)
+		if where != test.where || rng != test.rng {
+			t.Errorf("test %d: have %q %+v, want %q %+v", i, where, rng, test.where, test.rng)
+		}
+	}
+}
+
+// Tests that the merkle proofs of the bridge intents verify against the root,
+// for both even and odd numbers of leaves.
+func TestBridgeMerkleProof(t *testing.T) {
+	for n := 1; n <= 7; n++ {
+		leaves := make([]common.Hash, n)
+		for i := range leaves {
+			leaves[i] = (&BridgeIntent{Id: GetBridgeIntentId(1, uint64(i)), Amount: uint64(i)}).Leaf()
+		}
+		root := BridgeMerkleRoot(leaves)
+		for i, leaf := range leaves {
+			proof := BridgeMerkleProof(leaves, i)
+			if !VerifyBridgeProof(root, leaf, uint64(i), proof) {
+				t.Errorf("leaves %d: proof of %d failed to verify", n, i)
+			}
+			if n > 1 && VerifyBridgeProof(root, leaf, uint64((i+1)%n), proof) {
+				t.Errorf("leaves %d: proof of %d verified at the wrong index", n, i)
+			}
+		}
+		if n > 1 && VerifyBridgeProof(root, common.Hash{}, 0, BridgeMerkleProof(leaves, 0)) {
+			t.Errorf("leaves %d: forged leaf verified", n)
+		}
+	}
+}
+
+// Tests that the staking, voting and table write events are logged only after
+// the precompile logs fork, and that they decode with the precompile ABIs.
+func TestPrecompileLogs(t *testing.T) {
+	systemABI, _ := abi.JSON(strings.NewReader(SystemContractABI))
+	dbABI, _ := abi.JSON(strings.NewReader(DBABI))
+
+	staker, witness := common.HexToAddress("0x57a4e2"), common.HexToAddress("0x1337")
+	emit := func(evm *EVM) {
+		var (
+			sc = new(systemContract)
+			dc = new(dbContract)
+		)
+		for _, err := range []error{
+			sc.addActivityLog(evm, &systemABI, "Stake", []common.Hash{staker.Hash()}, uint64(10), uint64(30)),
+			sc.addActivityLog(evm, &systemABI, "Unstake", []common.Hash{staker.Hash()}, uint64(10), uint64(1234)),
+			sc.addActivityLog(evm, &systemABI, "Claim", []common.Hash{staker.Hash()}, uint64(10)),
+			sc.addActivityLog(evm, &systemABI, "Vote", []common.Hash{staker.Hash()}, []common.Address{witness}, uint64(20)),
+			sc.addActivityLog(evm, &systemABI, "Unvote", []common.Hash{staker.Hash()}, []common.Address{witness}),
+			dc.addLog(evm, &dbABI, "TableCreated", []common.Hash{staker.Hash()}, "Users"),
+			dc.addLog(evm, &dbABI, "RowInserted", []common.Hash{staker.Hash()}, "Users", []byte{0x01}),
+			dc.addLog(evm, &dbABI, "RowDeleted", []common.Hash{staker.Hash()}, "Users", []byte{0x01}),
+		} {
+			if err != nil {
+				t.Fatalf("failed to log event: %v", err)
+			}
+		}
+	}
+	// Nothing is logged before the fork
+	config := *params.TestChainConfig
+	config.PrecompileLogsBlock = nil
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	emit(NewEVM(Context{BlockNumber: new(big.Int)}, statedb, nil, &config, Config{}))
+	if logs := statedb.Logs(); len(logs) != 0 {
+		t.Fatalf("logs emitted before the fork: %d", len(logs))
+	}
+	// All events are logged and decodable after it
+	statedb, _ = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	emit(NewEVM(Context{BlockNumber: new(big.Int)}, statedb, nil, params.TestChainConfig, Config{}))
+
+	logs := statedb.Logs()
+	if len(logs) != 8 {
+		t.Fatalf("logs mismatch: have %d, want 8", len(logs))
+	}
+	for i, log := range logs {
+		contractABI, address := &systemABI, types.PrecompliledSystemContract
+		if i >= 5 {
+			contractABI, address = &dbABI, types.PrecompliledDBContract
+		}
+		if log.Address != address {
+			t.Errorf("log %d: address mismatch: have %x, want %x", i, log.Address, address)
+		}
+		event, err := contractABI.EventByID(log.Topics[0])
+		if err != nil {
+			t.Fatalf("log %d: unknown event: %v", i, err)
+		}
+		if log.Topics[1] != staker.Hash() {
+			t.Errorf("log %d: indexed address mismatch: have %x", i, log.Topics[1])
+		}
+		if _, err := event.Inputs.NonIndexed().UnpackValues(log.Data); err != nil {
+			t.Errorf("log %d: failed to unpack %s: %v", i, event.Name, err)
+		}
+	}
+}
+
+func TestWrappedToken(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	vmctx := Context{
+		CanTransfer: func(db StateDB, addr common.Address, amount *big.Int) bool {
+			return db.GetBalance(addr).Cmp(amount) >= 0
+		},
+		Transfer: func(db StateDB, sender, recipient common.Address, amount *big.Int) {
+			db.SubBalance(sender, amount)
+			db.AddBalance(recipient, amount)
+		},
+		BlockNumber: new(big.Int),
+	}
+	evm := NewEVM(vmctx, statedb, nil, params.TestChainConfig, Config{})
+	tokenABI, _ := abi.JSON(strings.NewReader(WrappedTokenABI))
+
+	var (
+		c       = new(wrappedToken)
+		alice   = common.HexToAddress("0xa11ce")
+		bob     = common.HexToAddress("0xb0b")
+		carol   = common.HexToAddress("0xca201")
+		address = types.PrecompliledWrappedToken
+	)
+	statedb.AddBalance(alice, big.NewInt(1000))
+
+	// call runs the precompile like the EVM does, moving the value first
+	call := func(from common.Address, value int64, method string, args ...interface{}) error {
+		input, err := tokenABI.Pack(method, args...)
+		if err != nil {
+			t.Fatalf("failed to pack %s: %v", method, err)
+		}
+		contract := NewContract(AccountRef(from), AccountRef(address), big.NewInt(value), 1000000)
+		contract.SetCallCode(&address, common.Hash{}, nil)
+
+		snapshot := statedb.Snapshot()
+		vmctx.Transfer(statedb, from, address, big.NewInt(value))
+		if _, err = c.Run(evm, contract, input); err != nil {
+			statedb.RevertToSnapshot(snapshot)
+		}
+		return err
+	}
+	check := func(owner common.Address, want int64) {
+		t.Helper()
+		if have := GetWrappedBalance(statedb, owner); have.Cmp(big.NewInt(want)) != 0 {
+			t.Errorf("balance of %x mismatch: have %v, want %d", owner, have, want)
+		}
+	}
+	if err := call(alice, 600, WrappedTokenDepositCmd); err != nil {
+		t.Fatalf("failed to deposit: %v", err)
+	}
+	if err := call(alice, 0, WrappedTokenTransferCmd, bob, big.NewInt(200)); err != nil {
+		t.Fatalf("failed to transfer: %v", err)
+	}
+	if err := call(alice, 1, WrappedTokenTransferCmd, bob, big.NewInt(1)); err != errWrappedTokenNonPayable {
+		t.Errorf("payable transfer error mismatch: have %v, want %v", err, errWrappedTokenNonPayable)
+	}
+	// Spenders can't pull more than they were approved for
+	if err := call(bob, 0, WrappedTokenApproveCmd, carol, big.NewInt(50)); err != nil {
+		t.Fatalf("failed to approve: %v", err)
+	}
+	if err := call(carol, 0, WrappedTokenTransferFromCmd, bob, carol, big.NewInt(51)); err != errWrappedTokenAllowance {
+		t.Errorf("over allowance error mismatch: have %v, want %v", err, errWrappedTokenAllowance)
+	}
+	if err := call(carol, 0, WrappedTokenTransferFromCmd, bob, carol, big.NewInt(50)); err != nil {
+		t.Fatalf("failed to transfer from: %v", err)
+	}
+	if have := GetWrappedAllowance(statedb, bob, carol); have.Sign() != 0 {
+		t.Errorf("allowance left: %v", have)
+	}
+	check(alice, 400)
+	check(bob, 150)
+	check(carol, 50)
+
+	// Withdrawals pay back the EBK, the supply always backing the balances
+	if err := call(bob, 0, WrappedTokenWithdrawCmd, big.NewInt(151)); err != errWrappedTokenBalance {
+		t.Errorf("over balance withdrawal error mismatch: have %v, want %v", err, errWrappedTokenBalance)
+	}
+	if err := call(bob, 0, WrappedTokenWithdrawCmd, big.NewInt(150)); err != nil {
+		t.Fatalf("failed to withdraw: %v", err)
+	}
+	check(bob, 0)
+	if have := statedb.GetBalance(bob); have.Cmp(big.NewInt(150)) != 0 {
+		t.Errorf("withdrawn balance mismatch: have %v, want 150", have)
+	}
+	if have := statedb.GetBalance(address); have.Cmp(big.NewInt(450)) != 0 {
+		t.Errorf("supply mismatch: have %v, want 450", have)
+	}
+	// Calls in the context of another account are refused
+	contract := NewContract(AccountRef(alice), AccountRef(bob), new(big.Int), 1000000)
+	contract.SetCallCode(&address, common.Hash{}, nil)
+	if _, err := c.Run(evm, contract, nil); err != errWrappedTokenContext {
+		t.Errorf("call code error mismatch: have %v, want %v", err, errWrappedTokenContext)
+	}
+}
+
+// Tests that the db contract charges the gas proportional to the rows handled
+// only after the row size gas fork.
+func TestDBContractRowSizeGas(t *testing.T) {
+	var (
+		c      = new(dbContract)
+		caller = AccountRef(common.HexToAddress("0x1337"))
+		gas    = 1024 * params.DBContractWriteByteGas
+	)
+	config := *params.TestChainConfig
+	config.RowSizeGasBlock = nil
+
+	evm := NewEVM(Context{BlockNumber: new(big.Int)}, nil, nil, &config, Config{})
+	contract := NewContract(caller, AccountRef(types.PrecompliledDBContract), new(big.Int), 100000)
+	if err := c.useRowSizeGas(evm, contract, gas); err != nil || contract.Gas != 100000 {
+		t.Errorf("row size gas charged before the fork: gas left %d, err %v", contract.Gas, err)
+	}
+	evm = NewEVM(Context{BlockNumber: new(big.Int)}, nil, nil, params.TestChainConfig, Config{})
+	if err := c.useRowSizeGas(evm, contract, gas); err != nil || contract.Gas != 100000-gas {
+		t.Errorf("row size gas mismatch: gas left %d, want %d, err %v", contract.Gas, 100000-gas, err)
+	}
+	if err := c.useRowSizeGas(evm, contract, contract.Gas+1); err != ErrOutOfGas {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrOutOfGas)
+	}
+}
+
+// Tests that the system contract amounts are converted to and from wei in the
+// precision configured by the chain, capping the amounts not fitting in them.
+func TestValuePrecision(t *testing.T) {
+	tests := []struct {
+		decimals uint64
+		amount   uint64
+		wei      string
+	}{
+		{0, 1, "100000000000000"},     // unset, default precision
+		{4, 1, "100000000000000"},     // default precision
+		{8, 12345, "123450000000000"}, // finer precision
+		{18, 1, "1"},                  // wei precision
+		{18, math.MaxUint64, "18446744073709551615"},
+	}
+	for i, tt := range tests {
+		config := &params.ChainConfig{ValueDecimalPoints: tt.decimals}
+		evm := &EVM{valuePrecision: config.ValuePrecision()}
+
+		wei, _ := new(big.Int).SetString(tt.wei, 10)
+		if have := evm.toWei(tt.amount); have.Cmp(wei) != 0 {
+			t.Errorf("test %d: wei mismatch: have %v, want %v", i, have, wei)
+		}
+		if have := evm.fromWei(wei); have != tt.amount {
+			t.Errorf("test %d: amount mismatch: have %d, want %d", i, have, tt.amount)
+		}
+	}
+	// Balances beyond the amounts of the precision are capped, not truncated
+	evm := &EVM{valuePrecision: (&params.ChainConfig{ValueDecimalPoints: 18}).ValuePrecision()}
+	balance := new(big.Int).Mul(big.NewInt(1e9), big.NewInt(1e18))
+	if have := evm.fromWei(balance); have != math.MaxUint64 {
+		t.Errorf("overflowing balance mismatch: have %d, want %d", have, uint64(math.MaxUint64))
+	}
+	// The largest amount of the coarsest precision is converted exactly
+	evm = &EVM{valuePrecision: (&params.ChainConfig{ValueDecimalPoints: 1}).ValuePrecision()}
+	want := new(big.Int).Mul(new(big.Int).SetUint64(math.MaxUint64), big.NewInt(1e17))
+	if have := evm.toWei(math.MaxUint64); have.Cmp(want) != 0 {
+		t.Errorf("largest amount mismatch: have %v, want %v", have, want)
+	}
+	if have := evm.fromWei(want); have != math.MaxUint64 {
+		t.Errorf("largest amount mismatch: have %d, want %d", have, uint64(math.MaxUint64))
+	}
+}
+
+// Tests that the ids and hashes persisted by the system contract have the same
+// encoding on every architecture, so that little and big endian producers agree
+// on the state roots.
+func TestSystemContractEncoding(t *testing.T) {
+	var (
+		from  = common.HexToAddress("0x0102030405060708090a0b0c0d0e0f1011121314")
+		to    = common.HexToAddress("0x2122232425262728292a2b2c2d2e2f3031323334")
+		stamp = uint64(0x0102030405060708)
+	)
+	claimable := GetClaimableId(from, stamp)
+	if have, want := common.Bytes2Hex(claimable[:]), "0102030405060708090a0b0c0d0e0f10111213140807060504030201"; have != want {
+		t.Errorf("claimable id mismatch: have %s, want %s", have, want)
+	}
+	locked := GetLockedTransferId(to, from, stamp)
+	if have, want := common.Bytes2Hex(locked[:]), "2122232425262728292a2b2c2d2e2f303132333401020304050607080102030405060708090a0b0c0d0e0f1011121314"; have != want {
+		t.Errorf("locked transfer id mismatch: have %s, want %s", have, want)
+	}
+	intent := BridgeIntent{
+		Id:        GetBridgeIntentId(stamp, 3),
+		Sender:    from,
+		ChainId:   1,
+		Recipient: to.Hash(),
+		Amount:    10000,
+	}
+	if have, want := common.Bytes2Hex(intent.Id[:]), "01020304050607080000000000000003"; have != want {
+		t.Errorf("bridge intent id mismatch: have %s, want %s", have, want)
+	}
+	if have, want := intent.Leaf(), common.HexToHash("0x202912f8cfd7775987062ca93c6a823401d4d15def6a740b713bc2666874e785"); have != want {
+		t.Errorf("bridge intent leaf mismatch: have %x, want %x", have, want)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/params"
+)
+
+var errDBQueryNotIndexed = errors.New("db query compares a non indexed field")
+
+// dbRange is the LIMIT and OFFSET terms a where clause may end in.
+type dbRange struct {
+	Limit   uint64
+	Offset  uint64
+	Limited bool // Whether there's a LIMIT term
+}
+
+// cutRangeTerm cuts a trailing "<keyword> <number>" term off a where clause,
+// reporting whether there was one.
+func cutRangeTerm(whereClause string, keyword string) (string, uint64, bool) {
+	i := strings.LastIndex(whereClause, keyword+" ")
+	if i < 0 || (i > 0 && whereClause[i-1] != ' ') {
+		return whereClause, 0, false
+	}
+	n, err := strconv.ParseUint(whereClause[i+len(keyword)+1:], 10, 64)
+	if err != nil {
+		return whereClause, 0, false
+	}
+	return strings.TrimRight(whereClause[:i], " "), n, true
+}
+
+// splitDBRange splits the trailing "LIMIT n OFFSET m" terms off a where clause.
+// Both terms are optional, so a where clause without them is returned as is.
+func splitDBRange(whereClause string) (string, dbRange) {
+	var rng dbRange
+
+	whereClause, rng.Offset, _ = cutRangeTerm(whereClause, "OFFSET")
+	whereClause, rng.Limit, rng.Limited = cutRangeTerm(whereClause, "LIMIT")
+
+	return whereClause, rng
+}
+
+// isComparisonClause reports whether a where clause compares a field with one
+// of the <, >, <= and >= operators.
+func isComparisonClause(whereClause string) bool {
+	terms := strings.SplitN(whereClause, " ", 3)
+	if len(terms) < 3 {
+		return false
+	}
+	switch terms[1] {
+	case "<", ">", "<=", ">=":
+		return true
+	}
+	return false
+}
+
+// rangeIterator stops iterating after the limit of a range.
+type rangeIterator struct {
+	ebkdb.Iterator
+	limit     uint64
+	remaining uint64
+}
+
+func (it *rangeIterator) Next(val interface{}) bool {
+	if it.remaining == 0 {
+		return false
+	}
+	if !it.Iterator.Next(val) {
+		return false
+	}
+	it.remaining--
+	return true
+}
+
+func (it *rangeIterator) Prev(val interface{}) bool {
+	if !it.Iterator.Prev(val) {
+		return false
+	}
+	if it.remaining < it.limit {
+		it.remaining++
+	}
+	return true
+}
+
+// EbakusDBSelectRange is like EbakusDBSelect, but the where clause may end in
+// "LIMIT n OFFSET m" terms and compare indexed fields with the <, >, <= and >=
+// operators. The rows before the offset are skipped, calling scan before each
+// one if set, so they can be paid for.
+func EbakusDBSelectRange(db ebkdb.State, contractAddress common.Address, tableName string, whereClause string, orderClause string, scan func() error) (ebkdb.Iterator, error) {
+	whereClause, rng := splitDBRange(whereClause)
+
+	// Comparisons are only allowed on indexed fields, so they never turn into
+	// a full table scan
+	if isComparisonClause(whereClause) {
+		plan, err := EbakusDBExplain(db, contractAddress, tableName, whereClause, orderClause)
+		if err != nil {
+			return nil, err
+		}
+		if plan.Scan == ebkdb.ScanFull {
+			return nil, errDBQueryNotIndexed
+		}
+	}
+	iter, err := EbakusDBSelect(db, contractAddress, tableName, whereClause, orderClause)
+	if err != nil {
+		return nil, err
+	}
+	if rng.Offset > 0 {
+		tableABI, err := GetAbiForTable(db, contractAddress, tableName)
+		if err != nil {
+			iter.Release()
+			return nil, err
+		}
+		obj, err := tableABI.GetTableInstance(tableName)
+		if err != nil {
+			iter.Release()
+			return nil, err
+		}
+		for i := uint64(0); i < rng.Offset; i++ {
+			if scan != nil {
+				if err := scan(); err != nil {
+					iter.Release()
+					return nil, err
+				}
+			}
+			if !iter.Next(obj) {
+				break
+			}
+		}
+	}
+	if rng.Limited {
+		iter = &rangeIterator{Iterator: iter, limit: rng.Limit, remaining: rng.Limit}
+	}
+	return iter, nil
+}
+
+// scanRow returns a function paying for a row skipped by a select offset.
+func (c *dbContract) scanRow(evm *EVM, contract *Contract) func() error {
+	return func() error {
+		if err := evm.useEbakusDBRows(1); err != nil {
+			return err
+		}
+		if !contract.UseGas(params.DBContractScanRowGas) {
+			return ErrOutOfGas
+		}
+		return nil
+	}
+}
+
+// getRange is like EbakusDBGet, but the where clause is handled as by
+// EbakusDBSelectRange.
+func (c *dbContract) getRange(evm *EVM, contract *Contract, contractAddress common.Address, selectObj selectDef) (interface{}, error) {
+	db := evm.EbakusState
+
+	iter, err := EbakusDBSelectRange(db, contractAddress, selectObj.TableName, selectObj.WhereClause, selectObj.OrderClause, c.scanRow(evm, contract))
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Release()
+
+	tableABI, err := GetAbiForTable(db, contractAddress, selectObj.TableName)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := tableABI.GetTableInstance(selectObj.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if !iter.Next(obj) {
+		return nil, errNoEntryFound
+	}
+	return obj, nil
+}
@@ -17,13 +17,14 @@
 package vm
 
 import (
+	"math"
 	"math/big"
 	"math/rand"
 	"sync/atomic"
 	"time"
 
-	"github.com/ebakus/ebakusdb"
 	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/crypto"
 	"github.com/ebakus/go-ebakus/params"
 )
@@ -44,12 +45,33 @@ type (
 
 // run runs the given contract and takes care of running precompiles with a fallback to the byte code interpreter.
 func run(evm *EVM, contract *Contract, input []byte, readOnly bool) ([]byte, error) {
+	// Iterators never outlive the message which selected them
+	if evm.depth == 0 {
+		defer evm.releaseEbakusStateIterators(nil)
+	}
 	if contract.CodeAddr != nil {
 		precompiles := PrecompiledContractsEbakus
+		if evm.chainRules.IsWrappedToken {
+			precompiles = PrecompiledContractsWrappedToken
+		}
 		if p := precompiles[*contract.CodeAddr]; p != nil {
+			if evm.chainRules.IsCallPolicy {
+				if err := checkPrecompileCall(p, contract, input, readOnly || evm.inStaticCall()); err != nil {
+					return nil, err
+				}
+			}
 			return RunPrecompiledContract(evm, p, input, contract)
 		}
 	}
+	// Savepoints are scoped to the call frame which created them
+	defer evm.releaseEbakusSavepoints(contract, 0)
+	evm.ebakusWriters++
+
+	// So are iterators, once they can't be shared among frames
+	if evm.chainRules.IsIteratorScope {
+		defer evm.releaseEbakusStateIterators(contract)
+	}
+
 	for _, interpreter := range evm.interpreters {
 		if interpreter.CanRun(contract.Code) {
 			if evm.interpreter != interpreter {
@@ -104,8 +126,22 @@ type EVM struct {
 	// StateDB gives access to the underlying state
 	StateDB StateDB
 	// EbakusDB is the ebakus db status
-	EbakusState          *ebakusdb.Snapshot
+	EbakusState          ebkdb.State
 	ebakusStateIterators map[uint64]*ebakusStateIterator
+	ebakusIteratorIds    uint64
+	// ebakusSavepoints are the db contract savepoints of the live call frames
+	ebakusSavepoints   map[uint64]*ebakusSavepoint
+	ebakusSavepointIds uint64
+	// ebakusWriters counts the frames able to write foreign ebakus state, i.e.
+	// contract code and system contract calls
+	ebakusWriters uint64
+	// ebakusDBRowsLimit caps the rows read from the ebakus db (0 = unlimited)
+	ebakusDBRowsLimit uint64
+	ebakusDBRows      uint64
+	// tracedRows counts the ebakus db rows accessed by the traced precompile calls
+	tracedRows tracedRows
+	// lowPriority makes the ebakus db access yield to block import and production
+	lowPriority bool
 	// Depth is the current call stack
 	depth int
 
@@ -113,6 +149,8 @@ type EVM struct {
 	chainConfig *params.ChainConfig
 	// chain rules contains the chain rules for the current epoch
 	chainRules params.Rules
+	// valuePrecision is the wei in the smallest unit of the system contract amounts
+	valuePrecision *big.Int
 	// virtual machine configuration options used to initialise the
 	// evm.
 	vmConfig Config
@@ -131,38 +169,30 @@ type EVM struct {
 
 // NewEVM returns a new EVM. The returned EVM is not thread safe and should
 // only ever be used *once*.
-func NewEVM(ctx Context, statedb StateDB, ebakusState *ebakusdb.Snapshot, chainConfig *params.ChainConfig, vmConfig Config) *EVM {
+func NewEVM(ctx Context, statedb StateDB, ebakusState ebkdb.State, chainConfig *params.ChainConfig, vmConfig Config) *EVM {
 	evm := &EVM{
 		Context:              ctx,
 		StateDB:              statedb,
 		EbakusState:          ebakusState,
 		ebakusStateIterators: make(map[uint64]*ebakusStateIterator, 0),
+		ebakusSavepoints:     make(map[uint64]*ebakusSavepoint),
 		vmConfig:             vmConfig,
 		chainConfig:          chainConfig,
 		chainRules:           chainConfig.Rules(ctx.BlockNumber),
+		valuePrecision:       chainConfig.ValuePrecision(),
 		interpreters:         make([]Interpreter, 0, 1),
 	}
 
 	if chainConfig.IsEWASM(ctx.BlockNumber) {
-		// to be implemented by EVM-C and Wagon PRs.
-		// if vmConfig.EWASMInterpreter != "" {
-		//  extIntOpts := strings.Split(vmConfig.EWASMInterpreter, ":")
-		//  path := extIntOpts[0]
-		//  options := []string{}
-		//  if len(extIntOpts) > 1 {
-		//    options = extIntOpts[1..]
-		//  }
-		//  evm.interpreters = append(evm.interpreters, NewEVMVCInterpreter(evm, vmConfig, options))
-		// } else {
-		// 	evm.interpreters = append(evm.interpreters, NewEWASMInterpreter(evm, vmConfig))
-		// }
-		panic("No supported ewasm interpreter yet.")
+		// The ewasm interpreter only claims code carrying the wasm preamble,
+		// everything else falls through to the built-in EVM.
+		evm.interpreters = append(evm.interpreters, NewEWASMInterpreter(evm, vmConfig))
 	}
 
 	// vmConfig.EVMInterpreter will be used by EVM-C, it won't be checked here
 	// as we always want to have the built-in EVM as the failover option.
 	evm.interpreters = append(evm.interpreters, NewEVMInterpreter(evm, vmConfig))
-	evm.interpreter = evm.interpreters[0]
+	evm.interpreter = evm.interpreters[len(evm.interpreters)-1]
 
 	return evm
 }
@@ -211,6 +241,9 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 
 	if !evm.StateDB.Exist(addr) {
 		precompiles := PrecompiledContractsEbakus
+		if evm.chainRules.IsWrappedToken {
+			precompiles = PrecompiledContractsWrappedToken
+		}
 		if precompiles[addr] == nil && value.Sign() == 0 {
 			// Calling a non existing account, don't do anything, but ping the tracer
 			if evm.vmConfig.Debug && evm.depth == 0 {
@@ -423,6 +456,24 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 	}
 	evm.Transfer(evm.StateDB, caller.Address(), address, value)
 
+	// Record the creator, which may publish new versions of the contract's ABI
+	if evm.chainRules.IsAbiVersioning {
+		if err := recordContractCreator(evm.EbakusState, address, caller.Address()); err != nil {
+			evm.StateDB.RevertToSnapshot(snapshot)
+			evm.EbakusState.ResetTo(ebakusSnapshot)
+			return nil, address, gas, err
+		}
+	}
+	// Code deployed again at the address of a self-destructed contract takes
+	// its tables back
+	if evm.chainRules.IsTableTombstone {
+		if err := untombstoneContractTables(evm.EbakusState, address); err != nil {
+			evm.StateDB.RevertToSnapshot(snapshot)
+			evm.EbakusState.ResetTo(ebakusSnapshot)
+			return nil, address, gas, err
+		}
+	}
+
 	// Initialise a new contract and set the code that is to be used by the EVM.
 	// The contract is a scoped environment for this execution context only.
 	contract := NewContract(caller, AccountRef(address), value, gas)
@@ -439,6 +490,12 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 
 	ret, err := run(evm, contract, nil, false)
 
+	// wasm init code must deploy a wasm module, otherwise the contract would
+	// end up being interpreted as EVM bytecode
+	if err == nil && evm.chainRules.IsEWASM && IsEWASMCode(codeAndHash.code) && !IsEWASMCode(ret) {
+		err = errEWASMInvalidCode
+	}
+
 	// check whether the max code size has been exceeded
 	maxCodeSizeExceeded := evm.chainRules.IsEIP158 && len(ret) > params.MaxCodeSize
 	// if the contract creation ran successfully and no errors were returned
@@ -494,23 +551,49 @@ func (evm *EVM) Create2(caller ContractRef, code []byte, gas uint64, endowment *
 // ChainConfig returns the environment's chain configuration
 func (evm *EVM) ChainConfig() *params.ChainConfig { return evm.chainConfig }
 
+// toWei converts an amount of the system contracts to wei.
+func (evm *EVM) toWei(amount uint64) *big.Int {
+	return new(big.Int).Mul(new(big.Int).SetUint64(amount), evm.valuePrecision)
+}
+
+// fromWei converts wei to an amount of the system contracts, rounding down.
+// Amounts not fitting in an uint64, possible with a fine enough precision, are
+// capped to the largest one.
+func (evm *EVM) fromWei(wei *big.Int) uint64 {
+	amount := new(big.Int).Div(wei, evm.valuePrecision)
+	if !amount.IsUint64() {
+		return math.MaxUint64
+	}
+	return amount.Uint64()
+}
+
 type ebakusStateIterator struct {
 	TableName string
-	Iter      *ebakusdb.ResultIterator
+	Iter      ebkdb.Iterator
+	frame     ContractRef // Call frame which selected the iterator
 }
 
-func (evm *EVM) addEbakusStateIterator(tableName string, iter *ebakusdb.ResultIterator) uint64 {
+// addEbakusStateIterator registers an iterator selected by the call frame,
+// returning its handle.
+func (evm *EVM) addEbakusStateIterator(frame ContractRef, tableName string, iter ebkdb.Iterator) uint64 {
 	var handle uint64
-	for {
-		handle = rand.Uint64()
-		if _, ok := evm.ebakusStateIterators[handle]; !ok {
-			break
+	if evm.chainRules.IsIteratorScope {
+		// The handles are returned to the contracts, so must be deterministic
+		evm.ebakusIteratorIds++
+		handle = evm.ebakusIteratorIds
+	} else {
+		for {
+			handle = rand.Uint64()
+			if _, ok := evm.ebakusStateIterators[handle]; !ok {
+				break
+			}
 		}
 	}
 
 	tableIter := ebakusStateIterator{
 		TableName: tableName,
 		Iter:      iter,
+		frame:     frame,
 	}
 
 	evm.ebakusStateIterators[handle] = &tableIter
@@ -518,6 +601,118 @@ func (evm *EVM) addEbakusStateIterator(tableName string, iter *ebakusdb.ResultIt
 	return handle
 }
 
-func (evm *EVM) getEbakusStateIterator(handle uint64) *ebakusStateIterator {
-	return evm.ebakusStateIterators[handle]
+// ebakusSavepoint is a snapshot of the ebakus state taken by a call frame
+// through the db contract, which the frame may roll its table writes back to.
+type ebakusSavepoint struct {
+	frame    ContractRef // Call frame which created the savepoint
+	writers  uint64      // Foreign writers run when the savepoint was taken
+	snapshot ebkdb.State
+}
+
+// addEbakusSavepoint takes a savepoint of the ebakus state for the call frame,
+// returning its id.
+func (evm *EVM) addEbakusSavepoint(frame ContractRef) uint64 {
+	evm.ebakusSavepointIds++
+	evm.ebakusSavepoints[evm.ebakusSavepointIds] = &ebakusSavepoint{
+		frame:    frame,
+		writers:  evm.ebakusWriters,
+		snapshot: evm.EbakusState.Snapshot(),
+	}
+	return evm.ebakusSavepointIds
+}
+
+// getEbakusSavepoint returns a savepoint of the call frame, or nil if it doesn't
+// exist or belongs to another frame.
+func (evm *EVM) getEbakusSavepoint(frame ContractRef, id uint64) *ebakusSavepoint {
+	if savepoint := evm.ebakusSavepoints[id]; savepoint != nil && savepoint.frame == frame {
+		return savepoint
+	}
+	return nil
+}
+
+// releaseEbakusSavepoints releases the savepoints of the call frame taken after
+// the given one, or all of them when after is 0.
+func (evm *EVM) releaseEbakusSavepoints(frame ContractRef, after uint64) {
+	for id, savepoint := range evm.ebakusSavepoints {
+		if id > after && savepoint.frame == frame {
+			savepoint.snapshot.Release()
+			delete(evm.ebakusSavepoints, id)
+		}
+	}
+}
+
+// inStaticCall reports whether the execution is within a static call, in which
+// case state modifications are forbidden.
+func (evm *EVM) inStaticCall() bool {
+	for _, interpreter := range evm.interpreters {
+		switch in := interpreter.(type) {
+		case *EVMInterpreter:
+			if in.readOnly {
+				return true
+			}
+		case *EWASMInterpreter:
+			if in.readOnly {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getEbakusStateIterator returns an iterator of the call frame, or nil if it
+// doesn't exist or, once iterators are scoped to call frames, belongs to another
+// frame.
+func (evm *EVM) getEbakusStateIterator(frame ContractRef, handle uint64) *ebakusStateIterator {
+	tableIter := evm.ebakusStateIterators[handle]
+	if tableIter != nil && evm.chainRules.IsIteratorScope && tableIter.frame != frame {
+		return nil
+	}
+	return tableIter
+}
+
+// releaseEbakusStateIterator releases an iterator.
+func (evm *EVM) releaseEbakusStateIterator(handle uint64) {
+	if tableIter := evm.ebakusStateIterators[handle]; tableIter != nil {
+		tableIter.Iter.Release()
+		delete(evm.ebakusStateIterators, handle)
+	}
+}
+
+// releaseEbakusStateIterators releases the iterators selected by the call frame,
+// or all of them when frame is nil.
+func (evm *EVM) releaseEbakusStateIterators(frame ContractRef) {
+	for handle, tableIter := range evm.ebakusStateIterators {
+		if frame == nil || tableIter.frame == frame {
+			tableIter.Iter.Release()
+			delete(evm.ebakusStateIterators, handle)
+		}
+	}
+}
+
+// SetLowPriority marks the execution as not critical for block import or
+// production (e.g. RPC calls), so its ebakus db access yields to those that are.
+func (evm *EVM) SetLowPriority(low bool) {
+	evm.lowPriority = low
+}
+
+// SetEbakusDBRowsLimit limits the number of rows the execution may read from the
+// ebakus db. Exceeding it aborts the execution, like Cancel does.
+func (evm *EVM) SetEbakusDBRowsLimit(limit uint64) {
+	evm.ebakusDBRowsLimit = limit
+}
+
+// EbakusDBRowsLimitExceeded returns whether the execution got aborted for reading
+// more ebakus db rows than allowed.
+func (evm *EVM) EbakusDBRowsLimitExceeded() bool {
+	return evm.ebakusDBRowsLimit > 0 && evm.ebakusDBRows > evm.ebakusDBRowsLimit
+}
+
+// useEbakusDBRows accounts rows read from the ebakus db.
+func (evm *EVM) useEbakusDBRows(rows uint64) error {
+	evm.ebakusDBRows += rows
+	if evm.EbakusDBRowsLimitExceeded() {
+		evm.Cancel()
+		return ErrDBRowsLimitExceeded
+	}
+	return nil
 }
@@ -0,0 +1,322 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/log"
+	"github.com/ebakus/go-ebakus/params"
+)
+
+// ewasmMagic is the preamble of every WebAssembly binary module (magic number
+// followed by the version 1 marker).
+var ewasmMagic = []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+
+var (
+	errEWASMEngineMissing = errors.New("ewasm: no engine available")
+	errEWASMInvalidCode   = errors.New("ewasm: deployed code is not a valid wasm module")
+	errEWASMFinish        = errors.New("ewasm: finish")
+	errEWASMRevert        = errors.New("ewasm: revert")
+	errEWASMOutOfBounds   = errors.New("ewasm: memory access out of bounds")
+)
+
+// IsEWASMCode returns whether the given code carries the WebAssembly preamble.
+func IsEWASMCode(code []byte) bool {
+	return len(code) >= len(ewasmMagic) && bytes.Equal(code[:len(ewasmMagic)], ewasmMagic)
+}
+
+// WASMEngine is the interface a WebAssembly runtime needs to implement in order
+// to execute ewasm contracts. The engine is expected to inject gas metering into
+// the module (calling host.UseGas for every metered block) and to resolve the
+// "ethereum" imported namespace to the host functions exposed by EWASMHost.
+type WASMEngine interface {
+	// Execute instantiates the module and runs its "main" export. Returning
+	// errEWASMFinish or errEWASMRevert (via the host) is not a failure.
+	Execute(host *EWASMHost, code []byte) error
+}
+
+// WASMEngineFactory creates a new engine given the interpreter options
+// configured through vm.Config.EWASMInterpreter.
+type WASMEngineFactory func(options []string) (WASMEngine, error)
+
+var (
+	ewasmEngines   = make(map[string]WASMEngineFactory)
+	ewasmEnginesMu sync.RWMutex
+)
+
+// RegisterEWASMEngine makes a WebAssembly runtime available under the given
+// name. It is meant to be called from the init function of the engine package.
+func RegisterEWASMEngine(name string, factory WASMEngineFactory) {
+	ewasmEnginesMu.Lock()
+	defer ewasmEnginesMu.Unlock()
+
+	ewasmEngines[name] = factory
+}
+
+// EWASMInterpreter runs WebAssembly contracts through a registered WASMEngine.
+type EWASMInterpreter struct {
+	evm *EVM
+	cfg Config
+
+	engine   WASMEngine
+	readOnly bool
+}
+
+// NewEWASMInterpreter returns a new ewasm interpreter. The engine is picked from
+// cfg.EWASMInterpreter which has the form "name[:option1:option2...]". When no
+// engine is found the interpreter still claims wasm code, so it never gets
+// executed as EVM bytecode, but every execution fails.
+func NewEWASMInterpreter(evm *EVM, cfg Config) *EWASMInterpreter {
+	in := &EWASMInterpreter{
+		evm: evm,
+		cfg: cfg,
+	}
+
+	opts := strings.Split(cfg.EWASMInterpreter, ":")
+
+	ewasmEnginesMu.RLock()
+	factory, ok := ewasmEngines[opts[0]]
+	ewasmEnginesMu.RUnlock()
+
+	if !ok {
+		log.Warn("No ewasm engine registered", "name", opts[0])
+		return in
+	}
+
+	engine, err := factory(opts[1:])
+	if err != nil {
+		log.Error("Failed to create ewasm engine", "name", opts[0], "err", err)
+		return in
+	}
+	in.engine = engine
+
+	return in
+}
+
+// CanRun tells if the contract, passed as an argument, can be
+// run by the current interpreter.
+func (in *EWASMInterpreter) CanRun(code []byte) bool {
+	return IsEWASMCode(code)
+}
+
+// Run executes the wasm module of the contract. Errors follow the EVM
+// interpreter semantics, errExecutionReverted keeping the gas left.
+func (in *EWASMInterpreter) Run(contract *Contract, input []byte, readOnly bool) ([]byte, error) {
+	if in.engine == nil {
+		return nil, errEWASMEngineMissing
+	}
+
+	in.evm.depth++
+	defer func() { in.evm.depth-- }()
+
+	if readOnly && !in.readOnly {
+		in.readOnly = true
+		defer func() { in.readOnly = false }()
+	}
+
+	contract.Input = input
+
+	host := &EWASMHost{
+		evm:      in.evm,
+		contract: contract,
+		readOnly: in.readOnly,
+	}
+
+	err := in.engine.Execute(host, contract.Code)
+	switch err {
+	case nil, errEWASMFinish:
+		return host.returnData, nil
+	case errEWASMRevert:
+		return host.returnData, errExecutionReverted
+	default:
+		return nil, err
+	}
+}
+
+// EWASMHost implements the Ethereum Environment Interface (EEI) used by ewasm
+// contracts, extended with a bridge to the ebakus DB precompile.
+type EWASMHost struct {
+	evm      *EVM
+	contract *Contract
+	readOnly bool
+
+	returnData []byte // Data returned by finish/revert or the last call
+}
+
+// UseGas charges the given amount of gas, as injected by the metering pass of the engine.
+func (h *EWASMHost) UseGas(amount uint64) error {
+	if !h.contract.UseGas(amount * params.EWASMGasMultiplier) {
+		return ErrOutOfGas
+	}
+	return nil
+}
+
+// GasLeft returns the gas available to the contract.
+func (h *EWASMHost) GasLeft() uint64 {
+	return h.contract.Gas
+}
+
+// Address returns the address of the executing contract.
+func (h *EWASMHost) Address() common.Address {
+	return h.contract.Address()
+}
+
+// Caller returns the caller of the executing contract.
+func (h *EWASMHost) Caller() common.Address {
+	return h.contract.Caller()
+}
+
+// CallValue returns the value sent along with the call.
+func (h *EWASMHost) CallValue() *big.Int {
+	return h.contract.Value()
+}
+
+// CallDataSize returns the size of the call input.
+func (h *EWASMHost) CallDataSize() uint64 {
+	return uint64(len(h.contract.Input))
+}
+
+// CallDataCopy returns length bytes of call input starting at offset.
+func (h *EWASMHost) CallDataCopy(offset, length uint64) ([]byte, error) {
+	if offset+length < offset || offset+length > uint64(len(h.contract.Input)) {
+		return nil, errEWASMOutOfBounds
+	}
+	return common.CopyBytes(h.contract.Input[offset : offset+length]), nil
+}
+
+// StorageLoad reads a storage slot of the executing contract.
+func (h *EWASMHost) StorageLoad(key common.Hash) common.Hash {
+	return h.evm.StateDB.GetState(h.contract.Address(), key)
+}
+
+// StorageStore writes a storage slot of the executing contract.
+func (h *EWASMHost) StorageStore(key, value common.Hash) error {
+	if h.readOnly {
+		return errWriteProtection
+	}
+	if err := h.UseGas(params.SstoreSetGas); err != nil {
+		return err
+	}
+	h.evm.StateDB.SetState(h.contract.Address(), key, value)
+	return nil
+}
+
+// BlockNumber returns the number of the block being processed.
+func (h *EWASMHost) BlockNumber() uint64 {
+	return h.evm.BlockNumber.Uint64()
+}
+
+// BlockTimestamp returns the timestamp of the block being processed.
+func (h *EWASMHost) BlockTimestamp() uint64 {
+	return h.evm.Time.Uint64()
+}
+
+// Log emits a log entry from the executing contract.
+func (h *EWASMHost) Log(topics []common.Hash, data []byte) error {
+	if h.readOnly {
+		return errWriteProtection
+	}
+	if len(topics) > 4 {
+		return fmt.Errorf("ewasm: too many log topics (%d)", len(topics))
+	}
+	if err := h.UseGas(params.LogGas + uint64(len(topics))*params.LogTopicGas + uint64(len(data))*params.LogDataGas); err != nil {
+		return err
+	}
+	h.evm.StateDB.AddLog(&types.Log{
+		Address:     h.contract.Address(),
+		Topics:      topics,
+		Data:        data,
+		BlockNumber: h.evm.BlockNumber.Uint64(),
+	})
+	return nil
+}
+
+// Call performs a message call to another contract, forwarding at most gas.
+// The returned status is 0 on success, 1 on failure and 2 on revert, as
+// defined by the EEI.
+func (h *EWASMHost) Call(gas uint64, addr common.Address, value *big.Int, input []byte) (uint32, error) {
+	if h.readOnly && value.Sign() != 0 {
+		return 1, errWriteProtection
+	}
+	if gas > h.contract.Gas {
+		gas = h.contract.Gas
+	}
+	h.contract.Gas -= gas
+
+	var (
+		ret      []byte
+		err      error
+		leftOver uint64
+	)
+	if h.readOnly {
+		ret, leftOver, err = h.evm.StaticCall(h.contract, addr, input, gas)
+	} else {
+		ret, leftOver, err = h.evm.Call(h.contract, addr, input, gas, value)
+	}
+	h.contract.Gas += leftOver
+	h.returnData = ret
+
+	switch err {
+	case nil:
+		return 0, nil
+	case errExecutionReverted:
+		return 2, nil
+	default:
+		return 1, nil
+	}
+}
+
+// DBCall bridges to the ebakus DB precompile, so wasm contracts get the same
+// table namespace (their own address) as solidity contracts calling it.
+func (h *EWASMHost) DBCall(gas uint64, input []byte) (uint32, error) {
+	return h.Call(gas, types.PrecompliledDBContract, new(big.Int), input)
+}
+
+// ReturnDataSize returns the size of the data returned by the last call.
+func (h *EWASMHost) ReturnDataSize() uint64 {
+	return uint64(len(h.returnData))
+}
+
+// ReturnDataCopy returns length bytes of the last call's return data starting at offset.
+func (h *EWASMHost) ReturnDataCopy(offset, length uint64) ([]byte, error) {
+	if offset+length < offset || offset+length > uint64(len(h.returnData)) {
+		return nil, errEWASMOutOfBounds
+	}
+	return common.CopyBytes(h.returnData[offset : offset+length]), nil
+}
+
+// Finish stops execution successfully returning data. Engines should abort
+// execution with the returned error.
+func (h *EWASMHost) Finish(data []byte) error {
+	h.returnData = common.CopyBytes(data)
+	return errEWASMFinish
+}
+
+// Revert stops execution reverting state changes and returning data. Engines
+// should abort execution with the returned error.
+func (h *EWASMHost) Revert(data []byte) error {
+	h.returnData = common.CopyBytes(data)
+	return errEWASMRevert
+}
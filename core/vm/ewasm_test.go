@@ -0,0 +1,100 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/params"
+)
+
+// echoEngine is a fake wasm engine charging a fixed amount of gas and
+// finishing (or reverting) with the call input.
+type echoEngine struct {
+	revert bool
+}
+
+func (e *echoEngine) Execute(host *EWASMHost, code []byte) error {
+	if err := host.UseGas(10); err != nil {
+		return err
+	}
+	input, err := host.CallDataCopy(0, host.CallDataSize())
+	if err != nil {
+		return err
+	}
+	if e.revert {
+		return host.Revert(input)
+	}
+	return host.Finish(input)
+}
+
+func TestIsEWASMCode(t *testing.T) {
+	if !IsEWASMCode(append(common.CopyBytes(ewasmMagic), 0x01)) {
+		t.Errorf("wasm module not detected")
+	}
+	for _, code := range [][]byte{nil, ewasmMagic[:4], {0x60, 0x80, 0x60, 0x40, 0x52, 0x00, 0x00, 0x00}} {
+		if IsEWASMCode(code) {
+			t.Errorf("code %x wrongly detected as wasm", code)
+		}
+	}
+}
+
+func TestEWASMInterpreterRun(t *testing.T) {
+	RegisterEWASMEngine("echo", func(options []string) (WASMEngine, error) {
+		return &echoEngine{revert: len(options) > 0 && options[0] == "revert"}, nil
+	})
+
+	ebakusDb, _ := ebkdb.OpenInMemory(nil)
+	ebakusSnapshot := ebkdb.NewState(ebakusDb.GetRootSnapshot())
+	defer ebakusSnapshot.Release()
+
+	tests := []struct {
+		config string
+		err    error
+	}{
+		{"echo", nil},
+		{"echo:revert", errExecutionReverted},
+		{"missing", errEWASMEngineMissing},
+	}
+	for i, tt := range tests {
+		env := NewEVM(Context{}, &dummyStatedb{}, ebakusSnapshot, params.TestChainConfig, Config{EWASMInterpreter: tt.config})
+		in := NewEWASMInterpreter(env, env.vmConfig)
+
+		contract := NewContract(&dummyContractRef{}, &dummyContractRef{}, new(big.Int), 100)
+		contract.Code = ewasmMagic
+
+		input := []byte{0xca, 0xfe}
+		ret, err := in.Run(contract, input, false)
+		if err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+			continue
+		}
+		if err == errEWASMEngineMissing {
+			continue
+		}
+		if !bytes.Equal(ret, input) {
+			t.Errorf("test %d: return mismatch: have %x, want %x", i, ret, input)
+		}
+		if contract.Gas != 100-10*params.EWASMGasMultiplier {
+			t.Errorf("test %d: gas left mismatch: have %d, want %d", i, contract.Gas, 100-10*params.EWASMGasMultiplier)
+		}
+	}
+}
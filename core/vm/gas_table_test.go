@@ -23,10 +23,10 @@ import (
 
 	"github.com/ebakus/go-ebakus/common"
 	"github.com/ebakus/go-ebakus/common/hexutil"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/rawdb"
 	"github.com/ebakus/go-ebakus/core/state"
 	"github.com/ebakus/go-ebakus/params"
-	"github.com/ebakus/ebakusdb"
 )
 
 func TestMemoryGasCost(t *testing.T) {
@@ -92,8 +92,8 @@ func TestEIP2200(t *testing.T) {
 			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
 			Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
 		}
-		ebakusDb, _ := ebakusdb.OpenInMemory(nil)
-		ebakusSnapshot := ebakusDb.GetRootSnapshot()
+		ebakusDb, _ := ebkdb.OpenInMemory(nil)
+		ebakusSnapshot := ebkdb.NewState(ebakusDb.GetRootSnapshot())
 		defer ebakusSnapshot.Release()
 
 		vmenv := NewEVM(vmctx, statedb, ebakusSnapshot, params.AllEthashProtocolChanges, Config{ExtraEips: []int{2200}})
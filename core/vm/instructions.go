@@ -877,6 +877,12 @@ func opSuicide(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memo
 	interpreter.evm.StateDB.AddBalance(common.BigToAddress(stack.pop()), balance)
 
 	interpreter.evm.StateDB.Suicide(contract.Address())
+
+	if interpreter.evm.chainRules.IsTableTombstone {
+		if err := tombstoneContractTables(interpreter.evm.EbakusState, contract.Address(), interpreter.evm.BlockNumber.Uint64()); err != nil {
+			return nil, err
+		}
+	}
 	return nil, nil
 }
 
@@ -25,9 +25,9 @@ import (
 	"testing"
 
 	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/crypto"
 	"github.com/ebakus/go-ebakus/params"
-	"github.com/ebakus/ebakusdb"
 )
 
 type TwoOperandTestcase struct {
@@ -91,8 +91,8 @@ func init() {
 }
 
 func testTwoOperandOp(t *testing.T, tests []TwoOperandTestcase, opFn executionFunc, name string) {
-	ebakusDb, _ := ebakusdb.OpenInMemory(nil)
-	ebakusSnapshot := ebakusDb.GetRootSnapshot()
+	ebakusDb, _ := ebkdb.OpenInMemory(nil)
+	ebakusSnapshot := ebkdb.NewState(ebakusDb.GetRootSnapshot())
 	defer ebakusSnapshot.Release()
 
 	var (
@@ -214,8 +214,8 @@ func TestSAR(t *testing.T) {
 
 // getResult is a convenience function to generate the expected values
 func getResult(args []*twoOperandParams, opFn executionFunc) []TwoOperandTestcase {
-	ebakusDb, _ := ebakusdb.OpenInMemory(nil)
-	ebakusSnapshot := ebakusDb.GetRootSnapshot()
+	ebakusDb, _ := ebkdb.OpenInMemory(nil)
+	ebakusSnapshot := ebkdb.NewState(ebakusDb.GetRootSnapshot())
 	defer ebakusSnapshot.Release()
 
 	var (
@@ -268,8 +268,8 @@ func TestJsonTestcases(t *testing.T) {
 }
 
 func opBenchmark(bench *testing.B, op func(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error), args ...string) {
-	ebakusDb, _ := ebakusdb.OpenInMemory(nil)
-	ebakusSnapshot := ebakusDb.GetRootSnapshot()
+	ebakusDb, _ := ebkdb.OpenInMemory(nil)
+	ebakusSnapshot := ebkdb.NewState(ebakusDb.GetRootSnapshot())
 	defer ebakusSnapshot.Release()
 
 	var (
@@ -507,8 +507,8 @@ func BenchmarkOpIsZero(b *testing.B) {
 }
 
 func TestOpMstore(t *testing.T) {
-	ebakusDb, _ := ebakusdb.OpenInMemory(nil)
-	ebakusSnapshot := ebakusDb.GetRootSnapshot()
+	ebakusDb, _ := ebkdb.OpenInMemory(nil)
+	ebakusSnapshot := ebkdb.NewState(ebakusDb.GetRootSnapshot())
 	defer ebakusSnapshot.Release()
 
 	var (
@@ -537,8 +537,8 @@ func TestOpMstore(t *testing.T) {
 }
 
 func BenchmarkOpMstore(bench *testing.B) {
-	ebakusDb, _ := ebakusdb.OpenInMemory(nil)
-	ebakusSnapshot := ebakusDb.GetRootSnapshot()
+	ebakusDb, _ := ebkdb.OpenInMemory(nil)
+	ebakusSnapshot := ebkdb.NewState(ebakusDb.GetRootSnapshot())
 	defer ebakusSnapshot.Release()
 
 	var (
@@ -564,8 +564,8 @@ func BenchmarkOpMstore(bench *testing.B) {
 }
 
 func BenchmarkOpSHA3(bench *testing.B) {
-	ebakusDb, _ := ebakusdb.OpenInMemory(nil)
-	ebakusSnapshot := ebakusDb.GetRootSnapshot()
+	ebakusDb, _ := ebkdb.OpenInMemory(nil)
+	ebakusSnapshot := ebkdb.NewState(ebakusDb.GetRootSnapshot())
 	defer ebakusSnapshot.Release()
 
 	var (
@@ -113,6 +113,7 @@ type StructLogger struct {
 	cfg LogConfig
 
 	logs          []StructLog
+	precompiles   []*PrecompileFrame
 	changedValues map[common.Address]Storage
 	output        []byte
 	err           error
@@ -190,6 +191,13 @@ func (l *StructLogger) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost ui
 	return nil
 }
 
+// CapturePrecompile implements the PrecompileTracer interface to trace the calls
+// into the ebakus precompiles.
+func (l *StructLogger) CapturePrecompile(env *EVM, frame *PrecompileFrame) error {
+	l.precompiles = append(l.precompiles, frame)
+	return nil
+}
+
 // CaptureEnd is called after the call finishes to finalize the tracing.
 func (l *StructLogger) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
 	l.output = output
@@ -206,6 +214,9 @@ func (l *StructLogger) CaptureEnd(output []byte, gasUsed uint64, t time.Duration
 // StructLogs returns the captured log entries.
 func (l *StructLogger) StructLogs() []StructLog { return l.logs }
 
+// PrecompileFrames returns the captured precompile calls.
+func (l *StructLogger) PrecompileFrames() []*PrecompileFrame { return l.precompiles }
+
 // Error returns the VM error captured by the trace.
 func (l *StructLogger) Error() error { return l.err }
 
@@ -72,6 +72,13 @@ func (l *JSONLogger) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint
 	return nil
 }
 
+// CapturePrecompile outputs the calls into the ebakus precompiles on the logger.
+func (l *JSONLogger) CapturePrecompile(env *EVM, frame *PrecompileFrame) error {
+	return l.encoder.Encode(struct {
+		Precompile *PrecompileFrame `json:"precompile"`
+	}{frame})
+}
+
 // CaptureEnd is triggered at end of execution.
 func (l *JSONLogger) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
 	type endLog struct {
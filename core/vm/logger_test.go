@@ -21,9 +21,9 @@ import (
 	"testing"
 
 	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/state"
 	"github.com/ebakus/go-ebakus/params"
-	"github.com/ebakus/ebakusdb"
 )
 
 type dummyContractRef struct {
@@ -50,8 +50,8 @@ type dummyStatedb struct {
 func (*dummyStatedb) GetRefund() uint64 { return 1337 }
 
 func TestStoreCapture(t *testing.T) {
-	ebakusDb, _ := ebakusdb.OpenInMemory(nil)
-	ebakusSnapshot := ebakusDb.GetRootSnapshot()
+	ebakusDb, _ := ebkdb.OpenInMemory(nil)
+	ebakusSnapshot := ebkdb.NewState(ebakusDb.GetRootSnapshot())
 	defer ebakusSnapshot.Release()
 
 	var (
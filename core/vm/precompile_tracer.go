@@ -0,0 +1,190 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding"
+	"reflect"
+	"strings"
+
+	"github.com/ebakus/go-ebakus/accounts/abi"
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/common/hexutil"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+)
+
+// PrecompileFrame describes a call into the system, db or wrapped token
+// contract, which would otherwise show up in traces as an opaque CALL.
+type PrecompileFrame struct {
+	Depth       int                    `json:"depth"`
+	Address     common.Address         `json:"address"`
+	Command     string                 `json:"command"`
+	Args        map[string]interface{} `json:"args,omitempty"`
+	RowsRead    uint64                 `json:"rowsRead"`
+	RowsWritten uint64                 `json:"rowsWritten"`
+	Gas         uint64                 `json:"gas"`       // Gas required by the command
+	MemoryGas   uint64                 `json:"memoryGas"` // Gas charged for the ebakus db memory used
+	GasUsed     uint64                 `json:"gasUsed"`
+	Err         error                  `json:"-"`
+	Error       string                 `json:"error,omitempty"`
+}
+
+// PrecompileTracer is implemented by the tracers which want to capture the calls
+// into the ebakus precompiles. It is optional, so the existing Tracer
+// implementations keep working unchanged.
+type PrecompileTracer interface {
+	CapturePrecompile(env *EVM, frame *PrecompileFrame) error
+}
+
+// precompileABI returns the ABI of the precompiles with one, or an empty string.
+func precompileABI(p PrecompiledContract) string {
+	switch p.(type) {
+	case *systemContract:
+		return SystemContractABI
+	case *dbContract:
+		return DBABI
+	case *wrappedToken:
+		return WrappedTokenABI
+	default:
+		return ""
+	}
+}
+
+// newPrecompileFrame decodes a call into a precompile with an ABI, returning nil
+// for the stateless precompiles.
+func newPrecompileFrame(evm *EVM, p PrecompiledContract, address common.Address, input []byte) *PrecompileFrame {
+	contractABI := precompileABI(p)
+	if contractABI == "" {
+		return nil
+	}
+	frame := &PrecompileFrame{
+		Depth:   evm.depth + 1,
+		Address: address,
+		Gas:     p.RequiredGas(input),
+	}
+	evmABI, err := abi.JSON(strings.NewReader(contractABI))
+	if err != nil {
+		return frame
+	}
+	method, err := evmABI.MethodById(input)
+	if err != nil {
+		return frame
+	}
+	frame.Command = method.Name
+
+	args := make(map[string]interface{})
+	if err := method.Inputs.UnpackIntoMap(args, input[4:]); err == nil {
+		for name, arg := range args {
+			args[name] = traceableArg(arg)
+		}
+		frame.Args = args
+	}
+	return frame
+}
+
+// traceableArg converts the byte arguments to hex, as they would otherwise be
+// encoded as base64 strings or number arrays. Addresses and hashes already are.
+func traceableArg(arg interface{}) interface{} {
+	if _, ok := arg.(encoding.TextMarshaler); ok {
+		return arg
+	}
+	value := reflect.ValueOf(arg)
+	switch {
+	case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Uint8:
+		return hexutil.Bytes(value.Bytes())
+	case value.Kind() == reflect.Array && value.Type().Elem().Kind() == reflect.Uint8:
+		blob := make([]byte, value.Len())
+		reflect.Copy(reflect.ValueOf(blob), value)
+		return hexutil.Bytes(blob)
+	default:
+		return arg
+	}
+}
+
+// tracedRows counts the ebakus db rows accessed while tracing.
+type tracedRows struct {
+	read    uint64
+	written uint64
+}
+
+// tracedState counts the rows read and written through the ebakus state.
+type tracedState struct {
+	ebkdb.State
+	rows *tracedRows
+}
+
+func (s *tracedState) Get(key []byte) (*[]byte, bool) {
+	value, ok := s.State.Get(key)
+	if ok {
+		s.rows.read++
+	}
+	return value, ok
+}
+
+func (s *tracedState) Insert(key, value []byte) error {
+	return s.written(s.State.Insert(key, value))
+}
+
+func (s *tracedState) Delete(key []byte) error {
+	return s.written(s.State.Delete(key))
+}
+
+func (s *tracedState) InsertObj(table string, obj interface{}) error {
+	return s.written(s.State.InsertObj(table, obj))
+}
+
+func (s *tracedState) DeleteObj(table string, id interface{}) error {
+	return s.written(s.State.DeleteObj(table, id))
+}
+
+func (s *tracedState) Select(table string, args ...interface{}) (ebkdb.Iterator, error) {
+	iter, err := s.State.Select(table, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedIterator{Iterator: iter, rows: s.rows}, nil
+}
+
+func (s *tracedState) written(err error) error {
+	if err == nil {
+		s.rows.written++
+	}
+	return err
+}
+
+// tracedIterator counts the rows read through an iterator. Iterators outlive
+// the call which selected them, so the rows are counted on the EVM and each
+// frame reports the rows read while it ran.
+type tracedIterator struct {
+	ebkdb.Iterator
+	rows *tracedRows
+}
+
+func (it *tracedIterator) Next(val interface{}) bool {
+	return it.read(it.Iterator.Next(val))
+}
+
+func (it *tracedIterator) Prev(val interface{}) bool {
+	return it.read(it.Iterator.Prev(val))
+}
+
+func (it *tracedIterator) read(ok bool) bool {
+	if ok {
+		it.rows.read++
+	}
+	return ok
+}
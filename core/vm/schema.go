@@ -0,0 +1,160 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/log"
+	"github.com/ebakus/go-ebakus/params"
+)
+
+// SystemSchemaVersion is the version of the system tables schema the software
+// supports. Upgrades adding system tables or fields bump it, registering the
+// migration bringing the previous version up to it in schemaMigrations, along
+// with the fork activating it.
+const SystemSchemaVersion = 1
+
+// systemSchemaVersionDBKey holds the version of the system tables schema. The
+// genesis states are at version 0, the fork gated migrations record the later
+// versions at the same block on every node.
+const systemSchemaVersionDBKey = "ebk:global:schemaVersion"
+
+// SchemaMigration upgrades the system tables from the previous schema version.
+type SchemaMigration struct {
+	Version     uint64                                   // Version the migration upgrades to
+	Description string                                   // Description of the changes, for the operators
+	Active      func(*params.ChainConfig, *big.Int) bool // Whether the fork running the migration is active at a block
+	Migrate     func(ebkdb.State) error                  // Upgrades the tables in place
+}
+
+// schemaMigrations are the migrations to each schema version, in order.
+var schemaMigrations = []SchemaMigration{
+	{
+		Version:     1,
+		Description: "Record the schema version of the genesis system tables",
+		Active:      (*params.ChainConfig).IsSystemSchema,
+		Migrate:     checkGenesisTables,
+	},
+}
+
+func init() {
+	for i, migration := range schemaMigrations {
+		if migration.Version != uint64(i+1) {
+			panic(fmt.Sprintf("system schema migration %d registered as version %d", i+1, migration.Version))
+		}
+	}
+	if len(schemaMigrations) != SystemSchemaVersion {
+		panic(fmt.Sprintf("system schema version %d has %d migrations", SystemSchemaVersion, len(schemaMigrations)))
+	}
+}
+
+// HasSystemTables reports whether the state was set up with the system tables,
+// which the chains without a dpos boot producer lack.
+func HasSystemTables(db ebkdb.State) bool {
+	return db.HasTable(WitnessesTable)
+}
+
+// GetSystemSchemaVersion returns the version of the system tables schema of the
+// state.
+func GetSystemSchemaVersion(db ebkdb.State) uint64 {
+	if version, found := db.Get([]byte(systemSchemaVersionDBKey)); found && len(*version) == 8 {
+		return binary.BigEndian.Uint64(*version)
+	}
+	return 0
+}
+
+func putSystemSchemaVersion(db ebkdb.State, version uint64) error {
+	blob := make([]byte, 8)
+	binary.BigEndian.PutUint64(blob, version)
+
+	return db.Insert([]byte(systemSchemaVersionDBKey), blob)
+}
+
+// PendingSchemaMigrations returns the migrations bringing the system tables of
+// the state up to the version the software supports, failing if the state is
+// of a newer software.
+func PendingSchemaMigrations(db ebkdb.State) ([]SchemaMigration, error) {
+	version := GetSystemSchemaVersion(db)
+	if version > SystemSchemaVersion {
+		return nil, fmt.Errorf("system schema version %d is newer than the supported %d, upgrade the node", version, SystemSchemaVersion)
+	}
+	return schemaMigrations[version:], nil
+}
+
+// ApplySchemaMigrations runs the pending migrations of the system tables whose
+// fork is active at the given block, in order. The migrations are consensus
+// changes, run while processing the blocks, so all the nodes migrate at the
+// same height.
+func ApplySchemaMigrations(config *params.ChainConfig, number *big.Int, db ebkdb.State) error {
+	if !HasSystemTables(db) {
+		return nil
+	}
+	migrations, err := PendingSchemaMigrations(db)
+	if err != nil {
+		return err
+	}
+	for i, migration := range migrations {
+		if !migration.Active(config, number) {
+			migrations = migrations[:i]
+			break
+		}
+	}
+	return runSchemaMigrations(db, migrations)
+}
+
+// MigrateSystemSchema runs all the pending migrations of the system tables of
+// the state in order, regardless of their forks. It's meant for dry runs, on a
+// snapshot of the state to be discarded.
+func MigrateSystemSchema(db ebkdb.State) error {
+	migrations, err := PendingSchemaMigrations(db)
+	if err != nil {
+		return err
+	}
+	return runSchemaMigrations(db, migrations)
+}
+
+// runSchemaMigrations runs the given migrations in order, recording the version
+// reached. It stops at the first failing one, leaving the state partially
+// migrated.
+func runSchemaMigrations(db ebkdb.State, migrations []SchemaMigration) error {
+	for _, migration := range migrations {
+		log.Info("Migrating system tables", "version", migration.Version, "change", migration.Description)
+
+		if err := migration.Migrate(db); err != nil {
+			return fmt.Errorf("system schema migration to version %d failed: %v", migration.Version, err)
+		}
+		if err := putSystemSchemaVersion(db, migration.Version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkGenesisTables checks that the tables SystemContractSetupDB creates exist.
+func checkGenesisTables(db ebkdb.State) error {
+	for _, table := range []string{WitnessesTable, types.StakedTable, ClaimableTable, DelegationTable, ContractAbiTable} {
+		if !db.HasTable(table) {
+			return fmt.Errorf("system table %s missing", table)
+		}
+	}
+	return nil
+}
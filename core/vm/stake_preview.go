@@ -0,0 +1,113 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/ebakus/go-ebakus/accounts/abi"
+	"github.com/ebakus/go-ebakus/common"
+)
+
+// StakePreview is the outcome of a stake or unstake, as previewed by running it
+// against a throwaway copy of the state.
+type StakePreview struct {
+	Claimed     uint64 // Vested claimable and unlocked amounts claimed to the balance first
+	Reused      uint64 // Vesting claimable amount restaked instead of transferred
+	Transferred uint64 // Amount transferred from the balance to the stake
+	Staked      uint64 // Amount staked afterwards
+	Claimable   uint64 // Amount of the claimable entry an unstake creates, merged ones included
+	ClaimableAt uint64 // Time the claimable entry an unstake creates vests at
+}
+
+// PreviewStake runs a stake of amount by from against the states of the evm,
+// exactly as the system contract does, and reports how it was funded. The evm
+// states are modified, so they must be throwaway copies.
+func PreviewStake(evm *EVM, from common.Address, amount uint64) (*StakePreview, error) {
+	evmABI, err := abi.JSON(strings.NewReader(SystemContractABI))
+	if err != nil {
+		return nil, errSystemContractAbiError
+	}
+	c := &systemContract{}
+
+	var (
+		preview = new(StakePreview)
+		before  = evm.StateDB.GetBalance(from)
+	)
+	if _, err := c.claimCmd(evm, &evmABI, from); err != nil {
+		return nil, err
+	}
+	claimed := evm.StateDB.GetBalance(from)
+	preview.Claimed = evm.fromWei(new(big.Int).Sub(claimed, before))
+
+	if _, err := c.stakeCmd(evm, &evmABI, from, amount); err != nil {
+		return preview, err
+	}
+	preview.Transferred = evm.fromWei(new(big.Int).Sub(claimed, evm.StateDB.GetBalance(from)))
+	preview.Reused = amount - preview.Transferred
+
+	staked, err := GetStaked(evm.EbakusState, from)
+	if err != nil {
+		return preview, err
+	}
+	if staked != nil {
+		preview.Staked = staked.Amount
+	}
+	return preview, nil
+}
+
+// PreviewUnstake runs an unstake of amount by from against the states of the
+// evm, exactly as the system contract does, and reports the claimable entry it
+// creates. The evm states are modified, so they must be throwaway copies.
+func PreviewUnstake(evm *EVM, from common.Address, amount uint64) (*StakePreview, error) {
+	evmABI, err := abi.JSON(strings.NewReader(SystemContractABI))
+	if err != nil {
+		return nil, errSystemContractAbiError
+	}
+	c := &systemContract{}
+
+	preview := &StakePreview{ClaimableAt: evm.Time.Uint64() + unstakeVestingPeriod}
+	if _, err := c.unstakeCmd(evm, &evmABI, from, amount); err != nil {
+		return preview, err
+	}
+	staked, err := GetStaked(evm.EbakusState, from)
+	if err != nil {
+		return preview, err
+	}
+	if staked != nil {
+		preview.Staked = staked.Amount
+	}
+	id := GetClaimableId(from, preview.ClaimableAt)
+
+	where := []byte("Id = ")
+	whereClause, err := evm.EbakusState.WhereParser(append(where, id[:]...))
+	if err != nil {
+		return preview, errSystemContractQueryError
+	}
+	iter, err := evm.EbakusState.Select(ClaimableTable, whereClause)
+	if err != nil {
+		return preview, errSystemContractError
+	}
+	defer iter.Release()
+
+	var claimable Claimable
+	if iter.Next(&claimable) {
+		preview.Claimable = claimable.Amount
+	}
+	return preview, nil
+}
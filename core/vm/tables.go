@@ -0,0 +1,78 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"reflect"
+
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/core/types"
+)
+
+func init() {
+	ebkdb.RegisterKeys(types.SystemStakeDBKey, bridgeCounterDBKey, systemSchemaVersionDBKey)
+	ebkdb.RegisterTables(listTables)
+}
+
+// systemTableRows are the row types of the system contract tables, which have
+// no ABI registered to decode them by.
+var systemTableRows = map[string]reflect.Type{
+	WitnessesTable:        reflect.TypeOf(Witness{}),
+	types.StakedTable:     reflect.TypeOf(types.Staked{}),
+	ClaimableTable:        reflect.TypeOf(Claimable{}),
+	DelegationTable:       reflect.TypeOf(Delegation{}),
+	WitnessVotersTable:    reflect.TypeOf(WitnessVoter{}),
+	ContractAbiTable:      reflect.TypeOf(ContractAbi{}),
+	ContractCreatorsTable: reflect.TypeOf(ContractCreator{}),
+	TombstoneTable:        reflect.TypeOf(Tombstone{}),
+	TablesAliasTable:      reflect.TypeOf(TablesAlias{}),
+	LockedTransferTable:   reflect.TypeOf(LockedTransfer{}),
+	SubscriptionTable:     reflect.TypeOf(Subscription{}),
+	AllowanceTable:        reflect.TypeOf(Allowance{}),
+	BridgeIntentTable:     reflect.TypeOf(BridgeIntent{}),
+	BridgeCommitmentTable: reflect.TypeOf(BridgeCommitment{}),
+	WitnessEnodesTable:    reflect.TypeOf(WitnessEnode{}),
+}
+
+// listTables lists the system tables, created lazily and thus not necessarily
+// held by the state, along with the tables the contracts created, typed after
+// their ABIs.
+func listTables(db ebkdb.State) ([]ebkdb.Table, error) {
+	var tables []ebkdb.Table
+	for name, row := range systemTableRows {
+		tables = append(tables, ebkdb.Table{Name: name, Row: row, System: true})
+	}
+	contractTables, err := GetContractTables(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range contractTables {
+		tableABI, err := GetAbiForTable(db, table.Contract, table.Name)
+		if err != nil {
+			return nil, err
+		}
+		row, err := tableABI.GetTableInstance(table.Name)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, ebkdb.Table{
+			Name: ebkdb.GetDBTableName(table.Contract, table.Name),
+			Row:  reflect.TypeOf(row).Elem(),
+		})
+	}
+	return tables, nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"errors"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/p2p/enode"
+)
+
+// maxEnodeLength is the maximum length of an announced enode URL.
+const maxEnodeLength = 256
+
+var (
+	errEnodeMalformed  = errors.New("enode announcement transaction malformed")
+	errEnodeInvalid    = errors.New("announced enode is invalid")
+	errEnodeNotWitness = errors.New("only witnesses can announce an enode")
+)
+
+// WitnessEnode is the enode URL a witness announces for its block producing
+// node, so the nodes of the network can stay connected to the producers.
+type WitnessEnode struct {
+	Id    common.Address
+	Enode string
+}
+
+var WitnessEnodesTable = ebkdb.GetDBTableName(types.PrecompliledSystemContract, "WitnessEnodes")
+
+// announceEnodeCmd records the enode of the witness's block producing node. It
+// serves both announceEnode and setWitnessEnode. An empty url withdraws the
+// announcement.
+func (c *systemContract) announceEnodeCmd(evm *EVM, from common.Address, url string) ([]byte, error) {
+	db := evm.EbakusState
+
+	whereClause, err := makeIDLikeWhereClause(db, from)
+	if err != nil {
+		return nil, err
+	}
+	iter, err := db.Select(WitnessesTable, whereClause)
+	if err != nil {
+		return nil, errSystemContractError
+	}
+	var witness Witness
+	if !iter.Next(&witness) {
+		return nil, errEnodeNotWitness
+	}
+
+	if url == "" {
+		if db.HasTable(WitnessEnodesTable) {
+			if err := db.DeleteObj(WitnessEnodesTable, from); err != nil {
+				return nil, errSystemContractError
+			}
+		}
+		return nil, nil
+	}
+	if len(url) > maxEnodeLength {
+		return nil, errEnodeInvalid
+	}
+	if _, err := enode.ParseV4(url); err != nil {
+		return nil, errEnodeInvalid
+	}
+
+	if !db.HasTable(WitnessEnodesTable) {
+		db.CreateTable(WitnessEnodesTable, &WitnessEnode{})
+	}
+	if err := db.InsertObj(WitnessEnodesTable, &WitnessEnode{Id: from, Enode: url}); err != nil {
+		return nil, errSystemContractError
+	}
+	return nil, nil
+}
+
+// GetWitnessEnode returns the enode announced by the witness, or nil if none.
+func GetWitnessEnode(db ebkdb.State, witness common.Address) (*enode.Node, error) {
+	if !db.HasTable(WitnessEnodesTable) {
+		return nil, nil
+	}
+	whereClause, err := makeIDLikeWhereClause(db, witness)
+	if err != nil {
+		return nil, err
+	}
+	iter, err := db.Select(WitnessEnodesTable, whereClause)
+	if err != nil {
+		return nil, errSystemContractError
+	}
+	defer iter.Release()
+
+	var entry WitnessEnode
+	if !iter.Next(&entry) || entry.Id != witness {
+		return nil, nil
+	}
+	return enode.ParseV4(entry.Enode)
+}
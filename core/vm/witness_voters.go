@@ -0,0 +1,138 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/core/types"
+)
+
+// WitnessVoter is a delegation keyed by the witness first, so the voters of a
+// witness are selected by an Id prefix rather than a scan of all delegations.
+type WitnessVoter struct {
+	Id DelegationId // <witness><from>
+}
+
+var WitnessVotersTable = ebkdb.GetDBTableName(types.PrecompliledSystemContract, "WitnessVoters")
+
+// CreateWitnessVotersIndex creates the table indexing the delegations by
+// witness out of the existing ones. It's run once, at the reward sharing fork,
+// after which the delegations keep the index up to date.
+func CreateWitnessVotersIndex(db ebkdb.State) error {
+	if db.HasTable(WitnessVotersTable) {
+		return nil
+	}
+	iter, err := db.Select(DelegationTable)
+	if err != nil {
+		return errSystemContractError
+	}
+	var (
+		voters     []WitnessVoter
+		delegation Delegation
+	)
+	for iter.Next(&delegation) {
+		from, witness := delegation.Id.Content()
+		voters = append(voters, WitnessVoter{Id: AddressesToDelegationId(witness, from)})
+	}
+	iter.Release()
+
+	db.CreateTable(WitnessVotersTable, &WitnessVoter{})
+	for i := range voters {
+		if err := db.InsertObj(WitnessVotersTable, &voters[i]); err != nil {
+			return errSystemContractError
+		}
+	}
+	return nil
+}
+
+// insertDelegation stores the delegation of from to the witness, indexing it
+// by witness too once the index exists.
+func insertDelegation(db ebkdb.State, from common.Address, witness common.Address) error {
+	if err := db.InsertObj(DelegationTable, &Delegation{Id: AddressesToDelegationId(from, witness)}); err != nil {
+		return errSystemContractError
+	}
+	if db.HasTable(WitnessVotersTable) {
+		if err := db.InsertObj(WitnessVotersTable, &WitnessVoter{Id: AddressesToDelegationId(witness, from)}); err != nil {
+			return errSystemContractError
+		}
+	}
+	return nil
+}
+
+// deleteDelegation deletes the delegation of from to the witness, along with
+// its witness index entry.
+func deleteDelegation(db ebkdb.State, from common.Address, witness common.Address) error {
+	if err := db.DeleteObj(DelegationTable, AddressesToDelegationId(from, witness)); err != nil {
+		return errSystemContractError
+	}
+	if db.HasTable(WitnessVotersTable) {
+		if err := db.DeleteObj(WitnessVotersTable, AddressesToDelegationId(witness, from)); err != nil {
+			return errSystemContractError
+		}
+	}
+	return nil
+}
+
+// GetWitnessVoters returns the accounts delegating to the given witness, sorted
+// by address. Before the witness index exists, all the delegations are scanned.
+func GetWitnessVoters(db ebkdb.State, witness common.Address) ([]common.Address, error) {
+	if !db.HasTable(WitnessVotersTable) {
+		return scanWitnessVoters(db, witness)
+	}
+	whereClause, err := makeIDLikeWhereClause(db, witness)
+	if err != nil {
+		return nil, err
+	}
+	iter, err := db.Select(WitnessVotersTable, whereClause)
+	if err != nil {
+		return nil, errSystemContractError
+	}
+	defer iter.Release()
+
+	var (
+		voters []common.Address
+		voter  WitnessVoter
+	)
+	for iter.Next(&voter) {
+		if to, from := voter.Id.Content(); to == witness {
+			voters = append(voters, from)
+		}
+	}
+	return voters, nil
+}
+
+// scanWitnessVoters returns the accounts delegating to the given witness out of
+// a scan of all the delegations.
+func scanWitnessVoters(db ebkdb.State, witness common.Address) ([]common.Address, error) {
+	iter, err := db.Select(DelegationTable)
+	if err != nil {
+		return nil, errSystemContractError
+	}
+	defer iter.Release()
+
+	var (
+		voters     []common.Address
+		delegation Delegation
+	)
+	for iter.Next(&delegation) {
+		if from, to := delegation.Id.Content(); to == witness {
+			voters = append(voters, from)
+		}
+	}
+	return voters, nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+)
+
+// Tests that the witness voters index, created over existing delegations and
+// maintained afterwards, returns the same voters as scanning the delegations.
+func TestWitnessVotersIndex(t *testing.T) {
+	db, err := ebkdb.OpenInMemory(nil)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+
+	state := ebkdb.NewState(db.GetRootSnapshot())
+	defer state.Release()
+
+	if err := state.CreateTable(DelegationTable, &Delegation{}); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	var (
+		witness = common.HexToAddress("0x0a")
+		other   = common.HexToAddress("0x0b")
+		voters  = []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02"), common.HexToAddress("0x03")}
+	)
+	check := func(stage string, want []common.Address) {
+		have, err := GetWitnessVoters(state, witness)
+		if err != nil {
+			t.Fatalf("%s: failed to get voters: %v", stage, err)
+		}
+		scanned, err := scanWitnessVoters(state, witness)
+		if err != nil {
+			t.Fatalf("%s: failed to scan voters: %v", stage, err)
+		}
+		if !reflect.DeepEqual(have, want) || !reflect.DeepEqual(scanned, want) {
+			t.Errorf("%s: voters mismatch: have %x, scanned %x, want %x", stage, have, scanned, want)
+		}
+	}
+	for _, voter := range voters[:2] {
+		if err := insertDelegation(state, voter, witness); err != nil {
+			t.Fatalf("failed to delegate: %v", err)
+		}
+		if err := insertDelegation(state, voter, other); err != nil {
+			t.Fatalf("failed to delegate: %v", err)
+		}
+	}
+	check("unindexed", voters[:2])
+
+	if err := CreateWitnessVotersIndex(state); err != nil {
+		t.Fatalf("failed to create index: %v", err)
+	}
+	check("indexed", voters[:2])
+
+	if err := insertDelegation(state, voters[2], witness); err != nil {
+		t.Fatalf("failed to delegate: %v", err)
+	}
+	if err := deleteDelegation(state, voters[0], witness); err != nil {
+		t.Fatalf("failed to undelegate: %v", err)
+	}
+	check("updated", voters[1:])
+}
@@ -0,0 +1,465 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/ebakus/go-ebakus/accounts/abi"
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/crypto"
+	"github.com/ebakus/go-ebakus/log"
+	"github.com/ebakus/go-ebakus/params"
+)
+
+const (
+	WrappedTokenName     = "Wrapped EBK"
+	WrappedTokenSymbol   = "WEBK"
+	WrappedTokenDecimals = 18
+
+	WrappedTokenNameCmd         = "name"
+	WrappedTokenSymbolCmd       = "symbol"
+	WrappedTokenDecimalsCmd     = "decimals"
+	WrappedTokenTotalSupplyCmd  = "totalSupply"
+	WrappedTokenBalanceOfCmd    = "balanceOf"
+	WrappedTokenAllowanceCmd    = "allowance"
+	WrappedTokenTransferCmd     = "transfer"
+	WrappedTokenTransferFromCmd = "transferFrom"
+	WrappedTokenApproveCmd      = "approve"
+	WrappedTokenDepositCmd      = "deposit"
+	WrappedTokenWithdrawCmd     = "withdraw"
+)
+
+// Storage slots of the balances and allowances mappings of the wrapped token.
+var (
+	wrappedTokenBalancesSlot   = common.Hash{}
+	wrappedTokenAllowancesSlot = common.BigToHash(big.NewInt(1))
+)
+
+var (
+	errWrappedTokenContext    = errors.New("wrapped token can only be called directly")
+	errWrappedTokenNonPayable = errors.New("wrapped token command is not payable")
+	errWrappedTokenMalformed  = errors.New("wrapped token transaction malformed")
+	errWrappedTokenBalance    = errors.New("wrapped token balance too low")
+	errWrappedTokenAllowance  = errors.New("wrapped token allowance too low")
+)
+
+// wrappedToken is an ERC-20 token wrapping EBK one to one at a canonical
+// address. Deposited EBK are held in the balance of the precompile, which is
+// the total supply of the token, while the token balances and allowances live
+// in its account storage, laid out like Solidity mappings.
+type wrappedToken struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *wrappedToken) RequiredGas(input []byte) uint64 {
+	if len(input) == 0 {
+		return params.WrappedTokenWriteGas
+	}
+	if len(input) < 4 {
+		return params.WrappedTokenBaseGas
+	}
+
+	evmABI, err := abi.JSON(strings.NewReader(WrappedTokenABI))
+	if err != nil {
+		return params.WrappedTokenBaseGas
+	}
+	method, err := evmABI.MethodById(input[:4])
+	if err != nil {
+		return params.WrappedTokenBaseGas
+	}
+
+	switch method.Name {
+	case WrappedTokenBalanceOfCmd, WrappedTokenAllowanceCmd:
+		return params.WrappedTokenReadGas
+	case WrappedTokenDepositCmd, WrappedTokenWithdrawCmd, WrappedTokenApproveCmd:
+		return params.WrappedTokenWriteGas
+	case WrappedTokenTransferCmd:
+		return 2 * params.WrappedTokenWriteGas
+	case WrappedTokenTransferFromCmd:
+		return 3 * params.WrappedTokenWriteGas
+	default:
+		return params.WrappedTokenBaseGas
+	}
+}
+
+func (c *wrappedToken) Run(evm *EVM, contract *Contract, input []byte) ([]byte, error) {
+	// Call codes and delegate calls would credit the deposits to the caller,
+	// minting tokens out of thin air
+	if contract.Address() != types.PrecompliledWrappedToken {
+		return nil, errWrappedTokenContext
+	}
+	from := contract.Caller()
+
+	// Plain transfers of EBK are deposits
+	if len(input) == 0 {
+		return c.depositCmd(evm, from, contract.Value())
+	}
+	if len(input) < 4 {
+		return nil, errInputMethodTooShort
+	}
+
+	evmABI, err := abi.JSON(strings.NewReader(WrappedTokenABI))
+	if err != nil {
+		return nil, errSystemContractAbiError
+	}
+
+	cmdData, inputData := input[:4], input[4:]
+	method, err := evmABI.MethodById(cmdData)
+	if err != nil {
+		return nil, errSystemContractAbiError
+	}
+	if err := validateMethodInput(method, inputData); err != nil {
+		log.Trace("WrappedTokenABI invalid input", "cmd", method.Name, "err", err)
+		return nil, err
+	}
+
+	cmd := method.Name
+
+	if cmd != WrappedTokenDepositCmd && contract.Value().Sign() > 0 {
+		return nil, errWrappedTokenNonPayable
+	}
+	if !readOnlyPrecompileCmds[cmd] && evm.inStaticCall() {
+		return nil, errWriteProtection
+	}
+
+	switch cmd {
+	case WrappedTokenNameCmd:
+		return method.Outputs.Pack(WrappedTokenName)
+	case WrappedTokenSymbolCmd:
+		return method.Outputs.Pack(WrappedTokenSymbol)
+	case WrappedTokenDecimalsCmd:
+		return method.Outputs.Pack(uint8(WrappedTokenDecimals))
+	case WrappedTokenTotalSupplyCmd:
+		return method.Outputs.Pack(evm.StateDB.GetBalance(types.PrecompliledWrappedToken))
+	case WrappedTokenBalanceOfCmd:
+		var owner common.Address
+		if err := evmABI.UnpackWithArguments(&owner, cmd, inputData, abi.InputsArgumentsType); err != nil {
+			return nil, errWrappedTokenMalformed
+		}
+		return method.Outputs.Pack(GetWrappedBalance(evm.StateDB, owner))
+	case WrappedTokenAllowanceCmd:
+		type allowanceInput struct {
+			Owner   common.Address
+			Spender common.Address
+		}
+
+		var input allowanceInput
+		if err := evmABI.UnpackWithArguments(&input, cmd, inputData, abi.InputsArgumentsType); err != nil {
+			return nil, errWrappedTokenMalformed
+		}
+		return method.Outputs.Pack(GetWrappedAllowance(evm.StateDB, input.Owner, input.Spender))
+	case WrappedTokenDepositCmd:
+		return c.depositCmd(evm, from, contract.Value())
+	case WrappedTokenWithdrawCmd:
+		var amount *big.Int
+		if err := evmABI.UnpackWithArguments(&amount, cmd, inputData, abi.InputsArgumentsType); err != nil {
+			return nil, errWrappedTokenMalformed
+		}
+		return c.withdrawCmd(evm, from, amount)
+	case WrappedTokenTransferCmd:
+		type transferInput struct {
+			To     common.Address
+			Amount *big.Int
+		}
+
+		var input transferInput
+		if err := evmABI.UnpackWithArguments(&input, cmd, inputData, abi.InputsArgumentsType); err != nil {
+			return nil, errWrappedTokenMalformed
+		}
+		if err := c.transfer(evm, &evmABI, from, input.To, input.Amount); err != nil {
+			return nil, err
+		}
+		return method.Outputs.Pack(true)
+	case WrappedTokenTransferFromCmd:
+		type transferFromInput struct {
+			From   common.Address
+			To     common.Address
+			Amount *big.Int
+		}
+
+		var input transferFromInput
+		if err := evmABI.UnpackWithArguments(&input, cmd, inputData, abi.InputsArgumentsType); err != nil {
+			return nil, errWrappedTokenMalformed
+		}
+		// Owners moving their own tokens need no allowance
+		if input.From != from {
+			allowance := GetWrappedAllowance(evm.StateDB, input.From, from)
+			if allowance.Cmp(input.Amount) < 0 {
+				return nil, errWrappedTokenAllowance
+			}
+			setWrappedAllowance(evm.StateDB, input.From, from, allowance.Sub(allowance, input.Amount))
+		}
+		if err := c.transfer(evm, &evmABI, input.From, input.To, input.Amount); err != nil {
+			return nil, err
+		}
+		return method.Outputs.Pack(true)
+	case WrappedTokenApproveCmd:
+		type approveInput struct {
+			Spender common.Address
+			Amount  *big.Int
+		}
+
+		var input approveInput
+		if err := evmABI.UnpackWithArguments(&input, cmd, inputData, abi.InputsArgumentsType); err != nil {
+			return nil, errWrappedTokenMalformed
+		}
+		setWrappedAllowance(evm.StateDB, from, input.Spender, input.Amount)
+
+		if err := c.addLog(evm, &evmABI, "Approval", []common.Hash{from.Hash(), input.Spender.Hash()}, input.Amount); err != nil {
+			return nil, err
+		}
+		return method.Outputs.Pack(true)
+	}
+
+	return nil, errSystemContractAbiError
+}
+
+// depositCmd credits the sender with the EBK sent along, which the EVM already
+// moved to the balance of the precompile.
+func (c *wrappedToken) depositCmd(evm *EVM, from common.Address, amount *big.Int) ([]byte, error) {
+	if amount.Sign() == 0 {
+		return nil, nil
+	}
+	evmABI, err := abi.JSON(strings.NewReader(WrappedTokenABI))
+	if err != nil {
+		return nil, errSystemContractAbiError
+	}
+	setWrappedBalance(evm.StateDB, from, new(big.Int).Add(GetWrappedBalance(evm.StateDB, from), amount))
+
+	if err := c.addLog(evm, &evmABI, "Deposit", []common.Hash{from.Hash()}, amount); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// withdrawCmd burns amount of the sender's tokens, paying back the EBK.
+func (c *wrappedToken) withdrawCmd(evm *EVM, from common.Address, amount *big.Int) ([]byte, error) {
+	balance := GetWrappedBalance(evm.StateDB, from)
+	if balance.Cmp(amount) < 0 {
+		return nil, errWrappedTokenBalance
+	}
+	if !evm.CanTransfer(evm.StateDB, types.PrecompliledWrappedToken, amount) {
+		log.Error("Wrapped token isn't backed by its balance", "supply", evm.StateDB.GetBalance(types.PrecompliledWrappedToken), "amount", amount)
+		return nil, ErrInsufficientBalance
+	}
+	evmABI, err := abi.JSON(strings.NewReader(WrappedTokenABI))
+	if err != nil {
+		return nil, errSystemContractAbiError
+	}
+	setWrappedBalance(evm.StateDB, from, balance.Sub(balance, amount))
+	evm.Transfer(evm.StateDB, types.PrecompliledWrappedToken, from, amount)
+
+	if err := c.addLog(evm, &evmABI, "Withdrawal", []common.Hash{from.Hash()}, amount); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// transfer moves amount tokens between two accounts.
+func (c *wrappedToken) transfer(evm *EVM, evmABI *abi.ABI, from common.Address, to common.Address, amount *big.Int) error {
+	balance := GetWrappedBalance(evm.StateDB, from)
+	if balance.Cmp(amount) < 0 {
+		return errWrappedTokenBalance
+	}
+	setWrappedBalance(evm.StateDB, from, balance.Sub(balance, amount))
+	setWrappedBalance(evm.StateDB, to, new(big.Int).Add(GetWrappedBalance(evm.StateDB, to), amount))
+
+	return c.addLog(evm, evmABI, "Transfer", []common.Hash{from.Hash(), to.Hash()}, amount)
+}
+
+// addLog emits a wrapped token event, see systemContract.addLog.
+func (c *wrappedToken) addLog(evm *EVM, evmABI *abi.ABI, name string, topics []common.Hash, args ...interface{}) error {
+	event, ok := evmABI.Events[name]
+	if !ok {
+		return errSystemContractAbiError
+	}
+
+	data, err := event.Inputs.NonIndexed().Pack(args...)
+	if err != nil {
+		log.Trace("WrappedTokenABI failed to pack event", "event", name, "err", err)
+		return errSystemContractError
+	}
+
+	evm.StateDB.AddLog(&types.Log{
+		Address:     types.PrecompliledWrappedToken,
+		Topics:      append([]common.Hash{event.ID()}, topics...),
+		Data:        data,
+		BlockNumber: evm.BlockNumber.Uint64(),
+	})
+
+	return nil
+}
+
+// touchWrappedToken bumps the nonce of the precompile account before its storage
+// is first written, so it isn't deleted as empty when all the EBK are withdrawn.
+func touchWrappedToken(db StateDB) {
+	if db.GetNonce(types.PrecompliledWrappedToken) == 0 {
+		db.SetNonce(types.PrecompliledWrappedToken, 1)
+	}
+}
+
+// wrappedBalanceKey returns the storage slot of the token balance of owner.
+func wrappedBalanceKey(owner common.Address) common.Hash {
+	return crypto.Keccak256Hash(owner.Hash().Bytes(), wrappedTokenBalancesSlot.Bytes())
+}
+
+// wrappedAllowanceKey returns the storage slot of the amount spender may
+// transfer on behalf of owner.
+func wrappedAllowanceKey(owner common.Address, spender common.Address) common.Hash {
+	inner := crypto.Keccak256Hash(owner.Hash().Bytes(), wrappedTokenAllowancesSlot.Bytes())
+	return crypto.Keccak256Hash(spender.Hash().Bytes(), inner.Bytes())
+}
+
+// GetWrappedBalance returns the wrapped token balance of owner.
+func GetWrappedBalance(db StateDB, owner common.Address) *big.Int {
+	return db.GetState(types.PrecompliledWrappedToken, wrappedBalanceKey(owner)).Big()
+}
+
+func setWrappedBalance(db StateDB, owner common.Address, amount *big.Int) {
+	touchWrappedToken(db)
+	db.SetState(types.PrecompliledWrappedToken, wrappedBalanceKey(owner), common.BigToHash(amount))
+}
+
+// GetWrappedAllowance returns the amount of wrapped tokens spender may transfer
+// on behalf of owner.
+func GetWrappedAllowance(db StateDB, owner common.Address, spender common.Address) *big.Int {
+	return db.GetState(types.PrecompliledWrappedToken, wrappedAllowanceKey(owner, spender)).Big()
+}
+
+func setWrappedAllowance(db StateDB, owner common.Address, spender common.Address, amount *big.Int) {
+	touchWrappedToken(db)
+	db.SetState(types.PrecompliledWrappedToken, wrappedAllowanceKey(owner, spender), common.BigToHash(amount))
+}
+
+const WrappedTokenABI = `[
+{
+  "type": "function",
+  "name": "name",
+  "inputs": [],
+  "outputs": [{"name": "", "type": "string"}],
+  "constant": true,
+  "payable": false,
+  "stateMutability": "view"
+},{
+  "type": "function",
+  "name": "symbol",
+  "inputs": [],
+  "outputs": [{"name": "", "type": "string"}],
+  "constant": true,
+  "payable": false,
+  "stateMutability": "view"
+},{
+  "type": "function",
+  "name": "decimals",
+  "inputs": [],
+  "outputs": [{"name": "", "type": "uint8"}],
+  "constant": true,
+  "payable": false,
+  "stateMutability": "view"
+},{
+  "type": "function",
+  "name": "totalSupply",
+  "inputs": [],
+  "outputs": [{"name": "", "type": "uint256"}],
+  "constant": true,
+  "payable": false,
+  "stateMutability": "view"
+},{
+  "type": "function",
+  "name": "balanceOf",
+  "inputs": [{"name": "owner", "type": "address"}],
+  "outputs": [{"name": "", "type": "uint256"}],
+  "constant": true,
+  "payable": false,
+  "stateMutability": "view"
+},{
+  "type": "function",
+  "name": "allowance",
+  "inputs": [{"name": "owner", "type": "address"}, {"name": "spender", "type": "address"}],
+  "outputs": [{"name": "", "type": "uint256"}],
+  "constant": true,
+  "payable": false,
+  "stateMutability": "view"
+},{
+  "type": "function",
+  "name": "transfer",
+  "inputs": [{"name": "to", "type": "address"}, {"name": "amount", "type": "uint256"}],
+  "outputs": [{"name": "", "type": "bool"}],
+  "stateMutability": "nonpayable"
+},{
+  "type": "function",
+  "name": "transferFrom",
+  "inputs": [{"name": "from", "type": "address"}, {"name": "to", "type": "address"}, {"name": "amount", "type": "uint256"}],
+  "outputs": [{"name": "", "type": "bool"}],
+  "stateMutability": "nonpayable"
+},{
+  "type": "function",
+  "name": "approve",
+  "inputs": [{"name": "spender", "type": "address"}, {"name": "amount", "type": "uint256"}],
+  "outputs": [{"name": "", "type": "bool"}],
+  "stateMutability": "nonpayable"
+},{
+  "type": "function",
+  "name": "deposit",
+  "inputs": [],
+  "outputs": [],
+  "payable": true,
+  "stateMutability": "payable"
+},{
+  "type": "function",
+  "name": "withdraw",
+  "inputs": [{"name": "amount", "type": "uint256"}],
+  "outputs": [],
+  "stateMutability": "nonpayable"
+},{
+  "type": "event",
+  "name": "Transfer",
+  "inputs": [
+    {"name": "from", "type": "address", "indexed": true},
+    {"name": "to", "type": "address", "indexed": true},
+    {"name": "amount", "type": "uint256", "indexed": false}
+  ],
+  "anonymous": false
+},{
+  "type": "event",
+  "name": "Approval",
+  "inputs": [
+    {"name": "owner", "type": "address", "indexed": true},
+    {"name": "spender", "type": "address", "indexed": true},
+    {"name": "amount", "type": "uint256", "indexed": false}
+  ],
+  "anonymous": false
+},{
+  "type": "event",
+  "name": "Deposit",
+  "inputs": [
+    {"name": "owner", "type": "address", "indexed": true},
+    {"name": "amount", "type": "uint256", "indexed": false}
+  ],
+  "anonymous": false
+},{
+  "type": "event",
+  "name": "Withdrawal",
+  "inputs": [
+    {"name": "owner", "type": "address", "indexed": true},
+    {"name": "amount", "type": "uint256", "indexed": false}
+  ],
+  "anonymous": false
+}]`
@@ -30,10 +30,12 @@ import (
 	"github.com/ebakus/go-ebakus/common"
 	"github.com/ebakus/go-ebakus/common/hexutil"
 	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/rawdb"
 	"github.com/ebakus/go-ebakus/core/state"
 	"github.com/ebakus/go-ebakus/core/types"
 	"github.com/ebakus/go-ebakus/internal/ethapi"
+	"github.com/ebakus/go-ebakus/miner"
 	"github.com/ebakus/go-ebakus/rlp"
 	"github.com/ebakus/go-ebakus/rpc"
 	"github.com/ebakus/go-ebakus/trie"
@@ -146,6 +148,22 @@ func (api *PrivateMinerAPI) GetHashrate() uint64 {
 	return api.e.miner.HashRate()
 }
 
+// LastBlockTimings returns how long each stage of producing the last locally
+// mined block took.
+func (api *PrivateMinerAPI) LastBlockTimings() (*miner.BlockTimings, error) {
+	timings := api.e.miner.LastBlockTimings()
+	if timings == nil {
+		return nil, errors.New("no block produced yet")
+	}
+	return timings, nil
+}
+
+// Watchdog returns the seconds since the last block got imported from the
+// network and since the local signer last produced one.
+func (api *PrivateMinerAPI) Watchdog() *miner.WatchdogStatus {
+	return api.e.miner.Watchdog()
+}
+
 // NewPrivateAdminAPI creates a new API definition for the full node private
 // admin methods of the Ebakus service.
 func NewPrivateAdminAPI(eth *Ebakus) *PrivateAdminAPI {
@@ -238,6 +256,12 @@ func (api *PrivateAdminAPI) ImportChain(file string) (bool, error) {
 	return true, nil
 }
 
+// SystemAlerts returns the most recent unexpected changes of the system tables
+// detected by the watchdog, oldest first.
+func (api *PrivateAdminAPI) SystemAlerts() []*SystemAlert {
+	return api.eth.systemWatchdog.Alerts()
+}
+
 // PublicDebugAPI is the collection of Ebakus full node APIs exposed
 // over the public debugging endpoint.
 type PublicDebugAPI struct {
@@ -262,6 +286,8 @@ func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error
 	var block *types.Block
 	if blockNr == rpc.LatestBlockNumber {
 		block = api.eth.blockchain.CurrentBlock()
+	} else if blockNr == rpc.SafeBlockNumber || blockNr == rpc.FinalizedBlockNumber {
+		block, _ = api.eth.APIBackend.BlockByNumber(context.Background(), blockNr)
 	} else {
 		block = api.eth.blockchain.GetBlockByNumber(uint64(blockNr))
 	}
@@ -377,10 +403,12 @@ func (api *PrivateDebugAPI) AccountRange(ctx context.Context, start *common.Hash
 			return AccountRangeResult{}, err
 		}
 	} else {
-		_, _, statedb, err = api.computeTxEnv(block.Hash(), len(block.Transactions())-1, 0)
+		var ebakusState ebkdb.State
+		_, _, statedb, ebakusState, err = api.computeTxEnv(block.Hash(), len(block.Transactions())-1, 0)
 		if err != nil {
 			return AccountRangeResult{}, err
 		}
+		ebakusState.Release()
 	}
 
 	trie, err := statedb.Database().OpenTrie(block.Header().Root)
@@ -406,10 +434,12 @@ type storageEntry struct {
 
 // StorageRangeAt returns the storage at the given block height and transaction index.
 func (api *PrivateDebugAPI) StorageRangeAt(ctx context.Context, blockHash common.Hash, txIndex int, contractAddress common.Address, keyStart hexutil.Bytes, maxResult int) (StorageRangeResult, error) {
-	_, _, statedb, err := api.computeTxEnv(blockHash, txIndex, 0)
+	_, _, statedb, ebakusState, err := api.computeTxEnv(blockHash, txIndex, 0)
 	if err != nil {
 		return StorageRangeResult{}, err
 	}
+	ebakusState.Release()
+
 	st := statedb.StorageTrie(contractAddress)
 	if st == nil {
 		return StorageRangeResult{}, fmt.Errorf("account %x doesn't exist", contractAddress)
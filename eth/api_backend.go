@@ -20,14 +20,15 @@ import (
 	"context"
 	"errors"
 	"math/big"
-
-	"github.com/ebakus/ebakusdb"
+	"time"
 
 	"github.com/ebakus/go-ebakus/accounts"
 	"github.com/ebakus/go-ebakus/common"
 	"github.com/ebakus/go-ebakus/common/math"
+	"github.com/ebakus/go-ebakus/consensus/dpos"
 	"github.com/ebakus/go-ebakus/core"
 	"github.com/ebakus/go-ebakus/core/bloombits"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/rawdb"
 	"github.com/ebakus/go-ebakus/core/state"
 	"github.com/ebakus/go-ebakus/core/types"
@@ -56,9 +57,16 @@ func (b *EthAPIBackend) CurrentBlock() *types.Block {
 	return b.eth.blockchain.CurrentBlock()
 }
 
-func (b *EthAPIBackend) SetHead(number uint64) {
+func (b *EthAPIBackend) SetHead(number uint64) error {
+	if err := b.eth.blockchain.CheckRewind(number); err != nil {
+		return err
+	}
 	b.eth.protocolManager.downloader.Cancel()
-	b.eth.blockchain.SetHead(number)
+	if err := b.eth.blockchain.SetHead(number); err != nil {
+		return err
+	}
+	b.eth.bloomIndexer.Rewind(number)
+	return nil
 }
 
 func (b *EthAPIBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
@@ -71,6 +79,9 @@ func (b *EthAPIBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumb
 	if number == rpc.LatestBlockNumber {
 		return b.eth.blockchain.CurrentBlock().Header(), nil
 	}
+	if number == rpc.SafeBlockNumber || number == rpc.FinalizedBlockNumber {
+		return dpos.LastIrreversibleHeader(b.eth.blockchain, b.eth.blockchain.CurrentHeader()), nil
+	}
 	return b.eth.blockchain.GetHeaderByNumber(uint64(number)), nil
 }
 
@@ -105,6 +116,13 @@ func (b *EthAPIBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumbe
 	if number == rpc.LatestBlockNumber {
 		return b.eth.blockchain.CurrentBlock(), nil
 	}
+	if number == rpc.SafeBlockNumber || number == rpc.FinalizedBlockNumber {
+		header := dpos.LastIrreversibleHeader(b.eth.blockchain, b.eth.blockchain.CurrentHeader())
+		if header == nil {
+			return nil, nil
+		}
+		return b.eth.blockchain.GetBlock(header.Hash(), header.Number.Uint64()), nil
+	}
 	return b.eth.blockchain.GetBlockByNumber(uint64(number)), nil
 }
 
@@ -173,7 +191,7 @@ func (b *EthAPIBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockN
 	return nil, nil, errors.New("invalid arguments; neither block nor hash specified")
 }
 
-func (b *EthAPIBackend) EbakusStateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*ebakusdb.Snapshot, *types.Header, error) {
+func (b *EthAPIBackend) EbakusStateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (ebkdb.State, *types.Header, error) {
 	// Pending state is only known by the miner
 	if blockNr == rpc.PendingBlockNumber {
 		block, snapshot := b.eth.miner.PendingEbakusState()
@@ -185,11 +203,11 @@ func (b *EthAPIBackend) EbakusStateAndHeaderByNumber(ctx context.Context, blockN
 		return nil, nil, err
 	}
 
-	ebakusState, err := b.eth.BlockChain().EbakusStateAt(header.Hash(), uint64(blockNr))
+	ebakusState, err := b.eth.BlockChain().ReadEbakusStateAt(header.Hash(), header.Number.Uint64())
 	return ebakusState, header, err
 }
 
-func (b *EthAPIBackend) EbakusStateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*ebakusdb.Snapshot, *types.Header, error) {
+func (b *EthAPIBackend) EbakusStateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (ebkdb.State, *types.Header, error) {
 	if blockNr, ok := blockNrOrHash.Number(); ok {
 		return b.EbakusStateAndHeaderByNumber(ctx, blockNr)
 	}
@@ -204,7 +222,7 @@ func (b *EthAPIBackend) EbakusStateAndHeaderByNumberOrHash(ctx context.Context,
 		if blockNrOrHash.RequireCanonical && b.eth.blockchain.GetCanonicalHash(header.Number.Uint64()) != hash {
 			return nil, nil, errors.New("hash is not currently canonical")
 		}
-		ebakusState, err := b.eth.BlockChain().EbakusStateAt(header.Hash(), header.Number.Uint64())
+		ebakusState, err := b.eth.BlockChain().ReadEbakusStateAt(header.Hash(), header.Number.Uint64())
 		return ebakusState, header, err
 	}
 	return nil, nil, errors.New("invalid arguments; neither block nor hash specified")
@@ -230,7 +248,7 @@ func (b *EthAPIBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*typ
 	return logs, nil
 }
 
-func (b *EthAPIBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, ebakusState *ebakusdb.Snapshot, header *types.Header) (*vm.EVM, func() error, error) {
+func (b *EthAPIBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, ebakusState ebkdb.State, header *types.Header) (*vm.EVM, func() error, error) {
 	state.SetBalance(msg.From(), math.MaxBig256)
 	vmError := func() error { return nil }
 
@@ -311,11 +329,15 @@ func (b *EthAPIBackend) SuggestPrice(ctx context.Context) (*float64, error) {
 	return b.gpo.SuggestPrice(ctx)
 }
 
+func (b *EthAPIBackend) SuggestVirtualDifficulty(ctx context.Context) (float64, error) {
+	return b.gpo.SuggestVirtualDifficulty(ctx)
+}
+
 func (b *EthAPIBackend) ChainDb() ethdb.Database {
 	return b.eth.ChainDb()
 }
 
-func (b *EthAPIBackend) EbakusDb() *ebakusdb.DB {
+func (b *EthAPIBackend) EbakusDb() *ebkdb.DB {
 	return b.eth.EbakusDb()
 }
 
@@ -335,12 +357,28 @@ func (b *EthAPIBackend) RPCGasCap() *big.Int {
 	return b.eth.config.RPCGasCap
 }
 
+func (b *EthAPIBackend) RPCEVMTimeout() time.Duration {
+	return b.eth.config.RPCEVMTimeout
+}
+
+func (b *EthAPIBackend) RPCDBRowsCap() uint64 {
+	return b.eth.config.RPCDBRowsCap
+}
+
+func (b *EthAPIBackend) RPCLogsMaxBlocks() uint64 {
+	return b.eth.config.RPCLogsMaxBlocks
+}
+
 func (b *EthAPIBackend) MinGasPrice() float64 {
 	return b.eth.config.Miner.GasPrice
 }
 
-func (b *EthAPIBackend) EbakusdbMaxActiveIterators() uint64 {
-	return b.eth.config.EbakusdbMaxActiveIterators
+func (b *EthAPIBackend) EbakusdbQueryCache() int {
+	return b.eth.config.EbakusdbQueryCache
+}
+
+func (b *EthAPIBackend) EbakusdbMaxIteratorLag() uint64 {
+	return b.eth.config.EbakusdbMaxIteratorLag
 }
 
 func (b *EthAPIBackend) BloomStatus() (uint64, uint64) {
@@ -29,11 +29,13 @@ import (
 	"github.com/ebakus/go-ebakus/common"
 	"github.com/ebakus/go-ebakus/common/hexutil"
 	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/rawdb"
 	"github.com/ebakus/go-ebakus/core/state"
 	"github.com/ebakus/go-ebakus/core/types"
 	"github.com/ebakus/go-ebakus/core/vm"
 	"github.com/ebakus/go-ebakus/eth/tracers"
+	"github.com/ebakus/go-ebakus/internal/ethapi"
 	"github.com/ebakus/go-ebakus/log"
 	"github.com/ebakus/go-ebakus/rlp"
 	"github.com/ebakus/go-ebakus/rpc"
@@ -92,8 +94,9 @@ type blockTraceResult struct {
 // txTraceTask represents a single transaction trace task when an entire block
 // is being traced.
 type txTraceTask struct {
-	statedb *state.StateDB // Intermediate state prepped for tracing
-	index   int            // Transaction offset in the block
+	statedb     *state.StateDB // Intermediate state prepped for tracing
+	ebakusState ebkdb.State    // Intermediate ebakus state prepped for tracing
+	index       int            // Transaction offset in the block
 }
 
 // TraceChain returns the structured logs created during the execution of EVM
@@ -107,6 +110,8 @@ func (api *PrivateDebugAPI) TraceChain(ctx context.Context, start, end rpc.Block
 		from = api.eth.miner.PendingBlock()
 	case rpc.LatestBlockNumber:
 		from = api.eth.blockchain.CurrentBlock()
+	case rpc.SafeBlockNumber, rpc.FinalizedBlockNumber:
+		from, _ = api.eth.APIBackend.BlockByNumber(ctx, start)
 	default:
 		from = api.eth.blockchain.GetBlockByNumber(uint64(start))
 	}
@@ -115,6 +120,8 @@ func (api *PrivateDebugAPI) TraceChain(ctx context.Context, start, end rpc.Block
 		to = api.eth.miner.PendingBlock()
 	case rpc.LatestBlockNumber:
 		to = api.eth.blockchain.CurrentBlock()
+	case rpc.SafeBlockNumber, rpc.FinalizedBlockNumber:
+		to, _ = api.eth.APIBackend.BlockByNumber(ctx, end)
 	default:
 		to = api.eth.blockchain.GetBlockByNumber(uint64(end))
 	}
@@ -200,12 +207,20 @@ func (api *PrivateDebugAPI) traceChain(ctx context.Context, start, end *types.Bl
 			for task := range tasks {
 				signer := types.MakeSigner(api.eth.blockchain.Config())
 
+				ebakusState, err := api.eth.blockchain.ReadEbakusStateAt(task.block.ParentHash(), task.block.NumberU64()-1)
+				if err != nil {
+					log.Warn("Tracing failed", "block", task.block.NumberU64(), "err", err)
+				}
 				// Trace all the transactions contained within
 				for i, tx := range task.block.Transactions() {
+					if ebakusState == nil {
+						task.results[i] = &txTraceResult{Error: err.Error()}
+						break
+					}
 					msg, _ := tx.AsMessage(signer)
 					vmctx := core.NewEVMContext(msg, task.block.Header(), api.eth.blockchain, nil)
 
-					res, err := api.traceTx(ctx, msg, vmctx, task.statedb, config)
+					res, err := api.traceTx(ctx, msg, vmctx, task.statedb, ebakusState, config)
 					if err != nil {
 						task.results[i] = &txTraceResult{Error: err.Error()}
 						log.Warn("Tracing failed", "hash", tx.Hash(), "block", task.block.NumberU64(), "err", err)
@@ -215,6 +230,9 @@ func (api *PrivateDebugAPI) traceChain(ctx context.Context, start, end *types.Bl
 					task.statedb.Finalise(api.eth.blockchain.Config().IsEIP158(task.block.Number()))
 					task.results[i] = &txTraceResult{Result: res}
 				}
+				if ebakusState != nil {
+					ebakusState.Release()
+				}
 				// Stream the result back to the user or abort on teardown
 				select {
 				case results <- task:
@@ -366,6 +384,8 @@ func (api *PrivateDebugAPI) TraceBlockByNumber(ctx context.Context, number rpc.B
 		block = api.eth.miner.PendingBlock()
 	case rpc.LatestBlockNumber:
 		block = api.eth.blockchain.CurrentBlock()
+	case rpc.SafeBlockNumber, rpc.FinalizedBlockNumber:
+		block, _ = api.eth.APIBackend.BlockByNumber(ctx, number)
 	default:
 		block = api.eth.blockchain.GetBlockByNumber(uint64(number))
 	}
@@ -455,17 +475,21 @@ func (api *PrivateDebugAPI) traceBlock(ctx context.Context, block *types.Block,
 	if parent == nil {
 		return nil, fmt.Errorf("parent %#x not found", block.ParentHash())
 	}
-	/*
-		reexec := defaultTraceReexec
-		if config != nil && config.Reexec != nil {
-			reexec = *config.Reexec
-		}
+	reexec := defaultTraceReexec
+	if config != nil && config.Reexec != nil {
+		reexec = *config.Reexec
+	}
+	statedb, err := api.computeStateDB(parent, reexec)
+	if err != nil {
+		return nil, err
+	}
+	// The precompiles run against the ebakus state, which can't be regenerated
+	ebakusState, err := api.eth.blockchain.ReadEbakusStateAt(parent.Hash(), parent.NumberU64())
+	if err != nil {
+		return nil, fmt.Errorf("ebakus state of block #%d unavailable: %v", parent.NumberU64(), err)
+	}
+	defer ebakusState.Release()
 
-			statedb, err := api.computeStateDB(parent, reexec)
-			if err != nil {
-				return nil, err
-			}
-	*/
 	// Execute all the transaction contained within the block concurrently
 	var (
 		signer = types.MakeSigner(api.eth.blockchain.Config())
@@ -490,7 +514,8 @@ func (api *PrivateDebugAPI) traceBlock(ctx context.Context, block *types.Block,
 				msg, _ := txs[task.index].AsMessage(signer)
 				vmctx := core.NewEVMContext(msg, block.Header(), api.eth.blockchain, nil)
 
-				res, err := api.traceTx(ctx, msg, vmctx, task.statedb, config)
+				res, err := api.traceTx(ctx, msg, vmctx, task.statedb, task.ebakusState, config)
+				task.ebakusState.Release()
 				if err != nil {
 					results[task.index] = &txTraceResult{Error: err.Error()}
 					continue
@@ -501,27 +526,23 @@ func (api *PrivateDebugAPI) traceBlock(ctx context.Context, block *types.Block,
 	}
 	// Feed the transactions into the tracers and return
 	var failed error
-	/*
-		for i, tx := range txs {
-			// Send the trace task over for execution
-			jobs <- &txTraceTask{statedb: statedb.Copy(), index: i}
-
-
-				// Generate the next state snapshot fast without tracing
-				msg, _ := tx.AsMessage(signer)
-				vmctx := core.NewEVMContext(msg, block.Header(), api.eth.blockchain, nil)
+	for i, tx := range txs {
+		// Send the trace task over for execution
+		jobs <- &txTraceTask{statedb: statedb.Copy(), ebakusState: ebakusState.Snapshot(), index: i}
 
+		// Generate the next state snapshot fast without tracing
+		msg, _ := tx.AsMessage(signer)
+		vmctx := core.NewEVMContext(msg, block.Header(), api.eth.blockchain, nil)
 
-					vmenv := vm.NewEVM(vmctx, statedb, api.eth.blockchain.Config(), vm.Config{})
-					if _, _, _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas())); err != nil {
-						failed = err
-						break
-					}
-
-			// Finalize the state so any modifications are written to the trie
-			statedb.Finalise(true)
+		vmenv := vm.NewEVM(vmctx, statedb, ebakusState, api.eth.blockchain.Config(), vm.Config{})
+		vmenv.SetLowPriority(true)
+		if _, _, _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas())); err != nil {
+			failed = err
+			break
 		}
-	*/
+		// Finalize the state so any modifications are written to the trie
+		statedb.Finalise(true)
+	}
 	close(jobs)
 	pend.Wait()
 
@@ -749,18 +770,20 @@ func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, hash common.Ha
 	if config != nil && config.Reexec != nil {
 		reexec = *config.Reexec
 	}
-	msg, vmctx, statedb, err := api.computeTxEnv(blockHash, int(index), reexec)
+	msg, vmctx, statedb, ebakusState, err := api.computeTxEnv(blockHash, int(index), reexec)
 	if err != nil {
 		return nil, err
 	}
+	defer ebakusState.Release()
+
 	// Trace the transaction and return
-	return api.traceTx(ctx, msg, vmctx, statedb, config)
+	return api.traceTx(ctx, msg, vmctx, statedb, ebakusState, config)
 }
 
 // traceTx configures a new tracer according to the provided configuration, and
 // executes the given message in the provided environment. The return value will
 // be tracer dependent.
-func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, vmctx vm.Context, statedb *state.StateDB, config *TraceConfig) (interface{}, error) {
+func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, vmctx vm.Context, statedb *state.StateDB, ebakusState ebkdb.State, config *TraceConfig) (interface{}, error) {
 	// Assemble the structured logger or the JavaScript tracer
 	var (
 		tracer vm.Tracer
@@ -793,53 +816,56 @@ func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, v
 	default:
 		tracer = vm.NewStructLogger(config.LogConfig)
 	}
-	/*
-			// Run the transaction with tracing enabled.
-			vmenv := vm.NewEVM(vmctx, statedb, api.eth.blockchain.Config(), vm.Config{Debug: true, Tracer: tracer})
-
-			ret, gas, failed, err := core.ApplyMessage(vmenv, message, new(core.GasPool).AddGas(message.Gas()))
-			if err != nil {
-				return nil, fmt.Errorf("tracing failed: %v", err)
-			}
+	// Run the transaction with tracing enabled.
+	vmenv := vm.NewEVM(vmctx, statedb, ebakusState, api.eth.blockchain.Config(), vm.Config{Debug: true, Tracer: tracer})
+	vmenv.SetLowPriority(true)
 
-		// Depending on the tracer type, format and return the output
-		switch tracer := tracer.(type) {
-		case *vm.StructLogger:
-			return &ethapi.ExecutionResult{
-				Gas:         gas,
-				Failed:      failed,
-				ReturnValue: fmt.Sprintf("%x", ret),
-				StructLogs:  ethapi.FormatLogs(tracer.StructLogs()),
-			}, nil
-
-		case *tracers.Tracer:
-			return tracer.GetResult()
+	ret, gas, failed, err := core.ApplyMessage(vmenv, message, new(core.GasPool).AddGas(message.Gas()))
+	if err != nil {
+		return nil, fmt.Errorf("tracing failed: %v", err)
+	}
+	// Depending on the tracer type, format and return the output
+	switch tracer := tracer.(type) {
+	case *vm.StructLogger:
+		return &ethapi.ExecutionResult{
+			Gas:         gas,
+			Failed:      failed,
+			ReturnValue: fmt.Sprintf("%x", ret),
+			StructLogs:  ethapi.FormatLogs(tracer.StructLogs()),
+			Precompiles: tracer.PrecompileFrames(),
+		}, nil
+
+	case *tracers.Tracer:
+		return tracer.GetResult()
 
-		default:
-			panic(fmt.Sprintf("bad tracer type %T", tracer))
-		}
-	*/
-	return nil, nil
+	default:
+		panic(fmt.Sprintf("bad tracer type %T", tracer))
+	}
 }
 
-// computeTxEnv returns the execution environment of a certain transaction.
-func (api *PrivateDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int, reexec uint64) (core.Message, vm.Context, *state.StateDB, error) {
+// computeTxEnv returns the execution environment of a certain transaction. The
+// returned ebakus state has to be released by the caller.
+func (api *PrivateDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int, reexec uint64) (core.Message, vm.Context, *state.StateDB, ebkdb.State, error) {
 	// Create the parent state database
 	block := api.eth.blockchain.GetBlockByHash(blockHash)
 	if block == nil {
-		return nil, vm.Context{}, nil, fmt.Errorf("block %#x not found", blockHash)
+		return nil, vm.Context{}, nil, nil, fmt.Errorf("block %#x not found", blockHash)
 	}
 	parent := api.eth.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
 	if parent == nil {
-		return nil, vm.Context{}, nil, fmt.Errorf("parent %#x not found", block.ParentHash())
+		return nil, vm.Context{}, nil, nil, fmt.Errorf("parent %#x not found", block.ParentHash())
 	}
 	statedb, err := api.computeStateDB(parent, reexec)
 	if err != nil {
-		return nil, vm.Context{}, nil, err
+		return nil, vm.Context{}, nil, nil, err
+	}
+	// The precompiles run against the ebakus state, which can't be regenerated
+	ebakusState, err := api.eth.blockchain.ReadEbakusStateAt(parent.Hash(), parent.NumberU64())
+	if err != nil {
+		return nil, vm.Context{}, nil, nil, fmt.Errorf("ebakus state of block #%d unavailable: %v", parent.NumberU64(), err)
 	}
-
 	if txIndex == 0 && len(block.Transactions()) == 0 {
-		return nil, vm.Context{}, statedb, nil
+		return nil, vm.Context{}, statedb, ebakusState, nil
 	}
 
 	// Recompute transactions up to the target index.
@@ -850,18 +876,19 @@ func (api *PrivateDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int, ree
 		msg, _ := tx.AsMessage(signer)
 		context := core.NewEVMContext(msg, block.Header(), api.eth.blockchain, nil)
 		if idx == txIndex {
-			return msg, context, statedb, nil
+			return msg, context, statedb, ebakusState, nil
+		}
+		// Not yet the searched for transaction, execute on top of the current state
+		vmenv := vm.NewEVM(context, statedb, ebakusState, api.eth.blockchain.Config(), vm.Config{})
+		vmenv.SetLowPriority(true)
+		if _, _, _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas())); err != nil {
+			ebakusState.Release()
+			return nil, vm.Context{}, nil, nil, fmt.Errorf("tx %x failed: %v", tx.Hash(), err)
 		}
-		/*
-			// Not yet the searched for transaction, execute on top of the current state
-			vmenv := vm.NewEVM(context, statedb, api.eth.blockchain.Config(), vm.Config{})
-			if _, _, _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas())); err != nil {
-				return nil, vm.Context{}, nil, fmt.Errorf("tx %x failed: %v", tx.Hash(), err)
-			}
-		*/
 		// Ensure any modifications are committed to the state
 		// Only delete empty objects if EIP158/161 (a.k.a Spurious Dragon) is in effect
 		statedb.Finalise(api.eth.blockchain.Config().IsEIP158(block.Number()))
 	}
-	return nil, vm.Context{}, nil, fmt.Errorf("transaction index %d out of range for block %#x", txIndex, blockHash)
+	ebakusState.Release()
+	return nil, vm.Context{}, nil, nil, fmt.Errorf("transaction index %d out of range for block %#x", txIndex, blockHash)
 }
@@ -24,8 +24,8 @@ import (
 	"sync"
 	"sync/atomic"
 
-	"github.com/ebakus/ebakusdb"
 	"github.com/ebakus/go-ebakus/consensus/dpos"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 
 	"github.com/ebakus/go-ebakus/accounts"
 	"github.com/ebakus/go-ebakus/accounts/abi/bind"
@@ -74,10 +74,12 @@ type Ebakus struct {
 	blockchain      *core.BlockChain
 	protocolManager *ProtocolManager
 	lesServer       LesServer
+	delegateDialer  *delegateDialer
+	systemWatchdog  *systemWatchdog
 
 	// DB interfaces
 	chainDb ethdb.Database // Block chain database
-	stateDb *ebakusdb.DB   // State database
+	stateDb *ebkdb.DB      // State database
 
 	eventMux       *event.TypeMux
 	engine         consensus.Engine
@@ -125,6 +127,9 @@ func New(ctx *node.ServiceContext, config *Config) (*Ebakus, error) {
 		log.Warn("Sanitizing invalid miner gas price", "provided", config.Miner.GasPrice, "updated", DefaultConfig.Miner.GasPrice)
 		config.Miner.GasPrice = DefaultConfig.Miner.GasPrice
 	}
+	if config.EbakusdbMaxActiveIterators != 0 {
+		log.Warn("Ignoring deprecated EbakusdbMaxActiveIterators, idle RPC iterators are released instead", "provided", config.EbakusdbMaxActiveIterators)
+	}
 	if config.NoPruning && config.TrieDirtyCache > 0 {
 		config.TrieCleanCache += config.TrieDirtyCache
 		config.TrieDirtyCache = 0
@@ -193,6 +198,17 @@ func New(ctx *node.ServiceContext, config *Config) (*Ebakus, error) {
 			TrieDirtyLimit:      config.TrieDirtyCache,
 			TrieDirtyDisabled:   config.NoPruning,
 			TrieTimeLimit:       config.TrieTimeout,
+
+			ArchiveContracts:   config.ArchiveContracts,
+			SnapshotRetention:  config.SnapshotRetention,
+			SnapshotCheckpoint: config.SnapshotCheckpoint,
+
+			ReceiptRetention: config.ReceiptRetention,
+			ReceiptContracts: config.ReceiptContracts,
+
+			ParallelWorkers: config.ParallelWorkers,
+
+			SchemaDryRun: config.SchemaDryRun,
 		}
 	)
 	eth.blockchain, err = core.NewBlockChain(chainDb, stateDb, cacheConfig, chainConfig, eth.engine, vmConfig, eth.shouldPreserve)
@@ -206,6 +222,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Ebakus, error) {
 		rawdb.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
 	eth.bloomIndexer.Start(eth.blockchain)
+	eth.systemWatchdog = newSystemWatchdog(eth.blockchain)
 
 	if chainConfig.DPOS != nil {
 		engine.(*dpos.DPOS).SetBlockchain(eth.blockchain)
@@ -214,6 +231,9 @@ func New(ctx *node.ServiceContext, config *Config) (*Ebakus, error) {
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
 	}
+	if config.TxPool.QueueJournal != "" {
+		config.TxPool.QueueJournal = ctx.ResolvePath(config.TxPool.QueueJournal)
+	}
 	eth.txPool = core.NewTxPool(config.TxPool, chainConfig, eth.blockchain)
 
 	// Permit the downloader to use the trie cache allowance during fast sync
@@ -252,7 +272,7 @@ func makeExtraData(extra []byte) []byte {
 }
 
 // CreateEbakusDB creates the ebakus state db
-func CreateEbakusDB(ctx *node.ServiceContext, config *Config, name string) (*ebakusdb.DB, error) {
+func CreateEbakusDB(ctx *node.ServiceContext, config *Config, name string) (*ebkdb.DB, error) {
 	db, err := ctx.OpenEbakusDatabase(name, config.DatabaseCache, config.DatabaseHandles)
 	if err != nil {
 		return nil, err
@@ -262,7 +282,7 @@ func CreateEbakusDB(ctx *node.ServiceContext, config *Config, name string) (*eba
 }
 
 // CreateConsensusEngine creates the required type of consensus engine instance for an Ebakus service
-func CreateConsensusEngine(ctx *node.ServiceContext, config *params.DPOSConfig, chainConfig *params.ChainConfig, db ethdb.Database, ebakusDb *ebakusdb.DB, genesis *core.Genesis) consensus.Engine {
+func CreateConsensusEngine(ctx *node.ServiceContext, config *params.DPOSConfig, chainConfig *params.ChainConfig, db ethdb.Database, ebakusDb *ebkdb.DB, genesis *core.Genesis) consensus.Engine {
 	return dpos.New(chainConfig.DPOS, db, ebakusDb, genesis)
 }
 
@@ -491,7 +511,7 @@ func (s *Ebakus) TxPool() *core.TxPool               { return s.txPool }
 func (s *Ebakus) EventMux() *event.TypeMux           { return s.eventMux }
 func (s *Ebakus) Engine() consensus.Engine           { return s.engine }
 func (s *Ebakus) ChainDb() ethdb.Database            { return s.chainDb }
-func (s *Ebakus) EbakusDb() *ebakusdb.DB             { return s.stateDb }
+func (s *Ebakus) EbakusDb() *ebkdb.DB                { return s.stateDb }
 func (s *Ebakus) IsListening() bool                  { return true } // Always listening
 func (s *Ebakus) EthVersion() int                    { return int(ProtocolVersions[0]) }
 func (s *Ebakus) NetVersion() uint64                 { return s.networkID }
@@ -537,12 +557,24 @@ func (s *Ebakus) Start(srvr *p2p.Server) error {
 	if s.lesServer != nil {
 		s.lesServer.Start(srvr)
 	}
+	// Watch the system tables for changes no transaction accounts for
+	s.systemWatchdog.start()
+
+	// Stay connected to the block producers if they announce their enodes
+	if config := s.blockchain.Config().DPOS; config != nil && !s.config.NoDelegateDial {
+		s.delegateDialer = newDelegateDialer(srvr, s.blockchain, config)
+		s.delegateDialer.start()
+	}
 	return nil
 }
 
 // Stop implements node.Service, terminating all internal goroutines used by the
 // Ebakus protocol.
 func (s *Ebakus) Stop() error {
+	if s.delegateDialer != nil {
+		s.delegateDialer.stop()
+	}
+	s.systemWatchdog.stop()
 	s.bloomIndexer.Close()
 	s.blockchain.Stop()
 	s.engine.Close()
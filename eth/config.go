@@ -33,21 +33,25 @@ import (
 
 // DefaultConfig contains default settings for use on the Ebakus main net.
 var DefaultConfig = Config{
-	SyncMode:                   downloader.FullSync,
-	DPOS:                       *params.MainnetDPOSConfig,
-	NetworkId:                  params.MainnetChainConfig.ChainID.Uint64(),
-	LightPeers:                 100,
-	UltraLightFraction:         75,
-	DatabaseCache:              768,
-	TrieCleanCache:             256,
-	TrieDirtyCache:             256,
-	TrieTimeout:                60 * time.Minute,
-	EbakusdbMaxActiveIterators: 1000,
+	SyncMode:           downloader.FullSync,
+	DPOS:               *params.MainnetDPOSConfig,
+	NetworkId:          params.MainnetChainConfig.ChainID.Uint64(),
+	LightPeers:         100,
+	UltraLightFraction: 75,
+	DatabaseCache:      768,
+	TrieCleanCache:     256,
+	TrieDirtyCache:     256,
+	TrieTimeout:        60 * time.Minute,
+	SnapshotRetention:  86400,
+	SnapshotCheckpoint: 3600,
+	EbakusdbQueryCache: 1024,
+	RPCEVMTimeout:      5 * time.Second,
 	Miner: miner.Config{
-		GasFloor: 80000000,
-		GasCeil:  160000000,
-		GasPrice: types.MinimumTargetDifficulty,
-		Recommit: 3 * time.Second,
+		GasFloor:  80000000,
+		GasCeil:   160000000,
+		GasWindow: 64,
+		GasPrice:  types.MinimumTargetDifficulty,
+		Recommit:  3 * time.Second,
 	},
 	TxPool: core.DefaultTxPoolConfig,
 	GPO: gasprice.Config{
@@ -79,6 +83,20 @@ type Config struct {
 	NoPruning  bool // Whether to disable pruning and flush everything to disk
 	NoPrefetch bool // Whether to disable prefetching and only load state on demand
 
+	ParallelWorkers int // Number of workers executing the disjoint transactions of blocks in parallel (< 2 = serial)
+
+	SchemaDryRun bool `toml:"-"` // Whether to only check the pending system schema migrations, exiting afterwards
+
+	ArchiveContracts []common.Address `toml:",omitempty"` // Contracts to retain historical ebakusdb state for, pruning the rest (nil = retain all)
+
+	SnapshotRetention  uint64 // Number of recent blocks to retain the ebakusdb snapshots of (0 = retain all)
+	SnapshotCheckpoint uint64 // Interval of the blocks whose ebakusdb snapshots are retained past the window (0 = none)
+
+	ReceiptRetention uint64           // Number of recent blocks to retain the receipts and logs of (0 = retain all)
+	ReceiptContracts []common.Address `toml:",omitempty"` // Contracts to retain the receipts and logs of past the retention window
+
+	NoDelegateDial bool // Whether to disable staying connected to the announced enodes of the block producers
+
 	// Whitelist of required block number -> hash values to accept
 	Whitelist map[uint64]common.Hash `toml:"-"`
 
@@ -88,10 +106,15 @@ type Config struct {
 	LightEgress  int `toml:",omitempty"` // Outgoing bandwidth limit for light servers
 	LightPeers   int `toml:",omitempty"` // Maximum number of LES client peers
 
+	// Wallet sync options
+	WalletAddresses []common.Address `toml:",omitempty"` // Addresses whose stake state the light client syncs
+
 	// Ultra Light client options
-	UltraLightServers      []string `toml:",omitempty"` // List of trusted ultra light servers
-	UltraLightFraction     int      `toml:",omitempty"` // Percentage of trusted servers to accept an announcement
-	UltraLightOnlyAnnounce bool     `toml:",omitempty"` // Whether to only announce headers, or also serve them
+	UltraLightServers      []string      `toml:",omitempty"` // List of trusted ultra light servers
+	UltraLightFraction     int           `toml:",omitempty"` // Percentage of trusted servers to accept an announcement
+	UltraLightOnlyAnnounce bool          `toml:",omitempty"` // Whether to only announce headers, or also serve them
+	UltraLightSkipList     uint64        `toml:",omitempty"` // Verify the seal of every Nth header and of delegate changes (0 = verify none)
+	UltraLightBatch        time.Duration `toml:",omitempty"` // Interval to coalesce server announcements over (0 = process immediately)
 
 	// Database options
 	SkipBcVersionCheck bool `toml:"-"`
@@ -103,7 +126,9 @@ type Config struct {
 	TrieDirtyCache int
 	TrieTimeout    time.Duration
 
-	EbakusdbMaxActiveIterators uint64 // Maximum number of ebakusDb iterators to retain in memory for RPC APIs
+	EbakusdbMaxActiveIterators uint64 // Deprecated and ignored, kept for the existing config files to load
+	EbakusdbQueryCache         int    // Number of rows returned by db RPC queries to cache (0 = disabled)
+	EbakusdbMaxIteratorLag     uint64 // Blocks an RPC iterator's snapshot may fall behind the head before failing (0 = unlimited)
 
 	// Mining options
 	Miner miner.Config
@@ -132,6 +157,16 @@ type Config struct {
 	// RPCGasCap is the global gas cap for eth-call variants.
 	RPCGasCap *big.Int `toml:",omitempty"`
 
+	// RPCEVMTimeout is the global timeout for eth-call variants.
+	RPCEVMTimeout time.Duration `toml:",omitempty"`
+
+	// RPCDBRowsCap is the global cap of ebakus db rows read by eth-call variants.
+	RPCDBRowsCap uint64 `toml:",omitempty"`
+
+	// RPCLogsMaxBlocks is the global cap of the blocks searched by eth_getLogs,
+	// and the number of blocks searched per eth_getLogsPage call.
+	RPCLogsMaxBlocks uint64 `toml:",omitempty"`
+
 	// Checkpoint is a hardcoded checkpoint which can be nil.
 	Checkpoint *params.TrustedCheckpoint `toml:",omitempty"`
 
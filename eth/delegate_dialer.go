@@ -0,0 +1,160 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/core/vm"
+	"github.com/ebakus/go-ebakus/log"
+	"github.com/ebakus/go-ebakus/metrics"
+	"github.com/ebakus/go-ebakus/p2p"
+	"github.com/ebakus/go-ebakus/p2p/enode"
+	"github.com/ebakus/go-ebakus/params"
+)
+
+// delegateRefreshInterval is the interval the elected witnesses are checked
+// for changes of the producers to stay connected to.
+const delegateRefreshInterval = 30 * time.Second
+
+var delegatePeersGauge = metrics.NewRegisteredGauge("eth/delegates/dialed", nil)
+
+// delegateDialer keeps the node connected to the block producers, resolving
+// the enodes announced by the elected witnesses and dialing them as trusted
+// static peers. Static dials are scheduled ahead of the dynamic ones and
+// trusted peers aren't subject to the peer limit, so blocks propagate directly
+// between the producers and this node.
+type delegateDialer struct {
+	srv    *p2p.Server
+	chain  *core.BlockChain
+	config *params.DPOSConfig
+
+	configured map[enode.ID]bool        // Static and trusted nodes of the user, left untouched
+	dialed     map[enode.ID]*enode.Node // Producers added to the server by the dialer
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newDelegateDialer creates a dialer of the producers of a dpos chain.
+func newDelegateDialer(srv *p2p.Server, chain *core.BlockChain, config *params.DPOSConfig) *delegateDialer {
+	d := &delegateDialer{
+		srv:        srv,
+		chain:      chain,
+		config:     config,
+		configured: make(map[enode.ID]bool),
+		dialed:     make(map[enode.ID]*enode.Node),
+		quit:       make(chan struct{}),
+	}
+	for _, n := range srv.StaticNodes {
+		d.configured[n.ID()] = true
+	}
+	for _, n := range srv.TrustedNodes {
+		d.configured[n.ID()] = true
+	}
+	return d
+}
+
+// start launches the refresh loop of the dialer.
+func (d *delegateDialer) start() {
+	d.wg.Add(1)
+	go d.loop()
+}
+
+// stop terminates the dialer, dropping the producers it added.
+func (d *delegateDialer) stop() {
+	close(d.quit)
+	d.wg.Wait()
+
+	for id, node := range d.dialed {
+		d.drop(id, node)
+	}
+}
+
+func (d *delegateDialer) loop() {
+	defer d.wg.Done()
+
+	refresh := time.NewTicker(delegateRefreshInterval)
+	defer refresh.Stop()
+
+	for {
+		d.refresh()
+
+		select {
+		case <-refresh.C:
+		case <-d.quit:
+			return
+		}
+	}
+}
+
+// refresh dials the announced enodes of the currently elected witnesses, and
+// drops the ones of the witnesses no longer elected.
+func (d *delegateDialer) refresh() {
+	state, err := d.chain.EbakusState()
+	if err != nil {
+		log.Debug("Failed to open state for delegate dialing", "err", err)
+		return
+	}
+	defer state.Release()
+
+	var (
+		self      = d.srv.Self().ID()
+		witnesses = vm.DelegateVotingGetDelegates(state, d.config.DelegateCount+d.config.BonusDelegateCount)
+		wanted    = make(map[enode.ID]*enode.Node)
+	)
+	for _, witness := range witnesses {
+		node, err := vm.GetWitnessEnode(state, witness.Id)
+		if err != nil {
+			log.Debug("Failed to resolve delegate enode", "witness", witness.Id, "err", err)
+			continue
+		}
+		if node == nil || node.ID() == self || d.configured[node.ID()] {
+			continue
+		}
+		wanted[node.ID()] = node
+	}
+	for id, node := range d.dialed {
+		if _, ok := wanted[id]; !ok {
+			d.drop(id, node)
+		}
+	}
+	for id, node := range wanted {
+		if old, ok := d.dialed[id]; ok {
+			if old.URLv4() == node.URLv4() {
+				continue
+			}
+			// The producer moved, redial it at its new endpoint
+			d.drop(id, old)
+		}
+		log.Debug("Dialing delegate", "id", id, "addr", node.URLv4())
+		d.srv.AddTrustedPeer(node)
+		d.srv.AddPeer(node)
+		d.dialed[id] = node
+	}
+	delegatePeersGauge.Update(int64(len(d.dialed)))
+}
+
+// drop removes a producer added by the dialer from the server.
+func (d *delegateDialer) drop(id enode.ID, node *enode.Node) {
+	log.Debug("Dropping delegate", "id", id, "addr", node.URLv4())
+	d.srv.RemovePeer(node)
+	d.srv.RemoveTrustedPeer(node)
+	delete(d.dialed, id)
+}
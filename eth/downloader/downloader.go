@@ -27,6 +27,8 @@ import (
 
 	"github.com/ebakus/go-ebakus"
 	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/consensus/dpos"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/rawdb"
 	"github.com/ebakus/go-ebakus/core/types"
 	"github.com/ebakus/go-ebakus/ethdb"
@@ -143,6 +145,8 @@ type Downloader struct {
 	trackStateReq  chan *stateReq
 	stateCh        chan dataPack // [eth/63] Channel receiving inbound node state data
 
+	ebakusCh chan dataPack // [eth/66] Channel receiving inbound ebakusdb snapshot chunks
+
 	// Cancellation and termination
 	cancelPeer string         // Identifier of the peer currently being used as the master (cancel on drop)
 	cancelCh   chan struct{}  // Channel to cancel mid-flight syncs
@@ -204,6 +208,12 @@ type BlockChain interface {
 
 	// InsertReceiptChain inserts a batch of receipts into the local chain.
 	InsertReceiptChain(types.Blocks, []types.Receipts, uint64) (int, error)
+
+	// EbakusStateAt retrieves the ebakus state of a block.
+	EbakusStateAt(common.Hash, uint64) (ebkdb.State, error)
+
+	// WriteEbakusState stores the ebakus state of a block imported unexecuted.
+	WriteEbakusState(common.Hash, ebkdb.State)
 }
 
 // New creates a new downloader to fetch hashes and blocks from remote peers.
@@ -231,6 +241,7 @@ func New(checkpoint uint64, stateDb ethdb.Database, stateBloom *trie.SyncBloom,
 		headerProcCh:   make(chan []*types.Header, 1),
 		quitCh:         make(chan struct{}),
 		stateCh:        make(chan dataPack),
+		ebakusCh:       make(chan dataPack),
 		stateSyncStart: make(chan *stateSync),
 		syncStatsState: stateSyncStats{
 			processed: rawdb.ReadFastTrieProgress(stateDb),
@@ -258,7 +269,7 @@ func (d *Downloader) Progress() ebakus.SyncProgress {
 	switch {
 	case d.blockchain != nil && d.mode == FullSync:
 		current = d.blockchain.CurrentBlock().NumberU64()
-	case d.blockchain != nil && d.mode == FastSync:
+	case d.blockchain != nil && d.mode.pivoted():
 		current = d.blockchain.CurrentFastBlock().NumberU64()
 	case d.lightchain != nil:
 		current = d.lightchain.CurrentHeader().Number.Uint64()
@@ -274,6 +285,12 @@ func (d *Downloader) Progress() ebakus.SyncProgress {
 	}
 }
 
+// QueueStats retrieves the occupancy of the download queue, which fills up if
+// the block imports can't keep up with the fetches.
+func (d *Downloader) QueueStats() QueueStats {
+	return d.queue.Stats()
+}
+
 // Synchronising returns whether the downloader is currently retrieving blocks.
 func (d *Downloader) Synchronising() bool {
 	return atomic.LoadInt32(&d.synchronising) > 0
@@ -450,7 +467,7 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 
 	// Ensure our origin point is below any fast sync pivot point
 	pivot := uint64(0)
-	if d.mode == FastSync {
+	if d.mode.pivoted() {
 		if height <= uint64(fsMinFullBlocks) {
 			origin = 0
 		} else {
@@ -461,10 +478,10 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 		}
 	}
 	d.committed = 1
-	if d.mode == FastSync && pivot != 0 {
+	if d.mode.pivoted() && pivot != 0 {
 		d.committed = 0
 	}
-	if d.mode == FastSync {
+	if d.mode.pivoted() {
 		// Set the ancient data limitation.
 		// If we are running fast sync, all block data older than ancientLimit will be
 		// written to the ancient store. More recent data will be written to the active
@@ -513,7 +530,7 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 		func() error { return d.fetchReceipts(origin + 1) },        // Receipts are retrieved during fast sync
 		func() error { return d.processHeaders(origin+1, pivot, td) },
 	}
-	if d.mode == FastSync {
+	if d.mode.pivoted() {
 		fetchers = append(fetchers, func() error { return d.processFastSyncContent(latest) })
 	} else if d.mode == FullSync {
 		fetchers = append(fetchers, d.processFullSyncContent)
@@ -620,7 +637,7 @@ func (d *Downloader) fetchHeight(p *peerConnection) (*types.Header, error) {
 				return nil, errBadPeer
 			}
 			head := headers[0]
-			if (d.mode == FastSync || d.mode == LightSync) && head.Number.Uint64() < d.checkpoint {
+			if (d.mode.pivoted() || d.mode == LightSync) && head.Number.Uint64() < d.checkpoint {
 				p.log.Warn("Remote head below checkpoint", "number", head.Number, "hash", head.Hash())
 				return nil, errUnsyncedPeer
 			}
@@ -705,7 +722,7 @@ func (d *Downloader) findAncestor(p *peerConnection, remoteHeader *types.Header)
 	switch d.mode {
 	case FullSync:
 		localHeight = d.blockchain.CurrentBlock().NumberU64()
-	case FastSync:
+	case FastSync, SnapshotSync:
 		localHeight = d.blockchain.CurrentFastBlock().NumberU64()
 	default:
 		localHeight = d.lightchain.CurrentHeader().Number.Uint64()
@@ -717,6 +734,14 @@ func (d *Downloader) findAncestor(p *peerConnection, remoteHeader *types.Header)
 		// We're above the max reorg threshold, find the earliest fork point
 		floor = int64(localHeight - maxForkAncestry)
 	}
+	// Never reorganise below the last block irreversibly built on by the delegates
+	if chain, ok := d.lightchain.(dpos.HeaderReader); ok {
+		if header := dpos.LastIrreversibleHeader(chain, chain.GetHeaderByNumber(localHeight)); header != nil {
+			if irreversible := int64(header.Number.Uint64()) - 1; irreversible > floor {
+				floor = irreversible
+			}
+		}
+	}
 	// If we're doing a light sync, ensure the floor doesn't go below the CHT, as
 	// all headers before that point will be missing.
 	if d.mode == LightSync {
@@ -788,7 +813,7 @@ func (d *Downloader) findAncestor(p *peerConnection, remoteHeader *types.Header)
 				switch d.mode {
 				case FullSync:
 					known = d.blockchain.HasBlock(h, n)
-				case FastSync:
+				case FastSync, SnapshotSync:
 					known = d.blockchain.HasFastBlock(h, n)
 				default:
 					known = d.lightchain.HasHeader(h, n)
@@ -861,7 +886,7 @@ func (d *Downloader) findAncestor(p *peerConnection, remoteHeader *types.Header)
 				switch d.mode {
 				case FullSync:
 					known = d.blockchain.HasBlock(h, n)
-				case FastSync:
+				case FastSync, SnapshotSync:
 					known = d.blockchain.HasFastBlock(h, n)
 				default:
 					known = d.lightchain.HasHeader(h, n)
@@ -1423,7 +1448,7 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 				// This check cannot be executed "as is" for full imports, since blocks may still be
 				// queued for processing when the header download completes. However, as long as the
 				// peer gave us something useful, we're already happy/progressed (above check).
-				if d.mode == FastSync || d.mode == LightSync {
+				if d.mode.pivoted() || d.mode == LightSync {
 					if td.Cmp(d.lightchain.CurrentHeader().Number) > 0 {
 						return errStallingPeer
 					}
@@ -1448,7 +1473,7 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 				}
 				chunk := headers[:limit]
 				// In case of header only syncing, validate the chunk immediately
-				if d.mode == FastSync || d.mode == LightSync {
+				if d.mode.pivoted() || d.mode == LightSync {
 					// Collect the yet unknown headers to mark them as uncertain
 					unknown := make([]*types.Header, 0, len(chunk))
 					for _, header := range chunk {
@@ -1476,7 +1501,7 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 					}
 				}
 				// Unless we're doing light chains, schedule the headers for associated content retrieval
-				if d.mode == FullSync || d.mode == FastSync {
+				if d.mode == FullSync || d.mode.pivoted() {
 					// If we've reached the allowed number of pending headers, stall a bit
 					for d.queue.PendingBlocks() >= maxQueuedHeaders || d.queue.PendingReceipts() >= maxQueuedHeaders {
 						select {
@@ -1571,6 +1596,15 @@ func (d *Downloader) processFastSyncContent(latest *types.Header) error {
 	// the state of the pivot block.
 	sync := d.syncState(latest.Root)
 	defer sync.Cancel()
+
+	// In snapshot sync, the ebakusdb snapshot of the pivot block is downloaded
+	// too, once the pivot is known
+	var ebakusSync *ebakusSync
+	defer func() {
+		if ebakusSync != nil {
+			ebakusSync.Cancel()
+		}
+	}()
 	closeOnErr := func(s *stateSync) {
 		if err := s.Wait(); err != nil && err != errCancelStateFetch && err != errCanceled {
 			d.queue.Close() // wake up Results
@@ -1632,6 +1666,13 @@ func (d *Downloader) processFastSyncContent(latest *types.Header) error {
 				sync = d.syncState(P.Header.Root)
 				defer sync.Cancel()
 				go closeOnErr(sync)
+
+				if d.mode == SnapshotSync {
+					if ebakusSync != nil {
+						ebakusSync.Cancel()
+					}
+					ebakusSync = d.syncEbakusState(P.Header)
+				}
 				oldPivot = P
 			}
 			// Wait for completion, occasionally checking for pivot staleness
@@ -1640,7 +1681,12 @@ func (d *Downloader) processFastSyncContent(latest *types.Header) error {
 				if sync.err != nil {
 					return sync.err
 				}
-				if err := d.commitPivotBlock(P); err != nil {
+				if ebakusSync != nil {
+					if err := ebakusSync.Wait(); err != nil {
+						return err
+					}
+				}
+				if err := d.commitPivotBlock(P, ebakusSync); err != nil {
 					return err
 				}
 				oldPivot = nil
@@ -1705,7 +1751,7 @@ func (d *Downloader) commitFastSyncData(results []*fetchResult, stateSync *state
 	return nil
 }
 
-func (d *Downloader) commitPivotBlock(result *fetchResult) error {
+func (d *Downloader) commitPivotBlock(result *fetchResult, ebakusSync *ebakusSync) error {
 	block := types.NewBlockWithHeader(result.Header).WithBody(result.Transactions)
 	log.Debug("Committing fast sync pivot as new head", "number", block.Number(), "hash", block.Hash())
 
@@ -1713,6 +1759,9 @@ func (d *Downloader) commitPivotBlock(result *fetchResult) error {
 	if _, err := d.blockchain.InsertReceiptChain([]*types.Block{block}, []types.Receipts{result.Receipts}, d.ancientLimit); err != nil {
 		return err
 	}
+	if ebakusSync != nil {
+		d.blockchain.WriteEbakusState(block.Hash(), ebakusSync.state)
+	}
 	if err := d.blockchain.FastSyncCommitHead(block.Hash()); err != nil {
 		return err
 	}
@@ -1750,6 +1799,22 @@ func (d *Downloader) DeliverNodeData(id string, data [][]byte) (err error) {
 	return d.deliver(id, d.stateCh, &statePack{id, data}, stateInMeter, stateDropMeter)
 }
 
+// DeliverEbakusState injects a chunk of an ebakusdb snapshot received from a
+// remote node. Chunks nobody waits for, like late replies to timed out
+// requests, are dropped.
+func (d *Downloader) DeliverEbakusState(id string, chunk *ebkdb.SnapshotChunk) error {
+	packet := &ebakusPack{id, chunk}
+
+	ebakusInMeter.Mark(int64(packet.Items()))
+	select {
+	case d.ebakusCh <- packet:
+		return nil
+	default:
+		ebakusDropMeter.Mark(int64(packet.Items()))
+		return errNoSyncActive
+	}
+}
+
 // deliver injects a new batch of data received from a remote node.
 func (d *Downloader) deliver(id string, destCh chan dataPack, packet dataPack, inMeter, dropMeter metrics.Meter) (err error) {
 	// Update the delivery metrics for both good and failed deliveries
@@ -0,0 +1,183 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ebakus/go-ebakus/common/hexutil"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/log"
+)
+
+// MaxEbakusStateFetch is the amount of ebakusdb snapshot entries, in bytes, to
+// be fetched per retrieval request.
+const MaxEbakusStateFetch = 512 * 1024
+
+var (
+	errNoEbakusPeers     = errors.New("no peers to download the ebakus state from")
+	errEbakusUnavailable = errors.New("ebakus state unavailable")
+)
+
+// ebakusSync downloads the ebakusdb snapshot of a block chunk by chunk from the
+// peers, applying the chunks on top of the local head snapshot. Each chunk is
+// verified to continue the previous one in key order; the state as a whole is
+// verified by the execution of the blocks following the pivot.
+type ebakusSync struct {
+	d      *Downloader
+	header *types.Header // Block whose snapshot is downloaded
+
+	state  ebkdb.State   // Snapshot the chunks are applied to
+	cancel chan struct{} // Channel to signal a termination request
+	done   chan struct{} // Channel to signal termination completion
+	err    error         // Any error hit during the download (set before done is closed)
+
+	cancelOnce sync.Once
+}
+
+// syncEbakusState starts downloading the ebakusdb snapshot of the given block.
+func (d *Downloader) syncEbakusState(header *types.Header) *ebakusSync {
+	s := &ebakusSync{
+		d:      d,
+		header: header,
+		cancel: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		s.err = s.run()
+		close(s.done)
+	}()
+	return s
+}
+
+// Wait blocks until the download finishes, returning any error hit.
+func (s *ebakusSync) Wait() error {
+	<-s.done
+	return s.err
+}
+
+// Cancel aborts the download, waits for it to stop and releases the snapshot.
+func (s *ebakusSync) Cancel() error {
+	s.cancelOnce.Do(func() {
+		close(s.cancel)
+		<-s.done
+		if s.state != nil {
+			s.state.Release()
+		}
+	})
+	return s.err
+}
+
+// run downloads the chunks of the snapshot in order, switching peers whenever
+// one fails to deliver.
+func (s *ebakusSync) run() error {
+	head := s.d.blockchain.CurrentBlock()
+	base, err := s.d.blockchain.EbakusStateAt(head.Hash(), head.NumberU64())
+	if err != nil {
+		return err
+	}
+	s.state = base.Snapshot()
+	base.Release()
+
+	log.Info("Downloading ebakus state", "number", s.header.Number, "hash", s.header.Hash())
+
+	var (
+		origin  []byte
+		failed  = make(map[string]struct{}) // Peers failed to deliver, not retried
+		chunks  int
+		entries int
+	)
+	for {
+		var p *peerConnection
+		for _, peer := range s.d.peers.EbakusStatePeers() {
+			if _, ok := failed[peer.id]; !ok {
+				p = peer
+				break
+			}
+		}
+		if p == nil {
+			return errNoEbakusPeers
+		}
+		chunk, err := s.fetch(p, origin)
+		switch err {
+		case nil:
+		case errCanceled:
+			return err
+		case errTimeout, errEbakusUnavailable:
+			p.log.Debug("Ebakus state chunk not delivered", "origin", hexutil.Encode(origin), "err", err)
+			failed[p.id] = struct{}{}
+			continue
+		default:
+			p.log.Warn("Invalid ebakus state chunk", "origin", hexutil.Encode(origin), "err", err)
+			failed[p.id] = struct{}{}
+			if s.d.dropPeer != nil {
+				s.d.dropPeer(p.id)
+			}
+			continue
+		}
+		if err := ebkdb.ApplySnapshotChunk(s.state, origin, chunk); err != nil {
+			return err
+		}
+		chunks++
+		entries += len(chunk.Keys)
+
+		if origin = chunk.Next(); origin == nil {
+			log.Info("Downloaded ebakus state", "number", s.header.Number, "hash", s.header.Hash(), "chunks", chunks, "entries", entries)
+			return nil
+		}
+	}
+}
+
+// fetch requests the chunk starting at origin from the peer and waits for it.
+func (s *ebakusSync) fetch(p *peerConnection, origin []byte) (*ebkdb.SnapshotChunk, error) {
+	if err := p.peer.(EbakusStatePeer).RequestEbakusState(s.header.Hash(), origin, MaxEbakusStateFetch); err != nil {
+		return nil, err
+	}
+	timeout := time.NewTimer(s.d.requestTTL())
+	defer timeout.Stop()
+
+	for {
+		select {
+		case packet := <-s.d.ebakusCh:
+			// Late replies of peers already given up on are ignored
+			if packet.PeerId() != p.id {
+				continue
+			}
+			chunk := packet.(*ebakusPack).chunk
+			if len(chunk.Keys) == 0 {
+				return nil, errEbakusUnavailable
+			}
+			if err := chunk.Verify(origin); err != nil {
+				return nil, err
+			}
+			return chunk, nil
+
+		case <-timeout.C:
+			ebakusTimeoutMeter.Mark(1)
+			return nil, errTimeout
+
+		case <-s.cancel:
+			return nil, errCanceled
+
+		case <-s.d.quitCh:
+			return nil, errCanceled
+		}
+	}
+}
@@ -22,22 +22,28 @@ import (
 	"github.com/ebakus/go-ebakus/metrics"
 )
 
+// The delivery, request and timeout metrics are collected even with metrics
+// disabled, as debug_syncStats reports them.
 var (
-	headerInMeter      = metrics.NewRegisteredMeter("eth/downloader/headers/in", nil)
-	headerReqTimer     = metrics.NewRegisteredTimer("eth/downloader/headers/req", nil)
+	headerInMeter      = metrics.NewRegisteredMeterForced("eth/downloader/headers/in", nil)
+	headerReqTimer     = metrics.NewRegisteredTimerForced("eth/downloader/headers/req", nil)
 	headerDropMeter    = metrics.NewRegisteredMeter("eth/downloader/headers/drop", nil)
-	headerTimeoutMeter = metrics.NewRegisteredMeter("eth/downloader/headers/timeout", nil)
+	headerTimeoutMeter = metrics.NewRegisteredMeterForced("eth/downloader/headers/timeout", nil)
 
-	bodyInMeter      = metrics.NewRegisteredMeter("eth/downloader/bodies/in", nil)
-	bodyReqTimer     = metrics.NewRegisteredTimer("eth/downloader/bodies/req", nil)
+	bodyInMeter      = metrics.NewRegisteredMeterForced("eth/downloader/bodies/in", nil)
+	bodyReqTimer     = metrics.NewRegisteredTimerForced("eth/downloader/bodies/req", nil)
 	bodyDropMeter    = metrics.NewRegisteredMeter("eth/downloader/bodies/drop", nil)
-	bodyTimeoutMeter = metrics.NewRegisteredMeter("eth/downloader/bodies/timeout", nil)
+	bodyTimeoutMeter = metrics.NewRegisteredMeterForced("eth/downloader/bodies/timeout", nil)
 
-	receiptInMeter      = metrics.NewRegisteredMeter("eth/downloader/receipts/in", nil)
-	receiptReqTimer     = metrics.NewRegisteredTimer("eth/downloader/receipts/req", nil)
+	receiptInMeter      = metrics.NewRegisteredMeterForced("eth/downloader/receipts/in", nil)
+	receiptReqTimer     = metrics.NewRegisteredTimerForced("eth/downloader/receipts/req", nil)
 	receiptDropMeter    = metrics.NewRegisteredMeter("eth/downloader/receipts/drop", nil)
-	receiptTimeoutMeter = metrics.NewRegisteredMeter("eth/downloader/receipts/timeout", nil)
+	receiptTimeoutMeter = metrics.NewRegisteredMeterForced("eth/downloader/receipts/timeout", nil)
 
 	stateInMeter   = metrics.NewRegisteredMeter("eth/downloader/states/in", nil)
 	stateDropMeter = metrics.NewRegisteredMeter("eth/downloader/states/drop", nil)
+
+	ebakusInMeter      = metrics.NewRegisteredMeterForced("eth/downloader/ebakus/in", nil)
+	ebakusDropMeter    = metrics.NewRegisteredMeter("eth/downloader/ebakus/drop", nil)
+	ebakusTimeoutMeter = metrics.NewRegisteredMeterForced("eth/downloader/ebakus/timeout", nil)
 )
@@ -22,13 +22,20 @@ import "fmt"
 type SyncMode int
 
 const (
-	FullSync  SyncMode = iota // Synchronise the entire blockchain history from full blocks
-	FastSync                  // Quickly download the headers, full sync only at the chain head
-	LightSync                 // Download only the headers and terminate afterwards
+	FullSync     SyncMode = iota // Synchronise the entire blockchain history from full blocks
+	FastSync                     // Quickly download the headers, full sync only at the chain head
+	LightSync                    // Download only the headers and terminate afterwards
+	SnapshotSync                 // Like fast sync, also downloading the ebakusdb snapshot of the pivot block
 )
 
 func (mode SyncMode) IsValid() bool {
-	return mode >= FullSync && mode <= LightSync
+	return mode >= FullSync && mode <= SnapshotSync
+}
+
+// pivoted reports whether the mode imports the chain up to a pivot block
+// without executing it, downloading the state of the pivot instead.
+func (mode SyncMode) pivoted() bool {
+	return mode == FastSync || mode == SnapshotSync
 }
 
 // String implements the stringer interface.
@@ -40,6 +47,8 @@ func (mode SyncMode) String() string {
 		return "fast"
 	case LightSync:
 		return "light"
+	case SnapshotSync:
+		return "snapshot"
 	default:
 		return "unknown"
 	}
@@ -53,6 +62,8 @@ func (mode SyncMode) MarshalText() ([]byte, error) {
 		return []byte("fast"), nil
 	case LightSync:
 		return []byte("light"), nil
+	case SnapshotSync:
+		return []byte("snapshot"), nil
 	default:
 		return nil, fmt.Errorf("unknown sync mode %d", mode)
 	}
@@ -66,8 +77,10 @@ func (mode *SyncMode) UnmarshalText(text []byte) error {
 		*mode = FastSync
 	case "light":
 		*mode = LightSync
+	case "snapshot":
+		*mode = SnapshotSync
 	default:
-		return fmt.Errorf(`unknown sync mode %q, want "full", "fast" or "light"`, text)
+		return fmt.Errorf(`unknown sync mode %q, want "full", "fast", "light" or "snapshot"`, text)
 	}
 	return nil
 }
@@ -90,6 +90,12 @@ type Peer interface {
 	RequestNodeData([]common.Hash) error
 }
 
+// EbakusStatePeer is implemented by the peers able to serve chunks of the
+// ebakusdb snapshots, needed by snapshot sync.
+type EbakusStatePeer interface {
+	RequestEbakusState(hash common.Hash, origin []byte, bytes uint64) error
+}
+
 // lightPeerWrapper wraps a LightPeer struct, stubbing out the Peer-only methods.
 type lightPeerWrapper struct {
 	peer LightPeer
@@ -515,6 +521,21 @@ func (ps *peerSet) NodeDataIdlePeers() ([]*peerConnection, int) {
 	return ps.idlePeers(63, 64, idle, throughput)
 }
 
+// EbakusStatePeers retrieves a flat list of all the peers within the active peer
+// set able to serve ebakusdb snapshot chunks.
+func (ps *peerSet) EbakusStatePeers() []*peerConnection {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*peerConnection, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		if _, ok := p.peer.(EbakusStatePeer); ok && p.version >= 66 {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
 // idlePeers retrieves a flat list of all currently idle peers satisfying the
 // protocol version constraints, using the provided function to check idleness.
 // The resulting set of peers are sorted by their measure throughput.
@@ -202,6 +202,47 @@ func (q *queue) InFlightReceipts() bool {
 	return len(q.receiptPendPool) > 0
 }
 
+// QueueStats is the occupancy of the download queue.
+type QueueStats struct {
+	PendingHeaders   int `json:"pendingHeaders"`   // Header batches pending retrieval
+	PendingBodies    int `json:"pendingBodies"`    // Block bodies pending retrieval
+	PendingReceipts  int `json:"pendingReceipts"`  // Block receipts pending retrieval
+	InFlightHeaders  int `json:"inFlightHeaders"`  // Header requests in flight
+	InFlightBodies   int `json:"inFlightBodies"`   // Block body requests in flight
+	InFlightReceipts int `json:"inFlightReceipts"` // Block receipt requests in flight
+	Fetching         int `json:"fetching"`         // Blocks with parts still being fetched
+	Completed        int `json:"completed"`        // Fetched blocks waiting to be imported
+	Capacity         int `json:"capacity"`         // Blocks the result cache can hold
+}
+
+// Stats retrieves the occupancy of the queue.
+func (q *queue) Stats() QueueStats {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	stats := QueueStats{
+		PendingBodies:    q.blockTaskQueue.Size(),
+		PendingReceipts:  q.receiptTaskQueue.Size(),
+		InFlightHeaders:  len(q.headerPendPool),
+		InFlightBodies:   len(q.blockPendPool),
+		InFlightReceipts: len(q.receiptPendPool),
+		Capacity:         len(q.resultCache),
+	}
+	if q.headerTaskQueue != nil { // Only scheduled along the skeleton
+		stats.PendingHeaders = q.headerTaskQueue.Size()
+	}
+	for _, result := range q.resultCache {
+		switch {
+		case result == nil:
+		case result.Pending > 0:
+			stats.Fetching++
+		default:
+			stats.Completed++
+		}
+	}
+	return stats
+}
+
 // Idle returns if the queue is fully idle or has some data still inside.
 func (q *queue) Idle() bool {
 	q.lock.Lock()
@@ -335,7 +376,7 @@ func (q *queue) Schedule(headers []*types.Header, from uint64) []*types.Header {
 		q.blockTaskPool[hash] = header
 		q.blockTaskQueue.Push(header, -int64(header.Number.Uint64()))
 
-		if q.mode == FastSync {
+		if q.mode.pivoted() {
 			q.receiptTaskPool[hash] = header
 			q.receiptTaskQueue.Push(header, -int64(header.Number.Uint64()))
 		}
@@ -510,7 +551,7 @@ func (q *queue) reserveHeaders(p *peerConnection, count int, taskPool map[common
 		}
 		if q.resultCache[index] == nil {
 			components := 1
-			if q.mode == FastSync {
+			if q.mode.pivoted() {
 				components = 2
 			}
 			q.resultCache[index] = &fetchResult{
@@ -19,6 +19,7 @@ package downloader
 import (
 	"fmt"
 
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/types"
 )
 
@@ -73,3 +74,13 @@ type statePack struct {
 func (p *statePack) PeerId() string { return p.peerID }
 func (p *statePack) Items() int     { return len(p.states) }
 func (p *statePack) Stats() string  { return fmt.Sprintf("%d", len(p.states)) }
+
+// ebakusPack is a chunk of an ebakusdb snapshot returned by a peer.
+type ebakusPack struct {
+	peerID string
+	chunk  *ebkdb.SnapshotChunk
+}
+
+func (p *ebakusPack) PeerId() string { return p.peerID }
+func (p *ebakusPack) Items() int     { return len(p.chunk.Keys) }
+func (p *ebakusPack) Stats() string  { return fmt.Sprintf("%d", len(p.chunk.Keys)) }
@@ -28,9 +28,13 @@ import (
 	ebakus "github.com/ebakus/go-ebakus"
 	"github.com/ebakus/go-ebakus/common"
 	"github.com/ebakus/go-ebakus/common/hexutil"
+	"github.com/ebakus/go-ebakus/consensus/dpos"
 	"github.com/ebakus/go-ebakus/core/types"
 	"github.com/ebakus/go-ebakus/ethdb"
 	"github.com/ebakus/go-ebakus/event"
+	"github.com/ebakus/go-ebakus/log"
+	"github.com/ebakus/go-ebakus/params"
+	"github.com/ebakus/go-ebakus/rlp"
 	"github.com/ebakus/go-ebakus/rpc"
 )
 
@@ -203,13 +207,106 @@ func (api *PublicFilterAPI) NewBlockFilter() rpc.ID {
 	return headerSub.ID
 }
 
+// NewHeadsOptions are the opt-in extensions of the newHeads subscription.
+type NewHeadsOptions struct {
+	SlotInfo bool `json:"slotInfo"` // Add the producer, slot and lateness of the blocks
+}
+
 // NewHeads send a notification each time a new (header) block is appended to the chain.
-func (api *PublicFilterAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+//
+// With the slotInfo option the headers are extended with the address of their
+// producer, their slot number and the milliseconds they were imported after the
+// start of their slot, so the health of the network can be monitored from a
+// single subscription.
+func (api *PublicFilterAPI) NewHeads(ctx context.Context, options *NewHeadsOptions) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
 		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
 	}
 
+	rpcSub := notifier.CreateSubscription()
+	slotInfo := options != nil && options.SlotInfo
+
+	go func() {
+		headers := make(chan *types.Header)
+		headersSub := api.events.SubscribeNewHeads(headers)
+
+		for {
+			select {
+			case h := <-headers:
+				if !slotInfo {
+					notifier.Notify(rpcSub.ID, h)
+					continue
+				}
+				head, err := api.slotInfoHeader(h, time.Now())
+				if err != nil {
+					log.Warn("Failed to extend new head with slot info", "number", h.Number, "err", err)
+					notifier.Notify(rpcSub.ID, h)
+					continue
+				}
+				notifier.Notify(rpcSub.ID, head)
+			case <-rpcSub.Err():
+				headersSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				headersSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// slotInfoHeader returns the header in its json form, extended with its producer,
+// its slot number and the milliseconds it arrived after the start of its slot.
+func (api *PublicFilterAPI) slotInfoHeader(header *types.Header, arrived time.Time) (map[string]interface{}, error) {
+	blob, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(blob, &fields); err != nil {
+		return nil, err
+	}
+	fields["producer"] = nil
+	if producer, err := dpos.Producer(header); err == nil {
+		fields["producer"] = producer
+	}
+	// Blocks are timestamped with the start of their slot
+	if config := api.backend.ChainConfig().DPOS; config != nil && config.Period > 0 {
+		fields["slot"] = hexutil.Uint64(header.Time / config.Period)
+	}
+	slotStart := time.Unix(int64(header.Time), 0)
+	fields["lateness"] = arrived.Sub(slotStart).Milliseconds()
+
+	return fields, nil
+}
+
+// DelegatesChange is the notification of the newDelegates subscription.
+type DelegatesChange struct {
+	Number       hexutil.Uint64     `json:"number"`
+	Hash         common.Hash        `json:"hash"`
+	DelegateDiff types.DelegateDiff `json:"delegateDiff"`
+	Delegates    []interface{}      `json:"delegates"`
+}
+
+// NewDelegates creates a subscription that fires each time a block appended to
+// the chain changes the delegates, delivering their full new list, as returned
+// by dpos_getDelegates, along with the change.
+func (api *PublicFilterAPI) NewDelegates(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	if api.events.lightMode {
+		return &rpc.Subscription{}, errors.New("delegate notifications not supported in light mode")
+	}
+	config := api.backend.ChainConfig().DPOS
+	if config == nil {
+		return &rpc.Subscription{}, errors.New("delegate notifications require the dpos engine")
+	}
+
 	rpcSub := notifier.CreateSubscription()
 
 	go func() {
@@ -219,7 +316,23 @@ func (api *PublicFilterAPI) NewHeads(ctx context.Context) (*rpc.Subscription, er
 		for {
 			select {
 			case h := <-headers:
-				notifier.Notify(rpcSub.ID, h)
+				if len(h.DelegateDiff) == 0 {
+					continue
+				}
+				ebakusState, _, err := api.backend.EbakusStateAndHeaderByNumberOrHash(context.Background(), rpc.BlockNumberOrHashWithHash(h.Hash(), false))
+				if err != nil {
+					log.Warn("Failed to retrieve new delegates", "number", h.Number, "err", err)
+					continue
+				}
+				delegates := dpos.GetDelegates(h, ebakusState, config.DelegateCount, config.BonusDelegateCount, config.TurnBlockCount)
+
+				notifier.Notify(rpcSub.ID, &DelegatesChange{
+					Number:       hexutil.Uint64(h.Number.Uint64()),
+					Hash:         h.Hash(),
+					DelegateDiff: h.DelegateDiff,
+					Delegates:    dpos.RPCMarshalWitnesses(ebakusState, &delegates),
+				})
+				ebakusState.Release()
 			case <-rpcSub.Err():
 				headersSub.Unsubscribe()
 				return
@@ -338,6 +451,9 @@ func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([
 		if crit.ToBlock != nil {
 			end = crit.ToBlock.Int64()
 		}
+		if err := api.checkRange(ctx, begin, end); err != nil {
+			return nil, err
+		}
 		// Construct the range filter
 		filter = NewRangeFilter(api.backend, begin, end, crit.Addresses, crit.Topics)
 	}
@@ -349,6 +465,118 @@ func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([
 	return returnLogs(logs), err
 }
 
+// defaultLogsPageBlocks is the number of blocks searched for a page of logs if
+// the number of blocks searched per query isn't capped.
+const defaultLogsPageBlocks = 4 * params.BloomBitsBlocks
+
+// LogsRangeError is returned when the blocks searched by a logs query exceed the
+// configured cap.
+type LogsRangeError struct {
+	Cap uint64 // Maximum number of blocks searched per query
+}
+
+func (e *LogsRangeError) Error() string {
+	return fmt.Sprintf("query exceeds max block range %d, use eth_getLogsPage to page through it", e.Cap)
+}
+
+// ErrorCode returns the JSON-RPC error code of the "limit exceeded" errors.
+func (e *LogsRangeError) ErrorCode() int { return -32005 }
+
+// checkRange returns an error if the filter range spans more blocks than the
+// configured cap.
+func (api *PublicFilterAPI) checkRange(ctx context.Context, begin, end int64) error {
+	maxBlocks := api.backend.RPCLogsMaxBlocks()
+	if maxBlocks == 0 {
+		return nil
+	}
+	first, last, ok := resolveRange(ctx, api.backend, begin, end)
+	if ok && last >= first && last-first >= maxBlocks {
+		return &LogsRangeError{Cap: maxBlocks}
+	}
+	return nil
+}
+
+// LogsPage is a page of the logs matching a filter criteria, found within the
+// blocks from FromBlock to ToBlock.
+type LogsPage struct {
+	Logs      []*types.Log   `json:"logs"`
+	FromBlock hexutil.Uint64 `json:"fromBlock"`
+	ToBlock   hexutil.Uint64 `json:"toBlock"`
+	Cursor    hexutil.Bytes  `json:"cursor,omitempty"` // Cursor of the next page, if any
+}
+
+// logsCursor is the position of the next page of logs, opaque to clients.
+type logsCursor struct {
+	Next uint64 // First block of the next page
+	End  uint64 // Last block of the range, resolved by the first page
+}
+
+// GetLogsPage returns the logs matching the given criteria like GetLogs, but
+// searches at most as many blocks as GetLogs is capped at per call, so that
+// long ranges can be backfilled without timing out. If there are more blocks
+// left to search, the page carries a cursor to pass to the next call, which then
+// resumes right after the page's last block. The end of the range is resolved
+// by the first call, so paging through "latest" doesn't chase the head, and the
+// block range of the criteria is ignored while a cursor is given.
+//
+// Pages are aligned to the bloom bits sections, so each one is either served
+// from the bloom bits index or by iterating over the blocks not indexed yet.
+func (api *PublicFilterAPI) GetLogsPage(ctx context.Context, crit FilterCriteria, cursor *hexutil.Bytes) (*LogsPage, error) {
+	if crit.BlockHash != nil {
+		return nil, errors.New("cannot page through a single block, use eth_getLogs")
+	}
+	var next logsCursor
+	if cursor != nil {
+		if err := rlp.DecodeBytes(*cursor, &next); err != nil {
+			return nil, errors.New("invalid logs cursor")
+		}
+	} else {
+		// Convert the RPC block numbers into internal representations
+		begin := rpc.LatestBlockNumber.Int64()
+		if crit.FromBlock != nil {
+			begin = crit.FromBlock.Int64()
+		}
+		end := rpc.LatestBlockNumber.Int64()
+		if crit.ToBlock != nil {
+			end = crit.ToBlock.Int64()
+		}
+		first, last, ok := resolveRange(ctx, api.backend, begin, end)
+		if !ok {
+			return nil, errors.New("unknown block")
+		}
+		next = logsCursor{Next: first, End: last}
+	}
+	page := &LogsPage{
+		Logs:      []*types.Log{},
+		FromBlock: hexutil.Uint64(next.Next),
+		ToBlock:   hexutil.Uint64(next.End),
+	}
+	if next.Next > next.End {
+		return page, nil
+	}
+	maxBlocks := api.backend.RPCLogsMaxBlocks()
+	if maxBlocks == 0 {
+		maxBlocks = defaultLogsPageBlocks
+	}
+	size, _ := api.backend.BloomStatus()
+	last := chunkEnd(next.Next, next.End, maxBlocks, size)
+
+	filter := NewRangeFilter(api.backend, int64(next.Next), int64(last), crit.Addresses, crit.Topics)
+	logs, err := filter.Logs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	page.Logs = returnLogs(logs)
+	page.ToBlock = hexutil.Uint64(last)
+
+	if last < next.End {
+		if page.Cursor, err = rlp.EncodeToBytes(&logsCursor{Next: last + 1, End: next.End}); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
 // UninstallFilter removes the filter with the given filter id.
 //
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_uninstallfilter
@@ -393,6 +621,9 @@ func (api *PublicFilterAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*ty
 		if f.crit.ToBlock != nil {
 			end = f.crit.ToBlock.Int64()
 		}
+		if err := api.checkRange(ctx, begin, end); err != nil {
+			return nil, err
+		}
 		// Construct the range filter
 		filter = NewRangeFilter(api.backend, begin, end, f.crit.Addresses, f.crit.Topics)
 	}
@@ -24,19 +24,23 @@ import (
 	"github.com/ebakus/go-ebakus/common"
 	"github.com/ebakus/go-ebakus/core"
 	"github.com/ebakus/go-ebakus/core/bloombits"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/types"
 	"github.com/ebakus/go-ebakus/ethdb"
 	"github.com/ebakus/go-ebakus/event"
+	"github.com/ebakus/go-ebakus/params"
 	"github.com/ebakus/go-ebakus/rpc"
 )
 
 type Backend interface {
 	ChainDb() ethdb.Database
+	ChainConfig() *params.ChainConfig
 	EventMux() *event.TypeMux
 	HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error)
 	HeaderByHash(ctx context.Context, blockHash common.Hash) (*types.Header, error)
 	GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error)
 	GetLogs(ctx context.Context, blockHash common.Hash) ([][]*types.Log, error)
+	EbakusStateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (ebkdb.State, *types.Header, error)
 
 	SubscribeNewTxsEvent(chan<- core.NewTxsEvent) event.Subscription
 	SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription
@@ -45,6 +49,8 @@ type Backend interface {
 
 	BloomStatus() (uint64, uint64)
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
+
+	RPCLogsMaxBlocks() uint64 // global cap of the blocks searched by eth_getLogs: DoS protection
 }
 
 // Filter can be used to retrieve and filter logs.
@@ -114,6 +120,60 @@ func newFilter(backend Backend, addresses []common.Address, topics [][]common.Ha
 	}
 }
 
+// isFinalityTag reports whether the filter range limit is the "safe" or the
+// "finalized" block.
+func isFinalityTag(number int64) bool {
+	return number == rpc.SafeBlockNumber.Int64() || number == rpc.FinalizedBlockNumber.Int64()
+}
+
+// resolveRange resolves the limits of a filter range to block numbers, replacing
+// "latest" with the current head and the soft finality tags with the last
+// irreversible block. It reports false if the blocks needed are unavailable.
+func resolveRange(ctx context.Context, backend Backend, begin, end int64) (uint64, uint64, bool) {
+	header, _ := backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if header == nil {
+		return 0, 0, false
+	}
+	head := header.Number.Int64()
+
+	if begin == -1 {
+		begin = head
+	}
+	if end == -1 {
+		end = head
+	}
+	// Resolve the soft finality tags to the last irreversible block
+	if isFinalityTag(begin) || isFinalityTag(end) {
+		header, _ := backend.HeaderByNumber(ctx, rpc.FinalizedBlockNumber)
+		if header == nil {
+			return 0, 0, false
+		}
+		if isFinalityTag(begin) {
+			begin = header.Number.Int64()
+		}
+		if isFinalityTag(end) {
+			end = header.Number.Int64()
+		}
+	}
+	return uint64(begin), uint64(end), true
+}
+
+// chunkEnd returns the last block of the chunk of a filter range starting at
+// begin, spanning at most maxBlocks blocks. Chunks crossing the boundary of a
+// bloom bits section end at a section boundary, so that once aligned they span
+// whole sections and are either served from the bloom bits index or iterated
+// over when not indexed yet.
+func chunkEnd(begin, end, maxBlocks, sectionSize uint64) uint64 {
+	last := begin + maxBlocks - 1
+	if maxBlocks == 0 || last < begin || last >= end {
+		return end
+	}
+	if sectionSize > 0 && last/sectionSize > begin/sectionSize {
+		last = (last+1)/sectionSize*sectionSize - 1
+	}
+	return last
+}
+
 // Logs searches the blockchain for matching log entries, returning all from the
 // first block that contains matches, updating the start of the filter accordingly.
 func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
@@ -129,19 +189,12 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 		return f.blockLogs(ctx, header)
 	}
 	// Figure out the limits of the filter range
-	header, _ := f.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
-	if header == nil {
+	begin, end, ok := resolveRange(ctx, f.backend, f.begin, f.end)
+	if !ok {
 		return nil, nil
 	}
-	head := header.Number.Uint64()
+	f.begin = int64(begin)
 
-	if f.begin == -1 {
-		f.begin = int64(head)
-	}
-	end := uint64(f.end)
-	if f.end == -1 {
-		end = head
-	}
 	// Gather all indexed logs, and finish with non indexed ones
 	var (
 		logs []*types.Log
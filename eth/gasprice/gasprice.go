@@ -36,8 +36,9 @@ type Config struct {
 	Default    *float64 `toml:",omitempty"`
 }
 
-// Oracle recommends gas prices based on the content of recent
-// blocks. Suitable for both light and full clients.
+// Oracle recommends gas prices and virtual difficulties based on the content
+// of recent blocks. Suitable for both light and full clients, though only full
+// clients have the ebakus state needed for virtual difficulties.
 type Oracle struct {
 	backend   ethapi.Backend
 	lastHead  common.Hash
@@ -45,6 +46,9 @@ type Oracle struct {
 	cacheLock sync.RWMutex
 	fetchLock sync.Mutex
 
+	lastDifficultyHead common.Hash
+	lastDifficulty     float64
+
 	checkBlocks, maxEmpty, maxBlocks int
 	percentile                       int
 }
@@ -63,12 +67,13 @@ func NewOracle(backend ethapi.Backend, params Config) *Oracle {
 		percent = 100
 	}
 	return &Oracle{
-		backend:     backend,
-		lastPrice:   params.Default,
-		checkBlocks: blocks,
-		maxEmpty:    blocks / 2,
-		maxBlocks:   blocks * 5,
-		percentile:  percent,
+		backend:        backend,
+		lastPrice:      params.Default,
+		lastDifficulty: types.MinimumVirtualDifficulty,
+		checkBlocks:    blocks,
+		maxEmpty:       blocks / 2,
+		maxBlocks:      blocks * 5,
+		percentile:     percent,
 	}
 }
 
@@ -146,6 +151,83 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*float64, error) {
 	return price, nil
 }
 
+// SuggestVirtualDifficulty returns the recommended virtual difficulty, which is
+// the given percentile of the lowest virtual difficulties that made it into
+// each of the recent blocks. Senders derive the work difficulty they need from
+// it by dividing it by their virtual capacity.
+func (gpo *Oracle) SuggestVirtualDifficulty(ctx context.Context) (float64, error) {
+	gpo.cacheLock.RLock()
+	lastHead := gpo.lastDifficultyHead
+	lastDifficulty := gpo.lastDifficulty
+	gpo.cacheLock.RUnlock()
+
+	head, err := gpo.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if head == nil {
+		return lastDifficulty, err
+	}
+	headHash := head.Hash()
+	if headHash == lastHead {
+		return lastDifficulty, nil
+	}
+
+	gpo.fetchLock.Lock()
+	defer gpo.fetchLock.Unlock()
+
+	// try checking the cache again, maybe the last fetch fetched what we need
+	gpo.cacheLock.RLock()
+	lastHead = gpo.lastDifficultyHead
+	lastDifficulty = gpo.lastDifficulty
+	gpo.cacheLock.RUnlock()
+	if headHash == lastHead {
+		return lastDifficulty, nil
+	}
+
+	blockNum := head.Number.Uint64()
+	ch := make(chan getBlockPricesResult, gpo.checkBlocks)
+	sent := 0
+	exp := 0
+	var blockDifficulties []float64
+	for sent < gpo.checkBlocks && blockNum > 0 {
+		go gpo.getBlockDifficulties(ctx, types.MakeSigner(gpo.backend.ChainConfig()), blockNum, ch)
+		sent++
+		exp++
+		blockNum--
+	}
+	maxEmpty := gpo.maxEmpty
+	for exp > 0 {
+		res := <-ch
+		if res.err != nil {
+			return lastDifficulty, res.err
+		}
+		exp--
+		if res.price != nil {
+			blockDifficulties = append(blockDifficulties, *res.price)
+			continue
+		}
+		if maxEmpty > 0 {
+			maxEmpty--
+			continue
+		}
+		if blockNum > 0 && sent < gpo.maxBlocks {
+			go gpo.getBlockDifficulties(ctx, types.MakeSigner(gpo.backend.ChainConfig()), blockNum, ch)
+			sent++
+			exp++
+			blockNum--
+		}
+	}
+	difficulty := lastDifficulty
+	if len(blockDifficulties) > 0 {
+		sort.Float64s(blockDifficulties)
+		difficulty = blockDifficulties[(len(blockDifficulties)-1)*gpo.percentile/100]
+	}
+
+	gpo.cacheLock.Lock()
+	gpo.lastDifficultyHead = headHash
+	gpo.lastDifficulty = difficulty
+	gpo.cacheLock.Unlock()
+	return difficulty, nil
+}
+
 type getBlockPricesResult struct {
 	price *float64
 	err   error
@@ -182,6 +264,42 @@ func (gpo *Oracle) getBlockPrices(ctx context.Context, signer types.Signer, bloc
 	ch <- getBlockPricesResult{nil, nil}
 }
 
+// getBlockDifficulties calculates the lowest virtual difficulty of the
+// transactions in a given block, weighing their work by the virtual capacity
+// of their senders at the parent block, and sends it to the result channel. If
+// the block is empty or its parent ebakus state is unavailable, it is nil.
+func (gpo *Oracle) getBlockDifficulties(ctx context.Context, signer types.Signer, blockNum uint64, ch chan getBlockPricesResult) {
+	block, err := gpo.backend.BlockByNumber(ctx, rpc.BlockNumber(blockNum))
+	if block == nil || len(block.Transactions()) == 0 {
+		ch <- getBlockPricesResult{nil, err}
+		return
+	}
+	ebakusState, _, err := gpo.backend.EbakusStateAndHeaderByNumber(ctx, rpc.BlockNumber(blockNum-1))
+	if ebakusState == nil {
+		ch <- getBlockPricesResult{nil, err}
+		return
+	}
+	defer ebakusState.Release()
+
+	var minDifficulty *big.Float
+	for _, tx := range block.Transactions() {
+		sender, err := types.Sender(signer, tx)
+		if err != nil {
+			continue
+		}
+		difficulty := tx.VirtualDifficulty(sender, ebakusState)
+		if minDifficulty == nil || difficulty.Cmp(minDifficulty) < 0 {
+			minDifficulty = difficulty
+		}
+	}
+	if minDifficulty == nil {
+		ch <- getBlockPricesResult{nil, nil}
+		return
+	}
+	difficulty, _ := minDifficulty.Float64()
+	ch <- getBlockPricesResult{&difficulty, nil}
+}
+
 type bigIntArray []*big.Int
 
 func (s bigIntArray) Len() int           { return len(s) }
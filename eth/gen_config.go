@@ -17,37 +17,52 @@ import (
 // MarshalTOML marshals as TOML.
 func (c Config) MarshalTOML() (interface{}, error) {
 	type Config struct {
-		Genesis                 *core.Genesis `toml:",omitempty"`
-		NetworkId               uint64
-		SyncMode                downloader.SyncMode
-		NoPruning               bool
-		NoPrefetch              bool
-		Whitelist               map[uint64]common.Hash `toml:"-"`
-		LightServ               int                    `toml:",omitempty"`
-		LightIngress            int                    `toml:",omitempty"`
-		LightEgress             int                    `toml:",omitempty"`
-		LightPeers              int                    `toml:",omitempty"`
-		UltraLightServers       []string               `toml:",omitempty"`
-		UltraLightFraction      int                    `toml:",omitempty"`
-		UltraLightOnlyAnnounce  bool                   `toml:",omitempty"`
-		SkipBcVersionCheck      bool                   `toml:"-"`
-		DatabaseHandles         int                    `toml:"-"`
-		DatabaseCache           int
-		DatabaseFreezer         string
-		TrieCleanCache          int
-		TrieDirtyCache          int
-		TrieTimeout             time.Duration
-		Miner                   miner.Config
-		DPOS                    params.DPOSConfig
-		TxPool                  core.TxPoolConfig
-		GPO                     gasprice.Config
-		EnablePreimageRecording bool
-		DocRoot                 string `toml:"-"`
-		EWASMInterpreter        string
-		EVMInterpreter          string
-		RPCGasCap               *big.Int                       `toml:",omitempty"`
-		Checkpoint              *params.TrustedCheckpoint      `toml:",omitempty"`
-		CheckpointOracle        *params.CheckpointOracleConfig `toml:",omitempty"`
+		Genesis                    *core.Genesis `toml:",omitempty"`
+		NetworkId                  uint64
+		SyncMode                   downloader.SyncMode
+		NoPruning                  bool
+		NoPrefetch                 bool
+		ParallelWorkers            int
+		SchemaDryRun               bool             `toml:"-"`
+		ArchiveContracts           []common.Address `toml:",omitempty"`
+		SnapshotRetention          uint64
+		SnapshotCheckpoint         uint64
+		ReceiptRetention           uint64
+		ReceiptContracts           []common.Address `toml:",omitempty"`
+		NoDelegateDial             bool
+		Whitelist                  map[uint64]common.Hash `toml:"-"`
+		LightServ                  int                    `toml:",omitempty"`
+		LightIngress               int                    `toml:",omitempty"`
+		LightEgress                int                    `toml:",omitempty"`
+		LightPeers                 int                    `toml:",omitempty"`
+		WalletAddresses            []common.Address       `toml:",omitempty"`
+		UltraLightServers          []string               `toml:",omitempty"`
+		UltraLightFraction         int                    `toml:",omitempty"`
+		UltraLightOnlyAnnounce     bool                   `toml:",omitempty"`
+		UltraLightSkipList         uint64                 `toml:",omitempty"`
+		UltraLightBatch            time.Duration          `toml:",omitempty"`
+		SkipBcVersionCheck         bool                   `toml:"-"`
+		DatabaseHandles            int                    `toml:"-"`
+		DatabaseCache              int
+		DatabaseFreezer            string
+		TrieCleanCache             int
+		TrieDirtyCache             int
+		TrieTimeout                time.Duration
+		EbakusdbMaxActiveIterators uint64
+		Miner                      miner.Config
+		DPOS                       params.DPOSConfig
+		TxPool                     core.TxPoolConfig
+		GPO                        gasprice.Config
+		EnablePreimageRecording    bool
+		DocRoot                    string `toml:"-"`
+		EWASMInterpreter           string
+		EVMInterpreter             string
+		RPCGasCap                  *big.Int                       `toml:",omitempty"`
+		RPCEVMTimeout              time.Duration                  `toml:",omitempty"`
+		RPCDBRowsCap               uint64                         `toml:",omitempty"`
+		RPCLogsMaxBlocks           uint64                         `toml:",omitempty"`
+		Checkpoint                 *params.TrustedCheckpoint      `toml:",omitempty"`
+		CheckpointOracle           *params.CheckpointOracleConfig `toml:",omitempty"`
 	}
 	var enc Config
 	enc.Genesis = c.Genesis
@@ -55,14 +70,25 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.SyncMode = c.SyncMode
 	enc.NoPruning = c.NoPruning
 	enc.NoPrefetch = c.NoPrefetch
+	enc.ParallelWorkers = c.ParallelWorkers
+	enc.SchemaDryRun = c.SchemaDryRun
+	enc.ArchiveContracts = c.ArchiveContracts
+	enc.SnapshotRetention = c.SnapshotRetention
+	enc.SnapshotCheckpoint = c.SnapshotCheckpoint
+	enc.ReceiptRetention = c.ReceiptRetention
+	enc.ReceiptContracts = c.ReceiptContracts
+	enc.NoDelegateDial = c.NoDelegateDial
 	enc.Whitelist = c.Whitelist
 	enc.LightServ = c.LightServ
 	enc.LightIngress = c.LightIngress
 	enc.LightEgress = c.LightEgress
 	enc.LightPeers = c.LightPeers
+	enc.WalletAddresses = c.WalletAddresses
 	enc.UltraLightServers = c.UltraLightServers
 	enc.UltraLightFraction = c.UltraLightFraction
 	enc.UltraLightOnlyAnnounce = c.UltraLightOnlyAnnounce
+	enc.UltraLightSkipList = c.UltraLightSkipList
+	enc.UltraLightBatch = c.UltraLightBatch
 	enc.SkipBcVersionCheck = c.SkipBcVersionCheck
 	enc.DatabaseHandles = c.DatabaseHandles
 	enc.DatabaseCache = c.DatabaseCache
@@ -70,6 +96,7 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.TrieCleanCache = c.TrieCleanCache
 	enc.TrieDirtyCache = c.TrieDirtyCache
 	enc.TrieTimeout = c.TrieTimeout
+	enc.EbakusdbMaxActiveIterators = c.EbakusdbMaxActiveIterators
 	enc.Miner = c.Miner
 	enc.DPOS = c.DPOS
 	enc.TxPool = c.TxPool
@@ -79,6 +106,9 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.EWASMInterpreter = c.EWASMInterpreter
 	enc.EVMInterpreter = c.EVMInterpreter
 	enc.RPCGasCap = c.RPCGasCap
+	enc.RPCEVMTimeout = c.RPCEVMTimeout
+	enc.RPCDBRowsCap = c.RPCDBRowsCap
+	enc.RPCLogsMaxBlocks = c.RPCLogsMaxBlocks
 	enc.Checkpoint = c.Checkpoint
 	enc.CheckpointOracle = c.CheckpointOracle
 	return &enc, nil
@@ -87,37 +117,52 @@ func (c Config) MarshalTOML() (interface{}, error) {
 // UnmarshalTOML unmarshals from TOML.
 func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	type Config struct {
-		Genesis                 *core.Genesis `toml:",omitempty"`
-		NetworkId               *uint64
-		SyncMode                *downloader.SyncMode
-		NoPruning               *bool
-		NoPrefetch              *bool
-		Whitelist               map[uint64]common.Hash `toml:"-"`
-		LightServ               *int                   `toml:",omitempty"`
-		LightIngress            *int                   `toml:",omitempty"`
-		LightEgress             *int                   `toml:",omitempty"`
-		LightPeers              *int                   `toml:",omitempty"`
-		UltraLightServers       []string               `toml:",omitempty"`
-		UltraLightFraction      *int                   `toml:",omitempty"`
-		UltraLightOnlyAnnounce  *bool                  `toml:",omitempty"`
-		SkipBcVersionCheck      *bool                  `toml:"-"`
-		DatabaseHandles         *int                   `toml:"-"`
-		DatabaseCache           *int
-		DatabaseFreezer         *string
-		TrieCleanCache          *int
-		TrieDirtyCache          *int
-		TrieTimeout             *time.Duration
-		Miner                   *miner.Config
-		DPOS                    *params.DPOSConfig
-		TxPool                  *core.TxPoolConfig
-		GPO                     *gasprice.Config
-		EnablePreimageRecording *bool
-		DocRoot                 *string `toml:"-"`
-		EWASMInterpreter        *string
-		EVMInterpreter          *string
-		RPCGasCap               *big.Int                       `toml:",omitempty"`
-		Checkpoint              *params.TrustedCheckpoint      `toml:",omitempty"`
-		CheckpointOracle        *params.CheckpointOracleConfig `toml:",omitempty"`
+		Genesis                    *core.Genesis `toml:",omitempty"`
+		NetworkId                  *uint64
+		SyncMode                   *downloader.SyncMode
+		NoPruning                  *bool
+		NoPrefetch                 *bool
+		ParallelWorkers            *int
+		SchemaDryRun               *bool            `toml:"-"`
+		ArchiveContracts           []common.Address `toml:",omitempty"`
+		SnapshotRetention          *uint64
+		SnapshotCheckpoint         *uint64
+		ReceiptRetention           *uint64
+		ReceiptContracts           []common.Address `toml:",omitempty"`
+		NoDelegateDial             *bool
+		Whitelist                  map[uint64]common.Hash `toml:"-"`
+		LightServ                  *int                   `toml:",omitempty"`
+		LightIngress               *int                   `toml:",omitempty"`
+		LightEgress                *int                   `toml:",omitempty"`
+		LightPeers                 *int                   `toml:",omitempty"`
+		WalletAddresses            []common.Address       `toml:",omitempty"`
+		UltraLightServers          []string               `toml:",omitempty"`
+		UltraLightFraction         *int                   `toml:",omitempty"`
+		UltraLightOnlyAnnounce     *bool                  `toml:",omitempty"`
+		UltraLightSkipList         *uint64                `toml:",omitempty"`
+		UltraLightBatch            *time.Duration         `toml:",omitempty"`
+		SkipBcVersionCheck         *bool                  `toml:"-"`
+		DatabaseHandles            *int                   `toml:"-"`
+		DatabaseCache              *int
+		DatabaseFreezer            *string
+		TrieCleanCache             *int
+		TrieDirtyCache             *int
+		TrieTimeout                *time.Duration
+		EbakusdbMaxActiveIterators *uint64
+		Miner                      *miner.Config
+		DPOS                       *params.DPOSConfig
+		TxPool                     *core.TxPoolConfig
+		GPO                        *gasprice.Config
+		EnablePreimageRecording    *bool
+		DocRoot                    *string `toml:"-"`
+		EWASMInterpreter           *string
+		EVMInterpreter             *string
+		RPCGasCap                  *big.Int                       `toml:",omitempty"`
+		RPCEVMTimeout              *time.Duration                 `toml:",omitempty"`
+		RPCDBRowsCap               *uint64                        `toml:",omitempty"`
+		RPCLogsMaxBlocks           *uint64                        `toml:",omitempty"`
+		Checkpoint                 *params.TrustedCheckpoint      `toml:",omitempty"`
+		CheckpointOracle           *params.CheckpointOracleConfig `toml:",omitempty"`
 	}
 	var dec Config
 	if err := unmarshal(&dec); err != nil {
@@ -138,6 +183,30 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.NoPrefetch != nil {
 		c.NoPrefetch = *dec.NoPrefetch
 	}
+	if dec.ParallelWorkers != nil {
+		c.ParallelWorkers = *dec.ParallelWorkers
+	}
+	if dec.SchemaDryRun != nil {
+		c.SchemaDryRun = *dec.SchemaDryRun
+	}
+	if dec.ArchiveContracts != nil {
+		c.ArchiveContracts = dec.ArchiveContracts
+	}
+	if dec.SnapshotRetention != nil {
+		c.SnapshotRetention = *dec.SnapshotRetention
+	}
+	if dec.SnapshotCheckpoint != nil {
+		c.SnapshotCheckpoint = *dec.SnapshotCheckpoint
+	}
+	if dec.ReceiptRetention != nil {
+		c.ReceiptRetention = *dec.ReceiptRetention
+	}
+	if dec.ReceiptContracts != nil {
+		c.ReceiptContracts = dec.ReceiptContracts
+	}
+	if dec.NoDelegateDial != nil {
+		c.NoDelegateDial = *dec.NoDelegateDial
+	}
 	if dec.Whitelist != nil {
 		c.Whitelist = dec.Whitelist
 	}
@@ -153,6 +222,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.LightPeers != nil {
 		c.LightPeers = *dec.LightPeers
 	}
+	if dec.WalletAddresses != nil {
+		c.WalletAddresses = dec.WalletAddresses
+	}
 	if dec.UltraLightServers != nil {
 		c.UltraLightServers = dec.UltraLightServers
 	}
@@ -162,6 +234,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.UltraLightOnlyAnnounce != nil {
 		c.UltraLightOnlyAnnounce = *dec.UltraLightOnlyAnnounce
 	}
+	if dec.UltraLightSkipList != nil {
+		c.UltraLightSkipList = *dec.UltraLightSkipList
+	}
+	if dec.UltraLightBatch != nil {
+		c.UltraLightBatch = *dec.UltraLightBatch
+	}
 	if dec.SkipBcVersionCheck != nil {
 		c.SkipBcVersionCheck = *dec.SkipBcVersionCheck
 	}
@@ -183,6 +261,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.TrieTimeout != nil {
 		c.TrieTimeout = *dec.TrieTimeout
 	}
+	if dec.EbakusdbMaxActiveIterators != nil {
+		c.EbakusdbMaxActiveIterators = *dec.EbakusdbMaxActiveIterators
+	}
 	if dec.Miner != nil {
 		c.Miner = *dec.Miner
 	}
@@ -210,6 +291,15 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.RPCGasCap != nil {
 		c.RPCGasCap = dec.RPCGasCap
 	}
+	if dec.RPCEVMTimeout != nil {
+		c.RPCEVMTimeout = *dec.RPCEVMTimeout
+	}
+	if dec.RPCDBRowsCap != nil {
+		c.RPCDBRowsCap = *dec.RPCDBRowsCap
+	}
+	if dec.RPCLogsMaxBlocks != nil {
+		c.RPCLogsMaxBlocks = *dec.RPCLogsMaxBlocks
+	}
 	if dec.Checkpoint != nil {
 		c.Checkpoint = dec.Checkpoint
 	}
@@ -29,6 +29,7 @@ import (
 	"github.com/ebakus/go-ebakus/common"
 	"github.com/ebakus/go-ebakus/consensus"
 	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/forkid"
 	"github.com/ebakus/go-ebakus/core/types"
 	"github.com/ebakus/go-ebakus/eth/downloader"
@@ -67,8 +68,9 @@ type ProtocolManager struct {
 	networkID  uint64
 	forkFilter forkid.Filter // Fork ID filter, constant across the lifetime of the node
 
-	fastSync  uint32 // Flag whether fast sync is enabled (gets disabled if we already have blocks)
-	acceptTxs uint32 // Flag whether we're considered synchronised (enables transaction processing)
+	fastSync  uint32              // Flag whether fast sync is enabled (gets disabled if we already have blocks)
+	fastMode  downloader.SyncMode // Pivoted sync mode to run while fast sync is enabled (fast or snapshot)
+	acceptTxs uint32              // Flag whether we're considered synchronised (enables transaction processing)
 
 	checkpointNumber uint64      // Block number for the sync progress validator to cross reference
 	checkpointHash   common.Hash // Block hash for the sync progress validator to cross reference
@@ -125,10 +127,12 @@ func NewProtocolManager(config *params.ChainConfig, checkpoint *params.TrustedCh
 		// * the last fast sync is not finished while user specifies a full sync this
 		//   time. But we don't have any recent state for full sync.
 		// In these cases however it's safe to reenable fast sync.
+		// Snapshot sync is resumed, as the fast synced state lacks the ebakus state.
 		fullBlock, fastBlock := blockchain.CurrentBlock(), blockchain.CurrentFastBlock()
 		if fullBlock.NumberU64() == 0 && fastBlock.NumberU64() > 0 {
 			manager.fastSync = uint32(1)
-			log.Warn("Switch sync mode from full sync to fast sync")
+			manager.fastMode = downloader.SnapshotSync
+			log.Warn("Switch sync mode from full sync to snapshot sync")
 		}
 	} else {
 		if blockchain.CurrentBlock().NumberU64() > 0 {
@@ -137,6 +141,7 @@ func NewProtocolManager(config *params.ChainConfig, checkpoint *params.TrustedCh
 		} else {
 			// If fast sync was requested and our database is empty, grant it
 			manager.fastSync = uint32(1)
+			manager.fastMode = mode
 		}
 	}
 	// If we have trusted checkpoints, enforce them on the chain
@@ -306,7 +311,7 @@ func (pm *ProtocolManager) handle(p *peer) error {
 		hash    = head.Hash()
 		number  = head.Number
 	)
-	if err := p.Handshake(pm.networkID, number, hash, genesis.Hash(), forkid.NewID(pm.blockchain), pm.forkFilter); err != nil {
+	if err := p.Handshake(pm.networkID, number, hash, genesis.Hash(), forkid.NewID(pm.blockchain), pm.forkFilter, pm.blockchain.Config().Fingerprint(genesis.Hash())); err != nil {
 		p.Log().Debug("Ebakus handshake failed", "err", err)
 		return err
 	}
@@ -658,6 +663,40 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			log.Debug("Failed to deliver receipts", "err", err)
 		}
 
+	case p.version >= eth66 && msg.Code == GetEbakusStateMsg:
+		// Decode the retrieval message
+		var query getEbakusStateData
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		if query.Bytes > softResponseLimit {
+			query.Bytes = softResponseLimit
+		}
+		// Gather the snapshot entries, replying with an empty chunk if the
+		// snapshot of the block isn't available to us
+		chunk := new(ebkdb.SnapshotChunk)
+		if header := pm.blockchain.GetHeaderByHash(query.Hash); header != nil {
+			if ebakusState, err := pm.blockchain.ReadEbakusStateAt(query.Hash, header.Number.Uint64()); err == nil {
+				if read, err := ebkdb.ReadSnapshotChunk(ebakusState, query.Origin, query.Bytes); err == nil {
+					chunk = read
+				} else {
+					p.Log().Debug("Failed to read ebakus state chunk", "hash", query.Hash, "err", err)
+				}
+				ebakusState.Release()
+			}
+		}
+		return p.SendEbakusState(chunk)
+
+	case p.version >= eth66 && msg.Code == EbakusStateMsg:
+		// A chunk of an ebakusdb snapshot arrived to one of our previous requests
+		chunk := new(ebkdb.SnapshotChunk)
+		if err := msg.Decode(chunk); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if err := pm.downloader.DeliverEbakusState(p.id, chunk); err != nil {
+			log.Debug("Failed to deliver ebakus state chunk", "err", err)
+		}
+
 	case msg.Code == NewBlockHashesMsg:
 		var announces newBlockHashesData
 		if err := msg.Decode(&announces); err != nil {
@@ -25,6 +25,8 @@ import (
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/common/hexutil"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/forkid"
 	"github.com/ebakus/go-ebakus/core/types"
 	"github.com/ebakus/go-ebakus/metrics"
@@ -312,6 +314,12 @@ func (p *peer) SendNodeData(data [][]byte) error {
 	return p2p.Send(p.rw, NodeDataMsg, data)
 }
 
+// SendEbakusState sends a chunk of an ebakusdb snapshot, corresponding to the
+// one requested.
+func (p *peer) SendEbakusState(chunk *ebkdb.SnapshotChunk) error {
+	return p2p.Send(p.rw, EbakusStateMsg, chunk)
+}
+
 // SendReceiptsRLP sends a batch of transaction receipts, corresponding to the
 // ones requested from an already RLP encoded format.
 func (p *peer) SendReceiptsRLP(receipts []rlp.RawValue) error {
@@ -353,6 +361,13 @@ func (p *peer) RequestNodeData(hashes []common.Hash) error {
 	return p2p.Send(p.rw, GetNodeDataMsg, hashes)
 }
 
+// RequestEbakusState fetches a chunk of the ebakusdb snapshot of a block from a
+// remote node, starting at the origin key.
+func (p *peer) RequestEbakusState(hash common.Hash, origin []byte, bytes uint64) error {
+	p.Log().Debug("Fetching ebakus state chunk", "hash", hash, "origin", hexutil.Encode(origin))
+	return p2p.Send(p.rw, GetEbakusStateMsg, &getEbakusStateData{Hash: hash, Origin: origin, Bytes: bytes})
+}
+
 // RequestReceipts fetches a batch of transaction receipts from a remote node.
 func (p *peer) RequestReceipts(hashes []common.Hash) error {
 	p.Log().Debug("Fetching batch of receipts", "count", len(hashes))
@@ -360,8 +375,9 @@ func (p *peer) RequestReceipts(hashes []common.Hash) error {
 }
 
 // Handshake executes the eth protocol handshake, negotiating version number,
-// network IDs, difficulties, head and genesis blocks.
-func (p *peer) Handshake(network uint64, headNumber *big.Int, head common.Hash, genesis common.Hash, forkID forkid.ID, forkFilter forkid.Filter) error {
+// network IDs, difficulties, head and genesis blocks. Since eth/65 the config
+// fingerprints of the two nodes must match too.
+func (p *peer) Handshake(network uint64, headNumber *big.Int, head common.Hash, genesis common.Hash, forkID forkid.ID, forkFilter forkid.Filter, fingerprint common.Hash) error {
 	// Send out own handshake in a new thread
 	errc := make(chan error, 2)
 
@@ -379,15 +395,19 @@ func (p *peer) Handshake(network uint64, headNumber *big.Int, head common.Hash,
 				CurrentBlock:    head,
 				GenesisBlock:    genesis,
 			})
-		case p.version == eth64:
-			errc <- p2p.Send(p.rw, StatusMsg, &statusData{
+		case p.version >= eth64:
+			status := &statusData{
 				ProtocolVersion: uint32(p.version),
 				NetworkID:       network,
 				HeadNumber:      headNumber,
 				Head:            head,
 				Genesis:         genesis,
 				ForkID:          forkID,
-			})
+			}
+			if p.version >= eth65 {
+				status.Fingerprint = []common.Hash{fingerprint}
+			}
+			errc <- p2p.Send(p.rw, StatusMsg, status)
 		default:
 			panic(fmt.Sprintf("unsupported eth protocol version: %d", p.version))
 		}
@@ -396,8 +416,8 @@ func (p *peer) Handshake(network uint64, headNumber *big.Int, head common.Hash,
 		switch {
 		case p.version == eth63:
 			errc <- p.readStatusLegacy(network, &status63, genesis)
-		case p.version == eth64:
-			errc <- p.readStatus(network, &status, genesis, forkFilter)
+		case p.version >= eth64:
+			errc <- p.readStatus(network, &status, genesis, forkFilter, fingerprint)
 		default:
 			panic(fmt.Sprintf("unsupported eth protocol version: %d", p.version))
 		}
@@ -417,7 +437,7 @@ func (p *peer) Handshake(network uint64, headNumber *big.Int, head common.Hash,
 	switch {
 	case p.version == eth63:
 		p.HeadNumber, p.head = status63.HeadNumber, status63.CurrentBlock
-	case p.version == eth64:
+	case p.version >= eth64:
 		p.HeadNumber, p.head = status.HeadNumber, status.Head
 	default:
 		panic(fmt.Sprintf("unsupported eth protocol version: %d", p.version))
@@ -452,7 +472,7 @@ func (p *peer) readStatusLegacy(network uint64, status *statusData63, genesis co
 	return nil
 }
 
-func (p *peer) readStatus(network uint64, status *statusData, genesis common.Hash, forkFilter forkid.Filter) error {
+func (p *peer) readStatus(network uint64, status *statusData, genesis common.Hash, forkFilter forkid.Filter, fingerprint common.Hash) error {
 	msg, err := p.rw.ReadMsg()
 	if err != nil {
 		return err
@@ -479,6 +499,14 @@ func (p *peer) readStatus(network uint64, status *statusData, genesis common.Has
 	if err := forkFilter(status.ForkID); err != nil {
 		return errResp(ErrForkIDRejected, "%v", err)
 	}
+	if p.version >= eth65 {
+		if len(status.Fingerprint) == 0 {
+			return errResp(ErrFingerprintMismatch, "missing (!= %x)", fingerprint)
+		}
+		if status.Fingerprint[0] != fingerprint {
+			return errResp(ErrFingerprintMismatch, "%x (!= %x)", status.Fingerprint[0], fingerprint)
+		}
+	}
 	return nil
 }
 
@@ -33,16 +33,18 @@ import (
 const (
 	eth63 = 63
 	eth64 = 64
+	eth65 = 65
+	eth66 = 66
 )
 
 // protocolName is the official short name of the protocol used during capability negotiation.
 const protocolName = "eth"
 
 // ProtocolVersions are the supported versions of the eth protocol (first is primary).
-var ProtocolVersions = []uint{eth64, eth63}
+var ProtocolVersions = []uint{eth66, eth65, eth64, eth63}
 
 // protocolLengths are the number of implemented message corresponding to different protocol versions.
-var protocolLengths = map[uint]uint64{eth64: 17, eth63: 17}
+var protocolLengths = map[uint]uint64{eth66: 19, eth65: 17, eth64: 17, eth63: 17}
 
 const protocolMaxMsgSize = 10 * 1024 * 1024 // Maximum cap on the size of a protocol message
 
@@ -60,6 +62,10 @@ const (
 	NodeDataMsg        = 0x0e
 	GetReceiptsMsg     = 0x0f
 	ReceiptsMsg        = 0x10
+
+	// Protocol messages belonging to eth/66
+	GetEbakusStateMsg = 0x11
+	EbakusStateMsg    = 0x12
 )
 
 type errCode int
@@ -74,6 +80,7 @@ const (
 	ErrForkIDRejected
 	ErrNoStatusMsg
 	ErrExtraStatusMsg
+	ErrFingerprintMismatch
 )
 
 func (e errCode) String() string {
@@ -91,6 +98,7 @@ var errorToString = map[int]string{
 	ErrForkIDRejected:          "Fork ID rejected",
 	ErrNoStatusMsg:             "No status message",
 	ErrExtraStatusMsg:          "Extra status message",
+	ErrFingerprintMismatch:     "Config fingerprint mismatch",
 }
 
 type txPool interface {
@@ -123,6 +131,9 @@ type statusData struct {
 	Head            common.Hash
 	Genesis         common.Hash
 	ForkID          forkid.ID
+
+	// Fingerprint of the genesis and DPOS config, only sent since eth/65
+	Fingerprint []common.Hash `rlp:"tail"`
 }
 
 // newBlockHashesData is the network packet for the block announcements.
@@ -139,6 +150,14 @@ type getBlockHeadersData struct {
 	Reverse bool         // Query direction (false = rising towards latest, true = falling towards genesis)
 }
 
+// getEbakusStateData represents a query for a chunk of the ebakusdb snapshot of
+// a block.
+type getEbakusStateData struct {
+	Hash   common.Hash // Block whose snapshot to retrieve a chunk of
+	Origin []byte      // Key from which to retrieve the snapshot entries
+	Bytes  uint64      // Soft limit of the chunk size in bytes
+}
+
 // hashOrNumber is a combined field for specifying an origin block.
 type hashOrNumber struct {
 	Hash   common.Hash // Block hash from which to retrieve headers (excludes Number)
@@ -179,9 +179,9 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 	mode := downloader.FullSync
 	if atomic.LoadUint32(&pm.fastSync) == 1 {
 		// Fast sync was explicitly requested, and explicitly granted
-		mode = downloader.FastSync
+		mode = pm.fastMode
 	}
-	if mode == downloader.FastSync {
+	if mode != downloader.FullSync {
 		// Make sure the peer's total difficulty we are synchronizing is higher.
 		if pm.blockchain.CurrentFastBlock().Number().Cmp(pTd) >= 0 {
 			return
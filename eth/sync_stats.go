@@ -0,0 +1,144 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"time"
+
+	"github.com/ebakus/go-ebakus/eth/downloader"
+	"github.com/ebakus/go-ebakus/metrics"
+)
+
+// The metrics of the sync pipeline stages reported by debug_syncStats, named by
+// stage. They are collected even with metrics disabled.
+var (
+	syncFetchMeters = map[string]string{
+		"headers":  "eth/downloader/headers/in",
+		"bodies":   "eth/downloader/bodies/in",
+		"receipts": "eth/downloader/receipts/in",
+		"ebakus":   "eth/downloader/ebakus/in",
+	}
+	syncTimeoutMeters = map[string]string{
+		"headers":  "eth/downloader/headers/timeout",
+		"bodies":   "eth/downloader/bodies/timeout",
+		"receipts": "eth/downloader/receipts/timeout",
+		"ebakus":   "eth/downloader/ebakus/timeout",
+	}
+	syncRequestTimers = map[string]string{
+		"headers":  "eth/downloader/headers/req",
+		"bodies":   "eth/downloader/bodies/req",
+		"receipts": "eth/downloader/receipts/req",
+	}
+	syncImportTimers = map[string]string{
+		"blocks":       "chain/inserts",
+		"execution":    "chain/execution",
+		"validation":   "chain/validation",
+		"write":        "chain/write",
+		"ebakusWait":   "chain/ebakus/importwait",
+		"ebakusCommit": "chain/ebakus/commits",
+	}
+	syncExecutionMeters = map[string]string{
+		"txs": "chain/inserts/txs",
+		"gas": "chain/inserts/gas",
+	}
+)
+
+// RateStats is the rate of the events of a sync pipeline stage.
+type RateStats struct {
+	Count int64   `json:"count"` // Number of events since the node started
+	Rate1 float64 `json:"rate1"` // Events per second, averaged over the last minute
+	Rate5 float64 `json:"rate5"` // Events per second, averaged over the last five minutes
+}
+
+// LatencyStats is the rate and the latency of the events of a sync pipeline
+// stage, the latencies being in milliseconds.
+type LatencyStats struct {
+	RateStats
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P95  float64 `json:"p95"`
+	Max  float64 `json:"max"`
+}
+
+// SyncStats is the throughput of the stages of the sync pipeline, from the data
+// fetches to the ebakusdb commits of the block imports, along with the download
+// queue occupancy, to spot the stage bottlenecking the sync.
+type SyncStats struct {
+	Syncing      bool   `json:"syncing"`
+	CurrentBlock uint64 `json:"currentBlock"`
+	HighestBlock uint64 `json:"highestBlock"`
+
+	Fetched   map[string]*RateStats    `json:"fetched"`   // Items delivered by the peers, by kind
+	Timeouts  map[string]*RateStats    `json:"timeouts"`  // Requests timed out, by kind
+	Requests  map[string]*LatencyStats `json:"requests"`  // Requests answered, by kind
+	Imports   map[string]*LatencyStats `json:"imports"`   // Block import stages
+	Execution map[string]*RateStats    `json:"execution"` // Transactions and gas executed by the imports
+
+	Queue downloader.QueueStats `json:"queue"`
+}
+
+// readRates reads the named meters of the default registry.
+func readRates(names map[string]string) map[string]*RateStats {
+	stats := make(map[string]*RateStats)
+	for stage, name := range names {
+		if meter, ok := metrics.DefaultRegistry.Get(name).(metrics.Meter); ok {
+			snap := meter.Snapshot()
+			stats[stage] = &RateStats{Count: snap.Count(), Rate1: snap.Rate1(), Rate5: snap.Rate5()}
+		}
+	}
+	return stats
+}
+
+// readLatencies reads the named timers of the default registry.
+func readLatencies(names map[string]string) map[string]*LatencyStats {
+	ms := func(ns float64) float64 { return ns / float64(time.Millisecond) }
+
+	stats := make(map[string]*LatencyStats)
+	for stage, name := range names {
+		if timer, ok := metrics.DefaultRegistry.Get(name).(metrics.Timer); ok {
+			snap := timer.Snapshot()
+			ps := snap.Percentiles([]float64{0.5, 0.95})
+			stats[stage] = &LatencyStats{
+				RateStats: RateStats{Count: snap.Count(), Rate1: snap.Rate1(), Rate5: snap.Rate5()},
+				Mean:      ms(snap.Mean()),
+				P50:       ms(ps[0]),
+				P95:       ms(ps[1]),
+				Max:       ms(float64(snap.Max())),
+			}
+		}
+	}
+	return stats
+}
+
+// SyncStats returns the throughput of the stages of the sync pipeline and the
+// occupancy of the download queue.
+func (api *PrivateDebugAPI) SyncStats() *SyncStats {
+	d := api.eth.Downloader()
+	progress := d.Progress()
+
+	return &SyncStats{
+		Syncing:      d.Synchronising(),
+		CurrentBlock: progress.CurrentBlock,
+		HighestBlock: progress.HighestBlock,
+		Fetched:      readRates(syncFetchMeters),
+		Timeouts:     readRates(syncTimeoutMeters),
+		Requests:     readLatencies(syncRequestTimers),
+		Imports:      readLatencies(syncImportTimers),
+		Execution:    readRates(syncExecutionMeters),
+		Queue:        d.QueueStats(),
+	}
+}
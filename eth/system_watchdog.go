@@ -0,0 +1,317 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ebakus/go-ebakus/accounts/abi"
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/common/hexutil"
+	"github.com/ebakus/go-ebakus/consensus/dpos"
+	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/core/vm"
+	"github.com/ebakus/go-ebakus/log"
+	"github.com/ebakus/go-ebakus/metrics"
+)
+
+// systemAlertLimit is the number of the most recent alerts kept by the system
+// table watchdog.
+const systemAlertLimit = 256
+
+var systemAlertMeter = metrics.NewRegisteredMeter("eth/watchdog/alerts", nil)
+
+// systemAbiOwners are the system contracts whose ABIs are stored at genesis and
+// never change afterwards.
+var systemAbiOwners = []common.Address{types.PrecompliledSystemContract, types.PrecompliledDBContract}
+
+// SystemAlert is a change of a system table a block made, which none of its
+// transactions or the consensus rules account for.
+type SystemAlert struct {
+	Number uint64        `json:"number"`
+	Hash   common.Hash   `json:"hash"`
+	Table  string        `json:"table"`
+	Id     hexutil.Bytes `json:"id"`
+	Change string        `json:"change"`
+}
+
+// systemTables is the content of the watched system tables at a block.
+type systemTables struct {
+	flags map[common.Address]uint64 // Flags of the witnesses
+	abis  map[string]string         // ABIs of the system contracts, by row id
+}
+
+// systemWatchdog compares the system tables of every canonical block to the
+// ones of its parent, raising alerts for the changes not made by a known kind
+// of transaction. It's a defense-in-depth against consensus bugs silently
+// corrupting the system state.
+type systemWatchdog struct {
+	chain       *core.BlockChain
+	electEnable []byte // Method id of the system contract call changing witness flags
+
+	last     *systemTables // Tables of the last checked block
+	lastHash common.Hash
+
+	alerts []*SystemAlert
+	lock   sync.RWMutex // Protects the alerts
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newSystemWatchdog creates a watchdog of the system tables of the chain.
+func newSystemWatchdog(chain *core.BlockChain) *systemWatchdog {
+	w := &systemWatchdog{
+		chain: chain,
+		quit:  make(chan struct{}),
+	}
+	if systemABI, err := abi.JSON(strings.NewReader(vm.SystemContractABI)); err == nil {
+		if method, ok := systemABI.Methods[vm.SystemContractElectEnableCmd]; ok {
+			w.electEnable = method.ID()
+		}
+	}
+	return w
+}
+
+// start launches the event loop of the watchdog.
+func (w *systemWatchdog) start() {
+	w.wg.Add(1)
+	go w.loop()
+}
+
+// stop terminates the watchdog.
+func (w *systemWatchdog) stop() {
+	close(w.quit)
+	w.wg.Wait()
+}
+
+// Alerts returns the most recent alerts raised, oldest first.
+func (w *systemWatchdog) Alerts() []*SystemAlert {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	return append([]*SystemAlert{}, w.alerts...)
+}
+
+func (w *systemWatchdog) loop() {
+	defer w.wg.Done()
+
+	events := make(chan core.ChainEvent, 64)
+	sub := w.chain.SubscribeChainEvent(events)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case ev := <-events:
+			w.check(ev.Block)
+		case <-sub.Err():
+			return
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+// check compares the system tables of a block to the ones of its parent.
+func (w *systemWatchdog) check(block *types.Block) {
+	if block.NumberU64() == 0 {
+		return
+	}
+	state, err := w.chain.ReadEbakusStateAt(block.Hash(), block.NumberU64())
+	if err != nil {
+		log.Debug("Failed to open state for system table checks", "number", block.NumberU64(), "err", err)
+		return
+	}
+	defer state.Release()
+
+	current, err := readSystemTables(state)
+	if err != nil {
+		log.Debug("Failed to read system tables", "number", block.NumberU64(), "err", err)
+		return
+	}
+	parent := w.last
+	if parent == nil || w.lastHash != block.ParentHash() {
+		parentState, err := w.chain.ReadEbakusStateAt(block.ParentHash(), block.NumberU64()-1)
+		if err != nil {
+			log.Debug("Failed to open parent state for system table checks", "number", block.NumberU64(), "err", err)
+			return
+		}
+		parent, err = readSystemTables(parentState)
+		parentState.Release()
+		if err != nil {
+			log.Debug("Failed to read parent system tables", "number", block.NumberU64(), "err", err)
+			return
+		}
+	}
+	w.last, w.lastHash = current, block.Hash()
+
+	for _, alert := range w.diff(block, state, parent, current) {
+		log.Error("Unexpected system table change", "number", alert.Number, "hash", alert.Hash, "table", alert.Table, "id", alert.Id, "change", alert.Change)
+		systemAlertMeter.Mark(1)
+
+		w.lock.Lock()
+		if len(w.alerts) == systemAlertLimit {
+			w.alerts = w.alerts[1:]
+		}
+		w.alerts = append(w.alerts, alert)
+		w.lock.Unlock()
+	}
+}
+
+// diff returns the changes between the system tables of a block and its parent
+// which aren't accounted for.
+//
+// Witness flags change by the electEnable calls to the system contract, either
+// by transactions or by the contracts they call, and the consensus engine
+// de-electing witnesses missing their turns. The ABIs of the system contracts
+// never change after genesis.
+func (w *systemWatchdog) diff(block *types.Block, state ebkdb.State, parent, current *systemTables) []*SystemAlert {
+	var (
+		alerts  []*SystemAlert
+		callers = w.electCallers(block)
+	)
+	alert := func(table string, id []byte, format string, args ...interface{}) {
+		alerts = append(alerts, &SystemAlert{
+			Number: block.NumberU64(),
+			Hash:   block.Hash(),
+			Table:  table,
+			Id:     common.CopyBytes(id),
+			Change: fmt.Sprintf(format, args...),
+		})
+	}
+	for address, flags := range current.flags {
+		prev, existed := parent.flags[address]
+		if existed && prev == flags {
+			continue
+		}
+		if _, ok := callers[address]; ok {
+			continue
+		}
+		if existed && flags == prev&^vm.ElectEnabledFlag && w.deelected(state, address) {
+			continue
+		}
+		if !existed {
+			alert("Witnesses", address.Bytes(), "witness added with flags %#x", flags)
+		} else {
+			alert("Witnesses", address.Bytes(), "flags changed from %#x to %#x", prev, flags)
+		}
+	}
+	for address := range parent.flags {
+		if _, ok := current.flags[address]; !ok {
+			alert("Witnesses", address.Bytes(), "witness removed")
+		}
+	}
+	for id, contractAbi := range current.abis {
+		if prev, ok := parent.abis[id]; !ok {
+			alert("ContractAbi", []byte(id), "system contract abi added")
+		} else if prev != contractAbi {
+			alert("ContractAbi", []byte(id), "system contract abi changed")
+		}
+	}
+	for id := range parent.abis {
+		if _, ok := current.abis[id]; !ok {
+			alert("ContractAbi", []byte(id), "system contract abi removed")
+		}
+	}
+	return alerts
+}
+
+// electCallers returns the addresses whose witness flags the transactions of
+// the block may have changed: the senders calling electEnable on the system
+// contract, and the contracts called, as they may call it themselves.
+func (w *systemWatchdog) electCallers(block *types.Block) map[common.Address]struct{} {
+	var (
+		signer  = types.MakeSigner(w.chain.Config())
+		callers = make(map[common.Address]struct{})
+	)
+	for _, tx := range block.Transactions() {
+		to := tx.To()
+		if to == nil {
+			continue
+		}
+		if *to != types.PrecompliledSystemContract {
+			callers[*to] = struct{}{}
+			continue
+		}
+		if data := tx.Data(); len(w.electEnable) == 0 || len(data) < 4 || !bytes.Equal(data[:4], w.electEnable) {
+			continue
+		}
+		if from, err := types.Sender(signer, tx); err == nil {
+			callers[from] = struct{}{}
+		}
+	}
+	return callers
+}
+
+// deelected reports whether the consensus engine de-elects the witness at the
+// block, as it missed too many turns in a row.
+func (w *systemWatchdog) deelected(state ebkdb.State, address common.Address) bool {
+	config := w.chain.Config().DPOS
+	if config == nil || config.MaxMissedSlots == 0 {
+		return false
+	}
+	performance, err := dpos.GetWitnessPerformance(state, address)
+	if err != nil || performance == nil {
+		return false
+	}
+	return performance.MissedInRow > config.MaxMissedSlots
+}
+
+// readSystemTables reads the content of the watched system tables.
+func readSystemTables(state ebkdb.State) (*systemTables, error) {
+	tables := &systemTables{
+		flags: make(map[common.Address]uint64),
+		abis:  make(map[string]string),
+	}
+	if state.HasTable(vm.WitnessesTable) {
+		iter, err := state.Select(vm.WitnessesTable)
+		if err != nil {
+			return nil, err
+		}
+		var witness vm.Witness
+		for iter.Next(&witness) {
+			tables.flags[witness.Id] = witness.Flags
+			witness = vm.Witness{}
+		}
+		iter.Release()
+	}
+	if !state.HasTable(vm.ContractAbiTable) {
+		return tables, nil
+	}
+	for _, owner := range systemAbiOwners {
+		whereClause, err := state.WhereParser(append([]byte("Id LIKE "), owner.Bytes()...))
+		if err != nil {
+			return nil, err
+		}
+		iter, err := state.Select(vm.ContractAbiTable, whereClause)
+		if err != nil {
+			return nil, err
+		}
+		var contractAbi vm.ContractAbi
+		for iter.Next(&contractAbi) {
+			tables.abis[string(contractAbi.Id)] = contractAbi.Abi
+			contractAbi = vm.ContractAbi{}
+		}
+		iter.Release()
+	}
+	return tables, nil
+}
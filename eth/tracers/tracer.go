@@ -297,8 +297,9 @@ type Tracer struct {
 	errorValue  *string // Swappable error value wrapped by a log accessor
 	refundValue *uint   // Swappable refund value wrapped by a log accessor
 
-	ctx map[string]interface{} // Transaction context gathered throughout execution
-	err error                  // Error, if one has occurred
+	ctx        map[string]interface{} // Transaction context gathered throughout execution
+	err        error                  // Error, if one has occurred
+	precompile bool                   // Flag whether the tracer exposes a precompile() function
 
 	interrupt uint32 // Atomic flag to signal execution interruption
 	reason    error  // Textual reason for the interruption
@@ -306,7 +307,7 @@ type Tracer struct {
 
 // New instantiates a new tracer instance. code specifies a Javascript snippet,
 // which must evaluate to an expression returning an object with 'step', 'fault'
-// and 'result' functions.
+// and 'result' functions, and optionally a 'precompile' one.
 func New(code string) (*Tracer, error) {
 	// Resolve any tracers by name and assemble the tracer object
 	if tracer, ok := tracer(code); ok {
@@ -390,7 +391,7 @@ func New(code string) (*Tracer, error) {
 		return 1
 	})
 	tracer.vm.PushGlobalGoFunction("isPrecompiled", func(ctx *duktape.Context) int {
-		_, ok := vm.PrecompiledContractsEbakus[common.BytesToAddress(popSlice(ctx))]
+		_, ok := vm.PrecompiledContractsWrappedToken[common.BytesToAddress(popSlice(ctx))]
 		ctx.PushBoolean(ok)
 		return 1
 	})
@@ -432,6 +433,9 @@ func New(code string) (*Tracer, error) {
 		return nil, fmt.Errorf("Trace object must expose a function result()")
 	}
 	tracer.vm.Pop()
+	// The precompile() function is optional, called for the ebakus precompile calls
+	tracer.precompile = tracer.vm.GetPropString(tracer.tracerObject, "precompile")
+	tracer.vm.Pop()
 
 	// Tracer is valid, inject the big int library to access large numbers
 	tracer.vm.EvalString(bigIntegerJS)
@@ -585,6 +589,27 @@ func (jst *Tracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost
 	return nil
 }
 
+// CapturePrecompile implements the PrecompileTracer interface to trace a call
+// into the ebakus precompiles, passing it to the optional precompile() function.
+func (jst *Tracer) CapturePrecompile(env *vm.EVM, frame *vm.PrecompileFrame) error {
+	if jst.err == nil && jst.precompile {
+		blob, err := json.Marshal(frame)
+		if err != nil {
+			jst.err = wrapError("precompile", err)
+			return nil
+		}
+		jst.vm.PushString(string(blob))
+		jst.vm.JsonDecode(-1)
+		jst.vm.PutPropString(jst.stateObject, "frame")
+
+		jst.dbWrapper.db = env.StateDB
+		if _, err := jst.call("precompile", "frame", "db"); err != nil {
+			jst.err = wrapError("precompile", err)
+		}
+	}
+	return nil
+}
+
 // CaptureEnd is called after the call finishes to finalize the tracing.
 func (jst *Tracer) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
 	jst.ctx["output"] = output
@@ -28,7 +28,6 @@ import (
 	"github.com/ebakus/go-ebakus/common"
 	"github.com/ebakus/go-ebakus/common/hexutil"
 	"github.com/ebakus/go-ebakus/core/types"
-	"github.com/ebakus/go-ebakus/rlp"
 	"github.com/ebakus/go-ebakus/rpc"
 )
 
@@ -340,6 +339,14 @@ func (ec *Client) NonceAt(ctx context.Context, account common.Address, blockNumb
 	return uint64(result), err
 }
 
+// ComputeContractAddress returns the address a CREATE2 deployment by deployer
+// with the given salt and init code hash will end up at.
+func (ec *Client) ComputeContractAddress(ctx context.Context, deployer common.Address, salt common.Hash, initCodeHash common.Hash) (common.Address, error) {
+	var result common.Address
+	err := ec.c.CallContext(ctx, &result, "eth_computeContractAddress", deployer, salt, initCodeHash)
+	return result, err
+}
+
 // Filters
 
 // FilterLogs executes a filter query.
@@ -485,12 +492,37 @@ func (ec *Client) SuggestDifficulty(ctx context.Context, address common.Address)
 	return &difficulty, nil
 }
 
+// SelectTable returns the JSON encoded rows of the owner's ebakusdb table
+// matching the where clause, sorted by the order clause. The rows are read in
+// pages from a single snapshot of the given block.
+func (ec *Client) SelectTable(ctx context.Context, owner common.Address, table string, whereClause string, orderClause string, blockNumber *big.Int) ([]json.RawMessage, error) {
+	var iter hexutil.Uint64
+	if err := ec.c.CallContext(ctx, &iter, "db_select", owner, table, whereClause, orderClause, toBlockNumArg(blockNumber)); err != nil {
+		return nil, err
+	}
+	var rows []json.RawMessage
+	for {
+		var page struct {
+			Rows []json.RawMessage `json:"rows"`
+			Done bool              `json:"done"`
+		}
+		if err := ec.c.CallContext(ctx, &page, "db_nextPage", iter, hexutil.Uint64(0)); err != nil {
+			ec.c.CallContext(ctx, nil, "db_releaseIterator", iter)
+			return nil, err
+		}
+		rows = append(rows, page.Rows...)
+		if page.Done {
+			return rows, nil
+		}
+	}
+}
+
 // SendTransaction injects a signed transaction into the pending pool for execution.
 //
 // If the transaction was a contract creation use the TransactionReceipt method to get the
 // contract address after the transaction has been mined.
 func (ec *Client) SendTransaction(ctx context.Context, tx *types.Transaction) error {
-	data, err := rlp.EncodeToBytes(tx)
+	data, err := tx.MarshalBinary()
 	if err != nil {
 		return err
 	}
@@ -29,8 +29,8 @@ import (
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
-	"github.com/ebakus/ebakusdb"
 	"github.com/ebakus/go-ebakus/accounts"
+	"github.com/ebakus/go-ebakus/accounts/abi"
 	"github.com/ebakus/go-ebakus/accounts/keystore"
 	"github.com/ebakus/go-ebakus/accounts/scwallet"
 	"github.com/ebakus/go-ebakus/common"
@@ -39,6 +39,7 @@ import (
 	"github.com/ebakus/go-ebakus/consensus/dpos"
 	"github.com/ebakus/go-ebakus/consensus/ethash"
 	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/rawdb"
 	"github.com/ebakus/go-ebakus/core/types"
 	"github.com/ebakus/go-ebakus/core/vm"
@@ -435,6 +436,33 @@ func (s *PrivateAccountAPI) Sign(ctx context.Context, data hexutil.Bytes, addr c
 	return signature, nil
 }
 
+// SignTypedData calculates an EIP-712 signature of the typed data, which must
+// be signed for the chain:
+// keccak256("\x19\x01" + domainSeparator + hashStruct(message))
+//
+// The account associated with addr must be unlocked, or passwd given. The V
+// value of the signature is 27 or 28, as with personal_sign.
+func (s *PrivateAccountAPI) SignTypedData(ctx context.Context, data abi.TypedData, addr common.Address, passwd string) (hexutil.Bytes, error) {
+	_, raw, err := types.NewTypedDataSigner(s.b.ChainConfig().ChainID).Hash(&data)
+	if err != nil {
+		return nil, err
+	}
+	// Look up the wallet containing the requested signer
+	account := accounts.Account{Address: addr}
+
+	wallet, err := s.b.AccountManager().Find(account)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := wallet.SignDataWithPassphrase(account, passwd, accounts.MimetypeTypedData, raw)
+	if err != nil {
+		log.Warn("Failed typed data sign attempt", "address", addr, "err", err)
+		return nil, err
+	}
+	signature[crypto.RecoveryIDOffset] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
+	return signature, nil
+}
+
 // EcRecover returns the address for the account that was used to create the signature.
 // Note, this function is compatible with eth_sign and personal_sign. As such it recovers
 // the address of:
@@ -546,10 +574,21 @@ func (s *PublicBlockChainAPI) GetBalance(ctx context.Context, address common.Add
 // given block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta
 // block numbers are also allowed.
 func (s *PublicBlockChainAPI) GetStaked(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (uint64, error) {
-	ebakusState, _, err := s.b.EbakusStateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	ebakusState, header, err := s.b.EbakusStateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if err != nil {
 		return 0, fmt.Errorf("Failed to get ebakusdb snapshot")
 	}
+	if ebakusState == nil {
+		stakedABI, row, err := odrEbakusRow(ctx, s.b, header, types.PrecompliledSystemContract, "Staked", "Id LIKE "+string(address.Bytes()), "")
+		if row == nil || err != nil {
+			return 0, err
+		}
+		var staked types.Staked
+		if err := stakedABI.Tables["Staked"].Inputs.Unpack(&staked, row); err != nil {
+			return 0, err
+		}
+		return staked.Amount, nil
+	}
 	defer ebakusState.Release()
 
 	staked, err := vm.GetStaked(ebakusState, address)
@@ -560,6 +599,137 @@ func (s *PublicBlockChainAPI) GetStaked(ctx context.Context, address common.Addr
 	return staked.Amount, nil
 }
 
+// GetLockedTransfers returns the transfers to the given address which can't be
+// claimed before their unlock time, in the state of the given block number.
+func (s *PublicBlockChainAPI) GetLockedTransfers(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+	ebakusState, _, err := s.b.EbakusStateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if ebakusState == nil {
+		return nil, fmt.Errorf("Failed to find ebakusdb snapshot")
+	}
+	defer ebakusState.Release()
+
+	transfers, err := vm.GetLockedTransfers(ebakusState, address)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]map[string]interface{}, len(transfers))
+	for i, transfer := range transfers {
+		_, from, _ := transfer.Id.Content()
+		out[i] = map[string]interface{}{
+			"from":       from,
+			"amount":     transfer.Amount,
+			"unlockTime": transfer.UnlockTime,
+		}
+	}
+	return out, nil
+}
+
+// GetSubscriptions returns the recurring payments the given address subscribed
+// to, in the state of the given block number.
+func (s *PublicBlockChainAPI) GetSubscriptions(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+	ebakusState, _, err := s.b.EbakusStateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if ebakusState == nil {
+		return nil, fmt.Errorf("Failed to find ebakusdb snapshot")
+	}
+	defer ebakusState.Release()
+
+	subscriptions, err := vm.GetSubscriptions(ebakusState, address)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]map[string]interface{}, len(subscriptions))
+	for i, subscription := range subscriptions {
+		_, payee := subscription.Id.Content()
+		out[i] = map[string]interface{}{
+			"payee":      payee,
+			"amount":     subscription.Amount,
+			"interval":   subscription.Interval,
+			"expiry":     subscription.Expiry,
+			"nextSettle": subscription.NextSettle,
+			"allowance":  subscription.Allowance,
+		}
+	}
+	return out, nil
+}
+
+// GetAllowance returns the amount of native tokens the spender may pull from
+// the owner, in the state of the given block number.
+func (s *PublicBlockChainAPI) GetAllowance(ctx context.Context, owner common.Address, spender common.Address, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Uint64, error) {
+	ebakusState, _, err := s.b.EbakusStateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return 0, err
+	}
+	if ebakusState == nil {
+		return 0, fmt.Errorf("Failed to find ebakusdb snapshot")
+	}
+	defer ebakusState.Release()
+
+	amount, err := vm.GetAllowance(ebakusState, owner, spender)
+	return hexutil.Uint64(amount), err
+}
+
+// GetBridgeCommitment returns the merkle root committing to the outbound
+// bridge intents of the given block number.
+func (s *PublicBlockChainAPI) GetBridgeCommitment(ctx context.Context, number hexutil.Uint64) (map[string]interface{}, error) {
+	ebakusState, _, err := s.b.EbakusStateAndHeaderByNumberOrHash(ctx, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber))
+	if err != nil {
+		return nil, err
+	}
+	if ebakusState == nil {
+		return nil, fmt.Errorf("Failed to find ebakusdb snapshot")
+	}
+	defer ebakusState.Release()
+
+	commitment, err := vm.GetBridgeCommitment(ebakusState, uint64(number))
+	if err != nil {
+		return nil, err
+	}
+	if commitment == nil {
+		return nil, nil
+	}
+	return map[string]interface{}{
+		"number": number,
+		"root":   commitment.Root,
+		"count":  hexutil.Uint64(commitment.Count),
+	}, nil
+}
+
+// GetBridgeProof returns the index-th outbound bridge intent of the given block
+// number, along with the merkle proof of its inclusion in the block commitment.
+func (s *PublicBlockChainAPI) GetBridgeProof(ctx context.Context, number hexutil.Uint64, index hexutil.Uint64) (map[string]interface{}, error) {
+	ebakusState, _, err := s.b.EbakusStateAndHeaderByNumberOrHash(ctx, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber))
+	if err != nil {
+		return nil, err
+	}
+	if ebakusState == nil {
+		return nil, fmt.Errorf("Failed to find ebakusdb snapshot")
+	}
+	defer ebakusState.Release()
+
+	intent, proof, commitment, err := vm.GetBridgeProof(ebakusState, uint64(number), uint64(index))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"number":    number,
+		"index":     index,
+		"sender":    intent.Sender,
+		"chainId":   hexutil.Uint64(intent.ChainId),
+		"recipient": intent.Recipient,
+		"amount":    hexutil.Uint64(intent.Amount),
+		"leaf":      intent.Leaf(),
+		"proof":     proof,
+		"root":      commitment.Root,
+		"count":     hexutil.Uint64(commitment.Count),
+	}, nil
+}
+
 // GetVirtualDifficultyFactor returns the factor used when calculating
 // virtual difficulty for a transaction
 func (s *PublicBlockChainAPI) GetVirtualDifficultyFactor(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (float64, error) {
@@ -756,6 +926,13 @@ func (s *PublicBlockChainAPI) GetCode(ctx context.Context, address common.Addres
 	return code, state.Error()
 }
 
+// ComputeContractAddress returns the address a contract will be deployed at
+// when created through CREATE2 by deployer, using the given salt and the
+// keccak256 hash of its init code.
+func (s *PublicBlockChainAPI) ComputeContractAddress(deployer common.Address, salt common.Hash, initCodeHash common.Hash) common.Address {
+	return crypto.CreateAddress2(deployer, salt, initCodeHash[:])
+}
+
 // GetStorageAt returns the storage from the state at the given address, key and
 // block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta block
 // numbers are also allowed.
@@ -792,6 +969,20 @@ type account struct {
 	StateDiff *map[common.Hash]common.Hash `json:"stateDiff"`
 }
 
+// LimitExceededError is returned when a call hits one of the resource limits
+// the node enforces on eth_call and eth_estimateGas.
+type LimitExceededError struct {
+	Limit string // Name of the limit exceeded ("timeout" or "dbrows")
+	Cap   string // Value of the limit
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("execution aborted: %s limit exceeded (cap = %s)", e.Limit, e.Cap)
+}
+
+// ErrorCode returns the JSON-RPC error code of the "limit exceeded" errors.
+func (e *LimitExceededError) ErrorCode() int { return -32005 }
+
 func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides map[common.Address]account, vmCfg vm.Config, timeout time.Duration, globalGasCap *big.Int) ([]byte, uint64, bool, error) {
 	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
 
@@ -885,6 +1076,9 @@ func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.Blo
 	if err != nil {
 		return nil, 0, false, err
 	}
+	evm.SetEbakusDBRowsLimit(b.RPCDBRowsCap())
+	evm.SetLowPriority(true)
+
 	// Wait for the context to be done and cancel the evm. Even if the
 	// EVM has finished, cancelling may be done (repeatedly)
 	go func() {
@@ -899,9 +1093,12 @@ func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.Blo
 	if err := vmError(); err != nil {
 		return nil, 0, false, err
 	}
-	// If the timer caused an abort, return an appropriate error message
+	// If a limit caused an abort, return an appropriate error message
+	if evm.EbakusDBRowsLimitExceeded() {
+		return nil, 0, false, &LimitExceededError{Limit: "dbrows", Cap: fmt.Sprintf("%d", b.RPCDBRowsCap())}
+	}
 	if evm.Cancelled() {
-		return nil, 0, false, fmt.Errorf("execution aborted (timeout = %v)", timeout)
+		return nil, 0, false, &LimitExceededError{Limit: "timeout", Cap: timeout.String()}
 	}
 	return res, gas, failed, err
 }
@@ -917,10 +1114,17 @@ func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNrOr
 	if overrides != nil {
 		accounts = *overrides
 	}
-	result, _, _, err := DoCall(ctx, s.b, args, blockNrOrHash, accounts, vm.Config{}, 5*time.Second, s.b.RPCGasCap())
+	result, _, _, err := DoCall(ctx, s.b, args, blockNrOrHash, accounts, vm.Config{}, s.b.RPCEVMTimeout(), s.b.RPCGasCap())
 	return (hexutil.Bytes)(result), err
 }
 
+// precompileGasMargin is the safety margin (in percent) added to the estimated
+// gas of calls to the system and db contracts. On top of their flat cost, they
+// charge for the ebakusdb memory they use, which depends on the state at the
+// time of inclusion (e.g. the claimables a stake releases, or the delegations
+// a vote replaces).
+const precompileGasMargin = 20
+
 func DoEstimateGas(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, gasCap *big.Int) (hexutil.Uint64, error) {
 	// Binary search the gas requirement, as it may be higher than the amount used
 	var (
@@ -944,20 +1148,37 @@ func DoEstimateGas(ctx context.Context, b Backend, args CallArgs, blockNrOrHash
 	}
 	cap = hi
 
+	// The timeout applies to the whole binary search, not to each execution
+	if timeout := b.RPCEVMTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	// Create a helper to check if a gas allowance results in an executable transaction
-	executable := func(gas uint64) bool {
+	executable := func(gas uint64) (bool, error) {
 		args.Gas = (*hexutil.Uint64)(&gas)
 
 		_, _, failed, err := DoCall(ctx, b, args, blockNrOrHash, nil, vm.Config{}, 0, gasCap)
+		if err, ok := err.(*LimitExceededError); ok {
+			if err.Limit == "timeout" {
+				err.Cap = b.RPCEVMTimeout().String()
+			}
+			return false, err
+		}
 		if err != nil || failed {
-			return false
+			return false, nil
 		}
-		return true
+		return true, nil
 	}
 	// Execute the binary search and hone in on an executable gas limit
 	for lo+1 < hi {
 		mid := (hi + lo) / 2
-		if !executable(mid) {
+		ok, err := executable(mid)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
 			lo = mid
 		} else {
 			hi = mid
@@ -965,10 +1186,21 @@ func DoEstimateGas(ctx context.Context, b Backend, args CallArgs, blockNrOrHash
 	}
 	// Reject the transaction as invalid if it still fails at the highest allowance
 	if hi == cap {
-		if !executable(hi) {
+		ok, err := executable(hi)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
 			return 0, fmt.Errorf("gas required exceeds allowance (%d) or always failing transaction", cap)
 		}
 	}
+	// The search observed the full cost against the snapshot, including the
+	// memory surcharge of precompiles, pad it for the state to change
+	if args.To != nil && (*args.To == types.PrecompliledSystemContract || *args.To == types.PrecompliledDBContract) {
+		if hi += hi * precompileGasMargin / 100; hi > cap {
+			hi = cap
+		}
+	}
 	return hexutil.Uint64(hi), nil
 }
 
@@ -990,10 +1222,13 @@ func DoSuggestDifficulty(ctx context.Context, b Backend, minTargetDifficulty flo
 	}
 	defer ebakusState.Release()
 
-	dv, err := DoSuggestVirtualDifficulty(b, ebakusState)
+	dv, err := b.SuggestVirtualDifficulty(ctx)
 	if err != nil {
 		return minTargetDifficulty, err
 	}
+	if dv < types.MinimumVirtualDifficulty {
+		dv = types.MinimumVirtualDifficulty
+	}
 
 	cv := types.VirtualCapacity(addr, ebakusState)
 
@@ -1005,55 +1240,13 @@ func DoSuggestDifficulty(ctx context.Context, b Backend, minTargetDifficulty flo
 	return diff, nil
 }
 
-// SuggestDifficulty returns the currently suggested difficulty needed to execute the
-// given transaction against the current pending block.
+// SuggestDifficulty returns the difficulty per gas the work nonce of a transaction
+// from the given address should target for prompt inclusion, based on the virtual
+// difficulties included in the recent blocks and the sender's virtual capacity.
 func (s *PublicBlockChainAPI) SuggestDifficulty(ctx context.Context, addr common.Address) (float64, error) {
 	return DoSuggestDifficulty(ctx, s.b, s.b.MinGasPrice(), addr)
 }
 
-func DoSuggestVirtualDifficulty(b Backend, ebakusState *ebakusdb.Snapshot) (float64, error) {
-	var minDv *big.Float
-
-	pending, queue := b.TxPoolContent()
-
-	// Flatten the pending transactions
-	for from, txs := range pending {
-		for _, tx := range txs {
-			dv := tx.VirtualDifficulty(from, ebakusState)
-			if minDv == nil || dv.Cmp(minDv) == -1 {
-				minDv = dv
-			}
-		}
-	}
-
-	// Flatten the queued transactions
-	for from, txs := range queue {
-		for _, tx := range txs {
-			dv := tx.VirtualDifficulty(from, ebakusState)
-			if minDv == nil || dv.Cmp(minDv) == -1 {
-				minDv = dv
-			}
-		}
-	}
-
-	if minDv == nil {
-		return types.MinimumVirtualDifficulty, nil
-	}
-
-	dv, _ := minDv.Float64()
-	if dv < types.MinimumVirtualDifficulty {
-		return types.MinimumVirtualDifficulty, nil
-	}
-
-	return dv, nil
-}
-
-// SuggestVirtualDifficulty returns the currently suggested virtual difficulty needed to execute the
-// given transaction against the current pending block.
-func (s *PublicBlockChainAPI) suggestVirtualDifficulty(ebakusState *ebakusdb.Snapshot) (float64, error) {
-	return DoSuggestVirtualDifficulty(s.b, ebakusState)
-}
-
 // ExecutionResult groups all structured logs emitted by the EVM
 // while replaying a transaction in debug mode as well as transaction
 // execution status, the amount of gas used and the return value
@@ -1062,6 +1255,9 @@ type ExecutionResult struct {
 	Failed      bool           `json:"failed"`
 	ReturnValue string         `json:"returnValue"`
 	StructLogs  []StructLogRes `json:"structLogs"`
+
+	// Precompiles are the calls into the ebakus precompiles, in execution order
+	Precompiles []*vm.PrecompileFrame `json:"precompiles,omitempty"`
 }
 
 // StructLogRes stores a structured log emitted by the EVM while replaying a
@@ -1115,6 +1311,28 @@ func FormatLogs(logs []vm.StructLog) []StructLogRes {
 	return formatted
 }
 
+// RPCBlock documents the fields of the blocks returned over RPC, which are
+// marshalled into maps by RPCMarshalBlock, for the OpenRPC document.
+type RPCBlock struct {
+	Number           *hexutil.Big       `json:"number"`
+	Hash             common.Hash        `json:"hash"`
+	ParentHash       common.Hash        `json:"parentHash"`
+	Signature        hexutil.Bytes      `json:"signature"`
+	Size             hexutil.Uint64     `json:"size"`
+	GasLimit         hexutil.Uint64     `json:"gasLimit"`
+	GasUsed          hexutil.Uint64     `json:"gasUsed"`
+	Timestamp        hexutil.Uint64     `json:"timestamp"`
+	TransactionsRoot common.Hash        `json:"transactionsRoot"`
+	ReceiptsRoot     common.Hash        `json:"receiptsRoot"`
+	DelegateDiff     types.DelegateDiff `json:"delegateDiff"`
+	Producer         common.Address     `json:"producer"`
+	Transactions     []RPCTransaction   `json:"transactions,omitempty"` // Hashes unless full transactions are requested
+}
+
+func init() {
+	rpc.RegisterOpenRPCType(RPCBlock{})
+}
+
 // RPCMarshalHeader converts the given header to the RPC output .
 func RPCMarshalHeader(head *types.Header) map[string]interface{} {
 	return map[string]interface{}{
@@ -1198,9 +1416,15 @@ type RPCTransaction struct {
 	To               *common.Address `json:"to"`
 	TransactionIndex *hexutil.Uint64 `json:"transactionIndex"`
 	Value            *hexutil.Big    `json:"value"`
+	Type             hexutil.Uint64  `json:"type"`
 	V                *hexutil.Big    `json:"v"`
 	R                *hexutil.Big    `json:"r"`
 	S                *hexutil.Big    `json:"s"`
+
+	// Proof is the Merkle proof of the transaction against the transactions root
+	// of its block, whose Header is included along, on request
+	Proof  []string               `json:"proof,omitempty"`
+	Header map[string]interface{} `json:"header,omitempty"`
 }
 
 // newRPCTransaction returns a transaction that will serialize to the RPC
@@ -1223,6 +1447,7 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 		Nonce:     hexutil.Uint64(tx.Nonce()),
 		To:        tx.To(),
 		Value:     (*hexutil.Big)(tx.Value()),
+		Type:      hexutil.Uint64(tx.Type()),
 		V:         (*hexutil.Big)(v),
 		R:         (*hexutil.Big)(r),
 		S:         (*hexutil.Big)(s),
@@ -1255,7 +1480,7 @@ func newRPCRawTransactionFromBlockIndex(b *types.Block, index uint64) hexutil.By
 	if index >= uint64(len(txs)) {
 		return nil
 	}
-	blob, _ := rlp.EncodeToBytes(txs[index])
+	blob, _ := txs[index].MarshalBinary()
 	return blob
 }
 
@@ -1349,15 +1574,30 @@ func (s *PublicTransactionPoolAPI) GetTransactionCount(ctx context.Context, addr
 	return (*hexutil.Uint64)(&nonce), state.Error()
 }
 
-// GetTransactionByHash returns the transaction for the given hash
-func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) (*RPCTransaction, error) {
+// TransactionOptions are the opt-in extensions of the transaction lookups.
+type TransactionOptions struct {
+	Proof bool `json:"proof"` // Add the inclusion proof and the block header
+}
+
+// GetTransactionByHash returns the transaction for the given hash.
+//
+// With the proof option, mined transactions include the Merkle proof of their
+// inclusion in the transactions trie and the header of their block, so they can
+// be verified without further requests.
+func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, hash common.Hash, options *TransactionOptions) (*RPCTransaction, error) {
 	// Try to return an already finalized transaction
 	tx, blockHash, blockNumber, index, err := s.b.GetTransaction(ctx, hash)
 	if err != nil {
 		return nil, err
 	}
 	if tx != nil {
-		return newRPCTransaction(tx, blockHash, blockNumber, index), nil
+		result := newRPCTransaction(tx, blockHash, blockNumber, index)
+		if options != nil && options.Proof {
+			if err := s.addInclusionProof(ctx, result, blockHash, index); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
 	}
 	// No finalized transaction, try to retrieve it from the pool
 	if tx := s.b.GetPoolTransaction(hash); tx != nil {
@@ -1368,6 +1608,25 @@ func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, has
 	return nil, nil
 }
 
+// addInclusionProof adds the Merkle proof of a transaction's inclusion in its
+// block, along with the block's header to verify it against.
+func (s *PublicTransactionPoolAPI) addInclusionProof(ctx context.Context, result *RPCTransaction, blockHash common.Hash, index uint64) error {
+	block, err := s.b.BlockByHash(ctx, blockHash)
+	if err != nil {
+		return err
+	}
+	if block == nil {
+		return fmt.Errorf("block %#x not found", blockHash)
+	}
+	proof, err := types.DeriveProof(block.Transactions(), int(index))
+	if err != nil {
+		return err
+	}
+	result.Proof = common.ToHexArray(proof)
+	result.Header = RPCMarshalHeader(block.Header())
+	return nil
+}
+
 // GetRawTransactionByHash returns the bytes of the transaction for the given hash.
 func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context, hash common.Hash) (hexutil.Bytes, error) {
 	// Retrieve a finalized transaction, or a pooled otherwise
@@ -1381,8 +1640,8 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context,
 			return nil, nil
 		}
 	}
-	// Serialize to RLP and return
-	return rlp.EncodeToBytes(tx)
+	// Serialize to the canonical encoding and return
+	return tx.MarshalBinary()
 }
 
 // GetTransactionReceipt returns the transaction receipt for the given transaction hash.
@@ -1411,6 +1670,7 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 		"blockNumber":       hexutil.Uint64(blockNumber),
 		"transactionHash":   hash,
 		"transactionIndex":  hexutil.Uint64(index),
+		"type":              hexutil.Uint(tx.Type()),
 		"from":              from,
 		"to":                tx.To(),
 		"gasUsed":           hexutil.Uint64(receipt.GasUsed),
@@ -1597,7 +1857,9 @@ func (s *PublicTransactionPoolAPI) SendTransaction(ctx context.Context, args Sen
 
 		targetDifficulty *= float64(*args.Gas)
 
-		tx.CalculateWorkNonce(targetDifficulty)
+		if err := tx.CalculateWorkNonceCtx(ctx, targetDifficulty, 0); err != nil {
+			return common.Hash{}, err
+		}
 	}
 
 	signed, err := wallet.SignTx(account, tx, s.b.ChainConfig().ChainID)
@@ -1623,11 +1885,95 @@ func (s *PublicTransactionPoolAPI) FillTransaction(ctx context.Context, args Sen
 	return &SignTransactionResult{data, tx}, nil
 }
 
+// Latency hints accepted by PrepareTransaction, scaling the suggested difficulty.
+const (
+	LatencyFast   = "fast"   // Outbid the suggested difficulty for quicker inclusion
+	LatencyNormal = "normal" // Use the suggested difficulty
+	LatencySlow   = "slow"   // Use the minimum difficulty, included once the network is idle
+)
+
+// latencyFastFactor is the factor the suggested difficulty is raised by for
+// fast inclusion.
+const latencyFastFactor = 1.5
+
+// maxPrepareWorkTime bounds the time PrepareTransaction spends computing the
+// work nonce, as the method is public.
+const maxPrepareWorkTime = 2 * time.Second
+
+// PrepareTxArgs represents the arguments to prepare a transaction for signing.
+type PrepareTxArgs struct {
+	SendTxArgs
+	LatencyHint string `json:"latencyHint"` // One of fast, normal (default) or slow
+	ComputeWork *bool  `json:"computeWork"` // Whether to compute the work nonce (default true)
+}
+
+// PrepareTransactionResult is an unsigned transaction ready for signing, along
+// with the difficulty its work nonce targets.
+type PrepareTransactionResult struct {
+	Raw              hexutil.Bytes      `json:"raw"`
+	Tx               *types.Transaction `json:"tx"`
+	TargetDifficulty float64            `json:"targetDifficulty"`
+	WorkComputed     bool               `json:"workComputed"` // Whether the work nonce meets the target difficulty
+}
+
+// PrepareTransaction builds an unsigned transaction in one go: it fills the
+// nonce, estimates the gas, picks the target difficulty based on the latency
+// hint and computes the work nonce within a bounded time. If the work couldn't
+// be completed in time the best nonce found is returned and the caller may
+// continue the search from there.
+func (s *PublicTransactionPoolAPI) PrepareTransaction(ctx context.Context, args PrepareTxArgs) (*PrepareTransactionResult, error) {
+	hasWorkNonce := args.WorkNonce != nil
+
+	if err := args.setDefaults(ctx, s.b); err != nil {
+		return nil, err
+	}
+	tx := args.toTransaction()
+
+	var difficulty float64
+	switch args.LatencyHint {
+	case LatencySlow:
+		difficulty = s.b.MinGasPrice()
+	case "", LatencyNormal, LatencyFast:
+		suggested, err := DoSuggestDifficulty(ctx, s.b, s.b.MinGasPrice(), args.From)
+		if err != nil {
+			return nil, err
+		}
+		difficulty = suggested
+		if args.LatencyHint == LatencyFast {
+			difficulty *= latencyFastFactor
+		}
+	default:
+		return nil, fmt.Errorf("unknown latency hint %q", args.LatencyHint)
+	}
+	result := &PrepareTransactionResult{
+		TargetDifficulty: difficulty,
+		WorkComputed:     hasWorkNonce,
+	}
+	if !hasWorkNonce && (args.ComputeWork == nil || *args.ComputeWork) {
+		workCtx, cancel := context.WithTimeout(ctx, maxPrepareWorkTime)
+		err := tx.CalculateWorkNonceCtx(workCtx, difficulty*float64(*args.Gas), 0)
+		cancel()
+
+		// Running out of time is fine, but not the client going away
+		if err != nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		result.WorkComputed = err == nil
+	}
+	data, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return nil, err
+	}
+	result.Raw, result.Tx = data, tx
+
+	return result, nil
+}
+
 // SendRawTransaction will add the signed transaction to the transaction pool.
 // The sender is responsible for signing the transaction and using the correct nonce.
 func (s *PublicTransactionPoolAPI) SendRawTransaction(ctx context.Context, encodedTx hexutil.Bytes) (common.Hash, error) {
 	tx := new(types.Transaction)
-	if err := rlp.DecodeBytes(encodedTx, tx); err != nil {
+	if err := tx.UnmarshalBinary(encodedTx); err != nil {
 		return common.Hash{}, err
 	}
 	return SubmitTransaction(ctx, s.b, tx)
@@ -1734,7 +2080,9 @@ func (s *PublicTransactionPoolAPI) CalculateWorkNonce(ctx context.Context, args
 
 	// Assemble the transaction and calculate PoW
 	tx := args.toTransaction()
-	tx.CalculateWorkNonce(targetDifficulty)
+	if err := tx.CalculateWorkNonceCtx(ctx, targetDifficulty, 0); err != nil {
+		return nil, err
+	}
 
 	workNonce := tx.WorkNonce()
 	args.WorkNonce = (*hexutil.Uint64)(&workNonce)
@@ -1785,7 +2133,7 @@ func (s *PublicTransactionPoolAPI) Resend(ctx context.Context, sendArgs SendTxAr
 
 // GetAbiForAddress returns the ABI for a contract address
 func (s *PublicTransactionPoolAPI) GetAbiForAddress(ctx context.Context, addr common.Address) (string, error) {
-	ebakusState, _, err := s.b.EbakusStateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	ebakusState, header, err := s.b.EbakusStateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
 	if err != nil {
 		return "", err
 	}
@@ -1795,31 +2143,77 @@ func (s *PublicTransactionPoolAPI) GetAbiForAddress(ctx context.Context, addr co
 	}
 	defer ebakusState.Release()
 
-	return vm.GetAbiAtAddress(ebakusState, addr)
+	return vm.GetAbiAtAddress(ebakusState, s.b.ChainConfig(), header.Number, addr)
 }
 
+const (
+	// dbIteratorDeadline is the time an RPC iterator is retained without being
+	// read before it's released.
+	dbIteratorDeadline = 5 * time.Minute
+
+	// maxDBIterators is the number of RPC iterators retained at once, the least
+	// recently read one being released to make room for a new one.
+	maxDBIterators = 1000
+)
+
 type PublicDBAPI struct {
 	b                        Backend
 	ebakusStateIteratorsMap  map[uint64]*list.Element
-	ebakusStateIteratorsList *list.List
+	ebakusStateIteratorsList *list.List // Iterators, the most recently read first
 	ebakusStateIteratorsMux  sync.Mutex
+
+	cache *dbQueryCache // Cache of the rows returned by queries, nil if disabled
 }
 
 // NewPublicTransactionPoolAPI creates a new RPC service with methods specific for the transaction pool.
 func NewPublicDBAPI(b Backend) *PublicDBAPI {
-	return &PublicDBAPI{b: b, ebakusStateIteratorsMap: make(map[uint64]*list.Element, 0), ebakusStateIteratorsList: list.New()}
+	api := &PublicDBAPI{b: b, ebakusStateIteratorsMap: make(map[uint64]*list.Element, 0), ebakusStateIteratorsList: list.New(), cache: newDBQueryCache(b, b.EbakusdbQueryCache())}
+	go api.timeoutLoop()
+
+	return api
 }
 
 type ebakusStateIterator struct {
 	TableName string
-	Iter      *ebakusdb.ResultIterator
+	Iter      ebkdb.Iterator
 
 	Handle          uint64
 	ContractAddress common.Address
 	BlockNumber     uint64
+
+	query    dbQuery   // Query the iterator was created for, used as cache key
+	pos      uint64    // Position of the next row in the result set
+	skip     uint64    // Rows served from the cache which Iter hasn't moved past yet
+	lastUsed time.Time // Time the iterator was last read, to release it once idle
+}
+
+// timeoutLoop periodically releases the iterators which haven't been read for
+// longer than dbIteratorDeadline, so the clients abandoning iterators before
+// exhausting them don't pin their snapshots forever.
+func (api *PublicDBAPI) timeoutLoop() {
+	ticker := time.NewTicker(dbIteratorDeadline / 5)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		api.expireEbakusStateIterators(time.Now().Add(-dbIteratorDeadline))
+	}
 }
 
-func (api *PublicDBAPI) addEbakusStateIterator(tableName string, iter *ebakusdb.ResultIterator, contractAddress common.Address, blockNumber uint64) uint64 {
+// expireEbakusStateIterators releases the iterators last read before the cutoff.
+func (api *PublicDBAPI) expireEbakusStateIterators(cutoff time.Time) {
+	api.ebakusStateIteratorsMux.Lock()
+	defer api.ebakusStateIteratorsMux.Unlock()
+
+	for elem := api.ebakusStateIteratorsList.Back(); elem != nil; elem = api.ebakusStateIteratorsList.Back() {
+		tableIter := elem.Value.(*ebakusStateIterator)
+		if !tableIter.lastUsed.Before(cutoff) {
+			break
+		}
+		api.removeEbakusStateIterator(elem)
+	}
+}
+
+func (api *PublicDBAPI) addEbakusStateIterator(tableName string, iter ebkdb.Iterator, contractAddress common.Address, blockNumber uint64, query dbQuery) uint64 {
 	api.ebakusStateIteratorsMux.Lock()
 	defer api.ebakusStateIteratorsMux.Unlock()
 
@@ -1837,19 +2231,16 @@ func (api *PublicDBAPI) addEbakusStateIterator(tableName string, iter *ebakusdb.
 		Handle:          handle,
 		ContractAddress: contractAddress,
 		BlockNumber:     blockNumber,
+		query:           query,
+		lastUsed:        time.Now(),
 	}
 
 	elem := api.ebakusStateIteratorsList.PushFront(&tableIter)
 	api.ebakusStateIteratorsMap[tableIter.Handle] = elem
 
-	if uint64(api.ebakusStateIteratorsList.Len()) > api.b.EbakusdbMaxActiveIterators() {
-		oldestEntry := api.ebakusStateIteratorsList.Back()
-		if oldestEntry != nil {
-			oldestTableIter := oldestEntry.Value.(*ebakusStateIterator)
-			api.releaseEbakusStateIterator(oldestTableIter.Handle)
-		}
+	if api.ebakusStateIteratorsList.Len() > maxDBIterators {
+		api.removeEbakusStateIterator(api.ebakusStateIteratorsList.Back())
 	}
-
 	return tableIter.Handle
 }
 
@@ -1866,6 +2257,9 @@ func (api *PublicDBAPI) getEbakusStateIterator(handle uint64) (*ebakusStateItera
 	if !ok {
 		return nil, fmt.Errorf("Failed to find ebakusdb iterator")
 	}
+	stateIter.lastUsed = time.Now()
+	api.ebakusStateIteratorsList.MoveToFront(stateIterElem)
+
 	return stateIter, nil
 }
 
@@ -1874,24 +2268,82 @@ func (api *PublicDBAPI) releaseEbakusStateIterator(handle uint64) {
 	defer api.ebakusStateIteratorsMux.Unlock()
 
 	if elem, ok := api.ebakusStateIteratorsMap[handle]; ok {
-		delete(api.ebakusStateIteratorsMap, handle)
-		api.ebakusStateIteratorsList.Remove(elem)
+		api.removeEbakusStateIterator(elem)
 	}
 }
 
+// removeEbakusStateIterator drops an iterator from the set, releasing it. The
+// caller must hold ebakusStateIteratorsMux.
+func (api *PublicDBAPI) removeEbakusStateIterator(elem *list.Element) {
+	tableIter := api.ebakusStateIteratorsList.Remove(elem).(*ebakusStateIterator)
+	delete(api.ebakusStateIteratorsMap, tableIter.Handle)
+
+	tableIter.Iter.Release()
+}
+
 // Get returns EbakusDB table entry based on search criteria
 func (api *PublicDBAPI) Get(ctx context.Context, contractAddress common.Address, tableName string, whereClause string, orderClause string, blockNr rpc.BlockNumber) (interface{}, error) {
-	ebakusState, _, err := api.b.EbakusStateAndHeaderByNumber(ctx, rpc.BlockNumber(blockNr))
+	ebakusState, header, err := api.b.EbakusStateAndHeaderByNumber(ctx, rpc.BlockNumber(blockNr))
 	if err != nil {
 		return "", err
 	}
 
 	if ebakusState == nil {
-		return "", fmt.Errorf("Failed to find ebakusdb snapshot")
+		return api.odrGet(ctx, header, contractAddress, tableName, whereClause, orderClause)
 	}
 	defer ebakusState.Release()
 
-	return vm.EbakusDBGet(ebakusState, contractAddress, tableName, whereClause, orderClause)
+	key := dbQueryRow{dbQuery: dbQuery{header.Hash(), contractAddress, tableName, whereClause, orderClause}}
+	if row, ok := api.cache.get(key); ok {
+		return row, nil
+	}
+	row, err := vm.EbakusDBGet(ebakusState, contractAddress, tableName, whereClause, orderClause)
+	if err != nil {
+		return nil, err
+	}
+	api.cache.add(key, row)
+
+	return row, nil
+}
+
+// odrGet returns the first table entry matching the search criteria, retrieved
+// from the network by backends without a local ebakus state. The entries can't
+// be proven, so they aren't cached.
+func (api *PublicDBAPI) odrGet(ctx context.Context, header *types.Header, contractAddress common.Address, tableName string, whereClause string, orderClause string) (interface{}, error) {
+	tableABI, row, err := odrEbakusRow(ctx, api.b, header, contractAddress, tableName, whereClause, orderClause)
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, errors.New("no entry found in db")
+	}
+	obj, err := tableABI.GetTableInstance(tableName)
+	if err != nil {
+		return nil, err
+	}
+	if err := tableABI.Tables[tableName].Inputs.Unpack(obj, row); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// odrEbakusRow retrieves from the network the first row of a contract table
+// matching the where clause, along with the ABI of the table. The row is nil
+// if there is no match.
+func odrEbakusRow(ctx context.Context, b Backend, header *types.Header, contract common.Address, table string, whereClause string, orderClause string) (*abi.ABI, []byte, error) {
+	odr, ok := b.(EbakusOdrBackend)
+	if !ok || header == nil {
+		return nil, nil, fmt.Errorf("Failed to find ebakusdb snapshot")
+	}
+	tableABI, err := odr.EbakusTableABI(ctx, header, contract, table)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := odr.EbakusRows(ctx, header, contract, table, whereClause, orderClause, 1)
+	if err != nil || len(rows) == 0 {
+		return nil, nil, err
+	}
+	return tableABI, rows[0], nil
 }
 
 // Select returns EbakusDB table iterator based on search criteria
@@ -1906,12 +2358,13 @@ func (api *PublicDBAPI) Select(ctx context.Context, contractAddress common.Addre
 	}
 	defer ebakusState.Release()
 
-	iter, err := vm.EbakusDBSelect(ebakusState, contractAddress, tableName, whereClause, orderClause)
+	iter, err := vm.EbakusDBSelectRange(ebakusState, contractAddress, tableName, whereClause, orderClause, nil)
 	if err != nil {
 		return 0, err
 	}
 
-	handle := api.addEbakusStateIterator(tableName, iter, contractAddress, header.Number.Uint64())
+	query := dbQuery{header.Hash(), contractAddress, tableName, whereClause, orderClause}
+	handle := api.addEbakusStateIterator(tableName, iter, contractAddress, header.Number.Uint64(), query)
 
 	return hexutil.Uint64(handle), nil
 }
@@ -1922,22 +2375,131 @@ func (api *PublicDBAPI) Next(ctx context.Context, iter hexutil.Uint64) (interfac
 	if err != nil {
 		return nil, err
 	}
+	if err := api.checkIteratorLag(ctx, tableIter); err != nil {
+		return nil, err
+	}
 
-	ebakusState, _, err := api.b.EbakusStateAndHeaderByNumber(ctx, rpc.BlockNumber(tableIter.BlockNumber))
+	ebakusState, err := api.iteratorSnapshot(ctx, tableIter)
 	if err != nil {
 		return nil, err
 	}
+	defer ebakusState.Release()
 
-	if ebakusState == nil {
-		return nil, fmt.Errorf("Failed to find ebakusdb snapshot")
+	return api.next(ebakusState, tableIter)
+}
+
+// maxDBPageRows is the maximum number of rows returned by a single NextPage.
+const maxDBPageRows = 1000
+
+// DBPage is a batch of rows read through an EbakusDB table iterator, along
+// with the block of the snapshot they were read from.
+type DBPage struct {
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	Rows        []interface{}  `json:"rows"`
+	Done        bool           `json:"done"`
+}
+
+// NextPage returns up to count entries of an EbakusDB table iterator, tagged
+// with the block of the snapshot the iterator reads from, so clients paginating
+// across head changes can tell how old the data is. Done is set once the
+// iterator is exhausted and released.
+func (api *PublicDBAPI) NextPage(ctx context.Context, iter hexutil.Uint64, count hexutil.Uint64) (*DBPage, error) {
+	tableIter, err := api.getEbakusStateIterator(uint64(iter))
+	if err != nil {
+		return nil, err
+	}
+	if err := api.checkIteratorLag(ctx, tableIter); err != nil {
+		return nil, err
+	}
+	if count == 0 || count > maxDBPageRows {
+		count = maxDBPageRows
+	}
+
+	ebakusState, err := api.iteratorSnapshot(ctx, tableIter)
+	if err != nil {
+		return nil, err
 	}
 	defer ebakusState.Release()
 
-	// fmt.Println("\n1111", tableIter)
+	page := &DBPage{
+		BlockNumber: hexutil.Uint64(tableIter.BlockNumber),
+		BlockHash:   tableIter.query.Block,
+		Rows:        make([]interface{}, 0, count),
+	}
+	for len(page.Rows) < int(count) {
+		row, err := api.next(ebakusState, tableIter)
+		if err != nil {
+			return nil, err
+		}
+		if row == nil {
+			page.Done = true
+			break
+		}
+		page.Rows = append(page.Rows, row)
+	}
+	return page, nil
+}
+
+// checkIteratorLag fails and releases the iterator if its snapshot fell more
+// blocks behind the chain head than allowed.
+func (api *PublicDBAPI) checkIteratorLag(ctx context.Context, tableIter *ebakusStateIterator) error {
+	maxLag := api.b.EbakusdbMaxIteratorLag()
+	if maxLag == 0 {
+		return nil
+	}
+	head, err := api.b.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return err
+	}
+	if number := head.Number.Uint64(); number > tableIter.BlockNumber+maxLag {
+		api.releaseEbakusStateIterator(tableIter.Handle)
+		return fmt.Errorf("ebakusdb iterator snapshot at block %d is %d blocks behind head %d (max %d)", tableIter.BlockNumber, number-tableIter.BlockNumber, number, maxLag)
+	}
+	return nil
+}
+
+// iteratorSnapshot retrieves the snapshot of the block the iterator was created
+// on, by hash so a reorg in the meantime can't switch it to another block.
+func (api *PublicDBAPI) iteratorSnapshot(ctx context.Context, tableIter *ebakusStateIterator) (ebkdb.State, error) {
+	ebakusState, _, err := api.b.EbakusStateAndHeaderByNumberOrHash(ctx, rpc.BlockNumberOrHashWithHash(tableIter.query.Block, false))
+	if err != nil {
+		return nil, err
+	}
 
+	if ebakusState == nil {
+		return nil, fmt.Errorf("Failed to find ebakusdb snapshot")
+	}
+	return ebakusState, nil
+}
+
+// next returns the next entry of a table iterator, releasing it once exhausted.
+func (api *PublicDBAPI) next(ebakusState ebkdb.State, tableIter *ebakusStateIterator) (interface{}, error) {
+	// Serve the row from the cache if an identical query already went past it,
+	// remembering that the underlying iterator fell behind
+	key := dbQueryRow{dbQuery: tableIter.query, Pos: tableIter.pos}
+	if ret, ok := api.cache.get(key); ok {
+		tableIter.pos++
+		tableIter.skip++
+		if ret == nil {
+			api.releaseEbakusStateIterator(tableIter.Handle)
+		}
+		return ret, nil
+	}
+	for ; tableIter.skip > 0; tableIter.skip-- {
+		if _, err := vm.EbakusDBNext(ebakusState, tableIter.ContractAddress, tableIter.TableName, tableIter.Iter); err != nil {
+			return nil, err
+		}
+	}
 	ret, err := vm.EbakusDBNext(ebakusState, tableIter.ContractAddress, tableIter.TableName, tableIter.Iter)
+	if err != nil {
+		return nil, err
+	}
+	api.cache.add(key, ret)
+	tableIter.pos++
+
 	if ret == nil {
-		api.releaseEbakusStateIterator(uint64(iter))
+		api.releaseEbakusStateIterator(tableIter.Handle)
 	}
 
 	return ret, nil
@@ -1992,6 +2554,75 @@ func (api *PublicDebugAPI) SeedHash(ctx context.Context, number uint64) (string,
 	return fmt.Sprintf("0x%x", ethash.SeedHash(number)), nil
 }
 
+// DBQueryPlan describes how a select on an ebakusdb table would be executed.
+type DBQueryPlan struct {
+	Table         string         `json:"table"`
+	Index         string         `json:"index"`
+	Scan          string         `json:"scan"`
+	Reverse       bool           `json:"reverse"`
+	EstimatedRows hexutil.Uint64 `json:"estimatedRows"`
+	EstimatedGas  hexutil.Uint64 `json:"estimatedGas"`
+}
+
+// maxDBQueryPlanRows caps the rows ExplainDBQuery counts for its estimate.
+const maxDBQueryPlanRows = 10000
+
+// ExplainDBQuery returns the index ebakusdb walks for a select with the given
+// clauses, the type of scan and an estimate of the rows it iterates. The rows
+// are counted off the contract, up to maxDBQueryPlanRows. The gas estimate
+// covers the select and iterating all rows from within a contract.
+func (api *PublicDebugAPI) ExplainDBQuery(ctx context.Context, contractAddress common.Address, tableName string, whereClause string, orderClause string, blockNr rpc.BlockNumber) (*DBQueryPlan, error) {
+	ebakusState, _, err := api.b.EbakusStateAndHeaderByNumber(ctx, blockNr)
+	if err != nil {
+		return nil, err
+	}
+
+	if ebakusState == nil {
+		return nil, fmt.Errorf("Failed to find ebakusdb snapshot")
+	}
+	defer ebakusState.Release()
+
+	plan, err := vm.EbakusDBExplain(ebakusState, contractAddress, tableName, whereClause, orderClause)
+	if err != nil {
+		return nil, err
+	}
+	estimate, err := countDBQueryRows(ebakusState, contractAddress, tableName, whereClause, orderClause)
+	if err != nil {
+		return nil, err
+	}
+	return &DBQueryPlan{
+		Table:         tableName,
+		Index:         plan.Index,
+		Scan:          string(plan.Scan),
+		Reverse:       plan.Reverse,
+		EstimatedRows: hexutil.Uint64(estimate),
+		EstimatedGas:  hexutil.Uint64(params.DBContractSelectGas + estimate*params.DBContractNextGas),
+	}, nil
+}
+
+// countDBQueryRows counts the rows a select iterates, up to maxDBQueryPlanRows.
+func countDBQueryRows(ebakusState ebkdb.State, contractAddress common.Address, tableName string, whereClause string, orderClause string) (uint64, error) {
+	tableABI, err := vm.GetAbiForTable(ebakusState, contractAddress, tableName)
+	if err != nil {
+		return 0, err
+	}
+	obj, err := tableABI.GetTableInstance(tableName)
+	if err != nil {
+		return 0, err
+	}
+	iter, err := vm.EbakusDBSelect(ebakusState, contractAddress, tableName, whereClause, orderClause)
+	if err != nil {
+		return 0, err
+	}
+	defer iter.Release()
+
+	var rows uint64
+	for rows < maxDBQueryPlanRows && iter.Next(obj) {
+		rows++
+	}
+	return rows, nil
+}
+
 // PrivateDebugAPI is the collection of Ebakus APIs exposed over the private
 // debugging endpoint.
 type PrivateDebugAPI struct {
@@ -2027,9 +2658,18 @@ func (api *PrivateDebugAPI) ChaindbCompact() error {
 	return nil
 }
 
-// SetHead rewinds the head of the blockchain to a previous block.
-func (api *PrivateDebugAPI) SetHead(number hexutil.Uint64) {
-	api.b.SetHead(uint64(number))
+// EbakusSnapshots returns the live ebakusdb snapshots grouped by the code which
+// created them, the most numerous first, for tracking down the ones which are
+// never released.
+func (api *PrivateDebugAPI) EbakusSnapshots() []*ebkdb.SnapshotOwner {
+	return ebkdb.LiveSnapshots()
+}
+
+// SetHead rewinds the head of the blockchain to a previous block, dropping the
+// blocks past it along with their ebakus snapshots and resetting the transaction
+// pool and the chain indexes. The block must have its state retained.
+func (api *PrivateDebugAPI) SetHead(number hexutil.Uint64) error {
+	return api.b.SetHead(uint64(number))
 }
 
 // PublicNetAPI offers network related RPC methods
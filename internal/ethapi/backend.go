@@ -20,12 +20,14 @@ package ethapi
 import (
 	"context"
 	"math/big"
+	"time"
 
-	"github.com/ebakus/ebakusdb"
 	"github.com/ebakus/go-ebakus/accounts"
+	"github.com/ebakus/go-ebakus/accounts/abi"
 	"github.com/ebakus/go-ebakus/common"
 	"github.com/ebakus/go-ebakus/core"
 	"github.com/ebakus/go-ebakus/core/bloombits"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/state"
 	"github.com/ebakus/go-ebakus/core/types"
 	"github.com/ebakus/go-ebakus/core/vm"
@@ -43,16 +45,21 @@ type Backend interface {
 	Downloader() *downloader.Downloader
 	ProtocolVersion() int
 	SuggestPrice(ctx context.Context) (*float64, error)
+	SuggestVirtualDifficulty(ctx context.Context) (float64, error)
 	ChainDb() ethdb.Database
 	EventMux() *event.TypeMux
 	AccountManager() *accounts.Manager
 	ExtRPCEnabled() bool
-	RPCGasCap() *big.Int // global gas cap for eth_call over rpc: DoS protection
+	RPCGasCap() *big.Int          // global gas cap for eth_call over rpc: DoS protection
+	RPCEVMTimeout() time.Duration // global timeout for eth_call over rpc: DoS protection
+	RPCDBRowsCap() uint64         // global ebakus db rows cap for eth_call over rpc: DoS protection
+	RPCLogsMaxBlocks() uint64     // global cap of the blocks searched by eth_getLogs: DoS protection
 	MinGasPrice() float64
-	EbakusdbMaxActiveIterators() uint64
+	EbakusdbQueryCache() int
+	EbakusdbMaxIteratorLag() uint64
 
 	// Blockchain API
-	SetHead(number uint64)
+	SetHead(number uint64) error
 	HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error)
 	HeaderByHash(ctx context.Context, blockHash common.Hash) (*types.Header, error)
 	HeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Header, error)
@@ -61,11 +68,11 @@ type Backend interface {
 	BlockByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, error)
 	StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error)
 	StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error)
-	EbakusStateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*ebakusdb.Snapshot, *types.Header, error)
-	EbakusStateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*ebakusdb.Snapshot, *types.Header, error)
+	EbakusStateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (ebkdb.State, *types.Header, error)
+	EbakusStateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (ebkdb.State, *types.Header, error)
 	GetBlockAuthor(header *types.Header) (common.Address, error)
 	GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error)
-	GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, ebakusState *ebakusdb.Snapshot, header *types.Header) (*vm.EVM, func() error, error)
+	GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, ebakusState ebkdb.State, header *types.Header) (*vm.EVM, func() error, error)
 	SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription
 	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
 	SubscribeChainSideEvent(ch chan<- core.ChainSideEvent) event.Subscription
@@ -91,8 +98,24 @@ type Backend interface {
 	CurrentBlock() *types.Block
 }
 
+// EbakusOdrBackend is implemented by the backends without a local ebakus state,
+// which retrieve the rows of the ebakusdb tables from the network instead.
+type EbakusOdrBackend interface {
+	// EbakusRows retrieves up to limit rows of a contract table at the given
+	// block, ABI encoded with the schema of the table.
+	EbakusRows(ctx context.Context, header *types.Header, contract common.Address, table string, whereClause string, orderClause string, limit uint64) ([][]byte, error)
+
+	// EbakusTableABI retrieves the ABI of a contract table at the given block.
+	EbakusTableABI(ctx context.Context, header *types.Header, contract common.Address, table string) (*abi.ABI, error)
+}
+
 func GetAPIs(apiBackend Backend) []rpc.API {
 	nonceLock := new(AddrLocker)
+
+	// The contract tables are queried in both the db and ebakus namespaces,
+	// sharing the same iterators
+	dbAPI := NewPublicDBAPI(apiBackend)
+
 	return []rpc.API{
 		{
 			Namespace: "eth",
@@ -136,7 +159,22 @@ func GetAPIs(apiBackend Backend) []rpc.API {
 		}, {
 			Namespace: "db",
 			Version:   "1.0",
-			Service:   NewPublicDBAPI(apiBackend),
+			Service:   dbAPI,
+			Public:    true,
+		}, {
+			Namespace: "ebakus",
+			Version:   "1.0",
+			Service:   dbAPI,
+			Public:    true,
+		}, {
+			Namespace: "ebakus",
+			Version:   "1.0",
+			Service:   NewPublicDecoderAPI(apiBackend),
+			Public:    true,
+		}, {
+			Namespace: "ebakus",
+			Version:   "1.0",
+			Service:   NewPublicStakingAPI(apiBackend),
 			Public:    true,
 		},
 	}
@@ -0,0 +1,138 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ebakus/go-ebakus/common/hexutil"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/core/vm"
+	"github.com/ebakus/go-ebakus/params"
+	"github.com/ebakus/go-ebakus/rpc"
+)
+
+// defaultHashrates are the proof of work levels, in hashes per second, the
+// capacity is simulated at if none are given, from browsers to dedicated
+// machines.
+var defaultHashrates = []float64{1e3, 1e4, 1e5, 1e6, 1e7}
+
+// maxHashrates is the number of proof of work levels a simulation accepts.
+const maxHashrates = 64
+
+// CapacityLevel is the sustainable transaction rate of an account at a proof of
+// work level.
+type CapacityLevel struct {
+	Hashrate    float64 `json:"hashrate"`    // Hashes per second the account computes
+	TxPerSecond float64 `json:"txPerSecond"` // Transactions per second the account sustains
+}
+
+// CapacitySimulation is the projected capacity of an account for a hypothetical
+// stake.
+type CapacitySimulation struct {
+	Stake             hexutil.Uint64   `json:"stake"`             // Stake of the account
+	SystemStake       hexutil.Uint64   `json:"systemStake"`       // System stake, the account's included
+	VirtualCapacity   float64          `json:"virtualCapacity"`   // Multiplier of the work difficulty into the virtual one
+	VirtualDifficulty float64          `json:"virtualDifficulty"` // Virtual difficulty per gas the transactions have to reach
+	WorkDifficulty    float64          `json:"workDifficulty"`    // Work difficulty per gas the account has to target
+	Gas               hexutil.Uint64   `json:"gas"`               // Gas of each transaction
+	MaxTxPerSecond    float64          `json:"maxTxPerSecond"`    // Transactions per second the blocks fit
+	Levels            []*CapacityLevel `json:"levels"`
+}
+
+// SimulateCapacity projects the capacity an account staking the given amount
+// would have, for businesses to size their stake.
+//
+// The stake is added to the system stake, which is the current one unless
+// given, and the virtual capacity follows as for the actual accounts. Like the
+// miners order the transactions, the work difficulty needed is the suggested
+// virtual difficulty divided by the virtual capacity. As reaching a difficulty
+// takes as many hashes on average, the sustainable transaction rate at each
+// hashrate is the hashrate over the work per transaction, capped by the gas the
+// blocks fit.
+func (api *PublicStakingAPI) SimulateCapacity(ctx context.Context, stake hexutil.Uint64, systemStake *hexutil.Uint64, gas *hexutil.Uint64, hashrates []float64) (*CapacitySimulation, error) {
+	if len(hashrates) == 0 {
+		hashrates = defaultHashrates
+	}
+	if len(hashrates) > maxHashrates {
+		return nil, fmt.Errorf("too many hashrates, at most %d allowed", maxHashrates)
+	}
+	txGas := params.TxGas
+	if gas != nil {
+		txGas = uint64(*gas)
+	}
+	if txGas == 0 {
+		return nil, errors.New("transaction gas must be positive")
+	}
+	ebakusState, header, err := api.b.EbakusStateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if ebakusState == nil {
+		return nil, fmt.Errorf("Failed to find ebakusdb snapshot")
+	}
+	system := vm.GetSystemStake(ebakusState)
+	ebakusState.Release()
+
+	if systemStake != nil {
+		system = uint64(*systemStake)
+	}
+	system += uint64(stake)
+
+	required, err := api.b.SuggestVirtualDifficulty(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if required < types.MinimumVirtualDifficulty {
+		required = types.MinimumVirtualDifficulty
+	}
+	cv := types.StakeCapacity(uint64(stake), system)
+
+	difficulty := required / cv
+	if min := api.b.MinGasPrice(); difficulty < min {
+		difficulty = min
+	}
+	period := uint64(1)
+	if config := api.b.ChainConfig().DPOS; config != nil && config.Period > 0 {
+		period = config.Period
+	}
+	maxRate := float64(header.GasLimit) / float64(period) / float64(txGas)
+
+	simulation := &CapacitySimulation{
+		Stake:             stake,
+		SystemStake:       hexutil.Uint64(system),
+		VirtualCapacity:   cv,
+		VirtualDifficulty: required,
+		WorkDifficulty:    difficulty,
+		Gas:               hexutil.Uint64(txGas),
+		MaxTxPerSecond:    maxRate,
+	}
+	work := difficulty * float64(txGas)
+	for _, hashrate := range hashrates {
+		if hashrate < 0 {
+			return nil, fmt.Errorf("invalid hashrate %v", hashrate)
+		}
+		rate := maxRate
+		if work > 0 && hashrate/work < rate {
+			rate = hashrate / work
+		}
+		simulation.Levels = append(simulation.Levels, &CapacityLevel{Hashrate: hashrate, TxPerSecond: rate})
+	}
+	return simulation, nil
+}
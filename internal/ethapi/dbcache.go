@@ -0,0 +1,101 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/metrics"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+var (
+	dbCacheHitMeter  = metrics.NewRegisteredMeter("rpc/db/cache/hit", nil)
+	dbCacheMissMeter = metrics.NewRegisteredMeter("rpc/db/cache/miss", nil)
+)
+
+// dbQuery identifies a query against the ebakusdb snapshot of a block.
+type dbQuery struct {
+	Block    common.Hash
+	Contract common.Address
+	Table    string
+	Where    string
+	Order    string
+}
+
+// dbQueryRow identifies a row returned by a query, by its position in the
+// result set of a select, or 0 for a get.
+type dbQueryRow struct {
+	dbQuery
+	Pos uint64
+}
+
+// dbQueryCache is an LRU cache of the rows returned by db RPC queries. The
+// snapshot of a block never changes, but the cache is flushed on every new
+// head anyway, as hot queries are usually against the latest block and the
+// old entries would only crowd it.
+type dbQueryCache struct {
+	rows *lru.Cache
+}
+
+// newDBQueryCache creates a cache of the given size, flushed on the chain head
+// events of the backend. A nil cache is returned if size is 0, which is safe
+// to use and caches nothing.
+func newDBQueryCache(b Backend, size int) *dbQueryCache {
+	if size <= 0 {
+		return nil
+	}
+	rows, _ := lru.New(size)
+	cache := &dbQueryCache{rows: rows}
+
+	heads := make(chan core.ChainHeadEvent, 10)
+	sub := b.SubscribeChainHeadEvent(heads)
+	go func() {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case <-heads:
+				cache.rows.Purge()
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+	return cache
+}
+
+// get retrieves a cached row, reporting whether it was found.
+func (c *dbQueryCache) get(key dbQueryRow) (interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+	row, ok := c.rows.Get(key)
+	if ok {
+		dbCacheHitMeter.Mark(1)
+	} else {
+		dbCacheMissMeter.Mark(1)
+	}
+	return row, ok
+}
+
+// add caches a row returned by a query.
+func (c *dbQueryCache) add(key dbQueryRow, row interface{}) {
+	if c == nil {
+		return
+	}
+	c.rows.Add(key, row)
+}
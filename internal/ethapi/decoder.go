@@ -0,0 +1,195 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ebakus/go-ebakus/accounts/abi"
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/core/vm"
+	"github.com/ebakus/go-ebakus/rpc"
+)
+
+// DecodedArgument is an argument of a call or an event, named after the ABI.
+type DecodedArgument struct {
+	Name    string      `json:"name"`
+	Type    string      `json:"type"`
+	Indexed bool        `json:"indexed,omitempty"`
+	Value   interface{} `json:"value"`
+}
+
+// DecodedLog is a log decoded with the ABI of the contract which emitted it.
+type DecodedLog struct {
+	Address   common.Address     `json:"address"`
+	Event     string             `json:"event"`
+	Signature string             `json:"signature"`
+	Args      []*DecodedArgument `json:"args"`
+}
+
+// DecodedTx is the calldata of a transaction decoded with the ABI of the
+// contract it calls.
+type DecodedTx struct {
+	Hash      common.Hash        `json:"hash"`
+	To        common.Address     `json:"to"`
+	Method    string             `json:"method"`
+	Signature string             `json:"signature"`
+	Args      []*DecodedArgument `json:"args"`
+}
+
+// PublicDecoderAPI decodes logs and transactions into named fields using the
+// ABIs stored on-chain, so clients need no local copy of them.
+type PublicDecoderAPI struct {
+	b Backend
+}
+
+// NewPublicDecoderAPI creates a new API definition for the ABI decoding methods.
+func NewPublicDecoderAPI(b Backend) *PublicDecoderAPI {
+	return &PublicDecoderAPI{b: b}
+}
+
+// contractABI returns the ABI a contract had stored at the given block.
+func (api *PublicDecoderAPI) contractABI(ctx context.Context, contract common.Address, block rpc.BlockNumberOrHash) (*abi.ABI, error) {
+	ebakusState, header, err := api.b.EbakusStateAndHeaderByNumberOrHash(ctx, block)
+	if err != nil {
+		return nil, err
+	}
+	if ebakusState == nil {
+		return nil, fmt.Errorf("Failed to find ebakusdb snapshot")
+	}
+	defer ebakusState.Release()
+
+	abiString, err := vm.GetAbiAtAddress(ebakusState, api.b.ChainConfig(), header.Number, contract)
+	if err != nil {
+		return nil, err
+	}
+	contractABI, err := abi.JSON(strings.NewReader(abiString))
+	if err != nil {
+		return nil, err
+	}
+	return &contractABI, nil
+}
+
+// DecodeLog decodes a log with the ABI its contract had stored at the block of
+// the log, or at the latest block for logs not yet included.
+func (api *PublicDecoderAPI) DecodeLog(ctx context.Context, log types.Log) (*DecodedLog, error) {
+	if len(log.Topics) == 0 {
+		return nil, errors.New("anonymous events can't be decoded")
+	}
+	block := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+	if log.BlockHash != (common.Hash{}) {
+		block = rpc.BlockNumberOrHashWithHash(log.BlockHash, false)
+	}
+	contractABI, err := api.contractABI(ctx, log.Address, block)
+	if err != nil {
+		return nil, err
+	}
+	event, err := contractABI.EventByID(log.Topics[0])
+	if err != nil {
+		return nil, err
+	}
+	values, err := event.Inputs.UnpackValues(log.Data)
+	if err != nil {
+		return nil, err
+	}
+	decoded := &DecodedLog{
+		Address:   log.Address,
+		Event:     event.RawName,
+		Signature: event.Sig(),
+		Args:      make([]*DecodedArgument, 0, len(event.Inputs)),
+	}
+	topics := log.Topics[1:]
+	for _, input := range event.Inputs {
+		arg := &DecodedArgument{Name: input.Name, Type: input.Type.String(), Indexed: input.Indexed}
+		if input.Indexed {
+			if len(topics) == 0 {
+				return nil, errors.New("missing indexed event topics")
+			}
+			if arg.Value, err = decodeTopic(input, topics[0]); err != nil {
+				return nil, err
+			}
+			topics = topics[1:]
+		} else {
+			arg.Value, values = values[0], values[1:]
+		}
+		decoded.Args = append(decoded.Args, arg)
+	}
+	return decoded, nil
+}
+
+// decodeTopic decodes an indexed event argument. Dynamic types are indexed by
+// their hash, which is returned as is.
+func decodeTopic(input abi.Argument, topic common.Hash) (interface{}, error) {
+	switch input.Type.T {
+	case abi.StringTy, abi.BytesTy, abi.SliceTy, abi.ArrayTy, abi.TupleTy:
+		return topic, nil
+	}
+	input.Indexed = false
+
+	values, err := abi.Arguments{input}.UnpackValues(topic[:])
+	if err != nil {
+		return nil, err
+	}
+	return values[0], nil
+}
+
+// DecodeTx decodes the calldata of a transaction with the ABI its recipient
+// had stored at the block of the transaction, or at the latest block for
+// pending ones.
+func (api *PublicDecoderAPI) DecodeTx(ctx context.Context, hash common.Hash) (*DecodedTx, error) {
+	block := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+
+	tx, blockHash, _, _, err := api.b.GetTransaction(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if tx != nil {
+		block = rpc.BlockNumberOrHashWithHash(blockHash, false)
+	} else if tx = api.b.GetPoolTransaction(hash); tx == nil {
+		return nil, fmt.Errorf("transaction %#x not found", hash)
+	}
+	if tx.To() == nil {
+		return nil, errors.New("contract creations can't be decoded")
+	}
+	contractABI, err := api.contractABI(ctx, *tx.To(), block)
+	if err != nil {
+		return nil, err
+	}
+	method, err := contractABI.MethodById(tx.Data())
+	if err != nil {
+		return nil, err
+	}
+	values, err := method.Inputs.UnpackValues(tx.Data()[4:])
+	if err != nil {
+		return nil, err
+	}
+	decoded := &DecodedTx{
+		Hash:      hash,
+		To:        *tx.To(),
+		Method:    method.RawName,
+		Signature: method.Sig(),
+		Args:      make([]*DecodedArgument, len(method.Inputs)),
+	}
+	for i, input := range method.Inputs {
+		decoded.Args[i] = &DecodedArgument{Name: input.Name, Type: input.Type.String(), Value: values[i]}
+	}
+	return decoded, nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/common/hexutil"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/core/vm"
+	"github.com/ebakus/go-ebakus/rpc"
+)
+
+// StakePreview is the outcome a stake or unstake would have at the head of the
+// chain, so wallets can confirm it with the user before computing the work.
+type StakePreview struct {
+	Amount          hexutil.Uint64 `json:"amount"`                // Amount to stake or unstake
+	Claimed         hexutil.Uint64 `json:"claimed"`               // Vested amount claimed to the balance first
+	Reused          hexutil.Uint64 `json:"reused"`                // Vesting claimable amount restaked instead of transferred
+	Transferred     hexutil.Uint64 `json:"transferred"`           // Amount transferred from the balance
+	Staked          hexutil.Uint64 `json:"staked"`                // Amount staked afterwards
+	Claimable       hexutil.Uint64 `json:"claimable,omitempty"`   // Amount of the claimable entry an unstake creates
+	ClaimableAt     hexutil.Uint64 `json:"claimableAt,omitempty"` // Time the claimable entry an unstake creates vests at
+	VirtualCapacity float64        `json:"virtualCapacity"`       // Virtual capacity of the account afterwards
+	Error           string         `json:"error,omitempty"`       // Error the operation would fail with
+}
+
+// PublicStakingAPI previews the staking operations of the system contract and
+// the capacity they grant.
+type PublicStakingAPI struct {
+	b Backend
+}
+
+// NewPublicStakingAPI creates a new API definition for the staking previews and
+// the capacity simulations.
+func NewPublicStakingAPI(b Backend) *PublicStakingAPI {
+	return &PublicStakingAPI{b: b}
+}
+
+// PreviewStake returns how staking amount would be funded, from the vesting
+// claimable entries first and the balance for the rest, along with the stake
+// and the virtual capacity of the account afterwards. An operation that would
+// fail isn't an RPC error, but is reported in the preview instead.
+func (api *PublicStakingAPI) PreviewStake(ctx context.Context, address common.Address, amount hexutil.Uint64) (*StakePreview, error) {
+	return api.preview(ctx, address, amount, vm.PreviewStake)
+}
+
+// PreviewUnstake returns the stake and the virtual capacity of the account after
+// unstaking amount, along with the claimable entry it would create.
+func (api *PublicStakingAPI) PreviewUnstake(ctx context.Context, address common.Address, amount hexutil.Uint64) (*StakePreview, error) {
+	return api.preview(ctx, address, amount, vm.PreviewUnstake)
+}
+
+// preview runs a staking operation against copies of the states at the head of
+// the chain.
+func (api *PublicStakingAPI) preview(ctx context.Context, address common.Address, amount hexutil.Uint64, run func(*vm.EVM, common.Address, uint64) (*vm.StakePreview, error)) (*StakePreview, error) {
+	state, header, err := api.b.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	ebakusState, header, err := api.b.EbakusStateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if ebakusState == nil {
+		return nil, fmt.Errorf("Failed to find ebakusdb snapshot")
+	}
+	defer ebakusState.Release()
+
+	msg := types.NewMessage(address, &types.PrecompliledSystemContract, 0, new(big.Int), math.MaxUint64/2, big.NewInt(0), nil, false)
+	evm, _, err := api.b.GetEVM(ctx, msg, state, ebakusState, header)
+	if err != nil {
+		return nil, err
+	}
+	result := &StakePreview{Amount: amount}
+
+	preview, err := run(evm, address, uint64(amount))
+	if preview != nil {
+		result.Claimed = hexutil.Uint64(preview.Claimed)
+		result.Reused = hexutil.Uint64(preview.Reused)
+		result.Transferred = hexutil.Uint64(preview.Transferred)
+		result.Staked = hexutil.Uint64(preview.Staked)
+		result.Claimable = hexutil.Uint64(preview.Claimable)
+		result.ClaimableAt = hexutil.Uint64(preview.ClaimableAt)
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.VirtualCapacity = types.VirtualCapacity(address, ebakusState)
+	return result, nil
+}
@@ -0,0 +1,218 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/common/hexutil"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/core/vm"
+	"github.com/ebakus/go-ebakus/rpc"
+)
+
+const (
+	maxStateDiffChanges   = 1000   // Maximum number of changes returned by a single page
+	maxStateDiffTableRows = 100000 // Maximum number of rows read from a table snapshot
+)
+
+// Kinds of row changes reported by a state diff.
+const (
+	rowAdded    = "added"
+	rowModified = "modified"
+	rowDeleted  = "deleted"
+)
+
+var errStateDiffTooLarge = fmt.Errorf("table exceeds %d rows, too large to diff", maxStateDiffTableRows)
+
+// StateDiffTable selects an ebakusdb table to diff. System contract tables
+// (e.g. Staked, Delegations, Witnesses) are selected through the system
+// contract address.
+type StateDiffTable struct {
+	Contract common.Address `json:"contract"`
+	Table    string         `json:"table"`
+}
+
+// StateRowChange is a row added, modified or deleted between two snapshots.
+type StateRowChange struct {
+	Contract common.Address `json:"contract"`
+	Table    string         `json:"table"`
+	Kind     string         `json:"kind"`
+	Id       interface{}    `json:"id"`
+	Before   interface{}    `json:"before,omitempty"`
+	After    interface{}    `json:"after,omitempty"`
+}
+
+// StateDiff is a page of the row changes between the snapshots of two blocks.
+type StateDiff struct {
+	From    common.Hash       `json:"from"`
+	To      common.Hash       `json:"to"`
+	Changes []*StateRowChange `json:"changes"`
+	Next    *hexutil.Uint64   `json:"next"` // Offset of the next page, nil if done
+}
+
+// diffRow is a row read from a table snapshot, keyed by its encoded primary key.
+type diffRow struct {
+	id   interface{}
+	data []byte
+	obj  interface{}
+}
+
+// EbakusStateDiff returns the rows of the given tables which got added, modified
+// or deleted between the ebakusdb snapshots of two blocks, allowing to audit
+// exactly what a set of transactions changed. Changes are ordered by table and
+// primary key and are paginated; pass the returned next offset to get the
+// following page.
+func (api *PublicDebugAPI) EbakusStateDiff(ctx context.Context, blockA, blockB rpc.BlockNumberOrHash, tables []StateDiffTable, offset hexutil.Uint64) (*StateDiff, error) {
+	if len(tables) == 0 {
+		return nil, errors.New("no tables selected")
+	}
+	stateA, headerA, err := api.b.EbakusStateAndHeaderByNumberOrHash(ctx, blockA)
+	if err != nil {
+		return nil, err
+	}
+	if stateA == nil {
+		return nil, fmt.Errorf("Failed to find ebakusdb snapshot")
+	}
+	defer stateA.Release()
+
+	stateB, headerB, err := api.b.EbakusStateAndHeaderByNumberOrHash(ctx, blockB)
+	if err != nil {
+		return nil, err
+	}
+	if stateB == nil {
+		return nil, fmt.Errorf("Failed to find ebakusdb snapshot")
+	}
+	defer stateB.Release()
+
+	diff := &StateDiff{From: headerA.Hash(), To: headerB.Hash(), Changes: []*StateRowChange{}}
+
+	skip := uint64(offset)
+	for _, table := range tables {
+		changes, err := diffTable(stateA, stateB, table)
+		if err != nil {
+			return nil, fmt.Errorf("table %s of %x: %v", table.Table, table.Contract, err)
+		}
+		if skip >= uint64(len(changes)) {
+			skip -= uint64(len(changes))
+			continue
+		}
+		changes = changes[skip:]
+		skip = 0
+
+		room := maxStateDiffChanges - len(diff.Changes)
+		if len(changes) > room {
+			diff.Changes = append(diff.Changes, changes[:room]...)
+			next := hexutil.Uint64(uint64(offset) + maxStateDiffChanges)
+			diff.Next = &next
+			break
+		}
+		diff.Changes = append(diff.Changes, changes...)
+	}
+	return diff, nil
+}
+
+// diffTable returns the row changes of a table between two snapshots, ordered
+// by primary key.
+func diffTable(stateA, stateB ebkdb.State, table StateDiffTable) ([]*StateRowChange, error) {
+	rowsA, err := readDiffRows(stateA, table)
+	if err != nil {
+		return nil, err
+	}
+	rowsB, err := readDiffRows(stateB, table)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(rowsA)+len(rowsB))
+	for key := range rowsA {
+		keys = append(keys, key)
+	}
+	for key := range rowsB {
+		if _, ok := rowsA[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []*StateRowChange
+	for _, key := range keys {
+		before, after := rowsA[key], rowsB[key]
+
+		change := &StateRowChange{Contract: table.Contract, Table: table.Table}
+		switch {
+		case before == nil:
+			change.Kind, change.Id, change.After = rowAdded, after.id, after.obj
+		case after == nil:
+			change.Kind, change.Id, change.Before = rowDeleted, before.id, before.obj
+		case !bytes.Equal(before.data, after.data):
+			change.Kind, change.Id, change.Before, change.After = rowModified, after.id, before.obj, after.obj
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// readDiffRows reads all the rows of a table snapshot, keyed by their primary key.
+// Tables missing from the snapshot are considered empty.
+func readDiffRows(state ebkdb.State, table StateDiffTable) (map[string]*diffRow, error) {
+	rows := make(map[string]*diffRow)
+
+	tableABI, err := vm.GetAbiForTable(state, table.Contract, table.Table)
+	if err != nil {
+		return rows, nil
+	}
+	iter, err := vm.EbakusDBSelect(state, table.Contract, table.Table, "", "")
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Release()
+
+	for {
+		obj, err := tableABI.GetTableInstance(table.Table)
+		if err != nil {
+			return nil, err
+		}
+		if !iter.Next(obj) {
+			return rows, nil
+		}
+		if len(rows) >= maxStateDiffTableRows {
+			return nil, errStateDiffTooLarge
+		}
+		id := reflect.Indirect(reflect.ValueOf(obj)).FieldByName("Id")
+		if !id.IsValid() {
+			return nil, errors.New("table has no Id field")
+		}
+		key, err := json.Marshal(id.Interface())
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		rows[string(key)] = &diffRow{id: id.Interface(), data: data, obj: obj}
+	}
+}
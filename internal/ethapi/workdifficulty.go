@@ -0,0 +1,191 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/common/hexutil"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/rpc"
+)
+
+const (
+	defaultInclusionBlocks = 3   // Inclusion window of the work difficulty estimates if none is given
+	maxInclusionBlocks     = 256 // Largest inclusion window of the work difficulty estimates
+
+	recentFillBlocks   = 20  // Number of recent blocks whose fill level is averaged
+	congestedBlockFill = 0.8 // Fill level over which the recent blocks are considered congested
+)
+
+// WorkDifficultyEstimate is the work difficulty a transaction needs to target
+// to be included within the requested number of blocks, along with the figures
+// it's derived from.
+type WorkDifficultyEstimate struct {
+	Difficulty        float64        `json:"difficulty"`        // Work difficulty per gas to target
+	VirtualDifficulty float64        `json:"virtualDifficulty"` // Virtual difficulty per gas the transaction has to reach
+	VirtualCapacity   float64        `json:"virtualCapacity"`   // Virtual capacity of the sender
+	Gas               hexutil.Uint64 `json:"gas"`               // Gas limit of the transaction
+	Blocks            hexutil.Uint64 `json:"blocks"`            // Inclusion window the estimate is for
+	PendingGas        hexutil.Uint64 `json:"pendingGas"`        // Gas of the pooled transactions of other senders
+	BlockFill         float64        `json:"blockFill"`         // Average gas used ratio of the recent blocks
+}
+
+// poolDifficulty is the virtual difficulty of a pooled transaction.
+type poolDifficulty struct {
+	difficulty float64
+	gas        uint64
+}
+
+// EstimateWorkDifficulty returns the work difficulty per gas the transaction
+// has to target to be included within the given number of blocks.
+//
+// The virtual difficulty the transaction needs is the higher of the one the
+// recent blocks included and the one outbidding the pooled transactions of the
+// other senders which don't fit in the window along with it. Recent blocks
+// filled over congestedBlockFill raise it further, up to double, as more
+// transactions arrive than fit. The work difficulty follows by dividing it by
+// the virtual capacity of the sender, i.e. the more it stakes the less work.
+func (s *PublicTransactionPoolAPI) EstimateWorkDifficulty(ctx context.Context, args SendTxArgs, blocks *hexutil.Uint64) (*WorkDifficultyEstimate, error) {
+	window := uint64(defaultInclusionBlocks)
+	if blocks != nil {
+		window = uint64(*blocks)
+	}
+	if window == 0 {
+		return nil, errors.New("inclusion window must be at least one block")
+	}
+	if window > maxInclusionBlocks {
+		return nil, fmt.Errorf("inclusion window exceeds %d blocks", maxInclusionBlocks)
+	}
+	if err := args.setDefaults(ctx, s.b); err != nil {
+		return nil, err
+	}
+	ebakusState, header, err := s.b.EbakusStateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if ebakusState == nil {
+		return nil, fmt.Errorf("Failed to find ebakusdb snapshot")
+	}
+	defer ebakusState.Release()
+
+	base, err := s.b.SuggestVirtualDifficulty(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if base < types.MinimumVirtualDifficulty {
+		base = types.MinimumVirtualDifficulty
+	}
+	// Rank the pooled transactions of the other senders by virtual difficulty
+	pending, err := s.b.GetPoolTransactions()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		signer     = types.MakeSigner(s.b.ChainConfig())
+		capacities = make(map[common.Address]float64)
+		pool       = make([]poolDifficulty, 0, len(pending))
+		pendingGas uint64
+	)
+	for _, tx := range pending {
+		sender, err := types.Sender(signer, tx)
+		if err != nil || sender == args.From || tx.Gas() == 0 {
+			continue
+		}
+		cv, ok := capacities[sender]
+		if !ok {
+			cv = types.VirtualCapacity(sender, ebakusState)
+			capacities[sender] = cv
+		}
+		pool = append(pool, poolDifficulty{difficulty: cv * tx.CalculateDifficulty() / float64(tx.Gas()), gas: tx.Gas()})
+		pendingGas += tx.Gas()
+	}
+	gas := uint64(*args.Gas)
+
+	required := base
+	if contention := outbidDifficulty(pool, window*header.GasLimit, gas); contention > required {
+		required = contention
+	}
+	fill := recentBlockFill(ctx, s.b, header)
+	if fill > congestedBlockFill {
+		required *= 1 + (fill-congestedBlockFill)/(1-congestedBlockFill)
+	}
+	cv := types.VirtualCapacity(args.From, ebakusState)
+
+	difficulty := required / cv
+	if min := s.b.MinGasPrice(); difficulty < min {
+		difficulty = min
+	}
+	return &WorkDifficultyEstimate{
+		Difficulty:        difficulty,
+		VirtualDifficulty: required,
+		VirtualCapacity:   cv,
+		Gas:               hexutil.Uint64(gas),
+		Blocks:            hexutil.Uint64(window),
+		PendingGas:        hexutil.Uint64(pendingGas),
+		BlockFill:         fill,
+	}, nil
+}
+
+// outbidDifficulty returns the virtual difficulty a transaction of the given gas
+// has to exceed to fit in the gas budget along with the pooled transactions of
+// higher virtual difficulties, or zero if they all fit.
+func outbidDifficulty(pool []poolDifficulty, budget uint64, gas uint64) float64 {
+	if gas >= budget {
+		return 0
+	}
+	budget -= gas
+
+	sort.Slice(pool, func(i, j int) bool { return pool[i].difficulty > pool[j].difficulty })
+	for _, tx := range pool {
+		if tx.gas > budget {
+			return tx.difficulty
+		}
+		budget -= tx.gas
+	}
+	return 0
+}
+
+// recentBlockFill returns the average ratio of the gas used to the gas limit
+// of the recent blocks up to head.
+func recentBlockFill(ctx context.Context, b Backend, head *types.Header) float64 {
+	var (
+		fill  float64
+		count int
+	)
+	for number := head.Number.Int64(); number > 0 && count < recentFillBlocks; number-- {
+		header := head
+		if number != head.Number.Int64() {
+			var err error
+			if header, err = b.HeaderByNumber(ctx, rpc.BlockNumber(number)); err != nil || header == nil {
+				break
+			}
+		}
+		if header.GasLimit > 0 {
+			fill += float64(header.GasUsed) / float64(header.GasLimit)
+		}
+		count++
+	}
+	if count == 0 {
+		return 0
+	}
+	return fill / float64(count)
+}
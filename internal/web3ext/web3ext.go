@@ -25,6 +25,7 @@ var Modules = map[string]string{
 	"db":         DBJs,
 	"dpos":       DposJs,
 	"debug":      DebugJs,
+	"ebakus":     EbakusJs,
 	"eth":        EthJs,
 	"miner":      MinerJs,
 	"net":        NetJs,
@@ -129,6 +130,29 @@ web3._extend({
 			params: 2,
 			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, null]
 		}),
+		new web3._extend.Method({
+			name: 'getWitnessPerformance',
+			call: 'dpos_getWitnessPerformance',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getVoters',
+			call: 'dpos_getVoters',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getDelegations',
+			call: 'dpos_getDelegations',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getLatestIrreversibleBlock',
+			call: 'dpos_getLatestIrreversibleBlock',
+			params: 0
+		}),
 	]
 });
 `
@@ -157,6 +181,26 @@ web3._extend({
 			call: 'admin_removeTrustedPeer',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'addPersistentPeer',
+			call: 'admin_addPersistentPeer',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'removePersistentPeer',
+			call: 'admin_removePersistentPeer',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'addPersistentTrustedPeer',
+			call: 'admin_addPersistentTrustedPeer',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'removePersistentTrustedPeer',
+			call: 'admin_removePersistentTrustedPeer',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'exportChain',
 			call: 'admin_exportChain',
@@ -203,10 +247,18 @@ web3._extend({
 			name: 'peers',
 			getter: 'admin_peers'
 		}),
+		new web3._extend.Property({
+			name: 'staticPeers',
+			getter: 'admin_staticPeers'
+		}),
 		new web3._extend.Property({
 			name: 'datadir',
 			getter: 'admin_datadir'
 		}),
+		new web3._extend.Property({
+			name: 'systemAlerts',
+			getter: 'admin_systemAlerts'
+		}),
 	]
 });
 `
@@ -241,6 +293,18 @@ web3._extend({
 			call: 'debug_seedHash',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'explainDBQuery',
+			call: 'debug_explainDBQuery',
+			params: 6,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null, null, null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'ebakusStateDiff',
+			call: 'debug_ebakusStateDiff',
+			params: 4,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter, null, web3._extend.utils.fromDecimal]
+		}),
 		new web3._extend.Method({
 			name: 'dumpBlock',
 			call: 'debug_dumpBlock',
@@ -256,6 +320,14 @@ web3._extend({
 			name: 'chaindbCompact',
 			call: 'debug_chaindbCompact',
 		}),
+		new web3._extend.Method({
+			name: 'ebakusSnapshots',
+			call: 'debug_ebakusSnapshots',
+		}),
+		new web3._extend.Method({
+			name: 'syncStats',
+			call: 'debug_syncStats',
+		}),
 		new web3._extend.Method({
 			name: 'verbosity',
 			call: 'debug_verbosity',
@@ -470,6 +542,12 @@ web3._extend({
 			params: 2,
 			inputFormatter: [web3._extend.formatters.inputTransactionFormatter,web3._extend.utils.fromFloat]
 		}),
+		new web3._extend.Method({
+			name: 'estimateWorkDifficulty',
+			call: 'eth_estimateWorkDifficulty',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputTransactionFormatter, null]
+		}),
 		new web3._extend.Method({
 			name: 'suggestDifficulty',
 			call: 'eth_suggestDifficulty',
@@ -495,6 +573,12 @@ web3._extend({
 			params: 1,
 			inputFormatter: [web3._extend.formatters.inputTransactionFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'prepareTransaction',
+			call: 'eth_prepareTransaction',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputTransactionFormatter]
+		}),
 		new web3._extend.Method({
 			name: 'getHeaderByNumber',
 			call: 'eth_getHeaderByNumber',
@@ -534,18 +618,61 @@ web3._extend({
 			params: 3,
 			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'getLogsPage',
+			call: 'eth_getLogsPage',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
 		new web3._extend.Method({
 			name: 'getStaked',
 			call: 'eth_getStaked',
 			params: 2,
 			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'getLockedTransfers',
+			call: 'eth_getLockedTransfers',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getSubscriptions',
+			call: 'eth_getSubscriptions',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getAllowance',
+			call: 'eth_getAllowance',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter],
+			outputFormatter: web3._extend.utils.toDecimal
+		}),
+		new web3._extend.Method({
+			name: 'getBridgeCommitment',
+			call: 'eth_getBridgeCommitment',
+			params: 1,
+			inputFormatter: [web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'getBridgeProof',
+			call: 'eth_getBridgeProof',
+			params: 2,
+			inputFormatter: [web3._extend.utils.fromDecimal, web3._extend.utils.fromDecimal]
+		}),
 		new web3._extend.Method({
 			name: 'getAbiForAddress',
 			call: 'eth_getAbiForAddress',
 			params: 1,
 			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'computeContractAddress',
+			call: 'eth_computeContractAddress',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null]
+		}),
 		new web3._extend.Method({
 			name: 'getVirtualDifficultyFactor',
 			call: 'eth_getVirtualDifficultyFactor',
@@ -601,6 +728,14 @@ web3._extend({
 			name: 'getHashrate',
 			call: 'miner_getHashrate'
 		}),
+		new web3._extend.Method({
+			name: 'lastBlockTimings',
+			call: 'miner_lastBlockTimings'
+		}),
+		new web3._extend.Method({
+			name: 'watchdog',
+			call: 'miner_watchdog'
+		}),
 	],
 	properties: []
 });
@@ -634,6 +769,12 @@ web3._extend({
 			params: 3,
 			inputFormatter: [null, web3._extend.formatters.inputAddressFormatter, null]
 		}),
+		new web3._extend.Method({
+			name: 'signTypedData',
+			call: 'personal_signTypedData',
+			params: 3,
+			inputFormatter: [null, web3._extend.formatters.inputAddressFormatter, null]
+		}),
 		new web3._extend.Method({
 			name: 'ecRecover',
 			call: 'personal_ecRecover',
@@ -828,6 +969,66 @@ web3._extend({
 });
 `
 
+const EbakusJs = `
+web3._extend({
+	property: 'ebakus',
+	methods: [
+		new web3._extend.Method({
+			name: 'decodeLog',
+			call: 'ebakus_decodeLog',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'decodeTx',
+			call: 'ebakus_decodeTx',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'previewStake',
+			call: 'ebakus_previewStake',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'previewUnstake',
+			call: 'ebakus_previewUnstake',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'simulateCapacity',
+			call: 'ebakus_simulateCapacity',
+			params: 4,
+			inputFormatter: [web3._extend.utils.fromDecimal, null, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'get',
+			call: 'ebakus_get',
+			params: 5,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null, null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'select',
+			call: 'ebakus_select',
+			params: 5,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null, null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'next',
+			call: 'ebakus_next',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'releaseIterator',
+			call: 'ebakus_releaseIterator',
+			params: 1,
+			inputFormatter: [null]
+		}),
+	]
+});
+`
+
 const DBJs = `
 web3._extend({
 	property: 'db',
@@ -850,6 +1051,12 @@ web3._extend({
 			params: 1,
 			inputFormatter: [null]
 		}),
+		new web3._extend.Method({
+			name: 'nextPage',
+			call: 'db_nextPage',
+			params: 2,
+			inputFormatter: [null, web3._extend.utils.fromDecimal]
+		}),
 		new web3._extend.Method({
 			name: 'releaseIterator',
 			call: 'db_releaseIterator',
@@ -19,14 +19,18 @@ package les
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math/big"
+	"time"
 
-	"github.com/ebakus/ebakusdb"
 	"github.com/ebakus/go-ebakus/accounts"
+	"github.com/ebakus/go-ebakus/accounts/abi"
 	"github.com/ebakus/go-ebakus/common"
 	"github.com/ebakus/go-ebakus/common/math"
+	"github.com/ebakus/go-ebakus/consensus/dpos"
 	"github.com/ebakus/go-ebakus/core"
 	"github.com/ebakus/go-ebakus/core/bloombits"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/rawdb"
 	"github.com/ebakus/go-ebakus/core/state"
 	"github.com/ebakus/go-ebakus/core/types"
@@ -54,15 +58,25 @@ func (b *LesApiBackend) CurrentBlock() *types.Block {
 	return types.NewBlockWithHeader(b.eth.BlockChain().CurrentHeader())
 }
 
-func (b *LesApiBackend) SetHead(number uint64) {
+func (b *LesApiBackend) SetHead(number uint64) error {
+	if current := b.eth.blockchain.CurrentHeader().Number.Uint64(); number > current {
+		return fmt.Errorf("block #%d is ahead of the head block #%d", number, current)
+	}
 	b.eth.handler.downloader.Cancel()
-	b.eth.blockchain.SetHead(number)
+	if err := b.eth.blockchain.SetHead(number); err != nil {
+		return err
+	}
+	b.eth.bloomIndexer.Rewind(number)
+	return nil
 }
 
 func (b *LesApiBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
 	if number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber {
 		return b.eth.blockchain.CurrentHeader(), nil
 	}
+	if number == rpc.SafeBlockNumber || number == rpc.FinalizedBlockNumber {
+		return dpos.LastIrreversibleHeader(b.eth.blockchain, b.eth.blockchain.CurrentHeader()), nil
+	}
 	return b.eth.blockchain.GetHeaderByNumberOdr(ctx, uint64(number))
 }
 
@@ -150,7 +164,10 @@ func (b *LesApiBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockN
 	return nil, nil, errors.New("invalid arguments; neither block nor hash specified")
 }
 
-func (b *LesApiBackend) EbakusStateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*ebakusdb.Snapshot, *types.Header, error) {
+// EbakusStateAndHeaderByNumber only returns the header. Headers carry no
+// commitment to the ebakusdb state, so there is no local state to run calls
+// against; table rows are retrieved on demand through EbakusRows instead.
+func (b *LesApiBackend) EbakusStateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (ebkdb.State, *types.Header, error) {
 	header, err := b.HeaderByNumber(ctx, blockNr)
 	if header == nil || err != nil {
 		return nil, nil, err
@@ -158,13 +175,23 @@ func (b *LesApiBackend) EbakusStateAndHeaderByNumber(ctx context.Context, blockN
 	return nil, header, nil
 }
 
-func (b *LesApiBackend) EbakusStateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*ebakusdb.Snapshot, *types.Header, error) {
+func (b *LesApiBackend) EbakusStateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (ebkdb.State, *types.Header, error) {
 	if blockNr, ok := blockNrOrHash.Number(); ok {
 		return b.EbakusStateAndHeaderByNumber(ctx, blockNr)
 	}
 	return nil, nil, errors.New("ebakus state for hash not supported")
 }
 
+// EbakusRows retrieves the rows of an ebakusdb table from the serving peers. As
+// explained above the rows can't be proven, so they are trusted as served.
+func (b *LesApiBackend) EbakusRows(ctx context.Context, header *types.Header, contract common.Address, table string, whereClause string, orderClause string, limit uint64) ([][]byte, error) {
+	return light.GetEbakusRows(ctx, b.eth.odr, header, contract, table, whereClause, orderClause, limit)
+}
+
+func (b *LesApiBackend) EbakusTableABI(ctx context.Context, header *types.Header, contract common.Address, table string) (*abi.ABI, error) {
+	return light.GetEbakusTableABI(ctx, b.eth.odr, header, contract, table)
+}
+
 func (b *LesApiBackend) GetBlockAuthor(header *types.Header) (common.Address, error) {
 	return b.eth.engine.Author(header)
 }
@@ -183,7 +210,7 @@ func (b *LesApiBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*typ
 	return nil, nil
 }
 
-func (b *LesApiBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, ebakusState *ebakusdb.Snapshot, header *types.Header) (*vm.EVM, func() error, error) {
+func (b *LesApiBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, ebakusState ebkdb.State, header *types.Header) (*vm.EVM, func() error, error) {
 	state.SetBalance(msg.From(), math.MaxBig256)
 	context := core.NewEVMContext(msg, header, b.eth.blockchain, nil)
 	return vm.NewEVM(context, state, ebakusState, b.eth.chainConfig, vm.Config{}), state.Error, nil
@@ -257,6 +284,10 @@ func (b *LesApiBackend) SuggestPrice(ctx context.Context) (*float64, error) {
 	return b.gpo.SuggestPrice(ctx)
 }
 
+func (b *LesApiBackend) SuggestVirtualDifficulty(ctx context.Context) (float64, error) {
+	return b.gpo.SuggestVirtualDifficulty(ctx)
+}
+
 func (b *LesApiBackend) ChainDb() ethdb.Database {
 	return b.eth.chainDb
 }
@@ -277,12 +308,28 @@ func (b *LesApiBackend) RPCGasCap() *big.Int {
 	return b.eth.config.RPCGasCap
 }
 
+func (b *LesApiBackend) RPCEVMTimeout() time.Duration {
+	return b.eth.config.RPCEVMTimeout
+}
+
+func (b *LesApiBackend) RPCDBRowsCap() uint64 {
+	return b.eth.config.RPCDBRowsCap
+}
+
+func (b *LesApiBackend) RPCLogsMaxBlocks() uint64 {
+	return b.eth.config.RPCLogsMaxBlocks
+}
+
 func (b *LesApiBackend) MinGasPrice() float64 {
 	return b.eth.config.Miner.GasPrice
 }
 
-func (b *LesApiBackend) EbakusdbMaxActiveIterators() uint64 {
-	return b.eth.config.EbakusdbMaxActiveIterators
+func (b *LesApiBackend) EbakusdbQueryCache() int {
+	return b.eth.config.EbakusdbQueryCache
+}
+
+func (b *LesApiBackend) EbakusdbMaxIteratorLag() uint64 {
+	return b.eth.config.EbakusdbMaxIteratorLag
 }
 
 func (b *LesApiBackend) BloomStatus() (uint64, uint64) {
@@ -56,6 +56,7 @@ type LightEbakus struct {
 	txPool     *light.TxPool
 	blockchain *light.LightChain
 	serverPool *serverPool
+	walletSync *walletSync
 
 	bloomRequests chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer  *core.ChainIndexer             // Bloom indexer operating during block imports
@@ -117,6 +118,7 @@ func New(ctx *node.ServiceContext, config *eth.Config) (*LightEbakus, error) {
 	}
 	leth.chainReader = leth.blockchain
 	leth.txPool = light.NewTxPool(leth.chainConfig, leth.blockchain, leth.relay)
+	leth.walletSync = newWalletSync(leth, config.WalletAddresses)
 
 	// Set up checkpoint oracle.
 	oracle := config.CheckpointOracle
@@ -130,7 +132,12 @@ func New(ctx *node.ServiceContext, config *eth.Config) (*LightEbakus, error) {
 	leth.handler = newClientHandler(config.UltraLightServers, config.UltraLightFraction, checkpoint, leth)
 	if leth.handler.ulc != nil {
 		log.Warn("Ultra light client is enabled", "trustedNodes", len(leth.handler.ulc.keys), "minTrustedFraction", leth.handler.ulc.fraction)
-		leth.blockchain.DisableCheckFreq()
+		if config.UltraLightSkipList > 0 {
+			leth.blockchain.SetSkipListVerification(config.UltraLightSkipList)
+		} else {
+			leth.blockchain.DisableCheckFreq()
+		}
+		leth.handler.ulc.batch = config.UltraLightBatch
 	}
 	// Rewind the chain in case of an incompatible config upgrade.
 	if compat, ok := genesisErr.(*params.ConfigCompatError); ok {
@@ -200,6 +207,16 @@ func (s *LightEbakus) APIs() []rpc.API {
 			Version:   "1.0",
 			Service:   NewPrivateLightAPI(&s.lesCommons),
 			Public:    false,
+		}, {
+			Namespace: "dpos",
+			Version:   "1.0",
+			Service:   NewLightDposAPI(s),
+			Public:    true,
+		}, {
+			Namespace: "wallet",
+			Version:   "1.0",
+			Service:   NewWalletAPI(s),
+			Public:    false,
 		},
 	}...)
 }
@@ -240,6 +257,7 @@ func (s *LightEbakus) Start(srvr *p2p.Server) error {
 	// clients are searching for the first advertised protocol in the list
 	protocolVersion := AdvertiseProtocolVersions[0]
 	s.serverPool.start(srvr, lesTopic(s.blockchain.Genesis().Hash(), protocolVersion))
+	s.walletSync.start()
 	return nil
 }
 
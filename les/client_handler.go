@@ -235,6 +235,21 @@ func (h *clientHandler) handleMsg(p *peer) error {
 			ReqID:   resp.ReqID,
 			Obj:     resp.Data,
 		}
+	case EbakusRowsMsg:
+		p.Log().Trace("Received ebakusdb rows response")
+		var resp struct {
+			ReqID, BV uint64
+			Data      [][][]byte
+		}
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.fcServer.ReceivedReply(resp.ReqID, resp.BV)
+		deliverMsg = &Msg{
+			MsgType: MsgEbakusRows,
+			ReqID:   resp.ReqID,
+			Obj:     resp.Data,
+		}
 	case ReceiptsMsg:
 		p.Log().Trace("Received receipts response")
 		var resp struct {
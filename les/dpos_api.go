@@ -0,0 +1,123 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ebakus/go-ebakus/accounts/abi"
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/consensus/dpos"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/core/vm"
+	"github.com/ebakus/go-ebakus/light"
+	"github.com/ebakus/go-ebakus/rpc"
+)
+
+var errNoDPOS = errors.New("chain is not a dpos one")
+
+// LightDposAPI serves the delegates queries of the dpos API to light clients,
+// retrieving the witnesses from the serving peers.
+type LightDposAPI struct {
+	leth *LightEbakus
+}
+
+// NewLightDposAPI creates a new dpos API for a light client.
+func NewLightDposAPI(leth *LightEbakus) *LightDposAPI {
+	return &LightDposAPI{leth: leth}
+}
+
+// witnesses retrieves the witnesses at the given block, sorted by stake, along
+// with the header of the block.
+func (api *LightDposAPI) witnesses(ctx context.Context, number rpc.BlockNumber, whereClause string) (vm.WitnessArray, *types.Header, error) {
+	header, err := api.leth.ApiBackend.HeaderByNumber(ctx, number)
+	if err != nil {
+		return nil, nil, err
+	}
+	if header == nil {
+		return nil, nil, errors.New("header not found")
+	}
+	systemABI, err := abi.JSON(strings.NewReader(vm.SystemContractTablesABI))
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := light.GetEbakusRows(ctx, api.leth.odr, header, types.PrecompliledSystemContract, "Witnesses", whereClause, "Stake DESC", MaxEbakusRows)
+	if err != nil {
+		return nil, nil, err
+	}
+	witnesses := make(vm.WitnessArray, len(rows))
+	for i, row := range rows {
+		if err := systemABI.Tables["Witnesses"].Inputs.Unpack(&witnesses[i], row); err != nil {
+			return nil, nil, err
+		}
+	}
+	return witnesses, header, nil
+}
+
+// GetDelegates retrieves the list of delegates at the specified block.
+func (api *LightDposAPI) GetDelegates(ctx context.Context, number rpc.BlockNumber) ([]interface{}, error) {
+	config := api.leth.chainConfig.DPOS
+	if config == nil {
+		return nil, errNoDPOS
+	}
+	witnesses, header, err := api.witnesses(ctx, number, "")
+	if err != nil {
+		return nil, err
+	}
+	maxWitnesses := config.DelegateCount + config.BonusDelegateCount
+
+	elected := make(vm.WitnessArray, 0, maxWitnesses)
+	for _, witness := range witnesses {
+		if uint64(len(elected)) == maxWitnesses {
+			break
+		}
+		if (witness.Flags & vm.ElectEnabledFlag) != 0 {
+			elected = append(elected, witness)
+		}
+	}
+	delegates := dpos.SelectDelegates(header, elected, config.DelegateCount, config.TurnBlockCount)
+
+	out := make([]interface{}, len(delegates))
+	for i, delegate := range delegates {
+		out[i] = map[string]interface{}{
+			"address": delegate.Id,
+			"stake":   delegate.Stake,
+		}
+	}
+	return out, nil
+}
+
+// GetDelegate get delegate.
+func (api *LightDposAPI) GetDelegate(ctx context.Context, address common.Address, number rpc.BlockNumber) (map[string]interface{}, error) {
+	witnesses, _, err := api.witnesses(ctx, number, "Id LIKE "+string(address.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	if len(witnesses) == 0 {
+		return nil, fmt.Errorf("Address is not a delegate")
+	}
+	witness := witnesses[0]
+
+	return map[string]interface{}{
+		"address": witness.Id,
+		"stake":   witness.Stake,
+		"elected": (witness.Flags & vm.ElectEnabledFlag) == 1,
+	}, nil
+}
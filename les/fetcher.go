@@ -60,6 +60,9 @@ type lightFetcher struct {
 	requestTrigger    chan struct{}
 	lastTrustedHeader *types.Header
 
+	batched    map[*peer]*batchedAnnounce // Announcements queued in ultra light mode
+	batchTimer *time.Timer                // Timer processing the queued announcements
+
 	closeCh chan struct{}
 	wg      sync.WaitGroup
 }
@@ -74,6 +77,13 @@ type fetcherPeerInfo struct {
 	firstUpdateStats    *updateStatsEntry
 }
 
+// batchedAnnounce is the merge of the announcements received from a peer during
+// an ultra light batching interval.
+type batchedAnnounce struct {
+	head     *announceData // Latest announced head
+	ancestor uint64        // Number of the deepest reorg common ancestor announced
+}
+
 // fetcherTreeNode is a node of a tree that holds information about blocks recently
 // announced and confirmed by a certain peer. Each new announce message from a peer
 // adds nodes to the tree, based on the previous announced head and the reorg depth.
@@ -122,6 +132,7 @@ func newLightFetcher(h *clientHandler) *lightFetcher {
 		syncDone:       make(chan *peer),
 		closeCh:        make(chan struct{}),
 		maxConfirmedTd: big.NewInt(0),
+		batched:        make(map[*peer]*batchedAnnounce),
 	}
 	h.backend.peers.notify(f)
 
@@ -131,6 +142,12 @@ func newLightFetcher(h *clientHandler) *lightFetcher {
 }
 
 func (f *lightFetcher) close() {
+	f.lock.Lock()
+	if f.batchTimer != nil {
+		f.batchTimer.Stop()
+	}
+	f.lock.Unlock()
+
 	close(f.closeCh)
 	f.wg.Wait()
 }
@@ -247,15 +264,77 @@ func (f *lightFetcher) unregisterPeer(p *peer) {
 	// check for potential timed out block delay statistics
 	f.checkUpdateStats(p, nil)
 	delete(f.peers, p)
+	delete(f.batched, p)
 }
 
 // announce processes a new announcement message received from a peer, adding new
-// nodes to the peer's block tree and removing old nodes if necessary
+// nodes to the peer's block tree and removing old nodes if necessary. In ultra
+// light mode announcements may be batched instead, see batchAnnounce.
 func (f *lightFetcher) announce(p *peer, head *announceData) {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 	p.Log().Debug("Received new announcement", "number", head.Number, "hash", head.Hash, "reorg", head.ReorgDepth)
 
+	if f.handler.ulc != nil && f.handler.ulc.batch > 0 {
+		if fp := f.peers[p]; fp != nil && fp.lastAnnounced != nil {
+			f.batchAnnounce(p, fp, head)
+			return
+		}
+	}
+	f.processAnnounce(p, head)
+}
+
+// batchAnnounce queues an announcement to be processed at the end of the ulc
+// batching interval, merging it with the ones already queued for the peer so
+// that only the latest head gets processed, with the reorg depth covering all
+// of them. The fetcher's lock is expected to be held.
+func (f *lightFetcher) batchAnnounce(p *peer, fp *fetcherPeerInfo, head *announceData) {
+	prev := fp.lastAnnounced.number
+	batched := f.batched[p]
+	if batched != nil {
+		if head.Number <= batched.head.Number {
+			p.Log().Debug("Received non-monotonic td", "current", head.Number, "previous", batched.head.Number)
+			go f.handler.removePeer(p.id)
+			return
+		}
+		prev = batched.head.Number
+	}
+	ancestor := uint64(0)
+	if head.ReorgDepth < prev {
+		ancestor = prev - head.ReorgDepth
+	}
+	if batched == nil {
+		batched = &batchedAnnounce{ancestor: ancestor}
+		f.batched[p] = batched
+	} else if ancestor < batched.ancestor {
+		batched.ancestor = ancestor
+	}
+	batched.head = head
+
+	if f.batchTimer == nil {
+		f.batchTimer = time.AfterFunc(f.handler.ulc.batch, f.flushAnnounces)
+	}
+}
+
+// flushAnnounces processes the announcements batched during the last interval.
+func (f *lightFetcher) flushAnnounces() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.batchTimer = nil
+	for p, batched := range f.batched {
+		head := *batched.head
+		if fp := f.peers[p]; fp != nil && fp.lastAnnounced != nil {
+			head.ReorgDepth = fp.lastAnnounced.number - batched.ancestor
+		}
+		f.processAnnounce(p, &head)
+	}
+	f.batched = make(map[*peer]*batchedAnnounce)
+}
+
+// processAnnounce adds the announced head to the peer's block tree. The
+// fetcher's lock is expected to be held.
+func (f *lightFetcher) processAnnounce(p *peer, head *announceData) {
 	fp := f.peers[p]
 	if fp == nil {
 		p.Log().Debug("Announcement from unknown peer")
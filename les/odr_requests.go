@@ -68,6 +68,8 @@ func LesRequest(req light.OdrRequest) LesOdrRequest {
 		return (*BloomRequest)(r)
 	case *light.TxStatusRequest:
 		return (*TxStatusRequest)(r)
+	case *light.EbakusRequest:
+		return (*EbakusRequest)(r)
 	default:
 		return nil
 	}
@@ -539,3 +541,66 @@ func (db *readTraceDB) Has(key []byte) (bool, error) {
 	_, err := db.Get(key)
 	return err == nil, nil
 }
+
+// EbakusReq is a request for the rows of an ebakusdb table at a given block
+type EbakusReq struct {
+	BHash       common.Hash
+	Contract    common.Address
+	Table       string
+	WhereClause string
+	OrderClause string
+	Limit       uint64
+}
+
+// EbakusRequest is the ODR request type for ebakusdb table rows
+type EbakusRequest light.EbakusRequest
+
+// GetCost returns the cost of the given ODR request according to the serving
+// peer's cost table (implementation of LesOdrRequest)
+func (r *EbakusRequest) GetCost(peer *peer) uint64 {
+	return peer.GetRequestCost(GetEbakusRowsMsg, 1)
+}
+
+// CanSend tells if a certain peer is suitable for serving the given request
+func (r *EbakusRequest) CanSend(peer *peer) bool {
+	if peer.version < lpv4 {
+		return false
+	}
+	return peer.HasBlock(r.BlockHash, r.BlockNumber, true)
+}
+
+// Request sends an ODR request to the LES network (implementation of LesOdrRequest)
+func (r *EbakusRequest) Request(reqID uint64, peer *peer) error {
+	peer.Log().Debug("Requesting ebakusdb rows", "contract", r.Contract, "table", r.Table)
+	req := EbakusReq{
+		BHash:       r.BlockHash,
+		Contract:    r.Contract,
+		Table:       r.Table,
+		WhereClause: r.WhereClause,
+		OrderClause: r.OrderClause,
+		Limit:       r.Limit,
+	}
+	return peer.RequestEbakusRows(reqID, r.GetCost(peer), []EbakusReq{req})
+}
+
+// Validate processes an ODR request reply message from the LES network
+// returns true and stores results in memory if the message was a valid reply
+// to the request (implementation of LesOdrRequest)
+func (r *EbakusRequest) Validate(db ethdb.Database, msg *Msg) error {
+	log.Debug("Validating ebakusdb rows", "contract", r.Contract, "table", r.Table)
+
+	// Ensure we have a correct message with a single set of rows
+	if msg.MsgType != MsgEbakusRows {
+		return errInvalidMessageType
+	}
+	reply := msg.Obj.([][][]byte)
+	if len(reply) != 1 {
+		return errInvalidEntryCount
+	}
+	// The rows can't be proven, only ensure the peer respected the limit
+	if r.Limit != 0 && uint64(len(reply[0])) > r.Limit {
+		return errInvalidEntryCount
+	}
+	r.Rows = reply[0]
+	return nil
+}
@@ -437,6 +437,13 @@ func (p *peer) ReplyHelperTrieProofs(reqID uint64, resp HelperTrieResps) *reply
 	return &reply{p.rw, HelperTrieProofsMsg, reqID, data}
 }
 
+// ReplyEbakusRows creates a reply with a batch of ebakusdb table rows, corresponding to the
+// selects requested.
+func (p *peer) ReplyEbakusRows(reqID uint64, rows [][][]byte) *reply {
+	data, _ := rlp.EncodeToBytes(rows)
+	return &reply{p.rw, EbakusRowsMsg, reqID, data}
+}
+
 // ReplyTxStatus creates a reply with a batch of transaction status records, corresponding to the ones requested.
 func (p *peer) ReplyTxStatus(reqID uint64, stats []light.TxStatus) *reply {
 	data, _ := rlp.EncodeToBytes(stats)
@@ -489,6 +496,12 @@ func (p *peer) RequestHelperTrieProofs(reqID, cost uint64, reqs []HelperTrieReq)
 	return sendRequest(p.rw, GetHelperTrieProofsMsg, reqID, cost, reqs)
 }
 
+// RequestEbakusRows fetches a batch of ebakusdb table rows from a remote node.
+func (p *peer) RequestEbakusRows(reqID, cost uint64, reqs []EbakusReq) error {
+	p.Log().Debug("Fetching batch of ebakusdb rows", "count", len(reqs))
+	return sendRequest(p.rw, GetEbakusRowsMsg, reqID, cost, reqs)
+}
+
 // RequestTxStatus fetches a batch of transaction status records from a remote node.
 func (p *peer) RequestTxStatus(reqID, cost uint64, txHashes []common.Hash) error {
 	p.Log().Debug("Requesting transaction status", "count", len(txHashes))
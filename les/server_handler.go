@@ -31,6 +31,7 @@ import (
 	"github.com/ebakus/go-ebakus/core/rawdb"
 	"github.com/ebakus/go-ebakus/core/state"
 	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/core/vm"
 	"github.com/ebakus/go-ebakus/ethdb"
 	"github.com/ebakus/go-ebakus/light"
 	"github.com/ebakus/go-ebakus/log"
@@ -53,6 +54,8 @@ const (
 	MaxHelperTrieProofsFetch = 64  // Amount of helper tries to be fetched per retrieval request
 	MaxTxSend                = 64  // Amount of transactions to be send per request
 	MaxTxStatus              = 256 // Amount of transactions to queried per request
+	MaxEbakusRowsFetch       = 16  // Amount of ebakusdb table selects to be served per request
+	MaxEbakusRows            = 256 // Amount of rows to be returned per ebakusdb table select
 )
 
 var (
@@ -818,6 +821,77 @@ func (h *serverHandler) handleMsg(p *peer, wg *sync.WaitGroup) error {
 			}()
 		}
 
+	case GetEbakusRowsMsg:
+		p.Log().Trace("Received ebakusdb rows request")
+		if metrics.EnabledExpensive {
+			miscInEbakusPacketsMeter.Mark(1)
+			miscInEbakusTrafficMeter.Mark(int64(msg.Size))
+			defer func(start time.Time) { miscServingTimeEbakusTimer.UpdateSince(start) }(time.Now())
+		}
+		var req struct {
+			ReqID uint64
+			Reqs  []EbakusReq
+		}
+		if err := msg.Decode(&req); err != nil {
+			clientErrorMeter.Mark(1)
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		var (
+			bytes int
+			data  [][][]byte
+		)
+		reqCnt := len(req.Reqs)
+		if accept(req.ReqID, uint64(reqCnt), MaxEbakusRowsFetch) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i, request := range req.Reqs {
+					if i != 0 && !task.waitOrStop() {
+						sendResponse(req.ReqID, 0, nil, task.servingTime)
+						return
+					}
+					// Look up the ebakus state belonging to the request
+					header := h.blockchain.GetHeaderByHash(request.BHash)
+					if header == nil {
+						p.Log().Warn("Failed to retrieve associate header for ebakusdb rows", "hash", request.BHash)
+						atomic.AddUint32(&p.invalidCount, 1)
+						continue
+					}
+					ebakusState, err := h.blockchain.ReadEbakusStateAt(header.Hash(), header.Number.Uint64())
+					if err != nil {
+						p.Log().Warn("Failed to retrieve ebakus state for rows", "block", header.Number, "hash", header.Hash(), "err", err)
+						atomic.AddUint32(&p.invalidCount, 1)
+						continue
+					}
+					limit := request.Limit
+					if limit == 0 || limit > MaxEbakusRows {
+						limit = MaxEbakusRows
+					}
+					rows, err := vm.EbakusDBRows(ebakusState, request.Contract, request.Table, request.WhereClause, request.OrderClause, limit)
+					ebakusState.Release()
+					if err != nil {
+						p.Log().Debug("Failed to select ebakusdb rows", "block", header.Number, "contract", request.Contract, "table", request.Table, "err", err)
+						atomic.AddUint32(&p.invalidCount, 1)
+						continue
+					}
+					// Accumulate the rows and abort if enough data was retrieved
+					data = append(data, rows)
+					for _, row := range rows {
+						bytes += len(row)
+					}
+					if bytes >= softResponseLimit {
+						break
+					}
+				}
+				reply := p.ReplyEbakusRows(req.ReqID, data)
+				sendResponse(req.ReqID, uint64(reqCnt), reply, task.done())
+				if metrics.EnabledExpensive {
+					miscOutEbakusPacketsMeter.Mark(1)
+					miscOutEbakusTrafficMeter.Mark(int64(reply.size()))
+				}
+			}()
+		}
+
 	default:
 		p.Log().Trace("Received invalid message", "code", msg.Code)
 		clientErrorMeter.Mark(1)
@@ -26,7 +26,6 @@ import (
 	"testing"
 	"time"
 
-	"github.com/ebakus/ebakusdb"
 	"github.com/ebakus/go-ebakus/accounts/abi/bind"
 	"github.com/ebakus/go-ebakus/accounts/abi/bind/backends"
 	"github.com/ebakus/go-ebakus/common"
@@ -34,6 +33,7 @@ import (
 	"github.com/ebakus/go-ebakus/consensus/ethash"
 	"github.com/ebakus/go-ebakus/contracts/checkpointoracle/contract"
 	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/rawdb"
 	"github.com/ebakus/go-ebakus/core/types"
 	"github.com/ebakus/go-ebakus/crypto"
@@ -166,7 +166,7 @@ func testIndexers(db ethdb.Database, odr light.OdrBackend, config *light.Indexer
 	return indexers[:]
 }
 
-func newTestClientHandler(backend *backends.SimulatedBackend, odr *LesOdr, indexers []*core.ChainIndexer, db ethdb.Database, ebakusDb *ebakusdb.DB, peers *peerSet, ulcServers []string, ulcFraction int) *clientHandler {
+func newTestClientHandler(backend *backends.SimulatedBackend, odr *LesOdr, indexers []*core.ChainIndexer, db ethdb.Database, ebakusDb *ebkdb.DB, peers *peerSet, ulcServers []string, ulcFraction int) *clientHandler {
 	var (
 		evmux  = new(event.TypeMux)
 		engine = ethash.NewFaker()
@@ -224,7 +224,7 @@ func newTestClientHandler(backend *backends.SimulatedBackend, odr *LesOdr, index
 	return client.handler
 }
 
-func newTestServerHandler(blocks int, indexers []*core.ChainIndexer, db ethdb.Database, ebakusDb *ebakusdb.DB, peers *peerSet, clock mclock.Clock) (*serverHandler, *backends.SimulatedBackend) {
+func newTestServerHandler(blocks int, indexers []*core.ChainIndexer, db ethdb.Database, ebakusDb *ebkdb.DB, peers *peerSet, clock mclock.Clock) (*serverHandler, *backends.SimulatedBackend) {
 	var (
 		gspec = core.Genesis{
 			Config:   params.AllEthashProtocolChanges,
@@ -439,7 +439,7 @@ type testServer struct {
 
 func newServerEnv(t *testing.T, blocks int, protocol int, callback indexerCallback, simClock bool, newPeer bool, testCost uint64) (*testServer, func()) {
 	db := rawdb.NewMemoryDatabase()
-	ebakusDb, _ := ebakusdb.OpenInMemory(nil)
+	ebakusDb, _ := ebkdb.OpenInMemory(nil)
 	indexers := testIndexers(db, nil, light.TestServerIndexerConfig)
 
 	var clock mclock.Clock = &mclock.System{}
@@ -484,8 +484,8 @@ func newServerEnv(t *testing.T, blocks int, protocol int, callback indexerCallba
 
 func newClientServerEnv(t *testing.T, blocks int, protocol int, callback indexerCallback, ulcServers []string, ulcFraction int, simClock bool, connect bool) (*testServer, *testClient, func()) {
 	sdb, cdb := rawdb.NewMemoryDatabase(), rawdb.NewMemoryDatabase()
-	sebakusDb, _ := ebakusdb.OpenInMemory(nil)
-	cebakusDb, _ := ebakusdb.OpenInMemory(nil)
+	sebakusDb, _ := ebkdb.OpenInMemory(nil)
+	cebakusDb, _ := ebkdb.OpenInMemory(nil)
 	speers, cPeers := newPeerSet(), newPeerSet()
 
 	var clock mclock.Clock = &mclock.System{}
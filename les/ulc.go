@@ -18,6 +18,7 @@ package les
 
 import (
 	"errors"
+	"time"
 
 	"github.com/ebakus/go-ebakus/log"
 	"github.com/ebakus/go-ebakus/p2p/enode"
@@ -26,6 +27,7 @@ import (
 type ulc struct {
 	keys     map[string]bool
 	fraction int
+	batch    time.Duration // Interval to coalesce announcements over, 0 to disable
 }
 
 // newULC creates and returns an ultra light client instance.
@@ -0,0 +1,266 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ebakus/go-ebakus/accounts/abi"
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/core/vm"
+	"github.com/ebakus/go-ebakus/light"
+	"github.com/ebakus/go-ebakus/log"
+)
+
+// walletSyncTimeout is the time allowed to retrieve the stake rows of all the
+// watched addresses for a new head.
+const walletSyncTimeout = 30 * time.Second
+
+var errNotWatched = errors.New("address is not watched")
+
+// WalletState is the stake state of a watched address at a given block.
+type WalletState struct {
+	BlockNumber uint64           `json:"blockNumber"`
+	BlockHash   common.Hash      `json:"blockHash"`
+	Staked      uint64           `json:"staked"`
+	Claimable   []vm.Claimable   `json:"claimable"`
+	Delegations []common.Address `json:"delegations"`
+}
+
+// walletSync follows the light chain head and retrieves, through the table
+// proofs of the ODR, only the Staked, Claimable and Delegations rows of the
+// watched addresses. It gives wallets their stake balances without the full
+// ebakus state.
+type walletSync struct {
+	leth *LightEbakus
+
+	lock    sync.RWMutex
+	watched map[common.Address]*WalletState // Nil state until the first sync of the address
+	headCh  chan core.ChainHeadEvent
+	wakeCh  chan struct{}
+}
+
+// newWalletSync creates a wallet sync watching the given addresses.
+func newWalletSync(leth *LightEbakus, addresses []common.Address) *walletSync {
+	w := &walletSync{
+		leth:    leth,
+		watched: make(map[common.Address]*WalletState),
+		headCh:  make(chan core.ChainHeadEvent, 10),
+		wakeCh:  make(chan struct{}, 1),
+	}
+	for _, address := range addresses {
+		w.watched[address] = nil
+	}
+	return w
+}
+
+// start subscribes to the light chain head and syncs the watched addresses
+// until the light client is closed.
+func (w *walletSync) start() {
+	sub := w.leth.blockchain.SubscribeChainHeadEvent(w.headCh)
+
+	w.leth.wg.Add(1)
+	go func() {
+		defer w.leth.wg.Done()
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case <-w.headCh:
+			case <-w.wakeCh:
+			case <-sub.Err():
+				return
+			case <-w.leth.closeCh:
+				return
+			}
+			// Only the latest head matters, drop the ones queued meanwhile
+			for drained := false; !drained; {
+				select {
+				case <-w.headCh:
+				default:
+					drained = true
+				}
+			}
+			w.sync(w.leth.blockchain.CurrentHeader())
+		}
+	}()
+}
+
+// watch adds an address to the watched ones and schedules its sync.
+func (w *walletSync) watch(address common.Address) {
+	w.lock.Lock()
+	if _, ok := w.watched[address]; !ok {
+		w.watched[address] = nil
+	}
+	w.lock.Unlock()
+
+	select {
+	case w.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// unwatch stops tracking an address and drops its state.
+func (w *walletSync) unwatch(address common.Address) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	delete(w.watched, address)
+}
+
+// addresses returns the watched addresses.
+func (w *walletSync) addresses() []common.Address {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	addresses := make([]common.Address, 0, len(w.watched))
+	for address := range w.watched {
+		addresses = append(addresses, address)
+	}
+	return addresses
+}
+
+// state returns the last synced stake state of a watched address, nil if it
+// wasn't synced yet.
+func (w *walletSync) state(address common.Address) (*WalletState, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	state, ok := w.watched[address]
+	if !ok {
+		return nil, errNotWatched
+	}
+	return state, nil
+}
+
+// sync retrieves the stake state of all the watched addresses at the given
+// header. Addresses failing to sync keep their previous state.
+func (w *walletSync) sync(header *types.Header) {
+	if header == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), walletSyncTimeout)
+	defer cancel()
+
+	for _, address := range w.addresses() {
+		state, err := w.retrieve(ctx, header, address)
+		if err != nil {
+			log.Debug("Failed to sync wallet stake state", "address", address, "number", header.Number, "err", err)
+			continue
+		}
+		w.lock.Lock()
+		if _, ok := w.watched[address]; ok {
+			w.watched[address] = state
+		}
+		w.lock.Unlock()
+	}
+}
+
+// retrieve fetches the Staked, Claimable and Delegations rows of an address at
+// the given header from the serving peers.
+func (w *walletSync) retrieve(ctx context.Context, header *types.Header, address common.Address) (*WalletState, error) {
+	var (
+		odr         = w.leth.odr
+		whereClause = "Id LIKE " + string(address.Bytes())
+	)
+	state := &WalletState{
+		BlockNumber: header.Number.Uint64(),
+		BlockHash:   header.Hash(),
+	}
+
+	stakedABI, err := abi.JSON(strings.NewReader(vm.StakedTableABI))
+	if err != nil {
+		return nil, err
+	}
+	rows, err := light.GetEbakusRows(ctx, odr, header, types.PrecompliledSystemContract, "Staked", whereClause, "", 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) > 0 {
+		var staked types.Staked
+		if err := stakedABI.Tables["Staked"].Inputs.Unpack(&staked, rows[0]); err != nil {
+			return nil, err
+		}
+		state.Staked = staked.Amount
+	}
+
+	systemABI, err := abi.JSON(strings.NewReader(vm.SystemContractTablesABI))
+	if err != nil {
+		return nil, err
+	}
+	if rows, err = light.GetEbakusRows(ctx, odr, header, types.PrecompliledSystemContract, "Claimable", whereClause, "", MaxEbakusRows); err != nil {
+		return nil, err
+	}
+	state.Claimable = make([]vm.Claimable, len(rows))
+	for i, row := range rows {
+		if err := systemABI.Tables["Claimable"].Inputs.Unpack(&state.Claimable[i], row); err != nil {
+			return nil, err
+		}
+	}
+
+	if rows, err = light.GetEbakusRows(ctx, odr, header, types.PrecompliledSystemContract, "Delegations", whereClause, "", MaxEbakusRows); err != nil {
+		return nil, err
+	}
+	state.Delegations = make([]common.Address, len(rows))
+	for i, row := range rows {
+		var delegation vm.Delegation
+		if err := systemABI.Tables["Delegations"].Inputs.Unpack(&delegation, row); err != nil {
+			return nil, err
+		}
+		state.Delegations[i] = common.BytesToAddress(delegation.Id[common.AddressLength:])
+	}
+	return state, nil
+}
+
+// WalletAPI exposes the stake state of the addresses watched by the wallet sync.
+type WalletAPI struct {
+	sync *walletSync
+}
+
+// NewWalletAPI creates a new wallet API for a light client.
+func NewWalletAPI(leth *LightEbakus) *WalletAPI {
+	return &WalletAPI{sync: leth.walletSync}
+}
+
+// Watch starts syncing the stake state of the given address.
+func (api *WalletAPI) Watch(address common.Address) bool {
+	api.sync.watch(address)
+	return true
+}
+
+// Unwatch stops syncing the stake state of the given address.
+func (api *WalletAPI) Unwatch(address common.Address) bool {
+	api.sync.unwatch(address)
+	return true
+}
+
+// Watched returns the addresses whose stake state is synced.
+func (api *WalletAPI) Watched() []common.Address {
+	return api.sync.addresses()
+}
+
+// GetState returns the last synced stake state of a watched address, null if
+// it wasn't synced yet.
+func (api *WalletAPI) GetState(address common.Address) (*WalletState, error) {
+	return api.sync.state(address)
+}
@@ -524,3 +524,10 @@ func (lc *LightChain) DisableCheckFreq() {
 func (lc *LightChain) EnableCheckFreq() {
 	atomic.StoreInt32(&lc.disableCheckFreq, 0)
 }
+
+// SetSkipListVerification makes header validation check the seals of every Nth
+// header plus all delegate set changes instead of random samples. This is used
+// for ultralight mode when disabling validation altogether is not desired.
+func (lc *LightChain) SetSkipListVerification(every uint64) {
+	lc.hc.SetSkipListVerification(every)
+}
@@ -199,3 +199,21 @@ type TxStatusRequest struct {
 
 // StoreResult stores the retrieved data in local database
 func (req *TxStatusRequest) StoreResult(db ethdb.Database) {}
+
+// EbakusRequest is the ODR request type for the rows of an ebakusdb table.
+// Headers carry no commitment to the ebakus state, so the rows can't be proven
+// and are trusted as served.
+type EbakusRequest struct {
+	OdrRequest
+	BlockHash   common.Hash
+	BlockNumber uint64
+	Contract    common.Address // Owner of the table namespace
+	Table       string
+	WhereClause string
+	OrderClause string
+	Limit       uint64
+	Rows        [][]byte // Rows ABI encoded with the schema of the table
+}
+
+// StoreResult does nothing, as unproven rows are not cached
+func (req *EbakusRequest) StoreResult(db ethdb.Database) {}
@@ -19,11 +19,15 @@ package light
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"strings"
 
+	"github.com/ebakus/go-ebakus/accounts/abi"
 	"github.com/ebakus/go-ebakus/common"
 	"github.com/ebakus/go-ebakus/core"
 	"github.com/ebakus/go-ebakus/core/rawdb"
 	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/core/vm"
 	"github.com/ebakus/go-ebakus/crypto"
 	"github.com/ebakus/go-ebakus/rlp"
 )
@@ -282,3 +286,59 @@ func GetTransaction(ctx context.Context, odr OdrBackend, txHash common.Hash) (*t
 		}
 	}
 }
+
+// GetEbakusRows retrieves up to limit rows of a contract table at the given
+// block, matching the where clause and sorted by the order clause. The rows are
+// ABI encoded with the schema of the table.
+func GetEbakusRows(ctx context.Context, odr OdrBackend, header *types.Header, contract common.Address, table string, whereClause string, orderClause string, limit uint64) ([][]byte, error) {
+	r := &EbakusRequest{
+		BlockHash:   header.Hash(),
+		BlockNumber: header.Number.Uint64(),
+		Contract:    contract,
+		Table:       table,
+		WhereClause: whereClause,
+		OrderClause: orderClause,
+		Limit:       limit,
+	}
+	if err := odr.Retrieve(ctx, r); err != nil {
+		return nil, err
+	}
+	return r.Rows, nil
+}
+
+// GetEbakusTableABI retrieves the ABI describing the schema of a contract table
+// at the given block.
+func GetEbakusTableABI(ctx context.Context, odr OdrBackend, header *types.Header, contract common.Address, table string) (*abi.ABI, error) {
+	systemABI, err := abi.JSON(strings.NewReader(vm.SystemContractTablesABI))
+	if err != nil {
+		return nil, err
+	}
+	if contract == types.PrecompliledSystemContract {
+		if table == "Staked" {
+			stakedABI, err := abi.JSON(strings.NewReader(vm.StakedTableABI))
+			if err != nil {
+				return nil, err
+			}
+			return &stakedABI, nil
+		}
+		return &systemABI, nil
+	}
+	id := vm.GetContractAbiId(contract, "table", table)
+
+	rows, err := GetEbakusRows(ctx, odr, header, types.PrecompliledSystemContract, "ContractAbi", "Id LIKE "+string(id), "", 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("abi of table %q not found", table)
+	}
+	values, err := systemABI.Tables["ContractAbi"].Inputs.UnpackValues(rows[0])
+	if err != nil {
+		return nil, err
+	}
+	tableABI, err := abi.JSON(strings.NewReader(values[1].(string)))
+	if err != nil {
+		return nil, err
+	}
+	return &tableABI, nil
+}
@@ -75,6 +75,29 @@ func NewTimer() Timer {
 	}
 }
 
+// NewRegisteredTimerForced constructs and registers a new StandardTimer no
+// matter the global switch is enabled or not.
+// Be sure to unregister the meter from the registry once it is of no use to
+// allow for garbage collection.
+func NewRegisteredTimerForced(name string, r Registry) Timer {
+	c := NewTimerForced()
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// NewTimerForced constructs a new StandardTimer using an exponentially-decaying
+// sample no matter the global switch is enabled or not.
+// Be sure to call Stop() once the timer is of no use to allow for garbage collection.
+func NewTimerForced() Timer {
+	return &StandardTimer{
+		histogram: &StandardHistogram{sample: newExpDecaySample(1028, 0.015)},
+		meter:     NewMeterForced(),
+	}
+}
+
 // NilTimer is a no-op Timer.
 type NilTimer struct {
 	h Histogram
@@ -99,3 +99,20 @@ func ExampleGetOrRegisterTimer() {
 	t.Update(47)
 	fmt.Println(t.Max()) // Output: 47
 }
+
+func TestTimerForced(t *testing.T) {
+	defer func(enabled bool) { Enabled = enabled }(Enabled)
+	Enabled = false
+
+	if _, ok := NewTimer().(NilTimer); !ok {
+		t.Fatalf("disabled timer collecting")
+	}
+	tm := NewTimerForced()
+	tm.Update(time.Second)
+	if count := tm.Count(); count != 1 {
+		t.Fatalf("count mismatch: have %d, want %d", count, 1)
+	}
+	if max := tm.Max(); max != int64(time.Second) {
+		t.Fatalf("max mismatch: have %d, want %d", max, int64(time.Second))
+	}
+}
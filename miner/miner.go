@@ -25,6 +25,7 @@ import (
 	"github.com/ebakus/go-ebakus/common/hexutil"
 	"github.com/ebakus/go-ebakus/consensus"
 	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/state"
 	"github.com/ebakus/go-ebakus/core/types"
 	"github.com/ebakus/go-ebakus/eth/downloader"
@@ -32,7 +33,6 @@ import (
 	"github.com/ebakus/go-ebakus/event"
 	"github.com/ebakus/go-ebakus/log"
 	"github.com/ebakus/go-ebakus/params"
-	"github.com/ebakus/ebakusdb"
 )
 
 // Backend wraps all methods required for mining.
@@ -40,7 +40,7 @@ type Backend interface {
 	BlockChain() *core.BlockChain
 	TxPool() *core.TxPool
 	ChainDb() ethdb.Database
-	EbakusDb() *ebakusdb.DB
+	EbakusDb() *ebkdb.DB
 }
 
 // Config is the configuration parameters of mining.
@@ -50,15 +50,25 @@ type Config struct {
 	ExtraData hexutil.Bytes  `toml:",omitempty"` // Block extra data set by the miner
 	GasFloor  uint64         // Target gas floor for mined blocks.
 	GasCeil   uint64         // Target gas ceiling for mined blocks.
+	GasTarget float64        // Target gas utilization ratio of mined blocks (0 = follow parent's usage only)
+	GasWindow uint64         // Number of blocks the gas utilization is averaged over
 	GasPrice  float64        // Minimum gas price for mining a transaction
-	Recommit  time.Duration  // The time interval for miner to re-create mining work.
+	Recommit  time.Duration  // Maximum time spent packing transactions into a block (0 = until the slot deadline)
 	Noverify  bool           // Disable remote mining solution verification(only useful in ethash).
+
+	SenderGasShare uint64 `toml:",omitempty"` // Maximum percentage of the block gas a sender fills while others have transactions pending (0 = no cap)
+
+	// Dead man's switch protecting the network from producers cut off from it
+	StallTimeout time.Duration `toml:",omitempty"` // Time without blocks from the network after which production is considered stalled (0 = disabled)
+	StallWebhook string        `toml:",omitempty"` // HTTP URL to post an alert to when production stalls
+	StallStop    bool          `toml:",omitempty"` // Stop producing blocks when production stalls
 }
 
 // Miner creates blocks and searches for proof-of-work values.
 type Miner struct {
 	mux      *event.TypeMux
 	worker   *worker
+	watchdog *watchdog
 	coinbase common.Address
 	eth      Backend
 	engine   consensus.Engine
@@ -77,8 +87,10 @@ func New(eth Backend, config *Config, chainConfig *params.ChainConfig, mux *even
 		worker:   newWorker(config, chainConfig, engine, eth, mux, isLocalBlock),
 		canStart: 1,
 	}
+	miner.watchdog = newWatchdog(miner, config)
 
 	go miner.update()
+	go miner.watchdog.loop()
 
 	return miner
 }
@@ -161,7 +173,7 @@ func (self *Miner) Pending() (*types.Block, *state.StateDB) {
 }
 
 // Pending returns the currently pending block and associated state.
-func (self *Miner) PendingEbakusState() (*types.Block, *ebakusdb.Snapshot) {
+func (self *Miner) PendingEbakusState() (*types.Block, ebkdb.State) {
 	return self.worker.pendingSnapshot()
 }
 
@@ -178,3 +190,15 @@ func (self *Miner) SetEtherbase(addr common.Address) {
 	self.coinbase = addr
 	self.worker.setEtherbase(addr)
 }
+
+// LastBlockTimings returns how long each stage of producing the last locally
+// mined block took, or nil if no block was mined yet.
+func (self *Miner) LastBlockTimings() *BlockTimings {
+	return self.worker.blockTimings()
+}
+
+// Watchdog returns how long ago the last block got imported from the network and
+// the local signer last produced one, and whether production is stalled.
+func (self *Miner) Watchdog() *WatchdogStatus {
+	return self.watchdog.status()
+}
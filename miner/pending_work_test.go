@@ -0,0 +1,64 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core/rawdb"
+	"github.com/ebakus/go-ebakus/core/types"
+)
+
+// Tests that the work persisted before a restart is only recovered when the
+// worker gets to produce the same block within the same slot.
+func TestRecoverPendingWork(t *testing.T) {
+	var (
+		parent = types.NewBlockWithHeader(&types.Header{Number: big.NewInt(9), Time: 99})
+		other  = types.NewBlockWithHeader(&types.Header{Number: big.NewInt(9), Time: 98})
+		hashes = []common.Hash{{0x01}, {0x02}, {0x03}}
+	)
+	db := rawdb.NewMemoryDatabase()
+	if have := recoverPendingWork(db, parent, &types.Header{Number: big.NewInt(10), Time: 100}); have != nil {
+		t.Fatalf("recovered work from empty database: %v", have)
+	}
+	rawdb.WritePendingWork(db, &rawdb.PendingWork{ParentHash: parent.Hash(), Number: 10, Time: 100, TxHashes: hashes})
+
+	tests := []struct {
+		parent *types.Block
+		header *types.Header
+		want   []common.Hash
+	}{
+		// Restart within the same slot
+		{parent, &types.Header{Number: big.NewInt(10), Time: 100}, hashes},
+		// Restart after the slot passed
+		{parent, &types.Header{Number: big.NewInt(10), Time: 101}, nil},
+		// Chain head changed meanwhile
+		{other, &types.Header{Number: big.NewInt(10), Time: 100}, nil},
+	}
+	for i, tt := range tests {
+		if have := recoverPendingWork(db, tt.parent, tt.header); !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("test %d: recovered work mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+	rawdb.DeletePendingWork(db)
+	if have := recoverPendingWork(db, parent, &types.Header{Number: big.NewInt(10), Time: 100}); have != nil {
+		t.Errorf("recovered deleted work: %v", have)
+	}
+}
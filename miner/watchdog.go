@@ -0,0 +1,184 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/log"
+	"github.com/ebakus/go-ebakus/metrics"
+)
+
+const (
+	watchdogInterval = time.Second     // Interval between stall checks
+	webhookTimeout   = 5 * time.Second // Timeout of the stall alert webhook requests
+)
+
+var (
+	headAgeGauge     = metrics.NewRegisteredGauge("miner/watchdog/headage", nil)
+	producedAgeGauge = metrics.NewRegisteredGauge("miner/watchdog/producedage", nil)
+	stallMeter       = metrics.NewRegisteredMeter("miner/watchdog/stalls", nil)
+)
+
+// WatchdogStatus reports the liveness of the chain as seen by the local producer.
+type WatchdogStatus struct {
+	HeadAge     float64 `json:"headAge"`     // Seconds since the last block imported from the network
+	ProducedAge float64 `json:"producedAge"` // Seconds since the local signer last produced a block
+	Stalled     bool    `json:"stalled"`     // Whether the stall timeout got exceeded
+}
+
+// stallAlert is the payload posted to the stall alert webhook.
+type stallAlert struct {
+	Coinbase    common.Address `json:"coinbase"`
+	Head        uint64         `json:"head"`
+	HeadAge     float64        `json:"headAge"`
+	ProducedAge float64        `json:"producedAge"`
+	Stopped     bool           `json:"stopped"`
+}
+
+// watchdog is a dead man's switch tracking the blocks imported from the network
+// and the ones produced locally. If the network goes silent for longer than the
+// stall timeout while mining, the local producer is most likely on its own and
+// it can stop claiming slots and alert the operator.
+type watchdog struct {
+	miner  *Miner
+	config *Config
+
+	lock         sync.RWMutex
+	lastImported time.Time // Time the last block from the network got imported
+	lastProduced time.Time // Time the last local block got imported
+	stalled      bool      // Whether a stall is in progress (reported once)
+}
+
+func newWatchdog(miner *Miner, config *Config) *watchdog {
+	now := time.Now()
+	return &watchdog{
+		miner:        miner,
+		config:       config,
+		lastImported: now,
+		lastProduced: now,
+	}
+}
+
+// loop tracks the chain head events and checks for stalls periodically.
+func (w *watchdog) loop() {
+	heads := make(chan core.ChainHeadEvent, 10)
+	sub := w.miner.eth.BlockChain().SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case ev := <-heads:
+			w.lock.Lock()
+			if w.miner.worker.isLocalBlock != nil && w.miner.worker.isLocalBlock(ev.Block) {
+				w.lastProduced = time.Now()
+			} else {
+				w.lastImported = time.Now()
+			}
+			w.lock.Unlock()
+
+		case <-ticker.C:
+			w.check()
+
+		case <-sub.Err():
+			return
+		case <-w.miner.exitCh:
+			return
+		}
+	}
+}
+
+// status returns the current liveness report.
+func (w *watchdog) status() *WatchdogStatus {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	return &WatchdogStatus{
+		HeadAge:     time.Since(w.lastImported).Seconds(),
+		ProducedAge: time.Since(w.lastProduced).Seconds(),
+		Stalled:     w.stalled,
+	}
+}
+
+// check updates the metrics and takes the configured actions the first time
+// the stall timeout is exceeded while mining.
+func (w *watchdog) check() {
+	w.lock.Lock()
+	headAge, producedAge := time.Since(w.lastImported), time.Since(w.lastProduced)
+
+	headAgeGauge.Update(int64(headAge.Seconds()))
+	producedAgeGauge.Update(int64(producedAge.Seconds()))
+
+	if w.config.StallTimeout == 0 || headAge < w.config.StallTimeout {
+		w.stalled = false
+		w.lock.Unlock()
+		return
+	}
+	if w.stalled || !w.miner.Mining() {
+		w.lock.Unlock()
+		return
+	}
+	w.stalled = true
+	w.lock.Unlock()
+
+	stallMeter.Mark(1)
+	log.Error("Block production stalled, no blocks from the network", "headAge", common.PrettyDuration(headAge), "producedAge", common.PrettyDuration(producedAge))
+
+	if w.config.StallStop {
+		log.Warn("Stopping block production due to stall")
+		w.miner.Stop()
+	}
+	if w.config.StallWebhook != "" {
+		go w.alert(&stallAlert{
+			Coinbase:    w.miner.coinbase,
+			Head:        w.miner.eth.BlockChain().CurrentBlock().NumberU64(),
+			HeadAge:     headAge.Seconds(),
+			ProducedAge: producedAge.Seconds(),
+			Stopped:     w.config.StallStop,
+		})
+	}
+}
+
+// alert posts the stall report to the configured webhook.
+func (w *watchdog) alert(alert *stallAlert) {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		log.Error("Failed to encode stall alert", "err", err)
+		return
+	}
+	client := &http.Client{Timeout: webhookTimeout}
+
+	resp, err := client.Post(w.config.StallWebhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Warn("Failed to deliver stall alert", "url", w.config.StallWebhook, "err", err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		log.Warn("Stall alert rejected", "url", w.config.StallWebhook, "status", resp.Status)
+	}
+}
@@ -22,27 +22,82 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/ebakus/ebakusdb"
 	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/common/mclock"
 	"github.com/ebakus/go-ebakus/consensus"
 	"github.com/ebakus/go-ebakus/consensus/dpos"
 	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
+	"github.com/ebakus/go-ebakus/core/rawdb"
 	"github.com/ebakus/go-ebakus/core/state"
 	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/ethdb"
 	"github.com/ebakus/go-ebakus/event"
 	"github.com/ebakus/go-ebakus/log"
 	"github.com/ebakus/go-ebakus/metrics"
 	"github.com/ebakus/go-ebakus/params"
 )
 
-var blockProduceTimer = metrics.GetOrRegisterTimer("worker/blocks/produce", nil)
+const (
+	// chainHeadChanSize is the size of channel listening to ChainHeadEvent.
+	chainHeadChanSize = 10
+
+	// minPackingTime is the least time given to packing transactions, even if
+	// the slot deadline already passed.
+	minPackingTime = 100 * time.Millisecond
+)
+
+const (
+	commitInterruptNone int32 = iota
+	commitInterruptNewHead
+	commitInterruptDeadline
+)
+
+var (
+	blockProduceTimer = metrics.GetOrRegisterTimer("worker/blocks/produce", nil)
+
+	interruptedNewHeadMeter  = metrics.NewRegisteredMeter("worker/interrupts/newhead", nil)
+	interruptedDeadlineMeter = metrics.NewRegisteredMeter("worker/interrupts/deadline", nil)
+
+	senderCappedMeter = metrics.NewRegisteredMeter("worker/senders/capped", nil)
+
+	prepareStageTimer  = metrics.NewRegisteredTimer("worker/stages/prepare", nil)
+	txsStageTimer      = metrics.NewRegisteredTimer("worker/stages/transactions", nil)
+	finalizeStageTimer = metrics.NewRegisteredTimer("worker/stages/finalize", nil)
+	sealStageTimer     = metrics.NewRegisteredTimer("worker/stages/seal", nil)
+	writeStageTimer    = metrics.NewRegisteredTimer("worker/stages/write", nil)
+	totalStageTimer    = metrics.NewRegisteredTimer("worker/stages/total", nil)
+)
+
+// BlockTimings holds how long each stage of producing a block took, measured
+// from the start of the block's slot.
+type BlockTimings struct {
+	Number       uint64        `json:"number"`
+	Hash         common.Hash   `json:"hash"`
+	Prepare      time.Duration `json:"prepare"`      // Delay from the slot start until the header was prepared
+	Transactions time.Duration `json:"transactions"` // Executing and packing the pending transactions
+	Finalize     time.Duration `json:"finalize"`     // Finalizing the state and assembling the block
+	Seal         time.Duration `json:"seal"`         // Signing the block
+	Write        time.Duration `json:"write"`        // Writing the block and its state to the chain
+	Total        time.Duration `json:"total"`        // From the slot start until the block was written
+}
+
+// update feeds the stage durations into the metrics system.
+func (t *BlockTimings) update() {
+	prepareStageTimer.Update(t.Prepare)
+	txsStageTimer.Update(t.Transactions)
+	finalizeStageTimer.Update(t.Finalize)
+	sealStageTimer.Update(t.Seal)
+	writeStageTimer.Update(t.Write)
+	totalStageTimer.Update(t.Total)
+}
 
 // environment is the worker's current environment and holds all of the current state information.
 type environment struct {
 	signer types.Signer
 
 	state       *state.StateDB // apply state changes here
-	ebakusState *ebakusdb.Snapshot
+	ebakusState ebkdb.State
 	tcount      int           // tx count in cycle
 	gasPool     *core.GasPool // available gas used to pack transactions
 
@@ -53,6 +108,7 @@ type environment struct {
 	receipts []*types.Receipt
 
 	createdAt time.Time
+	timings   BlockTimings // Time spent in the production stages of the block
 }
 
 // worker is the main object which takes care of submitting new work to consensus engine
@@ -63,10 +119,13 @@ type worker struct {
 	engine      consensus.Engine
 	eth         Backend
 	chain       *core.BlockChain
-	ebakusDb    *ebakusdb.DB
+	ebakusDb    *ebkdb.DB
+	clock       mclock.Clock // Clock used for retry backoffs and the transaction packing deadline
 
 	// Subscriptions
-	mux *event.TypeMux
+	mux          *event.TypeMux
+	chainHeadCh  chan core.ChainHeadEvent
+	chainHeadSub event.Subscription
 
 	// Channels
 	stopCh chan struct{}
@@ -77,12 +136,18 @@ type worker struct {
 	mu       sync.RWMutex // The lock used to protect the coinbase and extra fields
 	coinbase common.Address
 
+	interruptMu     sync.Mutex
+	interrupt       *int32 // Interrupt signal of the transactions being packed, nil if none
+	interruptNumber uint64 // Number of the block the transactions are packed into
+
 	// atomic status counters
 	running int32 // The indicator whether the consensus engine is running or not.
 
 	// wait group is used for graceful shutdowns
 	wg sync.WaitGroup
 
+	lastTimings atomic.Value // Stage timings of the last block produced (*BlockTimings)
+
 	// External functions
 	isLocalBlock func(block *types.Block) bool // Function used to determine whether the specified block is mined by local miner.
 }
@@ -97,12 +162,68 @@ func newWorker(config *Config, chainConfig *params.ChainConfig, engine consensus
 		stopCh:       make(chan struct{}),
 		chain:        eth.BlockChain(),
 		ebakusDb:     eth.EbakusDb(),
+		clock:        mclock.System{},
 		isLocalBlock: isLocalBlock,
+		chainHeadCh:  make(chan core.ChainHeadEvent, chainHeadChanSize),
 	}
+	worker.chainHeadSub = eth.BlockChain().SubscribeChainHeadEvent(worker.chainHeadCh)
+
+	go worker.newHeadLoop()
 
 	return worker
 }
 
+// newHeadLoop interrupts the packing of transactions once a block for the same
+// height arrives from the network, as the block being produced lost its slot.
+func (w *worker) newHeadLoop() {
+	for {
+		select {
+		case ev := <-w.chainHeadCh:
+			w.interruptMu.Lock()
+			if w.interrupt != nil && ev.Block.NumberU64() >= w.interruptNumber {
+				atomic.CompareAndSwapInt32(w.interrupt, commitInterruptNone, commitInterruptNewHead)
+			}
+			w.interruptMu.Unlock()
+
+		case <-w.chainHeadSub.Err():
+			return
+		}
+	}
+}
+
+// setInterrupt sets the interrupt signal of the transactions being packed into
+// the block of the given number, nil once done.
+func (w *worker) setInterrupt(interrupt *int32, number uint64) {
+	w.interruptMu.Lock()
+	defer w.interruptMu.Unlock()
+
+	w.interrupt, w.interruptNumber = interrupt, number
+}
+
+// packingTime returns how long transactions may be packed into the block of
+// the given header: until the middle of its slot, leaving the rest of it to
+// seal and propagate the block, and no longer than the recommit interval.
+func (w *worker) packingTime(header *types.Header) time.Duration {
+	budget := w.config.Recommit
+	if config := w.chainConfig.DPOS; config != nil && config.Period > 0 {
+		deadline := time.Unix(int64(header.Time), 0).Add(time.Duration(config.Period) * time.Second / 2)
+		if left := time.Until(deadline); budget <= 0 || left < budget {
+			budget = left
+		}
+	}
+	if budget < minPackingTime {
+		budget = minPackingTime
+	}
+	return budget
+}
+
+// blockTimings returns the stage timings of the last block produced, or nil if
+// none was produced yet.
+func (w *worker) blockTimings() *BlockTimings {
+	timings, _ := w.lastTimings.Load().(*BlockTimings)
+	return timings
+}
+
 // setEtherbase sets the etherbase used to initialize the block coinbase field.
 func (w *worker) setEtherbase(addr common.Address) {
 	w.mu.Lock()
@@ -126,7 +247,7 @@ func (w *worker) pending() (*types.Block, *state.StateDB) {
 	return w.current.Block, w.current.state.Copy()
 }
 
-func (w *worker) pendingSnapshot() (*types.Block, *ebakusdb.Snapshot) {
+func (w *worker) pendingSnapshot() (*types.Block, ebkdb.State) {
 	w.currentMu.Lock()
 	defer w.currentMu.Unlock()
 
@@ -196,6 +317,7 @@ func (w *worker) isRunning() bool {
 // close terminates all background threads maintained by the worker.
 // Note the worker does not support being closed multiple times.
 func (w *worker) close() {
+	w.chainHeadSub.Unsubscribe()
 	close(w.stopCh)
 }
 
@@ -235,11 +357,19 @@ func (w *worker) processWork(env *environment, block *types.Block) {
 		log.BlockHash = block.Hash()
 	}
 
+	start := w.clock.Now()
 	stat, err := w.chain.WriteBlockWithState(block, env.receipts, env.state, env.ebakusState)
 	if err != nil {
 		log.Error("Failed writing block to chain", "err", err)
+		env.ebakusState.Release()
 		return
 	}
+	env.timings.Write = time.Duration(w.clock.Now() - start)
+	env.timings.Number, env.timings.Hash = block.NumberU64(), block.Hash()
+
+	if len(env.txs) > 0 {
+		rawdb.DeletePendingWork(w.eth.ChainDb())
+	}
 
 	env.ebakusState.Release()
 
@@ -270,7 +400,7 @@ func (w *worker) makeCurrent(parent *types.Block, header *types.Header) error {
 		return err
 	}
 
-	ebakusState, err := w.chain.EbakusStateAt(parent.Hash(), parent.NumberU64()) // gets released in processWork()
+	ebakusState, err := w.chain.EbakusStateAt(parent.Hash(), parent.NumberU64()) // released in processWork(), or once the work is dropped
 	if err != nil {
 		return fmt.Errorf("Worker makeCurrent() failed to get ebakus state at block number %d: %s", parent.NumberU64(), err)
 	}
@@ -305,17 +435,19 @@ func (w *worker) commitNewWork() {
 	if err != nil {
 		if err != dpos.ErrProductionAborted {
 			log.Error("Failed to prepare header for mining", "err", err)
-			time.Sleep(2 * time.Second)
+			w.clock.Sleep(2 * time.Second)
 		}
 		return
 	}
 
+	prepared := w.clock.Now()
+
 	// Are we still mining?
 	if !w.isRunning() {
 		return
 	}
 
-	header.GasLimit = core.CalcGasLimit(parent.Header(), w.config.GasFloor, w.config.GasCeil)
+	header.GasLimit = core.CalcGasLimitTarget(w.chain, parent.Header(), w.config.GasFloor, w.config.GasCeil, w.config.GasTarget, w.config.GasWindow)
 
 	// Could potentially happen if starting to mine in an odd state.
 	err = w.makeCurrent(parent, header)
@@ -325,39 +457,92 @@ func (w *worker) commitNewWork() {
 	}
 
 	// Fill the block with all available pending transactions.
+	env := w.current
+
 	pending, err := w.eth.TxPool().Pending()
 	if err != nil {
 		log.Error("Failed to fetch pending transactions", "err", err)
+		env.ebakusState.Release()
 		return
 	}
+	if delay := time.Since(time.Unix(int64(header.Time), 0)); delay > 0 {
+		env.timings.Prepare = delay
+	}
 
-	env := w.current
+	start := w.clock.Now()
+
+	// Replay the work done on this slot before a restart, if any, so the block
+	// gets rebuilt with the same transactions ordering.
+	if hashes := recoverPendingWork(w.eth.ChainDb(), parent, header); len(hashes) > 0 {
+		w.commitRecoveredTransactions(hashes, w.coinbase)
+	}
 	txs := types.NewTransactionsByVirtualDifficultyAndNonce(w.current.signer, pending, env.ebakusState)
-	// tcount := w.current.tcount
-	w.commitTransactions(txs, w.coinbase)
+
+	// Pack transactions until the slot deadline, unless a block for the same
+	// height arrives meanwhile
+	interrupt := new(int32)
+	w.setInterrupt(interrupt, header.Number.Uint64())
+	timer := w.clock.AfterFunc(w.packingTime(header), func() {
+		atomic.CompareAndSwapInt32(interrupt, commitInterruptNone, commitInterruptDeadline)
+	})
+	aborted := w.commitTransactions(txs, w.coinbase, interrupt)
+	timer.Stop()
+	w.setInterrupt(nil, 0)
+
+	if aborted {
+		log.Debug("Block production aborted by new head", "number", header.Number)
+		env.ebakusState.Release()
+		return
+	}
+	env.timings.Transactions = time.Duration(w.clock.Now() - start)
+
+	if len(env.txs) > 0 {
+		work := &rawdb.PendingWork{
+			ParentHash: parent.Hash(),
+			Number:     header.Number.Uint64(),
+			Time:       header.Time,
+		}
+		for _, tx := range env.txs {
+			work.TxHashes = append(work.TxHashes, tx.Hash())
+		}
+		rawdb.WritePendingWork(w.eth.ChainDb(), work)
+	}
 
 	// Create the new block to seal with the consensus engine
+	start = w.clock.Now()
 	if env.Block, err = w.engine.FinalizeAndAssemble(w.chain, header, env.state, env.ebakusState, w.coinbase, env.txs, env.receipts); err != nil {
 		if err != dpos.ErrWaitForTransactions {
 			log.Error("Failed to finalize block for sealing", "err", err)
 		}
+		env.ebakusState.Release()
 		return
 	}
+	env.timings.Finalize = time.Duration(w.clock.Now() - start)
 	// We only care about logging if we're actually mining.
 	if w.isRunning() {
 		log.Info("Commit new mining work", "number", env.Block.Number(), "txs", env.tcount, "hash", env.Block.Hash())
 	}
 
+	start = w.clock.Now()
 	results := make(chan *types.Block, 1)
 	if err := w.engine.Seal(w.chain, env.Block, results, nil); err != nil {
 		log.Error("Block sealing failed", "err", err)
+		env.ebakusState.Release()
 		return
 	}
 
 	select {
 	case res := <-results:
+		env.timings.Seal = time.Duration(w.clock.Now() - start)
 		w.processWork(env, res)
 
+		// Only blocks making it into the chain get their timings recorded
+		if timings := &env.timings; timings.Hash != (common.Hash{}) {
+			timings.Total = timings.Prepare + time.Duration(w.clock.Now()-prepared)
+			timings.update()
+			w.lastTimings.Store(timings)
+		}
+
 		log.Info("Committed work", "number", env.Block.Number())
 	}
 }
@@ -380,7 +565,46 @@ func (w *worker) commitTransaction(tx *types.Transaction, coinbase common.Addres
 	return receipt.Logs, nil
 }
 
-func (w *worker) commitTransactions(txs *types.TransactionsByVirtualDifficultyAndNonce, coinbase common.Address) bool {
+// recoverPendingWork returns the transactions persisted for the block being
+// produced, if they were meant for the same parent and slot as header.
+func recoverPendingWork(db ethdb.KeyValueReader, parent *types.Block, header *types.Header) []common.Hash {
+	work := rawdb.ReadPendingWork(db)
+	if work == nil || work.ParentHash != parent.Hash() || work.Number != header.Number.Uint64() || work.Time != header.Time {
+		return nil
+	}
+	return work.TxHashes
+}
+
+// commitRecoveredTransactions applies, in order, the transactions of a block
+// whose production got interrupted. Transactions no longer in the pool, or no
+// longer applicable, are skipped.
+func (w *worker) commitRecoveredTransactions(hashes []common.Hash, coinbase common.Address) {
+	if w.current.gasPool == nil {
+		w.current.gasPool = new(core.GasPool).AddGas(w.current.header.GasLimit)
+	}
+	pool := w.eth.TxPool()
+
+	for _, hash := range hashes {
+		tx := pool.Get(hash)
+		if tx == nil {
+			log.Debug("Recovered transaction no longer pending", "hash", hash)
+			continue
+		}
+		w.current.state.Prepare(tx.Hash(), common.Hash{}, w.current.tcount)
+
+		if _, err := w.commitTransaction(tx, coinbase); err != nil {
+			log.Debug("Recovered transaction failed", "hash", hash, "err", err)
+			continue
+		}
+		w.current.tcount++
+	}
+	log.Info("Recovered interrupted block work", "number", w.current.header.Number, "txs", w.current.tcount, "persisted", len(hashes))
+}
+
+// commitTransactions packs transactions into the current block until the block
+// is full or the interrupt signal is raised. It returns whether the block must
+// be discarded, as a block for its height arrived from the network.
+func (w *worker) commitTransactions(txs *types.TransactionsByVirtualDifficultyAndNonce, coinbase common.Address, interrupt *int32) bool {
 	// Short circuit if current is nil
 	if w.current == nil {
 		return true
@@ -392,12 +616,37 @@ func (w *worker) commitTransactions(txs *types.TransactionsByVirtualDifficultyAn
 
 	var coalescedLogs []*types.Log
 
-	startTime := time.Now()
+	// Cap the gas a single sender fills while others have transactions pending,
+	// deferring its remaining ones until the others are served.
+	var (
+		capGas    uint64
+		senderGas map[common.Address]uint64
+		deferred  map[common.Address]types.Transactions
+	)
+	if share := w.config.SenderGasShare; share > 0 && share < 100 {
+		capGas = w.current.header.GasLimit * share / 100
+		senderGas = make(map[common.Address]uint64)
+		deferred = make(map[common.Address]types.Transactions)
+	}
+	startTime := w.clock.Now()
 
+loop:
 	for {
-		if elapsed := time.Since(startTime); elapsed > time.Millisecond*500 {
-			log.Trace("Not enough time for further transactions", elapsed)
-			break
+		// In the following two cases, we will interrupt the execution of the transaction.
+		// (1) new head block event arrival, the interrupt signal is 1
+		// (2) slot deadline or recommit interval reached, the interrupt signal is 2.
+		// For the first case, the block is discarded. For the second case, the block
+		// is sealed with the transactions packed so far.
+		if interrupt != nil {
+			switch atomic.LoadInt32(interrupt) {
+			case commitInterruptNewHead:
+				interruptedNewHeadMeter.Mark(1)
+				return true
+			case commitInterruptDeadline:
+				interruptedDeadlineMeter.Mark(1)
+				log.Trace("Not enough time for further transactions", "elapsed", common.PrettyDuration(w.clock.Now()-startTime))
+				break loop
+			}
 		}
 
 		// If we don't have enough gas for any further transactions then we're done
@@ -408,13 +657,30 @@ func (w *worker) commitTransactions(txs *types.TransactionsByVirtualDifficultyAn
 		// Retrieve the next transaction and abort if all done
 		tx := txs.Peek()
 		if tx == nil {
-			break
+			if len(deferred) == 0 {
+				break
+			}
+			// Everyone else is served, fill the rest of the block with the
+			// deferred senders in pure virtual difficulty order
+			log.Trace("Packing transactions of capped senders", "senders", len(deferred))
+			senderCappedMeter.Mark(int64(len(deferred)))
+
+			txs = types.NewTransactionsByVirtualDifficultyAndNonce(w.current.signer, deferred, w.current.ebakusState)
+			capGas, deferred = 0, nil
+			continue
 		}
 		// Error may be ignored here. The error has already been checked
 		// during transaction acceptance is the transaction pool.
 		//
 		// We use the eip155 signer regardless of the current hf.
 		from, _ := types.Sender(w.current.signer, tx)
+
+		// Defer the sender if the transaction could take it over its gas share
+		if capGas > 0 && senderGas[from]+tx.Gas() > capGas {
+			log.Trace("Deferring sender over its block gas share", "sender", from, "used", senderGas[from], "cap", capGas)
+			deferred[from] = txs.Take()
+			continue
+		}
 		// Check whether the tx is replay protected. If we're not in the EIP155 hf
 		// phase, start ignoring the sender until we do.
 		if tx.Protected() && !w.chainConfig.IsEIP155(w.current.header.Number) {
@@ -423,10 +689,18 @@ func (w *worker) commitTransactions(txs *types.TransactionsByVirtualDifficultyAn
 			txs.Pop()
 			continue
 		}
+		// Skip the unprotected transactions once they are invalid
+		if !tx.Protected() && w.chainConfig.IsStrictEIP155(w.current.header.Number) {
+			log.Trace("Ignoring unprotected transaction", "hash", tx.Hash())
+
+			txs.Pop()
+			continue
+		}
 
 		// Start executing the transaction
 		w.current.state.Prepare(tx.Hash(), common.Hash{}, w.current.tcount)
 
+		gas := w.current.gasPool.Gas()
 		logs, err := w.commitTransaction(tx, coinbase)
 		switch err {
 		case core.ErrGasLimitReached:
@@ -448,6 +722,9 @@ func (w *worker) commitTransactions(txs *types.TransactionsByVirtualDifficultyAn
 			// Everything ok, collect the logs and shift in the next transaction from the same account
 			coalescedLogs = append(coalescedLogs, logs...)
 			w.current.tcount++
+			if capGas > 0 {
+				senderGas[from] += gas - w.current.gasPool.Gas()
+			}
 			txs.Shift()
 
 		default:
@@ -470,7 +747,7 @@ func (w *worker) commitTransactions(txs *types.TransactionsByVirtualDifficultyAn
 		go w.mux.Post(core.PendingLogsEvent{Logs: cpy})
 	}
 
-	elapsed := time.Since(startTime)
+	elapsed := time.Duration(w.clock.Now() - startTime)
 
 	log.Trace("Commit transactions completed", "elapsed", common.PrettyDuration(elapsed))
 	blockProduceTimer.Update(elapsed)
@@ -139,6 +139,7 @@ func (opts *TransactOpts) SetContext(context *Context) { opts.opts.Context = con
 // higher level contract bindings to operate.
 type BoundContract struct {
 	contract *bind.BoundContract
+	abi      abi.ABI
 	address  common.Address
 	deployer *types.Transaction
 }
@@ -151,12 +152,17 @@ func DeployContract(opts *TransactOpts, abiJSON string, bytecode []byte, client
 	if err != nil {
 		return nil, err
 	}
-	addr, tx, bound, err := bind.DeployContract(&opts.opts, parsed, common.CopyBytes(bytecode), client.client, args.objects...)
+	params, err := packTuples(parsed.Constructor.Inputs, args.objects)
+	if err != nil {
+		return nil, err
+	}
+	addr, tx, bound, err := bind.DeployContract(&opts.opts, parsed, common.CopyBytes(bytecode), client.client, params...)
 	if err != nil {
 		return nil, err
 	}
 	return &BoundContract{
 		contract: bound,
+		abi:      parsed,
 		address:  addr,
 		deployer: tx,
 	}, nil
@@ -171,6 +177,7 @@ func BindContract(address *Address, abiJSON string, client *EbakusClient) (contr
 	}
 	return &BoundContract{
 		contract: bind.NewBoundContract(address.address, parsed, client.client, client.client, client.client),
+		abi:      parsed,
 		address:  address.address,
 	}, nil
 }
@@ -186,26 +193,31 @@ func (c *BoundContract) GetDeployer() *Transaction {
 // Call invokes the (constant) contract method with params as input values and
 // sets the output to result.
 func (c *BoundContract) Call(opts *CallOpts, out *Interfaces, method string, args *Interfaces) error {
-	if len(out.objects) == 1 {
-		result := out.objects[0]
-		if err := c.contract.Call(&opts.opts, result, method, args.objects...); err != nil {
+	params, err := packTuples(c.abi.Methods[method].Inputs, args.objects)
+	if err != nil {
+		return err
+	}
+	results := unpackTargets(c.abi.Methods[method].Outputs, out.objects)
+	if len(results) == 1 {
+		if err := c.contract.Call(&opts.opts, results[0], method, params...); err != nil {
 			return err
 		}
-		out.objects[0] = result
 	} else {
-		results := make([]interface{}, len(out.objects))
-		copy(results, out.objects)
-		if err := c.contract.Call(&opts.opts, &results, method, args.objects...); err != nil {
+		if err := c.contract.Call(&opts.opts, &results, method, params...); err != nil {
 			return err
 		}
-		copy(out.objects, results)
 	}
+	unpackTuples(results, out.objects)
 	return nil
 }
 
 // Transact invokes the (paid) contract method with params as input values.
 func (c *BoundContract) Transact(opts *TransactOpts, method string, args *Interfaces) (tx *Transaction, _ error) {
-	rawTx, err := c.contract.Transact(&opts.opts, method, args.objects...)
+	params, err := packTuples(c.abi.Methods[method].Inputs, args.objects)
+	if err != nil {
+		return nil, err
+	}
+	rawTx, err := c.contract.Transact(&opts.opts, method, params...)
 	if err != nil {
 		return nil, err
 	}
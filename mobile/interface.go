@@ -20,8 +20,11 @@ package ebakus
 
 import (
 	"errors"
+	"fmt"
 	"math/big"
+	"reflect"
 
+	"github.com/ebakus/go-ebakus/accounts/abi"
 	"github.com/ebakus/go-ebakus/common"
 )
 
@@ -118,6 +121,7 @@ func (i *Interface) SetUint64s(bigints *BigInts) {
 }
 func (i *Interface) SetBigInt(bigint *BigInt)    { i.object = &bigint.bigint }
 func (i *Interface) SetBigInts(bigints *BigInts) { i.object = &bigints.bigints }
+func (i *Interface) SetTuple(fields *Interfaces) { i.object = &tuple{fields: fields.objects} }
 
 func (i *Interface) SetDefaultBool()      { i.object = new(bool) }
 func (i *Interface) SetDefaultBools()     { i.object = new([]bool) }
@@ -147,6 +151,7 @@ func (i *Interface) SetDefaultUint64()    { i.object = new(uint64) }
 func (i *Interface) SetDefaultUint64s()   { i.object = new([]uint64) }
 func (i *Interface) SetDefaultBigInt()    { i.object = new(*big.Int) }
 func (i *Interface) SetDefaultBigInts()   { i.object = new([]*big.Int) }
+func (i *Interface) SetDefaultTuple()     { i.object = new(tuple) }
 
 func (i *Interface) GetBool() bool            { return *i.object.(*bool) }
 func (i *Interface) GetBools() *Bools         { return &Bools{*i.object.(*[]bool)} }
@@ -238,8 +243,104 @@ func (i *Interface) GetUint64s() *BigInts {
 	}
 	return bigints
 }
-func (i *Interface) GetBigInt() *BigInt   { return &BigInt{*i.object.(**big.Int)} }
-func (i *Interface) GetBigInts() *BigInts { return &BigInts{*i.object.(*[]*big.Int)} }
+func (i *Interface) GetBigInt() *BigInt    { return &BigInt{*i.object.(**big.Int)} }
+func (i *Interface) GetBigInts() *BigInts  { return &BigInts{*i.object.(*[]*big.Int)} }
+func (i *Interface) GetTuple() *Interfaces { return &Interfaces{i.object.(*tuple).fields} }
+
+// tuple holds the fields of a Solidity struct. Go structs can't cross over to
+// the mobile platforms, so tuples are converted to the structs the ABI expects
+// right before packing, and back right after unpacking.
+type tuple struct {
+	fields []interface{}
+}
+
+// packTuples returns the objects with their tuples converted to the structs of
+// the matching arguments.
+func packTuples(args abi.Arguments, objects []interface{}) ([]interface{}, error) {
+	packed := make([]interface{}, len(objects))
+	for i, object := range objects {
+		if i >= len(args) {
+			packed[i] = object
+			continue
+		}
+		value, err := tupleToStruct(args[i].Type, object)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %v", i, err)
+		}
+		packed[i] = value
+	}
+	return packed, nil
+}
+
+// tupleToStruct converts a tuple into a pointer to the struct of the given
+// type. Other objects are returned as is.
+func tupleToStruct(kind abi.Type, object interface{}) (interface{}, error) {
+	t, ok := object.(*tuple)
+	if !ok {
+		return object, nil
+	}
+	if kind.T != abi.TupleTy {
+		return nil, fmt.Errorf("tuple given for %v", kind)
+	}
+	if len(t.fields) != len(kind.TupleElems) {
+		return nil, fmt.Errorf("tuple has %d fields, want %d", len(t.fields), len(kind.TupleElems))
+	}
+	value := reflect.New(kind.Type).Elem()
+	for i, elem := range kind.TupleElems {
+		if t.fields[i] == nil {
+			return nil, fmt.Errorf("tuple field %d not set", i)
+		}
+		field, err := tupleToStruct(*elem, t.fields[i])
+		if err != nil {
+			return nil, err
+		}
+		fieldValue := reflect.Indirect(reflect.ValueOf(field))
+		if !fieldValue.Type().AssignableTo(value.Field(i).Type()) {
+			return nil, fmt.Errorf("tuple field %d: cannot use %v as %v", i, fieldValue.Type(), value.Field(i).Type())
+		}
+		value.Field(i).Set(fieldValue)
+	}
+	return value.Addr().Interface(), nil
+}
+
+// unpackTargets returns the result objects with their default tuples replaced
+// by pointers to the structs of the matching arguments, to unpack into.
+func unpackTargets(args abi.Arguments, objects []interface{}) []interface{} {
+	targets := make([]interface{}, len(objects))
+	for i, object := range objects {
+		targets[i] = object
+		if _, ok := object.(*tuple); ok && i < len(args) && args[i].Type.T == abi.TupleTy {
+			targets[i] = reflect.New(args[i].Type.Type).Interface()
+		}
+	}
+	return targets
+}
+
+// unpackTuples stores the unpacked targets into the result objects, converting
+// the structs back to tuples.
+func unpackTuples(targets []interface{}, objects []interface{}) {
+	for i, object := range objects {
+		if _, ok := object.(*tuple); ok && targets[i] != object {
+			objects[i] = structToTuple(reflect.ValueOf(targets[i]).Elem())
+		} else {
+			objects[i] = targets[i]
+		}
+	}
+}
+
+// structToTuple converts an unpacked struct into a tuple, its fields exposed as
+// pointers like the objects of any other interface.
+func structToTuple(value reflect.Value) *tuple {
+	t := &tuple{fields: make([]interface{}, value.NumField())}
+	for i := range t.fields {
+		if field := value.Field(i); field.Kind() == reflect.Struct {
+			t.fields[i] = structToTuple(field)
+		} else {
+			t.fields[i] = field.Addr().Interface()
+		}
+	}
+	return t
+}
 
 // Interfaces is a slices of wrapped generic objects.
 type Interfaces struct {
@@ -104,6 +104,69 @@ func (api *PrivateAdminAPI) RemoveTrustedPeer(url string) (bool, error) {
 	return true, nil
 }
 
+// AddPersistentPeer connects to a remote node and keeps it connected like
+// AddPeer, additionally storing it in the static node list of the data
+// directory so it's reconnected after a restart too.
+func (api *PrivateAdminAPI) AddPersistentPeer(url string) (bool, error) {
+	if _, err := api.AddPeer(url); err != nil {
+		return false, err
+	}
+	node, _ := enode.Parse(enode.ValidSchemes, url)
+	if err := api.node.Config().AddStaticNode(node); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RemovePersistentPeer disconnects from a remote node and deletes it from the
+// static node list of the data directory.
+func (api *PrivateAdminAPI) RemovePersistentPeer(url string) (bool, error) {
+	if _, err := api.RemovePeer(url); err != nil {
+		return false, err
+	}
+	node, _ := enode.Parse(enode.ValidSchemes, url)
+	if err := api.node.Config().RemoveStaticNode(node); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// AddPersistentTrustedPeer marks a remote node as trusted like AddTrustedPeer,
+// additionally storing it in the trusted node list of the data directory.
+func (api *PrivateAdminAPI) AddPersistentTrustedPeer(url string) (bool, error) {
+	if _, err := api.AddTrustedPeer(url); err != nil {
+		return false, err
+	}
+	node, _ := enode.Parse(enode.ValidSchemes, url)
+	if err := api.node.Config().AddTrustedNode(node); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RemovePersistentTrustedPeer removes a remote node from the trusted peer set
+// and deletes it from the trusted node list of the data directory.
+func (api *PrivateAdminAPI) RemovePersistentTrustedPeer(url string) (bool, error) {
+	if _, err := api.RemoveTrustedPeer(url); err != nil {
+		return false, err
+	}
+	node, _ := enode.Parse(enode.ValidSchemes, url)
+	if err := api.node.Config().RemoveTrustedNode(node); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// StaticPeers retrieves the dial statistics of the static peers, which the
+// node keeps reconnecting to with exponential backoff.
+func (api *PrivateAdminAPI) StaticPeers() ([]*p2p.DialStats, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.DialStats(), nil
+}
+
 // PeerEvents creates an RPC subscription which receives peer events from the
 // node's p2p.Server
 func (api *PrivateAdminAPI) PeerEvents(ctx context.Context) (*rpc.Subscription, error) {
@@ -18,6 +18,8 @@ package node
 
 import (
 	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -25,6 +27,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ebakus/go-ebakus/accounts"
 	"github.com/ebakus/go-ebakus/accounts/external"
@@ -136,6 +139,10 @@ type Config struct {
 	// interface.
 	HTTPTimeouts rpc.HTTPTimeouts
 
+	// RPCSlowQuery is the duration past which the RPC calls served by any of the
+	// interfaces are logged along with their parameters. Zero disables the log.
+	RPCSlowQuery time.Duration `toml:",omitempty"`
+
 	// WSHost is the host interface on which to start the websocket RPC server. If
 	// this field is empty, no websocket API endpoint will be started.
 	WSHost string `toml:",omitempty"`
@@ -436,6 +443,68 @@ func (c *Config) parsePersistentNodes(w *bool, path string) []*enode.Node {
 	return nodes
 }
 
+// persistentNodesLock serialises the updates of the node list files.
+var persistentNodesLock sync.Mutex
+
+// AddStaticNode stores the node in the static node list of the data directory,
+// so that it's dialed again after a restart.
+func (c *Config) AddStaticNode(node *enode.Node) error {
+	return c.updatePersistentNodes(c.ResolvePath(datadirStaticNodes), node, true)
+}
+
+// RemoveStaticNode deletes the node from the static node list of the data directory.
+func (c *Config) RemoveStaticNode(node *enode.Node) error {
+	return c.updatePersistentNodes(c.ResolvePath(datadirStaticNodes), node, false)
+}
+
+// AddTrustedNode stores the node in the trusted node list of the data directory.
+func (c *Config) AddTrustedNode(node *enode.Node) error {
+	return c.updatePersistentNodes(c.ResolvePath(datadirTrustedNodes), node, true)
+}
+
+// RemoveTrustedNode deletes the node from the trusted node list of the data directory.
+func (c *Config) RemoveTrustedNode(node *enode.Node) error {
+	return c.updatePersistentNodes(c.ResolvePath(datadirTrustedNodes), node, false)
+}
+
+// updatePersistentNodes adds or removes a node from a .json node list file
+// within the data directory. Entries are matched by node ID, so re-adding a
+// node updates its endpoint.
+func (c *Config) updatePersistentNodes(path string, node *enode.Node, add bool) error {
+	if c.DataDir == "" {
+		return errors.New("ephemeral node, no data directory to persist to")
+	}
+	persistentNodesLock.Lock()
+	defer persistentNodesLock.Unlock()
+
+	var nodelist []string
+	if _, err := os.Stat(path); err == nil {
+		if err := common.LoadJSON(path, &nodelist); err != nil {
+			return err
+		}
+	}
+	updated := nodelist[:0]
+	for _, url := range nodelist {
+		if n, err := enode.Parse(enode.ValidSchemes, url); err == nil && n.ID() == node.ID() {
+			continue
+		}
+		updated = append(updated, url)
+	}
+	if add {
+		updated = append(updated, node.String())
+	}
+	blob, err := json.MarshalIndent(updated, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so a crash can't corrupt the list
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, blob, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
 // AccountConfig determines the settings for scrypt and keydirectory
 func (c *Config) AccountConfig() (int, int, string, error) {
 	scryptN := keystore.StandardScryptN
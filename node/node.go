@@ -26,9 +26,8 @@ import (
 	"strings"
 	"sync"
 
-	"github.com/ebakus/ebakusdb"
-
 	"github.com/ebakus/go-ebakus/accounts"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/rawdb"
 	"github.com/ebakus/go-ebakus/ethdb"
 	"github.com/ebakus/go-ebakus/event"
@@ -285,6 +284,8 @@ func (n *Node) startRPC(services map[reflect.Type]Service) error {
 	for _, service := range services {
 		apis = append(apis, service.APIs()...)
 	}
+	rpc.SetSlowQueryThreshold(n.config.RPCSlowQuery)
+
 	// Start the various API endpoints, terminating all in case of errors
 	if err := n.startInProc(apis); err != nil {
 		return err
@@ -635,12 +636,12 @@ func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, freezer,
 	return rawdb.NewLevelDBDatabaseWithFreezer(root, cache, handles, freezer, namespace)
 }
 
-func (n *Node) OpenEbakusDatabase(name string) (*ebakusdb.DB, error) {
+func (n *Node) OpenEbakusDatabase(name string) (*ebkdb.DB, error) {
 	if n.config.DataDir == "" {
 		return nil, nil // TODO: Implement a memory only version for tests
 	}
 
-	db, err := ebakusdb.Open(n.config.ResolvePath(name), 0666, nil)
+	db, err := ebkdb.Open(n.config.ResolvePath(name), 0666, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -21,13 +21,13 @@ import (
 	"reflect"
 
 	"github.com/ebakus/go-ebakus/accounts"
+	"github.com/ebakus/go-ebakus/core/ebkdb"
 	"github.com/ebakus/go-ebakus/core/rawdb"
 	"github.com/ebakus/go-ebakus/ethdb"
 	"github.com/ebakus/go-ebakus/event"
 	"github.com/ebakus/go-ebakus/log"
 	"github.com/ebakus/go-ebakus/p2p"
 	"github.com/ebakus/go-ebakus/rpc"
-	"github.com/ebakus/ebakusdb"
 )
 
 // ServiceContext is a collection of service independent options inherited from
@@ -72,12 +72,12 @@ func (ctx *ServiceContext) OpenDatabaseWithFreezer(name string, cache int, handl
 
 // OpenEbakusDatabase opens an existing database with the given name (or creates one
 // if no previous can be found) from within the node's data directory.
-func (ctx *ServiceContext) OpenEbakusDatabase(name string, cache int, handles int) (*ebakusdb.DB, error) {
+func (ctx *ServiceContext) OpenEbakusDatabase(name string, cache int, handles int) (*ebkdb.DB, error) {
 	if ctx.config.DataDir == "" {
 		return nil, nil // TODO: Implement a memory only version for tests
 	}
 
-	db, err := ebakusdb.Open(ctx.config.ResolvePath(name), 0666, nil)
+	db, err := ebkdb.Open(ctx.config.ResolvePath(name), 0666, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -39,6 +39,11 @@ const (
 	// Endpoint resolution is throttled with bounded backoff.
 	initialResolveDelay = 60 * time.Second
 	maxResolveDelay     = time.Hour
+
+	// Redials of unreachable static nodes back off exponentially, starting at
+	// dialHistoryExpiration, so a restarted peer of a small mesh is reconnected
+	// quickly while dead ones aren't hammered.
+	maxStaticDialBackoff = 5 * time.Minute
 )
 
 // NodeDialer is used to connect to nodes in the network, typically by using
@@ -203,6 +208,7 @@ var (
 	errAlreadyConnected = errors.New("already connected")
 	errRecentlyDialed   = errors.New("recently dialed")
 	errNotWhitelisted   = errors.New("not contained in netrestrict whitelist")
+	errUnresolved       = errors.New("endpoint unresolved")
 )
 
 func (s *dialstate) checkDial(n *enode.Node, peers map[enode.ID]*Peer) error {
@@ -225,7 +231,11 @@ func (s *dialstate) checkDial(n *enode.Node, peers map[enode.ID]*Peer) error {
 func (s *dialstate) taskDone(t task, now time.Time) {
 	switch t := t.(type) {
 	case *dialTask:
-		s.hist.add(string(t.dest.ID().Bytes()), now.Add(dialHistoryExpiration))
+		expiry := dialHistoryExpiration
+		if t.flags&staticDialedConn != 0 {
+			expiry = t.backoff()
+		}
+		s.hist.add(string(t.dest.ID().Bytes()), now.Add(expiry))
 		delete(s.dialing, t.dest.ID())
 	case *discoverTask:
 		s.lookupRunning = false
@@ -240,11 +250,16 @@ type dialTask struct {
 	dest         *enode.Node
 	lastResolved time.Time
 	resolveDelay time.Duration
+
+	err      error // Outcome of the last dial attempt
+	failures int   // Number of consecutive failed dials of a static node
 }
 
 func (t *dialTask) Do(srv *Server) {
+	t.err = nil
 	if t.dest.Incomplete() {
 		if !t.resolve(srv) {
+			t.err = errUnresolved
 			return
 		}
 	}
@@ -254,10 +269,33 @@ func (t *dialTask) Do(srv *Server) {
 		// Try resolving the ID of static nodes if dialing failed.
 		if _, ok := err.(*dialError); ok && t.flags&staticDialedConn != 0 {
 			if t.resolve(srv) {
-				t.dial(srv, t.dest)
+				err = t.dial(srv, t.dest)
 			}
 		}
 	}
+	t.err = err
+	if t.flags&staticDialedConn != 0 && srv.dialstats != nil {
+		srv.dialstats.record(t.dest, err)
+	}
+}
+
+// backoff returns the time to wait before redialing a static node, doubling
+// with every consecutive failure up to maxStaticDialBackoff.
+func (t *dialTask) backoff() time.Duration {
+	if t.err == nil {
+		t.failures = 0
+		return dialHistoryExpiration
+	}
+	t.failures++
+
+	delay := dialHistoryExpiration
+	for i := 1; i < t.failures && delay < maxStaticDialBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxStaticDialBackoff {
+		delay = maxStaticDialBackoff
+	}
+	return delay
 }
 
 // resolve attempts to find the current endpoint for the destination
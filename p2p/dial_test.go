@@ -555,6 +555,34 @@ func TestDialResolve(t *testing.T) {
 	}
 }
 
+// This test checks that redials of unreachable static nodes back off
+// exponentially and reset after a successful dial.
+func TestDialStaticBackoff(t *testing.T) {
+	task := &dialTask{flags: staticDialedConn, dest: newNode(uintID(1), nil)}
+
+	task.err = errUnresolved
+	for i, want := range []time.Duration{
+		dialHistoryExpiration,
+		2 * dialHistoryExpiration,
+		4 * dialHistoryExpiration,
+		8 * dialHistoryExpiration,
+		maxStaticDialBackoff,
+		maxStaticDialBackoff,
+	} {
+		if delay := task.backoff(); delay != want {
+			t.Fatalf("failure %d: backoff mismatch: have %v, want %v", i+1, delay, want)
+		}
+	}
+	task.err = nil
+	if delay := task.backoff(); delay != dialHistoryExpiration {
+		t.Fatalf("success: backoff mismatch: have %v, want %v", delay, dialHistoryExpiration)
+	}
+	task.err = errUnresolved
+	if delay := task.backoff(); delay != dialHistoryExpiration {
+		t.Fatalf("failure after success: backoff mismatch: have %v, want %v", delay, dialHistoryExpiration)
+	}
+}
+
 // compares task lists but doesn't care about the order.
 func sametasks(a, b []task) bool {
 	if len(a) != len(b) {
@@ -0,0 +1,105 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ebakus/go-ebakus/metrics"
+	"github.com/ebakus/go-ebakus/p2p/enode"
+)
+
+// DialStats are the outbound connection statistics of a static node.
+type DialStats struct {
+	Enode       string    `json:"enode"`       // Node URL as last dialed
+	Attempts    uint64    `json:"attempts"`    // Number of dial attempts
+	Failures    uint64    `json:"failures"`    // Number of failed dial attempts
+	Consecutive uint64    `json:"consecutive"` // Number of failed dial attempts since the last success
+	LastAttempt time.Time `json:"lastAttempt"` // Time of the last dial attempt
+	LastSuccess time.Time `json:"lastSuccess"` // Time of the last successful dial
+	LastError   string    `json:"lastError"`   // Error of the last failed dial attempt
+}
+
+// dialStats tracks the dial attempts to static nodes. Dynamic dials are not
+// tracked, keeping the number of entries bounded by the configured nodes.
+type dialStats struct {
+	lock  sync.Mutex
+	stats map[enode.ID]*DialStats
+}
+
+func newDialStats() *dialStats {
+	return &dialStats{stats: make(map[enode.ID]*DialStats)}
+}
+
+// record updates the statistics of a node with the outcome of a dial.
+func (d *dialStats) record(node *enode.Node, err error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	stats, ok := d.stats[node.ID()]
+	if !ok {
+		stats = new(DialStats)
+		d.stats[node.ID()] = stats
+	}
+	stats.Enode = node.String()
+	stats.Attempts++
+	stats.LastAttempt = time.Now()
+
+	if err != nil {
+		stats.Failures++
+		stats.Consecutive++
+		stats.LastError = err.Error()
+	} else {
+		stats.Consecutive = 0
+		stats.LastSuccess = stats.LastAttempt
+	}
+	if metrics.Enabled {
+		id := node.ID()
+		name := fmt.Sprintf("%x/success", id[:8])
+		if err != nil {
+			name = fmt.Sprintf("%x/failure", id[:8])
+		}
+		metrics.GetOrRegisterMeter(name, PeerDialRegistry).Mark(1)
+	}
+}
+
+// remove drops the statistics of a node no longer dialed.
+func (d *dialStats) remove(node *enode.Node) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	delete(d.stats, node.ID())
+
+	id := node.ID()
+	PeerDialRegistry.Unregister(fmt.Sprintf("%x/success", id[:8]))
+	PeerDialRegistry.Unregister(fmt.Sprintf("%x/failure", id[:8]))
+}
+
+// snapshot returns a copy of the statistics of all the tracked nodes.
+func (d *dialStats) snapshot() []*DialStats {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	stats := make([]*DialStats, 0, len(d.stats))
+	for _, s := range d.stats {
+		cpy := *s
+		stats = append(stats, &cpy)
+	}
+	return stats
+}
@@ -56,9 +56,12 @@ type LocalNode struct {
 }
 
 type lnEndpoint struct {
-	track                *netutil.IPTracker
-	staticIP, fallbackIP net.IP
-	fallbackUDP          int
+	track                    *netutil.IPTracker
+	staticIP, fallbackIP     net.IP
+	fallbackTCP, fallbackUDP int
+
+	announceIP               net.IP
+	announceTCP, announceUDP int
 }
 
 // NewLocalNode creates a local node.
@@ -172,6 +175,28 @@ func (ln *LocalNode) SetFallbackIP(ip net.IP) {
 	ln.updateEndpoints()
 }
 
+// SetAnnouncedEndpoint sets the endpoint advertised for the address family of
+// the given IP unconditionally, overriding static IPs and endpoint prediction.
+// Zero ports leave the respective ports unchanged.
+func (ln *LocalNode) SetAnnouncedEndpoint(ip net.IP, tcp, udp int) {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+
+	e := ln.endpointForIP(ip)
+	e.announceIP, e.announceTCP, e.announceUDP = ip, tcp, udp
+	ln.updateEndpoints()
+}
+
+// SetFallbackTCP sets the last-resort TCP port, usually the listening port.
+func (ln *LocalNode) SetFallbackTCP(port int) {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+
+	ln.endpoint4.fallbackTCP = port
+	ln.endpoint6.fallbackTCP = port
+	ln.updateEndpoints()
+}
+
 // SetFallbackUDP sets the last-resort UDP-on-IPv4 port. This port is used
 // if no endpoint prediction can be made.
 func (ln *LocalNode) SetFallbackUDP(port int) {
@@ -205,8 +230,8 @@ func (ln *LocalNode) UDPContact(toaddr *net.UDPAddr) {
 
 // updateEndpoints updates the record with predicted endpoints.
 func (ln *LocalNode) updateEndpoints() {
-	ip4, udp4 := ln.endpoint4.get()
-	ip6, udp6 := ln.endpoint6.get()
+	ip4, tcp4, udp4 := ln.endpoint4.get()
+	ip6, tcp6, udp6 := ln.endpoint6.get()
 
 	if ip4 != nil && !ip4.IsUnspecified() {
 		ln.set(enr.IPv4(ip4))
@@ -228,11 +253,20 @@ func (ln *LocalNode) updateEndpoints() {
 	} else {
 		ln.delete(enr.UDP6(0))
 	}
+	// The TCP port may also be set directly, only override it if known
+	if tcp4 != 0 {
+		ln.set(enr.TCP(tcp4))
+	}
+	if tcp6 != 0 && tcp6 != tcp4 {
+		ln.set(enr.TCP6(tcp6))
+	} else {
+		ln.delete(enr.TCP6(0))
+	}
 }
 
 // get returns the endpoint with highest precedence.
-func (e *lnEndpoint) get() (newIP net.IP, newPort int) {
-	newPort = e.fallbackUDP
+func (e *lnEndpoint) get() (newIP net.IP, newTCP, newUDP int) {
+	newTCP, newUDP = e.fallbackTCP, e.fallbackUDP
 	if e.fallbackIP != nil {
 		newIP = e.fallbackIP
 	}
@@ -240,9 +274,18 @@ func (e *lnEndpoint) get() (newIP net.IP, newPort int) {
 		newIP = e.staticIP
 	} else if ip, port := predictAddr(e.track); ip != nil {
 		newIP = ip
-		newPort = port
+		newUDP = port
+	}
+	if e.announceIP != nil {
+		newIP = e.announceIP
+		if e.announceTCP != 0 {
+			newTCP = e.announceTCP
+		}
+		if e.announceUDP != 0 {
+			newUDP = e.announceUDP
+		}
 	}
-	return newIP, newPort
+	return newIP, newTCP, newUDP
 }
 
 // predictAddr wraps IPTracker.PredictEndpoint, converting from its string-based
@@ -120,3 +120,33 @@ func TestLocalNodeEndpoint(t *testing.T) {
 	assert.Equal(t, fallback.Port, ln.Node().UDP())
 	assert.Equal(t, uint64(4), ln.Node().Seq())
 }
+
+// This test checks that announced endpoints of both address families end up
+// in the record.
+func TestLocalNodeAnnouncedEndpoint(t *testing.T) {
+	var (
+		announced4 = &net.TCPAddr{IP: net.IP{203, 0, 113, 1}, Port: 30303}
+		announced6 = &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 30304}
+	)
+	ln, db := newLocalNodeForTesting()
+	defer db.Close()
+
+	ln.SetFallbackIP(net.IP{127, 0, 0, 1})
+	ln.SetFallbackTCP(30300)
+	ln.SetFallbackUDP(30300)
+	assert.Equal(t, 30300, ln.Node().TCP())
+	assert.Equal(t, net.IP(nil), ln.Node().IPv6())
+
+	// Announced endpoints override the static IP and the fallback ports.
+	ln.SetStaticIP(net.IP{127, 0, 1, 2})
+	ln.SetAnnouncedEndpoint(announced4.IP, announced4.Port, announced4.Port)
+	ln.SetAnnouncedEndpoint(announced6.IP, announced6.Port, announced6.Port)
+
+	n := ln.Node()
+	assert.Equal(t, announced4.IP, n.IP())
+	assert.Equal(t, announced4.Port, n.TCP())
+	assert.Equal(t, announced4.Port, n.UDP())
+	assert.Equal(t, announced6.IP, n.IPv6())
+	assert.Equal(t, announced6.Port, n.TCP6())
+	assert.Equal(t, announced6.Port, n.UDP6())
+}
@@ -115,20 +115,65 @@ func (n *Node) IP() net.IP {
 	return nil
 }
 
-// UDP returns the UDP port of the node.
+// IPv6 returns the IPv6 address of the node, if it has one.
+func (n *Node) IPv6() net.IP {
+	var ip6 enr.IPv6
+	if n.Load(&ip6) == nil {
+		return net.IP(ip6)
+	}
+	return nil
+}
+
+// UDP returns the UDP port of the node, the one of the address returned by IP.
 func (n *Node) UDP() int {
+	if !n.hasIPv4() {
+		return n.UDP6()
+	}
 	var port enr.UDP
 	n.Load(&port)
 	return int(port)
 }
 
-// UDP returns the TCP port of the node.
+// TCP returns the TCP port of the node, the one of the address returned by IP.
 func (n *Node) TCP() int {
+	if !n.hasIPv4() {
+		return n.TCP6()
+	}
+	var port enr.TCP
+	n.Load(&port)
+	return int(port)
+}
+
+// UDP6 returns the UDP port of the IPv6 endpoint of the node. Unless set
+// explicitly, it is the same as the IPv4 one.
+func (n *Node) UDP6() int {
+	var port6 enr.UDP6
+	if n.Load(&port6) == nil {
+		return int(port6)
+	}
+	var port enr.UDP
+	n.Load(&port)
+	return int(port)
+}
+
+// TCP6 returns the TCP port of the IPv6 endpoint of the node. Unless set
+// explicitly, it is the same as the IPv4 one.
+func (n *Node) TCP6() int {
+	var port6 enr.TCP6
+	if n.Load(&port6) == nil {
+		return int(port6)
+	}
 	var port enr.TCP
 	n.Load(&port)
 	return int(port)
 }
 
+// hasIPv4 reports whether the node has an IPv4 address.
+func (n *Node) hasIPv4() bool {
+	var ip4 enr.IPv4
+	return n.Load(&ip4) == nil
+}
+
 // Pubkey returns the secp256k1 public key of the node, if present.
 func (n *Node) Pubkey() *ecdsa.PublicKey {
 	var key ecdsa.PublicKey
@@ -25,6 +25,7 @@ import (
 	"net/url"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/ebakus/go-ebakus/common/math"
 	"github.com/ebakus/go-ebakus/crypto"
@@ -66,6 +67,11 @@ func MustParseV4(rawurl string) *Node {
 // and UDP discovery port 30301.
 //
 //    enode://<hex node id>@10.3.58.6:30303?discport=30301
+//
+// A dual-stack node lists the endpoint of its other address family as query
+// parameter "addr", whose port is both its TCP and UDP port there:
+//
+//    enode://<hex node id>@10.3.58.6:30303?addr=[2001:db8::6]:30305
 func ParseV4(rawurl string) (*Node, error) {
 	if m := incompleteNodeURL.FindStringSubmatch(rawurl); m != nil {
 		id, err := parsePubkey(m[1])
@@ -146,7 +152,44 @@ func parseComplete(rawurl string) (*Node, error) {
 			return nil, errors.New("invalid discport in query")
 		}
 	}
-	return NewV4(id, ip, int(tcpPort), int(udpPort)), nil
+	if qv.Get("addr") == "" {
+		return NewV4(id, ip, int(tcpPort), int(udpPort)), nil
+	}
+	// Parse the endpoint of the other address family.
+	host, port, err := net.SplitHostPort(qv.Get("addr"))
+	if err != nil {
+		return nil, errors.New("invalid addr in query")
+	}
+	addrIP := net.ParseIP(host)
+	if addrIP == nil {
+		return nil, errors.New("invalid addr IP in query")
+	}
+	addrPort, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, errors.New("invalid addr port in query")
+	}
+	if (addrIP.To4() != nil) == (ip.To4() != nil) {
+		return nil, errors.New("addr in query has the address family of the host")
+	}
+	ip4, tcp4, udp4 := ip, tcpPort, udpPort
+	ip6, tcp6, udp6 := addrIP, addrPort, addrPort
+	if ip.To4() == nil {
+		ip4, tcp4, udp4 = addrIP, addrPort, addrPort
+		ip6, tcp6, udp6 = ip, tcpPort, udpPort
+	}
+	var r enr.Record
+	r.Set(enr.IPv4(ip4.To4()))
+	r.Set(enr.TCP(tcp4))
+	r.Set(enr.UDP(udp4))
+	r.Set(enr.IPv6(ip6))
+	if tcp6 != tcp4 {
+		r.Set(enr.TCP6(tcp6))
+	}
+	if udp6 != udp4 {
+		r.Set(enr.UDP6(udp6))
+	}
+	signV4Compat(&r, id)
+	return New(v4CompatID{}, &r)
 }
 
 // parsePubkey parses a hex-encoded secp256k1 public key.
@@ -182,9 +225,16 @@ func (n *Node) URLv4() string {
 		addr := net.TCPAddr{IP: n.IP(), Port: n.TCP()}
 		u.User = url.User(nodeid)
 		u.Host = addr.String()
+
+		var query []string
 		if n.UDP() != n.TCP() {
-			u.RawQuery = "discport=" + strconv.Itoa(n.UDP())
+			query = append(query, "discport="+strconv.Itoa(n.UDP()))
+		}
+		if ip6 := n.IPv6(); ip6 != nil && n.hasIPv4() {
+			addr6 := net.TCPAddr{IP: ip6, Port: n.TCP6()}
+			query = append(query, "addr="+addr6.String())
 		}
+		u.RawQuery = strings.Join(query, "&")
 	}
 	return u.String()
 }
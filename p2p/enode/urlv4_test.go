@@ -178,6 +178,57 @@ func TestParseNode(t *testing.T) {
 	}
 }
 
+func TestParseNodeDualStack(t *testing.T) {
+	const id = "1dd9d65c4552b5eb43d5ad55a2ee3f56c6cbc1c64a5c8d659f51fcd51bace24351232b8d7821617d2b29b54b81cdefb9b3e9c37d7fd5f63270bcc9e1a6f6a439"
+
+	tests := []struct {
+		input, canonical string
+		wantError        string
+	}{
+		{
+			input: "enode://" + id + "@127.0.0.1:52150?addr=[2001:db8::1]:52151",
+		},
+		{
+			input:     "enode://" + id + "@[2001:db8::1]:52151?addr=127.0.0.1:52150",
+			canonical: "enode://" + id + "@127.0.0.1:52150?addr=[2001:db8::1]:52151",
+		},
+		{
+			input:     "enode://" + id + "@127.0.0.1:52150?addr=127.0.0.2:52151",
+			wantError: "address family",
+		},
+		{
+			input:     "enode://" + id + "@127.0.0.1:52150?addr=[2001:db8::1]",
+			wantError: "invalid addr in query",
+		},
+	}
+	for _, test := range tests {
+		n, err := ParseV4(test.input)
+		if test.wantError != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantError) {
+				t.Errorf("test %q:\n  got error %v, expected %#q", test.input, err, test.wantError)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %q:\n  unexpected error: %v", test.input, err)
+			continue
+		}
+		if !n.IP().Equal(net.IP{127, 0, 0, 1}) || n.TCP() != 52150 || n.UDP() != 52150 {
+			t.Errorf("test %q:\n  IPv4 endpoint mismatch: %v:%d/%d", test.input, n.IP(), n.TCP(), n.UDP())
+		}
+		if !n.IPv6().Equal(net.ParseIP("2001:db8::1")) || n.TCP6() != 52151 || n.UDP6() != 52151 {
+			t.Errorf("test %q:\n  IPv6 endpoint mismatch: %v:%d/%d", test.input, n.IPv6(), n.TCP6(), n.UDP6())
+		}
+		canonical := test.canonical
+		if canonical == "" {
+			canonical = test.input
+		}
+		if str := n.String(); str != canonical {
+			t.Errorf("test %q:\n  Node.String() mismatch:\ngot:  %s\nwant: %s", test.input, str, canonical)
+		}
+	}
+}
+
 func TestNodeString(t *testing.T) {
 	for i, test := range parseNodeTests {
 		if test.wantError == "" && strings.HasPrefix(test.input, "enode://") {
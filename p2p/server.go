@@ -37,7 +37,6 @@ import (
 	"github.com/ebakus/go-ebakus/p2p/discover"
 	"github.com/ebakus/go-ebakus/p2p/discv5"
 	"github.com/ebakus/go-ebakus/p2p/enode"
-	"github.com/ebakus/go-ebakus/p2p/enr"
 	"github.com/ebakus/go-ebakus/p2p/nat"
 	"github.com/ebakus/go-ebakus/p2p/netutil"
 )
@@ -143,6 +142,12 @@ type Config struct {
 	// Internet.
 	NAT nat.Interface `toml:",omitempty"`
 
+	// AnnounceAddrs are endpoints (ip:port) advertised in the node record in
+	// place of the detected ones, at most one per address family. They allow
+	// dual-stack nodes to advertise both their IPv4 and IPv6 endpoints. A zero
+	// port advertises the listening port.
+	AnnounceAddrs []string `toml:",omitempty"`
+
 	// If Dialer is set to a non-nil value, the given Dialer
 	// is used to dial outbound peer connections.
 	Dialer NodeDialer `toml:"-"`
@@ -185,6 +190,7 @@ type Server struct {
 	discmix   *enode.FairMix
 
 	staticNodeResolver nodeResolver
+	dialstats          *dialStats
 
 	// Channels into the run loop.
 	quit                    chan struct{}
@@ -464,6 +470,7 @@ func (srv *Server) Start() (err error) {
 	srv.removetrusted = make(chan *enode.Node)
 	srv.peerOp = make(chan peerOpFunc)
 	srv.peerOpDone = make(chan struct{})
+	srv.dialstats = newDialStats()
 
 	if err := srv.setupLocalNode(); err != nil {
 		return err
@@ -525,6 +532,23 @@ func (srv *Server) setupLocalNode() error {
 			}
 		}()
 	}
+	// Advertise the explicitly configured endpoints
+	var ipv4, ipv6 bool
+	for _, addr := range srv.AnnounceAddrs {
+		tcp, err := net.ResolveTCPAddr("tcp", addr)
+		if err != nil || tcp.IP == nil {
+			return fmt.Errorf("invalid announced address %q", addr)
+		}
+		family := &ipv6
+		if tcp.IP.To4() != nil {
+			family = &ipv4
+		}
+		if *family {
+			return fmt.Errorf("multiple announced addresses of the family of %q", addr)
+		}
+		*family = true
+		srv.localnode.SetAnnouncedEndpoint(tcp.IP, tcp.Port, tcp.Port)
+	}
 	return nil
 }
 
@@ -617,7 +641,7 @@ func (srv *Server) setupListening() error {
 
 	// Update the local node record and map the TCP listening port if NAT is configured.
 	if tcp, ok := listener.Addr().(*net.TCPAddr); ok {
-		srv.localnode.Set(enr.TCP(tcp.Port))
+		srv.localnode.SetFallbackTCP(tcp.Port)
 		if !tcp.IP.IsLoopback() && srv.NAT != nil {
 			srv.loopWG.Add(1)
 			go func() {
@@ -717,6 +741,7 @@ running:
 			// stop keeping the node connected.
 			srv.log.Trace("Removing static node", "node", n)
 			dialstate.removeStatic(n)
+			srv.dialstats.remove(n)
 			if p, ok := peers[n.ID()]; ok {
 				p.Disconnect(DiscRequested)
 			}
@@ -1131,6 +1156,14 @@ func (srv *Server) NodeInfo() *NodeInfo {
 	return info
 }
 
+// DialStats returns the outbound connection statistics of the static nodes.
+func (srv *Server) DialStats() []*DialStats {
+	if srv.dialstats == nil {
+		return nil
+	}
+	return srv.dialstats.snapshot()
+}
+
 // PeersInfo returns an array of metadata objects describing connected peers.
 func (srv *Server) PeersInfo() []*PeerInfo {
 	// Gather all the generic and sub-protocol specific infos
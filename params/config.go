@@ -18,6 +18,7 @@ package params
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"math/big"
 
@@ -103,12 +104,12 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), 0, new(EthashConfig), nil}
 
 	// AllDPOSProtocolChanges contains all changes
-	AllDPOSProtocolChanges = &ChainConfig{big.NewInt(7), big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, &DPOSConfig{Period: 1}}
+	AllDPOSProtocolChanges = &ChainConfig{big.NewInt(7), big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), 0, nil, &DPOSConfig{Period: 1}}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), 0, new(EthashConfig), nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -172,6 +173,36 @@ type ChainConfig struct {
 	ConstantinopleBlock *big.Int `json:"constantinopleBlock,omitempty"` // Constantinople switch block (nil = no fork, 0 = already activated)
 	PetersburgBlock     *big.Int `json:"petersburgBlock,omitempty"`     // Petersburg switch block (nil = same as Constantinople)
 	EWASMBlock          *big.Int `json:"ewasmBlock,omitempty"`          // EWASM switch block (nil = no fork, 0 = already activated)
+	TableTombstoneBlock *big.Int `json:"tableTombstoneBlock,omitempty"` // Self-destructed contracts' tables tombstoning switch block (nil = no fork, 0 = already activated)
+	TableAliasBlock     *big.Int `json:"tableAliasBlock,omitempty"`     // Tables namespace aliasing switch block (nil = no fork, 0 = already activated)
+	StrictInputBlock    *big.Int `json:"strictInputBlock,omitempty"`    // Strict precompile input validation switch block (nil = no fork, 0 = already activated)
+	PartialUnvoteBlock  *big.Int `json:"partialUnvoteBlock,omitempty"`  // Partial unvote of witnesses switch block (nil = no fork, 0 = already activated)
+	TableUpdateBlock    *big.Int `json:"tableUpdateBlock,omitempty"`    // Partial table row updates switch block (nil = no fork, 0 = already activated)
+	CallPolicyBlock     *big.Int `json:"callPolicyBlock,omitempty"`     // System and db contracts call policy switch block (nil = no fork, 0 = already activated)
+	SavepointBlock      *big.Int `json:"savepointBlock,omitempty"`      // Db contract savepoints switch block (nil = no fork, 0 = already activated)
+	PerformanceBlock    *big.Int `json:"performanceBlock,omitempty"`    // Witness performance tracking switch block (nil = no fork, 0 = already activated)
+	ClaimMergeBlock     *big.Int `json:"claimMergeBlock,omitempty"`     // Claimable entries merging switch block (nil = no fork, 0 = already activated)
+	LockedTransferBlock *big.Int `json:"lockedTransferBlock,omitempty"` // Time locked transfers switch block (nil = no fork, 0 = already activated)
+	SubscriptionBlock   *big.Int `json:"subscriptionBlock,omitempty"`   // Recurring payment subscriptions switch block (nil = no fork, 0 = already activated)
+	AllowanceBlock      *big.Int `json:"allowanceBlock,omitempty"`      // Native token allowances switch block (nil = no fork, 0 = already activated)
+	WrappedTokenBlock   *big.Int `json:"wrappedTokenBlock,omitempty"`   // Wrapped EBK precompile switch block (nil = no fork, 0 = already activated)
+	BridgeBlock         *big.Int `json:"bridgeBlock,omitempty"`         // Bridge intents switch block (nil = no fork, 0 = already activated)
+	StrictEIP155Block   *big.Int `json:"strictEIP155Block,omitempty"`   // Unprotected transactions rejection switch block (nil = no fork, 0 = already activated)
+	RangeQueryBlock     *big.Int `json:"rangeQueryBlock,omitempty"`     // Db contract range queries switch block (nil = no fork, 0 = already activated)
+	EnodeAnnounceBlock  *big.Int `json:"enodeAnnounceBlock,omitempty"`  // Witness enode announcements switch block (nil = no fork, 0 = already activated)
+	AbiVersioningBlock  *big.Int `json:"abiVersioningBlock,omitempty"`  // Contract ABI versioning switch block (nil = no fork, 0 = already activated)
+	PrecompileLogsBlock *big.Int `json:"precompileLogsBlock,omitempty"` // Staking, voting and db contract logs switch block (nil = no fork, 0 = already activated)
+	RowSizeGasBlock     *big.Int `json:"rowSizeGasBlock,omitempty"`     // Db contract gas proportional to row sizes switch block (nil = no fork, 0 = already activated)
+	IteratorScopeBlock  *big.Int `json:"iteratorScopeBlock,omitempty"`  // Db contract iterators scoped to call frames switch block (nil = no fork, 0 = already activated)
+	RewardShareBlock    *big.Int `json:"rewardShareBlock,omitempty"`    // Block reward sharing with the voters switch block (nil = no fork, 0 = already activated)
+	SystemSchemaBlock   *big.Int `json:"systemSchemaBlock,omitempty"`   // System tables schema migrations switch block (nil = no fork, 0 = already activated)
+	StandbyBlock        *big.Int `json:"standbyBlock,omitempty"`        // Standby block production switch block (nil = no fork, 0 = already activated)
+
+	// ValueDecimalPoints is the precision of the amounts the system contracts
+	// stake, transfer and claim, i.e. their smallest unit is 10^-ValueDecimalPoints
+	// EBK. Chains configured before it was introduced don't set it, and keep the
+	// default precision.
+	ValueDecimalPoints uint64 `json:"valueDecimalPoints,omitempty"` // (0 = DefaultValueDecimalPoints)
 
 	// Various consensus engines
 	Ethash *EthashConfig `json:"ethash,omitempty"`
@@ -196,11 +227,24 @@ type DPOSConfig struct {
 	BonusDelegateCount  uint64         `json:"bonusDelegateCount"`  // Number of delegates to pickup the 21st bonus delegate
 	MaxWitnessesVotes   uint64         `json:"maxWitnessesVotes"`   // Max number of witnesses votes per account
 	BootProducer        common.Address `json:"bootProducer"`        // Boot producer for genesis block
+
+	MaxMissedSlots uint64 `json:"maxMissedSlots,omitempty"` // Consecutive missed slots de-electing a witness (0 = never)
+	RewardShare    uint64 `json:"rewardShare,omitempty"`    // Percentage of the block reward shared with the producer's voters from RewardShareBlock on (0 = none)
+	StandbyCount   uint64 `json:"standbyCount,omitempty"`   // Delegates following the one in turn which may produce its missed blocks from StandbyBlock on (0 = none)
+	StandbyDelay   uint64 `json:"standbyDelay,omitempty"`   // Percentage of the slot each standby delegate waits for the ones before it
+}
+
+// Fingerprint returns a hash identifying the network by its genesis block and
+// its DPOS parameters, so nodes of unrelated private networks sharing the same
+// genesis or network id can tell each other apart.
+func (c *ChainConfig) Fingerprint(genesis common.Hash) common.Hash {
+	blob, _ := json.Marshal(c.DPOS)
+	return crypto.Keccak256Hash(genesis[:], blob)
 }
 
 // String implements the stringer interface, returning the consensus engine details.
 func (c *DPOSConfig) String() string {
-	return fmt.Sprintf("{DPOS: {DelegateCount: %v BonusDelegateCount: %v Period: %v TurnBlockCount: %v InitialDistribution: %v YearlyInflation: %v MaxWitnessesVotes: %v}}",
+	return fmt.Sprintf("{DPOS: {DelegateCount: %v BonusDelegateCount: %v Period: %v TurnBlockCount: %v InitialDistribution: %v YearlyInflation: %v MaxWitnessesVotes: %v MaxMissedSlots: %v RewardShare: %v StandbyCount: %v StandbyDelay: %v}}",
 		c.DelegateCount,
 		c.BonusDelegateCount,
 		c.Period,
@@ -208,6 +252,10 @@ func (c *DPOSConfig) String() string {
 		c.InitialDistribution,
 		c.YearlyInflation,
 		c.MaxWitnessesVotes,
+		c.MaxMissedSlots,
+		c.RewardShare,
+		c.StandbyCount,
+		c.StandbyDelay,
 	)
 }
 
@@ -260,6 +308,171 @@ func (c *ChainConfig) IsEWASM(num *big.Int) bool {
 	return isForked(c.EWASMBlock, num)
 }
 
+// IsTableTombstone returns whether num represents a block number after the
+// fork tombstoning the tables of self-destructed contracts.
+func (c *ChainConfig) IsTableTombstone(num *big.Int) bool {
+	return isForked(c.TableTombstoneBlock, num)
+}
+
+// IsTableAlias returns whether num represents a block number after the fork
+// allowing contracts to alias their tables namespace.
+func (c *ChainConfig) IsTableAlias(num *big.Int) bool {
+	return isForked(c.TableAliasBlock, num)
+}
+
+// IsStrictInput returns whether num represents a block number after the fork
+// rejecting misaligned or oversized system and db contract inputs.
+func (c *ChainConfig) IsStrictInput(num *big.Int) bool {
+	return isForked(c.StrictInputBlock, num)
+}
+
+// IsPartialUnvote returns whether num represents a block number after the fork
+// allowing stakers to unvote individual witnesses.
+func (c *ChainConfig) IsPartialUnvote(num *big.Int) bool {
+	return isForked(c.PartialUnvoteBlock, num)
+}
+
+// IsTableUpdate returns whether num represents a block number after the fork
+// allowing contracts to update individual fields of their table rows.
+func (c *ChainConfig) IsTableUpdate(num *big.Int) bool {
+	return isForked(c.TableUpdateBlock, num)
+}
+
+// IsCallPolicy returns whether num represents a block number after the fork
+// rejecting delegate calls and static writes to the system and db contracts.
+func (c *ChainConfig) IsCallPolicy(num *big.Int) bool {
+	return isForked(c.CallPolicyBlock, num)
+}
+
+// IsSavepoint returns whether num represents a block number after the fork
+// allowing contracts to roll back their table writes to a savepoint.
+func (c *ChainConfig) IsSavepoint(num *big.Int) bool {
+	return isForked(c.SavepointBlock, num)
+}
+
+// IsPerformance returns whether num represents a block number after the fork
+// tracking the produced and missed blocks of the witnesses.
+func (c *ChainConfig) IsPerformance(num *big.Int) bool {
+	return isForked(c.PerformanceBlock, num)
+}
+
+// IsClaimMerge returns whether num represents a block number after the fork
+// merging the claimable entries of an unstake vesting within the same day.
+func (c *ChainConfig) IsClaimMerge(num *big.Int) bool {
+	return isForked(c.ClaimMergeBlock, num)
+}
+
+// IsLockedTransfer returns whether num represents a block number after the fork
+// allowing transfers the recipient can only claim after an unlock time.
+func (c *ChainConfig) IsLockedTransfer(num *big.Int) bool {
+	return isForked(c.LockedTransferBlock, num)
+}
+
+// IsSubscription returns whether num represents a block number after the fork
+// allowing payees to settle recurring payments pre-authorized by the payers.
+func (c *ChainConfig) IsSubscription(num *big.Int) bool {
+	return isForked(c.SubscriptionBlock, num)
+}
+
+// IsAllowance returns whether num represents a block number after the fork
+// allowing accounts to approve spenders pulling native tokens from them.
+func (c *ChainConfig) IsAllowance(num *big.Int) bool {
+	return isForked(c.AllowanceBlock, num)
+}
+
+// IsWrappedToken returns whether num represents a block number after the fork
+// enabling the wrapped EBK token precompile.
+func (c *ChainConfig) IsWrappedToken(num *big.Int) bool {
+	return isForked(c.WrappedTokenBlock, num)
+}
+
+// IsBridge returns whether num represents a block number after the fork
+// committing to the bridge intents submitted to the system contract.
+func (c *ChainConfig) IsBridge(num *big.Int) bool {
+	return isForked(c.BridgeBlock, num)
+}
+
+// IsStrictEIP155 returns whether num represents a block number after the fork
+// rejecting the transactions without EIP155 replay protection.
+func (c *ChainConfig) IsStrictEIP155(num *big.Int) bool {
+	return isForked(c.StrictEIP155Block, num)
+}
+
+// IsRangeQuery returns whether num represents a block number after the fork
+// introducing the LIMIT and OFFSET terms and the comparisons on indexed fields
+// to the db contract selects.
+func (c *ChainConfig) IsRangeQuery(num *big.Int) bool {
+	return isForked(c.RangeQueryBlock, num)
+}
+
+// IsEnodeAnnounce returns whether num represents a block number after the fork
+// letting the witnesses announce the enode of their block producing node.
+func (c *ChainConfig) IsEnodeAnnounce(num *big.Int) bool {
+	return isForked(c.EnodeAnnounceBlock, num)
+}
+
+// IsAbiVersioning returns whether num represents a block number after the fork
+// letting the creators of contracts publish new versions of their ABIs.
+func (c *ChainConfig) IsAbiVersioning(num *big.Int) bool {
+	return isForked(c.AbiVersioningBlock, num)
+}
+
+// IsPrecompileLogs returns whether num represents a block number after the fork
+// making the system and db contracts log the staking, voting and table writes.
+func (c *ChainConfig) IsPrecompileLogs(num *big.Int) bool {
+	return isForked(c.PrecompileLogsBlock, num)
+}
+
+// IsRowSizeGas returns whether num represents a block number after the fork
+// charging the db contract commands proportionally to the rows and indexes
+// they handle.
+func (c *ChainConfig) IsRowSizeGas(num *big.Int) bool {
+	return isForked(c.RowSizeGasBlock, num)
+}
+
+// IsIteratorScope returns whether num represents a block number after the fork
+// scoping the db contract iterators to the call frame which selected them.
+func (c *ChainConfig) IsIteratorScope(num *big.Int) bool {
+	return isForked(c.IteratorScopeBlock, num)
+}
+
+// IsRewardShare returns whether num represents a block number after the fork
+// sharing the block reward with the voters of the producing witness.
+func (c *ChainConfig) IsRewardShare(num *big.Int) bool {
+	return isForked(c.RewardShareBlock, num)
+}
+
+// IsSystemSchema returns whether num represents a block number after the fork
+// running the system tables schema migrations.
+func (c *ChainConfig) IsSystemSchema(num *big.Int) bool {
+	return isForked(c.SystemSchemaBlock, num)
+}
+
+// IsStandby returns whether num represents a block number after the fork
+// letting the standby delegates produce the blocks missed by the delegate in turn.
+func (c *ChainConfig) IsStandby(num *big.Int) bool {
+	return isForked(c.StandbyBlock, num)
+}
+
+// ValueDecimals returns the number of decimal points of the amounts of the
+// system contracts.
+func (c *ChainConfig) ValueDecimals() uint64 {
+	if c.ValueDecimalPoints == 0 {
+		return DefaultValueDecimalPoints
+	}
+	return c.ValueDecimalPoints
+}
+
+// ValuePrecision returns the wei in the smallest unit of the amounts of the
+// system contracts.
+func (c *ChainConfig) ValuePrecision() *big.Int {
+	decimals := c.ValueDecimals()
+	if decimals > MaxValueDecimalPoints {
+		decimals = MaxValueDecimalPoints
+	}
+	return new(big.Int).Exp(big.NewInt(10), new(big.Int).SetUint64(MaxValueDecimalPoints-decimals), nil)
+}
+
 // CheckCompatible checks whether scheduled fork transitions have been imported
 // with a mismatching chain configuration.
 func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64) *ConfigCompatError {
@@ -308,6 +521,9 @@ func (c *ChainConfig) CheckConfigForkOrder() error {
 		}
 		lastFork = cur
 	}
+	if c.ValueDecimals() > MaxValueDecimalPoints {
+		return fmt.Errorf("unsupported value decimal points: %d, maximum %d", c.ValueDecimals(), MaxValueDecimalPoints)
+	}
 	return nil
 }
 
@@ -333,6 +549,83 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.EWASMBlock, newcfg.EWASMBlock, head) {
 		return newCompatError("ewasm fork block", c.EWASMBlock, newcfg.EWASMBlock)
 	}
+	if isForkIncompatible(c.TableTombstoneBlock, newcfg.TableTombstoneBlock, head) {
+		return newCompatError("Table tombstone fork block", c.TableTombstoneBlock, newcfg.TableTombstoneBlock)
+	}
+	if isForkIncompatible(c.TableAliasBlock, newcfg.TableAliasBlock, head) {
+		return newCompatError("Table alias fork block", c.TableAliasBlock, newcfg.TableAliasBlock)
+	}
+	if isForkIncompatible(c.StrictInputBlock, newcfg.StrictInputBlock, head) {
+		return newCompatError("Strict input fork block", c.StrictInputBlock, newcfg.StrictInputBlock)
+	}
+	if isForkIncompatible(c.PartialUnvoteBlock, newcfg.PartialUnvoteBlock, head) {
+		return newCompatError("Partial unvote fork block", c.PartialUnvoteBlock, newcfg.PartialUnvoteBlock)
+	}
+	if isForkIncompatible(c.TableUpdateBlock, newcfg.TableUpdateBlock, head) {
+		return newCompatError("Table update fork block", c.TableUpdateBlock, newcfg.TableUpdateBlock)
+	}
+	if isForkIncompatible(c.CallPolicyBlock, newcfg.CallPolicyBlock, head) {
+		return newCompatError("Call policy fork block", c.CallPolicyBlock, newcfg.CallPolicyBlock)
+	}
+	if isForkIncompatible(c.SavepointBlock, newcfg.SavepointBlock, head) {
+		return newCompatError("Savepoint fork block", c.SavepointBlock, newcfg.SavepointBlock)
+	}
+	if isForkIncompatible(c.PerformanceBlock, newcfg.PerformanceBlock, head) {
+		return newCompatError("Performance fork block", c.PerformanceBlock, newcfg.PerformanceBlock)
+	}
+	if isForkIncompatible(c.ClaimMergeBlock, newcfg.ClaimMergeBlock, head) {
+		return newCompatError("Claim merge fork block", c.ClaimMergeBlock, newcfg.ClaimMergeBlock)
+	}
+	if isForkIncompatible(c.LockedTransferBlock, newcfg.LockedTransferBlock, head) {
+		return newCompatError("Locked transfer fork block", c.LockedTransferBlock, newcfg.LockedTransferBlock)
+	}
+	if isForkIncompatible(c.SubscriptionBlock, newcfg.SubscriptionBlock, head) {
+		return newCompatError("Subscription fork block", c.SubscriptionBlock, newcfg.SubscriptionBlock)
+	}
+	if isForkIncompatible(c.AllowanceBlock, newcfg.AllowanceBlock, head) {
+		return newCompatError("Allowance fork block", c.AllowanceBlock, newcfg.AllowanceBlock)
+	}
+	if isForkIncompatible(c.WrappedTokenBlock, newcfg.WrappedTokenBlock, head) {
+		return newCompatError("Wrapped token fork block", c.WrappedTokenBlock, newcfg.WrappedTokenBlock)
+	}
+	if isForkIncompatible(c.BridgeBlock, newcfg.BridgeBlock, head) {
+		return newCompatError("Bridge fork block", c.BridgeBlock, newcfg.BridgeBlock)
+	}
+	if isForkIncompatible(c.StrictEIP155Block, newcfg.StrictEIP155Block, head) {
+		return newCompatError("Strict EIP155 fork block", c.StrictEIP155Block, newcfg.StrictEIP155Block)
+	}
+	if isForkIncompatible(c.RangeQueryBlock, newcfg.RangeQueryBlock, head) {
+		return newCompatError("Range query fork block", c.RangeQueryBlock, newcfg.RangeQueryBlock)
+	}
+	if isForkIncompatible(c.EnodeAnnounceBlock, newcfg.EnodeAnnounceBlock, head) {
+		return newCompatError("Enode announce fork block", c.EnodeAnnounceBlock, newcfg.EnodeAnnounceBlock)
+	}
+	if isForkIncompatible(c.AbiVersioningBlock, newcfg.AbiVersioningBlock, head) {
+		return newCompatError("ABI versioning fork block", c.AbiVersioningBlock, newcfg.AbiVersioningBlock)
+	}
+	if isForkIncompatible(c.PrecompileLogsBlock, newcfg.PrecompileLogsBlock, head) {
+		return newCompatError("Precompile logs fork block", c.PrecompileLogsBlock, newcfg.PrecompileLogsBlock)
+	}
+	if isForkIncompatible(c.RowSizeGasBlock, newcfg.RowSizeGasBlock, head) {
+		return newCompatError("Row size gas fork block", c.RowSizeGasBlock, newcfg.RowSizeGasBlock)
+	}
+	if isForkIncompatible(c.IteratorScopeBlock, newcfg.IteratorScopeBlock, head) {
+		return newCompatError("Iterator scope fork block", c.IteratorScopeBlock, newcfg.IteratorScopeBlock)
+	}
+	if isForkIncompatible(c.RewardShareBlock, newcfg.RewardShareBlock, head) {
+		return newCompatError("Reward share fork block", c.RewardShareBlock, newcfg.RewardShareBlock)
+	}
+	if isForkIncompatible(c.SystemSchemaBlock, newcfg.SystemSchemaBlock, head) {
+		return newCompatError("System schema fork block", c.SystemSchemaBlock, newcfg.SystemSchemaBlock)
+	}
+	if isForkIncompatible(c.StandbyBlock, newcfg.StandbyBlock, head) {
+		return newCompatError("Standby fork block", c.StandbyBlock, newcfg.StandbyBlock)
+	}
+	// The amounts already stored are in the units of the stored precision, so
+	// changing it means processing the chain anew
+	if c.ValueDecimals() != newcfg.ValueDecimals() {
+		return newCompatError("value decimal points", common.Big0, common.Big0)
+	}
 	return nil
 }
 
@@ -400,6 +693,21 @@ type Rules struct {
 	ChainID                        *big.Int
 	IsEIP150, IsEIP155, IsEIP158   bool
 	IsConstantinople, IsPetersburg bool
+	IsEWASM, IsTableTombstone      bool
+	IsTableAlias, IsStrictInput    bool
+	IsPartialUnvote, IsTableUpdate bool
+	IsCallPolicy, IsSavepoint      bool
+	IsClaimMerge, IsLockedTransfer bool
+	IsSubscription, IsAllowance    bool
+	IsWrappedToken, IsBridge       bool
+	IsStrictEIP155, IsRangeQuery   bool
+	IsEnodeAnnounce                bool
+	IsAbiVersioning                bool
+	IsPrecompileLogs               bool
+	IsRowSizeGas, IsIteratorScope  bool
+	IsRewardShare                  bool
+	IsSystemSchema                 bool
+	IsStandby                      bool
 }
 
 // Rules ensures c's ChainID is not nil.
@@ -415,5 +723,29 @@ func (c *ChainConfig) Rules(num *big.Int) Rules {
 		IsEIP158:         c.IsEIP158(num),
 		IsConstantinople: c.IsConstantinople(num),
 		IsPetersburg:     c.IsPetersburg(num),
+		IsEWASM:          c.IsEWASM(num),
+		IsTableTombstone: c.IsTableTombstone(num),
+		IsTableAlias:     c.IsTableAlias(num),
+		IsStrictInput:    c.IsStrictInput(num),
+		IsPartialUnvote:  c.IsPartialUnvote(num),
+		IsTableUpdate:    c.IsTableUpdate(num),
+		IsCallPolicy:     c.IsCallPolicy(num),
+		IsSavepoint:      c.IsSavepoint(num),
+		IsClaimMerge:     c.IsClaimMerge(num),
+		IsLockedTransfer: c.IsLockedTransfer(num),
+		IsSubscription:   c.IsSubscription(num),
+		IsAllowance:      c.IsAllowance(num),
+		IsWrappedToken:   c.IsWrappedToken(num),
+		IsBridge:         c.IsBridge(num),
+		IsStrictEIP155:   c.IsStrictEIP155(num),
+		IsRangeQuery:     c.IsRangeQuery(num),
+		IsEnodeAnnounce:  c.IsEnodeAnnounce(num),
+		IsAbiVersioning:  c.IsAbiVersioning(num),
+		IsPrecompileLogs: c.IsPrecompileLogs(num),
+		IsRowSizeGas:     c.IsRowSizeGas(num),
+		IsIteratorScope:  c.IsIteratorScope(num),
+		IsRewardShare:    c.IsRewardShare(num),
+		IsSystemSchema:   c.IsSystemSchema(num),
+		IsStandby:        c.IsStandby(num),
 	}
 }
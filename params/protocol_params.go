@@ -111,7 +111,10 @@ const (
 
 	MaxCodeSize = 24576 // Maximum bytecode to permit for a contract
 
-	EbakusDBMemoryUsageGas uint64 = 650 // Cost per EbakusDb byte used
+	EbakusDBMemoryUsageGas  uint64 = 650 // Cost per EbakusDb byte used
+	EbakusDBMemoryRefundGas uint64 = 325 // Refund per EbakusDb byte freed (table tombstone fork)
+
+	EWASMGasMultiplier uint64 = 1 // Multiplier applied to the gas metered by the ewasm engine
 
 	// Precompiled contract gas prices
 	SystemContractBaseGas        uint64 = 500 // Base price for not fine grained System contract commands
@@ -121,17 +124,42 @@ const (
 	SystemContractClaimGas       uint64 = 300
 	SystemContractVoteGas        uint64 = 100 // Multiplied by the number of the voted addresses
 	SystemContractUnvoteGas      uint64 = 500
+	SystemContractUnvoteAddrGas  uint64 = 100 // Multiplied by the number of the unvoted addresses
 	SystemContractElectEnableGas uint64 = 100
 	SystemContractStoreAbiGas    uint64 = 500
 	SystemContractGetAbiGas      uint64 = 100
+	SystemContractTablesAliasGas uint64 = 500
+	SystemContractLockedGas      uint64 = 800
+	SystemContractSubscribeGas   uint64 = 500
+	SystemContractSettleGas      uint64 = 300
+	SystemContractApproveGas     uint64 = 300
+	SystemContractAllowanceGas   uint64 = 100
+	SystemContractTransferGas    uint64 = 300
+	SystemContractBridgeGas      uint64 = 800
+	SystemContractAnnounceGas    uint64 = 500
 	DBContractBaseGas            uint64 = 500 // Base price for not fine grained DB contract commands
 	DBContractCreateTableGas     uint64 = 500
 	DBContractInsertObjGas       uint64 = 500
 	DBContractDeleteObjGas       uint64 = 500
+	DBContractUpdateObjGas       uint64 = 500
+	DBContractUpdateFieldGas     uint64 = 100 // Multiplied by the number of the updated fields
 	DBContractGetGas             uint64 = 500 // Multiplied by the number of the voted addresses
 	DBContractSelectGas          uint64 = 500
 	DBContractNextGas            uint64 = 500
 	DBContractPrevGas            uint64 = 500
+	DBContractCollectGarbageGas  uint64 = 500
+	DBContractSavepointGas       uint64 = 500
+	DBContractRollbackGas        uint64 = 500
+	DBContractCloseIteratorGas   uint64 = 100
+	DBContractGarbageRowGas      uint64 = 200   // Multiplied by the number of the collected rows
+	DBContractScanRowGas         uint64 = 100   // Multiplied by the number of the rows skipped by a select offset
+	DBContractIndexGas           uint64 = 500   // Multiplied by the number of the indexes of a created table (row size gas fork)
+	DBContractWriteByteGas       uint64 = 8     // Per byte of the rows inserted or updated (row size gas fork)
+	DBContractReadByteGas        uint64 = 2     // Per byte of the rows returned by get and next (row size gas fork)
+	DBContractRowGas             uint64 = 100   // Per row returned by next (row size gas fork)
+	WrappedTokenBaseGas          uint64 = 200   // Base price for the wrapped token metadata commands
+	WrappedTokenReadGas          uint64 = 800   // Price for reading a wrapped token balance or allowance
+	WrappedTokenWriteGas         uint64 = 20000 // Price per wrapped token balance or allowance written
 
 	EcrecoverGas        uint64 = 3000 // Elliptic curve sender recovery gas price
 	Sha256BaseGas       uint64 = 60   // Base price for a SHA256 operation
@@ -152,6 +180,11 @@ const (
 	Bn256PairingPerPointGasIstanbul  uint64 = 34000  // Per-point price for an elliptic curve pairing check
 )
 
+const (
+	DefaultValueDecimalPoints uint64 = 4  // Decimal points of the system contract amounts of the chains not configuring them
+	MaxValueDecimalPoints     uint64 = 18 // Decimal points of wei, the finest precision of the system contract amounts
+)
+
 var (
 	DifficultyBoundDivisor = big.NewInt(2048)   // The bound divisor of the difficulty, used in the update calculations.
 	GenesisDifficulty      = big.NewInt(131072) // Difficulty of the Genesis block.
@@ -327,8 +327,14 @@ func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage
 	if err != nil {
 		return msg.errorResponse(&invalidParamsError{err.Error()})
 	}
+	start := time.Now()
+	answer := h.runMethod(cp.ctx, msg, callb, args)
 
-	return h.runMethod(cp.ctx, msg, callb, args)
+	// Collect the statistics of the method calls, the subscriptions aside
+	if callb != h.unsubscribeCb {
+		h.recordCall(msg, answer, start)
+	}
+	return answer
 }
 
 // handleSubscribe processes *_subscribe method calls.
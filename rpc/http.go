@@ -242,6 +242,12 @@ func NewHTTPServer(cors []string, vhosts []string, timeouts HTTPTimeouts, srv ht
 
 // ServeHTTP serves JSON-RPC requests over HTTP.
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Serve the OpenRPC document describing the API at its well-known path
+	if r.Method == http.MethodGet && r.URL.Path == OpenRPCPath {
+		w.Header().Set("content-type", contentType)
+		json.NewEncoder(w).Encode(s.OpenRPC())
+		return
+	}
 	// Permit dumb empty requests for remote health-checks (AWS)
 	if r.Method == http.MethodGet && r.ContentLength == 0 && r.URL.RawQuery == "" {
 		return
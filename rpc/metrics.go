@@ -0,0 +1,97 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ebakus/go-ebakus/metrics"
+)
+
+var (
+	rpcRequestMeter = metrics.NewRegisteredMeter("rpc/requests", nil)
+	rpcSuccessMeter = metrics.NewRegisteredMeter("rpc/success", nil)
+	rpcFailureMeter = metrics.NewRegisteredMeter("rpc/failure", nil)
+	rpcServingTimer = metrics.NewRegisteredTimer("rpc/duration/all", nil)
+	rpcSlowMeter    = metrics.NewRegisteredMeter("rpc/slow", nil)
+)
+
+// maxSlowQueryParams is the length the parameters of the slow queries logged are
+// truncated to.
+const maxSlowQueryParams = 512
+
+// slowQueryThreshold is the duration of the calls to log, in nanoseconds.
+var slowQueryThreshold int64
+
+// SetSlowQueryThreshold sets the duration past which the calls served are logged
+// along with their parameters, zero disabling the log.
+func SetSlowQueryThreshold(threshold time.Duration) {
+	atomic.StoreInt64(&slowQueryThreshold, int64(threshold))
+}
+
+// newRPCServingTimer returns the timer of the calls of a method, the failed and
+// the successful ones being timed separately, so the error rate of a method is
+// the rate of the former out of both.
+func newRPCServingTimer(method string, success bool) metrics.Timer {
+	outcome := "success"
+	if !success {
+		outcome = "failure"
+	}
+	return metrics.GetOrRegisterTimer(fmt.Sprintf("rpc/duration/%s/%s", method, outcome), nil)
+}
+
+// recordCall updates the metrics of a served call, logging it if slow.
+func (h *handler) recordCall(msg *jsonrpcMessage, answer *jsonrpcMessage, start time.Time) {
+	elapsed := time.Since(start)
+	success := answer.Error == nil
+
+	rpcRequestMeter.Mark(1)
+	if success {
+		rpcSuccessMeter.Mark(1)
+	} else {
+		rpcFailureMeter.Mark(1)
+	}
+	rpcServingTimer.Update(elapsed)
+	newRPCServingTimer(msg.Method, success).Update(elapsed)
+
+	if threshold := time.Duration(atomic.LoadInt64(&slowQueryThreshold)); threshold > 0 && elapsed > threshold {
+		rpcSlowMeter.Mark(1)
+		h.log.Warn("Slow RPC call", "method", msg.Method, "params", sanitizeParams(msg.Method, msg.Params), "elapsed", elapsed, "failed", !success)
+	}
+}
+
+// secretMethods are the fragments of the names of the methods taking passwords
+// or keys, whose parameters are never logged.
+var secretMethods = []string{"personal_", "unlock", "importRawKey", "sign", "newAccount", "Passphrase", "Password"}
+
+// sanitizeParams returns the parameters of a call fit for logging, redacting the
+// ones of the methods handling secrets and truncating the long ones.
+func sanitizeParams(method string, params json.RawMessage) string {
+	for _, fragment := range secretMethods {
+		if strings.Contains(method, fragment) {
+			return "<redacted>"
+		}
+	}
+	if len(params) > maxSlowQueryParams {
+		return fmt.Sprintf("%s...(%d bytes)", params[:maxSlowQueryParams], len(params))
+	}
+	return string(params)
+}
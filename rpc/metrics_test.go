@@ -14,38 +14,30 @@
 // You should have received a copy of the GNU Lesser General Public License
 // along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
 
-package core
+package rpc
 
 import (
-	"math/big"
+	"encoding/json"
+	"strings"
 	"testing"
 )
 
-func TestParseInteger(t *testing.T) {
-	for i, tt := range []struct {
-		t   string
-		v   interface{}
-		exp *big.Int
+func TestSanitizeParams(t *testing.T) {
+	long := json.RawMessage(`["` + strings.Repeat("a", 2*maxSlowQueryParams) + `"]`)
+
+	tests := []struct {
+		method string
+		params json.RawMessage
+		want   string
 	}{
-		{"uint32", "-123", nil},
-		{"int32", "-123", big.NewInt(-123)},
-		{"uint32", "0xff", big.NewInt(0xff)},
-		{"int8", "0xffff", nil},
-	} {
-		res, err := parseInteger(tt.t, tt.v)
-		if tt.exp == nil && res == nil {
-			continue
-		}
-		if tt.exp == nil && res != nil {
-			t.Errorf("test %d, got %v, expected nil", i, res)
-			continue
-		}
-		if tt.exp != nil && res == nil {
-			t.Errorf("test %d, got '%v', expected %v", i, err, tt.exp)
-			continue
-		}
-		if tt.exp.Cmp(res) != 0 {
-			t.Errorf("test %d, got %v expected %v", i, res, tt.exp)
+		{"eth_getBalance", json.RawMessage(`["0x01","latest"]`), `["0x01","latest"]`},
+		{"personal_unlockAccount", json.RawMessage(`["0x01","secret"]`), "<redacted>"},
+		{"eth_signTransaction", json.RawMessage(`[{"from":"0x01"}]`), "<redacted>"},
+		{"eth_call", long, string(long[:maxSlowQueryParams]) + "...(1028 bytes)"},
+	}
+	for i, tt := range tests {
+		if have := sanitizeParams(tt.method, tt.params); have != tt.want {
+			t.Errorf("test %d: params mismatch: have %s, want %s", i, have, tt.want)
 		}
 	}
 }
@@ -0,0 +1,292 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// OpenRPCVersion is the version of the OpenRPC specification the generated
+	// documents follow.
+	OpenRPCVersion = "1.2.6"
+
+	// OpenRPCPath is the well-known HTTP path the OpenRPC document is served at.
+	OpenRPCPath = "/openrpc.json"
+)
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	blockNumberType   = reflect.TypeOf(BlockNumber(0))
+	blockNrOrHashType = reflect.TypeOf(BlockNumberOrHash{})
+
+	openRPCTypes     []reflect.Type // Types always included in the document components
+	openRPCTypesLock sync.Mutex
+)
+
+// RegisterOpenRPCType adds the schema of the type of v to the components of the
+// generated OpenRPC documents. It's meant for types methods return marshalled
+// into generic maps, which can't be discovered through their signatures.
+func RegisterOpenRPCType(v interface{}) {
+	openRPCTypesLock.Lock()
+	defer openRPCTypesLock.Unlock()
+
+	openRPCTypes = append(openRPCTypes, reflect.TypeOf(v))
+}
+
+// OpenRPCDocument is a machine-readable description of the methods served by
+// an RPC server, following the OpenRPC specification (https://open-rpc.org).
+type OpenRPCDocument struct {
+	OpenRPC    string            `json:"openrpc"`
+	Info       OpenRPCInfo       `json:"info"`
+	Methods    []*OpenRPCMethod  `json:"methods"`
+	Components OpenRPCComponents `json:"components"`
+}
+
+// OpenRPCInfo is the metadata of an OpenRPC document.
+type OpenRPCInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenRPCMethod describes a single RPC method.
+type OpenRPCMethod struct {
+	Name   string               `json:"name"`
+	Params []*OpenRPCDescriptor `json:"params"`
+	Result *OpenRPCDescriptor   `json:"result"`
+	Tags   []map[string]string  `json:"tags,omitempty"`
+}
+
+// OpenRPCDescriptor describes a parameter or the result of a method.
+type OpenRPCDescriptor struct {
+	Name     string     `json:"name"`
+	Required bool       `json:"required"`
+	Schema   JSONSchema `json:"schema"`
+}
+
+// OpenRPCComponents holds the schemas of the named types the methods refer to.
+type OpenRPCComponents struct {
+	Schemas map[string]JSONSchema `json:"schemas"`
+}
+
+// JSONSchema is a JSON schema object.
+type JSONSchema map[string]interface{}
+
+// Discover returns the OpenRPC document describing all the methods served.
+func (s *RPCService) Discover() *OpenRPCDocument {
+	return s.server.OpenRPC()
+}
+
+// OpenRPC generates the OpenRPC document of all the registered services. The
+// parameters are described by their Go types, as the names aren't available
+// through reflection; trailing pointer parameters are optional.
+func (s *Server) OpenRPC() *OpenRPCDocument {
+	s.services.mu.Lock()
+	defer s.services.mu.Unlock()
+
+	gen := &schemaGenerator{schemas: make(map[string]JSONSchema)}
+	doc := &OpenRPCDocument{
+		OpenRPC: OpenRPCVersion,
+		Info:    OpenRPCInfo{Title: "Ebakus JSON-RPC API", Version: "1.0"},
+	}
+	for namespace, svc := range s.services.services {
+		for name, cb := range svc.callbacks {
+			doc.Methods = append(doc.Methods, gen.method(namespace+serviceMethodSeparator+name, namespace, cb))
+		}
+	}
+	sort.Slice(doc.Methods, func(i, j int) bool { return doc.Methods[i].Name < doc.Methods[j].Name })
+
+	openRPCTypesLock.Lock()
+	for _, typ := range openRPCTypes {
+		gen.schema(typ)
+	}
+	openRPCTypesLock.Unlock()
+
+	doc.Components.Schemas = gen.schemas
+
+	return doc
+}
+
+// schemaGenerator derives JSON schemas from Go types, collecting the named
+// struct types as reusable components.
+type schemaGenerator struct {
+	schemas map[string]JSONSchema
+}
+
+func (g *schemaGenerator) method(name, namespace string, cb *callback) *OpenRPCMethod {
+	method := &OpenRPCMethod{
+		Name:   name,
+		Params: make([]*OpenRPCDescriptor, len(cb.argTypes)),
+		Tags:   []map[string]string{{"name": namespace}},
+	}
+	optional := len(cb.argTypes)
+	for optional > 0 && cb.argTypes[optional-1].Kind() == reflect.Ptr {
+		optional--
+	}
+	for i, typ := range cb.argTypes {
+		method.Params[i] = &OpenRPCDescriptor{
+			Name:     fmt.Sprintf("arg%d", i),
+			Required: i < optional,
+			Schema:   g.schema(typ),
+		}
+	}
+	result := &OpenRPCDescriptor{Name: "result", Schema: JSONSchema{"type": "null"}}
+
+	fntype := cb.fn.Type()
+	for i := 0; i < fntype.NumOut(); i++ {
+		if i != cb.errPos {
+			result.Schema = g.schema(fntype.Out(i))
+		}
+	}
+	method.Result = result
+
+	return method
+}
+
+// schema returns the JSON schema of a Go type, following the encoding/json rules.
+func (g *schemaGenerator) schema(typ reflect.Type) JSONSchema {
+	switch typ {
+	case blockNumberType:
+		return JSONSchema{
+			"description": "block number as hex quantity or tag",
+			"oneOf": []JSONSchema{
+				{"type": "string", "pattern": "^0x[0-9a-fA-F]+$"},
+				{"type": "string", "enum": []string{"earliest", "latest", "pending"}},
+			},
+		}
+	case blockNrOrHashType:
+		return JSONSchema{
+			"description": "block number, tag or hash",
+			"type":        "string",
+		}
+	}
+	if typ.Kind() == reflect.Ptr {
+		return g.schema(typ.Elem())
+	}
+	if typ.Implements(textMarshalerType) || reflect.PtrTo(typ).Implements(textMarshalerType) {
+		return g.textSchema(typ)
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return JSONSchema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return JSONSchema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return JSONSchema{"type": "number"}
+	case reflect.String:
+		return JSONSchema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return JSONSchema{"type": "string", "contentEncoding": "base64"}
+		}
+		return JSONSchema{"type": "array", "items": g.schema(typ.Elem())}
+	case reflect.Map:
+		return JSONSchema{"type": "object", "additionalProperties": g.schema(typ.Elem())}
+	case reflect.Struct:
+		return g.structSchema(typ)
+	}
+	// Interfaces, channels and the like can't be described statically
+	return JSONSchema{}
+}
+
+// textSchema describes a type marshalling to a string, e.g. hashes, addresses
+// and hex encoded quantities.
+func (g *schemaGenerator) textSchema(typ reflect.Type) JSONSchema {
+	schema := JSONSchema{"type": "string", "title": typeName(typ)}
+	switch {
+	case strings.HasSuffix(typ.Name(), "Hash"):
+		schema["pattern"] = "^0x[0-9a-fA-F]{64}$"
+	case typ.Name() == "Address":
+		schema["pattern"] = "^0x[0-9a-fA-F]{40}$"
+	case strings.HasPrefix(typ.Name(), "Uint"), typ.Name() == "Big", typ.Name() == "Bytes":
+		schema["pattern"] = "^0x[0-9a-fA-F]*$"
+	}
+	return schema
+}
+
+// structSchema registers a struct type as a component, returning a reference to
+// it. Anonymous structs are inlined.
+func (g *schemaGenerator) structSchema(typ reflect.Type) JSONSchema {
+	name := typeName(typ)
+	if name != "" {
+		if _, ok := g.schemas[name]; !ok {
+			g.schemas[name] = JSONSchema{} // placeholder for recursive types
+			g.schemas[name] = g.objectSchema(typ)
+		}
+		return JSONSchema{"$ref": "#/components/schemas/" + name}
+	}
+	return g.objectSchema(typ)
+}
+
+func (g *schemaGenerator) objectSchema(typ reflect.Type) JSONSchema {
+	properties := make(map[string]JSONSchema)
+	g.fields(typ, properties)
+
+	return JSONSchema{"type": "object", "properties": properties}
+}
+
+// fields collects the JSON properties of a struct, flattening embedded structs
+// the same way encoding/json does.
+func (g *schemaGenerator) fields(typ reflect.Type, properties map[string]JSONSchema) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.fields(embedded, properties)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = g.schema(field.Type)
+	}
+}
+
+// typeName returns the package qualified name of a type, e.g. types.Header.
+func typeName(typ reflect.Type) string {
+	if typ.Name() == "" {
+		return ""
+	}
+	pkg := typ.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	if pkg == "" {
+		return typ.Name()
+	}
+	return pkg + "." + typ.Name()
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenRPC(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+
+	doc := server.OpenRPC()
+
+	methods := make(map[string]*OpenRPCMethod)
+	for _, method := range doc.Methods {
+		methods[method.Name] = method
+	}
+	for _, name := range []string{"rpc_modules", "rpc_discover", "test_echo", "test_rets"} {
+		if methods[name] == nil {
+			t.Errorf("method %s missing", name)
+		}
+	}
+	echo := methods["test_echo"]
+	if echo == nil {
+		t.FailNow()
+	}
+	if len(echo.Params) != 3 {
+		t.Fatalf("echo params mismatch: have %d, want 3", len(echo.Params))
+	}
+	if !echo.Params[0].Required || !echo.Params[1].Required || echo.Params[2].Required {
+		t.Errorf("echo params required mismatch: have %v %v %v, want true true false", echo.Params[0].Required, echo.Params[1].Required, echo.Params[2].Required)
+	}
+	if ref := echo.Params[2].Schema["$ref"]; ref != "#/components/schemas/rpc.Args" {
+		t.Errorf("echo args reference mismatch: have %v", ref)
+	}
+	if ref := echo.Result.Schema["$ref"]; ref != "#/components/schemas/rpc.Result" {
+		t.Errorf("echo result reference mismatch: have %v", ref)
+	}
+	if _, ok := doc.Components.Schemas["rpc.Result"]["properties"].(map[string]JSONSchema)["String"]; !ok {
+		t.Errorf("echo result schema misses field: %v", doc.Components.Schemas["rpc.Result"])
+	}
+}
+
+func TestOpenRPCHTTP(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+
+	httpsrv := httptest.NewServer(server)
+	defer httpsrv.Close()
+
+	resp, err := http.Get(httpsrv.URL + OpenRPCPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var doc OpenRPCDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenRPC != OpenRPCVersion || len(doc.Methods) == 0 {
+		t.Fatalf("unexpected document: %+v", doc)
+	}
+}
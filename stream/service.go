@@ -0,0 +1,149 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package stream implements a gRPC streaming server of the chain, giving
+// indexers the blocks, transactions, receipts and stake calls without the cost
+// of the JSON encoding of the websocket subscriptions.
+package stream
+
+//go:generate protoc --go_out=plugins=grpc:. stream.proto
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/event"
+	"github.com/ebakus/go-ebakus/log"
+	"github.com/ebakus/go-ebakus/p2p"
+	"github.com/ebakus/go-ebakus/params"
+	"github.com/ebakus/go-ebakus/rpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+const (
+	// maxStreams is the number of clients streamed to at once, the ones beyond
+	// being refused.
+	maxStreams = 32
+
+	// maxRequestSize is the size limit of the requests of the clients.
+	maxRequestSize = 1024
+
+	// handshakeTimeout is the time allowed to a client to set up its connection.
+	handshakeTimeout = 10 * time.Second
+
+	// keepaliveInterval is the time a connection may stay silent before the
+	// server pings it, dropping it if the ping isn't answered in time.
+	keepaliveInterval = time.Minute
+	keepaliveTimeout  = 20 * time.Second
+)
+
+// Backend is the part of the API backends of the full and light clients the
+// streams are read from.
+type Backend interface {
+	HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error)
+	BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error)
+	GetBlockAuthor(header *types.Header) (common.Address, error)
+	GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error)
+	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+	ChainConfig() *params.ChainConfig
+}
+
+// Service encapsulates a chain streaming service.
+type Service struct {
+	endpoint string  // The host:port endpoint for this service.
+	backend  Backend // The backend the streams are read from.
+
+	server *grpc.Server // The gRPC server streaming to the clients.
+
+	lock    sync.Mutex
+	streams int // Number of clients being streamed to
+}
+
+// New constructs a new chain streaming service instance.
+func New(backend Backend, endpoint string) (*Service, error) {
+	return &Service{
+		endpoint: endpoint,
+		backend:  backend,
+	}, nil
+}
+
+// Protocols returns the list of protocols exported by this service.
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs returns the list of APIs exported by this service.
+func (s *Service) APIs() []rpc.API { return nil }
+
+// Start is called after all services have been constructed and the networking
+// layer was also initialized to spawn any goroutines required by the service.
+func (s *Service) Start(server *p2p.Server) error {
+	listener, err := net.Listen("tcp", s.endpoint)
+	if err != nil {
+		return err
+	}
+	s.server = grpc.NewServer(
+		grpc.MaxRecvMsgSize(maxRequestSize),
+		grpc.ConnectionTimeout(handshakeTimeout),
+		grpc.KeepaliveParams(keepalive.ServerParameters{Time: keepaliveInterval, Timeout: keepaliveTimeout}),
+	)
+	RegisterChainStreamServer(s.server, s)
+
+	go s.server.Serve(listener)
+
+	log.Info("Stream endpoint opened", "url", "grpc://"+s.endpoint)
+	return nil
+}
+
+// Subscribe implements ChainStreamServer, streaming the chain to a client until
+// either side ends the call.
+func (s *Service) Subscribe(req *Request, stream ChainStream_SubscribeServer) error {
+	s.lock.Lock()
+	if s.streams >= maxStreams {
+		s.lock.Unlock()
+		return errTooManyStreams
+	}
+	s.streams++
+	s.lock.Unlock()
+
+	defer func() {
+		s.lock.Lock()
+		s.streams--
+		s.lock.Unlock()
+	}()
+	err := newStreamer(s.backend, stream).run(stream.Context(), req)
+	if err != nil && stream.Context().Err() == nil {
+		log.Debug("Stream failed", "err", err)
+	}
+	return err
+}
+
+// Stop terminates all goroutines belonging to the service, blocking until they
+// are all terminated.
+func (s *Service) Stop() error {
+	if s.server == nil {
+		return nil
+	}
+	s.server.Stop()
+	s.server = nil
+
+	log.Info("Stream endpoint closed", "url", "grpc://"+s.endpoint)
+	return nil
+}
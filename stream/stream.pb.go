@@ -0,0 +1,879 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: stream.proto
+
+package stream
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type Request struct {
+	FromBlock            uint64   `protobuf:"varint,1,opt,name=from_block,json=fromBlock,proto3" json:"from_block,omitempty"`
+	Latest               bool     `protobuf:"varint,2,opt,name=latest,proto3" json:"latest,omitempty"`
+	Transactions         bool     `protobuf:"varint,3,opt,name=transactions,proto3" json:"transactions,omitempty"`
+	Receipts             bool     `protobuf:"varint,4,opt,name=receipts,proto3" json:"receipts,omitempty"`
+	StakeEvents          bool     `protobuf:"varint,5,opt,name=stake_events,json=stakeEvents,proto3" json:"stake_events,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Request) Reset()         { *m = Request{} }
+func (m *Request) String() string { return proto.CompactTextString(m) }
+func (*Request) ProtoMessage()    {}
+func (*Request) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bb17ef3f514bfe54, []int{0}
+}
+
+func (m *Request) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Request.Unmarshal(m, b)
+}
+func (m *Request) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Request.Marshal(b, m, deterministic)
+}
+func (m *Request) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Request.Merge(m, src)
+}
+func (m *Request) XXX_Size() int {
+	return xxx_messageInfo_Request.Size(m)
+}
+func (m *Request) XXX_DiscardUnknown() {
+	xxx_messageInfo_Request.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Request proto.InternalMessageInfo
+
+func (m *Request) GetFromBlock() uint64 {
+	if m != nil {
+		return m.FromBlock
+	}
+	return 0
+}
+
+func (m *Request) GetLatest() bool {
+	if m != nil {
+		return m.Latest
+	}
+	return false
+}
+
+func (m *Request) GetTransactions() bool {
+	if m != nil {
+		return m.Transactions
+	}
+	return false
+}
+
+func (m *Request) GetReceipts() bool {
+	if m != nil {
+		return m.Receipts
+	}
+	return false
+}
+
+func (m *Request) GetStakeEvents() bool {
+	if m != nil {
+		return m.StakeEvents
+	}
+	return false
+}
+
+type Envelope struct {
+	// Types that are valid to be assigned to Message:
+	//	*Envelope_Block
+	//	*Envelope_Transaction
+	//	*Envelope_Receipt
+	//	*Envelope_StakeEvent
+	//	*Envelope_Rollback
+	Message              isEnvelope_Message `protobuf_oneof:"message"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *Envelope) Reset()         { *m = Envelope{} }
+func (m *Envelope) String() string { return proto.CompactTextString(m) }
+func (*Envelope) ProtoMessage()    {}
+func (*Envelope) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bb17ef3f514bfe54, []int{1}
+}
+
+func (m *Envelope) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Envelope.Unmarshal(m, b)
+}
+func (m *Envelope) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Envelope.Marshal(b, m, deterministic)
+}
+func (m *Envelope) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Envelope.Merge(m, src)
+}
+func (m *Envelope) XXX_Size() int {
+	return xxx_messageInfo_Envelope.Size(m)
+}
+func (m *Envelope) XXX_DiscardUnknown() {
+	xxx_messageInfo_Envelope.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Envelope proto.InternalMessageInfo
+
+type isEnvelope_Message interface {
+	isEnvelope_Message()
+}
+
+type Envelope_Block struct {
+	Block *Block `protobuf:"bytes,1,opt,name=block,proto3,oneof"`
+}
+
+type Envelope_Transaction struct {
+	Transaction *Transaction `protobuf:"bytes,2,opt,name=transaction,proto3,oneof"`
+}
+
+type Envelope_Receipt struct {
+	Receipt *Receipt `protobuf:"bytes,3,opt,name=receipt,proto3,oneof"`
+}
+
+type Envelope_StakeEvent struct {
+	StakeEvent *StakeEvent `protobuf:"bytes,4,opt,name=stake_event,json=stakeEvent,proto3,oneof"`
+}
+
+type Envelope_Rollback struct {
+	Rollback *Rollback `protobuf:"bytes,5,opt,name=rollback,proto3,oneof"`
+}
+
+func (*Envelope_Block) isEnvelope_Message() {}
+
+func (*Envelope_Transaction) isEnvelope_Message() {}
+
+func (*Envelope_Receipt) isEnvelope_Message() {}
+
+func (*Envelope_StakeEvent) isEnvelope_Message() {}
+
+func (*Envelope_Rollback) isEnvelope_Message() {}
+
+func (m *Envelope) GetMessage() isEnvelope_Message {
+	if m != nil {
+		return m.Message
+	}
+	return nil
+}
+
+func (m *Envelope) GetBlock() *Block {
+	if x, ok := m.GetMessage().(*Envelope_Block); ok {
+		return x.Block
+	}
+	return nil
+}
+
+func (m *Envelope) GetTransaction() *Transaction {
+	if x, ok := m.GetMessage().(*Envelope_Transaction); ok {
+		return x.Transaction
+	}
+	return nil
+}
+
+func (m *Envelope) GetReceipt() *Receipt {
+	if x, ok := m.GetMessage().(*Envelope_Receipt); ok {
+		return x.Receipt
+	}
+	return nil
+}
+
+func (m *Envelope) GetStakeEvent() *StakeEvent {
+	if x, ok := m.GetMessage().(*Envelope_StakeEvent); ok {
+		return x.StakeEvent
+	}
+	return nil
+}
+
+func (m *Envelope) GetRollback() *Rollback {
+	if x, ok := m.GetMessage().(*Envelope_Rollback); ok {
+		return x.Rollback
+	}
+	return nil
+}
+
+// XXX_OneofWrappers is for the internal use of the proto package.
+func (*Envelope) XXX_OneofWrappers() []interface{} {
+	return []interface{}{
+		(*Envelope_Block)(nil),
+		(*Envelope_Transaction)(nil),
+		(*Envelope_Receipt)(nil),
+		(*Envelope_StakeEvent)(nil),
+		(*Envelope_Rollback)(nil),
+	}
+}
+
+type Block struct {
+	Number               uint64   `protobuf:"varint,1,opt,name=number,proto3" json:"number,omitempty"`
+	Hash                 []byte   `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+	ParentHash           []byte   `protobuf:"bytes,3,opt,name=parent_hash,json=parentHash,proto3" json:"parent_hash,omitempty"`
+	Time                 uint64   `protobuf:"varint,4,opt,name=time,proto3" json:"time,omitempty"`
+	Producer             []byte   `protobuf:"bytes,5,opt,name=producer,proto3" json:"producer,omitempty"`
+	GasLimit             uint64   `protobuf:"varint,6,opt,name=gas_limit,json=gasLimit,proto3" json:"gas_limit,omitempty"`
+	GasUsed              uint64   `protobuf:"varint,7,opt,name=gas_used,json=gasUsed,proto3" json:"gas_used,omitempty"`
+	TxHashes             [][]byte `protobuf:"bytes,8,rep,name=tx_hashes,json=txHashes,proto3" json:"tx_hashes,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Block) Reset()         { *m = Block{} }
+func (m *Block) String() string { return proto.CompactTextString(m) }
+func (*Block) ProtoMessage()    {}
+func (*Block) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bb17ef3f514bfe54, []int{2}
+}
+
+func (m *Block) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Block.Unmarshal(m, b)
+}
+func (m *Block) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Block.Marshal(b, m, deterministic)
+}
+func (m *Block) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Block.Merge(m, src)
+}
+func (m *Block) XXX_Size() int {
+	return xxx_messageInfo_Block.Size(m)
+}
+func (m *Block) XXX_DiscardUnknown() {
+	xxx_messageInfo_Block.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Block proto.InternalMessageInfo
+
+func (m *Block) GetNumber() uint64 {
+	if m != nil {
+		return m.Number
+	}
+	return 0
+}
+
+func (m *Block) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
+func (m *Block) GetParentHash() []byte {
+	if m != nil {
+		return m.ParentHash
+	}
+	return nil
+}
+
+func (m *Block) GetTime() uint64 {
+	if m != nil {
+		return m.Time
+	}
+	return 0
+}
+
+func (m *Block) GetProducer() []byte {
+	if m != nil {
+		return m.Producer
+	}
+	return nil
+}
+
+func (m *Block) GetGasLimit() uint64 {
+	if m != nil {
+		return m.GasLimit
+	}
+	return 0
+}
+
+func (m *Block) GetGasUsed() uint64 {
+	if m != nil {
+		return m.GasUsed
+	}
+	return 0
+}
+
+func (m *Block) GetTxHashes() [][]byte {
+	if m != nil {
+		return m.TxHashes
+	}
+	return nil
+}
+
+type Transaction struct {
+	Hash                 []byte   `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	BlockNumber          uint64   `protobuf:"varint,2,opt,name=block_number,json=blockNumber,proto3" json:"block_number,omitempty"`
+	Index                uint32   `protobuf:"varint,3,opt,name=index,proto3" json:"index,omitempty"`
+	From                 []byte   `protobuf:"bytes,4,opt,name=from,proto3" json:"from,omitempty"`
+	To                   []byte   `protobuf:"bytes,5,opt,name=to,proto3" json:"to,omitempty"`
+	Value                []byte   `protobuf:"bytes,6,opt,name=value,proto3" json:"value,omitempty"`
+	Nonce                uint64   `protobuf:"varint,7,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	WorkNonce            uint64   `protobuf:"varint,8,opt,name=work_nonce,json=workNonce,proto3" json:"work_nonce,omitempty"`
+	Gas                  uint64   `protobuf:"varint,9,opt,name=gas,proto3" json:"gas,omitempty"`
+	Input                []byte   `protobuf:"bytes,10,opt,name=input,proto3" json:"input,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Transaction) Reset()         { *m = Transaction{} }
+func (m *Transaction) String() string { return proto.CompactTextString(m) }
+func (*Transaction) ProtoMessage()    {}
+func (*Transaction) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bb17ef3f514bfe54, []int{3}
+}
+
+func (m *Transaction) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Transaction.Unmarshal(m, b)
+}
+func (m *Transaction) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Transaction.Marshal(b, m, deterministic)
+}
+func (m *Transaction) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Transaction.Merge(m, src)
+}
+func (m *Transaction) XXX_Size() int {
+	return xxx_messageInfo_Transaction.Size(m)
+}
+func (m *Transaction) XXX_DiscardUnknown() {
+	xxx_messageInfo_Transaction.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Transaction proto.InternalMessageInfo
+
+func (m *Transaction) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
+func (m *Transaction) GetBlockNumber() uint64 {
+	if m != nil {
+		return m.BlockNumber
+	}
+	return 0
+}
+
+func (m *Transaction) GetIndex() uint32 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+func (m *Transaction) GetFrom() []byte {
+	if m != nil {
+		return m.From
+	}
+	return nil
+}
+
+func (m *Transaction) GetTo() []byte {
+	if m != nil {
+		return m.To
+	}
+	return nil
+}
+
+func (m *Transaction) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *Transaction) GetNonce() uint64 {
+	if m != nil {
+		return m.Nonce
+	}
+	return 0
+}
+
+func (m *Transaction) GetWorkNonce() uint64 {
+	if m != nil {
+		return m.WorkNonce
+	}
+	return 0
+}
+
+func (m *Transaction) GetGas() uint64 {
+	if m != nil {
+		return m.Gas
+	}
+	return 0
+}
+
+func (m *Transaction) GetInput() []byte {
+	if m != nil {
+		return m.Input
+	}
+	return nil
+}
+
+type Receipt struct {
+	TxHash               []byte   `protobuf:"bytes,1,opt,name=tx_hash,json=txHash,proto3" json:"tx_hash,omitempty"`
+	BlockNumber          uint64   `protobuf:"varint,2,opt,name=block_number,json=blockNumber,proto3" json:"block_number,omitempty"`
+	Index                uint32   `protobuf:"varint,3,opt,name=index,proto3" json:"index,omitempty"`
+	Status               uint64   `protobuf:"varint,4,opt,name=status,proto3" json:"status,omitempty"`
+	GasUsed              uint64   `protobuf:"varint,5,opt,name=gas_used,json=gasUsed,proto3" json:"gas_used,omitempty"`
+	CumulativeGasUsed    uint64   `protobuf:"varint,6,opt,name=cumulative_gas_used,json=cumulativeGasUsed,proto3" json:"cumulative_gas_used,omitempty"`
+	ContractAddress      []byte   `protobuf:"bytes,7,opt,name=contract_address,json=contractAddress,proto3" json:"contract_address,omitempty"`
+	Logs                 []*Log   `protobuf:"bytes,8,rep,name=logs,proto3" json:"logs,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Receipt) Reset()         { *m = Receipt{} }
+func (m *Receipt) String() string { return proto.CompactTextString(m) }
+func (*Receipt) ProtoMessage()    {}
+func (*Receipt) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bb17ef3f514bfe54, []int{4}
+}
+
+func (m *Receipt) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Receipt.Unmarshal(m, b)
+}
+func (m *Receipt) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Receipt.Marshal(b, m, deterministic)
+}
+func (m *Receipt) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Receipt.Merge(m, src)
+}
+func (m *Receipt) XXX_Size() int {
+	return xxx_messageInfo_Receipt.Size(m)
+}
+func (m *Receipt) XXX_DiscardUnknown() {
+	xxx_messageInfo_Receipt.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Receipt proto.InternalMessageInfo
+
+func (m *Receipt) GetTxHash() []byte {
+	if m != nil {
+		return m.TxHash
+	}
+	return nil
+}
+
+func (m *Receipt) GetBlockNumber() uint64 {
+	if m != nil {
+		return m.BlockNumber
+	}
+	return 0
+}
+
+func (m *Receipt) GetIndex() uint32 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+func (m *Receipt) GetStatus() uint64 {
+	if m != nil {
+		return m.Status
+	}
+	return 0
+}
+
+func (m *Receipt) GetGasUsed() uint64 {
+	if m != nil {
+		return m.GasUsed
+	}
+	return 0
+}
+
+func (m *Receipt) GetCumulativeGasUsed() uint64 {
+	if m != nil {
+		return m.CumulativeGasUsed
+	}
+	return 0
+}
+
+func (m *Receipt) GetContractAddress() []byte {
+	if m != nil {
+		return m.ContractAddress
+	}
+	return nil
+}
+
+func (m *Receipt) GetLogs() []*Log {
+	if m != nil {
+		return m.Logs
+	}
+	return nil
+}
+
+type Log struct {
+	Address              []byte   `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	Topics               [][]byte `protobuf:"bytes,2,rep,name=topics,proto3" json:"topics,omitempty"`
+	Data                 []byte   `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	Index                uint32   `protobuf:"varint,4,opt,name=index,proto3" json:"index,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Log) Reset()         { *m = Log{} }
+func (m *Log) String() string { return proto.CompactTextString(m) }
+func (*Log) ProtoMessage()    {}
+func (*Log) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bb17ef3f514bfe54, []int{5}
+}
+
+func (m *Log) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Log.Unmarshal(m, b)
+}
+func (m *Log) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Log.Marshal(b, m, deterministic)
+}
+func (m *Log) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Log.Merge(m, src)
+}
+func (m *Log) XXX_Size() int {
+	return xxx_messageInfo_Log.Size(m)
+}
+func (m *Log) XXX_DiscardUnknown() {
+	xxx_messageInfo_Log.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Log proto.InternalMessageInfo
+
+func (m *Log) GetAddress() []byte {
+	if m != nil {
+		return m.Address
+	}
+	return nil
+}
+
+func (m *Log) GetTopics() [][]byte {
+	if m != nil {
+		return m.Topics
+	}
+	return nil
+}
+
+func (m *Log) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *Log) GetIndex() uint32 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+type StakeEvent struct {
+	TxHash               []byte   `protobuf:"bytes,1,opt,name=tx_hash,json=txHash,proto3" json:"tx_hash,omitempty"`
+	BlockNumber          uint64   `protobuf:"varint,2,opt,name=block_number,json=blockNumber,proto3" json:"block_number,omitempty"`
+	From                 []byte   `protobuf:"bytes,3,opt,name=from,proto3" json:"from,omitempty"`
+	Method               string   `protobuf:"bytes,4,opt,name=method,proto3" json:"method,omitempty"`
+	Args                 []byte   `protobuf:"bytes,5,opt,name=args,proto3" json:"args,omitempty"`
+	Failed               bool     `protobuf:"varint,6,opt,name=failed,proto3" json:"failed,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StakeEvent) Reset()         { *m = StakeEvent{} }
+func (m *StakeEvent) String() string { return proto.CompactTextString(m) }
+func (*StakeEvent) ProtoMessage()    {}
+func (*StakeEvent) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bb17ef3f514bfe54, []int{6}
+}
+
+func (m *StakeEvent) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StakeEvent.Unmarshal(m, b)
+}
+func (m *StakeEvent) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StakeEvent.Marshal(b, m, deterministic)
+}
+func (m *StakeEvent) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StakeEvent.Merge(m, src)
+}
+func (m *StakeEvent) XXX_Size() int {
+	return xxx_messageInfo_StakeEvent.Size(m)
+}
+func (m *StakeEvent) XXX_DiscardUnknown() {
+	xxx_messageInfo_StakeEvent.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StakeEvent proto.InternalMessageInfo
+
+func (m *StakeEvent) GetTxHash() []byte {
+	if m != nil {
+		return m.TxHash
+	}
+	return nil
+}
+
+func (m *StakeEvent) GetBlockNumber() uint64 {
+	if m != nil {
+		return m.BlockNumber
+	}
+	return 0
+}
+
+func (m *StakeEvent) GetFrom() []byte {
+	if m != nil {
+		return m.From
+	}
+	return nil
+}
+
+func (m *StakeEvent) GetMethod() string {
+	if m != nil {
+		return m.Method
+	}
+	return ""
+}
+
+func (m *StakeEvent) GetArgs() []byte {
+	if m != nil {
+		return m.Args
+	}
+	return nil
+}
+
+func (m *StakeEvent) GetFailed() bool {
+	if m != nil {
+		return m.Failed
+	}
+	return false
+}
+
+// Rollback tells the client the blocks after number were reorged out. The
+// stream goes on from number+1 on the new canonical chain.
+type Rollback struct {
+	Number               uint64   `protobuf:"varint,1,opt,name=number,proto3" json:"number,omitempty"`
+	Hash                 []byte   `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Rollback) Reset()         { *m = Rollback{} }
+func (m *Rollback) String() string { return proto.CompactTextString(m) }
+func (*Rollback) ProtoMessage()    {}
+func (*Rollback) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bb17ef3f514bfe54, []int{7}
+}
+
+func (m *Rollback) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Rollback.Unmarshal(m, b)
+}
+func (m *Rollback) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Rollback.Marshal(b, m, deterministic)
+}
+func (m *Rollback) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Rollback.Merge(m, src)
+}
+func (m *Rollback) XXX_Size() int {
+	return xxx_messageInfo_Rollback.Size(m)
+}
+func (m *Rollback) XXX_DiscardUnknown() {
+	xxx_messageInfo_Rollback.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Rollback proto.InternalMessageInfo
+
+func (m *Rollback) GetNumber() uint64 {
+	if m != nil {
+		return m.Number
+	}
+	return 0
+}
+
+func (m *Rollback) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*Request)(nil), "stream.Request")
+	proto.RegisterType((*Envelope)(nil), "stream.Envelope")
+	proto.RegisterType((*Block)(nil), "stream.Block")
+	proto.RegisterType((*Transaction)(nil), "stream.Transaction")
+	proto.RegisterType((*Receipt)(nil), "stream.Receipt")
+	proto.RegisterType((*Log)(nil), "stream.Log")
+	proto.RegisterType((*StakeEvent)(nil), "stream.StakeEvent")
+	proto.RegisterType((*Rollback)(nil), "stream.Rollback")
+}
+
+func init() { proto.RegisterFile("stream.proto", fileDescriptor_bb17ef3f514bfe54) }
+
+var fileDescriptor_bb17ef3f514bfe54 = []byte{
+	// 736 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x55, 0xdd, 0x6e, 0xd4, 0x3a,
+	0x10, 0xde, 0xec, 0x6f, 0x76, 0x92, 0x9e, 0xf6, 0xb8, 0x47, 0x3d, 0xa1, 0x08, 0xb5, 0x8d, 0x84,
+	0x54, 0x84, 0xb4, 0xaa, 0x16, 0x01, 0x97, 0x88, 0xa2, 0x8a, 0xbd, 0xa8, 0x7a, 0xe1, 0xc2, 0x75,
+	0xe4, 0x4d, 0xdc, 0x6c, 0xb4, 0x49, 0xbc, 0xc4, 0xce, 0xd2, 0x57, 0x80, 0xa7, 0x40, 0xe2, 0xa9,
+	0x78, 0x0c, 0xde, 0x00, 0x79, 0xec, 0x64, 0xd3, 0x4b, 0xe0, 0xce, 0xdf, 0xf7, 0x8d, 0x9d, 0xf9,
+	0x66, 0xc6, 0x0e, 0xf8, 0x52, 0x55, 0x9c, 0x15, 0xb3, 0x4d, 0x25, 0x94, 0x20, 0x63, 0x83, 0xc2,
+	0xef, 0x0e, 0x4c, 0x28, 0xff, 0x54, 0x73, 0xa9, 0xc8, 0x13, 0x80, 0xbb, 0x4a, 0x14, 0xd1, 0x32,
+	0x17, 0xf1, 0x3a, 0x70, 0x4e, 0x9d, 0xf3, 0x21, 0x9d, 0x6a, 0xe6, 0x52, 0x13, 0xe4, 0x08, 0xc6,
+	0x39, 0x53, 0x5c, 0xaa, 0xa0, 0x7f, 0xea, 0x9c, 0xbb, 0xd4, 0x22, 0x12, 0x82, 0xaf, 0x2a, 0x56,
+	0x4a, 0x16, 0xab, 0x4c, 0x94, 0x32, 0x18, 0xa0, 0xfa, 0x80, 0x23, 0xc7, 0xe0, 0x56, 0x3c, 0xe6,
+	0xd9, 0x46, 0xc9, 0x60, 0x88, 0x7a, 0x8b, 0xc9, 0x99, 0x4e, 0x8d, 0xad, 0x79, 0xc4, 0xb7, 0xbc,
+	0x54, 0x32, 0x18, 0xa1, 0xee, 0x21, 0x77, 0x85, 0x54, 0xf8, 0xa5, 0x0f, 0xee, 0x55, 0xb9, 0xe5,
+	0xb9, 0xd8, 0x70, 0xf2, 0x14, 0x46, 0xbb, 0x0c, 0xbd, 0xf9, 0xde, 0xcc, 0x1a, 0xc3, 0x2c, 0x17,
+	0x3d, 0x6a, 0x54, 0xf2, 0x1a, 0xbc, 0x4e, 0x0a, 0x98, 0xb3, 0x37, 0x3f, 0x6c, 0x82, 0x3f, 0xec,
+	0xa4, 0x45, 0x8f, 0x76, 0x23, 0xc9, 0x73, 0x98, 0xd8, 0xdc, 0xd0, 0x8a, 0x37, 0xdf, 0x6f, 0x36,
+	0x51, 0x43, 0x2f, 0x7a, 0xb4, 0x89, 0x20, 0x2f, 0xc1, 0xeb, 0x24, 0x8f, 0xde, 0xbc, 0x39, 0x69,
+	0x36, 0xdc, 0xb6, 0x1e, 0x16, 0x3d, 0x0a, 0x3b, 0x47, 0x64, 0x06, 0x6e, 0x25, 0xf2, 0x7c, 0xc9,
+	0xe2, 0x35, 0xfa, 0xf5, 0xe6, 0x07, 0xed, 0x47, 0x2c, 0xbf, 0xe8, 0xd1, 0x36, 0xe6, 0x72, 0x0a,
+	0x93, 0x82, 0x4b, 0xc9, 0x52, 0x1e, 0xfe, 0x70, 0x60, 0xd4, 0x36, 0xa4, 0xac, 0x8b, 0x25, 0xaf,
+	0x6c, 0xaf, 0x2c, 0x22, 0x04, 0x86, 0x2b, 0x26, 0x57, 0x68, 0xd9, 0xa7, 0xb8, 0x26, 0x27, 0xe0,
+	0x6d, 0x58, 0xc5, 0x4b, 0x15, 0xa1, 0x34, 0x40, 0x09, 0x0c, 0xb5, 0xd0, 0x01, 0x04, 0x86, 0x2a,
+	0x2b, 0x38, 0x3a, 0x18, 0x52, 0x5c, 0xeb, 0xae, 0x6d, 0x2a, 0x91, 0xd4, 0x31, 0xaf, 0x30, 0x4b,
+	0x9f, 0xb6, 0x98, 0x3c, 0x86, 0x69, 0xca, 0x64, 0x94, 0x67, 0x45, 0xa6, 0x82, 0x31, 0x6e, 0x72,
+	0x53, 0x26, 0xaf, 0x35, 0x26, 0x8f, 0x40, 0xaf, 0xa3, 0x5a, 0xf2, 0x24, 0x98, 0xa0, 0x36, 0x49,
+	0x99, 0xfc, 0x28, 0x79, 0xa2, 0xf7, 0xa9, 0x7b, 0x4c, 0x82, 0xcb, 0xc0, 0x3d, 0x1d, 0xe8, 0x43,
+	0xd5, 0xfd, 0x02, 0x71, 0xf8, 0xd3, 0x01, 0xaf, 0xd3, 0x99, 0xd6, 0x89, 0xd3, 0x71, 0x72, 0x06,
+	0x3e, 0x36, 0x38, 0xb2, 0xde, 0xfb, 0x78, 0xbe, 0x87, 0xdc, 0x8d, 0x29, 0xc0, 0x7f, 0x30, 0xca,
+	0xca, 0x84, 0xdf, 0xa3, 0xcd, 0x3d, 0x6a, 0x80, 0x3e, 0x4c, 0x0f, 0x33, 0x3a, 0xf4, 0x29, 0xae,
+	0xc9, 0x3f, 0xd0, 0x57, 0xc2, 0x7a, 0xeb, 0x2b, 0xa1, 0x77, 0x6e, 0x59, 0x5e, 0x73, 0x74, 0xe4,
+	0x53, 0x03, 0x34, 0x5b, 0x8a, 0x32, 0xe6, 0xd6, 0x8b, 0x01, 0xfa, 0xba, 0x7c, 0x16, 0xd5, 0x3a,
+	0x32, 0x92, 0x6b, 0xae, 0x8b, 0x66, 0x6e, 0x50, 0x3e, 0x80, 0x41, 0xca, 0x64, 0x30, 0x45, 0x5e,
+	0x2f, 0x4d, 0x5a, 0x9b, 0x5a, 0x05, 0x60, 0x0e, 0x47, 0x10, 0x7e, 0xed, 0xeb, 0x1b, 0x68, 0xa6,
+	0xe9, 0x7f, 0x98, 0xd8, 0xe2, 0x58, 0xcb, 0x63, 0x53, 0x9a, 0x3f, 0x37, 0x7d, 0x04, 0x63, 0xa9,
+	0x98, 0xaa, 0xa5, 0x6d, 0xac, 0x45, 0x0f, 0x3a, 0x34, 0x7a, 0xd8, 0xa1, 0x19, 0x1c, 0xc6, 0x75,
+	0x51, 0xe7, 0x4c, 0x65, 0x5b, 0x1e, 0xb5, 0x51, 0xa6, 0xc7, 0xff, 0xee, 0xa4, 0xf7, 0x36, 0xfe,
+	0x19, 0x1c, 0xc4, 0xa2, 0x54, 0x15, 0x8b, 0x55, 0xc4, 0x92, 0xa4, 0xe2, 0x52, 0x62, 0xa1, 0x7c,
+	0xba, 0xdf, 0xf0, 0x6f, 0x0d, 0x4d, 0x4e, 0x60, 0x98, 0x8b, 0xd4, 0xf4, 0xdd, 0x9b, 0x7b, 0xcd,
+	0xc8, 0x5f, 0x8b, 0x94, 0xa2, 0x10, 0x32, 0x18, 0x5c, 0x8b, 0x94, 0x04, 0x30, 0x69, 0x4e, 0x32,
+	0x75, 0x68, 0xa0, 0xf6, 0xa3, 0xc4, 0x26, 0x8b, 0x65, 0xd0, 0xc7, 0xd9, 0xb1, 0x48, 0x37, 0x37,
+	0x61, 0x8a, 0xd9, 0xc1, 0xc6, 0xf5, 0xae, 0x22, 0xc3, 0x4e, 0x45, 0xc2, 0x6f, 0x0e, 0xc0, 0xee,
+	0x5e, 0xfe, 0x55, 0xc9, 0x9b, 0x89, 0x1a, 0x74, 0x26, 0xea, 0x08, 0xc6, 0x05, 0x57, 0x2b, 0x91,
+	0xe0, 0x57, 0xa7, 0xd4, 0x22, 0x1d, 0xcb, 0xaa, 0x54, 0xda, 0x59, 0xc3, 0xb5, 0x8e, 0xbd, 0x63,
+	0x59, 0x6e, 0x8b, 0xeb, 0x52, 0x8b, 0xc2, 0x57, 0xe0, 0x36, 0xaf, 0xc0, 0xef, 0x5c, 0xf2, 0xf9,
+	0x1b, 0xf0, 0xde, 0xad, 0x58, 0x56, 0xde, 0x62, 0x59, 0xc9, 0x05, 0x4c, 0x6f, 0xeb, 0xa5, 0x8c,
+	0xab, 0x6c, 0xc9, 0x49, 0xe7, 0x11, 0xc3, 0xd7, 0xfe, 0xb8, 0x7d, 0x70, 0x9a, 0x87, 0xf5, 0xc2,
+	0x59, 0x8e, 0xf1, 0xe7, 0xf0, 0xe2, 0xd7, 0x00, 0xa9, 0xe6, 0xa9, 0x6a, 0x2c, 0x06, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// ChainStreamClient is the client API for ChainStream service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type ChainStreamClient interface {
+	// Subscribe streams the blocks from the requested one on, following the head
+	// of the chain. A client slower than the chain lags behind the head, the
+	// flow control of the call holding the server back.
+	Subscribe(ctx context.Context, in *Request, opts ...grpc.CallOption) (ChainStream_SubscribeClient, error)
+}
+
+type chainStreamClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewChainStreamClient(cc *grpc.ClientConn) ChainStreamClient {
+	return &chainStreamClient{cc}
+}
+
+func (c *chainStreamClient) Subscribe(ctx context.Context, in *Request, opts ...grpc.CallOption) (ChainStream_SubscribeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_ChainStream_serviceDesc.Streams[0], "/stream.ChainStream/Subscribe", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &chainStreamSubscribeClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ChainStream_SubscribeClient interface {
+	Recv() (*Envelope, error)
+	grpc.ClientStream
+}
+
+type chainStreamSubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *chainStreamSubscribeClient) Recv() (*Envelope, error) {
+	m := new(Envelope)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ChainStreamServer is the server API for ChainStream service.
+type ChainStreamServer interface {
+	// Subscribe streams the blocks from the requested one on, following the head
+	// of the chain. A client slower than the chain lags behind the head, the
+	// flow control of the call holding the server back.
+	Subscribe(*Request, ChainStream_SubscribeServer) error
+}
+
+func RegisterChainStreamServer(s *grpc.Server, srv ChainStreamServer) {
+	s.RegisterService(&_ChainStream_serviceDesc, srv)
+}
+
+func _ChainStream_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Request)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ChainStreamServer).Subscribe(m, &chainStreamSubscribeServer{stream})
+}
+
+type ChainStream_SubscribeServer interface {
+	Send(*Envelope) error
+	grpc.ServerStream
+}
+
+type chainStreamSubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *chainStreamSubscribeServer) Send(m *Envelope) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _ChainStream_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "stream.ChainStream",
+	HandlerType: (*ChainStreamServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _ChainStream_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "stream.proto",
+}
@@ -0,0 +1,333 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ebakus/go-ebakus/accounts/abi"
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/core/vm"
+	"github.com/ebakus/go-ebakus/rpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// writeTimeout is the time allowed to a client to take a message. Slow
+	// clients only lag behind the head, but stalled ones are dropped.
+	writeTimeout = 30 * time.Second
+
+	// rollbackDepth is the number of streamed blocks remembered to find where
+	// a reorg forked off, deeper reorgs ending the stream.
+	rollbackDepth = 128
+)
+
+var (
+	errTooManyStreams   = status.Error(codes.ResourceExhausted, "too many streams")
+	errStreamStalled    = status.Error(codes.DeadlineExceeded, "stream stalled")
+	errRollbackTooDeep  = status.Error(codes.Aborted, "reorg deeper than the rollback window")
+	errStreamHeadClosed = status.Error(codes.Unavailable, "chain head subscription closed")
+)
+
+// stakeMethods are the system contract methods streamed as stake events.
+var stakeMethods = map[string]bool{
+	vm.SystemContractStakeCmd:           true,
+	vm.SystemContractUnstakeCmd:         true,
+	vm.SystemContractClaimCmd:           true,
+	vm.SystemContractVoteCmd:            true,
+	vm.SystemContractUnvoteCmd:          true,
+	vm.SystemContractUnvoteAddressesCmd: true,
+	vm.SystemContractElectEnableCmd:     true,
+}
+
+// streamedBlock is a block sent to the client, kept to detect reorgs.
+type streamedBlock struct {
+	number uint64
+	hash   common.Hash
+}
+
+// streamer streams the chain to a single client. Blocks are read one by one
+// as they are sent, and sends block while the flow control window of the call
+// is full, so the pace of the client bounds the work done for it: a client
+// slower than the chain lags behind the head without the server buffering
+// anything on its behalf.
+type streamer struct {
+	backend Backend
+	stream  ChainStream_SubscribeServer
+	signer  types.Signer
+
+	stalled   chan struct{} // Closed if the client doesn't take a message in time
+	stallOnce sync.Once
+
+	streamed []streamedBlock // Last blocks sent, oldest first
+}
+
+// newStreamer creates a streamer sending to the given call.
+func newStreamer(backend Backend, stream ChainStream_SubscribeServer) *streamer {
+	return &streamer{
+		backend: backend,
+		stream:  stream,
+		signer:  types.MakeSigner(backend.ChainConfig()),
+		stalled: make(chan struct{}),
+	}
+}
+
+// run streams the chain as requested until the context is canceled, the client
+// goes away or stalls. A stalled client is dropped by returning, which ends the
+// call and unblocks the pending send.
+func (s *streamer) run(ctx context.Context, req *Request) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.loop(ctx, req)
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-s.stalled:
+		return errStreamStalled
+	}
+}
+
+// loop streams the blocks, waiting for new ones whenever it reaches the head.
+func (s *streamer) loop(ctx context.Context, req *Request) error {
+	systemABI, err := abi.JSON(strings.NewReader(vm.SystemContractABI))
+	if err != nil {
+		return err
+	}
+	// Head events only wake the streamer, which reads the blocks by itself, so
+	// a busy stream never holds up the chain feed
+	var (
+		headCh = make(chan core.ChainHeadEvent, 10)
+		wakeCh = make(chan struct{}, 1)
+	)
+	sub := s.backend.SubscribeChainHeadEvent(headCh)
+	defer sub.Unsubscribe()
+
+	go func() {
+		for {
+			select {
+			case <-headCh:
+				select {
+				case wakeCh <- struct{}{}:
+				default:
+				}
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+
+	next := req.FromBlock
+	if req.Latest {
+		head, err := s.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+		if err != nil {
+			return err
+		}
+		next = head.Number.Uint64() + 1
+	}
+	for {
+		head, err := s.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+		if err != nil {
+			return err
+		}
+		for next <= head.Number.Uint64() && ctx.Err() == nil {
+			block, err := s.backend.BlockByNumber(ctx, rpc.BlockNumber(next))
+			if err != nil {
+				return err
+			}
+			if block == nil {
+				break // Chain shortened by a reorg meanwhile
+			}
+			if last := len(s.streamed) - 1; last >= 0 && s.streamed[last].number+1 == next && s.streamed[last].hash != block.ParentHash() {
+				if next, err = s.rollback(ctx); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := s.sendBlock(ctx, req, &systemABI, block); err != nil {
+				return err
+			}
+			next++
+		}
+		select {
+		case <-wakeCh:
+		case <-sub.Err():
+			return errStreamHeadClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// rollback finds the last streamed block still canonical and tells the client
+// to roll back to it, returning the number of the block to stream next.
+func (s *streamer) rollback(ctx context.Context) (uint64, error) {
+	for i := len(s.streamed) - 1; i >= 0; i-- {
+		header, err := s.backend.HeaderByNumber(ctx, rpc.BlockNumber(s.streamed[i].number))
+		if err != nil {
+			return 0, err
+		}
+		if header == nil || header.Hash() != s.streamed[i].hash {
+			continue
+		}
+		s.streamed = s.streamed[:i+1]
+		rollback := &Rollback{Number: header.Number.Uint64(), Hash: header.Hash().Bytes()}
+		if err := s.send(&Envelope{Message: &Envelope_Rollback{Rollback: rollback}}); err != nil {
+			return 0, err
+		}
+		return rollback.Number + 1, nil
+	}
+	return 0, errRollbackTooDeep
+}
+
+// sendBlock writes a block to the client, followed by its transactions,
+// receipts and stake events as requested.
+func (s *streamer) sendBlock(ctx context.Context, req *Request, systemABI *abi.ABI, block *types.Block) error {
+	var (
+		number = block.NumberU64()
+		txs    = block.Transactions()
+	)
+	hashes := make([][]byte, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash().Bytes()
+	}
+	producer, err := s.backend.GetBlockAuthor(block.Header())
+	if err != nil {
+		return err
+	}
+	msg := &Block{
+		Number:     number,
+		Hash:       block.Hash().Bytes(),
+		ParentHash: block.ParentHash().Bytes(),
+		Time:       block.Time(),
+		Producer:   producer.Bytes(),
+		GasLimit:   block.GasLimit(),
+		GasUsed:    block.GasUsed(),
+		TxHashes:   hashes,
+	}
+	if err := s.send(&Envelope{Message: &Envelope_Block{Block: msg}}); err != nil {
+		return err
+	}
+	var receipts types.Receipts
+	if len(txs) > 0 && (req.Receipts || req.StakeEvents) {
+		if receipts, err = s.backend.GetReceipts(ctx, block.Hash()); err != nil {
+			return err
+		}
+		if len(receipts) != len(txs) {
+			return fmt.Errorf("missing receipts of block %d: have %d, want %d", number, len(receipts), len(txs))
+		}
+	}
+	senders := make([]common.Address, len(txs))
+	for i, tx := range txs {
+		from, err := types.Sender(s.signer, tx)
+		if err != nil {
+			return err
+		}
+		senders[i] = from
+	}
+	if req.Transactions {
+		for i, tx := range txs {
+			msg := &Transaction{
+				Hash:        tx.Hash().Bytes(),
+				BlockNumber: number,
+				Index:       uint32(i),
+				From:        senders[i].Bytes(),
+				Value:       tx.Value().Bytes(),
+				Nonce:       tx.Nonce(),
+				WorkNonce:   tx.WorkNonce(),
+				Gas:         tx.Gas(),
+				Input:       tx.Data(),
+			}
+			if to := tx.To(); to != nil {
+				msg.To = to.Bytes()
+			}
+			if err := s.send(&Envelope{Message: &Envelope_Transaction{Transaction: msg}}); err != nil {
+				return err
+			}
+		}
+	}
+	if req.Receipts {
+		for i, receipt := range receipts {
+			msg := &Receipt{
+				TxHash:            receipt.TxHash.Bytes(),
+				BlockNumber:       number,
+				Index:             uint32(i),
+				Status:            receipt.Status,
+				GasUsed:           receipt.GasUsed,
+				CumulativeGasUsed: receipt.CumulativeGasUsed,
+			}
+			if receipt.ContractAddress != (common.Address{}) {
+				msg.ContractAddress = receipt.ContractAddress.Bytes()
+			}
+			for _, l := range receipt.Logs {
+				topics := make([][]byte, len(l.Topics))
+				for j, topic := range l.Topics {
+					topics[j] = topic.Bytes()
+				}
+				msg.Logs = append(msg.Logs, &Log{Address: l.Address.Bytes(), Topics: topics, Data: l.Data, Index: uint32(l.Index)})
+			}
+			if err := s.send(&Envelope{Message: &Envelope_Receipt{Receipt: msg}}); err != nil {
+				return err
+			}
+		}
+	}
+	if req.StakeEvents {
+		for i, tx := range txs {
+			if to := tx.To(); to == nil || *to != types.PrecompliledSystemContract || len(tx.Data()) < 4 {
+				continue
+			}
+			method, err := systemABI.MethodById(tx.Data()[:4])
+			if err != nil || !stakeMethods[method.Name] {
+				continue
+			}
+			msg := &StakeEvent{
+				TxHash:      tx.Hash().Bytes(),
+				BlockNumber: number,
+				From:        senders[i].Bytes(),
+				Method:      method.Name,
+				Args:        tx.Data()[4:],
+				Failed:      receipts[i].Status == types.ReceiptStatusFailed,
+			}
+			if err := s.send(&Envelope{Message: &Envelope_StakeEvent{StakeEvent: msg}}); err != nil {
+				return err
+			}
+		}
+	}
+	s.streamed = append(s.streamed, streamedBlock{number: number, hash: block.Hash()})
+	if len(s.streamed) > rollbackDepth {
+		s.streamed = s.streamed[len(s.streamed)-rollbackDepth:]
+	}
+	return nil
+}
+
+// send sends a message to the client, flagging the stream stalled if the client
+// doesn't take it in time.
+func (s *streamer) send(msg *Envelope) error {
+	timer := time.AfterFunc(writeTimeout, func() {
+		s.stallOnce.Do(func() { close(s.stalled) })
+	})
+	defer timer.Stop()
+
+	return s.stream.Send(msg)
+}
@@ -0,0 +1,232 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"context"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ebakus/go-ebakus/accounts/abi"
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/core"
+	"github.com/ebakus/go-ebakus/core/types"
+	"github.com/ebakus/go-ebakus/core/vm"
+	"github.com/ebakus/go-ebakus/crypto"
+	"github.com/ebakus/go-ebakus/event"
+	"github.com/ebakus/go-ebakus/params"
+	"github.com/ebakus/go-ebakus/rpc"
+	"google.golang.org/grpc"
+)
+
+var (
+	testKey, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddress  = crypto.PubkeyToAddress(testKey.PublicKey)
+	testProducer = common.HexToAddress("0xfeed")
+)
+
+// testBackend is a chain held in memory, swapped wholesale to simulate reorgs.
+type testBackend struct {
+	lock     sync.Mutex
+	blocks   []*types.Block
+	receipts map[common.Hash]types.Receipts
+	feed     event.Feed
+}
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	block, _ := b.BlockByNumber(ctx, number)
+	if block == nil {
+		return nil, nil
+	}
+	return block.Header(), nil
+}
+
+func (b *testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if number == rpc.LatestBlockNumber {
+		return b.blocks[len(b.blocks)-1], nil
+	}
+	if int(number) >= len(b.blocks) {
+		return nil, nil
+	}
+	return b.blocks[number], nil
+}
+
+func (b *testBackend) GetBlockAuthor(header *types.Header) (common.Address, error) {
+	return testProducer, nil
+}
+
+func (b *testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.receipts[hash], nil
+}
+
+func (b *testBackend) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return b.feed.Subscribe(ch)
+}
+
+func (b *testBackend) ChainConfig() *params.ChainConfig {
+	return params.TestChainConfig
+}
+
+// extend appends blocks to the chain from the given number on, dropping the
+// ones after it, each holding the given transactions. The seed tells apart the
+// blocks of different forks.
+func (b *testBackend) extend(t *testing.T, from int, count int, seed uint64, txs ...*types.Transaction) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.receipts == nil {
+		b.receipts = make(map[common.Hash]types.Receipts)
+	}
+	b.blocks = b.blocks[:from]
+	for i := 0; i < count; i++ {
+		header := &types.Header{Number: big.NewInt(int64(from + i)), Time: seed}
+		if len(b.blocks) > 0 {
+			header.ParentHash = b.blocks[len(b.blocks)-1].Hash()
+		}
+		receipts := make(types.Receipts, len(txs))
+		for j, tx := range txs {
+			receipts[j] = &types.Receipt{Status: types.ReceiptStatusSuccessful, TxHash: tx.Hash(), GasUsed: 21000}
+		}
+		block := types.NewBlock(header, txs, receipts, nil)
+		b.blocks = append(b.blocks, block)
+		b.receipts[block.Hash()] = receipts
+	}
+}
+
+// testClient serves the streams of the backend on a local port, returning the
+// call of a client subscribing with the given request.
+func testClient(t *testing.T, backend *testBackend, req *Request) (ChainStream_SubscribeClient, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := grpc.NewServer()
+	RegisterChainStreamServer(server, &Service{backend: backend})
+	go server.Serve(listener)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := NewChainStreamClient(conn).Subscribe(ctx, req)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	return stream, func() {
+		cancel()
+		conn.Close()
+		server.Stop()
+	}
+}
+
+// expect reads the next message of the stream.
+func expect(t *testing.T, stream ChainStream_SubscribeClient) *Envelope {
+	t.Helper()
+
+	msg, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("failed to read message: %v", err)
+	}
+	return msg
+}
+
+// Tests that the blocks are streamed in order, each followed by its
+// transactions, receipts and stake events.
+func TestStreamBlocks(t *testing.T) {
+	systemABI, _ := abi.JSON(strings.NewReader(vm.SystemContractABI))
+	input, err := systemABI.Pack(vm.SystemContractStakeCmd, uint64(10))
+	if err != nil {
+		t.Fatalf("failed to pack stake: %v", err)
+	}
+	signer := types.MakeSigner(params.TestChainConfig)
+	stake, _ := types.SignTx(types.NewTransaction(0, 0, types.PrecompliledSystemContract, new(big.Int), 100000, input), signer, testKey)
+	transfer, _ := types.SignTx(types.NewTransaction(0, 1, common.HexToAddress("0xb0b"), big.NewInt(1), 21000, nil), signer, testKey)
+
+	backend := new(testBackend)
+	backend.extend(t, 0, 1, 0)
+	backend.extend(t, 1, 2, 0, stake, transfer)
+
+	stream, stop := testClient(t, backend, &Request{FromBlock: 1, Transactions: true, Receipts: true, StakeEvents: true})
+	defer stop()
+
+	for number := uint64(1); number <= 2; number++ {
+		block := expect(t, stream).GetBlock()
+		if block == nil || block.Number != number || len(block.TxHashes) != 2 {
+			t.Fatalf("block %d: have %v", number, block)
+		}
+		if producer := common.BytesToAddress(block.Producer); producer != testProducer {
+			t.Errorf("block %d: producer mismatch: have %x, want %x", number, producer, testProducer)
+		}
+		for i, tx := range []*types.Transaction{stake, transfer} {
+			msg := expect(t, stream).GetTransaction()
+			if msg == nil || common.BytesToHash(msg.Hash) != tx.Hash() {
+				t.Fatalf("block %d: transaction %d: have %v", number, i, msg)
+			}
+			if from := common.BytesToAddress(msg.From); from != testAddress {
+				t.Errorf("block %d: transaction %d: sender mismatch: have %x, want %x", number, i, from, testAddress)
+			}
+		}
+		for i, tx := range []*types.Transaction{stake, transfer} {
+			if msg := expect(t, stream).GetReceipt(); msg == nil || common.BytesToHash(msg.TxHash) != tx.Hash() {
+				t.Fatalf("block %d: receipt %d: have %v", number, i, msg)
+			}
+		}
+		event := expect(t, stream).GetStakeEvent()
+		if event == nil || event.Method != vm.SystemContractStakeCmd || common.BytesToHash(event.TxHash) != stake.Hash() {
+			t.Fatalf("block %d: stake event: have %v", number, event)
+		}
+	}
+}
+
+// Tests that a reorg of streamed blocks rolls the client back to the last
+// block still canonical before streaming the new ones.
+func TestStreamRollback(t *testing.T) {
+	backend := new(testBackend)
+	backend.extend(t, 0, 3, 0)
+
+	stream, stop := testClient(t, backend, &Request{})
+	defer stop()
+
+	for number := uint64(0); number < 3; number++ {
+		if block := expect(t, stream).GetBlock(); block == nil || block.Number != number {
+			t.Fatalf("block %d: have %v", number, block)
+		}
+	}
+	backend.extend(t, 2, 2, 1)
+	backend.feed.Send(core.ChainHeadEvent{Block: backend.blocks[3]})
+
+	rollback := expect(t, stream).GetRollback()
+	if rollback == nil || rollback.Number != 1 || common.BytesToHash(rollback.Hash) != backend.blocks[1].Hash() {
+		t.Fatalf("rollback: have %v", rollback)
+	}
+	for number := uint64(2); number < 4; number++ {
+		block := expect(t, stream).GetBlock()
+		if block == nil || block.Number != number || common.BytesToHash(block.Hash) != backend.blocks[number].Hash() {
+			t.Fatalf("block %d: have %v", number, block)
+		}
+	}
+}
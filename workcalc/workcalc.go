@@ -0,0 +1,116 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package workcalc calculates the proof of work of unsigned transactions, so
+// they can be prepared offline without access to a node.
+package workcalc
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ebakus/go-ebakus/common"
+	"github.com/ebakus/go-ebakus/common/hexutil"
+	"github.com/ebakus/go-ebakus/core/types"
+)
+
+var (
+	errMissingNonce = errors.New("nonce not specified")
+	errMissingGas   = errors.New("gas not specified")
+)
+
+// Transaction is an unsigned transaction, in the format accepted by the
+// eth_calculateWorkNonce and eth_sendTransaction RPC calls.
+type Transaction struct {
+	From      *common.Address `json:"from,omitempty"`
+	To        *common.Address `json:"to"`
+	Gas       *hexutil.Uint64 `json:"gas"`
+	WorkNonce *hexutil.Uint64 `json:"workNonce"`
+	Value     *hexutil.Big    `json:"value"`
+	Nonce     *hexutil.Uint64 `json:"nonce"`
+	// We accept "data" and "input" for backwards-compatibility reasons. "input" is the
+	// newer name and should be preferred by clients.
+	Data  *hexutil.Bytes `json:"data,omitempty"`
+	Input *hexutil.Bytes `json:"input,omitempty"`
+}
+
+// ToTransaction assembles the unsigned transaction. The nonce and gas have to
+// be given, as they can't be filled in without a node, while a missing value
+// or work nonce default to zero.
+func (args *Transaction) ToTransaction() (*types.Transaction, error) {
+	if args.Nonce == nil {
+		return nil, errMissingNonce
+	}
+	if args.Gas == nil || *args.Gas == 0 {
+		return nil, errMissingGas
+	}
+	var (
+		workNonce uint64
+		value     = new(big.Int)
+		input     []byte
+	)
+	if args.WorkNonce != nil {
+		workNonce = uint64(*args.WorkNonce)
+	}
+	if args.Value != nil {
+		value = (*big.Int)(args.Value)
+	}
+	if args.Input != nil {
+		input = *args.Input
+	} else if args.Data != nil {
+		input = *args.Data
+	}
+	if args.To == nil {
+		return types.NewContractCreation(workNonce, uint64(*args.Nonce), value, uint64(*args.Gas), input), nil
+	}
+	return types.NewTransaction(workNonce, uint64(*args.Nonce), *args.To, value, uint64(*args.Gas), input), nil
+}
+
+// Result is a transaction along with its calculated work nonce.
+type Result struct {
+	Transaction
+	Difficulty float64 `json:"difficulty"` // Difficulty per gas achieved by the work nonce
+}
+
+// Calculate searches for the work nonce of the transaction meeting the target
+// difficulty per gas, like eth_calculateWorkNonce does, on the given number of
+// threads (one per CPU if not positive).
+//
+// If ctx is done before the target difficulty is met, the result carries the
+// best work nonce found so far and the context error is returned along with it.
+func Calculate(ctx context.Context, args *Transaction, targetDifficulty float64, threads int) (*Result, error) {
+	tx, err := args.ToTransaction()
+	if err != nil {
+		return nil, err
+	}
+	err = tx.CalculateWorkNonceCtx(ctx, targetDifficulty*float64(tx.Gas()), threads)
+
+	workNonce := hexutil.Uint64(tx.WorkNonce())
+	result := &Result{
+		Transaction: *args,
+		Difficulty:  Difficulty(tx),
+	}
+	result.WorkNonce = &workNonce
+
+	return result, err
+}
+
+// Difficulty returns the difficulty per gas achieved by the work nonce of the
+// transaction, which is what nodes compare against their minimum difficulty.
+func Difficulty(tx *types.Transaction) float64 {
+	return tx.CalculateDifficulty() / float64(tx.Gas())
+}
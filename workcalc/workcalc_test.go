@@ -0,0 +1,95 @@
+// Copyright 2019 The ebakus/go-ebakus Authors
+// This file is part of the ebakus/go-ebakus library.
+//
+// The ebakus/go-ebakus library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The ebakus/go-ebakus library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the ebakus/go-ebakus library. If not, see <http://www.gnu.org/licenses/>.
+
+package workcalc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// Tests that the work nonce calculated meets the target difficulty, and that it
+// is kept by the transaction assembled from the result.
+func TestCalculate(t *testing.T) {
+	var args Transaction
+	input := `{"to":"0x000000000000000000000000000000000000dead","gas":"0x5208","value":"0x1","nonce":"0x3"}`
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Fatalf("failed to decode transaction: %v", err)
+	}
+	result, err := Calculate(context.Background(), &args, 0.01, 2)
+	if err != nil {
+		t.Fatalf("failed to calculate work nonce: %v", err)
+	}
+	if result.WorkNonce == nil {
+		t.Fatalf("work nonce missing")
+	}
+	if result.Difficulty < 0.01 {
+		t.Errorf("difficulty mismatch: have %v, want at least 0.01", result.Difficulty)
+	}
+	tx, err := result.ToTransaction()
+	if err != nil {
+		t.Fatalf("failed to assemble transaction: %v", err)
+	}
+	if tx.WorkNonce() != uint64(*result.WorkNonce) {
+		t.Errorf("work nonce mismatch: have %d, want %d", tx.WorkNonce(), *result.WorkNonce)
+	}
+	if difficulty := Difficulty(tx); difficulty != result.Difficulty {
+		t.Errorf("difficulty mismatch: have %v, want %v", difficulty, result.Difficulty)
+	}
+}
+
+// Tests that an unreachable target difficulty returns the best work nonce found
+// until the context is done.
+func TestCalculateTimeout(t *testing.T) {
+	var args Transaction
+	input := `{"gas":"0x5208","nonce":"0x0","input":"0x6000"}`
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Fatalf("failed to decode transaction: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	result, err := Calculate(ctx, &args, 1e30, 1)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("error mismatch: have %v, want %v", err, context.DeadlineExceeded)
+	}
+	if result == nil || result.WorkNonce == nil || result.Difficulty <= 0 {
+		t.Errorf("best work nonce missing: %+v", result)
+	}
+}
+
+// Tests that the fields needing a node to fill in are required.
+func TestCalculateMissingFields(t *testing.T) {
+	tests := []struct {
+		input string
+		err   error
+	}{
+		{`{"gas":"0x5208"}`, errMissingNonce},
+		{`{"nonce":"0x0"}`, errMissingGas},
+		{`{"nonce":"0x0","gas":"0x0"}`, errMissingGas},
+	}
+	for i, tt := range tests {
+		var args Transaction
+		if err := json.Unmarshal([]byte(tt.input), &args); err != nil {
+			t.Fatalf("test %d: failed to decode transaction: %v", i, err)
+		}
+		if _, err := Calculate(context.Background(), &args, 1, 1); err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}